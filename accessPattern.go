@@ -0,0 +1,121 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+)
+
+// AccessPattern is the access pattern of the bloom filter declared to the kernel, see Controller.AccessPattern.
+type AccessPattern uint8
+
+const (
+	// AccessPatternNormal leaves the readahead of the kernel as is.
+	AccessPatternNormal AccessPattern = iota
+	// AccessPatternRandom disables the readahead, which otherwise reads a window of pages around each probe
+	// of a filter larger than the page cache, evicting the hot pages.
+	AccessPatternRandom
+	// AccessPatternSequential doubles the readahead, e.g. for filters mostly merged, cloned or scanned.
+	AccessPatternSequential
+	// AccessPatternWillNeed reads the filter into the page cache in the background, see WarmUp for the blocking counterpart.
+	AccessPatternWillNeed
+	// AccessPatternDontNeed drops the clean pages of the filter from the page cache, e.g. for a filter rarely looked up.
+	AccessPatternDontNeed
+)
+
+func (p AccessPattern) String() string {
+	switch p {
+	case AccessPatternNormal:
+		return "normal"
+	case AccessPatternRandom:
+		return "random"
+	case AccessPatternSequential:
+		return "sequential"
+	case AccessPatternWillNeed:
+		return "willneed"
+	case AccessPatternDontNeed:
+		return "dontneed"
+	default:
+		return "invalid"
+	}
+}
+
+// warmUpChunk is the size of the reads of WarmUp.
+const warmUpChunk = 1 << 20
+
+// Advise declares the access pattern of the bloom filter to the kernel by posix_fadvise on the file,
+// and by madvise on the mapping of Controller.Mmap, which is applied again if the filter is mapped again.
+// It is a hint, which returns UnsupportedErr on platforms other than Linux and for the files opened by OpenFS.
+func (f *DiskFilter) Advise(pattern AccessPattern) error {
+	if pattern > AccessPatternDontNeed {
+		return fmt.Errorf("%w: access pattern %v", UnsupportedErr, pattern)
+	}
+	if !f.acquire() {
+		return ClosedErr
+	}
+	defer f.release()
+	f.lock()
+	defer f.file.mu.Unlock()
+	if err := f.adviseLocked(pattern); err != nil {
+		return err
+	}
+	f.controller.AccessPattern = pattern
+	return nil
+}
+
+func (f *DiskFilter) adviseLocked(pattern AccessPattern) error {
+	file := f.file.osFile()
+	if file == nil {
+		return UnsupportedErr
+	}
+	// the length 0 advises the file up to its end
+	if err := fadviseFile(file, f.bloomStart, 0, pattern); err != nil {
+		return err
+	}
+	if f.mapped != nil {
+		return madviseMem(f.mapped.mem, pattern)
+	}
+	return nil
+}
+
+// WarmUp reads the whole bloom filter into the page cache, or faults in the pages of the mapping of Controller.Mmap,
+// so that the first lookups after a restart do not wait for the disk one page at a time.
+// It blocks until the filter is read, unlike AccessPatternWillNeed. The reads bypass Controller.BlockCache,
+// whose pages are kept for the lookups.
+func (f *DiskFilter) WarmUp() error {
+	if !f.acquire() {
+		return ClosedErr
+	}
+	defer f.release()
+	f.rlock()
+	defer f.file.mu.RUnlock()
+	if file := f.file.osFile(); file != nil {
+		// start the readahead of the whole file, which the reads below wait for; it is only a hint
+		_ = fadviseFile(file, f.bloomStart, 0, AccessPatternWillNeed)
+	}
+	if m := f.mapped; m != nil {
+		_ = madviseMem(m.mem, AccessPatternWillNeed)
+		var sum byte
+		for i := 0; i < len(m.mem); i += pageSize {
+			sum += m.mem[i]
+		}
+		// keep the loads from being optimized away
+		runtime.KeepAlive(sum)
+		return nil
+	}
+	info, err := f.file.f.Stat()
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, warmUpChunk)
+	for offset := f.bloomStart; offset < info.Size(); offset += warmUpChunk {
+		n := int64(warmUpChunk)
+		if info.Size()-offset < n {
+			n = info.Size() - offset
+		}
+		if _, err := (retryStorage{f.file.f}).ReadAt(buf[:n], offset); err != nil && err != io.EOF {
+			return err
+		}
+	}
+	return nil
+}
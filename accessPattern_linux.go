@@ -0,0 +1,36 @@
+//go:build amd64 || arm64 || ppc64 || ppc64le || mips64 || mips64le || riscv64
+
+package disk_bloom
+
+import (
+	"os"
+	"syscall"
+)
+
+// fadviseFile advises the range of the file by posix_fadvise, where length 0 means up to the end of the file.
+// The advices of s390x differ, so it is limited to the 64-bit platforms passing the offsets in single registers.
+func fadviseFile(f *os.File, offset int64, length int64, pattern AccessPattern) error {
+	advice := map[AccessPattern]uintptr{
+		AccessPatternNormal:     0, // POSIX_FADV_NORMAL
+		AccessPatternRandom:     1, // POSIX_FADV_RANDOM
+		AccessPatternSequential: 2, // POSIX_FADV_SEQUENTIAL
+		AccessPatternWillNeed:   3, // POSIX_FADV_WILLNEED
+		AccessPatternDontNeed:   4, // POSIX_FADV_DONTNEED
+	}[pattern]
+	_, _, errno := syscall.Syscall6(syscall.SYS_FADVISE64, f.Fd(), uintptr(offset), uintptr(length), advice, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func madviseMem(mem []byte, pattern AccessPattern) error {
+	advice := map[AccessPattern]int{
+		AccessPatternNormal:     syscall.MADV_NORMAL,
+		AccessPatternRandom:     syscall.MADV_RANDOM,
+		AccessPatternSequential: syscall.MADV_SEQUENTIAL,
+		AccessPatternWillNeed:   syscall.MADV_WILLNEED,
+		AccessPatternDontNeed:   syscall.MADV_DONTNEED,
+	}[pattern]
+	return syscall.Madvise(mem, advice)
+}
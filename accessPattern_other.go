@@ -0,0 +1,14 @@
+//go:build !linux || !(amd64 || arm64 || ppc64 || ppc64le || mips64 || mips64le || riscv64)
+
+package disk_bloom
+
+import "os"
+
+// fadviseFile is only supported on 64-bit Linux, so the access patterns are not declared elsewhere.
+func fadviseFile(f *os.File, offset int64, length int64, pattern AccessPattern) error {
+	return UnsupportedErr
+}
+
+func madviseMem(mem []byte, pattern AccessPattern) error {
+	return UnsupportedErr
+}
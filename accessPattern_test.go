@@ -0,0 +1,71 @@
+package disk_bloom
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDiskFilter_Advise(t *testing.T) {
+	for name, controller := range map[string]Controller{
+		"file": {AccessPattern: AccessPatternRandom},
+		"mmap": {AccessPattern: AccessPatternRandom, Mmap: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			bf := newTestFilter(t, controller)
+			for i := 0; i < 1000; i++ {
+				bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+			}
+			supported := fadviseFile(bf.file.osFile(), 0, 0, AccessPatternNormal) == nil
+			for _, pattern := range []AccessPattern{AccessPatternSequential, AccessPatternWillNeed, AccessPatternDontNeed, AccessPatternNormal} {
+				if err := bf.Advise(pattern); supported && err != nil {
+					t.Fatalf("Should advise %v, got %v", pattern, err)
+				} else if !supported && !errors.Is(err, UnsupportedErr) {
+					t.Fatalf("Should be UnsupportedErr, got %v", err)
+				}
+			}
+			if err := bf.Advise(AccessPatternDontNeed + 1); !errors.Is(err, UnsupportedErr) {
+				t.Fatalf("Should reject an invalid pattern, got %v", err)
+			}
+			if err := bf.WarmUp(); err != nil {
+				t.Fatal(err)
+			}
+			// the advices drop no data
+			for i := 0; i < 1000; i++ {
+				if !bf.Exist([]byte(strconv.Itoa(i))) {
+					t.Fatalf("%v should be in the filter", i)
+				}
+			}
+			bf.Close()
+			if err := bf.WarmUp(); !errors.Is(err, ClosedErr) {
+				t.Fatalf("Should be ClosedErr, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDiskFilter_WarmUpFS(t *testing.T) {
+	bf := newTestFilter(t, Controller{})
+	bf.ExistOrAdd([]byte("a"))
+	bf.Close()
+	b, err := os.ReadFile("testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{"filter": {Data: b}}
+	f, err := OpenFS(fsys, "filter", Controller{AccessPattern: AccessPatternWillNeed, GetParam: func([]byte) (FilterParam, []byte) {
+		return FilterParam{Hash: doubleFNV}, nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err = f.WarmUp(); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Advise(AccessPatternRandom); !errors.Is(err, UnsupportedErr) {
+		t.Fatalf("Should be UnsupportedErr for files of an fs.FS, got %v", err)
+	}
+}
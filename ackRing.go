@@ -0,0 +1,263 @@
+package disk_bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"sort"
+	"sync"
+)
+
+// The ack ring of Controller.AckRing is a sidecar file of a fixed size next to the filter:
+//
+// | magic(8) | slots(4) | reserved(4) | slot | slot | ...
+//
+// where a slot is | ordinal(8) | seq(8) | hash X(8) | hash Y(8) | CRC32C of the rest(4) | reserved(4) |.
+// The record of the ordinal i, counted from 1, is written to the slot i%slots, and a torn slot is skipped on open.
+const (
+	ackRingMagic      = "DBACKS01"
+	ackRingHeaderSize = 16
+	ackRingSlotSize   = 40
+)
+
+// AckRingFilename returns the filename of the ack ring of the filter file filename.
+func AckRingFilename(filename string) string {
+	return filename + ".acks"
+}
+
+// AckRecord is an add of ExistOrAddSeq which is durable, see Controller.AckRing.
+type AckRecord struct {
+	// Seq is the sequence number given to ExistOrAddSeq, e.g. the offset of the message in its stream
+	Seq  uint64
+	Hash KeyHash
+}
+
+type ackRing struct {
+	f          *os.File
+	slots      uint64
+	readOnly   bool
+	durability DurabilityLevel
+
+	mu sync.Mutex
+	// next is the ordinal of the next record written
+	next uint64
+	// records are the durable records, the oldest first, and pending are the ones waiting for the sync of the filter
+	records []AckRecord
+	pending []AckRecord
+}
+
+// openAckRing opens the ack ring, and reads the records left by the last run.
+// The ring is rewritten if it has another number of slots.
+func openAckRing(filename string, slots int, readOnly bool, durability DurabilityLevel) (*ackRing, error) {
+	if slots <= 0 {
+		return nil, fmt.Errorf("%w: %v slots of the ack ring", InvalidParamErr, slots)
+	}
+	flag := os.O_CREATE | os.O_RDWR
+	if readOnly {
+		flag = os.O_RDONLY
+	}
+	f, err := os.OpenFile(filename, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+	r := &ackRing{f: f, slots: uint64(slots), readOnly: readOnly, durability: durability, next: 1}
+	if err = r.load(); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// load reads the records of the ring, and formats it if it is empty or of another number of slots.
+func (r *ackRing) load() error {
+	info, err := r.f.Stat()
+	if err != nil {
+		return err
+	}
+	var header [ackRingHeaderSize]byte
+	if info.Size() > 0 {
+		if _, err = (retryStorage{r.f}).ReadAt(header[:], 0); err != nil {
+			return err
+		}
+		if string(header[:8]) != ackRingMagic {
+			return fmt.Errorf("%w: not an ack ring", InvalidHeaderErr)
+		}
+		slots := uint64(binary.LittleEndian.Uint32(header[8:]))
+		b := make([]byte, slots*ackRingSlotSize)
+		n, _ := r.f.ReadAt(b, ackRingHeaderSize)
+		type ordered struct {
+			ordinal uint64
+			record  AckRecord
+		}
+		var found []ordered
+		for i := 0; i+ackRingSlotSize <= n; i += ackRingSlotSize {
+			s := b[i : i+ackRingSlotSize]
+			ordinal := binary.LittleEndian.Uint64(s)
+			if ordinal == 0 || binary.LittleEndian.Uint32(s[32:]) != crc32.Checksum(s[:32], castagnoli) {
+				continue
+			}
+			found = append(found, ordered{ordinal, AckRecord{
+				Seq:  binary.LittleEndian.Uint64(s[8:]),
+				Hash: KeyHash{X: binary.LittleEndian.Uint64(s[16:]), Y: binary.LittleEndian.Uint64(s[24:])},
+			}})
+		}
+		sort.Slice(found, func(i, j int) bool {
+			return found[i].ordinal < found[j].ordinal
+		})
+		for _, o := range found {
+			r.records = append(r.records, o.record)
+			r.next = o.ordinal + 1
+		}
+		if slots == r.slots || r.readOnly {
+			r.slots = slots
+			r.trim()
+			return nil
+		}
+	}
+	if r.readOnly {
+		return nil
+	}
+	// formatted with the records kept
+	r.trim()
+	copy(header[:], ackRingMagic)
+	binary.LittleEndian.PutUint32(header[8:], uint32(r.slots))
+	if err = r.f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err = (retryStorage{r.f}).WriteAt(header[:], 0); err != nil {
+		return err
+	}
+	if err = r.f.Truncate(ackRingHeaderSize + int64(r.slots)*ackRingSlotSize); err != nil {
+		return err
+	}
+	records := r.records
+	r.records, r.next = nil, 1
+	return r.write(records)
+}
+
+// trim keeps the last slots records.
+func (r *ackRing) trim() {
+	if n := uint64(len(r.records)); n > r.slots {
+		r.records = append(r.records[:0], r.records[n-r.slots:]...)
+	}
+}
+
+// write writes the records to the ring and syncs it. It should be invoked with mu held.
+func (r *ackRing) write(records []AckRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if uint64(len(records)) > r.slots {
+		r.next += uint64(len(records)) - r.slots
+		records = records[uint64(len(records))-r.slots:]
+	}
+	var s [ackRingSlotSize]byte
+	for i, record := range records {
+		ordinal := r.next + uint64(i)
+		binary.LittleEndian.PutUint64(s[:], ordinal)
+		binary.LittleEndian.PutUint64(s[8:], record.Seq)
+		binary.LittleEndian.PutUint64(s[16:], record.Hash.X)
+		binary.LittleEndian.PutUint64(s[24:], record.Hash.Y)
+		binary.LittleEndian.PutUint32(s[32:], crc32.Checksum(s[:32], castagnoli))
+		if _, err := (retryStorage{r.f}).WriteAt(s[:], ackRingHeaderSize+int64(ordinal%r.slots)*ackRingSlotSize); err != nil {
+			return err
+		}
+	}
+	if err := syncDurable(r.f, r.durability); err != nil {
+		return err
+	}
+	r.next += uint64(len(records))
+	r.records = append(r.records, records...)
+	r.trim()
+	return nil
+}
+
+// add records an add, which is written at once if it is durable, or by flush once the filter is synced.
+func (r *ackRing) add(record AckRecord, durable bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if durable {
+		return r.write([]AckRecord{record})
+	}
+	r.pending = append(r.pending, record)
+	if n := uint64(len(r.pending)); n > r.slots {
+		// overwritten in the ring anyway
+		r.pending = append(r.pending[:0], r.pending[n-r.slots:]...)
+	}
+	return nil
+}
+
+// flush writes the pending records, whose adds are synced. The records are kept pending if it fails.
+func (r *ackRing) flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.write(r.pending); err != nil {
+		return err
+	}
+	r.pending = r.pending[:0]
+	return nil
+}
+
+// dropPending drops the pending records, whose adds are lost, e.g. by ReplaceWith.
+func (r *ackRing) dropPending() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending = r.pending[:0]
+}
+
+// acked returns the durable records, the oldest first.
+func (r *ackRing) acked() []AckRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]AckRecord(nil), r.records...)
+}
+
+// ExistOrAddSeq is like ExistOrAddErr, and records the entry with seq in the ack ring once the add is durable,
+// see Controller.AckRing. It returns UnsupportedErr if the AckRing is not set.
+func (f *DiskFilter) ExistOrAddSeq(b []byte, seq uint64) (exist bool, err error) {
+	if f.acks == nil {
+		return false, fmt.Errorf("%w: Controller.AckRing is not set", UnsupportedErr)
+	}
+	h := f.Hash(b)
+	if exist, err = f.existOrAddHashed(h); err != nil {
+		return exist, err
+	}
+	// the adds of FsyncModeAlways are synced on return, and so are the ones journaled
+	durable := f.file.fsync == FsyncModeAlways || f.controller.Journal
+	return exist, f.acks.add(AckRecord{Seq: seq, Hash: h}, durable)
+}
+
+// Acked returns the last adds of ExistOrAddSeq which are durable, the oldest first, including the ones of the last run
+// read from the ack ring, so that a pipeline restarted after a crash resumes after the adds acknowledged.
+// It returns nil if the AckRing is not set.
+func (f *DiskFilter) Acked() []AckRecord {
+	if f.acks == nil {
+		return nil
+	}
+	return f.acks.acked()
+}
+
+// flushAcksLocked writes the records of the ack ring waiting for the sync of the filter, which is done.
+func (f *DiskFilter) flushAcksLocked() error {
+	if f.acks == nil {
+		return nil
+	}
+	return f.acks.flush()
+}
+
+// closeAcksLocked closes the ack ring, after writing the pending records if the filter file is synced.
+func (f *DiskFilter) closeAcksLocked(synced bool) error {
+	if f.acks == nil {
+		return nil
+	}
+	var err error
+	if synced && !f.acks.readOnly {
+		err = f.acks.flush()
+	}
+	if e := f.acks.f.Close(); err == nil {
+		err = e
+	}
+	f.acks = nil
+	return err
+}
@@ -0,0 +1,125 @@
+package disk_bloom
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func openAckFilter(t *testing.T, filename string, controller Controller) *DiskFilter {
+	controller.GetParam = func(metadata []byte) (FilterParam, []byte) {
+		slots, bits := OptimalParam(1e4, 1e-4)
+		return FilterParam{Slots: slots, Bits: bits, Hash: doubleFNV}, nil
+	}
+	bf, err := New(filename, controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bf
+}
+
+func checkAcked(t *testing.T, bf *DiskFilter, seqs ...uint64) {
+	t.Helper()
+	acked := bf.Acked()
+	if len(acked) != len(seqs) {
+		t.Fatalf("Should ack %v, got %+v", seqs, acked)
+	}
+	for i, seq := range seqs {
+		if acked[i].Seq != seq || acked[i].Hash != bf.Hash([]byte(strconv.FormatUint(seq, 10))) {
+			t.Fatalf("Should ack %v at %v, got %+v", seq, i, acked[i])
+		}
+	}
+}
+
+func TestDiskFilter_AckRing(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "filter")
+	bf := openAckFilter(t, filename, Controller{Fsync: FsyncModeAlways, AckRing: 4})
+	for seq := uint64(1); seq <= 10; seq++ {
+		if _, err := bf.ExistOrAddSeq([]byte(strconv.FormatUint(seq, 10)), seq); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkAcked(t, bf, 7, 8, 9, 10)
+	if err := bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(AckRingFilename(filename)); err != nil || info.Size() != ackRingHeaderSize+4*ackRingSlotSize {
+		t.Fatalf("Should be a ring of a fixed size, got %v", err)
+	}
+
+	// more slots, with the records kept
+	bf = openAckFilter(t, filename, Controller{Fsync: FsyncModeAlways, AckRing: 8})
+	checkAcked(t, bf, 7, 8, 9, 10)
+	if _, err := bf.ExistOrAddSeq([]byte("11"), 11); err != nil {
+		t.Fatal(err)
+	}
+	if err := bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	bf = openAckFilter(t, filename, Controller{ReadOnly: true, AckRing: 1})
+	checkAcked(t, bf, 7, 8, 9, 10, 11)
+	if _, err := bf.ExistOrAddSeq([]byte("12"), 12); !errors.Is(err, ReadOnlyErr) {
+		t.Fatalf("Should not add to a read-only filter, got %v", err)
+	}
+	_ = bf.Close()
+
+	// a torn slot is skipped, where 11 is the fifth record of the ring formatted again
+	raw, err := os.OpenFile(AckRingFilename(filename), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = raw.WriteAt([]byte{0xff}, ackRingHeaderSize+5*ackRingSlotSize+8); err != nil {
+		t.Fatal(err)
+	}
+	_ = raw.Close()
+	bf = openAckFilter(t, filename, Controller{Fsync: FsyncModeAlways, AckRing: 8})
+	defer bf.Close()
+	checkAcked(t, bf, 7, 8, 9, 10)
+}
+
+func TestDiskFilter_AckRingEverySec(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "filter")
+	bf := openAckFilter(t, filename, Controller{Fsync: FsyncModeEverySec, SyncInterval: MaxSyncInterval, AckRing: 16})
+	tick := func() {
+		bf.file.mu.Lock()
+		defer bf.file.mu.Unlock()
+		if err := bf.syncEverySecLocked(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for seq := uint64(1); seq <= 2; seq++ {
+		if _, err := bf.ExistOrAddSeq([]byte(strconv.FormatUint(seq, 10)), seq); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkAcked(t, bf)
+	tick()
+	checkAcked(t, bf, 1, 2)
+	// an entry existing writes nothing, and is acked by the next tick
+	if exist, err := bf.ExistOrAddSeq([]byte("2"), 2); err != nil || !exist {
+		t.Fatalf("Should exist, got %v, %v", exist, err)
+	}
+	tick()
+	checkAcked(t, bf, 1, 2, 2)
+	if _, err := bf.ExistOrAddSeq([]byte("3"), 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	bf = openAckFilter(t, filename, Controller{Fsync: FsyncModeEverySec, AckRing: 16})
+	defer bf.Close()
+	checkAcked(t, bf, 1, 2, 2, 3)
+}
+
+func TestDiskFilter_AckRingUnset(t *testing.T) {
+	bf := newTestFilter(t, Controller{})
+	if _, err := bf.ExistOrAddSeq([]byte("key"), 1); !errors.Is(err, UnsupportedErr) {
+		t.Fatalf("Should fail without AckRing, got %v", err)
+	}
+	if bf.Acked() != nil {
+		t.Fatal("Should ack nothing")
+	}
+}
@@ -0,0 +1,60 @@
+package disk_bloom
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+)
+
+// An adaptive filter sets Slots+AdaptiveSlots bits per entry, but Exist only probes as many of them as the fill ratio needs:
+// few probes give enough evidence while the filter is sparse, and more are needed as it fills.
+// It is experimental.
+
+// Adaptive returns whether Exist probes according to the fill ratio, see Controller.AdaptiveSlots.
+func (h Header) Adaptive() bool {
+	return h.Flags&FlagAdaptive != 0
+}
+
+// slots returns the number of bits set per entry.
+func (f *DiskFilter) slots() int {
+	return int(f.param.Slots) + int(f.header.AdaptiveSlots)
+}
+
+// lookupSlots returns the number of probes of Exist at the current fill ratio.
+// It grows by one extra probe per 1/(2*AdaptiveSlots) of the bits set, and probes all bits
+// once half of the bits are set, which is where a filter of optimal parameters reaches its capacity.
+func (f *DiskFilter) lookupSlots() int {
+	extra := uint64(f.header.AdaptiveSlots)
+	if extra == 0 {
+		return int(f.param.Slots)
+	}
+	if g := 2 * extra * atomic.LoadUint64(&f.setBits) / f.param.Bits; g < extra {
+		extra = g
+	}
+	return int(f.param.Slots) + int(extra)
+}
+
+// persistHeaderLocked writes the number of bits set and the times of the last add and sync into the header,
+// before it is signed.
+func (f *DiskFilter) persistHeaderLocked() error {
+	if f.header.Version == 0 || f.readOnly {
+		return nil
+	}
+	raw := retryStorage{f.file.f}
+	start := LenOfMetadataSize + int64(f.controller.MetadataSize)
+	if f.header.WearLeveled() {
+		return f.persistTimesSlotLocked(start)
+	}
+	var b [16]byte
+	lastAdd, lastSync := f.lastTimes()
+	putTime(b[:8], lastAdd)
+	putTime(b[8:], lastSync)
+	if _, err := raw.WriteAt(b[:], start+headerLastAddOffset); err != nil {
+		return err
+	}
+	if !f.header.Adaptive() {
+		return nil
+	}
+	binary.LittleEndian.PutUint64(b[:8], atomic.LoadUint64(&f.setBits))
+	_, err := raw.WriteAt(b[:8], start+headerSetBitsOffset)
+	return err
+}
@@ -0,0 +1,57 @@
+package disk_bloom
+
+import (
+	"math/bits"
+	"strconv"
+	"testing"
+)
+
+func TestDiskFilter_AdaptiveSlots(t *testing.T) {
+	const extra = 4
+	bf := newTestFilter(t, Controller{AdaptiveSlots: extra})
+	slots := int(bf.FilterParam().Slots)
+	if !bf.Header().Adaptive() || bf.lookupSlots() != slots {
+		t.Fatalf("An empty adaptive filter should probe %v slots, got %v", slots, bf.lookupSlots())
+	}
+	last := slots
+	for i := 0; i < 10000; i++ {
+		key := []byte(strconv.Itoa(i))
+		bf.ExistOrAdd(key)
+		if !bf.Exist(key) {
+			t.Fatalf("%v should exist in filter", i)
+		}
+		if n := bf.lookupSlots(); n < last {
+			t.Fatalf("The probes should not decrease, got %v after %v", n, last)
+		} else {
+			last = n
+		}
+	}
+	if last != slots+extra {
+		t.Fatalf("A full filter should probe %v slots, got %v", slots+extra, last)
+	}
+	// the fill ratio is tracked exactly
+	b := make([]byte, bf.header.bloomSize(bf.FilterParam().Bits))
+	if _, err := bf.file.rw.ReadAt(b, bf.fileOffset(0)); err != nil {
+		t.Fatal(err)
+	}
+	var set int
+	for _, v := range b {
+		set += bits.OnesCount8(v)
+	}
+	if want := float64(set) / float64(bf.FilterParam().Bits); bf.FillRatio() != want {
+		t.Fatalf("Got fill ratio %v, want %v", bf.FillRatio(), want)
+	}
+	fill := bf.FillRatio()
+	bf.Close()
+
+	// the parameters and the fill ratio are read from the header
+	bf = newTestFilter(t, Controller{})
+	if bf.Header().AdaptiveSlots != extra || bf.FillRatio() != fill {
+		t.Fatalf("Got %v extra slots and fill ratio %v after reopening, want %v and %v", bf.Header().AdaptiveSlots, bf.FillRatio(), extra, fill)
+	}
+	for i := 0; i < 10000; i++ {
+		if !bf.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should exist in filter", i)
+		}
+	}
+}
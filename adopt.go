@@ -0,0 +1,123 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// legacyFilter is a filter of a legacy pair, see AdoptLegacyPair.
+type legacyFilter struct {
+	filename string
+	// last is the time of the last add, or of the last modification of the file if it is not recorded
+	last     time.Time
+	metadata Metadata
+}
+
+// inspectLegacy reads the parameters, the estimated count and the time of the last add of the legacy filter.
+func inspectLegacy(filename string, legacy Controller) (legacyFilter, error) {
+	legacy.ReadOnly = true
+	f, err := New(filename, legacy)
+	if err != nil {
+		return legacyFilter{}, err
+	}
+	defer f.Close()
+	l := legacyFilter{filename: filename, last: f.Header().LastAdd}
+	if l.last.IsZero() {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return legacyFilter{}, err
+		}
+		l.last = info.ModTime()
+	}
+	// the capacity of optimal parameters, where Slots = Bits/capacity * ln2
+	capacity := uint64(float64(f.param.Bits) * math.Ln2 / float64(f.param.Slots))
+	added := uint64(math.Round(f.EstimateCount()))
+	if added == 0 {
+		added = 1
+	}
+	l.metadata = Metadata{Added: added, Expected: capacity, Slots: f.param.Slots, Bits: f.param.Bits}
+	return l, nil
+}
+
+// AdoptLegacyPair turns two filter files fileA and fileB, alternated by the application by hand and opened with
+// the legacy controller, into the first two filters of a FilterGroup of pattern, which is opened and returned
+// with the rest of the arguments as by NewGroup. The filter added to last becomes the active one, and the other
+// one the older filter, which is full, so their entries are kept and the group rotates from then on.
+// The files are rewritten for the metadata of the group by Migrate, whose metadata of the application is replaced,
+// and renamed by the naming scheme of the group. Their parameters and hash are kept, so hash should be the hash
+// of the legacy filters.
+// The group must not have any files yet, and the legacy filters must not be open. It is not atomic: if it fails
+// midway, the files migrated already are left under their legacy names.
+func AdoptLegacyPair(fileA, fileB string, legacy Controller, pattern string, fsync FsyncMode, n uint64, p float64, hash func([]byte) (uint64, uint64), opts ...GroupOption) (*FilterGroup, error) {
+	starIndex := strings.LastIndex(pattern, "*")
+	if starIndex == -1 {
+		return nil, InvalidPatternErr
+	}
+	pair := make([]legacyFilter, 2)
+	for i, filename := range []string{fileA, fileB} {
+		l, err := inspectLegacy(filename, legacy)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", filename, err)
+		}
+		pair[i] = l
+	}
+	if pair[1].last.Before(pair[0].last) {
+		pair[0], pair[1] = pair[1], pair[0]
+	}
+	// the older filter accepts no more entries
+	older := &pair[0].metadata
+	if older.Added < older.Expected {
+		older.Expected = older.Added
+	}
+
+	g := &FilterGroup{}
+	for _, opt := range opts {
+		opt(g)
+	}
+	filenames := []string{pattern[:starIndex] + "0" + pattern[starIndex+1:], pattern[:starIndex] + "1" + pattern[starIndex+1:]}
+	if g.naming == NamingSchemeTimestamp {
+		newer := pair[1].last.UTC()
+		if !newer.After(pair[0].last.UTC()) {
+			newer = pair[0].last.UTC().Add(time.Nanosecond)
+		}
+		for i, t := range []time.Time{pair[0].last.UTC(), newer} {
+			filenames[i] = pattern[:starIndex] + t.Format(timestampLayout) + pattern[starIndex+1:]
+		}
+	}
+	for i, filename := range filenames {
+		if filepath.Clean(filename) == filepath.Clean(pair[1-i].filename) {
+			return nil, fmt.Errorf("%w: %v would be renamed over the other legacy filter", InvalidParamErr, pair[i].filename)
+		}
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", InvalidPatternErr, err)
+	}
+	for _, match := range matches {
+		// the legacy files may match the pattern themselves
+		if match = filepath.Clean(match); match != filepath.Clean(fileA) && match != filepath.Clean(fileB) {
+			return nil, fmt.Errorf("%w: the group already has %v", fs.ErrExist, match)
+		}
+	}
+
+	for i, l := range pair {
+		updated := l.metadata.Encode()
+		if err := Migrate(l.filename, legacy, Controller{
+			MetadataSize: metadataSize,
+			GetParam: func(metadata []byte) (FilterParam, []byte) {
+				return FilterParam{}, updated
+			},
+		}); err != nil {
+			return nil, fmt.Errorf("%v: %w", l.filename, err)
+		}
+		if err := os.Rename(l.filename, filenames[i]); err != nil {
+			return nil, err
+		}
+	}
+	return NewGroup(pattern, fsync, n, p, hash, opts...)
+}
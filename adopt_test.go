@@ -0,0 +1,83 @@
+package disk_bloom
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func createLegacy(t *testing.T, filename string, legacy Controller, keys ...string) {
+	f, err := New(filename, legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		f.ExistOrAdd([]byte(key))
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAdoptLegacyPair(t *testing.T) {
+	for _, scheme := range []NamingScheme{NamingSchemeSequence, NamingSchemeTimestamp} {
+		dir := t.TempDir()
+		legacy := Controller{MetadataSize: 8, GetParam: func(metadata []byte) (FilterParam, []byte) {
+			slots, bits := OptimalParam(1000, 1e-3)
+			return FilterParam{Slots: slots, Bits: bits, Hash: doubleFNV}, nil
+		}}
+		var older, newer []string
+		for i := 0; i < 100; i++ {
+			older = append(older, fmt.Sprint("older", i))
+		}
+		for i := 0; i < 50; i++ {
+			newer = append(newer, fmt.Sprint("newer", i))
+		}
+		// the second file is the one added to first
+		a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+		createLegacy(t, b, legacy, older...)
+		time.Sleep(time.Millisecond)
+		createLegacy(t, a, legacy, newer...)
+
+		g, err := AdoptLegacyPair(a, b, legacy, filepath.Join(dir, "group-*"), FsyncModeNo, 1000, 1e-3, doubleFNV, WithNamingScheme(scheme))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range append(older, newer...) {
+			if !g.Exist([]byte(key)) {
+				t.Fatalf("%v: %v should be kept", scheme, key)
+			}
+		}
+		if g.ExistOrAdd([]byte("new")) {
+			t.Fatalf("%v: Should add a new entry", scheme)
+		}
+		members := g.Members()
+		if len(members) != 2 || members[0].Added < 90 || members[0].Added != members[0].Expected || members[1].Added < 45 || members[1].Expected < 900 {
+			t.Fatalf("%v: Should adopt the older filter as full and the newer as the active one, got %+v", scheme, members)
+		}
+		if w, ok := g.FirstSeenWindow([]byte(older[0])); !ok || w != 1 {
+			t.Fatalf("%v: Should keep the older entries in the older window, got %v", scheme, w)
+		}
+		if !members[1].Filter.Exist([]byte("new")) {
+			t.Fatalf("%v: Should add to the newer filter", scheme)
+		}
+		if err = g.Close(); err != nil {
+			t.Fatal(err)
+		}
+		for _, filename := range []string{a, b} {
+			if _, err = os.Stat(filename); !os.IsNotExist(err) {
+				t.Fatalf("%v: %v should be renamed, got %v", scheme, filename, err)
+			}
+		}
+
+		createLegacy(t, a, legacy)
+		createLegacy(t, b, legacy)
+		if _, err = AdoptLegacyPair(a, b, legacy, filepath.Join(dir, "group-*"), FsyncModeNo, 1000, 1e-3, doubleFNV, WithNamingScheme(scheme)); !errors.Is(err, fs.ErrExist) {
+			t.Fatalf("%v: Should not adopt into an existing group, got %v", scheme, err)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package disk_bloom
+
+import "fmt"
+
+// adviceMinSamples is the number of lookups or adds below which Advice does not judge.
+const adviceMinSamples = 100
+
+// adviceMinReduction is the reduction below which a setting is not worth suggesting.
+const adviceMinReduction = 0.1
+
+// Advice returns the tuning suggestions drawn from the DebugStats of the traffic so far, e.g.
+// "blocked layout would reduce writes by 63%". It is empty unless Controller.Debug is set.
+//
+// The estimates are based on how the probes of the entries spread over pages: a blocked bloom filter
+// keeps the probes of an entry in a page, so it reads a page per lookup and writes a page per add,
+// and WriteBuffer writes a run per page instead of a write per byte.
+func (f *DiskFilter) Advice() (advice []string) {
+	s := f.DebugStats()
+	if s.Lookups >= adviceMinSamples && s.ProbePages > 0 {
+		if r := 1 - float64(s.Lookups)/float64(s.ProbePages); r >= adviceMinReduction {
+			advice = append(advice, fmt.Sprintf("blocked layout would reduce pages read by %.0f%% (%.2f pages per lookup)",
+				r*100, s.PagesPerLookup()))
+		}
+	}
+	if s.WritingAdds >= adviceMinSamples && s.Writes > 0 {
+		if r := 1 - float64(s.WritingAdds)/float64(s.Writes); r >= adviceMinReduction {
+			advice = append(advice, fmt.Sprintf("blocked layout would reduce writes by %.0f%% (%.2f writes per add)",
+				r*100, float64(s.Writes)/float64(s.WritingAdds)))
+		}
+		// WriteBuffer is ignored in FsyncModeAlways
+		if f.file.fsync != FsyncModeAlways {
+			if r := 1 - float64(s.WritePages)/float64(s.Writes); r >= adviceMinReduction {
+				advice = append(advice, fmt.Sprintf("WriteBuffer would reduce writes by at least %.0f%%, at the risk of losing the adds buffered on a crash",
+					r*100))
+			}
+		}
+	}
+	return advice
+}
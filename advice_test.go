@@ -0,0 +1,53 @@
+package disk_bloom
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDiskFilter_Advice(t *testing.T) {
+	defer os.Remove("testfile")
+	bf, err := New("testfile", Controller{
+		Fsync: FsyncModeNo,
+		Debug: true,
+		GetParam: func([]byte) (FilterParam, []byte) {
+			// the probes of an entry spread over many pages
+			return FilterParam{Slots: 8, Bits: 1 << 23, Hash: doubleFNV}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bf.Close()
+	if advice := bf.Advice(); len(advice) != 0 {
+		t.Fatalf("Should not judge without traffic, got %v", advice)
+	}
+	for i := 0; i < 200; i++ {
+		bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	stats := bf.DebugStats()
+	if stats.WritingAdds != 200 || stats.Writes <= stats.WritingAdds || stats.WritePages > stats.Writes {
+		t.Fatalf("Unexpected writes: %v", stats)
+	}
+	if p := stats.PagesPerLookup(); p <= 1 || p > 8 {
+		t.Fatalf("PagesPerLookup: got %v", p)
+	}
+	advice := strings.Join(bf.Advice(), "\n")
+	for _, want := range []string{"blocked layout would reduce pages read by", "blocked layout would reduce writes by"} {
+		if !strings.Contains(advice, want) {
+			t.Fatalf("Should advise %q, got %q", want, advice)
+		}
+	}
+}
+
+func TestDiskFilter_AdviceDisabled(t *testing.T) {
+	bf := newTestFilter(t, Controller{})
+	for i := 0; i < 200; i++ {
+		bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	if advice := bf.Advice(); len(advice) != 0 {
+		t.Fatalf("Should be empty without Debug, got %v", advice)
+	}
+}
@@ -0,0 +1,95 @@
+package disk_bloom
+
+import (
+	"encoding/binary"
+	"math/rand"
+)
+
+const (
+	// auditBatch is the number of keys looked up by Audit at once.
+	auditBatch = 1024
+	// auditSamples is the number of absent keys probed by Audit to measure the false positive rate.
+	auditSamples = 10000
+	// auditMissing is the number of false negatives kept in AuditReport.Missing.
+	auditMissing = 16
+)
+
+// auditPrefix is the prefix of the absent keys sampled by Audit, which are 16 random bytes after it.
+const auditPrefix = "\x00disk-bloom-audit\x00"
+
+// AuditReport is the result of Audit.
+type AuditReport struct {
+	// Keys is the number of keys of the source of truth
+	Keys uint64
+	// FalseNegatives is the number of keys reported as not in the filter, which should be zero.
+	// Missing are the first of them.
+	FalseNegatives uint64
+	Missing        [][]byte
+	// Samples is the number of absent keys probed, and FalsePositives is the number of them reported as in the filter
+	Samples        uint64
+	FalsePositives uint64
+	// FPR is the false positive rate measured, and ExpectedFPR is the rate expected with Keys entries, see EstimateFPR
+	FPR         float64
+	ExpectedFPR float64
+	// Err is the last I/O error of the filter after the audit, see DiskFilter.Err, in which case the false negatives
+	// may be spurious, or ClosedErr
+	Err error
+}
+
+// Audit streams the authoritative key set of the filter from keys, an iter.Seq[[]byte] in Go 1.23,
+// and reports the keys missing from the filter and the false positive rate sampled by random absent keys,
+// e.g. to validate long-lived filters periodically. The keys may be reused by keys once yield returns.
+// The lookups are counted into Stats like the other lookups.
+func (f *DiskFilter) Audit(keys func(yield func(key []byte) bool)) AuditReport {
+	var r AuditReport
+	if !f.acquire() {
+		r.Err = ClosedErr
+		return r
+	}
+	f.release()
+	batch := make([][]byte, 0, auditBatch)
+	check := func() {
+		for i, exist := range f.ExistBatch(batch) {
+			if exist {
+				continue
+			}
+			r.FalseNegatives++
+			if len(r.Missing) < auditMissing {
+				r.Missing = append(r.Missing, batch[i])
+			}
+		}
+		batch = batch[:0]
+	}
+	keys(func(key []byte) bool {
+		r.Keys++
+		batch = append(batch, append([]byte(nil), key...))
+		if len(batch) == auditBatch {
+			check()
+		}
+		return true
+	})
+	if len(batch) > 0 {
+		check()
+	}
+
+	for r.Samples < auditSamples {
+		for i := 0; i < auditBatch && r.Samples < auditSamples; i++ {
+			key := make([]byte, len(auditPrefix)+16)
+			copy(key, auditPrefix)
+			binary.LittleEndian.PutUint64(key[len(auditPrefix):], rand.Uint64())
+			binary.LittleEndian.PutUint64(key[len(auditPrefix)+8:], rand.Uint64())
+			batch = append(batch, key)
+			r.Samples++
+		}
+		for _, exist := range f.ExistBatch(batch) {
+			if exist {
+				r.FalsePositives++
+			}
+		}
+		batch = batch[:0]
+	}
+	r.FPR = float64(r.FalsePositives) / float64(r.Samples)
+	r.ExpectedFPR = EstimateFPR(f.param.Slots, f.param.Bits, r.Keys)
+	r.Err = f.Err()
+	return r
+}
@@ -0,0 +1,44 @@
+package disk_bloom
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestDiskFilter_Audit(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo})
+	keys := func(n int) func(yield func(key []byte) bool) {
+		return func(yield func(key []byte) bool) {
+			for i := 0; i < n; i++ {
+				if !yield([]byte(strconv.Itoa(i))) {
+					return
+				}
+			}
+		}
+	}
+	for i := 0; i < 5000; i++ {
+		bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	r := bf.Audit(keys(5000))
+	if r.Keys != 5000 || r.FalseNegatives != 0 || r.Err != nil {
+		t.Fatalf("Unexpected report %+v", r)
+	}
+	if r.Samples == 0 || math.Abs(r.FPR-r.ExpectedFPR) > 0.01 {
+		t.Fatalf("the measured FPR %v should be about %v", r.FPR, r.ExpectedFPR)
+	}
+
+	// the keys never added are missing
+	r = bf.Audit(keys(5100))
+	if r.FalseNegatives < 90 || len(r.Missing) != auditMissing || string(r.Missing[0]) < "5000" {
+		t.Fatalf("the keys not added should be reported, got %v, %q", r.FalseNegatives, r.Missing)
+	}
+
+	if err := bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if r = bf.Audit(keys(1)); !errors.Is(r.Err, ClosedErr) {
+		t.Fatalf("Should fail on a closed filter, got %v", r.Err)
+	}
+}
@@ -0,0 +1,126 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// autoTuneReads is the number of random page reads timed by Controller.AutoTune.
+	autoTuneReads = 16
+	// autoTuneFastRead is the read latency below which the storage is fast enough to be mapped.
+	autoTuneFastRead = 50 * time.Microsecond
+	// autoTuneSlowRead is the read latency above which the pages read are cached and the readahead is disabled.
+	autoTuneSlowRead = 500 * time.Microsecond
+	// autoTuneSlowSync is the sync latency above which the syncs of FsyncModeAlways are shared by a group commit.
+	autoTuneSlowSync = 2 * time.Millisecond
+	// autoTuneBlockCache is the BlockCache of the slow storages.
+	autoTuneBlockCache = 16 << 20
+	// autoTuneGroupCommit is the GroupCommit of the slow syncs.
+	autoTuneGroupCommit = time.Millisecond
+)
+
+// AutoTune is the result of Controller.AutoTune, which is reported by Describe.
+type AutoTune struct {
+	// ReadLatency is the median latency of a random page read, and SyncLatency is the median latency of a sync,
+	// which is zero if the filter is read-only
+	ReadLatency time.Duration `json:"read_latency"`
+	SyncLatency time.Duration `json:"sync_latency"`
+	// Decisions are the options turned on by the probe, e.g. "mmap", "block_cache=16777216", "access_pattern=random"
+	// or "group_commit=1ms"
+	Decisions []string `json:"decisions"`
+}
+
+// autoTune probes the storage of filename for Controller.AutoTune, and turns on the options the latencies call for
+// in the controller. The random reads are timed on the file if it exists, or on a temporary file in its directory,
+// and the syncs by ProbeSync, so the reads served by the page cache look fast.
+func autoTune(filename string, controller *Controller) (*AutoTune, error) {
+	t := &AutoTune{}
+	var err error
+	if t.ReadLatency, err = probeReads(filename); err != nil {
+		return nil, err
+	}
+	if !controller.ReadOnly {
+		p, err := ProbeSync(filepath.Dir(filename))
+		if err != nil {
+			return nil, err
+		}
+		t.SyncLatency = p.Latency
+	}
+	t.decide(controller)
+	return t, nil
+}
+
+// decide turns on the options the latencies call for in the controller unless set.
+func (t *AutoTune) decide(controller *Controller) {
+	switch {
+	case t.ReadLatency < autoTuneFastRead:
+		if !controller.Mmap && controller.Hybrid == 0 {
+			controller.Mmap = true
+			t.Decisions = append(t.Decisions, "mmap")
+		}
+	case t.ReadLatency > autoTuneSlowRead:
+		if controller.BlockCache == 0 {
+			controller.BlockCache = autoTuneBlockCache
+			t.Decisions = append(t.Decisions, fmt.Sprintf("block_cache=%v", controller.BlockCache))
+		}
+		if controller.AccessPattern == AccessPatternNormal {
+			controller.AccessPattern = AccessPatternRandom
+			t.Decisions = append(t.Decisions, "access_pattern=random")
+		}
+	}
+	if t.SyncLatency > autoTuneSlowSync && controller.Fsync == FsyncModeAlways && controller.GroupCommit == 0 {
+		controller.GroupCommit = autoTuneGroupCommit
+		t.Decisions = append(t.Decisions, fmt.Sprintf("group_commit=%v", controller.GroupCommit))
+	}
+}
+
+// probeReads returns the median latency of reading a random page of filename, or of a temporary file in its directory
+// if it does not exist or is smaller than a page.
+func probeReads(filename string) (time.Duration, error) {
+	f, err := os.Open(filename)
+	if err == nil {
+		defer f.Close()
+	} else if !os.IsNotExist(err) {
+		return 0, err
+	}
+	var size int64
+	if f != nil {
+		info, err := f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		size = info.Size()
+	}
+	if size < pageSize {
+		tmp, err := os.CreateTemp(filepath.Dir(filename), ".autotune-*")
+		if err != nil {
+			return 0, err
+		}
+		defer func() {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}()
+		size = autoTuneReads * pageSize
+		if _, err = tmp.WriteAt(make([]byte, size), 0); err != nil {
+			return 0, err
+		}
+		f = tmp
+	}
+	page := make([]byte, pageSize)
+	latencies := make([]time.Duration, autoTuneReads)
+	for i := range latencies {
+		offset := rand.Int63n(size/pageSize) * pageSize
+		start := time.Now()
+		if _, err = f.ReadAt(page, offset); err != nil {
+			return 0, err
+		}
+		latencies[i] = time.Since(start)
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return latencies[len(latencies)/2], nil
+}
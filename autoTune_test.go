@@ -0,0 +1,37 @@
+package disk_bloom
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAutoTune(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeAlways, AutoTune: true})
+	tune := bf.Describe().AutoTune
+	if tune == nil || tune.ReadLatency <= 0 || tune.SyncLatency <= 0 {
+		t.Fatalf("the latencies should be reported, got %+v", tune)
+	}
+	t.Logf("%+v", tune)
+	bf.ExistOrAdd([]byte("a"))
+	if !bf.Exist([]byte("a")) {
+		t.Fatal("a should exist")
+	}
+
+	// the decisions by the latencies
+	for _, c := range []struct {
+		read, sync time.Duration
+		controller Controller
+		decisions  int
+	}{
+		{time.Microsecond, time.Millisecond, Controller{}, 1},
+		{time.Microsecond, time.Millisecond, Controller{Mmap: true}, 0},
+		{time.Millisecond, 10 * time.Millisecond, Controller{Fsync: FsyncModeAlways}, 3},
+		{time.Millisecond, 10 * time.Millisecond, Controller{Fsync: FsyncModeNo, BlockCache: 1 << 20}, 1},
+	} {
+		tune := &AutoTune{ReadLatency: c.read, SyncLatency: c.sync}
+		tune.decide(&c.controller)
+		if len(tune.Decisions) != c.decisions {
+			t.Fatalf("%v reads and %v syncs should make %v decisions, got %v", c.read, c.sync, c.decisions, tune.Decisions)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// Storage is a backend of the filter file other than a file on the disk, e.g. a MemStorage for tests,
+// an adapter of a remote block store, or a wrapper encrypting at rest, see NewOnStorage.
+// The reads past the end return io.EOF, and the writes past the end grow it. *os.File is a Storage.
+type Storage interface {
+	io.ReaderAt
+	io.WriterAt
+	Sync() error
+	Truncate(size int64) error
+}
+
+// NewOnStorage creates or opens the classic Bloom Filter in s as New does, where name is only used in the errors,
+// Describe and the metrics. An implementation of io.Closer is closed by Close, and the shrunk filters are opened
+// by an implementation of Size() int64. Control is invoked with a nil file, see ControlStorage.
+// Checksums, Journal and AckRing, which write next to the file, are unsupported, Xattrs and FileLock are ignored,
+// and Mmap falls back to the I/O of s. Clone copies the storage, but Compact, Publish and ReplaceWith
+// return UnsupportedErr.
+func NewOnStorage(s Storage, name string, controller Controller) (*DiskFilter, error) {
+	if controller.Checksums || controller.Journal || controller.AckRing != 0 {
+		return nil, fmt.Errorf("%w: checksums, journal and ack ring of %v on a Storage", UnsupportedErr, name)
+	}
+	controller.Xattrs, controller.FileLock = false, false
+	filter, err := openHandle(&storageFile{Storage: s, name: name}, name, controller, variantClassic, &debugCounters{})
+	if err != nil {
+		return nil, err
+	}
+	filter.start()
+	return filter, nil
+}
+
+// storageFile is the filter file in a Storage opened by NewOnStorage.
+type storageFile struct {
+	Storage
+	name string
+	// offset is the offset of Read
+	offset int64
+}
+
+func (f *storageFile) Read(b []byte) (int, error) {
+	n, err := f.ReadAt(b, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *storageFile) Name() string {
+	return f.name
+}
+
+func (f *storageFile) Stat() (os.FileInfo, error) {
+	s, ok := f.Storage.(interface{ Size() int64 })
+	if !ok {
+		return nil, fmt.Errorf("%w: the size of %v", UnsupportedErr, f.name)
+	}
+	return storageInfo{name: f.name, size: s.Size()}, nil
+}
+
+func (f *storageFile) Close() error {
+	if c, ok := f.Storage.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// storageInfo is the os.FileInfo of a storageFile.
+type storageInfo struct {
+	name string
+	size int64
+}
+
+func (i storageInfo) Name() string       { return i.name }
+func (i storageInfo) Size() int64        { return i.size }
+func (i storageInfo) Mode() fs.FileMode  { return 0644 }
+func (i storageInfo) ModTime() time.Time { return time.Time{} }
+func (i storageInfo) IsDir() bool        { return false }
+func (i storageInfo) Sys() interface{}   { return nil }
+
+// MemStorage is a Storage in memory, e.g. for tests. The zero value is empty and ready to use.
+type MemStorage struct {
+	mu sync.RWMutex
+	b  []byte
+}
+
+func (m *MemStorage) ReadAt(b []byte, offset int64) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if offset >= int64(len(m.b)) {
+		return 0, io.EOF
+	}
+	n := copy(b, m.b[offset:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *MemStorage) WriteAt(b []byte, offset int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if end := offset + int64(len(b)); end > int64(len(m.b)) {
+		m.growLocked(end)
+	}
+	return copy(m.b[offset:], b), nil
+}
+
+func (m *MemStorage) Sync() error {
+	return nil
+}
+
+func (m *MemStorage) Truncate(size int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if size < int64(len(m.b)) {
+		m.b = m.b[:size]
+	} else {
+		m.growLocked(size)
+	}
+	return nil
+}
+
+// growLocked grows the storage to size bytes, which are zeros.
+func (m *MemStorage) growLocked(size int64) {
+	if size <= int64(cap(m.b)) {
+		tail := m.b[len(m.b):size]
+		for i := range tail {
+			tail[i] = 0
+		}
+		m.b = m.b[:size]
+		return
+	}
+	b := make([]byte, size, size+size/4)
+	copy(b, m.b)
+	m.b = b
+}
+
+// Size returns the size of the storage in bytes.
+func (m *MemStorage) Size() int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return int64(len(m.b))
+}
+
+// Bytes returns a copy of the storage, e.g. to ship the filter file.
+func (m *MemStorage) Bytes() []byte {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]byte(nil), m.b...)
+}
+
+// backend returns the Storage of the filter file, which is the *os.File unless it is opened by OpenFS or NewOnStorage.
+func (m *muFile) backend() Storage {
+	if f, ok := m.f.(*storageFile); ok {
+		return f.Storage
+	}
+	return m.f
+}
+
+// controlLocked invokes Control and ControlStorage.
+func (f *DiskFilter) controlLocked() {
+	if f.controller.Control != nil {
+		f.controller.Control(f.file.osFile(), f.file.modified)
+	}
+	if f.controller.ControlStorage != nil {
+		f.controller.ControlStorage(f.file.backend(), f.file.modified)
+	}
+}
@@ -0,0 +1,91 @@
+package disk_bloom
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestNewOnStorage(t *testing.T) {
+	var s MemStorage
+	controls := 0
+	controller := Controller{
+		Fsync:        FsyncModeAlways,
+		MetadataSize: 4,
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			slots, bits := OptimalParam(1000, 1e-3)
+			return FilterParam{Slots: slots, Bits: bits, HashKind: HashKindXXHash64}, nil
+		},
+		ControlStorage: func(storage Storage, modified bool) {
+			if storage != &s {
+				t.Errorf("ControlStorage should be given the storage, got %T", storage)
+			}
+			controls++
+		},
+	}
+	bf, err := NewOnStorage(&s, "mem", controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	clone := filepath.Join(t.TempDir(), "clone")
+	if err = bf.Clone(clone); err != nil {
+		t.Fatal(err)
+	}
+	if err = bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if controls == 0 {
+		t.Fatal("ControlStorage should be invoked on Close")
+	}
+	if s.Size() < bf.bloomStart+int64(bf.Size()) {
+		t.Fatalf("the filter should be written into the storage, got %v bytes", s.Size())
+	}
+
+	bf, err = NewOnStorage(&s, "mem", controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bf.Close()
+	copied, err := New(clone, Controller{Fsync: FsyncModeNo, MetadataSize: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer copied.Close()
+	for i := 0; i < 1000; i++ {
+		if !bf.Exist([]byte(strconv.Itoa(i))) || !copied.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should exist after reopened and cloned", i)
+		}
+	}
+
+	if _, err = NewOnStorage(&MemStorage{}, "mem", Controller{Checksums: true}); !errors.Is(err, UnsupportedErr) {
+		t.Fatalf("checksums should be unsupported, got %v", err)
+	}
+}
+
+func TestMemStorage(t *testing.T) {
+	var s MemStorage
+	if _, err := s.WriteAt([]byte("abc"), 2); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 4)
+	if n, err := s.ReadAt(b, 1); n != 4 || err != nil || string(b) != "\x00abc" {
+		t.Fatalf("Unexpected read %v, %v, %q", n, err, b)
+	}
+	if n, err := s.ReadAt(b, 3); n != 2 || err != io.EOF {
+		t.Fatalf("a read past the end should return io.EOF, got %v, %v", n, err)
+	}
+	if err := s.Truncate(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Truncate(5); err != nil {
+		t.Fatal(err)
+	}
+	if string(s.Bytes()) != "\x00\x00a\x00\x00" {
+		t.Fatalf("the storage should be truncated and grown with zeros, got %q", s.Bytes())
+	}
+}
@@ -0,0 +1,43 @@
+package disk_bloom
+
+import "context"
+
+// Barrier makes every add returned before the call durable on return, whatever the FsyncMode is,
+// by writing the bytes buffered in memory and syncing the file, so that an application can order its own commits after it.
+// It returns ctx.Err() once ctx is done, and the sync completes in the background.
+func (f *DiskFilter) Barrier(ctx context.Context) error {
+	_, err := withContext(ctx, func() (bool, error) {
+		return false, f.barrier()
+	})
+	return err
+}
+
+func (f *DiskFilter) barrier() error {
+	if !f.acquire() {
+		return ClosedErr
+	}
+	defer f.release()
+	f.file.mu.Lock()
+	defer f.file.mu.Unlock()
+	if len(f.pending) > 0 {
+		if err := f.flushPendingLocked(); err != nil {
+			return err
+		}
+	}
+	f.controlLocked()
+	if err := f.persistHeaderLocked(); err != nil {
+		return err
+	}
+	if err := f.signLocked(); err != nil {
+		return err
+	}
+	if err := f.updateChecksumsLocked(); err != nil {
+		return err
+	}
+	if err := f.syncFile(); err != nil {
+		return err
+	}
+	f.file.modified = false
+	f.file.metadataModified = false
+	return nil
+}
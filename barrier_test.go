@@ -0,0 +1,38 @@
+package disk_bloom
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDiskFilter_Barrier(t *testing.T) {
+	injector := NewFaultInjector(1)
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo, WriteBuffer: time.Hour, FaultInjector: injector})
+	bf.ExistOrAdd([]byte("testing"))
+	if err := bf.Barrier(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(bf.pending) != 0 || bf.file.modified {
+		t.Fatal("the adds should be written and synced")
+	}
+
+	bf.ExistOrAdd([]byte("failing"))
+	injector.Set(Faults{SyncErrRate: 1})
+	if err := bf.Barrier(context.Background()); !errors.Is(err, FaultInjectedErr) {
+		t.Fatalf("Should fail with the sync, got %v", err)
+	}
+	injector.Set(Faults{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := bf.Barrier(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Should fail with the canceled context, got %v", err)
+	}
+	if err := bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := bf.Barrier(context.Background()); !errors.Is(err, ClosedErr) {
+		t.Fatalf("Should fail with ClosedErr, got %v", err)
+	}
+}
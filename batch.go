@@ -0,0 +1,231 @@
+package disk_bloom
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// ExistBatch returns whether each of the keys is in the filter.
+// The offsets of all keys are sorted once, and the probed bytes are read page by page under a single lock acquisition.
+func (f *DiskFilter) ExistBatch(keys [][]byte) []bool {
+	exist := make([]bool, len(keys))
+	if !f.acquire() {
+		return exist
+	}
+	defer f.release()
+	if f.lockFree {
+		for k, key := range keys {
+			exist[k] = f.existMapped(f.Hash(key))
+			f.countLookup(exist[k])
+		}
+		return exist
+	}
+	slots := f.lookupSlots()
+	offsets, positions := f.batchOffsets(keys, slots)
+	var vals map[int64]byte
+	var batch uint64
+	f.phase("io", func() {
+		f.rlock()
+		// the error is surfaced by Err, and the keys failed to be read are not in
+		vals, batch, _ = f.readBatchLocked(positions)
+		f.file.mu.RUnlock()
+	})
+	found := false
+	for k := range keys {
+		exist[k] = batchExist(f, vals, offsets[k*slots:(k+1)*slots])
+		found = found || exist[k]
+	}
+	if found && batch > 0 && f.commit.wait(batch) != nil {
+		// do not report an entry before its bits are durable, nor the ones whose bits failed to be synced
+		f.rlock()
+		for k := range keys {
+			exist[k] = exist[k] && !f.pendingLocked(offsets[k*slots:(k+1)*slots])
+		}
+		f.file.mu.RUnlock()
+	}
+	for _, e := range exist {
+		f.countLookup(e)
+	}
+	return exist
+}
+
+// ExistOrAddBatch returns whether each of the keys was in the filter, and adds the keys not in.
+// A key repeated in keys exists at its following occurrences.
+// Like ExistBatch, the probed bytes are read page by page, and the changed bytes are written in order,
+// all under a single lock acquisition.
+func (f *DiskFilter) ExistOrAddBatch(keys [][]byte) (exist []bool) {
+	exist, _ = f.ExistOrAddBatchErr(keys)
+	return exist
+}
+
+// AddBatch adds the keys to the filter, like Add.
+func (f *DiskFilter) AddBatch(keys [][]byte) error {
+	_, err := f.ExistOrAddBatchErr(keys)
+	return err
+}
+
+// ExistOrAddBatchErr is like ExistOrAddBatch, but returns the error if the keys not in the filter failed to be added.
+func (f *DiskFilter) ExistOrAddBatchErr(keys [][]byte) (exist []bool, err error) {
+	if !f.acquire() {
+		return nil, ClosedErr
+	}
+	defer f.release()
+	exist = make([]bool, len(keys))
+	slots := f.slots()
+	offsets, positions := f.batchOffsets(keys, slots)
+	var batch uint64
+	var novel uint64
+	f.phase("io", func() {
+		f.lock()
+		defer f.file.mu.Unlock()
+		var vals map[int64]byte
+		if vals, batch, err = f.readBatchLocked(positions); err != nil {
+			batch = 0
+			return
+		}
+		// the bytes to write
+		changed := make(map[int64]byte)
+		var set uint64
+		for k := range keys {
+			keyOffsets := offsets[k*slots : (k+1)*slots]
+			if exist[k] = batchExist(f, vals, keyOffsets); exist[k] {
+				continue
+			}
+			novel++
+			for _, offset := range keyOffsets {
+				pos := f.fileOffset(int64(offset / 8))
+				if vals[pos]&(1<<(offset%8)) == 0 {
+					vals[pos] |= 1 << (offset % 8)
+					changed[pos] = vals[pos]
+					set++
+				}
+			}
+		}
+		if batch, err = f.writeChangedLocked(changed, novel, batch); err == nil {
+			atomic.AddUint64(&f.setBits, set)
+		}
+	})
+	if batch > 0 {
+		// do not return before the bits are durable
+		if e := f.commit.wait(batch); err == nil {
+			err = e
+		}
+	}
+	if err == nil {
+		f.countAdds(uint64(len(keys)), novel)
+	}
+	return exist, err
+}
+
+// batchOffsets returns the bit offsets of the first slots probes of every key, in the order of keys,
+// and the sorted unique file offsets of the bytes containing them.
+func (f *DiskFilter) batchOffsets(keys [][]byte, slots int) (offsets []uint64, positions []int64) {
+	offsets = make([]uint64, 0, len(keys)*slots)
+	for _, key := range keys {
+		h := f.Hash(key)
+		for i := 0; i < slots; i++ {
+			offsets = append(offsets, f.bloomOffset(h.X, h.Y, i))
+		}
+	}
+	positions = make([]int64, len(offsets))
+	for i, offset := range offsets {
+		positions[i] = f.fileOffset(int64(offset / 8))
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i] < positions[j]
+	})
+	return offsets, uniquePositions(positions)
+}
+
+// readBatchLocked reads the bytes at the sorted positions.
+// It returns the latest group commit batch which those bytes are waiting for, if any, or the error of reading them.
+func (f *DiskFilter) readBatchLocked(positions []int64) (vals map[int64]byte, batch uint64, err error) {
+	r := pageReader{f: f, positions: positions}
+	defer f.account(&r)
+	vals = make(map[int64]byte, len(positions))
+	for i, pos := range positions {
+		vals[pos] = r.readByte(i)
+		if b := f.unsynced[pos]; b > batch {
+			batch = b
+		}
+	}
+	return vals, batch, r.err
+}
+
+// batchExist returns if all bits at offsets are set in vals.
+func batchExist(f *DiskFilter, vals map[int64]byte, offsets []uint64) bool {
+	for _, offset := range offsets {
+		if vals[f.fileOffset(int64(offset/8))]&(1<<(offset%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// writeChangedLocked writes the changed bytes of the adds in order.
+// It returns the group commit batch which the bytes are waiting for, if any.
+func (f *DiskFilter) writeChangedLocked(changed map[int64]byte, adds uint64, batch uint64) (uint64, error) {
+	if len(changed) == 0 {
+		return batch, nil
+	}
+	if f.readOnly {
+		return batch, f.readOnlyErr()
+	}
+	if f.writeBuffer {
+		if err := f.bufferLocked(changed); err != nil {
+			return batch, f.onWriteErrorLocked(err, changed)
+		}
+		f.touchAddLocked()
+		return batch, nil
+	}
+	if err := f.journalBytesLocked(changed); err != nil {
+		return batch, err
+	}
+	var prev map[int64]byte
+	if f.file.fsync == FsyncModeAlways && f.commit == nil {
+		// restored if the sync fails
+		positions := make([]int64, 0, len(changed))
+		for pos := range changed {
+			positions = append(positions, pos)
+		}
+		sort.Slice(positions, func(i, j int) bool {
+			return positions[i] < positions[j]
+		})
+		var err error
+		if prev, _, err = f.readBatchLocked(positions); err != nil {
+			return batch, err
+		}
+	}
+	written, err := f.writeGatheredLocked(changed)
+	if err != nil {
+		return batch, f.onWriteErrorLocked(err, changed)
+	}
+	f.noteIO(ioOpWrite, nil)
+	f.touchAddLocked()
+	f.accountWrites(written, adds)
+	f.file.modified = true
+	if f.file.fsync == FsyncModeAlways {
+		if f.commit != nil {
+			return f.commit.enqueueLocked(written), nil
+		}
+		if err := f.syncFile(); err != nil {
+			return batch, f.unwriteLocked(err, prev, written)
+		}
+	}
+	return batch, nil
+}
+
+// uniquePositions removes the duplicates in the sorted positions in place.
+func uniquePositions(positions []int64) []int64 {
+	if len(positions) == 0 {
+		return positions
+	}
+	n := 1
+	for _, pos := range positions[1:] {
+		if pos != positions[n-1] {
+			positions[n] = pos
+			n++
+		}
+	}
+	return positions[:n]
+}
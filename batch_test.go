@@ -0,0 +1,42 @@
+package disk_bloom
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestDiskFilter_Batch(t *testing.T) {
+	for _, controller := range []Controller{{}, {Mmap: true}} {
+		bf := newTestFilter(t, controller)
+		var keys [][]byte
+		for i := 0; i < 100; i++ {
+			keys = append(keys, []byte(strconv.Itoa(i)))
+		}
+		if err := bf.AddBatch(keys[:50]); err != nil {
+			t.Fatal(err)
+		}
+		for i, exist := range bf.ExistBatch(keys) {
+			if exist != (i < 50) {
+				t.Fatalf("%v: got exist %v", i, exist)
+			}
+		}
+		// repeated in the batch
+		exist := bf.ExistOrAddBatch(append(keys, []byte("60"), []byte("70")))
+		for i, e := range exist {
+			if e != (i < 50 || i >= 100) {
+				t.Fatalf("%v: got exist %v", i, e)
+			}
+		}
+		for i, exist := range bf.ExistBatch(keys) {
+			if !exist {
+				t.Fatalf("%v should exist in filter", i)
+			}
+			if !bf.Exist(keys[i]) {
+				t.Fatalf("%v should exist in filter", i)
+			}
+		}
+		bf.Close()
+		os.Remove("testfile")
+	}
+}
@@ -0,0 +1,145 @@
+// Package bench runs the same workload against the variants of disk_bloom, and reports their false positive rate,
+// throughput, disk I/O and file size, so that a variant can be chosen empirically.
+// Simulate generates a configurable workload against a filter file, to choose its options the same way.
+package bench
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	disk_bloom "github.com/mzz2017/disk-bloom"
+)
+
+// Workload is run against every variant.
+type Workload struct {
+	// Keys are added, and then looked up, which must all be found.
+	Keys [][]byte
+	// Absent are keys not in Keys, looked up to measure the false positive rate.
+	Absent [][]byte
+	// FPRate is the false positive rate the variants are sized for, with the capacity of len(Keys).
+	// The xor filter has a fixed rate of about 1/256.
+	FPRate float64
+	// Fsync is the FsyncMode of the variants.
+	Fsync disk_bloom.FsyncMode
+}
+
+// Result is the measurements of a variant.
+type Result struct {
+	// Variant is "classic", "counting" or "xor"
+	Variant string
+	// FPR is the ratio of Absent found
+	FPR float64
+	// AddsPerSec is the throughput of adding Keys, which is of building the filter for xor
+	AddsPerSec float64
+	// LookupsPerSec is the throughput of looking up Keys and Absent
+	LookupsPerSec float64
+	// ReadBytes and WrittenBytes are the bytes read and written by the process, zero if unknown on the platform
+	ReadBytes    uint64
+	WrittenBytes uint64
+	// FileSize is the size of the filter file in bytes
+	FileSize int64
+}
+
+// variant builds a filter of the keys in filename.
+type variant struct {
+	name  string
+	build func(filename string, controller disk_bloom.Controller, keys [][]byte) (disk_bloom.Filter, error)
+}
+
+var variants = []variant{
+	{"classic", func(filename string, controller disk_bloom.Controller, keys [][]byte) (disk_bloom.Filter, error) {
+		f, err := disk_bloom.New(filename, controller)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			if _, err = f.ExistOrAddErr(key); err != nil {
+				_ = f.Close()
+				return nil, err
+			}
+		}
+		return f, nil
+	}},
+	{"counting", func(filename string, controller disk_bloom.Controller, keys [][]byte) (disk_bloom.Filter, error) {
+		f, err := disk_bloom.NewCounting(filename, controller)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			if _, err = f.ExistOrAddErr(key); err != nil {
+				_ = f.Close()
+				return nil, err
+			}
+		}
+		return f, nil
+	}},
+	{"xor", func(filename string, controller disk_bloom.Controller, keys [][]byte) (disk_bloom.Filter, error) {
+		return disk_bloom.BuildXor(filename, controller, keys)
+	}},
+}
+
+// Run runs the workload against every variant in files under dir, which are removed afterwards.
+func Run(dir string, w Workload) ([]Result, error) {
+	if len(w.Keys) == 0 || w.FPRate <= 0 || w.FPRate >= 1 {
+		return nil, fmt.Errorf("bench: the keys and a false positive rate in (0, 1) are required")
+	}
+	slots, bits := disk_bloom.OptimalParam(uint64(len(w.Keys)), w.FPRate)
+	controller := disk_bloom.Controller{
+		Fsync: w.Fsync,
+		GetParam: func(metadata []byte) (disk_bloom.FilterParam, []byte) {
+			return disk_bloom.FilterParam{Slots: slots, Bits: bits, HashKind: disk_bloom.HashKindXXHash64}, nil
+		},
+	}
+	var results []Result
+	for _, v := range variants {
+		r, err := run(filepath.Join(dir, "bench-"+v.name), v, controller, w)
+		if err != nil {
+			return nil, fmt.Errorf("bench: %v: %w", v.name, err)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+func run(filename string, v variant, controller disk_bloom.Controller, w Workload) (Result, error) {
+	_ = os.Remove(filename)
+	defer os.Remove(filename)
+	r := Result{Variant: v.name}
+	readBefore, writtenBefore := processIO()
+	start := time.Now()
+	f, err := v.build(filename, controller, w.Keys)
+	if err != nil {
+		return Result{}, err
+	}
+	r.AddsPerSec = float64(len(w.Keys)) / time.Since(start).Seconds()
+	start = time.Now()
+	for _, key := range w.Keys {
+		if !f.Exist(key) {
+			_ = f.Close()
+			return Result{}, fmt.Errorf("%q is not found", key)
+		}
+	}
+	var positives int
+	for _, key := range w.Absent {
+		if f.Exist(key) {
+			positives++
+		}
+	}
+	r.LookupsPerSec = float64(len(w.Keys)+len(w.Absent)) / time.Since(start).Seconds()
+	if err = f.Close(); err != nil {
+		return Result{}, err
+	}
+	readAfter, writtenAfter := processIO()
+	r.ReadBytes, r.WrittenBytes = readAfter-readBefore, writtenAfter-writtenBefore
+	if len(w.Absent) > 0 {
+		r.FPR = float64(positives) / float64(len(w.Absent))
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return Result{}, err
+	}
+	r.FileSize = info.Size()
+	return r, nil
+}
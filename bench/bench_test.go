@@ -0,0 +1,53 @@
+package bench
+
+import (
+	"fmt"
+	"testing"
+
+	disk_bloom "github.com/mzz2017/disk-bloom"
+)
+
+func TestRun(t *testing.T) {
+	w := Workload{FPRate: 0.01, Fsync: disk_bloom.FsyncModeNo}
+	for i := 0; i < 2000; i++ {
+		w.Keys = append(w.Keys, []byte(fmt.Sprint("key", i)))
+		w.Absent = append(w.Absent, []byte(fmt.Sprint("absent", i)))
+	}
+	results, err := Run(t.TempDir(), w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(variants) {
+		t.Fatalf("Should report %v variants, got %v", len(variants), len(results))
+	}
+	for _, r := range results {
+		if r.FPR > 0.03 {
+			t.Fatalf("%v: FPR should be about 0.01, got %v", r.Variant, r.FPR)
+		}
+		if r.AddsPerSec <= 0 || r.LookupsPerSec <= 0 || r.FileSize <= 0 {
+			t.Fatalf("%v: unexpected result %+v", r.Variant, r)
+		}
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	for _, s := range []Simulation{
+		{Keys: 2000, KeySize: 16, Ops: 4000, ReadRatio: 0.5, Goroutines: 4, FPRate: 0.01, Fsync: disk_bloom.FsyncModeNo},
+		{Keys: 2000, KeySize: 8, Ops: 4000, ReadRatio: 0.9, Distribution: DistributionZipfian, Goroutines: 1, FPRate: 0.01,
+			Fsync: disk_bloom.FsyncModeNo, BlockCache: 1 << 16, BlockSize: 64},
+	} {
+		r, err := Simulate(t.TempDir(), s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Ops != s.Ops || r.OpsPerSec <= 0 || r.Reads.Max < r.Reads.P50 || r.Adds.P50 <= 0 || r.FileSize <= 0 {
+			t.Fatalf("%v: unexpected report %+v", s.Distribution, r)
+		}
+		if r.FPR > 0.05 {
+			t.Fatalf("%v: FPR should be about 0.01 at most, got %v", s.Distribution, r.FPR)
+		}
+	}
+	if _, err := Simulate(t.TempDir(), Simulation{Keys: 10, KeySize: 4, Ops: 1, Goroutines: 1, FPRate: 0.01}); err == nil {
+		t.Fatal("Should fail with keys shorter than 8 bytes")
+	}
+}
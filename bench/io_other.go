@@ -0,0 +1,8 @@
+//go:build !linux
+
+package bench
+
+// processIO is unknown on the platform.
+func processIO() (read, written uint64) {
+	return 0, 0
+}
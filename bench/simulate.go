@@ -0,0 +1,251 @@
+package bench
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	disk_bloom "github.com/mzz2017/disk-bloom"
+)
+
+// Distribution is how the keys of a Simulation are drawn from the key space.
+type Distribution uint8
+
+const (
+	// DistributionUniform draws every key with the same probability
+	DistributionUniform Distribution = iota
+	// DistributionZipfian draws the keys by a Zipf distribution of Simulation.ZipfS, so that a few keys are hot
+	DistributionZipfian
+)
+
+func (d Distribution) String() string {
+	switch d {
+	case DistributionUniform:
+		return "uniform"
+	case DistributionZipfian:
+		return "zipfian"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseDistribution parses the name of a Distribution.
+func ParseDistribution(s string) (Distribution, error) {
+	switch s {
+	case "uniform":
+		return DistributionUniform, nil
+	case "zipfian":
+		return DistributionZipfian, nil
+	}
+	return 0, fmt.Errorf("bench: unknown distribution %q", s)
+}
+
+// Simulation is a workload generated against a real filter file, mixing the lookups and the adds of the keys drawn
+// from a key space by several goroutines, to choose the FsyncMode, the BlockCache and the layout empirically.
+type Simulation struct {
+	// Keys is the size of the key space, which is the capacity the filter is sized for
+	Keys uint64
+	// KeySize is the size of a key in bytes, at least 8
+	KeySize int
+	// Ops is the number of operations of all goroutines
+	Ops int
+	// ReadRatio is the fraction of the operations which are Exist, and the rest are ExistOrAdd
+	ReadRatio    float64
+	Distribution Distribution
+	// ZipfS is the exponent of DistributionZipfian, greater than 1, 1.1 if zero
+	ZipfS      float64
+	Goroutines int
+	// Seed seeds the keys drawn
+	Seed int64
+	// FPRate is the false positive rate the filter is sized for
+	FPRate float64
+	// AbsentProbes is the number of keys out of the key space looked up after the run to measure the FPR, 10000 if zero
+	AbsentProbes int
+
+	Fsync disk_bloom.FsyncMode
+	// BlockCache is the Controller.BlockCache in bytes
+	BlockCache int64
+	// BlockSize is the FilterParam.BlockSize of a blocked filter, or 0 for the classic layout
+	BlockSize uint32
+}
+
+// Latency is the percentiles of the latency of the operations.
+type Latency struct {
+	P50, P90, P99, P999, Max time.Duration
+}
+
+// Report is the measurements of a Simulation.
+type Report struct {
+	Ops       int
+	Duration  time.Duration
+	OpsPerSec float64
+	// Reads and Adds are the latencies of Exist and ExistOrAdd
+	Reads Latency
+	Adds  Latency
+	// FPR is the ratio of the keys out of the key space found after the run
+	FPR float64
+	// ReadBytes and WrittenBytes are the bytes read and written by the process, zero if unknown on the platform
+	ReadBytes    uint64
+	WrittenBytes uint64
+	FileSize     int64
+}
+
+// putKey returns the key of the index in b, which is out of the key space if index >= Simulation.Keys.
+func putKey(b []byte, index uint64) []byte {
+	binary.BigEndian.PutUint64(b, index)
+	return b
+}
+
+func (s Simulation) check() error {
+	if s.Keys == 0 || s.KeySize < 8 || s.Ops <= 0 || s.Goroutines <= 0 {
+		return fmt.Errorf("bench: keys, a key size of 8 bytes at least, ops and goroutines are required")
+	}
+	if s.ReadRatio < 0 || s.ReadRatio > 1 || s.FPRate <= 0 || s.FPRate >= 1 {
+		return fmt.Errorf("bench: the read ratio should be in [0, 1], and the false positive rate in (0, 1)")
+	}
+	if s.Distribution == DistributionZipfian && s.ZipfS != 0 && s.ZipfS <= 1 {
+		return fmt.Errorf("bench: the exponent of the zipfian distribution should be greater than 1")
+	}
+	return nil
+}
+
+// Simulate runs the simulation against a filter file under dir, which is removed afterwards.
+func Simulate(dir string, s Simulation) (Report, error) {
+	if err := s.check(); err != nil {
+		return Report{}, err
+	}
+	if s.ZipfS == 0 {
+		s.ZipfS = 1.1
+	}
+	if s.AbsentProbes == 0 {
+		s.AbsentProbes = 10000
+	}
+	slots, bits := disk_bloom.OptimalParam(s.Keys, s.FPRate)
+	filename := filepath.Join(dir, "bench-simulation")
+	_ = os.Remove(filename)
+	defer os.Remove(filename)
+	f, err := disk_bloom.New(filename, disk_bloom.Controller{
+		Fsync:      s.Fsync,
+		BlockCache: s.BlockCache,
+		GetParam: func(metadata []byte) (disk_bloom.FilterParam, []byte) {
+			return disk_bloom.FilterParam{Slots: slots, Bits: bits, BlockSize: s.BlockSize, HashKind: disk_bloom.HashKindXXHash64}, nil
+		},
+	})
+	if err != nil {
+		return Report{}, fmt.Errorf("bench: %w", err)
+	}
+
+	readBefore, writtenBefore := processIO()
+	var (
+		wg           sync.WaitGroup
+		mu           sync.Mutex
+		reads, adds  []time.Duration
+		firstErr     error
+		start        = time.Now()
+		opsPerWorker = (s.Ops + s.Goroutines - 1) / s.Goroutines
+	)
+	for g := 0; g < s.Goroutines; g++ {
+		n := opsPerWorker
+		if rest := s.Ops - g*opsPerWorker; rest < n {
+			n = rest
+		}
+		if n <= 0 {
+			break
+		}
+		wg.Add(1)
+		go func(g int, n int) {
+			defer wg.Done()
+			r, err := s.worker(f, g, n)
+			mu.Lock()
+			defer mu.Unlock()
+			reads, adds = append(reads, r.reads...), append(adds, r.adds...)
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}(g, n)
+	}
+	wg.Wait()
+	report := Report{Ops: s.Ops, Duration: time.Since(start)}
+	if firstErr != nil {
+		_ = f.Close()
+		return Report{}, fmt.Errorf("bench: %w", firstErr)
+	}
+	report.OpsPerSec = float64(s.Ops) / report.Duration.Seconds()
+	report.Reads, report.Adds = percentiles(reads), percentiles(adds)
+
+	var positives int
+	b := make([]byte, s.KeySize)
+	for i := 0; i < s.AbsentProbes; i++ {
+		if f.Exist(putKey(b, s.Keys+uint64(i))) {
+			positives++
+		}
+	}
+	report.FPR = float64(positives) / float64(s.AbsentProbes)
+	if err = f.Close(); err != nil {
+		return Report{}, fmt.Errorf("bench: %w", err)
+	}
+	readAfter, writtenAfter := processIO()
+	report.ReadBytes, report.WrittenBytes = readAfter-readBefore, writtenAfter-writtenBefore
+	info, err := os.Stat(filename)
+	if err != nil {
+		return Report{}, fmt.Errorf("bench: %w", err)
+	}
+	report.FileSize = info.Size()
+	return report, nil
+}
+
+type workerResult struct {
+	reads, adds []time.Duration
+}
+
+// worker runs n operations of the goroutine g.
+func (s Simulation) worker(f *disk_bloom.DiskFilter, g int, n int) (r workerResult, err error) {
+	random := rand.New(rand.NewSource(s.Seed + int64(g)))
+	next := func() uint64 {
+		return uint64(random.Int63n(int64(s.Keys)))
+	}
+	if s.Distribution == DistributionZipfian {
+		zipf := rand.NewZipf(random, s.ZipfS, 1, s.Keys-1)
+		next = zipf.Uint64
+	}
+	b := make([]byte, s.KeySize)
+	for i := 0; i < n; i++ {
+		key := putKey(b, next())
+		read := random.Float64() < s.ReadRatio
+		start := time.Now()
+		if read {
+			_, err = f.ExistErr(key)
+		} else {
+			_, err = f.ExistOrAddErr(key)
+		}
+		d := time.Since(start)
+		if err != nil {
+			return r, err
+		}
+		if read {
+			r.reads = append(r.reads, d)
+		} else {
+			r.adds = append(r.adds, d)
+		}
+	}
+	return r, nil
+}
+
+// percentiles returns the percentiles of the latencies, which are sorted.
+func percentiles(latencies []time.Duration) Latency {
+	if len(latencies) == 0 {
+		return Latency{}
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+	at := func(q float64) time.Duration {
+		return latencies[int(q*float64(len(latencies)-1))]
+	}
+	return Latency{P50: at(0.5), P90: at(0.9), P99: at(0.99), P999: at(0.999), Max: latencies[len(latencies)-1]}
+}
@@ -0,0 +1,283 @@
+package disk_bloom
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// BlockCacheStats are the counters of Controller.BlockCache.
+type BlockCacheStats struct {
+	// Hits and Misses are the numbers of pages found and not found in the cache
+	Hits   uint64
+	Misses uint64
+	// Size is the current size of the cache in bytes, which changes if it is adaptive, see Controller.BlockCacheMin
+	Size int64
+}
+
+// HitRate returns the fraction of pages found in the cache.
+func (s BlockCacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// cachedStorage keeps the least recently read pages of the bloom filter in memory.
+// Writes go through to the storage beneath, and update the pages cached.
+type cachedStorage struct {
+	storage
+	// start and end are the file offsets of the bloom filter, out of which reads and writes are not cached
+	start, end int64
+	// capacity is the maximum number of pages cached
+	capacity int
+	// min and max bound the capacity if the cache is adaptive, or min is 0
+	min, max int
+	// lastHits and lastMisses are the counters at the last resize
+	lastHits, lastMisses uint64
+	// budget is charged for the pages cached
+	budget *MemoryBudget
+
+	mu    sync.Mutex
+	lru   *list.List
+	pages map[int64]*list.Element
+
+	hits   uint64
+	misses uint64
+}
+
+type cachedPage struct {
+	page int64
+	// start is the file offset of buf, which is the page clipped to the bloom filter
+	start int64
+	buf   []byte
+	// touched is whether the page is read since the last resize, guarded by mu
+	touched bool
+}
+
+func newCachedStorage(s storage, start, end int64, size int64, budget *MemoryBudget) *cachedStorage {
+	capacity := int(size / pageSize)
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &cachedStorage{
+		storage:  s,
+		start:    start,
+		end:      end,
+		capacity: capacity,
+		budget:   budget,
+		lru:      list.New(),
+		pages:    make(map[int64]*list.Element),
+	}
+}
+
+// load returns the cached page, reading it on a miss.
+func (s *cachedStorage) load(page int64) (*cachedPage, error) {
+	if e, ok := s.pages[page]; ok {
+		atomic.AddUint64(&s.hits, 1)
+		s.lru.MoveToFront(e)
+		p := e.Value.(*cachedPage)
+		p.touched = true
+		return p, nil
+	}
+	atomic.AddUint64(&s.misses, 1)
+	start, end := page*pageSize, (page+1)*pageSize
+	if start < s.start {
+		start = s.start
+	}
+	if end > s.end {
+		end = s.end
+	}
+	p := &cachedPage{page: page, start: start, buf: make([]byte, end-start), touched: true}
+	if _, err := s.storage.ReadAt(p.buf, start); err != nil {
+		return nil, err
+	}
+	if s.lru.Len() >= s.capacity {
+		s.evict()
+	}
+	for !s.budget.reserve(pageSize) {
+		if s.lru.Len() == 0 {
+			// served without being cached
+			return p, nil
+		}
+		s.evict()
+	}
+	s.pages[page] = s.lru.PushFront(p)
+	return p, nil
+}
+
+// cached returns whether the page is cached.
+func (s *cachedStorage) cached(page int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.pages[page]
+	return ok
+}
+
+// view returns the bytes [from, to) of a page in the cache, reading it on a miss.
+// The page may be evicted meanwhile, but its buffer is never reused.
+func (s *cachedStorage) view(from, to int64) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, err := s.load(from / pageSize)
+	if err != nil {
+		return nil, err
+	}
+	return p.buf[from-p.start : to-p.start : to-p.start], nil
+}
+
+// evict removes the least recently read page.
+func (s *cachedStorage) evict() {
+	oldest := s.lru.Back()
+	s.lru.Remove(oldest)
+	delete(s.pages, oldest.Value.(*cachedPage).page)
+	s.budget.release(pageSize)
+}
+
+// purge removes all pages.
+func (s *cachedStorage) purge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.lru.Len() > 0 {
+		s.evict()
+	}
+}
+
+func (s *cachedStorage) ReadAt(b []byte, offset int64) (int, error) {
+	if offset < s.start || offset+int64(len(b)) > s.end || len(b) == 0 {
+		return s.storage.ReadAt(b, offset)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for n < len(b) {
+		pos := offset + int64(n)
+		p, err := s.load(pos / pageSize)
+		if err != nil {
+			// e.g. the page is beyond the end of a shrunk file, which is read as requested
+			m, err := s.storage.ReadAt(b[n:], pos)
+			return n + m, err
+		}
+		n += copy(b[n:], p.buf[pos-p.start:])
+	}
+	return n, nil
+}
+
+func (s *cachedStorage) WriteAt(b []byte, offset int64) (int, error) {
+	n, err := s.storage.WriteAt(b, offset)
+	if n == 0 || offset+int64(n) <= s.start || offset >= s.end {
+		return n, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for page := offset / pageSize; page <= (offset+int64(n)-1)/pageSize; page++ {
+		e, ok := s.pages[page]
+		if !ok {
+			continue
+		}
+		p := e.Value.(*cachedPage)
+		// the overlap of the written bytes and the page
+		from, to := offset, offset+int64(n)
+		if from < p.start {
+			from = p.start
+		}
+		if pageEnd := p.start + int64(len(p.buf)); to > pageEnd {
+			to = pageEnd
+		}
+		copy(p.buf[from-p.start:to-p.start], b[from-offset:to-offset])
+	}
+	return n, err
+}
+
+// BlockCacheStats returns the counters of Controller.BlockCache. They are all zero if it is not set.
+func (f *DiskFilter) BlockCacheStats() BlockCacheStats {
+	if f.cache == nil {
+		return BlockCacheStats{}
+	}
+	return BlockCacheStats{
+		Hits:   atomic.LoadUint64(&f.cache.hits),
+		Misses: atomic.LoadUint64(&f.cache.misses),
+		Size:   f.cache.size(),
+	}
+}
+
+const (
+	// adaptive cache grows by 1/cacheStep while the hit rate is below cacheGrowBelow, and shrinks by 1/cacheStep
+	// while the cache would take over half of the available memory, or while the hit rate is above cacheShrinkAbove
+	// but not below the pages read since the last resize with a margin of 1/cacheStep
+	cacheStep        = 4
+	cacheGrowBelow   = 0.9
+	cacheShrinkAbove = 0.99
+	// cacheMinSamples is the number of page reads needed to judge the hit rate
+	cacheMinSamples = 256
+)
+
+// adapt makes the cache adaptive, starting at min bytes and bounded by the current capacity.
+func (s *cachedStorage) adapt(min int64) {
+	s.max = s.capacity
+	s.min = int(min / pageSize)
+	if s.min < 1 {
+		s.min = 1
+	}
+	s.capacity = s.min
+}
+
+// size returns the capacity in bytes.
+func (s *cachedStorage) size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int64(s.capacity) * pageSize
+}
+
+// resize adjusts the capacity of an adaptive cache by the hit rate since the last resize,
+// and the available memory in bytes, which is negative if unknown.
+func (s *cachedStorage) resize(available int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hits, misses := atomic.LoadUint64(&s.hits), atomic.LoadUint64(&s.misses)
+	h, m := hits-s.lastHits, misses-s.lastMisses
+	step := s.capacity / cacheStep
+	if step < 1 {
+		step = 1
+	}
+	capacity := s.capacity
+	if available >= 0 && int64(s.lru.Len())*pageSize > available/2 {
+		capacity -= step
+	} else if h+m < cacheMinSamples {
+		// too few reads to judge, keep the counters to judge with the following ones
+		return
+	} else {
+		touched := 0
+		for e := s.lru.Front(); e != nil; e = e.Next() {
+			if p := e.Value.(*cachedPage); p.touched {
+				touched++
+				p.touched = false
+			}
+		}
+		switch rate := float64(h) / float64(h+m); {
+		case rate < cacheGrowBelow && s.lru.Len() >= s.capacity:
+			if available < 0 || int64(s.capacity+step)*pageSize <= available/2 {
+				capacity += step
+			}
+		case rate > cacheShrinkAbove:
+			capacity -= step
+			if working := touched + touched/cacheStep; capacity < working {
+				capacity = working
+			}
+			if capacity > s.capacity {
+				capacity = s.capacity
+			}
+		}
+	}
+	if capacity < s.min {
+		capacity = s.min
+	}
+	if capacity > s.max {
+		capacity = s.max
+	}
+	s.capacity = capacity
+	for s.lru.Len() > s.capacity {
+		s.evict()
+	}
+	s.lastHits, s.lastMisses = hits, misses
+}
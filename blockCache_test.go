@@ -0,0 +1,129 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestDiskFilter_BlockCache(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo, BlockCache: 8 * pageSize})
+	buf := []byte("testing")
+	bf.ExistOrAdd(buf)
+	before := bf.BlockCacheStats()
+	for i := 0; i < 100; i++ {
+		if !bf.Exist(buf) {
+			t.Fatal("Should exist in filter but got false")
+		}
+	}
+	stats := bf.BlockCacheStats()
+	if stats.Misses != before.Misses {
+		t.Fatalf("Lookups of a hot entry should hit the cache, got %+v after %+v", stats, before)
+	}
+	if stats.Hits < before.Hits+100 {
+		t.Fatalf("Should hit the cache at least 100 times, got %+v", stats)
+	}
+	// the writes are seen by the pages cached
+	for i := 0; i < 1000; i++ {
+		bf.ExistOrAdd([]byte(fmt.Sprint(i)))
+	}
+	for i := 0; i < 1000; i++ {
+		if !bf.Exist([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%v should exist in filter", i)
+		}
+	}
+	// the bloom filter of 1e4 entries has 6 pages
+	if n := bf.cache.lru.Len(); n != 6 {
+		t.Fatalf("Should cache 6 pages, got %v", n)
+	}
+	if rate := bf.BlockCacheStats().HitRate(); rate <= 0 || rate >= 1 {
+		t.Fatalf("Hit rate should be between 0 and 1, got %v", rate)
+	}
+}
+
+func TestCachedStorage_Evict(t *testing.T) {
+	f, err := os.Create("testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		f.Close()
+		os.Remove("testfile")
+	}()
+	if _, err = f.WriteAt(make([]byte, 4*pageSize), 0); err != nil {
+		t.Fatal(err)
+	}
+	s := newCachedStorage(f, 0, 4*pageSize, 2*pageSize, nil)
+	var b [1]byte
+	for _, page := range []int64{0, 1, 0, 2, 0, 1} {
+		if _, err = s.ReadAt(b[:], page*pageSize); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 1 is evicted by 2, since 0 is read more recently
+	if s.hits != 2 || s.misses != 4 {
+		t.Fatalf("Should hit 2 times and miss 4 times, got %v and %v", s.hits, s.misses)
+	}
+	if _, err = s.WriteAt([]byte{1}, pageSize); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.ReadAt(b[:], pageSize); err != nil || b[0] != 1 {
+		t.Fatalf("Should read the byte written, got %v %v", b[0], err)
+	}
+}
+
+func TestCachedStorage_Resize(t *testing.T) {
+	f, err := os.Create("testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		f.Close()
+		os.Remove("testfile")
+	}()
+	const pages = 64
+	if _, err = f.WriteAt(make([]byte, pages*pageSize), 0); err != nil {
+		t.Fatal(err)
+	}
+	s := newCachedStorage(f, 0, pages*pageSize, pages*pageSize, nil)
+	s.adapt(4 * pageSize)
+	var b [1]byte
+	read := func(pages int64, times int) {
+		for i := 0; i < times; i++ {
+			if _, err = s.ReadAt(b[:], int64(i)%pages*pageSize); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// a working set of 32 pages misses a cache of 4 pages, which grows until it fits
+	for i := 0; i < 20; i++ {
+		read(32, 1000)
+		s.resize(-1)
+	}
+	if s.capacity < 32 || s.capacity > pages {
+		t.Fatalf("Should grow to fit 32 pages, got %v", s.capacity)
+	}
+	// all hits, shrinking down to the minimum
+	for i := 0; i < 20; i++ {
+		read(1, 1000)
+		s.resize(-1)
+	}
+	if s.capacity != 4 || s.lru.Len() > 4 {
+		t.Fatalf("Should shrink to 4 pages, got %v with %v pages cached", s.capacity, s.lru.Len())
+	}
+	// short of memory, never growing
+	for i := 0; i < 20; i++ {
+		read(32, 1000)
+		s.resize(2 * pageSize)
+	}
+	if s.capacity != 4 {
+		t.Fatalf("Should not grow without memory, got %v", s.capacity)
+	}
+}
+
+func TestDiskFilter_AdaptiveBlockCache(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo, BlockCache: 8 * pageSize, BlockCacheMin: 2 * pageSize})
+	if size := bf.BlockCacheStats().Size; size != 2*pageSize {
+		t.Fatalf("Should start at 2 pages, got %v", size)
+	}
+}
@@ -0,0 +1,26 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// checkBlockSize returns InvalidParamErr if the block size is not supported by the variant.
+func checkBlockSize(blockSize uint32, v variant) error {
+	if v != variantClassic {
+		return fmt.Errorf("%w: blocked %v filters", UnsupportedErr, v)
+	}
+	if blockSize < 64 || blockSize > pageSize || blockSize&(blockSize-1) != 0 {
+		return fmt.Errorf("%w: block size %v is not a power of two from 64 to %v", InvalidParamErr, blockSize, pageSize)
+	}
+	return nil
+}
+
+// blockedOffset returns the bit offset of the probe i of a blocked filter: x chooses the block by Lemire's
+// multiply-shift, and y double hashes the probes within it. The step is odd, so that the probes are distinct
+// in the blocks of a power of two bits.
+func blockedOffset(x, y uint64, i int, totalBits uint64, blockBits uint64) uint64 {
+	block, _ := bits.Mul64(x, totalBits/blockBits)
+	step := bits.RotateLeft64(y, 32) | 1
+	return block*blockBits + (y+uint64(i)*step)&(blockBits-1)
+}
@@ -0,0 +1,74 @@
+package disk_bloom
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestBlockedFilter(t *testing.T) {
+	slots, bits := OptimalParam(10000, 1e-3)
+	param := FilterParam{Slots: slots, Bits: bits, HashKind: HashKindXXHash64, BlockSize: 512}
+	controller := Controller{
+		Fsync: FsyncModeNo,
+		Debug: true,
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			return param, nil
+		},
+	}
+	dir := t.TempDir()
+	bf, err := New(dir+"/blocked", controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bf.Header().Blocked() || bf.FilterParam().Bits%(512*8) != 0 {
+		t.Fatalf("the bits should be rounded up to the blocks, got %v", bf.FilterParam().Bits)
+	}
+	for i := 0; i < 10000; i++ {
+		bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	before := bf.DebugStats()
+	for i := 0; i < 1000; i++ {
+		h := bf.Hash([]byte(strconv.Itoa(i)))
+		offsets := bf.offsets(h, int(slots))
+		if offsets[0]/4096 != offsets[len(offsets)-1]/4096 {
+			t.Fatalf("the probes of %v should land in one block, got %v", i, offsets)
+		}
+		if !bf.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should exist", i)
+		}
+	}
+	if reads := bf.DebugStats().Reads - before.Reads; reads != 1000 {
+		t.Fatalf("every lookup should be one read, got %v reads", reads)
+	}
+	var fp int
+	for i := 0; i < 10000; i++ {
+		if bf.Exist([]byte("absent" + strconv.Itoa(i))) {
+			fp++
+		}
+	}
+	if fp > 30 {
+		t.Fatalf("too many false positives: %v", fp)
+	}
+	if err = bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the block size is recorded
+	param.BlockSize = 0
+	if bf, err = New(dir+"/blocked", controller); err != nil {
+		t.Fatal(err)
+	}
+	if bf.FilterParam().BlockSize != 512 || !bf.Exist([]byte("1")) {
+		t.Fatalf("the block size should be restored, got %v", bf.FilterParam().BlockSize)
+	}
+	bf.Close()
+	param.BlockSize = 4096
+	if _, err = New(dir+"/blocked", controller); !errors.Is(err, InconsistentParamErr) {
+		t.Fatalf("Should reject another block size, got %v", err)
+	}
+	param.BlockSize = 1000
+	if _, err = New(dir+"/invalid", controller); !errors.Is(err, InvalidParamErr) {
+		t.Fatalf("Should reject a block size not a power of two, got %v", err)
+	}
+}
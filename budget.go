@@ -0,0 +1,103 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// pendingEntrySize is the approximate memory of a byte buffered in memory, including the map entry.
+const pendingEntrySize = 32
+
+var MemoryBudgetErr = fmt.Errorf("memory budget exhausted")
+
+// MemoryBudget bounds the memory of the filters sharing it, see Controller.MemoryBudget.
+// A budget can have a parent, e.g. a budget per filter under a budget per process,
+// so that the memory charged to it is also charged to the parent and bounded by both.
+type MemoryBudget struct {
+	used   int64
+	limit  int64
+	parent *MemoryBudget
+}
+
+// NewMemoryBudget returns a budget of limit bytes, which is unlimited if limit is not positive. parent is optional.
+func NewMemoryBudget(limit int64, parent *MemoryBudget) *MemoryBudget {
+	return &MemoryBudget{limit: limit, parent: parent}
+}
+
+// Used returns the bytes charged to the budget.
+func (b *MemoryBudget) Used() int64 {
+	return atomic.LoadInt64(&b.used)
+}
+
+// Limit returns the limit of the budget.
+func (b *MemoryBudget) Limit() int64 {
+	return b.limit
+}
+
+// reserve charges n bytes to the budget and its parents. It returns false and charges nothing if any of them is exhausted.
+func (b *MemoryBudget) reserve(n int64) bool {
+	if b == nil {
+		return true
+	}
+	for {
+		used := atomic.LoadInt64(&b.used)
+		if b.limit > 0 && used+n > b.limit {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&b.used, used, used+n) {
+			break
+		}
+	}
+	if !b.parent.reserve(n) {
+		atomic.AddInt64(&b.used, -n)
+		return false
+	}
+	return true
+}
+
+// release returns n bytes charged by reserve.
+func (b *MemoryBudget) release(n int64) {
+	if b == nil {
+		return
+	}
+	atomic.AddInt64(&b.used, -n)
+	b.parent.release(n)
+}
+
+// addPendingLocked buffers the bits of val at pos in memory, charging Controller.MemoryBudget for a new position.
+// It returns false if the budget is exhausted.
+func (f *DiskFilter) addPendingLocked(pos int64, val byte) bool {
+	if f.pending == nil {
+		f.pending = make(map[int64]byte)
+	}
+	if _, ok := f.pending[pos]; !ok && !f.controller.MemoryBudget.reserve(pendingEntrySize) {
+		return false
+	}
+	f.pending[pos] |= val
+	if f.pinned.contains(pos) {
+		f.pinned.buf[pos-f.pinned.start] |= val
+	}
+	return true
+}
+
+// removePendingLocked forgets the byte buffered at pos, and releases its memory.
+func (f *DiskFilter) removePendingLocked(pos int64) {
+	if _, ok := f.pending[pos]; ok {
+		delete(f.pending, pos)
+		f.controller.MemoryBudget.release(pendingEntrySize)
+	}
+}
+
+// releaseMemoryLocked releases all memory charged to Controller.MemoryBudget, on Close.
+func (f *DiskFilter) releaseMemoryLocked() {
+	for pos := range f.pending {
+		f.removePendingLocked(pos)
+	}
+	f.unpinLocked()
+	if f.cache != nil {
+		f.cache.purge()
+	}
+	if f.hybrid != nil {
+		f.controller.MemoryBudget.release(int64(len(f.hybrid.mem)))
+	}
+}
@@ -0,0 +1,76 @@
+package disk_bloom
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	process := NewMemoryBudget(100, nil)
+	a, b := NewMemoryBudget(80, process), NewMemoryBudget(0, process)
+	if !a.reserve(60) {
+		t.Fatal("Should reserve within the limits")
+	}
+	if a.reserve(30) {
+		t.Fatal("Should not exceed the limit of a")
+	}
+	if b.reserve(50) {
+		t.Fatal("Should not exceed the limit of the parent")
+	}
+	if !b.reserve(40) {
+		t.Fatal("Should reserve within the limit of the parent")
+	}
+	if process.Used() != 100 || a.Used() != 60 || b.Used() != 40 {
+		t.Fatalf("Should charge the parent, got %v %v %v", process.Used(), a.Used(), b.Used())
+	}
+	a.release(60)
+	b.release(40)
+	if process.Used() != 0 {
+		t.Fatalf("Should release the parent, got %v", process.Used())
+	}
+}
+
+func TestDiskFilter_MemoryBudget(t *testing.T) {
+	budget := NewMemoryBudget(2*pageSize, nil)
+	bf := newTestFilter(t, Controller{
+		Fsync:        FsyncModeNo,
+		BlockCache:   16 * pageSize,
+		WriteBuffer:  time.Hour,
+		MemoryBudget: budget,
+	})
+	if err := bf.Pin(Range{Length: 3 * pageSize}); !errors.Is(err, MemoryBudgetErr) {
+		t.Fatalf("Should fail with MemoryBudgetErr, got %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		bf.ExistOrAdd([]byte(fmt.Sprint(i)))
+		if used := budget.Used(); used > budget.Limit() {
+			t.Fatalf("Should use at most %v bytes, got %v", budget.Limit(), used)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		if !bf.Exist([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%v should exist", i)
+		}
+	}
+	if err := bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if used := budget.Used(); used != 0 {
+		t.Fatalf("Should release the memory on Close, got %v", used)
+	}
+}
+
+func TestDiskFilter_MemoryBudgetMmap(t *testing.T) {
+	var fallback error
+	bf := newTestFilter(t, Controller{
+		Fsync:          FsyncModeNo,
+		Mmap:           true,
+		OnMmapFallback: func(err error) { fallback = err },
+		MemoryBudget:   NewMemoryBudget(pageSize, nil),
+	})
+	if !errors.Is(fallback, MemoryBudgetErr) || bf.mapped != nil {
+		t.Fatalf("Mmap should fall back with MemoryBudgetErr, got %v", fallback)
+	}
+}
@@ -0,0 +1,71 @@
+package disk_bloom
+
+import (
+	"os"
+	"runtime"
+)
+
+// PlatformCapabilities are the platform accelerations available. Each of them falls back to the portable file I/O if missing.
+type PlatformCapabilities struct {
+	// Mmap is whether the bloom filter can be mapped, see Controller.Mmap
+	Mmap bool
+	// Fallocate is whether the pages of the file are allocated before they are mapped,
+	// so that a full disk fails the mapping instead of the writes to the mapped pages
+	Fallocate bool
+	// Reflink is whether Clone shares the blocks of the file instead of copying them
+	Reflink bool
+	// SyncFileRange is whether SyncMetadata syncs only the metadata and the header instead of the whole file
+	SyncFileRange bool
+	// SharedMemory is whether NewShared is supported
+	SharedMemory bool
+	// PunchHole is whether Compact returns the disk space of the pages all zeros
+	PunchHole bool
+	// FileLock is whether Controller.FileLock is supported
+	FileLock bool
+	// Fadvise is whether Controller.AccessPattern and Advise reach the kernel
+	Fadvise bool
+	// Xattrs is whether Controller.Xattrs writes the extended attributes
+	Xattrs bool
+	// Writable is whether the filters can be opened for writing, which is false in the WebAssembly builds
+	// without the diskbloom_writable tag
+	Writable bool
+}
+
+// Capabilities probes the platform accelerations on the filesystem of dir with a temporary file,
+// so that deployments can verify they get the fast paths. The temporary file is removed before it returns.
+func Capabilities(dir string) (PlatformCapabilities, error) {
+	c := PlatformCapabilities{Writable: writable}
+	f, err := os.CreateTemp(dir, ".capabilities-*")
+	if err != nil {
+		return c, err
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+	if _, err = f.WriteAt(make([]byte, pageSize), 0); err != nil {
+		return c, err
+	}
+	c.Fallocate = fallocate(f, 0, pageSize) == nil
+	if mem, err := mmapFile(f, 0, pageSize); err == nil {
+		c.Mmap = true
+		_ = munmapFile(mem)
+	}
+	c.SyncFileRange = syncFileRange(f, 0, pageSize) == nil
+	c.PunchHole = punchHole(f, 0, pageSize) == nil
+	c.FileLock = lockFile(f, true) == nil
+	c.Fadvise = fadviseFile(f, 0, 0, AccessPatternNormal) == nil
+	c.Xattrs = setXattr(f.Name(), XattrPurpose, "") == nil
+	clone, err := os.CreateTemp(dir, ".capabilities-*")
+	if err != nil {
+		return c, err
+	}
+	c.Reflink = reflink(clone, f) == nil
+	_ = clone.Close()
+	_ = os.Remove(clone.Name())
+	if runtime.GOOS == "linux" {
+		info, err := os.Stat("/dev/shm")
+		c.SharedMemory = err == nil && info.IsDir()
+	}
+	return c, nil
+}
@@ -0,0 +1,24 @@
+package disk_bloom
+
+import (
+	"os"
+	"runtime"
+	"testing"
+)
+
+func TestCapabilities(t *testing.T) {
+	c, err := Capabilities(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%+v", c)
+	if runtime.GOOS != "linux" && (c.Mmap || c.Fallocate || c.Reflink || c.SyncFileRange || c.SharedMemory || c.PunchHole || c.FileLock || c.Fadvise || c.Xattrs) {
+		t.Fatalf("Should have no acceleration except on Linux, got %+v", c)
+	}
+	if runtime.GOOS == "linux" && !c.Mmap {
+		t.Fatal("Should map files on Linux")
+	}
+	if _, err = Capabilities("not-exists"); !os.IsNotExist(err) {
+		t.Fatalf("Should fail on a missing directory, got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package disk_bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// The checksums of the bloom filter are kept in a sidecar file next to the filter, see Controller.Checksums:
+//
+// | magic(8) | block size(4) | reserved(4) | CRC32C of each block(4) |
+//
+// They cover the bytes on disk, so that encrypted files are verified without the key.
+const (
+	checksumMagic      = "DBLMCRC1"
+	checksumHeaderSize = 16
+	// checksumBlockSize is the number of bytes of the bloom filter covered by a checksum
+	checksumBlockSize = 64 << 10
+)
+
+var (
+	ChecksumErr = fmt.Errorf("checksum mismatch")
+	castagnoli  = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// ChecksumFilename returns the filename of the checksums of the filter file filename.
+func ChecksumFilename(filename string) string {
+	return filename + ".crc"
+}
+
+// checksums are the checksums of the blocks of the bloom filter.
+type checksums struct {
+	f    *os.File
+	crcs []uint32
+	// dirty is the blocks written since the checksums were updated
+	dirty map[int64]bool
+}
+
+// markDirty marks the blocks of the n bytes at offset of the bloom filter dirty.
+func (c *checksums) markDirty(offset int64, n int64) {
+	if c == nil {
+		return
+	}
+	for i := offset / checksumBlockSize; i <= (offset+n-1)/checksumBlockSize && i < int64(len(c.crcs)); i++ {
+		c.dirty[i] = true
+	}
+}
+
+// openChecksumsLocked opens the checksums, which are computed from the bloom filter if the sidecar file does not exist.
+func (f *DiskFilter) openChecksumsLocked() error {
+	size := f.header.bloomSize(f.param.Bits)
+	blocks := (size + checksumBlockSize - 1) / checksumBlockSize
+	filename := ChecksumFilename(f.file.f.Name())
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	c := &checksums{f: file, crcs: make([]uint32, blocks), dirty: make(map[int64]bool)}
+	raw := retryStorage{file}
+	b := make([]byte, checksumHeaderSize+4*blocks)
+	if n, err := raw.ReadAt(b, 0); n == 0 && err == io.EOF {
+		// new, every block is computed
+		copy(b, checksumMagic)
+		binary.LittleEndian.PutUint32(b[len(checksumMagic):], checksumBlockSize)
+		if _, err = raw.WriteAt(b[:checksumHeaderSize], 0); err != nil {
+			_ = file.Close()
+			return err
+		}
+		for i := range c.crcs {
+			c.dirty[int64(i)] = true
+		}
+	} else if err != nil || string(b[:len(checksumMagic)]) != checksumMagic || binary.LittleEndian.Uint32(b[len(checksumMagic):]) != checksumBlockSize {
+		_ = file.Close()
+		return fmt.Errorf("%w: invalid checksum file %v", ChecksumErr, filename)
+	} else {
+		for i := range c.crcs {
+			c.crcs[i] = binary.LittleEndian.Uint32(b[checksumHeaderSize+4*i:])
+		}
+	}
+	f.checksums = c
+	return f.updateChecksumsLocked()
+}
+
+// blockChecksum computes the checksum of the block i from the disk.
+func (f *DiskFilter) blockChecksum(i int64, buf []byte) (uint32, error) {
+	size := f.header.bloomSize(f.param.Bits)
+	n := int64(checksumBlockSize)
+	if rest := size - i*checksumBlockSize; rest < n {
+		n = rest
+	}
+	if _, err := (retryStorage{f.file.f}).ReadAt(buf[:n], f.bloomStart+i*checksumBlockSize); err != nil {
+		return 0, err
+	}
+	return crc32.Checksum(buf[:n], castagnoli), nil
+}
+
+// updateChecksumsLocked recomputes the checksums of the dirty blocks, and writes them into the sidecar file.
+func (f *DiskFilter) updateChecksumsLocked() error {
+	c := f.checksums
+	if c == nil || len(c.dirty) == 0 {
+		return nil
+	}
+	buf := make([]byte, checksumBlockSize)
+	var b [4]byte
+	for i := range c.dirty {
+		crc, err := f.blockChecksum(i, buf)
+		if err != nil {
+			return err
+		}
+		c.crcs[i] = crc
+		binary.LittleEndian.PutUint32(b[:], crc)
+		if _, err = (retryStorage{c.f}).WriteAt(b[:], checksumHeaderSize+4*i); err != nil {
+			return err
+		}
+		delete(c.dirty, i)
+	}
+	if f.file.fsync != FsyncModeNo {
+		return c.f.Sync()
+	}
+	return nil
+}
+
+// closeChecksumsLocked closes the sidecar file after updating it.
+func (f *DiskFilter) closeChecksumsLocked() error {
+	if f.checksums == nil {
+		return nil
+	}
+	err := f.updateChecksumsLocked()
+	if e := f.checksums.f.Close(); err == nil {
+		err = e
+	}
+	f.checksums = nil
+	return err
+}
+
+// removeChecksumsLocked removes the sidecar file, e.g. when the bloom filter is shrunk.
+func (f *DiskFilter) removeChecksumsLocked() error {
+	if f.checksums == nil {
+		return nil
+	}
+	_ = f.checksums.f.Close()
+	f.checksums = nil
+	return os.Remove(ChecksumFilename(f.file.f.Name()))
+}
+
+// Verify reads the whole bloom filter, and returns ChecksumErr with the offsets of the corrupt blocks
+// if any block does not match its checksum, see Controller.Checksums.
+// The blocks written since the checksums were last updated are not verified, but their checksums are updated.
+func (f *DiskFilter) Verify() error {
+	if !f.acquire() {
+		return ClosedErr
+	}
+	defer f.release()
+	f.file.mu.Lock()
+	defer f.file.mu.Unlock()
+	return f.verifyChecksumsLocked()
+}
+
+func (f *DiskFilter) verifyChecksumsLocked() error {
+	c := f.checksums
+	if c == nil {
+		return fmt.Errorf("checksums are not enabled")
+	}
+	if err := f.updateChecksumsLocked(); err != nil {
+		return err
+	}
+	buf := make([]byte, checksumBlockSize)
+	var corrupt []int64
+	for i, want := range c.crcs {
+		crc, err := f.blockChecksum(int64(i), buf)
+		if err != nil {
+			return err
+		}
+		if crc != want {
+			corrupt = append(corrupt, f.bloomStart+int64(i)*checksumBlockSize)
+		}
+	}
+	if len(corrupt) > 0 {
+		return fmt.Errorf("%w: %v blocks of %v bytes at the file offsets %v", ChecksumErr, len(corrupt), checksumBlockSize, corrupt)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package disk_bloom
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestDiskFilter_Verify(t *testing.T) {
+	defer os.Remove(ChecksumFilename("testfile"))
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo, Checksums: true})
+	for i := 0; i < 1000; i++ {
+		bf.ExistOrAdd([]byte(fmt.Sprint(i)))
+	}
+	if err := bf.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	bloomStart := bf.bloomStart
+	bf.Close()
+
+	bf = newTestFilter(t, Controller{Fsync: FsyncModeNo, Checksums: true, VerifyOnOpen: true})
+	// flip a bit behind the filter
+	f, err := os.OpenFile("testfile", os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b [1]byte
+	f.ReadAt(b[:], bloomStart+1)
+	b[0] ^= 1
+	f.WriteAt(b[:], bloomStart+1)
+	f.Close()
+	if err = bf.Verify(); !errors.Is(err, ChecksumErr) {
+		t.Fatalf("should fail with ChecksumErr, got %v", err)
+	}
+	bf.Close()
+
+	if _, err = New("testfile", Controller{
+		Fsync:        FsyncModeNo,
+		Checksums:    true,
+		VerifyOnOpen: true,
+		GetParam:     bf.controller.GetParam,
+	}); !errors.Is(err, ChecksumErr) {
+		t.Fatalf("should fail with ChecksumErr, got %v", err)
+	}
+}
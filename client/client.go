@@ -0,0 +1,397 @@
+// Package client is a Go client of the HTTP API of the server package. It gathers the concurrent calls into batches,
+// retries the failed requests with backoff, and optionally caches the keys confirmed to be in the filter,
+// so that the keys seen again do not need a round trip.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mzz2017/disk-bloom/server"
+)
+
+var ServerErr = fmt.Errorf("server error")
+
+// Client calls a server.Server. It is safe for concurrent use.
+type Client struct {
+	url  string
+	http *http.Client
+
+	maxBatch int
+	linger   time.Duration
+
+	attempts   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+
+	cache *positiveCache
+
+	mu      sync.Mutex
+	batches map[string]*batch
+}
+
+// Option configures a Client.
+type Option func(c *Client)
+
+// WithHTTPClient sets the http.Client of the requests, http.DefaultClient by default.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.http = client
+	}
+}
+
+// WithBatch gathers the calls of single keys made within linger of each other into a request of up to maxBatch keys.
+// The default is 256 keys within a millisecond, and a non-positive maxBatch sends every call on its own.
+func WithBatch(maxBatch int, linger time.Duration) Option {
+	return func(c *Client) {
+		c.maxBatch, c.linger = maxBatch, linger
+	}
+}
+
+// WithRetry makes up to attempts of a request, waiting for backoff before the second, doubled afterwards
+// up to maxBackoff, with a random jitter. The default is 3 attempts from 50ms up to 1s.
+// The requests looking up or adding keys are retried on the network errors and the 5xx responses.
+// Since a key added by a failed attempt would be reported as existing by the next,
+// the requests of ExistOrAdd are only retried if the server did not get them: on the errors of connecting,
+// and the 429 and 503 responses.
+func WithRetry(attempts int, backoff time.Duration, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.attempts, c.backoff, c.maxBackoff = attempts, backoff, maxBackoff
+	}
+}
+
+// WithPositiveCache keeps up to size keys confirmed to be in the filter, evicting the oldest first,
+// which Exist, ExistOrAdd and Add answer without a request. The keys are kept for ttl if it is positive,
+// which should be shorter than the rotation of a FilterGroup served, whose keys expire with its filters.
+func WithPositiveCache(size int, ttl time.Duration) Option {
+	return func(c *Client) {
+		c.cache = newPositiveCache(size, ttl)
+	}
+}
+
+// New returns a Client of the server at url, e.g. "http://localhost:8080" or with the prefix mounted under.
+func New(url string, opts ...Option) *Client {
+	c := &Client{
+		url:        strings.TrimSuffix(url, "/"),
+		http:       http.DefaultClient,
+		maxBatch:   256,
+		linger:     time.Millisecond,
+		attempts:   3,
+		backoff:    50 * time.Millisecond,
+		maxBackoff: time.Second,
+		batches:    make(map[string]*batch),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.attempts < 1 {
+		c.attempts = 1
+	}
+	return c
+}
+
+// Exist returns whether the key is in the filter.
+func (c *Client) Exist(ctx context.Context, key []byte) (bool, error) {
+	if c.cache.contains(key) {
+		return true, nil
+	}
+	return c.call(ctx, "/exist", key)
+}
+
+// ExistOrAdd returns whether the key was in the filter, and adds it if it was not.
+func (c *Client) ExistOrAdd(ctx context.Context, key []byte) (bool, error) {
+	if c.cache.contains(key) {
+		return true, nil
+	}
+	return c.call(ctx, "/exist-or-add", key)
+}
+
+// Add adds the key to the filter.
+func (c *Client) Add(ctx context.Context, key []byte) error {
+	if c.cache.contains(key) {
+		return nil
+	}
+	_, err := c.call(ctx, "/add", key)
+	return err
+}
+
+// ExistBatch returns whether each of the keys is in the filter, by a single request for the keys not cached.
+func (c *Client) ExistBatch(ctx context.Context, keys [][]byte) ([]bool, error) {
+	return c.batchCall(ctx, "/exist", keys)
+}
+
+// ExistOrAddBatch returns whether each of the keys was in the filter, and adds the keys not in,
+// by a single request for the keys not cached.
+func (c *Client) ExistOrAddBatch(ctx context.Context, keys [][]byte) ([]bool, error) {
+	return c.batchCall(ctx, "/exist-or-add", keys)
+}
+
+// Stats returns the statistics of the server.
+func (c *Client) Stats(ctx context.Context) (server.Stats, error) {
+	var stats server.Stats
+	err := c.retry(ctx, true, func() (bool, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"/stats", nil)
+		if err != nil {
+			return false, err
+		}
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return true, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return retryable(resp.StatusCode, true), responseErr(resp)
+		}
+		return false, json.NewDecoder(resp.Body).Decode(&stats)
+	})
+	return stats, err
+}
+
+// batchCall sends the keys not cached by a single request.
+func (c *Client) batchCall(ctx context.Context, path string, keys [][]byte) ([]bool, error) {
+	exist := make([]bool, len(keys))
+	var missed [][]byte
+	var index []int
+	for i, key := range keys {
+		if c.cache.contains(key) {
+			exist[i] = true
+			continue
+		}
+		missed = append(missed, key)
+		index = append(index, i)
+	}
+	if len(missed) == 0 {
+		return exist, nil
+	}
+	got, err := c.post(ctx, path, missed)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range index {
+		exist[i] = got[j]
+	}
+	return exist, nil
+}
+
+// call sends the key in the batch of the path being gathered.
+func (c *Client) call(ctx context.Context, path string, key []byte) (bool, error) {
+	if c.maxBatch <= 0 {
+		exist, err := c.post(ctx, path, [][]byte{key})
+		if err != nil {
+			return false, err
+		}
+		return exist[0], nil
+	}
+	p := &pendingCall{key: key, done: make(chan struct{})}
+	c.mu.Lock()
+	b := c.batches[path]
+	if b == nil {
+		b = &batch{}
+		c.batches[path] = b
+		time.AfterFunc(c.linger, func() {
+			c.flush(path, b)
+		})
+	}
+	b.calls = append(b.calls, p)
+	full := len(b.calls) >= c.maxBatch
+	c.mu.Unlock()
+	if full {
+		c.flush(path, b)
+	}
+	select {
+	case <-p.done:
+		return p.exist, p.err
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// batch is the calls of single keys gathered into a request.
+type batch struct {
+	calls []*pendingCall
+}
+
+type pendingCall struct {
+	key   []byte
+	exist bool
+	err   error
+	done  chan struct{}
+}
+
+// flush sends the batch, unless it is sent already.
+func (c *Client) flush(path string, b *batch) {
+	c.mu.Lock()
+	if c.batches[path] != b {
+		c.mu.Unlock()
+		return
+	}
+	delete(c.batches, path)
+	c.mu.Unlock()
+	keys := make([][]byte, len(b.calls))
+	for i, p := range b.calls {
+		keys[i] = p.key
+	}
+	// the batch outlives the contexts of the calls, which only stop waiting for it
+	exist, err := c.post(context.Background(), path, keys)
+	for i, p := range b.calls {
+		if err != nil {
+			p.err = err
+		} else {
+			p.exist = exist[i]
+		}
+		close(p.done)
+	}
+}
+
+// post sends the keys to the path, and caches the keys found or added.
+func (c *Client) post(ctx context.Context, path string, keys [][]byte) ([]bool, error) {
+	req := server.Request{Keys: make([]string, len(keys)), Encoding: "base64"}
+	for i, key := range keys {
+		req.Keys[i] = base64.StdEncoding.EncodeToString(key)
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	idempotent := path != "/exist-or-add"
+	var resp server.Response
+	err = c.retry(ctx, idempotent, func() (bool, error) {
+		r, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+path, bytes.NewReader(body))
+		if err != nil {
+			return false, err
+		}
+		r.Header.Set("Content-Type", "application/json")
+		hr, err := c.http.Do(r)
+		if err != nil {
+			return idempotent || isDialErr(err), err
+		}
+		defer hr.Body.Close()
+		if hr.StatusCode != http.StatusOK {
+			return retryable(hr.StatusCode, idempotent), responseErr(hr)
+		}
+		resp = server.Response{}
+		return false, json.NewDecoder(hr.Body).Decode(&resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if path == "/add" {
+		resp.Exist = make([]bool, len(keys))
+	} else if len(resp.Exist) != len(keys) {
+		return nil, fmt.Errorf("%w: %v results of %v keys", ServerErr, len(resp.Exist), len(keys))
+	}
+	for i, key := range keys {
+		if path != "/exist" || resp.Exist[i] {
+			// found, or added
+			c.cache.add(key)
+		}
+	}
+	return resp.Exist, nil
+}
+
+// retry invokes do until it succeeds, returns an error not to retry, or the attempts are made,
+// waiting for the backoff between them.
+func (c *Client) retry(ctx context.Context, idempotent bool, do func() (retry bool, err error)) error {
+	backoff := c.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := do()
+		if err == nil || !retry || attempt >= c.attempts || ctx.Err() != nil {
+			return err
+		}
+		// full jitter within [backoff/2, backoff)
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+		if backoff *= 2; backoff > c.maxBackoff {
+			backoff = c.maxBackoff
+		}
+	}
+}
+
+// retryable returns whether the response of the status is worth retrying.
+func retryable(status int, idempotent bool) bool {
+	switch {
+	case status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable:
+		return true
+	case status >= 500:
+		return idempotent
+	default:
+		return false
+	}
+}
+
+// isDialErr returns whether err failed to connect, so that the request did not reach the server.
+func isDialErr(err error) bool {
+	var op *net.OpError
+	return errors.As(err, &op) && op.Op == "dial"
+}
+
+// responseErr returns the error of a response other than 200 OK.
+func responseErr(resp *http.Response) error {
+	var r server.Response
+	if json.NewDecoder(resp.Body).Decode(&r) == nil && r.Error != "" {
+		return fmt.Errorf("%w: %v: %v", ServerErr, resp.Status, r.Error)
+	}
+	return fmt.Errorf("%w: %v", ServerErr, resp.Status)
+}
+
+// positiveCache is the keys confirmed to be in the filter, evicted in the order of addition.
+type positiveCache struct {
+	size int
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	added map[string]time.Time
+	order []string
+}
+
+func newPositiveCache(size int, ttl time.Duration) *positiveCache {
+	if size <= 0 {
+		return nil
+	}
+	return &positiveCache{size: size, ttl: ttl, added: make(map[string]time.Time, size)}
+}
+
+func (p *positiveCache) contains(key []byte) bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	added, ok := p.added[string(key)]
+	return ok && (p.ttl <= 0 || time.Since(added) < p.ttl)
+}
+
+func (p *positiveCache) add(key []byte) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	k := string(key)
+	if _, ok := p.added[k]; ok {
+		p.added[k] = time.Now()
+		return
+	}
+	if len(p.order) >= p.size {
+		delete(p.added, p.order[0])
+		p.order = p.order[1:]
+	}
+	p.added[k] = time.Now()
+	p.order = append(p.order, k)
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	disk_bloom "github.com/mzz2017/disk-bloom"
+	"github.com/mzz2017/disk-bloom/server"
+)
+
+// newServer serves a new filter, failing the first requests with failures.
+func newServer(t *testing.T, failures int32, requests *int32) *httptest.Server {
+	slots, bits := disk_bloom.OptimalParam(1000, 0.001)
+	f, err := disk_bloom.New(filepath.Join(t.TempDir(), "testfile"), disk_bloom.Controller{
+		Fsync: disk_bloom.FsyncModeNo,
+		GetParam: func(metadata []byte) (disk_bloom.FilterParam, []byte) {
+			return disk_bloom.FilterParam{Slots: slots, Bits: bits, HashKind: disk_bloom.HashKindXXHash64}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	s := server.New(f)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		s.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestClient(t *testing.T) {
+	var requests int32
+	ts := newServer(t, 2, &requests)
+	c := New(ts.URL, WithRetry(3, time.Millisecond, time.Millisecond))
+	ctx := context.Background()
+	if exist, err := c.ExistOrAdd(ctx, []byte{0, 0xff}); exist || err != nil {
+		t.Fatalf("Should be added after the retries, got %v %v", exist, err)
+	}
+	if requests != 3 {
+		t.Fatalf("Should retry the unavailable server, got %v requests", requests)
+	}
+	if exist, err := c.Exist(ctx, []byte{0, 0xff}); !exist || err != nil {
+		t.Fatalf("Should exist, got %v %v", exist, err)
+	}
+	if err := c.Add(ctx, []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	exist, err := c.ExistOrAddBatch(ctx, [][]byte{[]byte("a"), []byte("b"), []byte("b")})
+	if err != nil || !exist[0] || exist[1] || !exist[2] {
+		t.Fatalf("Unexpected %v %v", exist, err)
+	}
+	if stats, err := c.Stats(ctx); err != nil || stats.Adds != 5 {
+		t.Fatalf("Unexpected stats %+v %v", stats, err)
+	}
+
+	// not retried beyond the attempts
+	atomic.StoreInt32(&requests, 0)
+	ts = newServer(t, 10, &requests)
+	c = New(ts.URL, WithRetry(2, time.Millisecond, time.Millisecond))
+	if _, err := c.Exist(ctx, []byte("a")); !errors.Is(err, ServerErr) || requests != 2 {
+		t.Fatalf("Should fail after 2 attempts, got %v and %v requests", err, requests)
+	}
+}
+
+func TestClient_Batch(t *testing.T) {
+	var requests int32
+	ts := newServer(t, 0, &requests)
+	c := New(ts.URL, WithBatch(64, 20*time.Millisecond))
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if exist, err := c.ExistOrAdd(context.Background(), []byte(strconv.Itoa(i))); exist || err != nil {
+				t.Errorf("%v should be added, got %v %v", i, exist, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if requests >= 64 {
+		t.Fatalf("Should gather the calls into batches, got %v requests", requests)
+	}
+}
+
+func TestClient_PositiveCache(t *testing.T) {
+	var requests int32
+	ts := newServer(t, 0, &requests)
+	c := New(ts.URL, WithBatch(0, 0), WithPositiveCache(2, 0))
+	ctx := context.Background()
+	for _, key := range []string{"a", "a", "b", "b"} {
+		if _, err := c.ExistOrAdd(ctx, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests != 2 {
+		t.Fatalf("Should answer the cached keys locally, got %v requests", requests)
+	}
+	if exist, err := c.Exist(ctx, []byte("c")); exist || err != nil || requests != 3 {
+		t.Fatalf("Should not cache the keys not found, got %v %v", exist, err)
+	}
+	c.ExistOrAdd(ctx, []byte("c"))
+	if c.cache.contains([]byte("a")) || !c.cache.contains([]byte("c")) {
+		t.Fatal("Should evict the oldest key")
+	}
+}
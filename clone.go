@@ -0,0 +1,90 @@
+package disk_bloom
+
+import (
+	"io"
+	"os"
+)
+
+// Clone copies the filter file to filename, which must not exist, e.g. to snapshot a large filter.
+// The copy is consistent since the filter is locked during the copy, and can be opened with the same Controller.
+// On filesystems supporting reflinks (btrfs, XFS), the copy is instantaneous and shares the blocks
+// until either file is modified. Otherwise it is copied by copy_file_range where available, and by streaming at last.
+// The bits buffered in memory due to the full disk are not in the copy.
+func (f *DiskFilter) Clone(filename string) error {
+	if !f.acquire() {
+		return ClosedErr
+	}
+	defer f.release()
+	f.file.mu.Lock()
+	defer f.file.mu.Unlock()
+	return f.cloneLocked(filename)
+}
+
+// cloneLocked is Clone with the file locked.
+func (f *DiskFilter) cloneLocked(filename string) error {
+	if err := f.prepareCopyLocked(); err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(filename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if f.file.osFile() != nil {
+		err = copyFile(dst, f.file.f.Name())
+	} else {
+		err = copyHandle(dst, f.file.f)
+	}
+	if err == nil {
+		err = dst.Sync()
+	}
+	if e := dst.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		_ = os.Remove(filename)
+	}
+	return err
+}
+
+// prepareCopyLocked brings the file up to date before it is copied.
+func (f *DiskFilter) prepareCopyLocked() error {
+	if len(f.pending) > 0 {
+		_ = f.flushPendingLocked()
+	}
+	if f.hybrid != nil {
+		if err := f.hybrid.flush(); err != nil {
+			return err
+		}
+	}
+	f.controlLocked()
+	if err := f.persistHeaderLocked(); err != nil {
+		return err
+	}
+	return f.signLocked()
+}
+
+// copyFile copies the file src to dst, by reflink if possible.
+func copyFile(dst *os.File, src string) error {
+	// open src again to read from the beginning
+	s, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if reflink(dst, s) == nil {
+		return nil
+	}
+	// io.Copy between files uses copy_file_range on Linux, and falls back to streaming
+	_, err = io.Copy(dst, s)
+	return err
+}
+
+// copyHandle copies the file opened by OpenFS to dst.
+func copyHandle(dst *os.File, src fileHandle) error {
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, io.NewSectionReader(src, 0, info.Size()))
+	return err
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package disk_bloom
+
+import "os"
+
+// reflink makes dst share the blocks of src. It is only supported on Linux.
+func reflink(dst, src *os.File) error {
+	return UnsupportedErr
+}
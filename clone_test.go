@@ -0,0 +1,31 @@
+package disk_bloom
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDiskFilter_Clone(t *testing.T) {
+	bf := newTestFilter(t, Controller{})
+	bf.ExistOrAdd([]byte("before"))
+	defer os.Remove("testfile.clone")
+	if err := bf.Clone("testfile.clone"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bf.Clone("testfile.clone"); !os.IsExist(err) {
+		t.Fatalf("Should not overwrite an existing file, got %v", err)
+	}
+	bf.ExistOrAdd([]byte("after"))
+
+	clone, err := New("testfile.clone", bf.Controller())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clone.Close()
+	if !clone.Exist([]byte("before")) {
+		t.Fatal("Should exist in the clone but got false")
+	}
+	if clone.Exist([]byte("after")) {
+		t.Fatal("Should missing in the clone but got true")
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	disk_bloom "github.com/mzz2017/disk-bloom"
+	"github.com/mzz2017/disk-bloom/bench"
+)
+
+func runBench(w io.Writer, args []string) error {
+	fs := newFlagSet("bench")
+	dir := fs.String("dir", os.TempDir(), "directory of the filter file, which is removed afterwards")
+	keys := fs.Uint64("keys", 1000000, "size of the key space, which the filter is sized for")
+	keySize := fs.Int("key-size", 16, "size of a key in bytes, at least 8")
+	ops := fs.Int("ops", 1000000, "number of operations")
+	reads := fs.Float64("reads", 0.5, "fraction of the operations which are lookups, and the rest are adds")
+	dist := fs.String("dist", "uniform", "distribution of the keys: uniform or zipfian")
+	zipfS := fs.Float64("zipf-s", 1.1, "exponent of the zipfian distribution, greater than 1")
+	goroutines := fs.Int("goroutines", 1, "number of goroutines")
+	seed := fs.Int64("seed", 1, "seed of the keys drawn")
+	fpr := fs.Float64("fpr", 0.001, "false positive rate the filter is sized for")
+	fsync := fs.String("fsync", "no", "fsync mode: always, every_sec or no")
+	blockCache := fs.Int64("block-cache", 0, "bytes of the block cache")
+	blockSize := fs.Uint("block-size", 0, "block size in bytes of a blocked filter, or 0 for the classic layout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	distribution, err := bench.ParseDistribution(*dist)
+	if err != nil {
+		return err
+	}
+	mode, err := parseFsyncMode(*fsync)
+	if err != nil {
+		return err
+	}
+	report, err := bench.Simulate(*dir, bench.Simulation{
+		Keys:         *keys,
+		KeySize:      *keySize,
+		Ops:          *ops,
+		ReadRatio:    *reads,
+		Distribution: distribution,
+		ZipfS:        *zipfS,
+		Goroutines:   *goroutines,
+		Seed:         *seed,
+		FPRate:       *fpr,
+		Fsync:        mode,
+		BlockCache:   *blockCache,
+		BlockSize:    uint32(*blockSize),
+	})
+	if err != nil {
+		return err
+	}
+	writeReport(w, report)
+	return nil
+}
+
+func parseFsyncMode(s string) (disk_bloom.FsyncMode, error) {
+	for _, mode := range []disk_bloom.FsyncMode{disk_bloom.FsyncModeAlways, disk_bloom.FsyncModeEverySec, disk_bloom.FsyncModeNo} {
+		if mode.String() == s {
+			return mode, nil
+		}
+	}
+	return 0, fmt.Errorf("bench: unknown fsync mode %q", s)
+}
+
+func writeReport(w io.Writer, r bench.Report) {
+	fmt.Fprintf(w, "ops:           %v in %v\n", r.Ops, r.Duration)
+	fmt.Fprintf(w, "throughput:    %.0f ops/s\n", r.OpsPerSec)
+	for _, l := range []struct {
+		name string
+		bench.Latency
+	}{{"lookups", r.Reads}, {"adds", r.Adds}} {
+		fmt.Fprintf(w, "%-14v p50 %v, p90 %v, p99 %v, p99.9 %v, max %v\n", l.name+":", l.P50, l.P90, l.P99, l.P999, l.Max)
+	}
+	fmt.Fprintf(w, "achieved FPR:  %.3g\n", r.FPR)
+	fmt.Fprintf(w, "disk I/O:      %v bytes read, %v bytes written\n", r.ReadBytes, r.WrittenBytes)
+	fmt.Fprintf(w, "file size:     %v bytes\n", r.FileSize)
+}
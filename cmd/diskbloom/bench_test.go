@@ -0,0 +1,22 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRunBench(t *testing.T) {
+	var sb strings.Builder
+	err := runBench(&sb, []string{"-dir", t.TempDir(), "-keys", "1000", "-ops", "2000", "-dist", "zipfian", "-goroutines", "2", "-fsync", "every_sec", "-block-size", "64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"ops:           2000 in ", "lookups:", "adds:", "achieved FPR:", "file size:"} {
+		if !strings.Contains(sb.String(), s) {
+			t.Fatalf("Should report %q, got:\n%v", s, sb.String())
+		}
+	}
+	if err = runBench(&sb, []string{"-fsync", "sometimes"}); err == nil {
+		t.Fatal("Should fail with an unknown fsync mode")
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	disk_bloom "github.com/mzz2017/disk-bloom"
+)
+
+func runInfo(w io.Writer, args []string) error {
+	fs := newFlagSet("info")
+	hashKey := hashKeyFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("info: no file given")
+	}
+	for _, path := range fs.Args() {
+		f, err := openFilter(path, *hashKey, false, disk_bloom.WithReadOnly())
+		if err != nil {
+			return err
+		}
+		writeInfo(w, path, f)
+		if err = f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeInfo(w io.Writer, path string, f *disk_bloom.DiskFilter) {
+	header, d := f.Header(), f.Describe()
+	count := f.EstimateCount()
+	fmt.Fprintf(w, "%v:\n", path)
+	fmt.Fprintf(w, "  variant:         %v\n", d.Variant)
+	fmt.Fprintf(w, "  format version:  %v\n", d.FormatVersion)
+	fmt.Fprintf(w, "  flags:           %#x\n", d.Flags)
+	fmt.Fprintf(w, "  tag:             %v\n", d.Tag)
+	fmt.Fprintf(w, "  hash:            %v\n", header.HashKind)
+	fmt.Fprintf(w, "  slots:           %v\n", d.Slots)
+	fmt.Fprintf(w, "  bits:            %v\n", d.Bits)
+	fmt.Fprintf(w, "  fingerprint:     %016x\n", f.Fingerprint())
+	fmt.Fprintf(w, "  metadata size:   %v bytes\n", d.MetadataSize)
+	fmt.Fprintf(w, "  sealed:          %v\n", d.Sealed)
+	if !header.Created.IsZero() {
+		fmt.Fprintf(w, "  created:         %v\n", header.Created.UTC().Format(time.RFC3339))
+	}
+	if !header.LastAdd.IsZero() {
+		fmt.Fprintf(w, "  last add:        %v\n", header.LastAdd.UTC().Format(time.RFC3339))
+	}
+	if !header.LastSync.IsZero() {
+		fmt.Fprintf(w, "  last sync:       %v\n", header.LastSync.UTC().Format(time.RFC3339))
+	}
+	fmt.Fprintf(w, "  created by:      %v on %v, version %v\n", header.Command, header.Hostname, header.LibraryVersion)
+	fmt.Fprintf(w, "  fill ratio:      %.6f\n", f.FillRatio())
+	fmt.Fprintf(w, "  estimated count: %.0f\n", count)
+	fmt.Fprintf(w, "  estimated FPR:   %.3g\n", disk_bloom.EstimateFPR(d.Slots, d.Bits, uint64(count)))
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	disk_bloom "github.com/mzz2017/disk-bloom"
+)
+
+func TestInfo(t *testing.T) {
+	path := createFilter(t, "filter", disk_bloom.WithTag("tenant-1"))
+	if err := runAdd(&strings.Builder{}, []string{path, "a", "b", "c"}); err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	if err := runInfo(&sb, []string{path}); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"  variant:         classic\n",
+		"  tag:             tenant-1\n",
+		"  hash:            xxhash64\n",
+		"  estimated count: 3\n",
+	} {
+		if !strings.Contains(sb.String(), line) {
+			t.Fatalf("Should contain %q, got:\n%v", line, sb.String())
+		}
+	}
+}
+
+func TestInfo_CustomHash(t *testing.T) {
+	path := createFilter(t, "filter", disk_bloom.WithHashKind(disk_bloom.HashKindCustom, nil), disk_bloom.WithHash(func(b []byte) (uint64, uint64) {
+		return uint64(len(b)), 1
+	}))
+	var sb strings.Builder
+	if err := runInfo(&sb, []string{path}); err != nil {
+		t.Fatal(err)
+	}
+	if line := "  hash:            custom\n"; !strings.Contains(sb.String(), line) {
+		t.Fatalf("Should contain %q, got:\n%v", line, sb.String())
+	}
+	if err := runCheck(&sb, []string{path, "a"}); err == nil {
+		t.Fatal("Should not look up a file of an unknown hash")
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+func runCheck(w io.Writer, args []string) error {
+	fs := newFlagSet("check")
+	hashKey := hashKeyFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return fmt.Errorf("check: usage: check <file> <key>...")
+	}
+	f, err := openFilter(fs.Arg(0), *hashKey, true)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for _, key := range fs.Args()[1:] {
+		fmt.Fprintf(w, "%v: %v\n", key, f.Exist([]byte(key)))
+	}
+	return nil
+}
+
+func runAdd(w io.Writer, args []string) error {
+	fs := newFlagSet("add")
+	hashKey := hashKeyFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return fmt.Errorf("add: usage: add <file> <key>...")
+	}
+	f, err := openFilter(fs.Arg(0), *hashKey, true)
+	if err != nil {
+		return err
+	}
+	for _, key := range fs.Args()[1:] {
+		exist, err := f.ExistOrAddErr([]byte(key))
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+		if exist {
+			fmt.Fprintf(w, "%v: exists\n", key)
+		} else {
+			fmt.Fprintf(w, "%v: added\n", key)
+		}
+	}
+	return f.Close()
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	disk_bloom "github.com/mzz2017/disk-bloom"
+)
+
+// createFilter creates a filter file of a built-in hash under a temporary directory.
+func createFilter(t *testing.T, name string, opts ...disk_bloom.Option) string {
+	path := filepath.Join(t.TempDir(), name)
+	opts = append([]disk_bloom.Option{disk_bloom.WithHashKind(disk_bloom.HashKindXXHash64, nil), disk_bloom.WithCapacity(1000, 0.01)}, opts...)
+	f, err := disk_bloom.Open(path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAddCheck(t *testing.T) {
+	path := createFilter(t, "filter")
+	var sb strings.Builder
+	if err := runAdd(&sb, []string{path, "a", "b", "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "a: added\nb: added\na: exists\n"; sb.String() != want {
+		t.Fatalf("Should be %q, got %q", want, sb.String())
+	}
+	sb.Reset()
+	if err := runCheck(&sb, []string{path, "a", "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "a: true\nc: false\n"; sb.String() != want {
+		t.Fatalf("Should be %q, got %q", want, sb.String())
+	}
+	if err := runCheck(&sb, []string{filepath.Join(t.TempDir(), "missing"), "a"}); err == nil {
+		t.Fatal("Should fail to check a missing file")
+	}
+}
@@ -0,0 +1,112 @@
+// Command diskbloom inspects and edits filter files of disk_bloom.
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	disk_bloom "github.com/mzz2017/disk-bloom"
+)
+
+const usage = `Usage: diskbloom <command> [arguments]
+
+Commands:
+  stats    print fill ratio, estimated count and FPR of filter files
+  info     print the header, fill ratio and estimated count of filter files
+  check    report whether keys are in a filter file
+  add      add keys to a filter file
+  merge    merge filter files into the first one
+  verify   verify filter files against their checksums
+  shell    investigate a filter file interactively
+  bench    run a generated workload against a filter file
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "stats":
+		err = runStats(os.Stdout, os.Args[2:])
+	case "info":
+		err = runInfo(os.Stdout, os.Args[2:])
+	case "check":
+		err = runCheck(os.Stdout, os.Args[2:])
+	case "add":
+		err = runAdd(os.Stdout, os.Args[2:])
+	case "merge":
+		err = runMerge(os.Stdout, os.Args[2:])
+	case "verify":
+		err = runVerify(os.Stdout, os.Args[2:])
+	case "shell":
+		err = runShell(os.Stdin, os.Stdout, os.Args[2:])
+	case "bench":
+		err = runBench(os.Stdout, os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "diskbloom:", err)
+		os.Exit(1)
+	}
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// hashKeyFlag adds the -hash-key flag, the key of files hashed by siphash in hex.
+func hashKeyFlag(fs *flag.FlagSet) *string {
+	return fs.String("hash-key", "", "key of files hashed by siphash, in hex")
+}
+
+// openFilter opens an existing classic filter file, whose parameters and built-in hash are restored from its header.
+// Files of a custom hash are opened without a hash only if lookup is false, since the hash is unknown here.
+func openFilter(path string, hashKey string, lookup bool, opts ...disk_bloom.Option) (*disk_bloom.DiskFilter, error) {
+	header, err := disk_bloom.Inspect(path)
+	if err != nil {
+		return nil, err
+	}
+	if header.Bits == 0 {
+		return nil, fmt.Errorf("%v: the parameters are not recorded in the file", path)
+	}
+	key, err := hex.DecodeString(hashKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hash key: %w", err)
+	}
+	size, err := readMetadataSize(path)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, disk_bloom.WithMetadata(size, nil, nil))
+	if header.HashKind != disk_bloom.HashKindCustom {
+		opts = append(opts, disk_bloom.WithHashKind(header.HashKind, key))
+	} else if lookup {
+		return nil, fmt.Errorf("%v: the file is hashed by a custom hash of the application", path)
+	} else {
+		opts = append(opts, disk_bloom.WithoutHash())
+	}
+	return disk_bloom.Open(path, opts...)
+}
+
+func readMetadataSize(path string) (uint16, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var b [disk_bloom.LenOfMetadataSize]byte
+	if _, err = io.ReadFull(f, b[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(b[:]), nil
+}
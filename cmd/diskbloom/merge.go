@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	disk_bloom "github.com/mzz2017/disk-bloom"
+)
+
+func runMerge(w io.Writer, args []string) error {
+	fs := newFlagSet("merge")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return fmt.Errorf("merge: usage: merge <dst> <src>...")
+	}
+	if err := disk_bloom.MergeFiles(fs.Arg(0), fs.Args()[1:]...); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "merged %v files into %v\n", fs.NArg()-1, fs.Arg(0))
+	return nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	dst, src := createFilter(t, "dst"), createFilter(t, "src")
+	if err := runAdd(&strings.Builder{}, []string{dst, "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := runAdd(&strings.Builder{}, []string{src, "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := runMerge(&strings.Builder{}, []string{dst, src}); err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	if err := runCheck(&sb, []string{dst, "a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "a: true\nb: true\n"; sb.String() != want {
+		t.Fatalf("Should be %q, got %q", want, sb.String())
+	}
+}
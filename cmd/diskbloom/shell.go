@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"time"
+
+	disk_bloom "github.com/mzz2017/disk-bloom"
+)
+
+const shellHelp = `Commands:
+  add <key>...      add keys to the filter
+  check <key>...    report whether keys are in the filter
+  expire <key>...   report when keys expire from a ttl filter
+  stats             print the state of the filter
+  help              print this help
+  quit              close the filter and exit
+`
+
+// shellFilter is a filter opened by the shell, a classic or a ttl filter.
+type shellFilter interface {
+	ExistErr(b []byte) (bool, error)
+	ExistOrAddErr(b []byte) (bool, error)
+	Describe() disk_bloom.FilterDescription
+	Close() error
+}
+
+func runShell(r io.Reader, w io.Writer, args []string) error {
+	fs := newFlagSet("shell")
+	hashKey := hashKeyFlag(fs)
+	readOnly := fs.Bool("read-only", false, "open the file read-only")
+	lock := fs.Bool("lock", runtime.GOOS == "linux", "lock the file while it is open, shared if read-only")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("shell: usage: shell [-read-only] [-lock=false] <file>")
+	}
+	f, err := openShellFilter(fs.Arg(0), *hashKey, *readOnly, *lock)
+	if err != nil {
+		return err
+	}
+	d := f.Describe()
+	fmt.Fprintf(w, "%v: %v filter, read only: %v. Type help for the commands.\n", fs.Arg(0), d.Variant, d.ReadOnly)
+	scanner := bufio.NewScanner(r)
+	for fmt.Fprint(w, "diskbloom> "); scanner.Scan(); fmt.Fprint(w, "diskbloom> ") {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "quit" || fields[0] == "exit" {
+			break
+		}
+		if err = runShellCommand(w, f, fields[0], fields[1:]); err != nil {
+			fmt.Fprintf(w, "error: %v\n", err)
+		}
+	}
+	fmt.Fprintln(w)
+	if err = scanner.Err(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// openShellFilter opens an existing classic or ttl filter file, whose parameters are restored from its header.
+func openShellFilter(path string, hashKey string, readOnly bool, lock bool) (shellFilter, error) {
+	header, err := disk_bloom.Inspect(path)
+	if err != nil {
+		return nil, err
+	}
+	if !header.TTL() {
+		var opts []disk_bloom.Option
+		if readOnly {
+			opts = append(opts, disk_bloom.WithReadOnly())
+		}
+		if lock {
+			opts = append(opts, disk_bloom.WithFileLock())
+		}
+		return openFilter(path, hashKey, true, opts...)
+	}
+	if header.HashKind == disk_bloom.HashKindCustom {
+		return nil, fmt.Errorf("%v: the file is hashed by a custom hash of the application", path)
+	}
+	key, err := hex.DecodeString(hashKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hash key: %w", err)
+	}
+	size, err := readMetadataSize(path)
+	if err != nil {
+		return nil, err
+	}
+	return disk_bloom.NewTTLFilter(path, header.BucketSpan*time.Duration(header.Buckets-1), int(header.Buckets), disk_bloom.Controller{
+		Fsync:        disk_bloom.FsyncModeEverySec,
+		MetadataSize: size,
+		GetParam: func([]byte) (disk_bloom.FilterParam, []byte) {
+			return disk_bloom.FilterParam{HashKind: header.HashKind, HashKey: key}, nil
+		},
+		ReadOnly: readOnly,
+		FileLock: lock,
+	})
+}
+
+func runShellCommand(w io.Writer, f shellFilter, command string, keys []string) error {
+	switch command {
+	case "add":
+		for _, key := range keys {
+			exist, err := f.ExistOrAddErr([]byte(key))
+			if err != nil {
+				return err
+			}
+			if exist {
+				fmt.Fprintf(w, "%v: exists\n", key)
+			} else {
+				fmt.Fprintf(w, "%v: added\n", key)
+			}
+		}
+	case "check":
+		for _, key := range keys {
+			exist, err := f.ExistErr([]byte(key))
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%v: %v\n", key, exist)
+		}
+	case "expire":
+		for _, key := range keys {
+			if err := writeExpiry(w, f, key); err != nil {
+				return err
+			}
+		}
+	case "stats":
+		d := f.Describe()
+		fmt.Fprintf(w, "variant:         %v\n", d.Variant)
+		fmt.Fprintf(w, "health:          %v\n", d.Health)
+		fmt.Fprintf(w, "read only:       %v\n", d.ReadOnly)
+		if classic, ok := f.(*disk_bloom.DiskFilter); ok {
+			count := classic.EstimateCount()
+			fmt.Fprintf(w, "fill ratio:      %.6f\n", classic.FillRatio())
+			fmt.Fprintf(w, "estimated count: %.0f\n", count)
+			fmt.Fprintf(w, "estimated FPR:   %.3g\n", disk_bloom.EstimateFPR(d.Slots, d.Bits, uint64(count)))
+		}
+		if ttl, ok := f.(*disk_bloom.DiskTTLFilter); ok {
+			fmt.Fprintf(w, "ttl:             %v\n", ttl.TTL())
+		}
+		if !d.LastAdd.IsZero() {
+			fmt.Fprintf(w, "last add:        %v\n", d.LastAdd.Format(time.RFC3339))
+		}
+	case "help":
+		fmt.Fprint(w, shellHelp)
+	default:
+		return fmt.Errorf("unknown command %q, type help for the commands", command)
+	}
+	return nil
+}
+
+// writeExpiry reports when a key expires, which is never for the filters other than ttl filters.
+func writeExpiry(w io.Writer, f shellFilter, key string) error {
+	ttl, ok := f.(*disk_bloom.DiskTTLFilter)
+	if !ok {
+		exist, err := f.ExistErr([]byte(key))
+		if err != nil {
+			return err
+		}
+		if exist {
+			fmt.Fprintf(w, "%v: never expires\n", key)
+		} else {
+			fmt.Fprintf(w, "%v: not in filter\n", key)
+		}
+		return nil
+	}
+	expires, exist, err := ttl.ExpiresAt([]byte(key))
+	if err != nil {
+		return err
+	}
+	if exist {
+		fmt.Fprintf(w, "%v: expires by %v\n", key, expires.UTC().Format(time.RFC3339))
+	} else {
+		fmt.Fprintf(w, "%v: not in filter\n", key)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	disk_bloom "github.com/mzz2017/disk-bloom"
+)
+
+func TestShell(t *testing.T) {
+	path := createFilter(t, "filter")
+	var sb strings.Builder
+	in := "add a b a\ncheck a c\nexpire a c\nstats\nfrobnicate\nquit\ncheck a\n"
+	if err := runShell(strings.NewReader(in), &sb, []string{path}); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"classic filter, read only: false",
+		"a: added\nb: added\na: exists\n",
+		"a: true\nc: false\n",
+		"a: never expires\nc: not in filter\n",
+		"estimated count: 2\n",
+		`error: unknown command "frobnicate"`,
+	} {
+		if !strings.Contains(sb.String(), line) {
+			t.Fatalf("Should contain %q, got:\n%v", line, sb.String())
+		}
+	}
+	if strings.Count(sb.String(), "a: true") != 1 {
+		t.Fatalf("Should stop at quit, got:\n%v", sb.String())
+	}
+	sb.Reset()
+	if err := runShell(strings.NewReader("add d\ncheck a\n"), &sb, []string{"-read-only", path}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sb.String(), "error: ") || !strings.Contains(sb.String(), "a: true\n") {
+		t.Fatalf("Should reject the adds of a read-only filter, got:\n%v", sb.String())
+	}
+}
+
+func TestShell_TTL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ttl")
+	f, err := disk_bloom.NewTTLFilter(path, time.Hour, 5, disk_bloom.Controller{
+		GetParam: func([]byte) (disk_bloom.FilterParam, []byte) {
+			return disk_bloom.FilterParam{Slots: 7, Bits: 1e4, HashKind: disk_bloom.HashKindXXHash64}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.ExistOrAdd([]byte("a"))
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	if err = runShell(strings.NewReader("expire a b\nstats\n"), &sb, []string{path}); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"ttl filter", "a: expires by ", "b: not in filter\n", "ttl:             1h0m0s\n"} {
+		if !strings.Contains(sb.String(), line) {
+			t.Fatalf("Should contain %q, got:\n%v", line, sb.String())
+		}
+	}
+}
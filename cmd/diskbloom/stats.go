@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	disk_bloom "github.com/mzz2017/disk-bloom"
+)
+
+func runStats(w io.Writer, args []string) error {
+	fs := newFlagSet("stats")
+	format := fs.String("format", "text", "output format: text or openmetrics")
+	slots := fs.Uint("slots", 0, "number of hashes per entry of the files not recording it in their header")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("stats: no file given")
+	}
+	if *format != "text" && *format != "openmetrics" {
+		return fmt.Errorf("stats: unknown format %q", *format)
+	}
+	var all []disk_bloom.FileStats
+	for _, path := range fs.Args() {
+		stats, err := disk_bloom.ScanFile(path)
+		if stats.Slots == 0 {
+			stats.Slots = uint8(*slots)
+		}
+		if err != nil {
+			if *format == "text" {
+				return err
+			}
+			// reported as unhealthy
+			stats = disk_bloom.FileStats{}
+		}
+		all = append(all, stats)
+	}
+	if *format == "openmetrics" {
+		writeOpenMetrics(w, fs.Args(), all)
+		return nil
+	}
+	for i, path := range fs.Args() {
+		writeText(w, path, all[i])
+	}
+	return nil
+}
+
+func writeText(w io.Writer, path string, stats disk_bloom.FileStats) {
+	fmt.Fprintf(w, "%v:\n", path)
+	fmt.Fprintf(w, "  size:            %v bytes\n", stats.Size)
+	fmt.Fprintf(w, "  slots:           %v\n", stats.Slots)
+	fmt.Fprintf(w, "  fill ratio:      %.6f\n", stats.FillRatio())
+	fmt.Fprintf(w, "  estimated count: %.0f\n", stats.EstimateCount())
+	fmt.Fprintf(w, "  estimated FPR:   %.3g\n", stats.EstimateFPR())
+	fmt.Fprintf(w, "  healthy:         %v\n", stats.Healthy)
+}
+
+var metricFamilies = []struct {
+	name string
+	help string
+	get  func(stats disk_bloom.FileStats) float64
+}{
+	{"diskbloom_fill_ratio", "Fraction of bits set in the bloom filter.", disk_bloom.FileStats.FillRatio},
+	{"diskbloom_estimated_count", "Number of entries estimated from the fill ratio.", disk_bloom.FileStats.EstimateCount},
+	{"diskbloom_estimated_fpr", "False positive rate estimated from the fill ratio.", disk_bloom.FileStats.EstimateFPR},
+	{"diskbloom_size_bytes", "Size of the bloom filter in bytes.", func(stats disk_bloom.FileStats) float64 {
+		return float64(stats.Size)
+	}},
+	{"diskbloom_healthy", "Whether the file could be read and is as large as its layout requires.", func(stats disk_bloom.FileStats) float64 {
+		if stats.Healthy {
+			return 1
+		}
+		return 0
+	}},
+}
+
+// writeOpenMetrics writes the stats in the OpenMetrics text format, which node_exporter's textfile collector accepts.
+func writeOpenMetrics(w io.Writer, paths []string, all []disk_bloom.FileStats) {
+	for _, family := range metricFamilies {
+		fmt.Fprintf(w, "# TYPE %v gauge\n", family.name)
+		fmt.Fprintf(w, "# HELP %v %v\n", family.name, family.help)
+		for i, path := range paths {
+			fmt.Fprintf(w, "%v{path=\"%v\"} %v\n", family.name, labelEscaper.Replace(path), formatFloat(family.get(all[i])))
+		}
+	}
+	fmt.Fprintln(w, "# EOF")
+}
+
+// labelEscaper escapes a label value of OpenMetrics, where only the backslash, the double quote and the line feed are escaped.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	disk_bloom "github.com/mzz2017/disk-bloom"
+)
+
+func TestWriteOpenMetrics(t *testing.T) {
+	var sb strings.Builder
+	writeOpenMetrics(&sb, []string{"a", "b", "c\\\"é\n\t"}, []disk_bloom.FileStats{
+		{Size: 8, SetBits: 16, Slots: 2, Healthy: true},
+		{},
+		{},
+	})
+	out := sb.String()
+	for _, line := range []string{
+		"# TYPE diskbloom_fill_ratio gauge\n",
+		`diskbloom_fill_ratio{path="a"} 0.25` + "\n",
+		`diskbloom_estimated_fpr{path="a"} 0.0625` + "\n",
+		`diskbloom_estimated_count{path="b"} NaN` + "\n",
+		`diskbloom_healthy{path="b"} 0` + "\n",
+		// only the backslash, the double quote and the line feed are escaped
+		"diskbloom_healthy{path=\"c\\\\\\\"é\\n\t\"} 0\n",
+	} {
+		if !strings.Contains(out, line) {
+			t.Fatalf("Should contain %q, got:\n%v", line, out)
+		}
+	}
+	if !strings.HasSuffix(out, "# EOF\n") {
+		t.Fatal("Should end with # EOF")
+	}
+	if formatFloat(math.Inf(1)) != "+Inf" {
+		t.Fatal("Unexpected formatting of +Inf")
+	}
+}
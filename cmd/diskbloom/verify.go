@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	disk_bloom "github.com/mzz2017/disk-bloom"
+)
+
+func runVerify(w io.Writer, args []string) error {
+	fs := newFlagSet("verify")
+	hashKey := hashKeyFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("verify: no file given")
+	}
+	for _, path := range fs.Args() {
+		// the checksums would be computed afresh from the file if they were missing
+		if _, err := os.Stat(disk_bloom.ChecksumFilename(path)); err != nil {
+			return fmt.Errorf("%v: no checksums: %w", path, err)
+		}
+		f, err := openFilter(path, *hashKey, false, disk_bloom.WithChecksums(false))
+		if err != nil {
+			return err
+		}
+		err = f.Verify()
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+		fmt.Fprintf(w, "%v: ok\n", path)
+	}
+	return nil
+}
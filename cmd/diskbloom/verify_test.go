@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	disk_bloom "github.com/mzz2017/disk-bloom"
+)
+
+func TestVerify(t *testing.T) {
+	path := createFilter(t, "filter")
+	if err := runVerify(&strings.Builder{}, []string{path}); err == nil {
+		t.Fatal("Should fail without checksums")
+	}
+	path = createFilter(t, "filter", disk_bloom.WithChecksums(false))
+	var sb strings.Builder
+	if err := runVerify(&sb, []string{path}); err != nil {
+		t.Fatal(err)
+	}
+	if want := path + ": ok\n"; sb.String() != want {
+		t.Fatalf("Should be %q, got %q", want, sb.String())
+	}
+
+	// corrupt the last byte of the bloom filter
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.WriteAt([]byte{0xff}, info.Size()-1); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+	if err = runVerify(&strings.Builder{}, []string{path}); !errors.Is(err, disk_bloom.ChecksumErr) {
+		t.Fatalf("Should fail with ChecksumErr, got %v", err)
+	}
+}
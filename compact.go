@@ -0,0 +1,73 @@
+package disk_bloom
+
+import "fmt"
+
+// compactChunk is the number of pages scanned under a single lock acquisition by Compact.
+const compactChunk = 256
+
+// Compact returns the disk space of the pages of the bloom filter that are all zeros to the filesystem,
+// and returns the bytes reclaimed. New files are sparse, so the untouched pages take no space until written,
+// but copies such as those by ReadFrom, or by Clone without a reflink, take the full size, and so do the files
+// on filesystems without holes. The pages are read as zeros afterwards, and take space again once written.
+//
+// It is supported on Linux by punching holes, and the adds wait for a chunk of pages at a time.
+// Encrypted and shrunk filters, filters served by Mmap or opened with Preallocate, whose pages are kept allocated,
+// and filters opened by OpenFS are not supported.
+func (f *DiskFilter) Compact() (reclaimed int64, err error) {
+	file := f.file.osFile()
+	if f.header.Encrypted() || f.header.Shrunk() || f.mapped != nil || f.controller.Preallocate || file == nil {
+		return 0, fmt.Errorf("%w: compacting encrypted, shrunk, mapped, preallocated or io/fs filters", UnsupportedErr)
+	}
+	if !f.acquire() {
+		return 0, ClosedErr
+	}
+	defer f.release()
+	before, err := allocatedSize(file)
+	if err != nil {
+		return 0, err
+	}
+	start := (f.bloomStart + pageSize - 1) / pageSize
+	end := (f.bloomStart + f.header.bloomSize(f.param.Bits)) / pageSize
+	buf := make([]byte, compactChunk*pageSize)
+	for page := start; page < end; page += compactChunk {
+		n := end - page
+		if n > compactChunk {
+			n = compactChunk
+		}
+		if err = f.compactPages(buf[:n*pageSize], page); err != nil {
+			return 0, err
+		}
+	}
+	after, err := allocatedSize(file)
+	if err != nil {
+		return 0, err
+	}
+	return before - after, nil
+}
+
+// compactPages punches the holes of the pages all zeros, from the page first and as many as buf holds.
+func (f *DiskFilter) compactPages(buf []byte, first int64) error {
+	f.lock()
+	defer f.file.mu.Unlock()
+	if _, err := (retryStorage{f.file.f}).ReadAt(buf, first*pageSize); err != nil {
+		return err
+	}
+	// the run of pages all zeros
+	var from, n int64
+	for i := int64(0); i <= int64(len(buf))/pageSize; i++ {
+		if i < int64(len(buf))/pageSize && isZero(buf[i*pageSize:(i+1)*pageSize]) {
+			if n == 0 {
+				from = first + i
+			}
+			n++
+			continue
+		}
+		if n > 0 {
+			if err := punchHole(f.file.osFile(), from*pageSize, n*pageSize); err != nil {
+				return err
+			}
+			n = 0
+		}
+	}
+	return nil
+}
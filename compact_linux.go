@@ -0,0 +1,21 @@
+package disk_bloom
+
+import (
+	"os"
+	"syscall"
+)
+
+// punchHole deallocates the range of the file, which is read as zeros afterwards.
+func punchHole(f *os.File, offset int64, length int64) error {
+	const punchHole, keepSize = 0x2, 0x1 // FALLOC_FL_PUNCH_HOLE, FALLOC_FL_KEEP_SIZE
+	return syscall.Fallocate(int(f.Fd()), punchHole|keepSize, offset, length)
+}
+
+// allocatedSize returns the bytes of the disk space allocated to the file.
+func allocatedSize(f *os.File) (int64, error) {
+	var stat syscall.Stat_t
+	if err := syscall.Fstat(int(f.Fd()), &stat); err != nil {
+		return 0, err
+	}
+	return stat.Blocks * 512, nil
+}
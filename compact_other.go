@@ -0,0 +1,14 @@
+//go:build !linux
+
+package disk_bloom
+
+import "os"
+
+// punchHole is only supported on Linux.
+func punchHole(f *os.File, offset int64, length int64) error {
+	return UnsupportedErr
+}
+
+func allocatedSize(f *os.File) (int64, error) {
+	return 0, UnsupportedErr
+}
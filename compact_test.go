@@ -0,0 +1,69 @@
+package disk_bloom
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestDiskFilter_Compact(t *testing.T) {
+	if c, err := Capabilities("."); err != nil || !c.PunchHole {
+		t.Skip("punching holes is not supported")
+	}
+	// larger than newTestFilter, so that most of the pages are untouched
+	bf, err := New("testfile", Controller{Fsync: FsyncModeNo, GetParam: func(metadata []byte) (FilterParam, []byte) {
+		return FilterParam{Slots: 4, Bits: 1 << 23, Hash: doubleFNV}, nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("testfile")
+	defer bf.Close()
+	for i := 0; i < 10; i++ {
+		bf.ExistOrAdd([]byte(fmt.Sprint(i)))
+	}
+	// materialize the untouched pages, like a copy of the file
+	size := bf.header.bloomSize(bf.param.Bits)
+	buf := make([]byte, size)
+	if _, err := bf.file.f.ReadAt(buf, bf.bloomStart); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bf.file.f.WriteAt(buf, bf.bloomStart); err != nil {
+		t.Fatal(err)
+	}
+	if err := bf.file.f.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	reclaimed, err := bf.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// at most 40 pages are touched by the entries
+	if reclaimed < size-40*pageSize {
+		t.Fatalf("Should reclaim about %v bytes, got %v", size, reclaimed)
+	}
+	for i := 0; i < 10; i++ {
+		if !bf.Exist([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%v should exist in filter", i)
+		}
+	}
+	for i := 10; i < 20; i++ {
+		if bf.ExistOrAdd([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%v should be added", i)
+		}
+		if !bf.Exist([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%v should exist in filter", i)
+		}
+	}
+	if reclaimed, err = bf.Compact(); err != nil || reclaimed != 0 {
+		t.Fatalf("Should reclaim nothing again, got %v %v", reclaimed, err)
+	}
+}
+
+func TestDiskFilter_CompactEncrypted(t *testing.T) {
+	bf := newTestFilter(t, Controller{EncryptionKey: make([]byte, 32)})
+	if _, err := bf.Compact(); !errors.Is(err, UnsupportedErr) {
+		t.Fatalf("Should not compact an encrypted filter, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+var PrepareErr = fmt.Errorf("failed to prepare the next filter")
+
+// Consolidate rebuilds the filters of the filterGroup into a single filter sized for targetN entries
+// with the false positive rate targetP, for groups that have accreted many small filters.
+//
+// A bloom filter can not enumerate its entries, so source should feed the keys ever added to the filterGroup
+// by invoking add for each of them, e.g. from a log of the keys or the original dataset.
+// Keys not in the filterGroup are skipped.
+//
+// The filterGroup keeps serving during the consolidation: it first rotates to a new active filter,
+// so that the filters to consolidate are not modified, and entries added meanwhile go to the new ones.
+// Once done, the consolidated filter replaces them and becomes the first file of the group, which is full,
+// since its expected number of entries is the number of entries in it, and sealed, see DiskFilter.Seal.
+// Exist never misses an entry during the replacement, see FilterGroup.version.
+func (g *FilterGroup) Consolidate(targetN uint64, targetP float64, source func(add func(b []byte)) error) error {
+	old, err := g.rotate()
+	if err != nil {
+		return err
+	}
+	if len(old) == 0 {
+		// all evicted by the limits of the group
+		return nil
+	}
+	obj, err := g.consolidate(old, targetN, targetP, source)
+	if err != nil {
+		return err
+	}
+
+	// the preparation holds the name of the next filter, so wait for it
+	g.lockIdle()
+	defer g.mu.Unlock()
+	// the filters before the rotation may be evicted meanwhile, whose names may be taken by others
+	replaced := make(map[*filterObj]bool, len(old))
+	for _, f := range old {
+		replaced[f] = true
+	}
+	var rest, kept []*filterObj
+	for _, f := range g.load() {
+		if replaced[f] {
+			kept = append(kept, f)
+		} else {
+			rest = append(rest, f)
+		}
+	}
+	filters := append([]*filterObj{obj}, rest...)
+	// swap before closing the replaced filters, and let the lookups on them see the new version and look up again
+	g.filters.Store(filters)
+	atomic.AddUint64(&g.version, 1)
+	for _, f := range kept {
+		_ = f.filter.Close()
+		_ = os.Remove(f.filename)
+	}
+	first := g.positionFilename(0)
+	if g.naming == NamingSchemeTimestamp {
+		// named after the oldest filter replaced, to sort before the rest
+		first = old[0].filename
+	}
+	if err = obj.rename(first); err != nil {
+		return err
+	}
+	return g.renumberLocked(filters, 1)
+}
+
+// consolidate builds a filter of the entries in filters, and seals it.
+func (g *FilterGroup) consolidate(filters []*filterObj, targetN uint64, targetP float64, source func(add func(b []byte)) error) (*filterObj, error) {
+	slots, bits := OptimalParam(targetN, targetP)
+	param := FilterParam{Slots: slots, Bits: bits, Hash: g.param.Hash}
+	obj := &filterObj{filename: g.filename("consolidating")}
+	_ = os.Remove(obj.filename)
+	if err := g.createFilter(obj, param, Metadata{Expected: targetN, Slots: slots, Bits: bits}); err != nil {
+		return nil, err
+	}
+	filter := obj.filter
+	err := source(func(b []byte) {
+		h := g.Hash(b)
+		for _, f := range filters {
+			if f.filter.ExistHashed(h) {
+				if !filter.ExistOrAddHashed(h) {
+					atomic.AddUint64(&obj.added, 1)
+				}
+				return
+			}
+		}
+	})
+	if err != nil {
+		_ = filter.Close()
+		_ = os.Remove(obj.filename)
+		return nil, err
+	}
+	if err = filter.Close(); err != nil {
+		_ = os.Remove(obj.filename)
+		return nil, err
+	}
+	// reopened with the expected number of entries updated to the number of entries in it, so it is full
+	added := atomic.LoadUint64(&obj.added)
+	if err = g.createFilter(obj, param, Metadata{Added: added, Expected: added, Slots: slots, Bits: bits}); err != nil {
+		_ = os.Remove(obj.filename)
+		return nil, err
+	}
+	if err = obj.filter.Seal(); err != nil {
+		_ = obj.filter.Close()
+		_ = os.Remove(obj.filename)
+		return nil, err
+	}
+	return obj, nil
+}
+
+// rename renames the file of the filter.
+func (o *filterObj) rename(filename string) error {
+	if o.filename == filename {
+		return nil
+	}
+	if err := os.Rename(o.filename, filename); err != nil {
+		return err
+	}
+	o.filename = filename
+	return nil
+}
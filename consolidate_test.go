@@ -0,0 +1,132 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFilterGroup_Consolidate(t *testing.T) {
+	const n = 100
+	os.Mkdir("testfile", os.ModePerm)
+	defer os.RemoveAll("testfile")
+	bf, err := NewGroup("testfile/*", FsyncModeNo, n, 1e-6, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys [][]byte
+	for i := 0; len(bf.load()) < 5; i++ {
+		key := []byte(fmt.Sprint(i))
+		keys = append(keys, key)
+		bf.ExistOrAdd(key)
+		bf.tasks.wait()
+	}
+	err = bf.Consolidate(uint64(len(keys)), 1e-6, func(add func(b []byte)) error {
+		for _, key := range keys {
+			add(key)
+		}
+		add([]byte("never added"))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filters := len(bf.load()); filters != 2 {
+		t.Fatalf("Should be the consolidated filter and the active one, got %v filters", filters)
+	}
+	if count := bf.Count(); count != uint64(len(keys)) {
+		t.Fatalf("Count should be %v, got %v", len(keys), count)
+	}
+	consolidated := bf.load()[0]
+	if !consolidated.filter.header.Sealed() {
+		t.Fatal("The consolidated filter should be sealed")
+	}
+	if m, err := readGroupMetadata(consolidated.filename); err != nil || m.Added != uint64(len(keys)) || m.Expected != m.Added {
+		t.Fatalf("The consolidated filter should be full of %v entries, got %+v, %v", len(keys), m, err)
+	}
+	if bf.ExistOrAdd([]byte("new")) {
+		t.Fatal("Should be added")
+	}
+	for _, key := range keys {
+		if !bf.Exist(key) {
+			t.Fatalf("%s should exist in filter", key)
+		}
+	}
+	if bf.Exist([]byte("never added")) {
+		t.Fatal("Should missing in filter but got true")
+	}
+	bf.Close()
+
+	// reopen
+	bf, err = NewGroup("testfile/*", FsyncModeNo, n, 1e-6, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bf.Close()
+	if filters := len(bf.load()); filters != 2 {
+		t.Fatalf("Should reopen 2 filters, got %v", filters)
+	}
+	for _, key := range append(keys, []byte("new")) {
+		if !bf.Exist(key) {
+			t.Fatalf("%s should exist in filter", key)
+		}
+	}
+}
+
+func TestFilterGroup_ConsolidateConcurrentExist(t *testing.T) {
+	const n = 100
+	os.Mkdir("testfile", os.ModePerm)
+	defer os.RemoveAll("testfile")
+	bf, err := NewGroup("testfile/*", FsyncModeNo, n, 1e-6, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bf.Close()
+	var keys [][]byte
+	for i := 0; len(bf.load()) < 5; i++ {
+		key := []byte(fmt.Sprint(i))
+		keys = append(keys, key)
+		bf.ExistOrAdd(key)
+		bf.tasks.wait()
+	}
+	var missed int32
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				for _, key := range keys {
+					if !bf.Exist(key) {
+						atomic.AddInt32(&missed, 1)
+					}
+					if _, ok := bf.FirstSeenWindow(key); !ok {
+						atomic.AddInt32(&missed, 1)
+					}
+				}
+			}
+		}()
+	}
+	err = bf.Consolidate(uint64(len(keys)), 1e-6, func(add func(b []byte)) error {
+		for _, key := range keys {
+			add(key)
+		}
+		return nil
+	})
+	close(done)
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missed > 0 {
+		t.Fatalf("%v lookups missed the entries during the consolidation", missed)
+	}
+}
@@ -0,0 +1,59 @@
+package disk_bloom
+
+import "context"
+
+// withContext runs fn, and returns ctx.Err() once ctx is done before fn returns.
+// fn is not interrupted, since a read or write in a syscall can not be, and it completes in the background.
+func withContext(ctx context.Context, fn func() (bool, error)) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if ctx.Done() == nil {
+		// never canceled
+		return fn()
+	}
+	type result struct {
+		ok  bool
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		ok, err := fn()
+		done <- result{ok, err}
+	}()
+	select {
+	case r := <-done:
+		return r.ok, r.err
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// ExistCtx is like Exist, but returns ctx.Err() once ctx is done, e.g. a stalled disk read exceeds the deadline of a request.
+func (f *DiskFilter) ExistCtx(ctx context.Context, b []byte) (bool, error) {
+	return withContext(ctx, func() (bool, error) {
+		select {
+		case <-f.closed:
+			return false, ClosedErr
+		default:
+		}
+		return f.Exist(b), nil
+	})
+}
+
+// ExistOrAddCtx is like ExistOrAddErr, but returns ctx.Err() once ctx is done.
+// The entry may still be added after ctx is done.
+func (f *DiskFilter) ExistOrAddCtx(ctx context.Context, b []byte) (bool, error) {
+	return withContext(ctx, func() (bool, error) {
+		return f.ExistOrAddErr(b)
+	})
+}
+
+// CloseCtx is like Close, but returns ctx.Err() once ctx is done before the operations in flight finish.
+// The filter is closed once they finish.
+func (f *DiskFilter) CloseCtx(ctx context.Context) error {
+	_, err := withContext(ctx, func() (bool, error) {
+		return false, f.Close()
+	})
+	return err
+}
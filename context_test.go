@@ -0,0 +1,43 @@
+package disk_bloom
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDiskFilter_Ctx(t *testing.T) {
+	injector := NewFaultInjector(1)
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo, FaultInjector: injector})
+	ctx := context.Background()
+	if exist, err := bf.ExistOrAddCtx(ctx, []byte("a")); err != nil || exist {
+		t.Fatalf("Should add a, got %v %v", exist, err)
+	}
+	if exist, err := bf.ExistCtx(ctx, []byte("a")); err != nil || !exist {
+		t.Fatalf("Should exist, got %v %v", exist, err)
+	}
+
+	// a stalled disk
+	injector.Set(Faults{Latency: 50 * time.Millisecond})
+	timeout, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := bf.ExistCtx(timeout, []byte("a")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Should exceed the deadline, got %v", err)
+	}
+	if _, err := bf.ExistOrAddCtx(timeout, []byte("b")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Should exceed the deadline, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+		t.Fatalf("Should return at the deadline, took %v", elapsed)
+	}
+
+	injector.Set(Faults{})
+	if err := bf.CloseCtx(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bf.ExistCtx(ctx, []byte("a")); !errors.Is(err, ClosedErr) {
+		t.Fatalf("got %v, want ClosedErr", err)
+	}
+}
@@ -0,0 +1,73 @@
+package disk_bloom
+
+import (
+	"io"
+	"math"
+	"math/bits"
+	"sync/atomic"
+)
+
+// countSetBits counts the bits set by scanning the bloom filter once, unless they are known, e.g. from the header of adaptive filters.
+// The adds afterwards keep them up to date.
+func (f *DiskFilter) countSetBits() error {
+	if atomic.LoadInt32(&f.counted) == 1 {
+		return nil
+	}
+	if !f.acquire() {
+		return ClosedErr
+	}
+	defer f.release()
+	f.file.mu.Lock()
+	defer f.file.mu.Unlock()
+	if atomic.LoadInt32(&f.counted) == 1 {
+		return nil
+	}
+	buf := make([]byte, 1<<16)
+	r := io.NewSectionReader(f.file.rw, f.bloomStart, f.header.bloomSize(f.param.Bits))
+	var set uint64
+	for {
+		n, err := r.Read(buf)
+		for _, v := range buf[:n] {
+			set += uint64(bits.OnesCount8(v))
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+	// the bits buffered in memory
+	for pos, val := range f.pending {
+		var b [1]byte
+		if _, err := f.file.rw.ReadAt(b[:], pos); err != nil {
+			return err
+		}
+		set += uint64(bits.OnesCount8(b[0]|val) - bits.OnesCount8(b[0]))
+	}
+	atomic.StoreUint64(&f.setBits, set)
+	atomic.StoreInt32(&f.counted, 1)
+	return nil
+}
+
+// FillRatio returns the ratio of the bits set in the bloom filter, and 0 for filters other than classic ones.
+// The bits set are tracked from the header of adaptive filters, and counted by scanning the bloom filter
+// on the first call for the others.
+func (f *DiskFilter) FillRatio() float64 {
+	if f.header.variant() != variantClassic || f.countSetBits() != nil {
+		return 0
+	}
+	return float64(atomic.LoadUint64(&f.setBits)) / float64(f.param.Bits)
+}
+
+// EstimateCount estimates the number of entries added from the fill ratio: -(m/k)·ln(1-X/m).
+// It returns +Inf if all bits are set, and 0 for filters other than classic ones.
+func (f *DiskFilter) EstimateCount() float64 {
+	return estimateCount(f.FillRatio(), f.param.Slots, f.param.Bits)
+}
+
+func estimateCount(ratio float64, slots uint8, bits uint64) float64 {
+	if ratio >= 1 {
+		return math.Inf(1)
+	}
+	return -float64(bits) / float64(slots) * math.Log(1-ratio)
+}
@@ -0,0 +1,38 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestDiskFilter_EstimateCount(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo})
+	if ratio, count := bf.FillRatio(), bf.EstimateCount(); ratio != 0 || count != 0 {
+		t.Fatalf("Should be empty, got %v %v", ratio, count)
+	}
+	const n = 5000
+	for i := 0; i < n; i++ {
+		bf.ExistOrAdd([]byte(fmt.Sprint(i)))
+	}
+	if count := bf.EstimateCount(); math.Abs(count-n) > n*0.05 {
+		t.Fatalf("count should be about %v, got %v", n, count)
+	}
+	ratio := bf.FillRatio()
+	if err := bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the bits set are counted by scanning the reopened file
+	bf = newTestFilter(t, Controller{Fsync: FsyncModeNo})
+	if bf.counted != 0 {
+		t.Fatal("the bits set should be unknown before scanning")
+	}
+	if reopened := bf.FillRatio(); reopened != ratio {
+		t.Fatalf("fill ratio should be %v after reopening, got %v", ratio, reopened)
+	}
+	bf.ExistOrAdd([]byte("new"))
+	if bf.FillRatio() <= ratio {
+		t.Fatal("fill ratio should grow after adding")
+	}
+}
@@ -0,0 +1,187 @@
+package disk_bloom
+
+import (
+	"fmt"
+)
+
+// DefaultCounterWidth is the width of counters of new counting filters if Controller.CounterWidth is not given.
+const DefaultCounterWidth = 4
+
+var (
+	CounterWidthErr = fmt.Errorf("invalid counter width")
+	NotInFilterErr  = fmt.Errorf("entry is not in the filter")
+)
+
+// Counting returns whether each bit of the bloom filter is replaced by a counter.
+func (h Header) Counting() bool {
+	return h.Flags&FlagCounting != 0
+}
+
+// checkCounterWidth validates the counter width of a counting filter.
+func checkCounterWidth(header Header, controller Controller) error {
+	if !validCounterWidth(header.CounterWidth) {
+		return fmt.Errorf("%w: %v in the header", CounterWidthErr, header.CounterWidth)
+	}
+	if controller.CounterWidth != 0 && controller.CounterWidth != header.CounterWidth {
+		return fmt.Errorf("%w: the counter width written in the given file is %v, which is different from %v", CounterWidthErr, header.CounterWidth, controller.CounterWidth)
+	}
+	return nil
+}
+
+func validCounterWidth(width uint8) bool {
+	return width == 2 || width == 4 || width == 8
+}
+
+// DiskCountingFilter is a disk-based counting Bloom filter, which supports Delete.
+// Each bit of the classic Bloom filter is replaced by a counter of Controller.CounterWidth bits,
+// with the same metadata and header layout.
+// A counter saturated at its maximum is never decremented again, so entries sharing it can not be deleted completely.
+type DiskCountingFilter struct {
+	disk *DiskFilter
+}
+
+// NewCounting creates a counting Bloom filter. The controller is the same as New,
+// except that DiskFullPolicyBuffer is not supported, since the counters can not be merged.
+func NewCounting(filename string, controller Controller) (*DiskCountingFilter, error) {
+	if controller.CounterWidth != 0 && !validCounterWidth(controller.CounterWidth) {
+		return nil, fmt.Errorf("%w: %v, which should be 2, 4 or 8", CounterWidthErr, controller.CounterWidth)
+	}
+	if controller.DiskFullPolicy == DiskFullPolicyBuffer {
+		return nil, fmt.Errorf("DiskFullPolicyBuffer is not supported by counting filters")
+	}
+	disk, err := open(filename, controller, variantCounting)
+	if err != nil {
+		return nil, err
+	}
+	return &DiskCountingFilter{disk: disk}, nil
+}
+
+// CounterWidth returns the number of bits of each counter.
+func (c *DiskCountingFilter) CounterWidth() uint8 {
+	return c.disk.header.CounterWidth
+}
+
+func (c *DiskCountingFilter) FilterParam() FilterParam {
+	return c.disk.FilterParam()
+}
+
+// Header returns the header of the filter file.
+func (c *DiskCountingFilter) Header() Header {
+	return c.disk.Header()
+}
+
+// Close should be invoked if the filter is not needed anymore
+func (c *DiskCountingFilter) Close() error {
+	return c.disk.Close()
+}
+
+// Exist returns if an entry is in the filter
+func (c *DiskCountingFilter) Exist(b []byte) bool {
+	exist, _ := c.ExistErr(b)
+	return exist
+}
+
+// ExistErr is like Exist, but returns the error if the filter failed to be read,
+// instead of reporting the entry as not in.
+func (c *DiskCountingFilter) ExistErr(b []byte) (bool, error) {
+	return c.update(c.disk.Hash(b), func(exist bool) int { return 0 })
+}
+
+// ExistOrAdd returns whether the entry was in the filter, and adds an entry to the filter if it was not in.
+func (c *DiskCountingFilter) ExistOrAdd(b []byte) (exist bool) {
+	exist, _ = c.ExistOrAddErr(b)
+	return exist
+}
+
+// ExistOrAddErr is like ExistOrAdd, but returns the error if the entry failed to be added.
+func (c *DiskCountingFilter) ExistOrAddErr(b []byte) (exist bool, err error) {
+	return c.update(c.disk.Hash(b), func(exist bool) int {
+		if exist {
+			return 0
+		}
+		return 1
+	})
+}
+
+// Add increments the counters of an entry, even if it is in the filter,
+// so an entry added twice is in the filter until it is deleted twice.
+func (c *DiskCountingFilter) Add(b []byte) error {
+	_, err := c.update(c.disk.Hash(b), func(exist bool) int { return 1 })
+	return err
+}
+
+// Delete decrements the counters of an entry. It returns NotInFilterErr if the entry is not in the filter,
+// in which case nothing changes, since decrementing the counters of others would make them missing.
+// Deleting an entry never added but reported as existing by a false positive corrupts the filter.
+func (c *DiskCountingFilter) Delete(b []byte) error {
+	exist, err := c.update(c.disk.Hash(b), func(exist bool) int {
+		if !exist {
+			return 0
+		}
+		return -1
+	})
+	if err == nil && !exist {
+		err = NotInFilterErr
+	}
+	return err
+}
+
+// update adds delta(exist) to the counters of an entry, and returns whether the entry was in the filter.
+func (c *DiskCountingFilter) update(h KeyHash, delta func(exist bool) int) (exist bool, err error) {
+	f := c.disk
+	if !f.acquire() {
+		return false, ClosedErr
+	}
+	defer f.release()
+	width := uint64(f.header.CounterWidth)
+	full := byte(1<<width - 1)
+	offsets := f.offsets(h, f.slots())
+	positions := make([]int64, len(offsets))
+	shifts := make([]uint, len(offsets))
+	for i, offset := range offsets {
+		bit := offset * width
+		positions[i] = f.fileOffset(int64(bit / 8))
+		shifts[i] = uint(bit % 8)
+	}
+	var batch uint64
+	var d int
+	f.phase("io", func() {
+		f.lock()
+		defer f.file.mu.Unlock()
+		var vals map[int64]byte
+		if vals, batch, err = f.readBatchLocked(positions); err != nil {
+			batch = 0
+			return
+		}
+		exist = true
+		for i, pos := range positions {
+			if vals[pos]>>shifts[i]&full == 0 {
+				exist = false
+				break
+			}
+		}
+		d = delta(exist)
+		if d == 0 {
+			return
+		}
+		changed := make(map[int64]byte)
+		for i, pos := range positions {
+			counter := vals[pos] >> shifts[i] & full
+			if counter == full {
+				// saturated
+				continue
+			}
+			counter = byte(int(counter) + d)
+			vals[pos] = vals[pos]&^(full<<shifts[i]) | counter<<shifts[i]
+			changed[pos] = vals[pos]
+		}
+		batch, err = f.writeChangedLocked(changed, 1, batch)
+	})
+	if batch > 0 && (exist || d != 0) {
+		// do not report an entry or return before the counters are durable
+		if e := f.commit.wait(batch); err == nil {
+			err = e
+		}
+	}
+	return exist, err
+}
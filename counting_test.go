@@ -0,0 +1,116 @@
+package disk_bloom
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func newTestCountingFilter(t testing.TB, controller Controller) *DiskCountingFilter {
+	controller.GetParam = func(metadata []byte) (FilterParam, []byte) {
+		slots, bits := OptimalParam(1e4, 1e-4)
+		return FilterParam{
+			Slots: slots,
+			Bits:  bits,
+			Hash:  doubleFNV,
+		}, nil
+	}
+	bf, err := NewCounting("testfile", controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		bf.Close()
+		os.Remove("testfile")
+	})
+	return bf
+}
+
+func TestDiskCountingFilter_Delete(t *testing.T) {
+	for _, width := range []uint8{2, 4, 8} {
+		bf := newTestCountingFilter(t, Controller{CounterWidth: width})
+		for i := 0; i < 1000; i++ {
+			if err := bf.Add([]byte(strconv.Itoa(i))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// added twice
+		if err := bf.Add([]byte("0")); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 500; i++ {
+			if err := bf.Delete([]byte(strconv.Itoa(i))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !bf.Exist([]byte("0")) {
+			t.Fatalf("Width %v: 0 should exist until deleted twice", width)
+		}
+		if err := bf.Delete([]byte("0")); err != nil {
+			t.Fatal(err)
+		}
+		if bf.Exist([]byte("0")) {
+			t.Fatalf("Width %v: 0 should be deleted", width)
+		}
+		if err := bf.Delete([]byte("0")); !errors.Is(err, NotInFilterErr) {
+			t.Fatalf("Width %v: got %v, want NotInFilterErr", width, err)
+		}
+		deleted := 0
+		for i := 0; i < 500; i++ {
+			if !bf.Exist([]byte(strconv.Itoa(i))) {
+				deleted++
+			}
+		}
+		if deleted < 490 {
+			t.Fatalf("Width %v: only %v of 500 entries are deleted", width, deleted)
+		}
+		for i := 500; i < 1000; i++ {
+			if !bf.Exist([]byte(strconv.Itoa(i))) {
+				t.Fatalf("Width %v: %v should exist in filter", width, i)
+			}
+		}
+		bf.Close()
+
+		// the width is read from the header, and validated
+		other := uint8(2)
+		if width == 2 {
+			other = 4
+		}
+		if _, err := NewCounting("testfile", Controller{CounterWidth: other}); !errors.Is(err, CounterWidthErr) {
+			t.Fatalf("Width %v: got %v, want CounterWidthErr", width, err)
+		}
+		if _, err := New("testfile", Controller{}); !errors.Is(err, InvalidHeaderErr) {
+			t.Fatalf("Width %v: got %v, want InvalidHeaderErr", width, err)
+		}
+		bf = newTestCountingFilter(t, Controller{})
+		if bf.CounterWidth() != width {
+			t.Fatalf("Got width %v, want %v", bf.CounterWidth(), width)
+		}
+		for i := 500; i < 1000; i++ {
+			if !bf.Exist([]byte(strconv.Itoa(i))) {
+				t.Fatalf("Width %v: %v should exist in filter", width, i)
+			}
+		}
+		bf.Close()
+		os.Remove("testfile")
+	}
+}
+
+func TestDiskCountingFilter_Saturation(t *testing.T) {
+	bf := newTestCountingFilter(t, Controller{CounterWidth: 2})
+	for i := 0; i < 4; i++ {
+		if err := bf.Add([]byte("testing")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the saturated counters are never decremented
+	for i := 0; i < 4; i++ {
+		if err := bf.Delete([]byte("testing")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bf.Exist([]byte("testing")) {
+		t.Fatal("Saturated entry should still exist")
+	}
+}
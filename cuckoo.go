@@ -0,0 +1,357 @@
+package disk_bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+)
+
+const (
+	// cuckooBucketSize is the number of fingerprints in a bucket.
+	cuckooBucketSize = 4
+	// cuckooBucketBytes is the size of a bucket of 16-bit fingerprints.
+	cuckooBucketBytes = cuckooBucketSize * 2
+	// cuckooMaxKicks bounds the fingerprints relocated by an add before the filter is regarded as full.
+	cuckooMaxKicks = 500
+	// cuckooLoadFactor is the load factor which CuckooBits sizes the filter for.
+	cuckooLoadFactor = 0.95
+)
+
+var CuckooFullErr = fmt.Errorf("cuckoo filter is full")
+
+// Cuckoo returns whether the bloom filter is replaced by the buckets of a cuckoo filter.
+func (h Header) Cuckoo() bool {
+	return h.Flags&FlagCuckoo != 0
+}
+
+// CuckooBits returns the Bits of a cuckoo filter holding n entries.
+func CuckooBits(n uint64) uint64 {
+	return cuckooBits(uint64(float64(n)/cuckooLoadFactor) / cuckooBucketSize * cuckooBucketBytes * 8)
+}
+
+// cuckooBits rounds bits up to a power of two buckets, so that the alternate bucket is found by xor.
+func cuckooBits(bits uint64) uint64 {
+	buckets := uint64(1)
+	for buckets*cuckooBucketBytes*8 < bits {
+		buckets <<= 1
+	}
+	return buckets * cuckooBucketBytes * 8
+}
+
+// DiskCuckooFilter is a disk-based cuckoo filter (Fan et al.) of 16-bit fingerprints in buckets of 4,
+// which supports Delete. It takes about 17 bits per entry at the load factor 95% for the false positive rate 0.012%,
+// less than a classic Bloom filter of the same rate, and a lookup probes 2 buckets of 8 bytes.
+// It shares the metadata, the header, the fsync modes and the durability options of the classic Bloom filter.
+type DiskCuckooFilter struct {
+	disk *DiskFilter
+}
+
+// NewCuckoo creates or opens a cuckoo filter. The controller is the same as New, but the Slots of GetParam are ignored,
+// and the Bits are rounded up to a power of two buckets, see CuckooBits.
+// WriteBuffer and DiskFullPolicyBuffer are not supported, since the fingerprints can not be merged,
+// and neither are AdaptiveSlots.
+func NewCuckoo(filename string, controller Controller) (*DiskCuckooFilter, error) {
+	if controller.WriteBuffer > 0 || controller.DiskFullPolicy == DiskFullPolicyBuffer || controller.AdaptiveSlots > 0 {
+		return nil, fmt.Errorf("%w: WriteBuffer, DiskFullPolicyBuffer and AdaptiveSlots of cuckoo filters", UnsupportedErr)
+	}
+	if getParam := controller.GetParam; getParam != nil {
+		controller.GetParam = func(b []byte) (FilterParam, []byte) {
+			param, metadata := getParam(b)
+			if param.Bits > 0 {
+				param.Slots, param.Bits = cuckooBucketSize, cuckooBits(param.Bits)
+			}
+			return param, metadata
+		}
+	}
+	disk, err := open(filename, controller, variantCuckoo)
+	if err != nil {
+		return nil, err
+	}
+	return &DiskCuckooFilter{disk: disk}, nil
+}
+
+func (c *DiskCuckooFilter) FilterParam() FilterParam {
+	return c.disk.FilterParam()
+}
+
+// Header returns the header of the filter file.
+func (c *DiskCuckooFilter) Header() Header {
+	return c.disk.Header()
+}
+
+// Close should be invoked if the filter is not needed anymore
+func (c *DiskCuckooFilter) Close() error {
+	return c.disk.Close()
+}
+
+// Exist returns if an entry is in the filter
+func (c *DiskCuckooFilter) Exist(b []byte) bool {
+	exist, _ := c.ExistErr(b)
+	return exist
+}
+
+// ExistErr is like Exist, but returns the error if the filter failed to be read,
+// instead of reporting the entry as not in.
+func (c *DiskCuckooFilter) ExistErr(b []byte) (exist bool, err error) {
+	f := c.disk
+	if !f.acquire() {
+		return false, ClosedErr
+	}
+	defer f.release()
+	i1, i2, fp := c.locate(b)
+	var batch uint64
+	f.phase("io", func() {
+		f.rlock()
+		defer f.file.mu.RUnlock()
+		t := cuckooTable{f: f}
+		exist = t.find(i1, fp) >= 0 || t.find(i2, fp) >= 0
+		batch, err = t.batch, t.err
+	})
+	if err != nil {
+		return false, err
+	}
+	if exist && batch > 0 {
+		// do not report an entry before its fingerprint is durable, nor the one whose fingerprint failed to be synced
+		exist = f.commit.wait(batch) == nil
+	}
+	f.countLookup(exist)
+	return exist, nil
+}
+
+// ExistOrAdd returns whether the entry was in the filter, and adds an entry to the filter if it was not in.
+func (c *DiskCuckooFilter) ExistOrAdd(b []byte) (exist bool) {
+	exist, _ = c.ExistOrAddErr(b)
+	return exist
+}
+
+// ExistOrAddErr is like ExistOrAdd, but returns the error if the entry failed to be added,
+// which is CuckooFullErr if no bucket has room for it.
+func (c *DiskCuckooFilter) ExistOrAddErr(b []byte) (exist bool, err error) {
+	return c.update(b, func(t *cuckooTable, i1, i2 uint64, fp uint16) (bool, error) {
+		if t.find(i1, fp) >= 0 || t.find(i2, fp) >= 0 {
+			return true, nil
+		}
+		return false, t.insert(i1, i2, fp)
+	})
+}
+
+// Add adds the fingerprint of an entry, even if it is in the filter,
+// so an entry added twice is in the filter until it is deleted twice.
+// An entry can be added at most 8 times, after which Add returns CuckooFullErr.
+func (c *DiskCuckooFilter) Add(b []byte) error {
+	_, err := c.update(b, func(t *cuckooTable, i1, i2 uint64, fp uint16) (bool, error) {
+		return false, t.insert(i1, i2, fp)
+	})
+	return err
+}
+
+// Delete removes a fingerprint of an entry. It returns NotInFilterErr if the entry is not in the filter.
+// Deleting an entry never added but reported as existing by a false positive removes the fingerprint of another entry.
+func (c *DiskCuckooFilter) Delete(b []byte) error {
+	exist, err := c.update(b, func(t *cuckooTable, i1, i2 uint64, fp uint16) (bool, error) {
+		for _, i := range [2]uint64{i1, i2} {
+			if slot := t.find(i, fp); slot >= 0 {
+				t.set(i, slot, 0)
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err == nil && !exist {
+		err = NotInFilterErr
+	}
+	return err
+}
+
+// update applies fn to the buckets of an entry under the lock, and writes the buckets changed unless it fails.
+func (c *DiskCuckooFilter) update(b []byte, fn func(t *cuckooTable, i1, i2 uint64, fp uint16) (bool, error)) (exist bool, err error) {
+	f := c.disk
+	if !f.acquire() {
+		return false, ClosedErr
+	}
+	defer f.release()
+	i1, i2, fp := c.locate(b)
+	var batch uint64
+	f.phase("io", func() {
+		f.lock()
+		defer f.file.mu.Unlock()
+		t := cuckooTable{f: f}
+		if exist, err = fn(&t, i1, i2, fp); err == nil {
+			// the buckets failed to be read are not written, which would clear their fingerprints
+			err = t.err
+		}
+		if err != nil {
+			return
+		}
+		batch, err = f.writeChangedLocked(t.changedBytes(), 1, t.batch)
+	})
+	if batch > 0 {
+		// do not report an entry or return before the fingerprints are durable
+		if e := f.commit.wait(batch); err == nil {
+			err = e
+		}
+	}
+	return exist, err
+}
+
+// locate returns the buckets and the fingerprint of an entry.
+func (c *DiskCuckooFilter) locate(b []byte) (i1, i2 uint64, fp uint16) {
+	f := c.disk
+	h := f.Hash(b)
+	mask := f.param.Bits/(cuckooBucketBytes*8) - 1
+	// 0 marks an empty slot
+	fp = uint16(h.Y >> 48)
+	if fp == 0 {
+		fp = 1
+	}
+	i1 = h.X & mask
+	return i1, cuckooAlt(i1, fp, mask), fp
+}
+
+// cuckooAlt returns the other bucket of the fingerprint in bucket i, which is symmetric.
+func cuckooAlt(i uint64, fp uint16, mask uint64) uint64 {
+	return (i ^ uint64(fp)*0x5bd1e995) & mask
+}
+
+// cuckooTable is the buckets read and changed by an operation, which are written at once if it succeeds.
+type cuckooTable struct {
+	f *DiskFilter
+	// buckets are the buckets read, and original are their bytes in the file
+	buckets  map[uint64]*[cuckooBucketSize]uint16
+	original map[uint64][cuckooBucketBytes]byte
+	// batch is the group commit batch which the bytes read are waiting for
+	batch uint64
+	// err is the first error of reading the buckets, which read as empty
+	err error
+}
+
+// bucket reads the bucket i.
+func (t *cuckooTable) bucket(i uint64) *[cuckooBucketSize]uint16 {
+	if b, ok := t.buckets[i]; ok {
+		return b
+	}
+	if t.buckets == nil {
+		t.buckets = make(map[uint64]*[cuckooBucketSize]uint16)
+		t.original = make(map[uint64][cuckooBucketBytes]byte)
+	}
+	start := t.f.fileOffset(int64(i * cuckooBucketBytes))
+	positions := make([]int64, cuckooBucketBytes)
+	for j := range positions {
+		positions[j] = start + int64(j)
+	}
+	vals, batch, err := t.f.readBatchLocked(positions)
+	if batch > t.batch {
+		t.batch = batch
+	}
+	if err != nil && t.err == nil {
+		t.err = err
+	}
+	var raw [cuckooBucketBytes]byte
+	for j, pos := range positions {
+		raw[j] = vals[pos]
+	}
+	b := new([cuckooBucketSize]uint16)
+	for slot := range b {
+		b[slot] = binary.LittleEndian.Uint16(raw[slot*2:])
+	}
+	t.buckets[i], t.original[i] = b, raw
+	return b
+}
+
+// find returns the slot of fp in the bucket i, or -1.
+func (t *cuckooTable) find(i uint64, fp uint16) int {
+	for slot, v := range t.bucket(i) {
+		if v == fp {
+			return slot
+		}
+	}
+	return -1
+}
+
+func (t *cuckooTable) set(i uint64, slot int, fp uint16) {
+	t.bucket(i)[slot] = fp
+}
+
+// insert puts fp into an empty slot of either bucket, relocating the fingerprints in the way,
+// and returns CuckooFullErr if it fails, in which case the changes are not written.
+func (t *cuckooTable) insert(i1, i2 uint64, fp uint16) error {
+	for _, i := range [2]uint64{i1, i2} {
+		if slot := t.find(i, 0); slot >= 0 {
+			t.set(i, slot, fp)
+			return nil
+		}
+	}
+	mask := t.f.param.Bits/(cuckooBucketBytes*8) - 1
+	i := i1
+	if rand.Intn(2) == 0 {
+		i = i2
+	}
+	for kick := 0; kick < cuckooMaxKicks; kick++ {
+		slot := rand.Intn(cuckooBucketSize)
+		b := t.bucket(i)
+		fp, b[slot] = b[slot], fp
+		i = cuckooAlt(i, fp, mask)
+		if slot := t.find(i, 0); slot >= 0 {
+			t.set(i, slot, fp)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: no room after %v relocations", CuckooFullErr, cuckooMaxKicks)
+}
+
+// changedBytes returns the bytes of the buckets changed by their file offsets.
+func (t *cuckooTable) changedBytes() map[int64]byte {
+	changed := make(map[int64]byte)
+	for i, b := range t.buckets {
+		var raw [cuckooBucketBytes]byte
+		for slot, v := range b {
+			binary.LittleEndian.PutUint16(raw[slot*2:], v)
+		}
+		start := t.f.fileOffset(int64(i * cuckooBucketBytes))
+		for j, v := range raw {
+			if v != t.original[i][j] {
+				changed[start+int64(j)] = v
+			}
+		}
+	}
+	return changed
+}
+
+// Count returns the number of fingerprints in the filter, reading the whole filter.
+func (c *DiskCuckooFilter) Count() (uint64, error) {
+	f := c.disk
+	if !f.acquire() {
+		return 0, ClosedErr
+	}
+	defer f.release()
+	f.rlock()
+	defer f.file.mu.RUnlock()
+	size := int64(f.param.Bits / 8)
+	buf := make([]byte, 1<<16)
+	var count uint64
+	for offset := int64(0); offset < size; offset += int64(len(buf)) {
+		n := int64(len(buf))
+		if size-offset < n {
+			n = size - offset
+		}
+		if err := f.readBloomLocked(buf[:n], offset); err != nil {
+			return 0, err
+		}
+		for j := int64(0); j < n; j += 2 {
+			if binary.LittleEndian.Uint16(buf[j:]) != 0 {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+// LoadFactor returns the fraction of the slots holding a fingerprint, reading the whole filter.
+func (c *DiskCuckooFilter) LoadFactor() (float64, error) {
+	count, err := c.Count()
+	if err != nil {
+		return 0, err
+	}
+	slots := c.disk.param.Bits / 16
+	return float64(count) / float64(slots), nil
+}
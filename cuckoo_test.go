@@ -0,0 +1,150 @@
+package disk_bloom
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func newTestCuckooFilter(t testing.TB, n uint64) *DiskCuckooFilter {
+	bf, err := NewCuckoo("testfile", Controller{GetParam: func([]byte) (FilterParam, []byte) {
+		return FilterParam{Bits: CuckooBits(n), Hash: doubleFNV}, nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		bf.Close()
+		os.Remove("testfile")
+	})
+	return bf
+}
+
+func TestDiskCuckooFilter(t *testing.T) {
+	const n = 10000
+	bf := newTestCuckooFilter(t, n)
+	if param := bf.FilterParam(); param.Slots != cuckooBucketSize || param.Bits != CuckooBits(n) || param.Bits/64&(param.Bits/64-1) != 0 {
+		t.Fatalf("Unexpected parameters %+v", param)
+	}
+	// added regardless of the false positives, so that each can be deleted
+	for i := 0; i < n; i++ {
+		if err := bf.Add([]byte(strconv.Itoa(i))); err != nil {
+			t.Fatal(i, err)
+		}
+	}
+	for i := 0; i < n; i++ {
+		if !bf.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should exist in filter", i)
+		}
+	}
+	fp := 0
+	for i := n; i < 11*n; i++ {
+		if bf.Exist([]byte(strconv.Itoa(i))) {
+			fp++
+		}
+	}
+	if rate := float64(fp) / (10 * n); rate > 1e-3 {
+		t.Fatalf("False positive rate should be about 1e-4, got %v", rate)
+	}
+	count, err := bf.Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != n {
+		t.Fatalf("Count should be %v, got %v", n, count)
+	}
+	if !bf.ExistOrAdd([]byte("0")) {
+		t.Fatal("0 should exist in filter")
+	}
+	if count, _ = bf.Count(); count != n {
+		t.Fatalf("Should not add an existing entry, count %v", count)
+	}
+
+	// delete
+	for i := 0; i < n/2; i++ {
+		if err = bf.Delete([]byte(strconv.Itoa(i))); err != nil {
+			t.Fatal(i, err)
+		}
+	}
+	for i := n / 2; i < n; i++ {
+		if !bf.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should exist after deleting others", i)
+		}
+	}
+	missing := 0
+	for i := 0; i < n/2; i++ {
+		if !bf.Exist([]byte(strconv.Itoa(i))) {
+			missing++
+		}
+	}
+	if missing < n/2*99/100 {
+		t.Fatalf("Should delete, %v of %v missing", missing, n/2)
+	}
+	if err = bf.Delete([]byte("never added")); !errors.Is(err, NotInFilterErr) {
+		t.Fatalf("Should be NotInFilterErr, got %v", err)
+	}
+
+	// added twice
+	if err = bf.Add([]byte("twice")); err != nil {
+		t.Fatal(err)
+	}
+	if err = bf.Add([]byte("twice")); err != nil {
+		t.Fatal(err)
+	}
+	if err = bf.Delete([]byte("twice")); err != nil {
+		t.Fatal(err)
+	}
+	if !bf.Exist([]byte("twice")) {
+		t.Fatal("Should exist until deleted twice")
+	}
+
+	// reopen
+	if err = bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = New("testfile", Controller{}); !errors.Is(err, InvalidHeaderErr) {
+		t.Fatalf("Should be InvalidHeaderErr, got %v", err)
+	}
+	reopened, err := NewCuckoo("testfile", Controller{GetParam: func([]byte) (FilterParam, []byte) {
+		return FilterParam{Hash: doubleFNV}, nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if !reopened.Header().Cuckoo() || reopened.Describe().Variant != "cuckoo" {
+		t.Fatalf("Should be a cuckoo filter, got %+v", reopened.Describe())
+	}
+	for i := n / 2; i < n; i++ {
+		if !reopened.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should exist after reopening", i)
+		}
+	}
+}
+
+func TestDiskCuckooFilter_Full(t *testing.T) {
+	bf := newTestCuckooFilter(t, 100)
+	var err error
+	added := 0
+	for i := 0; err == nil; i++ {
+		var exist bool
+		if exist, err = bf.ExistOrAddErr([]byte(strconv.Itoa(i))); err == nil && !exist {
+			added++
+		}
+	}
+	if !errors.Is(err, CuckooFullErr) {
+		t.Fatalf("Should be CuckooFullErr, got %v", err)
+	}
+	// the failed add loses no fingerprint
+	count, err := bf.Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != uint64(added) {
+		t.Fatalf("Should keep %v fingerprints, got %v", added, count)
+	}
+	if load, _ := bf.LoadFactor(); load < 0.9 {
+		t.Fatalf("Load factor should be high when full, got %v", load)
+	}
+}
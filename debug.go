@@ -0,0 +1,216 @@
+package disk_bloom
+
+import (
+	"context"
+	"fmt"
+	"runtime/pprof"
+	"sync/atomic"
+	"time"
+)
+
+// DebugStats is a dump of the internal counters of a DiskFilter, see Controller.Debug.
+type DebugStats struct {
+	// Lookups is the number of Exist and ExistOrAdd
+	Lookups uint64
+	// Probes is the number of probed bytes
+	Probes uint64
+	// Reads is the number of reads from the storage
+	Reads uint64
+	// CacheHits is the number of probes served without reading, by the pinned range or pages read before
+	CacheHits uint64
+	// LockWait is the total time waiting for the file lock
+	LockWait time.Duration
+	// Exists is the number of entries looked up by Exist and ExistBatch
+	Exists uint64
+	// Adds is the number of entries given to ExistOrAdd and ExistOrAddBatch
+	Adds uint64
+	// Hits is the number of Exists and Adds finding the entry
+	Hits uint64
+	// BytesRead and BytesWritten are the bytes read from and written to the file,
+	// not counting the bytes served by Mmap or BlockCache
+	BytesRead    uint64
+	BytesWritten uint64
+	// Syncs is the number of syncs of the file, and SyncTime their total time
+	Syncs    uint64
+	SyncTime time.Duration
+	// ProbePages is the number of distinct pages probed by the lookups
+	ProbePages uint64
+	// Writes is the number of bytes written one by one by the adds, WritePages the number of distinct pages among them
+	// per add or batch, and WritingAdds the number of adds writing them. See DiskFilter.Advice.
+	Writes      uint64
+	WritePages  uint64
+	WritingAdds uint64
+}
+
+// ProbesPerLookup returns the average number of probed bytes per lookup.
+func (s DebugStats) ProbesPerLookup() float64 {
+	if s.Lookups == 0 {
+		return 0
+	}
+	return float64(s.Probes) / float64(s.Lookups)
+}
+
+// CacheHitRate returns the fraction of probes served without reading.
+func (s DebugStats) CacheHitRate() float64 {
+	if s.Probes == 0 {
+		return 0
+	}
+	return float64(s.CacheHits) / float64(s.Probes)
+}
+
+// PagesPerLookup returns the average number of distinct pages probed per lookup.
+func (s DebugStats) PagesPerLookup() float64 {
+	if s.Lookups == 0 {
+		return 0
+	}
+	return float64(s.ProbePages) / float64(s.Lookups)
+}
+
+// HitRate returns the fraction of Exists and Adds finding the entry.
+func (s DebugStats) HitRate() float64 {
+	if s.Exists+s.Adds == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Exists+s.Adds)
+}
+
+// SyncLatency returns the average time of a sync.
+func (s DebugStats) SyncLatency() time.Duration {
+	if s.Syncs == 0 {
+		return 0
+	}
+	return s.SyncTime / time.Duration(s.Syncs)
+}
+
+func (s DebugStats) String() string {
+	return fmt.Sprintf("lookups: %v, probes per lookup: %.2f, reads: %v, cache hit rate: %.2f%%, lock wait: %v, "+
+		"hit rate: %.2f%%, bytes read: %v, bytes written: %v, syncs: %v, sync latency: %v, pages per lookup: %.2f, writes: %v",
+		s.Lookups, s.ProbesPerLookup(), s.Reads, s.CacheHitRate()*100, s.LockWait,
+		s.HitRate()*100, s.BytesRead, s.BytesWritten, s.Syncs, s.SyncLatency(), s.PagesPerLookup(), s.Writes)
+}
+
+type debugCounters struct {
+	lookups   uint64
+	probes    uint64
+	reads     uint64
+	cacheHits uint64
+	lockWait  int64
+
+	exists       uint64
+	adds         uint64
+	hits         uint64
+	bytesRead    uint64
+	bytesWritten uint64
+	syncs        uint64
+	syncTime     int64
+	probePages   uint64
+	writes       uint64
+	writePages   uint64
+	writingAdds  uint64
+}
+
+// DebugStats returns the internal counters. They are all zero unless Controller.Debug is set.
+func (f *DiskFilter) DebugStats() DebugStats {
+	return DebugStats{
+		Lookups:   atomic.LoadUint64(&f.debug.lookups),
+		Probes:    atomic.LoadUint64(&f.debug.probes),
+		Reads:     atomic.LoadUint64(&f.debug.reads),
+		CacheHits: atomic.LoadUint64(&f.debug.cacheHits),
+		LockWait:  time.Duration(atomic.LoadInt64(&f.debug.lockWait)),
+
+		Exists:       atomic.LoadUint64(&f.debug.exists),
+		Adds:         atomic.LoadUint64(&f.debug.adds),
+		Hits:         atomic.LoadUint64(&f.debug.hits),
+		BytesRead:    atomic.LoadUint64(&f.debug.bytesRead),
+		BytesWritten: atomic.LoadUint64(&f.debug.bytesWritten),
+		Syncs:        atomic.LoadUint64(&f.debug.syncs),
+		SyncTime:     time.Duration(atomic.LoadInt64(&f.debug.syncTime)),
+		ProbePages:   atomic.LoadUint64(&f.debug.probePages),
+		Writes:       atomic.LoadUint64(&f.debug.writes),
+		WritePages:   atomic.LoadUint64(&f.debug.writePages),
+		WritingAdds:  atomic.LoadUint64(&f.debug.writingAdds),
+	}
+}
+
+// phase runs fn with the pprof label "disk_bloom" set to name if Controller.Debug is set.
+func (f *DiskFilter) phase(name string, fn func()) {
+	if !f.controller.Debug {
+		fn()
+		return
+	}
+	pprof.Do(context.Background(), pprof.Labels("disk_bloom", name), func(context.Context) {
+		fn()
+	})
+}
+
+// lock locks the file, and accounts the time waiting for it if Controller.Debug is set.
+func (f *DiskFilter) lock() {
+	if !f.controller.Debug {
+		f.file.mu.Lock()
+		return
+	}
+	start := time.Now()
+	f.file.mu.Lock()
+	atomic.AddInt64(&f.debug.lockWait, int64(time.Since(start)))
+}
+
+// rlock read-locks the file for a lookup, and accounts the time waiting for it if Controller.Debug is set.
+func (f *DiskFilter) rlock() {
+	if !f.controller.Debug {
+		f.file.mu.RLock()
+		return
+	}
+	start := time.Now()
+	f.file.mu.RLock()
+	atomic.AddInt64(&f.debug.lockWait, int64(time.Since(start)))
+}
+
+// account adds the counters of a lookup.
+func (f *DiskFilter) account(r *pageReader) {
+	if !f.controller.Debug {
+		return
+	}
+	atomic.AddUint64(&f.debug.lookups, 1)
+	atomic.AddUint64(&f.debug.probes, r.probes)
+	atomic.AddUint64(&f.debug.reads, r.reads)
+	atomic.AddUint64(&f.debug.cacheHits, r.probes-r.reads)
+	atomic.AddUint64(&f.debug.probePages, distinctPages(r.positions))
+}
+
+// accountWrites adds the counters of the sorted file offsets written one by one by adds.
+func (f *DiskFilter) accountWrites(written []int64, adds uint64) {
+	if !f.controller.Debug || len(written) == 0 {
+		return
+	}
+	atomic.AddUint64(&f.debug.writes, uint64(len(written)))
+	atomic.AddUint64(&f.debug.writePages, distinctPages(written))
+	atomic.AddUint64(&f.debug.writingAdds, adds)
+}
+
+// distinctPages returns the number of distinct pages of the sorted file offsets.
+func distinctPages(positions []int64) (n uint64) {
+	for i, pos := range positions {
+		if i == 0 || pos/pageSize != positions[i-1]/pageSize {
+			n++
+		}
+	}
+	return n
+}
+
+// meteredStorage counts the bytes read and written, see Controller.Debug.
+type meteredStorage struct {
+	storage
+	counters *debugCounters
+}
+
+func (s meteredStorage) ReadAt(b []byte, offset int64) (int, error) {
+	n, err := s.storage.ReadAt(b, offset)
+	atomic.AddUint64(&s.counters.bytesRead, uint64(n))
+	return n, err
+}
+
+func (s meteredStorage) WriteAt(b []byte, offset int64) (int, error) {
+	n, err := s.storage.WriteAt(b, offset)
+	atomic.AddUint64(&s.counters.bytesWritten, uint64(n))
+	return n, err
+}
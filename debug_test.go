@@ -0,0 +1,51 @@
+package disk_bloom
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestDiskFilter_DebugStats(t *testing.T) {
+	bf := newTestFilter(t, Controller{Debug: true})
+	for i := 0; i < 100; i++ {
+		bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	for i := 0; i < 100; i++ {
+		if !bf.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should exist in filter", i)
+		}
+	}
+	stats := bf.DebugStats()
+	if stats.Lookups != 200 {
+		t.Fatalf("Lookups: got %v, want 200", stats.Lookups)
+	}
+	if p := stats.ProbesPerLookup(); p <= 0 || p > float64(bf.FilterParam().Slots) {
+		t.Fatalf("ProbesPerLookup: got %v", p)
+	}
+	if stats.Reads == 0 || stats.Reads > stats.Probes || stats.CacheHits != stats.Probes-stats.Reads {
+		t.Fatalf("Unexpected reads: %v", stats)
+	}
+}
+
+func TestDiskFilter_DebugStatsDisabled(t *testing.T) {
+	bf := newTestFilter(t, Controller{})
+	bf.ExistOrAdd([]byte("testing"))
+	if stats := bf.DebugStats(); stats != (DebugStats{}) {
+		t.Fatalf("Should be zero without Debug, got %v", stats)
+	}
+}
+
+func TestDiskFilter_DebugStatsIO(t *testing.T) {
+	bf := newTestFilter(t, Controller{Debug: true, Fsync: FsyncModeAlways})
+	bf.ExistOrAdd([]byte("testing"))
+	bf.ExistOrAdd([]byte("testing"))
+	bf.Exist([]byte("testing"))
+	bf.Exist([]byte("absent"))
+	stats := bf.DebugStats()
+	if stats.Exists != 2 || stats.Adds != 2 || stats.Hits != 2 || stats.HitRate() != 0.5 {
+		t.Fatalf("Unexpected hits: %v", stats)
+	}
+	if stats.BytesRead == 0 || stats.BytesWritten == 0 || stats.Syncs == 0 || stats.SyncTime <= 0 {
+		t.Fatalf("Unexpected I/O: %v", stats)
+	}
+}
@@ -0,0 +1,149 @@
+package disk_bloom
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+	"sort"
+	"sync/atomic"
+)
+
+// A delta carries the bytes of the bloom filter changed since the last delta, which are ORed into the replica,
+// so that applying a delta twice or out of order is harmless:
+//
+// | magic(8) | count(8) | (offset in the bloom filter(8) | byte(1)) * count |
+const deltaMagic = "DBLMDLT1"
+
+var InvalidDeltaErr = fmt.Errorf("invalid delta")
+
+// ExportDelta writes the bytes of the bloom filter changed since the last ExportDelta, or since the file was opened,
+// and returns the number of bytes in the delta. See Controller.TrackDeltas.
+func (f *DiskFilter) ExportDelta(w io.Writer) (n int, err error) {
+	if !f.acquire() {
+		return 0, ClosedErr
+	}
+	defer f.release()
+	f.file.mu.Lock()
+	changes := f.changes
+	if changes == nil {
+		f.file.mu.Unlock()
+		return 0, fmt.Errorf("deltas are not tracked")
+	}
+	f.changes = make(map[int64]byte)
+	f.file.mu.Unlock()
+	if err = writeDelta(w, changes); err != nil {
+		// bring the changes back for the next delta
+		f.file.mu.Lock()
+		for offset, val := range changes {
+			f.changes[offset] |= val
+		}
+		f.file.mu.Unlock()
+		return 0, err
+	}
+	return len(changes), nil
+}
+
+func writeDelta(w io.Writer, changes map[int64]byte) error {
+	offsets := make([]int64, 0, len(changes))
+	for offset := range changes {
+		offsets = append(offsets, offset)
+	}
+	sort.Slice(offsets, func(i, j int) bool {
+		return offsets[i] < offsets[j]
+	})
+	bw := bufio.NewWriter(w)
+	var b [9]byte
+	copy(b[:], deltaMagic)
+	if _, err := bw.Write(b[:len(deltaMagic)]); err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint64(b[:], uint64(len(offsets)))
+	if _, err := bw.Write(b[:8]); err != nil {
+		return err
+	}
+	for _, offset := range offsets {
+		binary.LittleEndian.PutUint64(b[:], uint64(offset))
+		b[8] = changes[offset]
+		if _, err := bw.Write(b[:]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// readDelta invokes fn with each byte of a delta.
+func readDelta(r io.Reader, fn func(offset uint64, val byte) error) error {
+	br := bufio.NewReader(r)
+	var b [9]byte
+	if _, err := io.ReadFull(br, b[:len(deltaMagic)]); err != nil || string(b[:len(deltaMagic)]) != deltaMagic {
+		return fmt.Errorf("%w: bad magic", InvalidDeltaErr)
+	}
+	if _, err := io.ReadFull(br, b[:8]); err != nil {
+		return fmt.Errorf("%w: %v", InvalidDeltaErr, err)
+	}
+	count := binary.LittleEndian.Uint64(b[:])
+	for i := uint64(0); i < count; i++ {
+		if _, err := io.ReadFull(br, b[:]); err != nil {
+			return fmt.Errorf("%w: %v", InvalidDeltaErr, err)
+		}
+		if err := fn(binary.LittleEndian.Uint64(b[:]), b[8]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyDelta ORs a delta exported by ExportDelta into the bloom filter, which should be a replica of the exporting filter.
+func (f *DiskFilter) ApplyDelta(r io.Reader) error {
+	if !f.acquire() {
+		return ClosedErr
+	}
+	defer f.release()
+	size := uint64(f.Size())
+	f.lock()
+	defer f.file.mu.Unlock()
+	if f.readOnly {
+		return f.readOnlyErr()
+	}
+	err := readDelta(r, func(offset uint64, val byte) error {
+		if offset >= size {
+			return fmt.Errorf("%w: offset %v beyond the bloom filter of %v bytes", InvalidDeltaErr, offset, size)
+		}
+		pos := f.fileOffset(int64(offset))
+		var old [1]byte
+		if _, err := f.file.rw.ReadAt(old[:], pos); err != nil {
+			return err
+		}
+		// including the bits buffered in memory
+		old[0] |= f.pending[pos]
+		if old[0]|val == old[0] {
+			return nil
+		}
+		if err := f.writeByteLocked(old[0]|val, pos); err != nil {
+			return err
+		}
+		atomic.AddUint64(&f.setBits, uint64(bits.OnesCount8(old[0]|val)-bits.OnesCount8(old[0])))
+		f.file.modified = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if f.file.fsync == FsyncModeAlways {
+		return f.syncFile()
+	}
+	return nil
+}
+
+// untakeDelta brings the changes of a delta failed to be delivered back, so that they are in the next delta.
+func (f *DiskFilter) untakeDelta(delta []byte) error {
+	f.file.mu.Lock()
+	defer f.file.mu.Unlock()
+	return readDelta(bytes.NewReader(delta), func(offset uint64, val byte) error {
+		f.changes[int64(offset)] |= val
+		return nil
+	})
+}
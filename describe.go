@@ -0,0 +1,114 @@
+package disk_bloom
+
+import (
+	"path/filepath"
+	"time"
+)
+
+const (
+	// HealthOK is the health of a filter accepting adds.
+	HealthOK = "ok"
+	// HealthDiskFull is the health of a filter which failed to write since the disk is full.
+	HealthDiskFull = "disk_full"
+	// HealthClosed is the health of a closed filter.
+	HealthClosed = "closed"
+	// HealthIOError is the health of a filter whose last I/O failed, see Err.
+	HealthIOError = "io_error"
+)
+
+// FilterDescription is a summary of a filter for operational tooling, which is serializable to JSON.
+type FilterDescription struct {
+	// Filename is the absolute path of the file
+	Filename string `json:"filename"`
+	// Variant is "classic", "counting", "xor", "cuckoo" or "ttl"
+	Variant       string `json:"variant"`
+	FormatVersion uint16 `json:"format_version"`
+	Flags         uint16 `json:"flags"`
+	Tag           string `json:"tag,omitempty"`
+	MetadataSize  uint16 `json:"metadata_size"`
+	Slots         uint8  `json:"slots"`
+	Bits          uint64 `json:"bits"`
+	// Backend is "mmap" if the bloom filter is mapped, see Controller.Mmap, "hybrid" if it is in memory, see Controller.Hybrid, or "file"
+	Backend string `json:"backend"`
+	// Fsync is "always", "every_sec" or "no"
+	Fsync    string `json:"fsync"`
+	Sealed   bool   `json:"sealed"`
+	ReadOnly bool   `json:"read_only"`
+	// Health is HealthOK, HealthDiskFull, HealthIOError or HealthClosed
+	Health string `json:"health"`
+	// Created, LastAdd and LastSync are the times of the header in UTC, which are zero if not recorded, see Header.LastAdd
+	Created  time.Time `json:"created"`
+	LastAdd  time.Time `json:"last_add"`
+	LastSync time.Time `json:"last_sync"`
+	// AutoTune is the result of Controller.AutoTune, or nil if it is off
+	AutoTune *AutoTune `json:"auto_tune,omitempty"`
+}
+
+// Describe returns a summary of the filter. It can be invoked after Close.
+func (f *DiskFilter) Describe() FilterDescription {
+	filename := f.file.f.Name()
+	// the names in an fs.FS of OpenFS are kept as they are
+	if f.file.osFile() != nil {
+		if abs, err := filepath.Abs(filename); err == nil {
+			filename = abs
+		}
+	}
+	d := FilterDescription{
+		Filename:      filename,
+		Variant:       f.header.variant().String(),
+		FormatVersion: f.header.Version,
+		Flags:         f.header.Flags,
+		Tag:           f.header.Tag,
+		MetadataSize:  f.controller.MetadataSize,
+		Slots:         f.param.Slots,
+		Bits:          f.param.Bits,
+		Backend:       "file",
+		Fsync:         f.file.fsync.String(),
+		Sealed:        f.header.Sealed(),
+		Health:        HealthOK,
+		Created:       f.header.Created.UTC(),
+		AutoTune:      f.tune,
+	}
+	lastAdd, lastSync := f.lastTimes()
+	d.LastAdd, d.LastSync = lastAdd.UTC(), lastSync.UTC()
+	select {
+	case <-f.closed:
+		d.Health = HealthClosed
+		return d
+	default:
+	}
+	f.file.mu.Lock()
+	defer f.file.mu.Unlock()
+	if f.mapped != nil {
+		d.Backend = "mmap"
+	} else if f.hybrid != nil {
+		d.Backend = "hybrid"
+	}
+	d.ReadOnly = f.readOnly
+	if f.diskFull {
+		d.Health = HealthDiskFull
+	} else if f.Err() != nil {
+		d.Health = HealthIOError
+	}
+	return d
+}
+
+// Describe returns a summary of the filter. It can be invoked after Close.
+func (c *DiskCountingFilter) Describe() FilterDescription {
+	return c.disk.Describe()
+}
+
+// Describe returns a summary of the filter. It can be invoked after Close.
+func (x *DiskXorFilter) Describe() FilterDescription {
+	return x.disk.Describe()
+}
+
+// Describe returns a summary of the filter. It can be invoked after Close.
+func (c *DiskCuckooFilter) Describe() FilterDescription {
+	return c.disk.Describe()
+}
+
+// Describe returns a summary of the filter. It can be invoked after Close.
+func (t *DiskTTLFilter) Describe() FilterDescription {
+	return t.disk.Describe()
+}
@@ -0,0 +1,43 @@
+package disk_bloom
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiskFilter_Describe(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeEverySec, Tag: "replay"})
+	d := bf.Describe()
+	abs, _ := filepath.Abs("testfile")
+	param := bf.FilterParam()
+	if d.Filename != abs || d.Variant != "classic" || d.FormatVersion != HeaderVersion || d.Tag != "replay" ||
+		d.Slots != param.Slots || d.Bits != param.Bits || d.Backend != "file" || d.Fsync != "every_sec" ||
+		d.Sealed || d.ReadOnly || d.Health != HealthOK {
+		t.Fatalf("unexpected description: %+v", d)
+	}
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded FilterDescription
+	if err = json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != d {
+		t.Fatalf("%+v != %+v", decoded, d)
+	}
+
+	if err = bf.Seal(); err != nil {
+		t.Fatal(err)
+	}
+	if d = bf.Describe(); !d.Sealed || !d.ReadOnly {
+		t.Fatalf("should be sealed and read-only: %+v", d)
+	}
+	if err = bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d = bf.Describe(); d.Health != HealthClosed {
+		t.Fatalf("health should be %v, got %v", HealthClosed, d.Health)
+	}
+}
@@ -0,0 +1,200 @@
+package disk_bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ForeignFileErr  = fmt.Errorf("not a filter of the group")
+	OrphanedFileErr = fmt.Errorf("orphaned file of the group")
+)
+
+// SkippedFile is a file matching the pattern of a FilterGroup, which is not used as a filter of the group.
+type SkippedFile struct {
+	Filename string
+	// Err wraps ForeignFileErr if the file is not a compatible filter,
+	// or OrphanedFileErr if it is out of the sequence of the filters.
+	Err error
+}
+
+// Skipped returns the files found by NewGroup that match the pattern but are not used as filters.
+// They are left untouched, and their names are never used by the group.
+func (g *FilterGroup) Skipped() []SkippedFile {
+	skipped := make([]SkippedFile, 0, len(g.skipped))
+	for _, s := range g.skipped {
+		skipped = append(skipped, s)
+	}
+	sort.Slice(skipped, func(i, j int) bool {
+		return skipped[i].Filename < skipped[j].Filename
+	})
+	return skipped
+}
+
+func (g *FilterGroup) skip(filename string, err error) {
+	if g.skipped == nil {
+		g.skipped = make(map[string]SkippedFile)
+	}
+	g.skipped[filepath.Clean(filename)] = SkippedFile{Filename: filename, Err: err}
+}
+
+// positionFilename returns the filename of the filter at the given position of the group, skipping the names of skipped files.
+func (g *FilterGroup) positionFilename(position int) string {
+	for i := 0; ; i++ {
+		filename := g.filename(strconv.Itoa(i))
+		if _, ok := g.skipped[filepath.Clean(filename)]; ok {
+			continue
+		}
+		if position == 0 {
+			return filename
+		}
+		position--
+	}
+}
+
+// NamingScheme is how the files of a FilterGroup are named, see WithNamingScheme.
+type NamingScheme uint8
+
+const (
+	// NamingSchemeSequence names the files by their positions in the group: 0, 1, 2, ...
+	// The files after a dropped one are renamed to keep the sequence.
+	NamingSchemeSequence NamingScheme = iota
+	// NamingSchemeTimestamp names the files by the UTC time they are created, e.g. 20240102T150405.000000000Z,
+	// which sort by themselves, so no file is renamed when an older one is dropped.
+	NamingSchemeTimestamp
+)
+
+// timestampLayout is the layout of the names of NamingSchemeTimestamp, which is fixed-width to sort.
+const timestampLayout = "20060102T150405.000000000Z"
+
+// WithNamingScheme sets how the files of the group are named, which defaults to NamingSchemeSequence.
+// A group should be opened with the scheme it was created with, or its files are skipped.
+func WithNamingScheme(scheme NamingScheme) GroupOption {
+	return func(g *FilterGroup) {
+		g.naming = scheme
+	}
+}
+
+// discover returns the existing files of the group in order, which are named by the index in place of the last "*"
+// of the pattern.
+func (g *FilterGroup) discover(pattern string, starIndex int) []string {
+	var filenames []string
+	if g.naming != NamingSchemeTimestamp {
+		for i := 0; ; i++ {
+			filename := g.filename(strconv.Itoa(i))
+			if _, err := os.Stat(filename); os.IsNotExist(err) {
+				break
+			}
+			filenames = append(filenames, filename)
+		}
+		return filenames
+	}
+	prefix, suffix := pattern[:starIndex], pattern[starIndex+1:]
+	created := make(map[string]time.Time)
+	// the pattern is validated, and the files not matched are skipped by skipUnknown
+	matches, _ := filepath.Glob(pattern)
+	for _, filename := range matches {
+		if !strings.HasPrefix(filename, prefix) || !strings.HasSuffix(filename, suffix) || len(filename) < len(prefix)+len(suffix) {
+			continue
+		}
+		t, err := time.Parse(timestampLayout, filename[len(prefix):len(filename)-len(suffix)])
+		if err != nil {
+			continue
+		}
+		created[filename] = t
+		filenames = append(filenames, filename)
+	}
+	sort.Slice(filenames, func(i, j int) bool {
+		return created[filenames[i]].Before(created[filenames[j]])
+	})
+	if len(filenames) > 0 {
+		g.stamp = created[filenames[len(filenames)-1]]
+	}
+	return filenames
+}
+
+// timestampFilename returns the filename of a new filter of NamingSchemeTimestamp, which is after all the existing ones.
+func (g *FilterGroup) timestampFilename() string {
+	now := SystemClock.Now().UTC()
+	if !now.After(g.stamp) {
+		now = g.stamp.Add(time.Nanosecond)
+	}
+	g.stamp = now
+	return g.filename(now.Format(timestampLayout))
+}
+
+// renumberLocked renames the files of filters from the position i on, and the prepared filter after them,
+// to keep the sequence of the group. The names of NamingSchemeTimestamp are kept.
+// It should be invoked with mu held when no filter is being prepared.
+func (g *FilterGroup) renumberLocked(filters []*filterObj, i int) error {
+	if g.naming == NamingSchemeTimestamp {
+		return nil
+	}
+	for ; i < len(filters); i++ {
+		if err := filters[i].rename(g.positionFilename(i)); err != nil {
+			return err
+		}
+	}
+	if g.next != nil {
+		return g.next.rename(g.positionFilename(len(filters)))
+	}
+	return nil
+}
+
+// skipUnknown skips the files matching pattern which are not filters of the group.
+func (g *FilterGroup) skipUnknown(pattern string, known map[string]bool) {
+	// the pattern is validated, and the files are reported on a best-effort basis
+	matches, _ := filepath.Glob(pattern)
+	for _, filename := range matches {
+		if known[filepath.Clean(filename)] {
+			continue
+		}
+		if _, ok := g.skipped[filepath.Clean(filename)]; !ok {
+			g.skip(filename, fmt.Errorf("%w: out of the sequence", OrphanedFileErr))
+		}
+	}
+}
+
+// readGroupMetadata reads the metadata of a filter file, and checks that it can be a filter of a FilterGroup.
+func readGroupMetadata(filename string) (Metadata, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return Metadata{}, err
+	}
+	defer f.Close()
+	raw := retryStorage{f}
+	b := make([]byte, LenOfMetadataSize+metadataSize)
+	if _, err = raw.ReadAt(b, 0); err != nil {
+		return Metadata{}, fmt.Errorf("%w: %v", ForeignFileErr, err)
+	}
+	if fms := binary.LittleEndian.Uint16(b); fms != metadataSize {
+		return Metadata{}, fmt.Errorf("%w: metadata size %v", ForeignFileErr, fms)
+	}
+	header, err := readHeader(raw, metadataSize)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("%w: %v", ForeignFileErr, err)
+	}
+	if header.Encrypted() {
+		return Metadata{}, fmt.Errorf("%w: encrypted", ForeignFileErr)
+	}
+	m := parseMetadata(b[LenOfMetadataSize:])
+	if m.Slots == 0 || m.Bits == 0 || m.Expected == 0 {
+		return Metadata{}, fmt.Errorf("%w: slots %v, bits %v, expected %v", ForeignFileErr, m.Slots, m.Bits, m.Expected)
+	}
+	if !header.Shrunk() {
+		info, err := f.Stat()
+		if err != nil {
+			return Metadata{}, err
+		}
+		if size := header.bloomStart(metadataSize) + header.bloomSize(header.bloomBits(m.Bits)); info.Size() < size {
+			return Metadata{}, fmt.Errorf("%w: %v bytes, but %v bits need %v bytes", ForeignFileErr, info.Size(), m.Bits, size)
+		}
+	}
+	return m, nil
+}
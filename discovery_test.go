@@ -0,0 +1,122 @@
+package disk_bloom
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestNewGroup_Skipped(t *testing.T) {
+	const n = 100
+	os.Mkdir("testfile", os.ModePerm)
+	defer os.RemoveAll("testfile")
+	bf, err := NewGroup("testfile/*", FsyncModeNo, n, 1e-4, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys [][]byte
+	add := func(filters int) {
+		for i := len(keys); len(bf.load()) < filters; i++ {
+			key := []byte(fmt.Sprint(i))
+			keys = append(keys, key)
+			bf.ExistOrAdd(key)
+			bf.tasks.wait()
+		}
+	}
+	add(2)
+	bf.Close()
+	if len(bf.Skipped()) != 0 {
+		t.Fatalf("Unexpected skipped files: %v", bf.Skipped())
+	}
+
+	// the spare "2" is replaced by a foreign file, and unrelated files are put into the directory
+	foreign := bytes.Repeat([]byte("foreign"), 100)
+	for _, filename := range []string{"testfile/2", "testfile/9", "testfile/notes.txt"} {
+		if err = os.WriteFile(filename, foreign, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bf, err = NewGroup("testfile/*", FsyncModeNo, n, 1e-4, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	skipped := bf.Skipped()
+	if len(skipped) != 3 ||
+		skipped[0].Filename != "testfile/2" || !errors.Is(skipped[0].Err, ForeignFileErr) ||
+		skipped[1].Filename != "testfile/9" || !errors.Is(skipped[1].Err, OrphanedFileErr) ||
+		skipped[2].Filename != "testfile/notes.txt" || !errors.Is(skipped[2].Err, OrphanedFileErr) {
+		t.Fatalf("Unexpected skipped files: %v", skipped)
+	}
+	for _, key := range keys {
+		if !bf.Exist(key) {
+			t.Fatalf("%s should exist in filter", key)
+		}
+	}
+
+	// the group grows around the skipped files
+	add(10)
+	for _, f := range bf.load() {
+		if f.filename == "testfile/2" || f.filename == "testfile/9" {
+			t.Fatalf("%v should not be used", f.filename)
+		}
+	}
+	bf.Close()
+	for _, filename := range []string{"testfile/2", "testfile/9", "testfile/notes.txt"} {
+		if b, err := os.ReadFile(filename); err != nil || !bytes.Equal(b, foreign) {
+			t.Fatalf("%v should be untouched", filename)
+		}
+	}
+	bf, err = NewGroup("testfile/*", FsyncModeNo, n, 1e-4, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bf.Close()
+	if len(bf.load()) != 10 {
+		t.Fatalf("Got %v filters after reopening, want 10", len(bf.load()))
+	}
+	for _, key := range keys {
+		if !bf.Exist(key) {
+			t.Fatalf("%s should exist in filter", key)
+		}
+	}
+}
+
+func TestNewGroup_OpenParallelism(t *testing.T) {
+	const n = 10
+	os.Mkdir("testfile", os.ModePerm)
+	defer os.RemoveAll("testfile")
+	bf, err := NewGroup("testfile/*", FsyncModeNo, n, 1e-4, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys [][]byte
+	for i := 0; len(bf.load()) < 50; i++ {
+		key := []byte(fmt.Sprint(i))
+		keys = append(keys, key)
+		bf.ExistOrAdd(key)
+		bf.tasks.wait()
+	}
+	filters := len(bf.load())
+	bf.Close()
+
+	bf, err = NewGroup("testfile/*", FsyncModeNo, n, 1e-4, doubleFNV, WithOpenParallelism(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bf.Close()
+	if len(bf.load()) != filters || len(bf.Skipped()) != 0 {
+		t.Fatalf("Should open %v filters in order, got %v and skipped %v", filters, len(bf.load()), bf.Skipped())
+	}
+	for i, f := range bf.load() {
+		if f.filename != bf.positionFilename(i) {
+			t.Fatalf("Should open %v at %v, got %v", bf.positionFilename(i), i, f.filename)
+		}
+	}
+	for _, key := range keys {
+		if !bf.Exist(key) {
+			t.Fatalf("%s should exist in filter", key)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package disk_bloom
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"syscall"
+)
+
+// DiskFullPolicy decides what ExistOrAdd does when the disk is full.
+type DiskFullPolicy int
+
+const (
+	// DiskFullPolicyError drops the entry and returns the error from ExistOrAddErr.
+	DiskFullPolicyError DiskFullPolicy = iota
+	// DiskFullPolicyBuffer keeps the bytes that failed to be written in memory, so the entry is still visible
+	// to Exist, and retries writing them every second until the disk has space again.
+	// It behaves as DiskFullPolicyError in FsyncModeAlways, where an entry must be durable before visible.
+	DiskFullPolicyBuffer
+	// DiskFullPolicyReadOnly makes the filter read-only. Following ExistOrAdd only check the existence.
+	DiskFullPolicyReadOnly
+)
+
+var ReadOnlyErr = fmt.Errorf("filter is read-only")
+
+func isDiskFull(err error) bool {
+	return errors.Is(err, syscall.ENOSPC)
+}
+
+// onWriteErrorLocked applies the DiskFullPolicy to the bytes failed to be written.
+// It returns the error ExistOrAddErr should report.
+func (f *DiskFilter) onWriteErrorLocked(err error, failed map[int64]byte) error {
+	f.noteIO(ioOpWrite, err)
+	if !isDiskFull(err) {
+		return err
+	}
+	if f.controller.OnDiskFull != nil && !f.diskFull {
+		f.controller.OnDiskFull(err)
+	}
+	f.diskFull = true
+	policy := f.controller.DiskFullPolicy
+	if policy == DiskFullPolicyBuffer && f.file.fsync == FsyncModeAlways {
+		policy = DiskFullPolicyError
+	}
+	switch policy {
+	case DiskFullPolicyBuffer:
+		for pos, val := range failed {
+			if !f.addPendingLocked(pos, val) {
+				return err
+			}
+		}
+		return nil
+	case DiskFullPolicyReadOnly:
+		f.readOnly = true
+		return err
+	default:
+		return err
+	}
+}
+
+// flushPendingLocked writes the buffered bytes in sorted runs, each covering the buffered bytes within a page
+// by a single read and write. The bytes failed to be written are kept for the next flush, and the error is returned.
+func (f *DiskFilter) flushPendingLocked() error {
+	positions := make([]int64, 0, len(f.pending))
+	for pos := range f.pending {
+		positions = append(positions, pos)
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i] < positions[j]
+	})
+	for len(positions) > 0 {
+		n := 1
+		for n < len(positions) && positions[n]/pageSize == positions[0]/pageSize {
+			n++
+		}
+		run := positions[:n]
+		positions = positions[n:]
+		start := run[0]
+		b := make([]byte, run[n-1]+1-start)
+		if _, err := f.file.rw.ReadAt(b, start); err != nil {
+			return err
+		}
+		for _, pos := range run {
+			b[pos-start] |= f.pending[pos]
+		}
+		if _, err := f.file.rw.WriteAt(b, start); err != nil {
+			return err
+		}
+		f.file.modified = true
+		for _, pos := range run {
+			if err := f.wroteLocked(b[pos-start], pos); err != nil {
+				return err
+			}
+			f.removePendingLocked(pos)
+		}
+	}
+	f.buffered = 0
+	f.diskFull = false
+	return nil
+}
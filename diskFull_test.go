@@ -0,0 +1,98 @@
+package disk_bloom
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func newTestFilter(t testing.TB, controller Controller) *DiskFilter {
+	controller.GetParam = func(metadata []byte) (FilterParam, []byte) {
+		slots, bits := OptimalParam(1e4, 1e-4)
+		return FilterParam{
+			Slots: slots,
+			Bits:  bits,
+			Hash:  doubleFNV,
+		}, nil
+	}
+	bf, err := New("testfile", controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		bf.Close()
+		os.Remove("testfile")
+	})
+	return bf
+}
+
+// simulateDiskFull applies the DiskFullPolicy as if writing the bits of b failed with ENOSPC.
+func simulateDiskFull(f *DiskFilter, b []byte) error {
+	x, y := f.param.Hash(b)
+	m := make(map[int64]byte)
+	for i := 0; i < int(f.param.Slots); i++ {
+		offset := f.bloomOffset(x, y, i)
+		m[f.fileOffset(int64(offset/8))] |= 1 << (offset % 8)
+	}
+	f.file.mu.Lock()
+	defer f.file.mu.Unlock()
+	return f.onWriteErrorLocked(&os.PathError{Op: "write", Path: "testfile", Err: syscall.ENOSPC}, m)
+}
+
+func TestDiskFullPolicyBuffer(t *testing.T) {
+	var alarmed int
+	bf := newTestFilter(t, Controller{
+		Fsync:          FsyncModeNo,
+		DiskFullPolicy: DiskFullPolicyBuffer,
+		OnDiskFull: func(err error) {
+			alarmed++
+		},
+	})
+	buf := []byte("testing")
+	if err := simulateDiskFull(bf, buf); err != nil {
+		t.Fatal(err)
+	}
+	if alarmed != 1 {
+		t.Fatalf("OnDiskFull should be invoked once, got %v", alarmed)
+	}
+	if !bf.Exist(buf) {
+		t.Fatal("Should exist in filter but got false")
+	}
+	bf.file.mu.Lock()
+	bf.flushPendingLocked()
+	bf.file.mu.Unlock()
+	if len(bf.pending) != 0 {
+		t.Fatal("Buffered bytes should be flushed")
+	}
+	if !bf.Exist(buf) {
+		t.Fatal("Should exist in filter after flushing but got false")
+	}
+}
+
+func TestDiskFullPolicyReadOnly(t *testing.T) {
+	bf := newTestFilter(t, Controller{
+		Fsync:          FsyncModeNo,
+		DiskFullPolicy: DiskFullPolicyReadOnly,
+	})
+	if err := simulateDiskFull(bf, []byte("testing")); !errors.Is(err, syscall.ENOSPC) {
+		t.Fatalf("Should return ENOSPC, got %v", err)
+	}
+	if _, err := bf.ExistOrAddErr([]byte("other")); !errors.Is(err, ReadOnlyErr) {
+		t.Fatalf("Should return ReadOnlyErr, got %v", err)
+	}
+}
+
+func TestDiskFullPolicyBuffer_FsyncModeAlways(t *testing.T) {
+	bf := newTestFilter(t, Controller{
+		Fsync:          FsyncModeAlways,
+		DiskFullPolicy: DiskFullPolicyBuffer,
+	})
+	buf := []byte("testing")
+	if err := simulateDiskFull(bf, buf); !errors.Is(err, syscall.ENOSPC) {
+		t.Fatalf("Buffering is not durable and should be refused, got %v", err)
+	}
+	if bf.Exist(buf) {
+		t.Fatal("Should missing in filter but got true")
+	}
+}
@@ -0,0 +1,316 @@
+package disk_bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+const (
+	diskSetMagic = "DBLMSET2"
+	// diskSetLegacyMagic is the format storing the hashes of the entries, which could not tell colliding entries apart
+	diskSetLegacyMagic  = "DBLMSET1"
+	diskSetRecordHeader = 16
+)
+
+var InvalidDiskSetErr = fmt.Errorf("invalid disk set file")
+
+// Filter is the interface shared by DiskFilter, FilterGroup and DiskSet.
+type Filter interface {
+	// Exist returns if an entry is in the filter
+	Exist(b []byte) bool
+	// ExistOrAdd returns whether the entry was in the filter, and adds an entry to the filter if it was not in.
+	ExistOrAdd(b []byte) bool
+	// Close should be invoked if the filter is not needed anymore
+	Close() error
+}
+
+// DiskSet is an exact disk-based set.
+// Instead of bits, it stores every entry together with the time it was added, so it gives no false positives.
+// It is only suitable for tiny datasets because all entries are kept in memory.
+// A record torn by a crash fails its checksum and is dropped when the set is opened.
+//
+// | magic(8) | crc32c(4) | len of entry(4) | added at(8) | entry | crc32c(4) | len of entry(4) | added at(8) | entry | ...
+type DiskSet struct {
+	file     muFile
+	filename string
+	mode     int
+	ttl      time.Duration
+	clock    Clock
+	entries  map[string]int64
+	// size is the offset where the next record is appended
+	size int64
+	// use this channel to inform the sync goroutine
+	closed chan struct{}
+	tasks  *taskGroup
+}
+
+// NewSet creates an exact DiskSet.
+// Entries older than ttl are regarded as not existing. Zero ttl means entries never expire.
+func NewSet(filename string, fsync FsyncMode, ttl time.Duration) (*DiskSet, error) {
+	return NewSetWithClock(filename, fsync, ttl, SystemClock)
+}
+
+// NewSetWithClock is like NewSet, but the expiration is driven by the given clock.
+func NewSetWithClock(filename string, fsync FsyncMode, ttl time.Duration, clock Clock) (*DiskSet, error) {
+	if !writable {
+		return nil, fmt.Errorf("%w: writing sets on %v", UnsupportedErr, runtime.GOOS)
+	}
+	mode := os.O_CREATE | os.O_RDWR
+	if fsync == FsyncModeAlways {
+		mode |= os.O_SYNC
+	}
+	f, err := os.OpenFile(filename, mode, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s := &DiskSet{
+		file:     muFile{f: f, fsync: fsync},
+		filename: filename,
+		mode:     mode,
+		ttl:      ttl,
+		clock:    clock,
+		entries:  make(map[string]int64),
+		closed:   make(chan struct{}),
+	}
+	if err = s.load(); err != nil {
+		_ = s.file.f.Close()
+		return nil, err
+	}
+	s.tasks = newTaskGroup(s.closed)
+	if fsync == FsyncModeEverySec {
+		s.tasks.start("sync", s.syncEverySec)
+	}
+	return s, nil
+}
+
+// load reads all records into memory and rewrites the file without the expired ones.
+func (s *DiskSet) load() error {
+	b, err := io.ReadAll(s.file.f)
+	if err != nil {
+		return err
+	}
+	if len(b) == 0 {
+		s.size = int64(len(diskSetMagic))
+		_, err = retryStorage{s.file.f}.WriteAt([]byte(diskSetMagic), 0)
+		return err
+	}
+	if len(b) >= len(diskSetLegacyMagic) && string(b[:len(diskSetLegacyMagic)]) == diskSetLegacyMagic {
+		return fmt.Errorf("%w: the set stores the hashes of the entries instead of the entries, rebuild it", InvalidDiskSetErr)
+	}
+	if len(b) < len(diskSetMagic) || string(b[:len(diskSetMagic)]) != diskSetMagic {
+		return InvalidDiskSetErr
+	}
+	expired := false
+	i := len(diskSetMagic)
+	for {
+		key, addedAt, n := parseRecord(b[i:])
+		if n == 0 {
+			break
+		}
+		i += n
+		if s.expired(addedAt) {
+			delete(s.entries, key)
+			expired = true
+			continue
+		}
+		s.entries[key] = addedAt
+	}
+	s.size = int64(i)
+	if expired {
+		return s.rewrite()
+	}
+	if i < len(b) {
+		// a torn record at the tail is dropped
+		return s.file.f.Truncate(s.size)
+	}
+	return nil
+}
+
+// rewrite compacts the file to the entries in memory. The entries are written aside, synced and renamed over the file,
+// so that a crash leaves either the old file or the new one.
+func (s *DiskSet) rewrite() (err error) {
+	buf := []byte(diskSetMagic)
+	for key, addedAt := range s.entries {
+		buf = appendRecord(buf, key, addedAt)
+	}
+	tmp := s.filename + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = retryStorage{f}.WriteAt(buf, 0)
+	if err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp, s.filename)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	syncDir(filepath.Dir(s.filename))
+	if f, err = os.OpenFile(s.filename, s.mode, 0644); err != nil {
+		return err
+	}
+	_ = s.file.f.Close()
+	s.file.f = f
+	s.size = int64(len(buf))
+	return nil
+}
+
+// syncDir syncs the directory so that a rename in it is durable. Not every platform supports it, which is ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
+
+func appendRecord(buf []byte, key string, addedAt int64) []byte {
+	var h [diskSetRecordHeader]byte
+	binary.LittleEndian.PutUint32(h[4:], uint32(len(key)))
+	binary.LittleEndian.PutUint64(h[8:], uint64(addedAt))
+	crc := crc32.Update(crc32.Checksum(h[4:], castagnoli), castagnoli, []byte(key))
+	binary.LittleEndian.PutUint32(h[:], crc)
+	buf = append(buf, h[:]...)
+	return append(buf, key...)
+}
+
+// parseRecord parses the record at the head of b. It returns zero n if b does not start with an intact record.
+func parseRecord(b []byte) (key string, addedAt int64, n int) {
+	if len(b) < diskSetRecordHeader {
+		return "", 0, 0
+	}
+	size := binary.LittleEndian.Uint32(b[4:])
+	if uint64(size) > uint64(len(b)-diskSetRecordHeader) {
+		return "", 0, 0
+	}
+	n = diskSetRecordHeader + int(size)
+	if crc32.Checksum(b[4:n], castagnoli) != binary.LittleEndian.Uint32(b) {
+		return "", 0, 0
+	}
+	return string(b[diskSetRecordHeader:n]), int64(binary.LittleEndian.Uint64(b[8:])), n
+}
+
+func (s *DiskSet) expired(addedAt int64) bool {
+	return s.ttl > 0 && s.clock.Now().UnixNano()-addedAt >= int64(s.ttl)
+}
+
+// Close should be invoked if the set is not needed anymore
+func (s *DiskSet) Close() error {
+	select {
+	case <-s.closed:
+		return nil
+	default:
+	}
+	close(s.closed)
+	s.tasks.wait()
+	s.file.mu.Lock()
+	defer s.file.mu.Unlock()
+	if s.file.fsync != FsyncModeAlways && s.file.modified {
+		s.file.modified = false
+		_ = s.file.f.Sync()
+	}
+	_ = s.file.f.Close()
+	return nil
+}
+
+func (s *DiskSet) syncEverySec(t *task) {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done():
+			return
+		case <-ticker.C:
+		}
+		s.file.mu.Lock()
+		if s.file.modified {
+			s.file.modified = false
+			_ = t.report(s.file.f.Sync())
+		}
+		s.file.mu.Unlock()
+	}
+}
+
+// Tasks returns the state of the background tasks of the set, i.e. the sync every second, with their last errors.
+func (s *DiskSet) Tasks() []TaskStats {
+	return s.tasks.stats()
+}
+
+// Exist returns if an entry is in the set
+func (s *DiskSet) Exist(b []byte) bool {
+	s.file.mu.Lock()
+	defer s.file.mu.Unlock()
+	addedAt, ok := s.entries[string(b)]
+	return ok && !s.expired(addedAt)
+}
+
+// ExistOrAdd returns whether the entry was in the set, and adds an entry to the set if it was not in.
+func (s *DiskSet) ExistOrAdd(b []byte) (exist bool) {
+	exist, _ = s.ExistOrAddErr(b)
+	return exist
+}
+
+// ExistOrAddErr is like ExistOrAdd, but returns the error if the entry failed to be written,
+// in which case the entry is not added.
+func (s *DiskSet) ExistOrAddErr(b []byte) (exist bool, err error) {
+	key := string(b)
+	s.file.mu.Lock()
+	defer s.file.mu.Unlock()
+	addedAt, ok := s.entries[key]
+	if ok && !s.expired(addedAt) {
+		return true, nil
+	}
+	now := s.clock.Now().UnixNano()
+	record := appendRecord(nil, key, now)
+	if _, err = (retryStorage{s.file.f}).WriteAt(record, s.size); err != nil {
+		// the torn record, if any, is overwritten by the next one, or dropped by its checksum
+		return false, err
+	}
+	s.entries[key] = now
+	s.size += int64(len(record))
+	s.file.modified = true
+	return false, nil
+}
+
+// Len returns the number of entries in the set, including the expired ones not yet compacted.
+func (s *DiskSet) Len() int {
+	s.file.mu.Lock()
+	defer s.file.mu.Unlock()
+	return len(s.entries)
+}
+
+// NewFilter returns a DiskSet if n is not greater than exactThreshold, and a DiskFilter otherwise.
+// Both of them answer through the same Filter interface, but the DiskSet gives no false positives.
+// n is the expected number of entries.
+// p is the expected false positive rate.
+func NewFilter(filename string, fsync FsyncMode, n uint64, p float64, exactThreshold uint64, hash func([]byte) (uint64, uint64)) (Filter, error) {
+	if n <= exactThreshold {
+		return NewSet(filename, fsync, 0)
+	}
+	return New(filename, Controller{
+		Fsync:        fsync,
+		MetadataSize: 0,
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			slots, bits := OptimalParam(n, p)
+			return FilterParam{
+				Slots: slots,
+				Bits:  bits,
+				Hash:  hash,
+			}, nil
+		},
+	})
+}
@@ -0,0 +1,211 @@
+package disk_bloom
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDiskSet_ExistOrAdd(t *testing.T) {
+	s, err := NewSet("testfile", FsyncModeEverySec, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		os.Remove("testfile")
+	}()
+	buf := []byte("testing")
+	if s.ExistOrAdd(buf) {
+		t.Fatal("Should missing in set but got true")
+	}
+	if !s.ExistOrAdd(buf) {
+		t.Fatal("Should exist in set but got false")
+	}
+	if s.Exist([]byte("not-exists")) {
+		t.Fatal("Should missing in set but got true")
+	}
+	s.Close()
+
+	// reopen
+	s, err = NewSet("testfile", FsyncModeEverySec, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if !s.Exist(buf) {
+		t.Fatal("Should exist in set after reopening but got false")
+	}
+}
+
+func TestDiskSet_TTL(t *testing.T) {
+	s, err := NewSet("testfile", FsyncModeNo, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		os.Remove("testfile")
+	}()
+	buf := []byte("testing")
+	s.ExistOrAdd(buf)
+	if !s.Exist(buf) {
+		t.Fatal("Should exist in set but got false")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if s.Exist(buf) {
+		t.Fatal("Should expire but got true")
+	}
+	s.Close()
+	s, err = NewSet("testfile", FsyncModeNo, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.Len() != 0 {
+		t.Fatalf("Expired entries should be compacted, got %v", s.Len())
+	}
+}
+
+func TestDiskSet_Clock(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	s, err := NewSetWithClock("testfile", FsyncModeNo, time.Hour, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		s.Close()
+		os.Remove("testfile")
+	}()
+	buf := []byte("testing")
+	s.ExistOrAdd(buf)
+	clock.Advance(time.Hour - 1)
+	if !s.Exist(buf) {
+		t.Fatal("Should exist in set but got false")
+	}
+	clock.Advance(1)
+	if s.Exist(buf) {
+		t.Fatal("Should expire but got true")
+	}
+}
+
+func TestNewFilter(t *testing.T) {
+	f, err := NewFilter("testfile", FsyncModeNo, 100, 1e-4, 1000, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		os.Remove("testfile")
+	}()
+	defer f.Close()
+	if _, ok := f.(*DiskSet); !ok {
+		t.Fatalf("Should be a DiskSet but got %T", f)
+	}
+}
+
+func TestDiskSet_Exact(t *testing.T) {
+	s, err := NewSet("testfile", FsyncModeNo, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		s.Close()
+		os.Remove("testfile")
+	}()
+	// the entries are stored as they are, so no two of them are mistaken for each other
+	for i := 0; i < 1000; i++ {
+		if s.ExistOrAdd([]byte{byte(i), byte(i >> 8)}) {
+			t.Fatalf("%v should be missing in set but got true", i)
+		}
+	}
+	if s.Exist([]byte{0}) || s.Exist(nil) {
+		t.Fatal("Should missing in set but got true")
+	}
+}
+
+func TestDiskSet_TornRecord(t *testing.T) {
+	s, err := NewSet("testfile", FsyncModeNo, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		os.Remove("testfile")
+	}()
+	s.ExistOrAdd([]byte("a"))
+	s.ExistOrAdd([]byte("bc"))
+	s.Close()
+	// tear the last record
+	info, err := os.Stat("testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Truncate("testfile", info.Size()-1); err != nil {
+		t.Fatal(err)
+	}
+	s, err = NewSet("testfile", FsyncModeNo, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if !s.Exist([]byte("a")) || s.Exist([]byte("bc")) || s.Len() != 1 {
+		t.Fatal("Should drop the torn record only")
+	}
+	if s.ExistOrAdd([]byte("bc")) {
+		t.Fatal("Should missing in set but got true")
+	}
+}
+
+func TestDiskSet_Rewrite(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	s, err := NewSetWithClock("testfile", FsyncModeNo, time.Hour, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		os.Remove("testfile")
+	}()
+	s.ExistOrAdd([]byte("old"))
+	clock.Advance(time.Hour)
+	s.ExistOrAdd([]byte("new"))
+	s.Close()
+	s, err = NewSetWithClock("testfile", FsyncModeNo, time.Hour, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat("testfile.tmp"); !os.IsNotExist(err) {
+		t.Fatalf("Should rename the compacted file, got %v", err)
+	}
+	// appended to the compacted file
+	s.ExistOrAdd([]byte("newer"))
+	s.Close()
+	s, err = NewSetWithClock("testfile", FsyncModeNo, time.Hour, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.Len() != 2 || !s.Exist([]byte("new")) || !s.Exist([]byte("newer")) || s.Exist([]byte("old")) {
+		t.Fatalf("Should keep the entries not expired, got %v", s.Len())
+	}
+}
+
+func TestDiskSet_WriteFailed(t *testing.T) {
+	s, err := NewSet("testfile", FsyncModeNo, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		os.Remove("testfile")
+	}()
+	defer s.Close()
+	f, err := os.Open("testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a read-only handle fails the writes
+	s.file.f.Close()
+	s.file.f = f
+	if _, err = s.ExistOrAddErr([]byte("a")); err == nil {
+		t.Fatal("Should fail to write")
+	}
+	if s.Exist([]byte("a")) || s.Len() != 0 {
+		t.Fatal("Should not keep the entry failed to be written")
+	}
+}
@@ -0,0 +1,107 @@
+// Package diskbloomtest provides helpers for testing code that depends on disk_bloom.
+package diskbloomtest
+
+import (
+	"sync"
+	"time"
+
+	disk_bloom "github.com/mzz2017/disk-bloom"
+)
+
+// Fake is an in-memory disk_bloom.Filter backed by an exact set.
+// It never gives false positives, so the dedup logic under test behaves deterministically.
+type Fake struct {
+	mu      sync.Mutex
+	set     map[string]struct{}
+	latency time.Duration
+	err     error
+	closed  bool
+}
+
+var _ disk_bloom.Filter = (*Fake)(nil)
+
+// NewFake returns an empty Fake.
+func NewFake() *Fake {
+	return &Fake{set: make(map[string]struct{})}
+}
+
+// SetLatency makes every following operation sleep d before answering.
+func (f *Fake) SetLatency(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.latency = d
+}
+
+// SetErr injects an I/O failure. While err is not nil, the Fake behaves like a DiskFilter on a failing disk:
+// Exist and ExistOrAdd report the entry as absent and nothing is added, and Close returns err.
+// Pass nil to recover.
+func (f *Fake) SetErr(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+// Err returns the injected error.
+func (f *Fake) Err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.err
+}
+
+func (f *Fake) sleep() {
+	f.mu.Lock()
+	d := f.latency
+	f.mu.Unlock()
+	if d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// Exist returns if an entry is in the filter
+func (f *Fake) Exist(b []byte) bool {
+	f.sleep()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return false
+	}
+	_, ok := f.set[string(b)]
+	return ok
+}
+
+// ExistOrAdd returns whether the entry was in the filter, and adds an entry to the filter if it was not in.
+func (f *Fake) ExistOrAdd(b []byte) bool {
+	f.sleep()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return false
+	}
+	if _, ok := f.set[string(b)]; ok {
+		return true
+	}
+	f.set[string(b)] = struct{}{}
+	return false
+}
+
+// Len returns the number of entries added.
+func (f *Fake) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.set)
+}
+
+// Closed returns whether Close has been invoked.
+func (f *Fake) Closed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+// Close marks the Fake as closed and returns the injected error.
+func (f *Fake) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return f.err
+}
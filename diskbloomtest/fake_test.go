@@ -0,0 +1,32 @@
+package diskbloomtest
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFake(t *testing.T) {
+	f := NewFake()
+	buf := []byte("testing")
+	if f.ExistOrAdd(buf) {
+		t.Fatal("Should missing in filter but got true")
+	}
+	if !f.Exist(buf) {
+		t.Fatal("Should exist in filter but got false")
+	}
+	ioErr := errors.New("input/output error")
+	f.SetErr(ioErr)
+	if f.Exist(buf) {
+		t.Fatal("Should missing in a failing filter but got true")
+	}
+	if f.ExistOrAdd([]byte("other")) {
+		t.Fatal("Should missing in a failing filter but got true")
+	}
+	if err := f.Close(); !errors.Is(err, ioErr) {
+		t.Fatalf("Close should return the injected error, got %v", err)
+	}
+	f.SetErr(nil)
+	if f.Len() != 1 {
+		t.Fatalf("Entries should not be added while failing, got %v", f.Len())
+	}
+}
@@ -0,0 +1,46 @@
+package disk_bloom
+
+import "os"
+
+// DurabilityLevel is what a sync of the filter file guarantees, see Controller.Durability.
+// The guarantees are the same on every platform, which differ in the calls providing them.
+type DurabilityLevel uint8
+
+const (
+	// DurabilityLevelFull syncs the data and the metadata of the file through the cache of the drive, so that they survive
+	// a power loss: fsync on Linux, F_FULLFSYNC on macOS and FlushFileBuffers on Windows, which is os.File.Sync.
+	DurabilityLevelFull DurabilityLevel = iota
+	// DurabilityLevelData syncs the data of the file and the metadata needed to read it, like the size, through the cache
+	// of the drive, but not the times of the file: fdatasync on Linux, which saves a write of the inode per sync
+	// since the filter file never grows. It is DurabilityLevelFull on the other platforms, which have nothing cheaper
+	// with the same guarantee: a plain fsync of macOS leaves the data in the cache of the drive.
+	DurabilityLevelData
+)
+
+func (l DurabilityLevel) String() string {
+	switch l {
+	case DurabilityLevelFull:
+		return "full"
+	case DurabilityLevelData:
+		return "data"
+	default:
+		return "unknown"
+	}
+}
+
+// syncDurable syncs the file at the level.
+func syncDurable(f *os.File, level DurabilityLevel) error {
+	if level == DurabilityLevelData {
+		return syncData(f)
+	}
+	return f.Sync()
+}
+
+// sync syncs the file of the filter at the level. The files not on the disk, see OpenFS and NewOnStorage, are synced
+// as they define.
+func (m *muFile) sync(level DurabilityLevel) error {
+	if f := m.osFile(); f != nil {
+		return syncDurable(f, level)
+	}
+	return m.f.Sync()
+}
@@ -0,0 +1,19 @@
+package disk_bloom
+
+import (
+	"os"
+	"syscall"
+)
+
+// syncData syncs the data of the file by fdatasync.
+func syncData(f *os.File) error {
+	for {
+		err := syscall.Fdatasync(int(f.Fd()))
+		if err != syscall.EINTR {
+			if err != nil {
+				return &os.PathError{Op: "fdatasync", Path: f.Name(), Err: err}
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package disk_bloom
+
+import "os"
+
+// syncData syncs the file by os.File.Sync, which is F_FULLFSYNC on macOS and FlushFileBuffers on Windows.
+func syncData(f *os.File) error {
+	return f.Sync()
+}
@@ -0,0 +1,46 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiskFilter_DurabilityLevelData(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "filter")
+	controller := Controller{Fsync: FsyncModeAlways, Durability: DurabilityLevelData, Journal: true, AckRing: 4}
+	bf := openAckFilter(t, filename, controller)
+	for i := uint64(0); i < 10; i++ {
+		if _, err := bf.ExistOrAddSeq([]byte(fmt.Sprint(i)), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	bf = openAckFilter(t, filename, controller)
+	defer bf.Close()
+	for i := 0; i < 10; i++ {
+		if !bf.Exist([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%v should exist", i)
+		}
+	}
+	checkAcked(t, bf, 6, 7, 8, 9)
+}
+
+func TestSyncDurable(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, level := range []DurabilityLevel{DurabilityLevelFull, DurabilityLevelData} {
+		if err = syncDurable(f, level); err != nil {
+			t.Fatalf("%v: %v", level, err)
+		}
+	}
+	_ = f.Close()
+	if err = syncDurable(f, DurabilityLevelData); err == nil {
+		t.Fatal("Should fail to sync a closed file")
+	}
+}
@@ -0,0 +1,140 @@
+package disk_bloom
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+)
+
+var InvalidKeyErr = fmt.Errorf("invalid encryption key")
+
+// encryptedStorage encrypts the metadata and the bloom filter with AES-CTR.
+// The header is kept in plaintext since it carries the nonce.
+// The counter of a byte is derived from its file offset, so any byte can be read and written independently.
+type encryptedStorage struct {
+	storage
+	block cipher.Block
+	nonce [aes.BlockSize]byte
+	// the plaintext region [headerStart, headerEnd)
+	headerStart, headerEnd int64
+}
+
+func newEncryptedStorage(s storage, key []byte, nonce [aes.BlockSize]byte, headerStart, headerEnd int64) (*encryptedStorage, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", InvalidKeyErr, err)
+	}
+	return &encryptedStorage{
+		storage:     s,
+		block:       block,
+		nonce:       nonce,
+		headerStart: headerStart,
+		headerEnd:   headerEnd,
+	}, nil
+}
+
+// newNonce returns a random nonce.
+func newNonce() (nonce [aes.BlockSize]byte, err error) {
+	_, err = rand.Read(nonce[:])
+	return nonce, err
+}
+
+// keyCheck returns a value stored in the header to detect a wrong key at open time.
+func keyCheck(block cipher.Block, nonce [aes.BlockSize]byte) (check [8]byte) {
+	var in, out [aes.BlockSize]byte
+	for i := range in {
+		in[i] = ^nonce[i]
+	}
+	block.Encrypt(out[:], in[:])
+	copy(check[:], out[:])
+	return check
+}
+
+func (s *encryptedStorage) keyCheck() [8]byte {
+	return keyCheck(s.block, s.nonce)
+}
+
+// xor encrypts or decrypts b in place, which is at the given file offset.
+func (s *encryptedStorage) xor(b []byte, offset int64) {
+	for len(b) > 0 {
+		// skip the plaintext header
+		if offset >= s.headerStart && offset < s.headerEnd {
+			n := s.headerEnd - offset
+			if n >= int64(len(b)) {
+				return
+			}
+			b, offset = b[n:], offset+n
+			continue
+		}
+		n := len(b)
+		if offset < s.headerStart && offset+int64(n) > s.headerStart {
+			n = int(s.headerStart - offset)
+		}
+		s.xorRange(b[:n], offset)
+		b, offset = b[n:], offset+int64(n)
+	}
+}
+
+func (s *encryptedStorage) xorRange(b []byte, offset int64) {
+	// iv = nonce + block index
+	var iv [aes.BlockSize]byte
+	copy(iv[:], s.nonce[:])
+	index := uint64(offset) / aes.BlockSize
+	lo := binary.BigEndian.Uint64(iv[8:])
+	hi := binary.BigEndian.Uint64(iv[:8])
+	if lo+index < lo {
+		hi++
+	}
+	binary.BigEndian.PutUint64(iv[8:], lo+index)
+	binary.BigEndian.PutUint64(iv[:8], hi)
+	stream := cipher.NewCTR(s.block, iv[:])
+	if skip := offset % aes.BlockSize; skip > 0 {
+		var discard [aes.BlockSize]byte
+		stream.XORKeyStream(discard[:skip], discard[:skip])
+	}
+	stream.XORKeyStream(b, b)
+}
+
+func (s *encryptedStorage) ReadAt(b []byte, offset int64) (int, error) {
+	n, err := s.storage.ReadAt(b, offset)
+	s.xor(b[:n], offset)
+	return n, err
+}
+
+func (s *encryptedStorage) WriteAt(b []byte, offset int64) (int, error) {
+	buf := make([]byte, len(b))
+	copy(buf, b)
+	s.xor(buf, offset)
+	return s.storage.WriteAt(buf, offset)
+}
+
+// zero writes encrypted zeros to [offset, offset+size), which is needed since zeros on the disk are not zeros after decryption.
+func (s *encryptedStorage) zero(offset int64, size int64) error {
+	buf := make([]byte, 1<<16)
+	for size > 0 {
+		n := int64(len(buf))
+		if n > size {
+			n = size
+		}
+		for i := range buf[:n] {
+			buf[i] = 0
+		}
+		if _, err := s.WriteAt(buf[:n], offset); err != nil {
+			return err
+		}
+		offset, size = offset+n, size-n
+	}
+	return nil
+}
+
+// checkKey verifies the key against the header.
+func (s *encryptedStorage) checkKey(h Header) error {
+	check := s.keyCheck()
+	if subtle.ConstantTimeCompare(check[:], h.keyCheck[:]) != 1 {
+		return fmt.Errorf("%w: key check mismatch", InvalidKeyErr)
+	}
+	return nil
+}
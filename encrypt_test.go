@@ -0,0 +1,69 @@
+package disk_bloom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestDiskFilter_Encryption(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	controller := Controller{
+		Fsync:         FsyncModeNo,
+		MetadataSize:  8,
+		EncryptionKey: key,
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			slots, bits := OptimalParam(1e4, 1e-4)
+			if metadata != nil && binary.LittleEndian.Uint64(metadata) != 42 {
+				t.Fatalf("Unexpected metadata %x", metadata)
+			}
+			updated := make([]byte, 8)
+			binary.LittleEndian.PutUint64(updated, 42)
+			return FilterParam{Slots: slots, Bits: bits, Hash: doubleFNV}, updated
+		},
+	}
+	bf, err := New("testfile", controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		os.Remove("testfile")
+	}()
+	buf := []byte("testing")
+	bf.ExistOrAdd(buf)
+	if bf.Exist([]byte("not-exists")) {
+		t.Fatal("Should missing in filter but got true")
+	}
+	bf.Close()
+
+	raw, err := os.ReadFile("testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zeros := bytes.Count(raw[bf.fileOffset(0):], []byte{0}); zeros > len(raw)/100 {
+		t.Fatalf("The bloom filter should look random on the disk, got %v zeros", zeros)
+	}
+
+	bf, err = New("testfile", controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bf.Exist(buf) {
+		t.Fatal("Should exist in filter but got false")
+	}
+	if bf.Exist([]byte("not-exists")) {
+		t.Fatal("Should missing in filter but got true")
+	}
+	bf.Close()
+
+	controller.EncryptionKey = bytes.Repeat([]byte{2}, 32)
+	if _, err = New("testfile", controller); !errors.Is(err, InvalidKeyErr) {
+		t.Fatalf("Should return InvalidKeyErr with a wrong key, got %v", err)
+	}
+	controller.EncryptionKey = nil
+	if _, err = New("testfile", controller); !errors.Is(err, InvalidKeyErr) {
+		t.Fatalf("Should return InvalidKeyErr without a key, got %v", err)
+	}
+}
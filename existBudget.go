@@ -0,0 +1,214 @@
+package disk_bloom
+
+import "sort"
+
+// Answer is the answer of ExistMultiBudget about a key.
+type Answer uint8
+
+const (
+	// AnswerUnknown means the key is not answered within the I/O budget.
+	AnswerUnknown Answer = iota
+	// AnswerAbsent means the key is not in the filter.
+	AnswerAbsent
+	// AnswerPresent means the key is in the filter, or is a false positive.
+	AnswerPresent
+)
+
+func (a Answer) String() string {
+	switch a {
+	case AnswerUnknown:
+		return "unknown"
+	case AnswerAbsent:
+		return "absent"
+	case AnswerPresent:
+		return "present"
+	default:
+		return "invalid"
+	}
+}
+
+// ExistMultiBudget is like ExistBatch, but reads at most maxIO pages from the storage, and answers AnswerUnknown
+// for the keys whose probed pages are not read within the budget, e.g. for best-effort dedup in latency-critical paths
+// while the disk is degraded. The pages pinned, mapped, in the BlockCache or read for another key take no budget,
+// and a key is AnswerAbsent as soon as a probed bit is zero, so the keys probing the fewest pages out of memory
+// are answered first. The keys whose pages fail to be read are AnswerUnknown.
+func (f *DiskFilter) ExistMultiBudget(keys [][]byte, maxIO int) []Answer {
+	answers := make([]Answer, len(keys))
+	if !f.acquire() {
+		return answers
+	}
+	defer f.release()
+	if f.lockFree {
+		for k, key := range keys {
+			exist := f.existMapped(f.Hash(key))
+			answers[k] = answerOf(exist)
+			f.countLookup(exist)
+		}
+		return answers
+	}
+	slots := f.lookupSlots()
+	offsets := make([][]uint64, len(keys))
+	for k, key := range keys {
+		offsets[k] = f.offsets(f.Hash(key), slots)
+	}
+	var batch uint64
+	f.phase("io", func() {
+		f.rlock()
+		defer f.file.mu.RUnlock()
+		r := budgetReader{f: f, pages: make(map[int64][]byte), budget: maxIO}
+		// the keys probing the fewest pages out of memory first
+		order := make([]int, len(keys))
+		missing := make([]int, len(keys))
+		for k := range keys {
+			order[k] = k
+			missing[k] = r.missing(offsets[k])
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return missing[order[i]] < missing[order[j]]
+		})
+		for _, k := range order {
+			answers[k] = r.exist(offsets[k])
+			if answers[k] != AnswerPresent {
+				continue
+			}
+			for _, offset := range offsets[k] {
+				if b := f.unsynced[f.fileOffset(int64(offset/8))]; b > batch {
+					batch = b
+				}
+			}
+		}
+	})
+	if batch > 0 && f.commit.wait(batch) != nil {
+		// do not report an entry before its bits are durable, nor the ones whose bits failed to be synced
+		f.rlock()
+		for k := range keys {
+			if answers[k] == AnswerPresent && f.pendingLocked(offsets[k]) {
+				answers[k] = AnswerAbsent
+			}
+		}
+		f.file.mu.RUnlock()
+	}
+	for _, a := range answers {
+		if a != AnswerUnknown {
+			f.countLookup(a == AnswerPresent)
+		}
+	}
+	return answers
+}
+
+func answerOf(exist bool) Answer {
+	if exist {
+		return AnswerPresent
+	}
+	return AnswerAbsent
+}
+
+// budgetReader reads the probed pages of ExistMultiBudget within the budget.
+type budgetReader struct {
+	f *DiskFilter
+	// pages are the pages read, by the page number
+	pages  map[int64][]byte
+	budget int
+}
+
+// inMemory returns whether the byte at pos is read without I/O.
+func (r *budgetReader) inMemory(pos int64) bool {
+	f := r.f
+	if f.pinned.contains(pos) || f.mapped != nil {
+		return true
+	}
+	if _, ok := r.pages[pos/pageSize]; ok {
+		return true
+	}
+	return f.cache != nil && f.cache.cached(pos/pageSize)
+}
+
+// missing returns the number of distinct pages of the sorted offsets which are not in memory.
+func (r *budgetReader) missing(offsets []uint64) (n int) {
+	last := int64(-1)
+	for _, offset := range offsets {
+		pos := r.f.fileOffset(int64(offset / 8))
+		if page := pos / pageSize; page != last && !r.inMemory(pos) {
+			n++
+			last = page
+		}
+	}
+	return n
+}
+
+// exist answers whether the bits at the sorted offsets are all set, probing the bytes in memory first.
+func (r *budgetReader) exist(offsets []uint64) Answer {
+	f := r.f
+	var deferred []uint64
+	for _, offset := range offsets {
+		pos := f.fileOffset(int64(offset / 8))
+		if !r.inMemory(pos) {
+			deferred = append(deferred, offset)
+			continue
+		}
+		val, ok := r.readByte(pos)
+		if !ok {
+			return AnswerUnknown
+		}
+		if val&(1<<(offset%8)) == 0 {
+			return AnswerAbsent
+		}
+	}
+	for _, offset := range deferred {
+		pos := f.fileOffset(int64(offset / 8))
+		if !r.inMemory(pos) {
+			if r.budget <= 0 || !r.fetch(pos/pageSize) {
+				return AnswerUnknown
+			}
+			r.budget--
+		}
+		val, ok := r.readByte(pos)
+		if !ok {
+			return AnswerUnknown
+		}
+		if val&(1<<(offset%8)) == 0 {
+			return AnswerAbsent
+		}
+	}
+	return AnswerPresent
+}
+
+// readByte reads the byte at pos in memory, including the bits buffered.
+func (r *budgetReader) readByte(pos int64) (byte, bool) {
+	f := r.f
+	var val byte
+	if f.pinned.contains(pos) {
+		val = f.pinned.buf[pos-f.pinned.start]
+	} else if page, ok := r.pages[pos/pageSize]; ok {
+		start := pos / pageSize * pageSize
+		if start < f.bloomStart {
+			start = f.bloomStart
+		}
+		val = page[pos-start]
+	} else {
+		var b [1]byte
+		if _, err := f.file.rw.ReadAt(b[:], pos); err != nil {
+			return 0, false
+		}
+		val = b[0]
+	}
+	return val | f.pending[pos], true
+}
+
+// fetch reads the page clipped to the bloom filter, and returns whether it succeeds.
+func (r *budgetReader) fetch(page int64) bool {
+	f := r.f
+	start, end := page*pageSize, (page+1)*pageSize
+	if start < f.bloomStart {
+		start = f.bloomStart
+	}
+	if bloomEnd := f.bloomStart + f.header.bloomSize(f.param.Bits); end > bloomEnd {
+		end = bloomEnd
+	}
+	b := make([]byte, end-start)
+	if _, err := f.file.rw.ReadAt(b, start); err != nil {
+		return false
+	}
+	r.pages[page] = b
+	return true
+}
@@ -0,0 +1,71 @@
+package disk_bloom
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestDiskFilter_ExistMultiBudget(t *testing.T) {
+	defer os.Remove("testfile")
+	bf, err := New("testfile", Controller{Debug: true, GetParam: func([]byte) (FilterParam, []byte) {
+		// the probes of a key spread over many pages
+		return FilterParam{Slots: 4, Bits: 1 << 24, Hash: doubleFNV}, nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bf.Close()
+	keys := make([][]byte, 100)
+	for i := range keys {
+		keys[i] = []byte(strconv.Itoa(i))
+		if i%2 == 0 {
+			bf.ExistOrAdd(keys[i])
+		}
+	}
+	// unlimited
+	before := bf.DebugStats().BytesRead
+	answers := bf.ExistMultiBudget(keys, len(keys)*4)
+	for i, a := range answers {
+		if want := answerOf(i%2 == 0); a != want {
+			t.Fatalf("%v: got %v, want %v", i, a, want)
+		}
+	}
+	if read := bf.DebugStats().BytesRead - before; read > uint64(len(keys)*4*pageSize) {
+		t.Fatalf("Should read at most a page per probe, read %v bytes", read)
+	}
+
+	// limited
+	before = bf.DebugStats().BytesRead
+	answers = bf.ExistMultiBudget(keys, 10)
+	if read := bf.DebugStats().BytesRead - before; read > 10*pageSize {
+		t.Fatalf("Should read at most 10 pages, read %v bytes", read)
+	}
+	unknown := 0
+	for i, a := range answers {
+		if a == AnswerUnknown {
+			unknown++
+		} else if want := answerOf(i%2 == 0); a != want {
+			t.Fatalf("%v: got %v, want %v", i, a, want)
+		}
+	}
+	if unknown == 0 || unknown == len(keys) {
+		t.Fatalf("Should answer some of the keys, %v unknown", unknown)
+	}
+	if answers := bf.ExistMultiBudget(keys, 0); answers[0] != AnswerUnknown {
+		t.Fatalf("Should not read without budget, got %v", answers[0])
+	}
+}
+
+func TestDiskFilter_ExistMultiBudgetPinned(t *testing.T) {
+	bf := newTestFilter(t, Controller{})
+	bf.ExistOrAdd([]byte("testing"))
+	if err := bf.Pin(Range{Offset: 0, Length: bf.Size()}); err != nil {
+		t.Fatal(err)
+	}
+	// the pinned pages take no budget
+	answers := bf.ExistMultiBudget([][]byte{[]byte("testing"), []byte("absent")}, 0)
+	if answers[0] != AnswerPresent || answers[1] != AnswerAbsent {
+		t.Fatalf("Unexpected answers: %v", answers)
+	}
+}
@@ -0,0 +1,75 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Probe is a probe of an entry in the bloom filter.
+type Probe struct {
+	// Offset is the bit offset in the bloom filter
+	Offset uint64
+	// Position is the file offset of the byte containing the bit
+	Position int64
+	// Byte is the byte read, including the bits buffered in memory
+	Byte byte
+	// Matched reports whether the bit is set
+	Matched bool
+}
+
+// Explanation describes how Exist answers for an entry.
+type Explanation struct {
+	Hash KeyHash
+	// Probes are in the order of the hashes
+	Probes []Probe
+	// Exist is true if all probes matched
+	Exist bool
+}
+
+func (e Explanation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "hash: %016x %016x, exist: %v\n", e.Hash.X, e.Hash.Y, e.Exist)
+	for i, p := range e.Probes {
+		fmt.Fprintf(&b, "  #%v offset: %v, position: %v, byte: %08b, matched: %v\n", i, p.Offset, p.Position, p.Byte, p.Matched)
+	}
+	return b.String()
+}
+
+// ExplainExist is like Exist, but returns all probes of the entry instead of stopping at the first missing bit.
+// It helps to debug surprising positives, or to verify that two deployments compute identical offsets.
+func (f *DiskFilter) ExplainExist(b []byte) Explanation {
+	return f.ExplainExistHashed(f.Hash(b))
+}
+
+// ExplainExistHashed is like ExplainExist, but takes the hash of the entry.
+func (f *DiskFilter) ExplainExistHashed(h KeyHash) Explanation {
+	if !f.acquire() {
+		return Explanation{Hash: h}
+	}
+	defer f.release()
+	slots := f.lookupSlots()
+	e := Explanation{
+		Hash:   h,
+		Probes: make([]Probe, slots),
+		Exist:  true,
+	}
+	offsets := make([]uint64, slots)
+	for i := range offsets {
+		offsets[i] = f.bloomOffset(h.X, h.Y, i)
+	}
+	r := pageReader{f: f, positions: f.probePositions(offsets)}
+	f.file.mu.RLock()
+	defer f.file.mu.RUnlock()
+	for i, offset := range offsets {
+		val := r.readByte(i)
+		matched := val&(1<<(offset%8)) != 0
+		e.Probes[i] = Probe{
+			Offset:   offset,
+			Position: r.positions[i],
+			Byte:     val,
+			Matched:  matched,
+		}
+		e.Exist = e.Exist && matched
+	}
+	return e
+}
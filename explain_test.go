@@ -0,0 +1,33 @@
+package disk_bloom
+
+import (
+	"testing"
+)
+
+func TestDiskFilter_ExplainExist(t *testing.T) {
+	bf := newTestFilter(t, Controller{})
+	buf := []byte("testing")
+	if e := bf.ExplainExist(buf); e.Exist || len(e.Probes) != int(bf.FilterParam().Slots) {
+		t.Fatalf("Should be missing with all probes, got %v", e)
+	}
+	bf.ExistOrAdd(buf)
+	e := bf.ExplainExist(buf)
+	if !e.Exist {
+		t.Fatalf("Should exist, got %v", e)
+	}
+	x, y := doubleFNV(buf)
+	if e.Hash != (KeyHash{X: x, Y: y}) {
+		t.Fatalf("Unexpected hash %v", e.Hash)
+	}
+	for i, p := range e.Probes {
+		if offset := (x + uint64(i)*y) % bf.FilterParam().Bits; p.Offset != offset || p.Position != bf.fileOffset(int64(offset/8)) {
+			t.Fatalf("Unexpected probe #%v: %+v", i, p)
+		}
+		if !p.Matched || p.Byte&(1<<(p.Offset%8)) == 0 {
+			t.Fatalf("Probe #%v should match: %+v", i, p)
+		}
+	}
+	if e := bf.ExplainExist([]byte("not-exists")); e.Exist != bf.Exist([]byte("not-exists")) {
+		t.Fatalf("Should agree with Exist, got %v", e)
+	}
+}
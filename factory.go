@@ -0,0 +1,176 @@
+package disk_bloom
+
+import (
+	"container/list"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var InvalidTenantErr = fmt.Errorf("invalid tenant")
+
+// Factory opens a filter per tenant on demand under a root directory, e.g. for a SaaS with thousands of tenants.
+// The filters share the controller, so a MemoryBudget in it bounds the block caches and buffers of all of them,
+// and at most maxOpen of them are open at once: the least recently used idle tenants are closed beyond it,
+// which bounds the file descriptors and the background goroutines.
+type Factory struct {
+	root       string
+	controller Controller
+	maxOpen    int
+
+	// mu guards the fields below, and is held while opening a filter
+	mu      sync.Mutex
+	lru     *list.List
+	tenants map[string]*list.Element
+	closed  bool
+}
+
+type tenantFilter struct {
+	name   string
+	filter *DiskFilter
+	// refs is the number of Do in flight, and the filter is closed only if it is 0
+	refs int
+}
+
+// NewFactory returns a Factory of the filters under root, which is created if missing.
+// Every filter is opened with the controller, whose GetParam gives the parameters of new tenants.
+// maxOpen is the number of open filters kept, which is unlimited if it is not positive.
+func NewFactory(root string, controller Controller, maxOpen int) (*Factory, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	return &Factory{
+		root:       root,
+		controller: controller,
+		maxOpen:    maxOpen,
+		lru:        list.New(),
+		tenants:    make(map[string]*list.Element),
+	}, nil
+}
+
+// Do invokes fn with the filter of the tenant, creating or opening it if it is not open.
+// The filter is not closed until fn returns, and fn must not keep it afterwards.
+// A tenant is a file name under the root, so it must not contain path separators.
+func (fa *Factory) Do(tenant string, fn func(f *DiskFilter) error) error {
+	t, err := fa.acquire(tenant)
+	if err != nil {
+		return err
+	}
+	defer fa.release(t)
+	return fn(t.filter)
+}
+
+// Exist returns if an entry is in the filter of the tenant.
+func (fa *Factory) Exist(tenant string, b []byte) (exist bool, err error) {
+	err = fa.Do(tenant, func(f *DiskFilter) error {
+		exist = f.Exist(b)
+		return nil
+	})
+	return exist, err
+}
+
+// ExistOrAdd returns whether the entry was in the filter of the tenant, and adds it if it was not in.
+func (fa *Factory) ExistOrAdd(tenant string, b []byte) (exist bool, err error) {
+	err = fa.Do(tenant, func(f *DiskFilter) (err error) {
+		exist, err = f.ExistOrAddErr(b)
+		return err
+	})
+	return exist, err
+}
+
+// Open returns the tenants open, the most recently used first.
+func (fa *Factory) Open() []string {
+	fa.mu.Lock()
+	defer fa.mu.Unlock()
+	tenants := make([]string, 0, fa.lru.Len())
+	for e := fa.lru.Front(); e != nil; e = e.Next() {
+		tenants = append(tenants, e.Value.(*tenantFilter).name)
+	}
+	return tenants
+}
+
+// MetricsHandler returns the MetricsHandler of the filters open at each request, labeled by their filenames.
+func (fa *Factory) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ts []*tenantFilter
+		fa.mu.Lock()
+		for e := fa.lru.Front(); e != nil; e = e.Next() {
+			t := e.Value.(*tenantFilter)
+			t.refs++
+			ts = append(ts, t)
+		}
+		fa.mu.Unlock()
+		filters := make([]*DiskFilter, len(ts))
+		for i, t := range ts {
+			filters[i] = t.filter
+		}
+		MetricsHandler(filters...).ServeHTTP(w, r)
+		for _, t := range ts {
+			fa.release(t)
+		}
+	})
+}
+
+// Close closes the filters of all tenants, and returns the first error. Do fails with ClosedErr afterwards.
+func (fa *Factory) Close() error {
+	fa.mu.Lock()
+	defer fa.mu.Unlock()
+	fa.closed = true
+	var err error
+	for e := fa.lru.Front(); e != nil; e = e.Next() {
+		if e := e.Value.(*tenantFilter).filter.Close(); err == nil {
+			err = e
+		}
+	}
+	fa.lru.Init()
+	fa.tenants = make(map[string]*list.Element)
+	return err
+}
+
+func (fa *Factory) acquire(tenant string) (*tenantFilter, error) {
+	if tenant == "" || tenant == "." || tenant == ".." || strings.ContainsAny(tenant, `/\`) {
+		return nil, fmt.Errorf("%w: %q", InvalidTenantErr, tenant)
+	}
+	fa.mu.Lock()
+	defer fa.mu.Unlock()
+	if fa.closed {
+		return nil, ClosedErr
+	}
+	if e, ok := fa.tenants[tenant]; ok {
+		fa.lru.MoveToFront(e)
+		t := e.Value.(*tenantFilter)
+		t.refs++
+		return t, nil
+	}
+	filter, err := New(filepath.Join(fa.root, tenant), fa.controller)
+	if err != nil {
+		return nil, err
+	}
+	t := &tenantFilter{name: tenant, filter: filter, refs: 1}
+	fa.tenants[tenant] = fa.lru.PushFront(t)
+	fa.evictLocked()
+	return t, nil
+}
+
+func (fa *Factory) release(t *tenantFilter) {
+	fa.mu.Lock()
+	defer fa.mu.Unlock()
+	t.refs--
+	fa.evictLocked()
+}
+
+// evictLocked closes the least recently used idle tenants beyond maxOpen.
+func (fa *Factory) evictLocked() {
+	for e := fa.lru.Back(); e != nil && fa.maxOpen > 0 && fa.lru.Len() > fa.maxOpen; {
+		prev := e.Prev()
+		if t := e.Value.(*tenantFilter); t.refs == 0 {
+			fa.lru.Remove(e)
+			delete(fa.tenants, t.name)
+			_ = t.filter.Close()
+		}
+		e = prev
+	}
+}
@@ -0,0 +1,87 @@
+package disk_bloom
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFactory(t *testing.T) {
+	defer os.RemoveAll("testfile")
+	budget := NewMemoryBudget(0, nil)
+	fa, err := NewFactory("testfile", Controller{
+		Fsync:        FsyncModeNo,
+		BlockCache:   4 * pageSize,
+		MemoryBudget: budget,
+		Debug:        true,
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			return FilterParam{Slots: 7, Bits: 1e5, Hash: doubleFNV}, nil
+		},
+	}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		tenant := fmt.Sprint("tenant", i)
+		if exist, err := fa.ExistOrAdd(tenant, []byte(tenant)); err != nil || exist {
+			t.Fatalf("Should add to %v, got %v %v", tenant, exist, err)
+		}
+	}
+	if open := fa.Open(); len(open) != 2 || open[0] != "tenant4" || open[1] != "tenant3" {
+		t.Fatalf("Should keep the 2 tenants used most recently, got %v", open)
+	}
+	if budget.Used() > 2*4*pageSize {
+		t.Fatalf("The closed tenants should release the budget, got %v bytes used", budget.Used())
+	}
+	// the closed tenants are opened again
+	for i := 0; i < 5; i++ {
+		tenant := fmt.Sprint("tenant", i)
+		if exist, err := fa.Exist(tenant, []byte(tenant)); err != nil || !exist {
+			t.Fatalf("%v should exist in its filter, got %v %v", tenant, exist, err)
+		}
+		if exist, _ := fa.Exist(tenant, []byte("other")); exist {
+			t.Fatalf("Tenants should not share a filter")
+		}
+	}
+	if _, err = os.Stat(filepath.Join("testfile", "tenant0")); err != nil {
+		t.Fatal(err)
+	}
+
+	// a tenant in use is not closed
+	err = fa.Do("busy", func(busy *DiskFilter) error {
+		for i := 0; i < 5; i++ {
+			_, _ = fa.Exist(fmt.Sprint("tenant", i), nil)
+		}
+		if !busy.ExistOrAdd([]byte("busy")) && !busy.Exist([]byte("busy")) {
+			return fmt.Errorf("the busy tenant is closed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	fa.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	b, _ := io.ReadAll(rec.Body)
+	if n := strings.Count(string(b), "disk_bloom_fill_ratio{"); n != 2 {
+		t.Fatalf("Should serve the metrics of 2 tenants, got %v:\n%s", n, b)
+	}
+
+	for _, tenant := range []string{"", "..", "a/b"} {
+		if _, err = fa.Exist(tenant, nil); !errors.Is(err, InvalidTenantErr) {
+			t.Fatalf("Should reject the tenant %q, got %v", tenant, err)
+		}
+	}
+	if err = fa.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = fa.Exist("tenant0", nil); !errors.Is(err, ClosedErr) {
+		t.Fatalf("Should fail with ClosedErr, got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var FaultInjectedErr = fmt.Errorf("injected fault")
+
+// Faults are the failures injected by a FaultInjector.
+type Faults struct {
+	// ReadErrRate, WriteErrRate and SyncErrRate are the probabilities of a read, a write or a sync failing with Err.
+	ReadErrRate  float64
+	WriteErrRate float64
+	SyncErrRate  float64
+	// Latency is added to every read, write and sync.
+	Latency time.Duration
+	// Err is the error of the injected failures, e.g. syscall.ENOSPC to exercise DiskFullPolicy.
+	// It defaults to FaultInjectedErr.
+	Err error
+}
+
+// FaultInjector injects failures and latency into the file I/O of a DiskFilter, see Controller.FaultInjector,
+// so that downstream systems can verify their behavior under disk failures in integration tests.
+// The faults can be changed at any time by Set.
+// The bytes of the bloom filter served by Controller.Mmap are not affected, but their syncs are.
+type FaultInjector struct {
+	mu       sync.Mutex
+	faults   Faults
+	rand     *rand.Rand
+	injected uint64
+}
+
+// NewFaultInjector returns a FaultInjector injecting nothing until Set. seed makes the failures reproducible.
+func NewFaultInjector(seed int64) *FaultInjector {
+	return &FaultInjector{rand: rand.New(rand.NewSource(seed))}
+}
+
+// Set replaces the faults injected.
+func (i *FaultInjector) Set(faults Faults) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.faults = faults
+}
+
+// Injected returns the number of failures injected.
+func (i *FaultInjector) Injected() uint64 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.injected
+}
+
+// inject sleeps the latency, and returns the error if the operation of the failure rate fails.
+func (i *FaultInjector) inject(rate func(Faults) float64) error {
+	if i == nil {
+		return nil
+	}
+	i.mu.Lock()
+	faults := i.faults
+	fail := rate(faults) > 0 && i.rand.Float64() < rate(faults)
+	if fail {
+		i.injected++
+	}
+	i.mu.Unlock()
+	if faults.Latency > 0 {
+		time.Sleep(faults.Latency)
+	}
+	if !fail {
+		return nil
+	}
+	if faults.Err != nil {
+		return faults.Err
+	}
+	return FaultInjectedErr
+}
+
+func readErrRate(f Faults) float64  { return f.ReadErrRate }
+func writeErrRate(f Faults) float64 { return f.WriteErrRate }
+func syncErrRate(f Faults) float64  { return f.SyncErrRate }
+
+// faultStorage injects the failures of a FaultInjector into the reads and writes.
+type faultStorage struct {
+	storage
+	injector *FaultInjector
+}
+
+func (s faultStorage) ReadAt(b []byte, offset int64) (int, error) {
+	if err := s.injector.inject(readErrRate); err != nil {
+		return 0, err
+	}
+	return s.storage.ReadAt(b, offset)
+}
+
+func (s faultStorage) WriteAt(b []byte, offset int64) (int, error) {
+	if err := s.injector.inject(writeErrRate); err != nil {
+		return 0, err
+	}
+	return s.storage.WriteAt(b, offset)
+}
+
+// syncFile syncs the file, failing as injected by Controller.FaultInjector.
+func (f *DiskFilter) syncFile() error {
+	if err := f.controller.FaultInjector.inject(syncErrRate); err != nil {
+		f.noteIO(ioOpSync, err)
+		return err
+	}
+	// the bytes of Controller.Hybrid reach the file before any sync
+	if f.hybrid != nil {
+		if err := f.hybrid.flush(); err != nil {
+			f.noteIO(ioOpWrite, err)
+			return err
+		}
+	}
+	if !f.controller.Debug {
+		return f.synced(f.file.sync(f.controller.Durability))
+	}
+	start := time.Now()
+	err := f.file.sync(f.controller.Durability)
+	atomic.AddUint64(&f.debug.syncs, 1)
+	atomic.AddInt64(&f.debug.syncTime, int64(time.Since(start)))
+	return f.synced(err)
+}
+
+// synced records the result of a sync for Err and Header.LastSync.
+func (f *DiskFilter) synced(err error) error {
+	f.noteIO(ioOpSync, err)
+	if err == nil {
+		f.touchSync()
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+package disk_bloom
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+)
+
+func TestFaultInjector(t *testing.T) {
+	injector := NewFaultInjector(1)
+	var diskFull error
+	bf := newTestFilter(t, Controller{
+		Fsync:         FsyncModeAlways,
+		FaultInjector: injector,
+		OnDiskFull: func(err error) {
+			diskFull = err
+		},
+	})
+	injector.Set(Faults{WriteErrRate: 1})
+	if _, err := bf.ExistOrAddErr([]byte("a")); !errors.Is(err, FaultInjectedErr) {
+		t.Fatalf("should fail with FaultInjectedErr, got %v", err)
+	}
+	injector.Set(Faults{WriteErrRate: 1, Err: syscall.ENOSPC})
+	if _, err := bf.ExistOrAddErr([]byte("b")); !errors.Is(err, syscall.ENOSPC) || diskFull == nil {
+		t.Fatalf("should fail with ENOSPC and invoke OnDiskFull, got %v", err)
+	}
+	injector.Set(Faults{SyncErrRate: 1})
+	if _, err := bf.ExistOrAddErr([]byte("c")); !errors.Is(err, FaultInjectedErr) {
+		t.Fatalf("should fail with FaultInjectedErr, got %v", err)
+	}
+	if n := injector.Injected(); n != 3 {
+		t.Fatalf("should inject 3 failures, got %v", n)
+	}
+	injector.Set(Faults{})
+	if _, err := bf.ExistOrAddErr([]byte("d")); err != nil {
+		t.Fatal(err)
+	}
+	if !bf.Exist([]byte("d")) {
+		t.Fatal("d should exist")
+	}
+	injector.Set(Faults{ReadErrRate: 0.5})
+	var failed int
+	for i := 0; i < 100; i++ {
+		if !bf.Exist([]byte("d")) {
+			failed++
+		}
+	}
+	if failed < 25 || failed > 75 {
+		t.Fatalf("about half of the lookups should fail, got %v", failed)
+	}
+}
@@ -8,247 +8,1412 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"math/bits"
 	"os"
+	"runtime"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type FsyncMode int
 
 const (
+	// FsyncModeAlways makes every add durable before ExistOrAdd returns.
+	// All bits of an entry are written, gathered into a single write per page, and then synced once, under the lock
+	// of the filter, so an entry is never reported as existing, by any goroutine, before all of its bits are durable.
+	// The bytes are restored if the sync fails, or else the filter becomes read-only.
 	FsyncModeAlways FsyncMode = iota
+	// FsyncModeEverySec syncs the file every Controller.SyncInterval, a second by default,
+	// so that up to an interval of adds may be lost on a crash.
 	FsyncModeEverySec
+	// FsyncModeNo leaves syncing to the operating system, except on Close.
 	FsyncModeNo
 )
 
+func (m FsyncMode) String() string {
+	switch m {
+	case FsyncModeAlways:
+		return "always"
+	case FsyncModeEverySec:
+		return "every_sec"
+	case FsyncModeNo:
+		return "no"
+	default:
+		return "unknown"
+	}
+}
+
 const LenOfMetadataSize = 2
 
 type muFile struct {
-	f        *os.File
+	// f is the file, an *os.File unless the filter is opened by OpenFS or NewOnStorage
+	f fileHandle
+	// rw is where the metadata and the bloom filter are read and written, which is f or wraps f.
+	rw       storage
 	fsync    FsyncMode
 	modified bool
-	mu       sync.Mutex
+	// metadataModified is set by WriteMetadata, and cleared once the metadata is synced
+	metadataModified bool
+	// mu is read-locked by the lookups, which only read the file, so that they run concurrently
+	mu sync.RWMutex
 }
 
-var InconsistentMetadataSizeErr = fmt.Errorf("inconsistent metadata size")
+var (
+	InconsistentMetadataSizeErr = fmt.Errorf("inconsistent metadata size")
+	ClosedErr                   = fmt.Errorf("filter is closed")
+	DrainTimeoutErr             = fmt.Errorf("operations in flight did not drain")
+	LockedErr                   = fmt.Errorf("filter file is locked by another filter")
+)
 
 // Disk-based Classic Bloom Filter
 type DiskFilter struct {
-	param *FilterParam
-	file  muFile
+	// setBits is the number of bits set, accessed atomically. It is the first field to be 64-bit aligned.
+	setBits uint64
+	// lastAdd and lastSync are the times of Header.LastAdd and Header.LastSync in unix nanoseconds, accessed atomically
+	lastAdd  int64
+	lastSync int64
+	// counted is 1 once setBits is valid, accessed atomically, see countSetBits
+	counted int32
+	param   *FilterParam
+	header  Header
+	// bloomStart is the file offset of the bloom filter
+	bloomStart int64
+	file       muFile
 	// use this channel to inform the sync goroutine
-	closed     chan struct{}
+	closed chan struct{}
+	// tasks are the background tasks, which Close cancels and waits for
+	tasks      *taskGroup
 	controller *Controller
+	// bytes buffered in memory by DiskFullPolicyBuffer
+	pending  map[int64]byte
+	diskFull bool
+	readOnly bool
+	pinned   *pinned
+	// commit shares syncs among concurrent adds in FsyncModeAlways, see Controller.GroupCommit
+	commit *groupCommit
+	// unsynced maps the file offsets written but not synced yet to their group commit batch
+	unsynced map[int64]uint64
+	debug    *debugCounters
+	stats    *statsRing
+	// breaker backs off the background sync while it fails
+	breaker *syncBreaker
+	// probe is the result of Controller.SyncProbe
+	probe SyncProbe
+	// tune is the result of Controller.AutoTune, or nil if it is off
+	tune *AutoTune
+	// ioErr is the last I/O error, see Err
+	ioErr lastIOError
+	// prepared are the adds of Prepare which are not committed or aborted yet
+	prepared preparedAdds
+	// inflight is read-locked by the operations, and locked by Close to wait for them
+	inflight sync.RWMutex
+	// mapped is the mapped bloom filter of Controller.Mmap, and lockFree is whether Exist reads it without the lock
+	mapped   *mmapStorage
+	lockFree bool
+	// hybrid is the bloom filter in memory of Controller.Hybrid
+	hybrid *hybridStorage
+	// changes are the bytes of the bloom filter changed since the last delta, by the offset in the bloom filter, see Controller.TrackDeltas
+	changes map[int64]byte
+	// checksums are the checksums of the blocks of Controller.Checksums
+	checksums *checksums
+	// syncInterval and syncJitter are the ones of Controller.SyncInterval and Controller.SyncJitter, changed by SetSyncInterval,
+	// which signals rescheduled to apply them at once
+	syncInterval int64
+	syncJitter   int64
+	rescheduled  chan struct{}
+	// reached is whether each of Controller.FillThresholds is reached, accessed by eventEverySec
+	reached []bool
+	// journal is the journal of Controller.Journal
+	journal *journal
+	// acks is the ring of Controller.AckRing
+	acks *ackRing
+	// cache is the block cache of Controller.BlockCache
+	cache *cachedStorage
+	// buffered is the number of adds buffered by Controller.WriteBuffer, which is enabled if writeBuffer
+	buffered    int
+	writeBuffer bool
+
+	// written are the file offsets written during Snapshot, which is serialized by snapshotMu
+	written    map[int64]bool
+	snapshotMu sync.Mutex
 }
 
 type FilterParam struct {
 	Slots uint8
 	Bits  uint64
-	Hash  func([]byte) (uint64, uint64)
+	// Hash is the double hash that takes an entry and returns two different hashes.
+	// It is set by New if HashKind selects a built-in hash.
+	Hash func([]byte) (uint64, uint64)
+	// HashKind selects a built-in hash, or HashKindCustom for Hash. It is recorded in the header of new files,
+	// and opening a file with another HashKind fails.
+	HashKind HashKind
+	// HashKey is the key of HashKindSipHash.
+	HashKey []byte
+	// BlockSize makes a blocked filter of classic filters, whose probes of an entry land in one block of BlockSize bytes
+	// chosen by the hash, e.g. 512 for a sector or 4096 for a page, so that a lookup reads one block instead of
+	// Slots scattered bytes, at the cost of a slightly higher false positive rate. It is a power of two
+	// from 64 to 4096, and Bits is rounded up to a multiple of it. It is recorded in the header of new files,
+	// and FastRange and HardenedProbes do not apply.
+	BlockSize uint32
 }
 
 type Controller struct {
 	Fsync FsyncMode
 	// Size in bytes
 	MetadataSize uint16
-	// Control will be invoked every second.
+	// Control will be invoked every SyncInterval. f is nil if the file is opened by OpenFS from an fs.FS other than os.DirFS,
+	// or by NewOnStorage, see ControlStorage.
 	//
-	// | len of metadata size(2 bytes) | metadata | bloom filter |
+	// | len of metadata size(2 bytes) | metadata | header | bloom filter |
 	Control func(f *os.File, modified bool)
+	// ControlStorage is like Control, but is given the Storage of the filter, which is the *os.File of New,
+	// or the Storage of NewOnStorage.
+	ControlStorage func(s Storage, modified bool)
 	// GetParam will be invoked when New.
 	//
-	// | len of metadata size(2 bytes) | metadata | bloom filter |
+	// | len of metadata size(2 bytes) | metadata | header | bloom filter |
 	GetParam func(metadata []byte) (param FilterParam, updatedMetadata []byte)
+	// DiskFullPolicy decides what ExistOrAdd does when the disk is full.
+	DiskFullPolicy DiskFullPolicy
+	// OnDiskFull will be invoked once the disk becomes full. It is optional.
+	OnDiskFull func(err error)
+	// SyncInterval is the interval of the goroutine in the background, which syncs the file in FsyncModeEverySec,
+	// invokes Control and does the other periodic work. It is from MinSyncInterval to MaxSyncInterval,
+	// and DefaultSyncInterval if not given. See SetSyncInterval.
+	SyncInterval time.Duration
+	// SyncJitter delays every tick by a random duration up to SyncJitter, which is at most SyncInterval,
+	// so that the many filters of a host do not sync at the same instant.
+	SyncJitter time.Duration
+	// OnBackgroundError will be invoked with the errors of the goroutines in the background,
+	// e.g. the failed syncs of FsyncModeEverySec and the failed flushes of WriteBuffer, which no operation returns.
+	// It is optional, see Err.
+	OnBackgroundError func(err error)
+	// SyncProbe makes New probe whether the syncs of the directory of the file look durable, see ProbeSync,
+	// which is reported by Stats. It is ignored if ReadOnly is set.
+	SyncProbe SyncProbeMode
+	// OnSyncProbe will be invoked by New if SyncProbe finds the syncs not durable, e.g. to warn. It is optional.
+	OnSyncProbe func(probe SyncProbe)
+	// AutoTune makes New time the random reads and the syncs of the storage, and turn on the options they call for
+	// unless set: Mmap on fast storages, BlockCache and AccessPatternRandom on slow ones, and GroupCommit
+	// in FsyncModeAlways on slow syncs. The latencies and the decisions are reported by Describe.
+	AutoTune bool
+	// PinnedRange is the byte range of the bloom filter kept in memory. See DiskFilter.Pin.
+	PinnedRange Range
+	// GroupCommit enables the group commit in FsyncModeAlways if it is positive:
+	// concurrent adds share a single sync, which is issued GroupCommit after the first of them.
+	// ExistOrAdd still returns after its entry is durable, and an entry is not reported as existing before that.
+	// If a sync fails, the entries of it and the following ones are never reported, and the filter becomes read-only.
+	GroupCommit time.Duration
+	// WriteBuffer defers the writes of adds if it is positive: the bytes changed are kept in memory, where lookups see them,
+	// and written in sorted runs, one per page, every WriteBuffer, once WriteBufferOps adds are buffered, or on Close,
+	// instead of a write per byte changed by every add. The adds buffered are lost on a crash.
+	// An add is visible to the lookups of the filter once it returns, whether it is flushed or not.
+	// It is ignored in FsyncModeAlways, and applies to classic filters.
+	WriteBuffer time.Duration
+	// WriteBufferOps flushes the write buffer once this number of adds are buffered, if it is positive.
+	WriteBufferOps int
+	// BlockCache keeps this number of bytes of the pages of the bloom filter read most recently in memory if it is positive,
+	// so that the lookups of hot entries do not read the disk every time. See BlockCacheStats.
+	// The writes go through it, so the lookups never see a page older than the adds completed.
+	// It is ignored if the bloom filter is served by Mmap.
+	BlockCache int64
+	// BlockCacheMin makes the BlockCache adaptive if it is positive and less than BlockCache: the cache starts
+	// at BlockCacheMin bytes, and is resized every second within [BlockCacheMin, BlockCache], growing while
+	// the hit rate is low and shrinking while it is high or the memory available to the process is short.
+	BlockCacheMin int64
+	// MemoryBudget bounds the memory of the block cache, the bytes buffered by WriteBuffer and DiskFullPolicyBuffer,
+	// the pinned range and the mapping of Mmap, if it is not nil. It can be shared by filters to bound them together.
+	// Once it is exhausted, the block cache evicts its pages, the write buffer is flushed,
+	// DiskFullPolicyBuffer behaves as DiskFullPolicyError, Pin fails with MemoryBudgetErr, and Mmap falls back to the file I/O.
+	MemoryBudget *MemoryBudget
+	// Stats enables the per-minute counters of Stats.
+	Stats bool
+	// Clock is the source of time of Stats, and of the backoff of the background sync. It is optional, and defaults to SystemClock.
+	Clock Clock
+	// MetadataSync syncs the metadata written by WriteMetadata at this interval if it is positive,
+	// which can be shorter than the interval of syncing the bloom filter since only the metadata and the header are synced.
+	MetadataSync time.Duration
+	// Debug enables the counters of DebugStats, and the pprof label "disk_bloom" on the phases "hash" and "io".
+	// See MetricsHandler and Var to export the counters.
+	Debug bool
+	// Tracer is invoked with the details of the lookups, the adds and the background syncs taking SlowOp at least,
+	// or all of them if SlowOp is zero. The batches are not traced. It is optional.
+	Tracer Tracer
+	SlowOp time.Duration
+	// Durability is what the syncs of the file, the journal and the ack ring guarantee, DurabilityLevelFull by default,
+	// the same on every platform.
+	Durability DurabilityLevel
+	// DrainTimeout bounds the wait of Close for the operations in flight, e.g. the adds waiting for a group commit.
+	// Close returns DrainTimeoutErr once it expires, and the filter is closed in the background once they finish.
+	// Zero waits without a limit.
+	DrainTimeout time.Duration
+	// WearLeveling is for the flash media rewriting the same pages for years: the bytes changed by an add
+	// are written starting at a random probe, and the times and the bits set persisted every second while the file
+	// is modified rotate over the slots of the header instead of rewriting the same bytes, see FlagWearLeveled.
+	// The rotation takes effect on new files, and is recorded in their header.
+	WearLeveling bool
+	// AlignToPage rounds Bits up to a multiple of the page size, so that page-granular I/O and O_DIRECT are satisfiable.
+	// The bloom filter of new files always starts at a page boundary.
+	// It takes effect on new files, and is recorded in their header.
+	AlignToPage bool
+	// VerifyWrites re-reads every byte written and rewrites it on mismatch, for questionable hardware,
+	// where a wrong bit silently breaks the replay protection. ExistOrAdd fails with CorruptWriteErr
+	// if the byte still mismatches after a few rewrites.
+	VerifyWrites bool
+	// OnCorruptWrite will be invoked on every mismatch found by VerifyWrites. It is optional.
+	OnCorruptWrite func(offset int64, written, read byte)
+	// ShrinkOnSeal makes Seal rewrite the bloom filter keeping only its non-zero blocks of 4 KiB,
+	// which reclaims the unused space of sparse filters. The rewrite is in place and not crash-safe,
+	// so seal a copy of the file if it can not be rebuilt.
+	ShrinkOnSeal bool
+	// Journal appends the bytes of every add and the metadata written by WriteMetadata to a journal next to the file,
+	// see JournalFilename, and syncs it before the add returns, so that the adds acknowledged in FsyncModeEverySec
+	// and FsyncModeNo survive a crash. The journal is replayed by New, and emptied once the file is synced every second,
+	// which happens even in FsyncModeNo while there are records. Bulk operations like Merge and ApplyDelta are not journaled.
+	// GetParam is given the metadata before the replay. It is ignored in FsyncModeAlways,
+	// and applies to classic filters which are neither encrypted nor write-buffered.
+	Journal bool
+	// AckRing records the last AckRing adds of ExistOrAddSeq with their sequence numbers in a ring of a fixed size
+	// next to the file, see AckRingFilename, once they are durable: on return in FsyncModeAlways or with Journal,
+	// or else once the file is synced, every second in FsyncModeEverySec or on Close.
+	// After a crash, Acked tells exactly which adds survived, so that an ingestion pipeline resumes after them.
+	AckRing int
+	// ReadOnly opens an existing file with O_RDONLY, so that many processes can read it without any risk of modifying it,
+	// see OpenReadOnly. The adds fail with ReadOnlyErr, nothing is synced in the background, and the options writing
+	// to the file or next to it, like Checksums, Journal and WriteBuffer, are ignored. Mmap falls back to the file I/O.
+	// It is required by the WebAssembly builds without the diskbloom_writable tag, see PlatformCapabilities.Writable.
+	ReadOnly bool
+	// NoHash opens an existing file without the hash of its entries, which is neither resolved from the HashKind recorded
+	// nor required of HashKindCustom, for the tools working on the bits only, like MergeFiles or inspecting a file.
+	// The entries are only taken by their hashes then, like ExistHashed, and Hash panics.
+	NoHash bool
+	// FileLock locks the file advisorily while it is open, exclusively, or shared if ReadOnly is set,
+	// so that New fails with LockedErr instead of interleaving the writes of two filters on the same file,
+	// in this process or another, and a ReadOnly filter fails while the file is open for writing.
+	// The filters without FileLock ignore the lock. It is supported on Linux, and New fails with UnsupportedErr elsewhere.
+	FileLock bool
+	// Preallocate allocates the blocks of the bloom filter when the file is created, instead of leaving a sparse file,
+	// so that the adds can not fail with ENOSPC later, and the blocks are not fragmented on HDDs.
+	// It uses fallocate on Linux, and writes zeros elsewhere or if the filesystem does not support it.
+	Preallocate bool
+	// FastRange maps the probes to the bloom filter by Lemire's multiply-shift instead of modulo,
+	// which is cheaper and distributes well for any Bits.
+	// It takes effect on new files, and is recorded in their header.
+	FastRange bool
+	// HardenedProbes corrects the double hashes whose probes collapse to few bits, which happens to weak custom hashes:
+	// the second hash y is rederived from the first x if it equals x or is zero, and made odd and not a multiple of Bits.
+	// It takes effect on new files, and is recorded in their header.
+	HardenedProbes bool
+	// Salted mixes a salt into the double hash of new files before probing, so that the keys precomputed to collide
+	// against the known hash, e.g. to flood the replay protection with false positives, do not collide in this filter,
+	// and independent filters do not share their false positives. The salt is Salt if it is not zero, e.g. the salt
+	// of the primary to create a replica, or else a random one. It is recorded in the header, see Header.Salt,
+	// and is not supported by xor and cuckoo filters. An existing file must have Salt if it is not zero.
+	// Keys of the same double hash still collide, which a keyed hash like HashKindSipHash prevents.
+	Salted bool
+	Salt   uint64
+	// Mmap serves the bloom filter from a shared mapping of the file, so that lookups and adds touch the mapped pages
+	// instead of issuing a syscall per probe, and Exist takes no lock unless FsyncModeAlways or Debug is set.
+	// The syncs of FsyncMode still apply, and cover the mapped pages.
+	// It falls back to the file I/O if the file can not be mapped, e.g. on ENOMEM, beyond the address space of 32-bit hosts,
+	// on platforms other than Linux, or if the file is encrypted or shrunk.
+	Mmap bool
+	// OnMmapFallback will be invoked with the reason if Mmap falls back to the file I/O. It is optional.
+	OnMmapFallback func(err error)
+	// Hybrid keeps the whole bloom filter in memory if it is positive, for the filters fitting in memory:
+	// the lookups and adds touch the memory only, and the pages changed are written to the file every Hybrid,
+	// before every sync of the file, e.g. every second in FsyncModeEverySec, and on Close, see Checkpoint.
+	// The adds not written yet are lost on a crash unless Journal is set. Mmap and BlockCache are ignored,
+	// and it falls back to the file I/O if MemoryBudget is exhausted. It is ignored in FsyncModeAlways.
+	Hybrid time.Duration
+	// AccessPattern is declared to the kernel for the bloom filter on open, see Advise. It is a hint,
+	// e.g. AccessPatternRandom keeps the readahead from evicting the hot pages of a filter larger than the page cache.
+	AccessPattern AccessPattern
+	// AdaptiveSlots sets this number of extra bits per entry, and lets Exist probe only as many of them as the fill ratio needs,
+	// so lookups on a sparse filter touch fewer pages, and gain more evidence as the filter fills. It is experimental.
+	// It takes effect on new files, and is recorded in their header together with the number of bits set.
+	AdaptiveSlots uint8
+	// CounterWidth is the number of bits of each counter of NewCounting, which is 2, 4 or 8.
+	// Wider counters take more space, but saturate later, after which they can not be deleted.
+	// It defaults to DefaultCounterWidth for new files, and is recorded in their header. Opening a file of another width fails.
+	CounterWidth uint8
+	// FillThresholds are the fractions of the design capacity, e.g. 0.5, 0.75 and 0.9, at which OnFillThreshold is invoked,
+	// once per threshold, by the goroutine ticking every second. The design capacity is the number of entries
+	// at which half of the bits are set, which is the n of OptimalParam. They apply to classic filters.
+	FillThresholds []float64
+	// OnFillThreshold will be invoked with the threshold reached and the current fill, e.g. to rotate or alert.
+	OnFillThreshold func(threshold float64, fill float64)
+	// Checksums keeps a CRC32C of every 64 KiB block of the bloom filter in the sidecar file ChecksumFilename,
+	// so that bit rot is detected by Verify. The checksums of the blocks written are updated every second and on Close,
+	// so the blocks written right before a crash may mismatch. They are computed from the file if the sidecar does not exist.
+	// Shrunk files are not checksummed.
+	Checksums bool
+	// VerifyOnOpen makes New fail with ChecksumErr if the bloom filter does not match the checksums, see Verify.
+	VerifyOnOpen bool
+	// TrackDeltas tracks the bytes of the bloom filter changed, so that they are exported by ExportDelta
+	// and applied to replicas by ApplyDelta, see NewUploader. It applies to classic filters.
+	TrackDeltas bool
+	// FaultInjector injects failures and latency into the file I/O, for chaos testing. It is optional.
+	FaultInjector *FaultInjector
+	// Tag is an application-defined tag written in the header of new files, see Inspect.
+	Tag string
+	// Xattrs writes the extended attributes of the creator, the purpose and the parameters on new files where supported,
+	// so that audit tools classify the files without opening them, see ReadXattrs.
+	Xattrs bool
+	// EncryptionKey enables AES-CTR encryption of the metadata and the bloom filter if it is not empty.
+	// It should be 16, 24 or 32 bytes to select AES-128, AES-192 or AES-256.
+	// Note that Control receives the underlying file, in which the metadata is encrypted.
+	EncryptionKey []byte
+	// HMACKey enables an HMAC over the header and the metadata if it is not empty,
+	// so that tampering with them is detected by New with TamperedErr.
+	// The HMAC skips the bits set and the times, which the adds and the syncs rewrite. The other changes of the header
+	// and the metadata are written together with the HMAC, so that a crash does not leave it stale.
+	//
+	// WARNING: the metadata is NOT covered once the file is signed with Control or ControlStorage set, which rewrite it
+	// out of the HMAC, e.g. by the filters of FilterGroup: tampering with the metadata of such a file is not detected,
+	// even by the opens without them. Only the header is covered then.
+	HMACKey []byte
 }
 
 // n is the expected number of entries.
 // p is the expected false positive rate.
+// The parameters are clamped to the range of a filter, and are 0 for the invalid n and p, which New rejects.
+// See PlanParam for the errors and the false positive rate achieved.
 func OptimalParam(n uint64, p float64) (slots uint8, bits uint64) {
-	k := -math.Log(p) * math.Log2E   // number of hashes
-	m := float64(n) * k * math.Log2E // number of bits
-	return uint8(k + 0.5), uint64(m / 8 * 8)
+	plan, _ := PlanParam(n, p)
+	return plan.Slots, plan.Bits
+}
+
+// EstimateFPR returns the expected false positive rate of a filter with given parameters after n entries are added.
+func EstimateFPR(slots uint8, bits uint64, n uint64) float64 {
+	if bits == 0 {
+		return 1
+	}
+	k := float64(slots)
+	return math.Pow(1-math.Exp(-k*float64(n)/float64(bits)), k)
 }
 
 // New creates a classic Bloom Filter.
 // h is a double hash that takes an entry and returns two different hashes.
 func New(filename string, controller Controller) (*DiskFilter, error) {
+	return open(filename, controller, variantClassic)
+}
+
+// OpenReadOnly opens an existing classic Bloom Filter read-only, see Controller.ReadOnly,
+// with the metadata size recorded in the file. The parameters left zero in param are restored from the header,
+// but the Hash of HashKindCustom must be given.
+func OpenReadOnly(filename string, param FilterParam) (*DiskFilter, error) {
+	_, metadataSize, err := inspect(filename)
+	if err != nil {
+		return nil, err
+	}
+	return New(filename, Controller{
+		Fsync:        FsyncModeNo,
+		MetadataSize: metadataSize,
+		ReadOnly:     true,
+		GetParam: func([]byte) (FilterParam, []byte) {
+			return param, nil
+		},
+	})
+}
+
+// open opens the file as a classic Bloom Filter, or the DiskFilter underlying the other variants.
+func open(filename string, controller Controller, v variant) (*DiskFilter, error) {
+	filter, err := openFile(filename, controller, v, &debugCounters{})
+	if err != nil {
+		return nil, err
+	}
+	filter.start()
+	return filter, nil
+}
+
+// openFile is open without starting the background goroutines, which count into debug.
+func openFile(filename string, controller Controller, v variant, debug *debugCounters) (*DiskFilter, error) {
 	// calculate the optimal num of bits
-	mode := os.O_CREATE | os.O_RDWR
 	// open the data file
-	if controller.Fsync == FsyncModeAlways {
-		mode |= os.O_SYNC
+	// FsyncModeAlways syncs once per add instead of using O_SYNC, which would sync every byte written
+	mode := os.O_CREATE | os.O_RDWR
+	if controller.ReadOnly {
+		mode = os.O_RDONLY
+	} else if !writable {
+		return nil, fmt.Errorf("%w: writing filters on %v, see Controller.ReadOnly", UnsupportedErr, runtime.GOOS)
+	}
+	probe, err := probeSync(filename, controller)
+	if err != nil {
+		return nil, err
+	}
+	var tune *AutoTune
+	if controller.AutoTune {
+		if tune, err = autoTune(filename, &controller); err != nil {
+			return nil, err
+		}
 	}
 	f, err := os.OpenFile(filename, mode, 0644)
 	if err != nil {
 		return nil, err
 	}
+	if controller.FileLock {
+		if err = lockFile(f, !controller.ReadOnly); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+	}
+	filter, err := openHandle(f, filename, controller, v, debug)
+	if err != nil {
+		return nil, err
+	}
+	filter.probe = probe
+	filter.tune = tune
+	return filter, nil
+}
+
+// openHandle is openFile with the file opened, which is closed if it fails, releasing the lock of Controller.FileLock.
+func openHandle(f fileHandle, filename string, controller Controller, v variant, debug *debugCounters) (_ *DiskFilter, err error) {
+	// removed is whether the file created is removed if it fails
+	removed := false
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			if removed {
+				_ = os.Remove(filename)
+			}
+		}
+	}()
 	var param FilterParam
+	var header Header
+	syncInterval, err := checkSyncInterval(controller.SyncInterval, controller.SyncJitter)
+	if err != nil {
+		return nil, err
+	}
 	var metadataSize [LenOfMetadataSize]byte
 	var updatedMetadata []byte
-	if n, err := f.ReadAt(metadataSize[:], 0); n == 0 && err == io.EOF {
+	// created is whether the file is new, whose bits set are known to be none
+	created := false
+	raw := retryStorage{f}
+	var rw storage = raw
+	headerStart := LenOfMetadataSize + int64(controller.MetadataSize)
+	if n, err := raw.ReadAt(metadataSize[:], 0); n == 0 && err == io.EOF {
+		if controller.ReadOnly {
+			return nil, fmt.Errorf("%w: %v is empty", ReadOnlyErr, filename)
+		}
+		if controller.NoHash {
+			return nil, fmt.Errorf("%w: the hash is required by new files", MissingParamErr)
+		}
+		created = true
+		if controller.GetParam == nil {
+			return nil, fmt.Errorf("%w: GetParam is required by new files", MissingParamErr)
+		}
 		param, updatedMetadata = controller.GetParam(nil)
+		if param.Slots == 0 || param.Bits == 0 {
+			return nil, fmt.Errorf("%w: slots and bits are required by new files", MissingParamErr)
+		}
+		if err = param.resolveHash(); err != nil {
+			return nil, err
+		}
 		// create a new file
+		if header, err = newHeader(controller.Tag); err != nil {
+			return nil, err
+		}
+		header.HashKind = param.HashKind
+		if len(controller.HMACKey) > 0 {
+			header.Flags |= FlagSigned
+		}
+		if controller.FastRange {
+			header.Flags |= FlagFastRange
+		}
+		if controller.HardenedProbes {
+			header.Flags |= FlagHardened
+		}
+		if controller.Salted || controller.Salt != 0 {
+			if header.Salt, err = newSalt(controller.Salt, v); err != nil {
+				return nil, err
+			}
+			header.Flags |= FlagSalted
+		}
+		if controller.AdaptiveSlots > 0 {
+			header.Flags |= FlagAdaptive
+			header.AdaptiveSlots = controller.AdaptiveSlots
+		}
+		switch v {
+		case variantCounting:
+			header.Flags |= FlagCounting
+			header.CounterWidth = controller.CounterWidth
+			if header.CounterWidth == 0 {
+				header.CounterWidth = DefaultCounterWidth
+			}
+		case variantXor:
+			header.Flags |= FlagXor
+			header.fingerprints = param.Bits / 8
+		case variantCuckoo:
+			header.Flags |= FlagCuckoo
+		case variantTTL:
+			header.Flags |= FlagTTL
+		}
+		if param.BlockSize > 0 {
+			if err = checkBlockSize(param.BlockSize, v); err != nil {
+				return nil, err
+			}
+			header.Flags |= FlagBlocked
+			header.BlockSize = param.BlockSize
+			param.Bits = header.bloomBits(param.Bits)
+		}
+		if controller.WearLeveling {
+			header.Flags |= FlagWearLeveled
+		}
+		if controller.AlignToPage {
+			header.Flags |= FlagAligned
+			param.Bits = header.bloomBits(param.Bits)
+		}
+		header.Slots, header.Bits = param.Slots, param.Bits
+		bloomStart := header.bloomStart(controller.MetadataSize)
+		bloomSize := header.bloomSize(param.Bits)
+		var encrypted *encryptedStorage
+		if len(controller.EncryptionKey) > 0 {
+			header.Flags |= FlagEncrypted
+			if header.nonce, err = newNonce(); err != nil {
+				return nil, err
+			}
+			if encrypted, err = newEncryptedStorage(raw, controller.EncryptionKey, header.nonce, headerStart, headerStart+int64(header.Size)); err != nil {
+				return nil, err
+			}
+			header.keyCheck = encrypted.keyCheck()
+			rw = encrypted
+		}
+		if _, err = raw.WriteAt(header.Encode(), headerStart); err != nil {
+			return nil, err
+		}
 		// write at the end of file to allocate specific space in the disk
-		// TODO: thick provision?
-		if _, err = f.WriteAt([]byte{0}, LenOfMetadataSize+int64(controller.MetadataSize)+int64(param.Bits/8)); err != nil {
+		if _, err = rw.WriteAt([]byte{0}, bloomStart+bloomSize-1); err != nil {
 			return nil, err
 		}
+		if file, ok := f.(*os.File); ok && controller.Preallocate && encrypted == nil {
+			// the encrypted zeros are written below
+			if err = preallocate(file, bloomStart, bloomSize); err != nil {
+				removed = true
+				return nil, err
+			}
+		}
+		if encrypted != nil {
+			if err = encrypted.zero(LenOfMetadataSize, int64(controller.MetadataSize)); err != nil {
+				return nil, err
+			}
+			if err = encrypted.zero(bloomStart, bloomSize); err != nil {
+				return nil, err
+			}
+		}
 		// write the metadata size at the head of file (2 bytes).
 		binary.LittleEndian.PutUint16(metadataSize[:], controller.MetadataSize)
-		if _, err = f.WriteAt(metadataSize[:], 0); err != nil {
+		if _, err = raw.WriteAt(metadataSize[:], 0); err != nil {
 			return nil, err
 		}
+		if controller.Fsync == FsyncModeAlways {
+			if err = f.Sync(); err != nil {
+				return nil, err
+			}
+		}
+		if controller.Xattrs {
+			// where the filesystem supports them, which ReadXattrs tells
+			_ = writeXattrs(filename, header)
+		}
 	} else if err != nil {
 		return nil, err
 	} else if fms := binary.LittleEndian.Uint16(metadataSize[:]); fms != controller.MetadataSize {
 		return nil, fmt.Errorf("%w: the metadata size written in the given file is %v, which is different from %v", InconsistentMetadataSizeErr, fms, controller.MetadataSize)
 	} else {
+		if header, err = readHeader(raw, controller.MetadataSize); err != nil {
+			return nil, err
+		}
+		if len(controller.HMACKey) > 0 {
+			if err = verifyHeaderMAC(controller.HMACKey, raw, header, controller.MetadataSize); err != nil {
+				return nil, err
+			}
+		}
+		if header.variant() != v {
+			return nil, fmt.Errorf("%w: the file is a %v filter, but opened as a %v filter", InvalidHeaderErr, header.variant(), v)
+		}
+		if controller.Salt != 0 && controller.Salt != header.Salt {
+			return nil, fmt.Errorf("%w: the file is created with salt %#x, which is different from %#x", InconsistentParamErr, header.Salt, controller.Salt)
+		}
+		if v == variantCounting {
+			if err = checkCounterWidth(header, controller); err != nil {
+				return nil, err
+			}
+		}
+		if header.Encrypted() != (len(controller.EncryptionKey) > 0) {
+			return nil, fmt.Errorf("%w: the file is encrypted: %v, but the key is given: %v", InvalidKeyErr, header.Encrypted(), len(controller.EncryptionKey) > 0)
+		}
+		if header.Encrypted() {
+			encrypted, err := newEncryptedStorage(raw, controller.EncryptionKey, header.nonce, headerStart, headerStart+int64(header.Size))
+			if err != nil {
+				return nil, err
+			}
+			if err = encrypted.checkKey(header); err != nil {
+				return nil, err
+			}
+			rw = encrypted
+		}
 		metadata := make([]byte, controller.MetadataSize)
-		if _, err := f.ReadAt(metadata[:], 2); err != nil {
+		if _, err := rw.ReadAt(metadata[:], 2); err != nil {
+			return nil, err
+		}
+		if controller.GetParam != nil {
+			param, updatedMetadata = controller.GetParam(metadata)
+		}
+		if err = header.restoreParam(&param); err != nil {
+			return nil, err
+		}
+		if param.Slots == 0 || param.Bits == 0 {
+			return nil, fmt.Errorf("%w: the parameters are not recorded in the file", MissingParamErr)
+		}
+		if err = checkHashKind(header, param); err != nil {
 			return nil, err
 		}
-		param, updatedMetadata = controller.GetParam(metadata)
+		if controller.NoHash {
+			param.Hash, param.HashKey = nil, nil
+		} else if err = param.resolveHash(); err != nil {
+			return nil, err
+		}
+		param.Bits = header.bloomBits(param.Bits)
+		if header.Shrunk() {
+			info, err := f.Stat()
+			if err != nil {
+				return nil, err
+			}
+			index, err := readBlockIndex(rw, info.Size(), header.bloomStart(controller.MetadataSize), header.bloomSize(param.Bits))
+			if err != nil {
+				return nil, err
+			}
+			rw = &blockedStorage{storage: rw, index: index}
+		}
 	}
 	if updatedMetadata != nil {
+		if controller.ReadOnly {
+			return nil, fmt.Errorf("%w: the metadata can not be updated", ReadOnlyErr)
+		}
+		if header.Sealed() {
+			return nil, fmt.Errorf("%w: the metadata can not be updated", SealedErr)
+		}
 		if len(updatedMetadata) != int(controller.MetadataSize) {
 			return nil, fmt.Errorf("%w: length of updated metadata can not satisfy", InconsistentMetadataSizeErr)
 		}
-		if _, err = f.WriteAt(updatedMetadata, LenOfMetadataSize); err != nil {
-			return nil, err
-		}
+	}
+	if controller.FaultInjector != nil {
+		rw = faultStorage{storage: rw, injector: controller.FaultInjector}
 	}
 	filter := DiskFilter{
 		param:      &param,
-		file:       muFile{f: f},
+		header:     header,
+		bloomStart: header.bloomStart(controller.MetadataSize),
+		file:       muFile{f: f, rw: rw, fsync: controller.Fsync},
 		controller: &controller,
 		closed:     make(chan struct{}),
+		readOnly:   header.Sealed() || controller.ReadOnly,
+		setBits:    header.setBits,
+		lastAdd:    timeNanos(header.LastAdd),
+		lastSync:   timeNanos(header.LastSync),
+		debug:      debug,
+		breaker:    newSyncBreaker(controller.Clock),
+
+		syncInterval: int64(syncInterval),
+		syncJitter:   int64(controller.SyncJitter),
+		rescheduled:  make(chan struct{}, 1),
 	}
-	if controller.Fsync == FsyncModeEverySec || controller.Control != nil {
-		go filter.eventEverySec()
+	filter.tasks = newTaskGroup(filter.closed)
+	if header.Adaptive() || created {
+		filter.counted = 1
+	}
+	if controller.Debug {
+		filter.file.rw = meteredStorage{storage: filter.file.rw, counters: debug}
+	}
+	if controller.Stats {
+		filter.stats = newStatsRing(controller.Clock)
+	}
+	if controller.Fsync == FsyncModeAlways && controller.GroupCommit > 0 {
+		filter.commit = newGroupCommit(&filter, controller.GroupCommit)
+		filter.unsynced = make(map[int64]uint64)
+	}
+	if controller.WriteBuffer > 0 && controller.Fsync != FsyncModeAlways && !controller.ReadOnly {
+		if v != variantClassic {
+			return nil, fmt.Errorf("write buffer of %v filters is not supported", v)
+		}
+		filter.writeBuffer = true
+	}
+	if controller.Hybrid > 0 && controller.Fsync != FsyncModeAlways {
+		if _, err = filter.hybridLocked(); err != nil {
+			return nil, err
+		}
+	}
+	if controller.Mmap && filter.hybrid == nil {
+		if err := filter.mmapLocked(); err != nil {
+			if controller.OnMmapFallback != nil {
+				controller.OnMmapFallback(err)
+			}
+		} else {
+			// the bits of FsyncModeAlways are not visible before they are synced, which takes the lock
+			filter.lockFree = controller.Fsync != FsyncModeAlways && !filter.writeBuffer && !controller.Debug && v == variantClassic
+		}
+	}
+	if controller.AccessPattern != AccessPatternNormal {
+		// unsupported on some platforms, which only lose the hint
+		_ = filter.adviseLocked(controller.AccessPattern)
+	}
+	if controller.BlockCache > 0 && filter.mapped == nil && filter.hybrid == nil {
+		filter.cache = newCachedStorage(filter.file.rw, filter.bloomStart, filter.bloomStart+header.bloomSize(param.Bits), controller.BlockCache, controller.MemoryBudget)
+		if controller.BlockCacheMin > 0 && controller.BlockCacheMin < controller.BlockCache {
+			filter.cache.adapt(controller.BlockCacheMin)
+		}
+		filter.file.rw = filter.cache
+	}
+	if err = filter.markByteOrderLocked(); err != nil {
+		return nil, err
+	}
+	if updatedMetadata != nil {
+		if err = filter.writeMetadataLocked(updatedMetadata); err != nil {
+			return nil, err
+		}
+	}
+	if err = filter.signLocked(); err != nil {
+		return nil, err
+	}
+	if controller.TrackDeltas {
+		if v != variantClassic {
+			return nil, fmt.Errorf("deltas of %v filters are not supported", v)
+		}
+		filter.changes = make(map[int64]byte)
+	}
+	if controller.Checksums && !header.Shrunk() && !controller.ReadOnly {
+		if err = filter.openChecksumsLocked(); err != nil {
+			return nil, err
+		}
+		if controller.VerifyOnOpen {
+			if err = filter.verifyChecksumsLocked(); err != nil {
+				_ = filter.closeChecksumsLocked()
+				return nil, err
+			}
+		}
+	}
+	if len(controller.FillThresholds) > 0 {
+		if err = filter.initFillThresholds(); err != nil {
+			return nil, err
+		}
+	}
+	if controller.PinnedRange.Length > 0 {
+		if err = filter.Pin(controller.PinnedRange); err != nil {
+			return nil, err
+		}
+	}
+	if controller.Journal && controller.Fsync != FsyncModeAlways && !controller.ReadOnly {
+		if err = filter.openJournalLocked(filename); err != nil {
+			_ = filter.closeChecksumsLocked()
+			return nil, err
+		}
+	}
+	if controller.AckRing != 0 {
+		filter.acks, err = openAckRing(AckRingFilename(filename), controller.AckRing, controller.ReadOnly, controller.Durability)
+		if err != nil && !(controller.ReadOnly && os.IsNotExist(err)) {
+			_ = filter.closeJournalLocked(false)
+			_ = filter.closeChecksumsLocked()
+			return nil, err
+		}
 	}
 	return &filter, nil
 }
 
-// Close should be invoked if the filter is not needed anymore
+// start starts the background goroutines, which exit once the filter is closed.
+func (f *DiskFilter) start() {
+	if f.controller.ReadOnly {
+		// nothing is written
+		return
+	}
+	if f.ticks() {
+		f.tasks.start("sync", f.eventEverySec)
+	}
+	if f.controller.MetadataSync > 0 {
+		f.tasks.start("metadata sync", f.syncMetadataEvery)
+	}
+	if f.writeBuffer {
+		f.tasks.start("write buffer flush", f.flushEvery)
+	}
+	if f.hybrid != nil {
+		f.tasks.start("hybrid flush", f.flushHybridEvery)
+	}
+}
+
+// Tasks returns the state of the background tasks of the filter, e.g. the sync every second,
+// with their last errors, which are also reported to Controller.OnBackgroundError.
+func (f *DiskFilter) Tasks() []TaskStats {
+	return f.tasks.stats()
+}
+
+// ticks returns whether the filter needs eventEverySec.
+func (f *DiskFilter) ticks() bool {
+	c := f.controller
+	return c.Fsync == FsyncModeEverySec || c.Control != nil || c.ControlStorage != nil || c.DiskFullPolicy == DiskFullPolicyBuffer || f.header.Adaptive() || len(c.FillThresholds) > 0 || f.checksums != nil ||
+		(f.cache != nil && f.cache.min > 0) || f.journal != nil
+}
+
+// Close should be invoked if the filter is not needed anymore.
+// It waits for the operations in flight and the background tasks, and the operations afterwards fail with ClosedErr.
+// See Controller.DrainTimeout to bound the wait.
 func (f *DiskFilter) Close() error {
+	if f.controller.DrainTimeout > 0 {
+		return f.closeDrained(f.controller.DrainTimeout)
+	}
+	return f.close()
+}
+
+func (f *DiskFilter) close() error {
+	if f.shutdown() {
+		// out of the lock, which the tasks take to see the filter closed
+		f.tasks.wait()
+	}
+	return nil
+}
+
+// shutdown closes the filter, and returns false if it is already closed.
+func (f *DiskFilter) shutdown() bool {
+	f.inflight.Lock()
+	defer f.inflight.Unlock()
 	select {
 	case <-f.closed:
-		return nil
+		return false
 	default:
 	}
 	close(f.closed)
+	if f.commit != nil {
+		_ = f.commit.flush()
+	}
 	f.file.mu.Lock()
 	defer f.file.mu.Unlock()
-	if f.file.fsync != FsyncModeAlways && f.file.modified {
-		f.file.modified = false
-		_ = f.file.f.Sync()
+	if len(f.pending) > 0 {
+		_ = f.flushPendingLocked()
 	}
+	// let the application persist its metadata changed since the last tick
+	f.controlLocked()
+	_ = f.persistHeaderLocked()
+	_ = f.signLocked()
+	_ = f.closeChecksumsLocked()
+	f.file.modified = false
+	if f.hybrid != nil {
+		_ = f.hybrid.flush()
+	}
+	synced := f.synced(f.file.sync(f.controller.Durability)) == nil
+	_ = f.closeJournalLocked(synced)
+	_ = f.closeAcksLocked(synced)
+	_ = f.munmapLocked()
+	f.releaseMemoryLocked()
 	_ = f.file.f.Close()
-	return nil
+	return true
+}
+
+// acquire marks an operation in flight, which Close waits for. It returns false if the filter is closed.
+func (f *DiskFilter) acquire() bool {
+	f.inflight.RLock()
+	select {
+	case <-f.closed:
+		f.inflight.RUnlock()
+		return false
+	default:
+		return true
+	}
+}
+
+// exclusive waits for the operations in flight, and blocks the others until release.
+// It returns false if the filter is closed.
+func (f *DiskFilter) exclusive() bool {
+	f.inflight.Lock()
+	select {
+	case <-f.closed:
+		f.inflight.Unlock()
+		return false
+	default:
+		return true
+	}
 }
 
-func (f *DiskFilter) eventEverySec() {
-	ticker := time.NewTicker(1 * time.Second)
-	for range ticker.C {
+// release marks the operation done.
+func (f *DiskFilter) release() {
+	f.inflight.RUnlock()
+}
+
+// eventEverySec does the periodic work every SyncInterval, a second by default.
+func (f *DiskFilter) eventEverySec(t *task) {
+	timer := time.NewTimer(f.nextTick())
+	defer timer.Stop()
+	for {
 		select {
-		case <-f.closed:
-			ticker.Stop()
+		case <-t.done():
+			return
+		case <-f.rescheduled:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(f.nextTick())
+			continue
+		case <-timer.C:
+		}
+		if !f.acquire() {
 			return
-		default:
+		}
+		if f.cache != nil && f.cache.min > 0 {
+			f.cache.resize(availableMemory())
 		}
 		f.file.mu.Lock()
-		if f.file.fsync == FsyncModeEverySec && f.file.modified {
-			f.file.modified = false
-			_ = f.file.f.Sync()
+		if len(f.pending) > 0 {
+			_ = t.report(f.flushPendingLocked())
 		}
-		if f.controller.Control != nil {
-			f.controller.Control(f.file.f, f.file.modified)
+		f.controlLocked()
+		if f.file.modified {
+			_ = t.report(f.persistHeaderLocked())
+			_ = t.report(f.signLocked())
+			_ = t.report(f.updateChecksumsLocked())
 		}
+		_ = t.report(f.syncEverySecLocked())
+		_ = t.report(f.checkpointLocked())
 		f.file.mu.Unlock()
+		f.release()
+		// out of the lock, since the callback may rotate or close the filter
+		t.detach(f.checkFillThresholds)
+		timer.Reset(f.nextTick())
 	}
 }
 
 func (f *DiskFilter) bloomOffset(x, y uint64, i int) uint64 {
-	return (x + uint64(i)*y) % f.param.Bits
+	if f.header.Salted() {
+		x, y = saltHash(x, y, f.header.Salt)
+	}
+	if f.header.Blocked() {
+		return blockedOffset(x, y, i, f.param.Bits, uint64(f.header.BlockSize)*8)
+	}
+	if f.header.Hardened() {
+		y = hardenedY(x, y, f.param.Bits)
+	}
+	h := x + uint64(i)*y
+	if f.header.FastRange() {
+		// Lemire's fast range: floor(h * Bits / 2^64)
+		offset, _ := bits.Mul64(h, f.param.Bits)
+		return offset
+	}
+	return h % f.param.Bits
 }
 
 // fileOffset returns the fileOffset relative to the beginning of the file
 func (f *DiskFilter) fileOffset(bloomOffset int64) int64 {
-	return LenOfMetadataSize + int64(f.controller.MetadataSize) + bloomOffset
+	return f.bloomStart + bloomOffset
+}
+
+// writeByteLocked writes the byte at pos, keeping the pinned copy up to date.
+func (f *DiskFilter) writeByteLocked(val byte, pos int64) error {
+	if _, err := f.file.rw.WriteAt([]byte{val}, pos); err != nil {
+		return err
+	}
+	return f.wroteLocked(val, pos)
+}
+
+// writeGatheredLocked writes the changed bytes gathered into one buffer, by a single write per run of the bytes
+// within a page, whose gaps are read first, so that an add of a blocked filter is a single write.
+// The runs start at firstWrite. The bytes written are removed from changed, leaving the ones failed to be written,
+// and their sorted positions are returned.
+func (f *DiskFilter) writeGatheredLocked(changed map[int64]byte) (written []int64, err error) {
+	positions := make([]int64, 0, len(changed))
+	for pos := range changed {
+		positions = append(positions, pos)
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i] < positions[j]
+	})
+	// runs are the indexes of positions starting the runs, and buf gathers the runs back to back
+	var runs []int
+	var size int64
+	for i, pos := range positions {
+		if i == 0 || pos/pageSize != positions[i-1]/pageSize {
+			runs = append(runs, i)
+			size++
+		} else {
+			size += pos - positions[i-1]
+		}
+	}
+	buf := make([]byte, size)
+	spans := make([][]byte, len(runs))
+	for r, i := range runs {
+		end := len(positions)
+		if r+1 < len(runs) {
+			end = runs[r+1]
+		}
+		run := positions[i:end]
+		spans[r], buf = buf[:run[len(run)-1]+1-run[0]], buf[run[len(run)-1]+1-run[0]:]
+		if len(spans[r]) > len(run) {
+			if _, err = f.file.rw.ReadAt(spans[r], run[0]); err != nil {
+				return nil, err
+			}
+		}
+		for _, pos := range run {
+			spans[r][pos-run[0]] = changed[pos]
+		}
+	}
+	first := f.firstWrite(len(runs))
+	for k := range runs {
+		r := (first + k) % len(runs)
+		end := len(positions)
+		if r+1 < len(runs) {
+			end = runs[r+1]
+		}
+		run := positions[runs[r]:end]
+		if _, err = f.file.rw.WriteAt(spans[r], run[0]); err != nil {
+			return nil, err
+		}
+		for _, pos := range run {
+			if err = f.wroteLocked(changed[pos], pos); err != nil {
+				return nil, err
+			}
+			delete(changed, pos)
+		}
+	}
+	return positions, nil
+}
+
+// wroteLocked keeps the pinned copy, the checksums and the deltas up to date with the byte written at pos.
+func (f *DiskFilter) wroteLocked(val byte, pos int64) error {
+	if f.controller.VerifyWrites {
+		if err := f.verifyLocked(val, pos); err != nil {
+			return err
+		}
+	}
+	if f.pinned.contains(pos) {
+		f.pinned.buf[pos-f.pinned.start] = val
+	}
+	f.checksums.markDirty(pos-f.bloomStart, 1)
+	if f.changes != nil {
+		f.changes[pos-f.bloomStart] = val
+	}
+	if f.written != nil {
+		f.written[pos] = true
+	}
+	return nil
+}
+
+// KeyHash is the double hash of an entry.
+// Compute it once by Hash and reuse it across filters sharing the same hash function.
+type KeyHash struct {
+	X, Y uint64
+}
+
+// Hash returns the double hash of an entry.
+func (f *DiskFilter) Hash(b []byte) (h KeyHash) {
+	if f.param.Hash == nil {
+		panic("disk_bloom: the filter is opened without a hash, see Controller.NoHash")
+	}
+	f.phase("hash", func() {
+		h.X, h.Y = f.param.Hash(b)
+	})
+	return h
 }
 
-// Exist returns if an entry is in the filter
+// Exist returns if an entry is in the filter.
+// An entry failed to be read is reported as not in, see ExistErr.
 func (f *DiskFilter) Exist(b []byte) bool {
-	x, y := f.param.Hash(b)
-	var offsets = make([]uint64, f.param.Slots)
-	for i := 0; i < int(f.param.Slots); i++ {
+	return f.ExistHashed(f.Hash(b))
+}
+
+// ExistErr is like Exist, but returns the error if the filter failed to be read,
+// instead of reporting the entry as not in.
+func (f *DiskFilter) ExistErr(b []byte) (bool, error) {
+	return f.existHashed(f.Hash(b))
+}
+
+// ExistHashed is like Exist, but takes the hash of the entry.
+func (f *DiskFilter) ExistHashed(h KeyHash) bool {
+	exist, _ := f.existHashed(h)
+	return exist
+}
+
+func (f *DiskFilter) existHashed(h KeyHash) (exist bool, err error) {
+	if !f.acquire() {
+		return false, ClosedErr
+	}
+	defer f.release()
+	if tr := f.startTrace(TraceOpExist, h); tr != nil {
+		defer func() { f.endTrace(tr, exist, err) }()
+		return f.existTraced(h, tr)
+	}
+	return f.existTraced(h, nil)
+}
+
+// existTraced looks up the entry with the filter acquired, and records the details in tr if traced.
+func (f *DiskFilter) existTraced(h KeyHash, tr *Trace) (exist bool, err error) {
+	if f.lockFree {
+		exist = f.existMapped(h)
+		f.countLookup(exist)
+		return exist, nil
+	}
+	offsets := f.offsets(h, f.lookupSlots())
+	var batch uint64
+	f.phase("io", func() {
+		f.rlockTraced(tr)
+		exist, batch, err = f.existLocked(offsets, tr)
+		f.file.mu.RUnlock()
+	})
+	if err != nil {
+		return false, err
+	}
+	if exist && batch > 0 {
+		// do not report an entry before its bits are durable, nor the one whose bits failed to be synced
+		exist = f.commit.wait(batch) == nil
+	}
+	f.countLookup(exist)
+	return exist, nil
+}
+
+// offsets returns the sorted bit offsets of the first slots probes of an entry in the bloom filter.
+func (f *DiskFilter) offsets(h KeyHash, slots int) []uint64 {
+	x, y := h.X, h.Y
+	var offsets = make([]uint64, slots)
+	for i := 0; i < slots; i++ {
 		offsets[i] = f.bloomOffset(x, y, i)
 	}
 	// sort to improve the performance on HDD
 	sort.Slice(offsets, func(i, j int) bool {
 		return offsets[i] < offsets[j]
 	})
+	return offsets
+}
+
+// existLocked returns if all bits at offsets are set, and the latest group commit batch those bits are waiting for,
+// or the error of reading them.
+func (f *DiskFilter) existLocked(offsets []uint64, tr *Trace) (exist bool, batch uint64, err error) {
+	r := pageReader{f: f, positions: f.probePositions(offsets)}
+	defer f.account(&r)
+	defer tr.read(&r)
 	var m = make(map[int64]byte)
-	f.file.mu.Lock()
-	defer f.file.mu.Unlock()
-	for _, offset := range offsets {
-		var b [1]byte
-		pos := f.fileOffset(int64(offset / 8))
-		if val, ok := m[pos]; ok {
-			b[0] = val
-		} else {
-			f.file.f.ReadAt(b[:], pos)
-			m[pos] = b[0]
+	for i, offset := range offsets {
+		pos := r.positions[i]
+		val, ok := m[pos]
+		if !ok {
+			val = r.readByte(i)
+			m[pos] = val
+			if b := f.unsynced[pos]; b > batch {
+				batch = b
+			}
 		}
-		if b[0]&(1<<(offset%8)) == 0 {
-			return false
+		if val&(1<<(offset%8)) == 0 {
+			return false, 0, r.err
 		}
 	}
-	return true
+	if r.err != nil {
+		return false, 0, r.err
+	}
+	return true, batch, nil
 }
 
 // ExistOrAdd returns whether the entry was in the filter, and adds an entry to the filter if it was not in.
 func (f *DiskFilter) ExistOrAdd(b []byte) (exist bool) {
-	x, y := f.param.Hash(b)
-	var offsets = make([]uint64, f.param.Slots)
-	for i := 0; i < int(f.param.Slots); i++ {
-		offsets[i] = f.bloomOffset(x, y, i)
+	exist, _ = f.ExistOrAddErr(b)
+	return exist
+}
+
+// ExistOrAddHashed is like ExistOrAdd, but takes the hash of the entry.
+func (f *DiskFilter) ExistOrAddHashed(h KeyHash) (exist bool) {
+	exist, _ = f.existOrAddHashed(h)
+	return exist
+}
+
+// Add adds an entry to the filter without reporting whether it was in, e.g. for bulk loading known-unique entries.
+// Unlike ExistOrAdd, it only writes the bytes whose bits change.
+func (f *DiskFilter) Add(b []byte) error {
+	return f.AddHashed(f.Hash(b))
+}
+
+// AddHashed is like Add, but takes the hash of the entry.
+func (f *DiskFilter) AddHashed(h KeyHash) (err error) {
+	if !f.acquire() {
+		return ClosedErr
 	}
-	// sort to improve the performance on HDD
-	sort.Slice(offsets, func(i, j int) bool {
-		return offsets[i] < offsets[j]
+	defer f.release()
+	offsets := f.offsets(h, f.slots())
+	var batch uint64
+	var novel bool
+	f.phase("io", func() {
+		f.lock()
+		novel, batch, err = f.addLocked(offsets)
+		f.file.mu.Unlock()
 	})
+	if batch > 0 {
+		// do not return before the bits are durable
+		if e := f.commit.wait(batch); err == nil {
+			err = e
+		}
+	}
+	if err == nil {
+		var n uint64
+		if novel {
+			n = 1
+		}
+		f.countAdds(1, n)
+	}
+	return err
+}
+
+// addLocked sets the bits at offsets, and writes the bytes changed.
+// It returns whether any bit changed, and the group commit batch which the bits are waiting for, if any.
+func (f *DiskFilter) addLocked(offsets []uint64) (novel bool, batch uint64, err error) {
+	r := pageReader{f: f, positions: f.probePositions(offsets)}
+	vals := make(map[int64]byte)
+	changed := make(map[int64]byte)
+	var set uint64
+	for i, offset := range offsets {
+		pos := r.positions[i]
+		val, ok := vals[pos]
+		if !ok {
+			val = r.readByte(i)
+		}
+		if bit := byte(1 << (offset % 8)); val&bit == 0 {
+			val |= bit
+			changed[pos] = val
+			set++
+		}
+		vals[pos] = val
+	}
+	f.account(&r)
+	if r.err != nil {
+		// the bytes are not written, which would clear the bits failed to be read
+		return false, 0, r.err
+	}
+	novel = len(changed) > 0
+	if batch, err = f.writeChangedLocked(changed, 1, 0); err == nil {
+		atomic.AddUint64(&f.setBits, set)
+	}
+	return novel, batch, err
+}
+
+// ExistOrAddErr is like ExistOrAdd, but returns the error if the entry failed to be added.
+// What happens when the disk is full depends on the DiskFullPolicy.
+func (f *DiskFilter) ExistOrAddErr(b []byte) (exist bool, err error) {
+	return f.existOrAddHashed(f.Hash(b))
+}
+
+func (f *DiskFilter) existOrAddHashed(h KeyHash) (exist bool, err error) {
+	if !f.acquire() {
+		return false, ClosedErr
+	}
+	defer f.release()
+	tr := f.startTrace(TraceOpExistOrAdd, h)
+	if tr != nil {
+		defer func() { f.endTrace(tr, exist, err) }()
+	}
+	offsets := f.offsets(h, f.slots())
+	var batch uint64
+	f.phase("io", func() {
+		f.lockTraced(tr)
+		exist, batch, err = f.existOrAddLocked(offsets, tr)
+		f.file.mu.Unlock()
+	})
+	if batch > 0 {
+		// do not return before the bits are durable
+		if e := f.commit.wait(batch); e != nil && err == nil {
+			// the entry, or the bits it is found by, failed to be synced
+			exist, err = false, e
+		}
+	}
+	if exist {
+		f.countAdds(1, 0)
+	} else if err == nil {
+		f.countAdds(1, 1)
+	}
+	return exist, err
+}
+
+// existOrAddLocked adds the bits at offsets.
+// It returns the group commit batch which the bits are waiting for, if any.
+func (f *DiskFilter) existOrAddLocked(offsets []uint64, tr *Trace) (exist bool, batch uint64, err error) {
+	r := pageReader{f: f, positions: f.probePositions(offsets)}
 	var m = make(map[int64]byte)
-	f.file.mu.Lock()
-	defer f.file.mu.Unlock()
+	// prev are the bytes read, restored if the sync of FsyncModeAlways fails
+	var prev = make(map[int64]byte)
 	exist = true
-	for _, offset := range offsets {
-		var b [1]byte
-		pos := f.fileOffset(int64(offset / 8))
-		if val, ok := m[pos]; ok {
-			b[0] = val
-		} else {
-			f.file.f.ReadAt(b[:], pos)
-			m[pos] = b[0]
+	var set uint64
+	for i, offset := range offsets {
+		pos := r.positions[i]
+		val, ok := m[pos]
+		if !ok {
+			val = r.readByte(i)
+			m[pos], prev[pos] = val, val
+			if b := f.unsynced[pos]; b > batch {
+				batch = b
+			}
 		}
-		if b[0]&(1<<(offset%8)) == 0 {
+		if val&(1<<(offset%8)) == 0 {
 			exist = false
 		}
+		if m[pos]&(1<<(offset%8)) == 0 {
+			set++
+		}
 		m[pos] |= 1 << (offset % 8)
 	}
+	f.account(&r)
+	tr.read(&r)
+	if r.err != nil {
+		return false, 0, r.err
+	}
 	if exist {
-		return
+		return true, batch, nil
+	}
+	if f.readOnly {
+		return false, 0, f.readOnlyErr()
 	}
-	for _, offset := range offsets {
-		pos := f.fileOffset(int64(offset / 8))
-		if val, ok := m[pos]; ok {
-			f.file.f.WriteAt([]byte{val}, pos)
+	if f.writeBuffer {
+		if err = f.bufferLocked(m); err != nil {
+			return false, 0, f.onWriteErrorLocked(err, m)
+		}
+		atomic.AddUint64(&f.setBits, set)
+		f.touchAddLocked()
+		return false, 0, nil
+	}
+	if err = f.journalBytesLocked(m); err != nil {
+		return false, 0, err
+	}
+	for pos, val := range m {
+		if prev[pos] == val {
 			delete(m, pos)
 		}
 	}
+	written, err := f.writeGatheredLocked(m)
+	if err != nil {
+		return false, 0, f.onWriteErrorLocked(err, m)
+	}
+	f.noteIO(ioOpWrite, nil)
+	f.touchAddLocked()
+	f.accountWrites(written, 1)
+	tr.wrote(len(written))
 	f.file.modified = true
-	return
+	if f.file.fsync == FsyncModeAlways {
+		if f.commit != nil {
+			atomic.AddUint64(&f.setBits, set)
+			return false, f.commit.enqueueLocked(written), nil
+		}
+		// the barrier: the entry is not visible to others until the lock is released
+		if err = f.syncFile(); err != nil {
+			return false, 0, f.unwriteLocked(err, prev, written)
+		}
+	}
+	atomic.AddUint64(&f.setBits, set)
+	return false, 0, nil
+}
+
+// unwriteLocked restores the bytes written before the failed sync of FsyncModeAlways to prev,
+// so that the entry is not visible without being durable, and returns err.
+// The filter becomes read-only if they fail to be restored.
+func (f *DiskFilter) unwriteLocked(err error, prev map[int64]byte, written []int64) error {
+	restored := make(map[int64]byte, len(written))
+	for _, pos := range written {
+		restored[pos] = prev[pos]
+	}
+	if _, e := f.writeGatheredLocked(restored); e != nil {
+		f.noteIO(ioOpWrite, e)
+		f.readOnly = true
+	}
+	return err
 }
 
 // Size returns the size of the filter in bytes
@@ -4,8 +4,11 @@ import (
 	"encoding/binary"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const metadataSize = 64
@@ -15,6 +18,9 @@ var (
 )
 
 type filterObj struct {
+	// hits is the decayed number of lookups found in the filter, see WithAdaptiveProbeOrder.
+	// It is accessed atomically, and is the first field to be 64-bit aligned.
+	hits     uint64
 	filename string
 	filter   *DiskFilter
 	added    uint64
@@ -34,7 +40,7 @@ func (o *filterObj) control(f *os.File, modified bool) {
 		return
 	}
 	var b [8]byte
-	binary.LittleEndian.PutUint64(b[:], o.added)
+	binary.LittleEndian.PutUint64(b[:], atomic.LoadUint64(&o.added))
 	// file: |len of metadata size(2)|added entries(8)|expected max entries(8)|slots(1)|bits(8)|bloom|
 	f.WriteAt(b[:], LenOfMetadataSize)
 }
@@ -60,13 +66,78 @@ func (m Metadata) Encode() []byte {
 
 // FilterGroup manages a group of DiskFilter.
 // To make sure each filter meets the best performance, it will add new filters to the group if necessary.
+//
+// The rotation never blocks on creating files: the next filter is always prepared in the background,
+// and the handover to it swaps the list of filters atomically once the active filter is full.
+// If the next filter is not ready in time, the active filter keeps accepting entries until it is,
+// but ExistOrAdd waits for it once the active filter is overfilled by 1/16.
+// The handover waits for the in-flight ExistOrAdd to drain, so that an entry is never added to two filters
+// by concurrent ExistOrAdd. Exist never waits for it.
 type FilterGroup struct {
-	filters      []*filterObj
+	// filters is a []*filterObj, which is replaced instead of modified
+	filters      atomic.Value
 	nextFilename func() string
 	fsync        FsyncMode
 	n            uint64
 	param        FilterParam
-	mu           sync.Mutex
+	// ExistOrAdd holds the read lock, and the handover holds the write lock.
+	mu sync.RWMutex
+	// preparing is 1 if the next filter is being created in the background.
+	preparing int32
+	// next is the prepared filter, guarded by mu
+	next *filterObj
+	// ready is closed when the preparation is done, guarded by mu
+	ready chan struct{}
+	// prepareErr is the error of the last failed preparation, guarded by mu
+	prepareErr error
+	// tasks run the background preparation
+	tasks *taskGroup
+	// filename returns the filename of the given index
+	filename func(index string) string
+	// skipped is the files matching the pattern but not used, by the cleaned filename
+	skipped map[string]SkippedFile
+	// version is incremented once filters are replaced by Consolidate, before the replaced ones are closed.
+	// A lookup missing the entry looks up again if the version changed meanwhile, see stable.
+	version uint64
+	// policy is the RotationPolicy, or nil to rotate by the count of entries
+	policy RotationPolicy
+	// parallelism is the number of files opened at once by NewGroup
+	parallelism int
+	// readRepair adds the entries found only in older filters to the active one, see WithReadRepair
+	readRepair bool
+	// naming is how the files are named, and stamp is the time of the newest file of NamingSchemeTimestamp
+	naming NamingScheme
+	stamp  time.Time
+	// maxFiles and diskBudget bound the files of the group, see WithMaxFiles and WithDiskBudget
+	maxFiles   int
+	diskBudget int64
+	// tracer and slowOp are the Controller.Tracer and Controller.SlowOp of the filters, see WithTracer
+	tracer Tracer
+	slowOp time.Duration
+	// order is the order of the lookups by the hits of the filters, or nil to probe the oldest first, see WithAdaptiveProbeOrder
+	order *probeOrder
+}
+
+// GroupOption configures a FilterGroup opened by NewGroup.
+type GroupOption func(g *FilterGroup)
+
+// WithOpenParallelism opens up to n existing files of the group at once, so that opening a group of hundreds of filters
+// is not bound by the latency of the disk. Only the metadata and the header of a file are read on opening.
+func WithOpenParallelism(n int) GroupOption {
+	return func(g *FilterGroup) {
+		g.parallelism = n
+	}
+}
+
+// WithReadRepair makes ExistOrAdd also add an entry found only in older filters to the active filter,
+// so that the entries seen frequently survive the rotation of the old filters ("refresh on access").
+// It changes the semantics of the windows: an entry is kept as long as it is seen within the retained windows,
+// instead of since it was first seen, and FirstSeenWindow still reports the oldest filter having it.
+// Exist never adds.
+func WithReadRepair() GroupOption {
+	return func(g *FilterGroup) {
+		g.readRepair = true
+	}
 }
 
 // NewGroup returns a FilterGroup, each filter is a file.
@@ -74,9 +145,13 @@ type FilterGroup struct {
 // the Pattern should includes a "*", and the index replaces the last "*".
 // n is the expected number of entries in single file.
 // p is the expected false positive rate.
-func NewGroup(pattern string, fsync FsyncMode, n uint64, p float64, hash func([]byte) (uint64, uint64)) (*FilterGroup, error) {
+// They apply to new files, and existing files keep the parameters they were created with.
+// Files matching the pattern that are not compatible filters, or out of the sequence, are skipped and reported by Skipped.
+// The active filter is rotated once it has n entries, unless another policy is given by WithRotationPolicy.
+func NewGroup(pattern string, fsync FsyncMode, n uint64, p float64, hash func([]byte) (uint64, uint64), opts ...GroupOption) (*FilterGroup, error) {
 	slots, bits := OptimalParam(n, p)
 	g := &FilterGroup{
+		tasks: newTaskGroup(nil),
 		fsync: fsync,
 		n:     n,
 		param: FilterParam{
@@ -85,46 +160,260 @@ func NewGroup(pattern string, fsync FsyncMode, n uint64, p float64, hash func([]
 			Hash:  hash,
 		},
 	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	if err := g.checkLimits(bits); err != nil {
+		return nil, err
+	}
+	g.filters.Store([]*filterObj(nil))
 	if err := g.resolvePatternAndSearch(pattern, fsync, hash); err != nil {
 		return nil, err
 	}
 
-	if len(g.filters) == 0 || g.filters[len(g.filters)-1].added >= g.filters[len(g.filters)-1].expected {
+	if filters := g.load(); len(filters) == 0 || filters[len(filters)-1].added >= filters[len(filters)-1].expected {
 		// last filter is full
-		g.mu.Lock()
-		defer g.mu.Unlock()
-		if err := g.appendNewFilter(); err != nil {
+		obj, err := g.newFilter()
+		if err != nil {
+			_ = g.Close()
 			return nil, err
 		}
+		g.filters.Store(append(filters, obj))
 	}
+	if err := g.evictLocked(); err != nil {
+		_ = g.Close()
+		return nil, err
+	}
+	g.prepare()
 	return g, nil
 }
 
-func (g *FilterGroup) appendNewFilter() error {
-	obj := new(filterObj)
-	if filter, err := New(
-		g.nextFilename(),
+// load returns the current filters. The last one is the active filter.
+func (g *FilterGroup) load() []*filterObj {
+	return g.filters.Load().([]*filterObj)
+}
+
+// prepare creates the next filter in the background.
+// It should be invoked with mu held, or before the filterGroup is used.
+func (g *FilterGroup) prepare() {
+	if !atomic.CompareAndSwapInt32(&g.preparing, 0, 1) {
+		return
+	}
+	ready := make(chan struct{})
+	g.ready = ready
+	g.tasks.start("rotation", func(t *task) {
+		defer close(ready)
+		start := time.Now()
+		obj, err := g.newFilter()
+		g.traceRotate(start, obj, err)
+		if err != nil {
+			// the next full ExistOrAdd will retry
+			_ = t.report(err)
+			g.mu.Lock()
+			g.prepareErr = err
+			atomic.StoreInt32(&g.preparing, 0)
+			g.mu.Unlock()
+			return
+		}
+		g.mu.Lock()
+		g.next = obj
+		g.prepareErr = nil
+		atomic.StoreInt32(&g.preparing, 0)
+		g.mu.Unlock()
+		// the active filter may be already full
+		g.handover()
+	})
+}
+
+// lockIdle locks mu after the background preparation is done.
+// No preparation starts until mu is unlocked.
+func (g *FilterGroup) lockIdle() {
+	for {
+		g.tasks.wait()
+		g.mu.Lock()
+		if atomic.LoadInt32(&g.preparing) == 0 {
+			return
+		}
+		g.mu.Unlock()
+	}
+}
+
+// SetCapacity changes the parameters of the filters created afterwards, e.g. when the traffic grows,
+// to n expected entries in single file and the expected false positive rate p.
+// Existing filters keep their own parameters, which are read from their metadata when the group is opened again.
+// The active filter is not affected, so call it before the rotation.
+func (g *FilterGroup) SetCapacity(n uint64, p float64) error {
+	plan, err := PlanParam(n, p)
+	if err != nil {
+		return err
+	}
+	if err = g.checkLimits(plan.Bits); err != nil {
+		return err
+	}
+	g.lockIdle()
+	defer g.mu.Unlock()
+	g.n = n
+	g.param.Slots, g.param.Bits = plan.Slots, plan.Bits
+	if g.next != nil {
+		// the prepared filter is empty, so prepare it again with the new parameters
+		next := g.next
+		g.next = nil
+		_ = next.filter.Close()
+		if err := os.Remove(next.filename); err != nil {
+			return err
+		}
+	}
+	g.prepare()
+	return nil
+}
+
+// rotate hands over to the next filter regardless of whether the active filter is full,
+// and returns the filters before it.
+func (g *FilterGroup) rotate() ([]*filterObj, error) {
+	for waited := false; ; waited = true {
+		g.mu.Lock()
+		if g.next != nil {
+			filters := g.load()
+			if err := filters[len(filters)-1].retire(); err != nil {
+				g.mu.Unlock()
+				return nil, err
+			}
+			g.filters.Store(append(filters[:len(filters):len(filters)], g.next))
+			g.next = nil
+			err := g.evictLocked()
+			g.prepare()
+			// the filters before the active one, which may be evicted
+			filters = g.load()
+			g.mu.Unlock()
+			if err != nil {
+				return nil, err
+			}
+			return filters[:len(filters)-1], nil
+		}
+		if waited && atomic.LoadInt32(&g.preparing) == 0 && g.prepareErr != nil {
+			err := g.prepareErr
+			g.mu.Unlock()
+			return nil, fmt.Errorf("%w: %v", PrepareErr, err)
+		}
+		g.prepare()
+		ready := g.ready
+		g.mu.Unlock()
+		<-ready
+	}
+}
+
+// retire marks the filter full, so that it is not the active filter when the group is opened again.
+// It should be invoked with mu held.
+func (o *filterObj) retire() error {
+	added := atomic.LoadUint64(&o.added)
+	if added >= o.expected {
+		return nil
+	}
+	atomic.StoreUint64(&o.expected, added)
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], added)
+	f := o.filter
+	f.file.mu.Lock()
+	defer f.file.mu.Unlock()
+	// file: |len of metadata size(2)|added entries(8)|expected max entries(8)|...
+	if _, err := f.file.rw.WriteAt(b[:], LenOfMetadataSize+8); err != nil {
+		return err
+	}
+	f.file.modified = true
+	return nil
+}
+
+// Active returns the active filter, which the entries are added to.
+func (g *FilterGroup) Active() *DiskFilter {
+	filters := g.load()
+	return filters[len(filters)-1].filter
+}
+
+// RotateNow rotates to a new active filter even if the active one is not full,
+// e.g. at a business boundary like midnight UTC. The retiring filter is regarded as full from now on.
+// To act on the retiring filter, e.g. to seal it, get it by Active before the rotation.
+func (g *FilterGroup) RotateNow() error {
+	_, err := g.rotate()
+	return err
+}
+
+// Tasks returns the state of the background tasks of the group, i.e. the preparation of the next filter,
+// with their last errors. The tasks of the filters are returned by their Tasks.
+func (g *FilterGroup) Tasks() []TaskStats {
+	return g.tasks.stats()
+}
+
+// handover switches to the next filter if the active one is full.
+func (g *FilterGroup) handover() {
+	for waited := false; ; waited = true {
+		g.mu.Lock()
+		filters := g.load()
+		active := filters[len(filters)-1]
+		added, expected := atomic.LoadUint64(&active.added), active.expected
+		if !g.full(active, added) {
+			g.mu.Unlock()
+			return
+		}
+		if g.next != nil {
+			// a policy may rotate before the expected number of entries, so mark it full for the next open
+			if err := active.retire(); err != nil {
+				g.mu.Unlock()
+				return
+			}
+			// copy to avoid modifying the slice in use
+			g.filters.Store(append(filters[:len(filters):len(filters)], g.next))
+			g.next = nil
+			// a failed eviction is retried on the next rotation
+			_ = g.evictLocked()
+			g.prepare()
+			g.mu.Unlock()
+			return
+		}
+		g.prepare()
+		ready := g.ready
+		g.mu.Unlock()
+		if waited || added < expected+expected/16 {
+			return
+		}
+		// overfilled, wait for the next filter
+		<-ready
+	}
+}
+
+func (g *FilterGroup) newFilter() (*filterObj, error) {
+	obj := &filterObj{filename: g.nextFilename()}
+	if err := g.createFilter(obj, g.param, Metadata{
+		Added:    0,
+		Expected: g.n,
+		Slots:    g.param.Slots,
+		Bits:     g.param.Bits,
+	}); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// createFilter opens the filter of obj with param, creating the file if it does not exist, and writes m to its metadata.
+func (g *FilterGroup) createFilter(obj *filterObj, param FilterParam, m Metadata) error {
+	filter, err := New(
+		obj.filename,
 		Controller{
 			Fsync:        g.fsync,
 			MetadataSize: metadataSize,
 			Control:      obj.control,
-			GetParam: func(metadata []byte) (param FilterParam, updatedMetadata []byte) {
-				obj.added = 0
-				obj.expected = g.n
-				return g.param, Metadata{
-					Added:    0,
-					Expected: g.n,
-					Slots:    g.param.Slots,
-					Bits:     g.param.Bits,
-				}.Encode()
+			Tracer:       g.tracer,
+			SlowOp:       g.slowOp,
+			GetParam: func(metadata []byte) (FilterParam, []byte) {
+				obj.added = m.Added
+				obj.expected = m.Expected
+				return param, m.Encode()
 			},
 		},
-	); err != nil {
+	)
+	if err != nil {
 		return err
-	} else {
-		obj.filter = filter
 	}
-	g.filters = append(g.filters, obj)
+	obj.filter = filter
 	return nil
 }
 
@@ -133,80 +422,254 @@ func (g *FilterGroup) resolvePatternAndSearch(pattern string, fsync FsyncMode, h
 	if starIndex == -1 {
 		return InvalidPatternErr
 	}
+	g.filename = func(index string) string {
+		return pattern[:starIndex] + index + pattern[starIndex+1:]
+	}
 	g.nextFilename = func() string {
-		return fmt.Sprintf("%v%v%v", pattern[:starIndex], len(g.filters), pattern[starIndex+1:])
+		return g.positionFilename(len(g.load()))
 	}
-	for {
-		if _, err := os.Stat(g.nextFilename()); os.IsNotExist(err) {
-			return nil
-		}
-		obj := new(filterObj)
-		if filter, err := New(
-			g.nextFilename(),
-			Controller{
-				Fsync:        fsync,
-				MetadataSize: metadataSize,
-				Control:      obj.control,
-				GetParam: func(metadata []byte) (FilterParam, []byte) {
-					m := parseMetadata(metadata)
-					obj.added = m.Added
-					obj.expected = m.Expected
-					return FilterParam{
-						Slots: m.Slots,
-						Bits:  m.Bits,
-						Hash:  hash,
-					}, nil
-				},
-			},
-		); err != nil {
-			return err
-		} else {
-			obj.filter = filter
+	if g.naming == NamingSchemeTimestamp {
+		g.nextFilename = g.timestampFilename
+	}
+	// known is the set of the filters and the spare
+	known := make(map[string]bool)
+	defer g.skipUnknown(pattern, known)
+	filenames := g.discover(pattern, starIndex)
+	metadata := make([]Metadata, len(filenames))
+	errs := make([]error, len(filenames))
+	parallel(g.parallelism, len(filenames), func(i int) {
+		metadata[i], errs[i] = readGroupMetadata(filenames[i])
+	})
+	// the filters to open, in order, decided by their metadata
+	var objs []*filterObj
+	spare := false
+	for i, filename := range filenames {
+		if errs[i] != nil {
+			g.skip(filename, errs[i])
+			continue
 		}
-		g.filters = append(g.filters, obj)
-		// loop until the last filter is not full
-		if obj.added < obj.expected {
-			return nil
+		m := metadata[i]
+		if len(objs) > 0 && objs[len(objs)-1].added < objs[len(objs)-1].expected {
+			// the last filter is not full, so this can only be the next filter prepared before
+			if m.Added == 0 && !spare {
+				spare = true
+				known[filepath.Clean(filename)] = true
+				if m.Slots != g.param.Slots || m.Bits != g.param.Bits || g.naming == NamingSchemeTimestamp {
+					// prepared with other parameters, which is recreated, or named before, which is named again
+					_ = os.Remove(filename)
+				}
+			} else {
+				g.skip(filename, fmt.Errorf("%w: after the active filter", OrphanedFileErr))
+			}
+			continue
 		}
+		objs = append(objs, &filterObj{filename: filename, added: m.Added, expected: m.Expected})
 	}
+	parallel(g.parallelism, len(objs), func(i int) {
+		errs[i] = g.open(objs[i], fsync, hash)
+	})
+	var filters []*filterObj
+	for i, obj := range objs {
+		if errs[i] != nil {
+			g.skip(obj.filename, fmt.Errorf("%w: %v", ForeignFileErr, errs[i]))
+			continue
+		}
+		if len(filters) > 0 && filters[len(filters)-1].added < filters[len(filters)-1].expected {
+			// a filter failed to open, after which the filters are out of the sequence
+			_ = obj.filter.Close()
+			g.skip(obj.filename, fmt.Errorf("%w: after the active filter", OrphanedFileErr))
+			continue
+		}
+		known[filepath.Clean(obj.filename)] = true
+		filters = append(filters, obj)
+	}
+	g.filters.Store(filters)
+	return nil
+}
+
+// open opens the filter of an existing file.
+func (g *FilterGroup) open(obj *filterObj, fsync FsyncMode, hash func([]byte) (uint64, uint64)) error {
+	filter, err := New(
+		obj.filename,
+		Controller{
+			Fsync:        fsync,
+			MetadataSize: metadataSize,
+			Control:      obj.control,
+			Tracer:       g.tracer,
+			SlowOp:       g.slowOp,
+			GetParam: func(metadata []byte) (FilterParam, []byte) {
+				m := parseMetadata(metadata)
+				obj.added = m.Added
+				obj.expected = m.Expected
+				return FilterParam{
+					Slots: m.Slots,
+					Bits:  m.Bits,
+					Hash:  hash,
+				}, nil
+			},
+		},
+	)
+	obj.filter = filter
+	return err
+}
+
+// parallel invokes fn for each of [0, n) with at most parallelism of them at once.
+func parallel(parallelism int, n int, fn func(i int)) {
+	if parallelism <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// Hash returns the double hash of an entry.
+func (g *FilterGroup) Hash(b []byte) KeyHash {
+	x, y := g.param.Hash(b)
+	return KeyHash{X: x, Y: y}
 }
 
 // ExistOrAdd returns whether the entry was in the filter, and adds an entry to the filterGroup if it was not in.
 // TODO: batch?
 func (g *FilterGroup) ExistOrAdd(b []byte) (exist bool) {
-	defer func() {
-		if exist == false {
-			g.mu.Lock()
-			defer g.mu.Unlock()
-			g.filters[len(g.filters)-1].added++
-			if g.filters[len(g.filters)-1].added < g.filters[len(g.filters)-1].expected {
-				return
-			}
-			if err := g.appendNewFilter(); err != nil {
-				// TODO:
-				// log.Println("[error] ExistOrAdd: appendNewFilter:", err)
-			}
+	return g.ExistOrAddHashed(g.Hash(b))
+}
+
+// ExistOrAddHashed is like ExistOrAdd, but takes the hash of the entry.
+func (g *FilterGroup) ExistOrAddHashed(h KeyHash) (exist bool) {
+	exist, full := g.existOrAddHashed(h)
+	if full {
+		g.handover()
+	}
+	return exist
+}
+
+func (g *FilterGroup) existOrAddHashed(h KeyHash) (exist bool, full bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	filters := g.load()
+	active := filters[len(filters)-1]
+	for _, f := range g.probeOrder(filters) {
+		if f == active {
+			continue
 		}
-	}()
-	// defers will be invoked in last-in-first-out order
-	g.mu.Lock()
-	defer g.mu.Unlock()
-	for _, f := range g.filters[:len(g.filters)-1] {
-		if f.filter.Exist(b) {
-			return true
+		if f.filter.ExistHashed(h) {
+			g.hit(f)
+			if g.readRepair && !active.filter.ExistOrAddHashed(h) {
+				return true, g.full(active, atomic.AddUint64(&active.added, 1))
+			}
+			return true, false
 		}
 	}
-	return g.filters[len(g.filters)-1].filter.ExistOrAdd(b)
+	if active.filter.ExistOrAddHashed(h) {
+		g.hit(active)
+		return true, false
+	}
+	return false, g.full(active, atomic.AddUint64(&active.added, 1))
 }
 
 // Exist returns if an entry is in the filterGroup
 func (g *FilterGroup) Exist(b []byte) (exist bool) {
+	return g.ExistHashed(g.Hash(b))
+}
+
+// ExistHashed is like Exist, but takes the hash of the entry.
+func (g *FilterGroup) ExistHashed(h KeyHash) (exist bool) {
+	g.stable(func(filters []*filterObj) bool {
+		for _, f := range g.probeOrder(filters) {
+			if f.filter.ExistHashed(h) {
+				g.hit(f)
+				exist = true
+				return true
+			}
+		}
+		return false
+	})
+	return exist
+}
+
+// stable invokes lookup with the filters until it finds the entry, or the filters were not replaced meanwhile,
+// since the replaced filters are closed and report no entries.
+func (g *FilterGroup) stable(lookup func(filters []*filterObj) (found bool)) {
+	for {
+		version := atomic.LoadUint64(&g.version)
+		if lookup(g.load()) || atomic.LoadUint64(&g.version) == version {
+			return
+		}
+	}
+}
+
+// FirstSeenWindow returns the generation in which an entry was first added.
+// Window 0 is the active filter, 1 is the filter before it, and so on.
+// Since ExistOrAdd does not add an entry existing in older filters, the oldest filter reporting it is the answer.
+func (g *FilterGroup) FirstSeenWindow(b []byte) (window int, ok bool) {
+	h := g.Hash(b)
+	g.stable(func(filters []*filterObj) bool {
+		for i, f := range filters {
+			if f.filter.ExistHashed(h) {
+				window, ok = len(filters)-1-i, true
+				return true
+			}
+		}
+		return false
+	})
+	return window, ok
+}
+
+// Close closes all filters in the filterGroup
+func (g *FilterGroup) Close() error {
+	g.tasks.wait()
 	g.mu.Lock()
 	defer g.mu.Unlock()
-	for _, f := range g.filters {
-		if f.filter.Exist(b) {
-			return true
-		}
+	for _, f := range g.load() {
+		_ = f.filter.Close()
+	}
+	if g.next != nil {
+		_ = g.next.filter.Close()
+		g.next = nil
+	}
+	return nil
+}
+
+// Count returns the approximate number of entries in the filterGroup.
+// Entries regarded as existing by false positives are not counted.
+func (g *FilterGroup) Count() uint64 {
+	var count uint64
+	for _, f := range g.load() {
+		count += atomic.LoadUint64(&f.added)
+	}
+	return count
+}
+
+// Capacity returns the total expected number of entries of the filters in the filterGroup.
+func (g *FilterGroup) Capacity() uint64 {
+	var capacity uint64
+	for _, f := range g.load() {
+		capacity += atomic.LoadUint64(&f.expected)
+	}
+	return capacity
+}
+
+// EstimateFPR returns the estimated false positive rate of the filterGroup.
+// An entry is false positive if any of the filters reports it, so it is 1 - Π(1 - p_i),
+// where p_i is the estimated false positive rate of each filter according to its added entries.
+func (g *FilterGroup) EstimateFPR() float64 {
+	pass := 1.0
+	for _, f := range g.load() {
+		param := f.filter.FilterParam()
+		pass *= 1 - EstimateFPR(param.Slots, param.Bits, atomic.LoadUint64(&f.added))
 	}
-	return false
+	return 1 - pass
 }
@@ -7,6 +7,8 @@ import (
 	"encoding/binary"
 	"fmt"
 	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
 )
 
@@ -14,6 +16,7 @@ func TestFilterGroup_Exist(t *testing.T) {
 	os.Mkdir("testfile", os.ModePerm)
 	bf, _ := NewGroup("testfile/*", FsyncModeEverySec, 1e6, 1e-4, doubleFNV)
 	defer func() {
+		bf.Close()
 		os.RemoveAll("testfile")
 	}()
 	buf := []byte("testing")
@@ -26,6 +29,109 @@ func TestFilterGroup_Exist(t *testing.T) {
 	}
 }
 
+func TestFilterGroup_EstimateFPR(t *testing.T) {
+	const (
+		n         = 1e3
+		expectFPR = 1e-3
+	)
+	os.Mkdir("testfile", os.ModePerm)
+	bf, _ := NewGroup("testfile/*", FsyncModeNo, n, expectFPR, doubleFNV)
+	defer func() {
+		bf.Close()
+		os.RemoveAll("testfile")
+	}()
+	if fpr := bf.EstimateFPR(); fpr != 0 {
+		t.Fatalf("FPR of an empty group should be 0, got %v", fpr)
+	}
+	for i := 0; i < 2*n; i++ {
+		bf.ExistOrAdd([]byte(fmt.Sprint(i)))
+	}
+	bf.tasks.wait()
+	if count := bf.Count(); count < 2*n*0.99 || count > 2*n {
+		t.Fatalf("Count should be about %v, got %v", 2*n, count)
+	}
+	filters := len(bf.load())
+	if capacity := bf.Capacity(); capacity != uint64(filters)*n {
+		t.Fatalf("Capacity should be %v, got %v", filters*n, capacity)
+	}
+	// full filters and an empty one, the filters may be a little overfilled during the rotation
+	if fpr := bf.EstimateFPR(); fpr < expectFPR/2 || fpr > float64(filters)*2*expectFPR {
+		t.Fatalf("FPR should be about %v, got %v", 2*expectFPR, fpr)
+	}
+}
+
+func TestFilterGroup_FirstSeenWindow(t *testing.T) {
+	const n = 100
+	os.Mkdir("testfile", os.ModePerm)
+	bf, _ := NewGroup("testfile/*", FsyncModeNo, n, 1e-6, doubleFNV)
+	defer func() {
+		bf.Close()
+		os.RemoveAll("testfile")
+	}()
+	bf.ExistOrAdd([]byte("old"))
+	for i := 0; len(bf.load()) < 3; i++ {
+		bf.ExistOrAdd([]byte(fmt.Sprint(i)))
+		bf.tasks.wait()
+	}
+	bf.ExistOrAdd([]byte("new"))
+	if window, ok := bf.FirstSeenWindow([]byte("old")); !ok || window != 2 {
+		t.Fatalf("Should be seen 2 rotations ago, got %v %v", window, ok)
+	}
+	if window, ok := bf.FirstSeenWindow([]byte("new")); !ok || window != 0 {
+		t.Fatalf("Should be seen in the active filter, got %v %v", window, ok)
+	}
+	if _, ok := bf.FirstSeenWindow([]byte("not-exists")); ok {
+		t.Fatal("Should missing in filter but got true")
+	}
+}
+
+func TestFilterGroup_ConcurrentRotation(t *testing.T) {
+	const (
+		n          = 100
+		N          = 20 * n
+		goroutines = 8
+	)
+	os.Mkdir("testfile", os.ModePerm)
+	bf, err := NewGroup("testfile/*", FsyncModeNo, n, 1e-6, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		bf.Close()
+		os.RemoveAll("testfile")
+	}()
+	// every entry is added by two goroutines at the same time, and at most one of them can see it as new
+	var novel [N]int32
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := g / 2; i < N; i += goroutines / 2 {
+				if !bf.ExistOrAdd([]byte(fmt.Sprint(i))) {
+					atomic.AddInt32(&novel[i], 1)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	var total int
+	for i := range novel {
+		if novel[i] > 1 {
+			t.Fatalf("%v is regarded as new %v times", i, novel[i])
+		}
+		total += int(novel[i])
+		if !bf.Exist([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%v should exist but got false", i)
+		}
+	}
+	bf.tasks.wait()
+	if filters := len(bf.load()); filters < N/n/2 {
+		t.Fatalf("Should rotate about %v times, got %v filters", N/n, filters)
+	}
+	t.Logf("Samples = %v, New = %v, Filters = %v", N, total, len(bf.load()))
+}
+
 func TestFilterGroupFalsePositive(t *testing.T) {
 	const (
 		n         = 1e6
@@ -63,6 +169,7 @@ func BenchmarkFilterGroup_ExistOrAdd(b *testing.B) {
 	os.Mkdir("testfile", os.ModePerm)
 	bf, _ := NewGroup("testfile/*", FsyncModeEverySec, 1e6, 1e-4, doubleFNV)
 	defer func() {
+		bf.Close()
 		os.RemoveAll("testfile")
 	}()
 	buf := make([]byte, 20)
@@ -79,6 +186,7 @@ func BenchmarkFilterGroup_Exist(b *testing.B) {
 	os.Mkdir("testfile", os.ModePerm)
 	bf, _ := NewGroup("testfile/*", FsyncModeEverySec, 1e6, 1e-4, doubleFNV)
 	defer func() {
+		bf.Close()
 		os.RemoveAll("testfile")
 	}()
 	buf := make([]byte, 20)
@@ -88,3 +196,134 @@ func BenchmarkFilterGroup_Exist(b *testing.B) {
 		bf.Exist(buf)
 	}
 }
+
+func TestFilterGroup_SetCapacity(t *testing.T) {
+	const n = 100
+	os.Mkdir("testfile", os.ModePerm)
+	defer os.RemoveAll("testfile")
+	bf, err := NewGroup("testfile/*", FsyncModeNo, n, 1e-4, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys [][]byte
+	add := func(filters int) {
+		for i := len(keys); len(bf.load()) < filters; i++ {
+			key := []byte(fmt.Sprint(i))
+			keys = append(keys, key)
+			bf.ExistOrAdd(key)
+			bf.tasks.wait()
+		}
+	}
+	add(2)
+	if err = bf.SetCapacity(10*n, 1e-6); err != nil {
+		t.Fatal(err)
+	}
+	add(4)
+	old, grown := bf.load()[0], bf.load()[2]
+	if old.expected != n || grown.expected != 10*n || grown.filter.FilterParam().Bits <= old.filter.FilterParam().Bits {
+		t.Fatalf("New filters should use the new parameters, got %v %v", old.filter.FilterParam(), grown.filter.FilterParam())
+	}
+	for _, key := range keys {
+		if !bf.Exist(key) {
+			t.Fatalf("%s should exist in filter", key)
+		}
+	}
+	bf.Close()
+
+	// the parameters of every file are kept after reopening
+	bf, err = NewGroup("testfile/*", FsyncModeNo, n, 1e-4, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bf.Close()
+	if filters := bf.load(); len(filters) != 4 || filters[2].expected != 10*n || filters[3].expected != 10*n {
+		t.Fatalf("Unexpected filters after reopening")
+	}
+	for _, key := range keys {
+		if !bf.Exist(key) {
+			t.Fatalf("%s should exist in filter", key)
+		}
+	}
+}
+
+func TestFilterGroup_ReadRepair(t *testing.T) {
+	os.Mkdir("testfile", os.ModePerm)
+	g, err := NewGroup("testfile/*", FsyncModeNo, 1e3, 1e-4, doubleFNV, WithReadRepair())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		g.Close()
+		os.RemoveAll("testfile")
+	}()
+	g.ExistOrAdd([]byte("seen"))
+	g.ExistOrAdd([]byte("looked up"))
+	for w := 0; w < 2; w++ {
+		if err = g.RotateNow(); err != nil {
+			t.Fatal(err)
+		}
+		if !g.ExistOrAdd([]byte("seen")) || !g.Exist([]byte("looked up")) {
+			t.Fatal("Should find the entries of the older windows")
+		}
+		if !g.Active().Exist([]byte("seen")) || g.Active().Exist([]byte("looked up")) {
+			t.Fatal("Should add the entries found by ExistOrAdd only to the active filter")
+		}
+	}
+	if window, _ := g.FirstSeenWindow([]byte("seen")); window != 2 {
+		t.Fatalf("Should report the oldest window, got %v", window)
+	}
+	if err = g.DropMember(2); err != nil {
+		t.Fatal(err)
+	}
+	if !g.Exist([]byte("seen")) || g.Exist([]byte("looked up")) {
+		t.Fatal("Should keep the entries refreshed only")
+	}
+}
+
+func TestFilterGroup_RotateNow(t *testing.T) {
+	const n = 100
+	os.Mkdir("testfile", os.ModePerm)
+	defer os.RemoveAll("testfile")
+	bf, err := NewGroup("testfile/*", FsyncModeNo, n, 1e-4, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		bf.ExistOrAdd([]byte(fmt.Sprint(i)))
+	}
+	old := bf.Active()
+	if err = bf.RotateNow(); err != nil {
+		t.Fatal(err)
+	}
+	active := bf.Active()
+	if active == old {
+		t.Fatal("Active should return the new filter")
+	}
+	filters := bf.load()
+	if len(filters) != 2 || filters[0].filter != old || filters[1].filter != active {
+		t.Fatalf("Should rotate to a second filter, got %v filters", len(filters))
+	}
+	if retired := filters[0]; retired.expected != 10 || !bf.full(retired, atomic.LoadUint64(&retired.added)) {
+		t.Fatalf("The old filter should be regarded as full, got %v of %v", retired.added, retired.expected)
+	}
+	if bf.ExistOrAdd([]byte("new")) {
+		t.Fatal("Should be added")
+	}
+	if !active.Exist([]byte("new")) || old.Exist([]byte("new")) {
+		t.Fatal("Should add to the new active filter")
+	}
+	bf.Close()
+
+	// still full after reopening
+	bf, err = NewGroup("testfile/*", FsyncModeNo, n, 1e-4, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bf.Close()
+	if filters = bf.load(); len(filters) != 2 || filters[0].expected != 10 {
+		t.Fatalf("Should reopen the full filter and the active one, got %v filters", len(filters))
+	}
+	if !bf.Active().Exist([]byte("new")) {
+		t.Fatal("Active should return the filter added to last")
+	}
+}
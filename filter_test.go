@@ -5,22 +5,16 @@ package disk_bloom
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
-	"hash/fnv"
+	"math/bits"
 	"os"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 )
 
-func doubleFNV(b []byte) (uint64, uint64) {
-	hx := fnv.New64()
-	hx.Write(b)
-	x := hx.Sum64()
-	hy := fnv.New64a()
-	hy.Write(b)
-	y := hy.Sum64()
-	return x, y
-}
-
 func TestDiskFilter_Exist(t *testing.T) {
 	bf, _ := New("testfile", Controller{
 		Fsync:        FsyncModeEverySec,
@@ -143,3 +137,296 @@ func BenchmarkDiskFilter_Exist(b *testing.B) {
 		bf.Exist(buf)
 	}
 }
+
+func TestDiskFilter_ExistHashed(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo})
+	buf := []byte("testing")
+	h := bf.Hash(buf)
+	if bf.ExistOrAddHashed(h) {
+		t.Fatal("Should missing in filter but got true")
+	}
+	if !bf.Exist(buf) {
+		t.Fatal("Should exist in filter but got false")
+	}
+	if !bf.ExistHashed(h) {
+		t.Fatal("Should exist in filter but got false")
+	}
+}
+
+func TestDiskFilter_FsyncModeAlways(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeAlways})
+	buf := []byte("testing")
+	if exist, err := bf.ExistOrAddErr(buf); exist || err != nil {
+		t.Fatalf("Should be added, got %v %v", exist, err)
+	}
+	if !bf.Exist(buf) {
+		t.Fatal("Should exist in filter but got false")
+	}
+}
+
+// writeCountingStorage counts the writes to a MemStorage.
+type writeCountingStorage struct {
+	MemStorage
+	writes int
+}
+
+func (s *writeCountingStorage) WriteAt(p []byte, off int64) (int, error) {
+	s.writes++
+	return s.MemStorage.WriteAt(p, off)
+}
+
+func TestDiskFilter_FsyncModeAlwaysGathered(t *testing.T) {
+	s := &writeCountingStorage{}
+	bf, err := NewOnStorage(s, "mem", Controller{Fsync: FsyncModeAlways, GetParam: func(metadata []byte) (FilterParam, []byte) {
+		slots, bits := OptimalParam(1e4, 1e-4)
+		return FilterParam{Slots: slots, Bits: bits, HashKind: HashKindXXHash64, BlockSize: 512}, nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bf.Close()
+	writes := s.writes
+	if bf.ExistOrAdd([]byte("testing")) {
+		t.Fatal("Should be added")
+	}
+	if s.writes != writes+1 {
+		t.Fatalf("Should write the bytes of an add at once, got %v writes", s.writes-writes)
+	}
+}
+
+func TestDiskFilter_FsyncModeAlwaysSyncFailed(t *testing.T) {
+	injector := NewFaultInjector(1)
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeAlways, FaultInjector: injector})
+	setBits := bf.setBits
+	injector.Set(Faults{SyncErrRate: 1})
+	if _, err := bf.ExistOrAddErr([]byte("testing")); !errors.Is(err, FaultInjectedErr) {
+		t.Fatalf("Should fail with the sync, got %v", err)
+	}
+	if err := bf.AddBatch([][]byte{[]byte("batch")}); !errors.Is(err, FaultInjectedErr) {
+		t.Fatalf("Should fail with the sync, got %v", err)
+	}
+	if bf.Exist([]byte("testing")) || bf.Exist([]byte("batch")) || bf.setBits != setBits {
+		t.Fatal("Should not keep the bits failed to be synced")
+	}
+	injector.Set(Faults{})
+	if exist, err := bf.ExistOrAddErr([]byte("testing")); exist || err != nil {
+		t.Fatalf("Should be added on retry, got %v %v", exist, err)
+	}
+	if !bf.Exist([]byte("testing")) {
+		t.Fatal("Should exist in filter but got false")
+	}
+}
+
+func TestDiskFilter_GroupCommit(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeAlways, GroupCommit: 5 * time.Millisecond})
+	const n = 64
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if exist, err := bf.ExistOrAddErr([]byte(strconv.Itoa(i))); exist || err != nil {
+				errs <- fmt.Errorf("%v should be added, got %v %v", i, exist, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	bf.file.mu.Lock()
+	unsynced := len(bf.unsynced)
+	bf.file.mu.Unlock()
+	if unsynced != 0 {
+		t.Fatalf("All adds should be synced after returning, got %v unsynced bytes", unsynced)
+	}
+	bf.commit.mu.Lock()
+	batches := bf.commit.synced
+	bf.commit.mu.Unlock()
+	if batches >= n {
+		t.Fatalf("Adds should share syncs, got %v syncs", batches)
+	}
+	for i := 0; i < n; i++ {
+		if !bf.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should exist in filter", i)
+		}
+	}
+}
+
+func TestDiskFilter_GroupCommitSyncFailed(t *testing.T) {
+	injector := NewFaultInjector(1)
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeAlways, GroupCommit: time.Millisecond, FaultInjector: injector})
+	if _, err := bf.ExistOrAddErr([]byte("synced")); err != nil {
+		t.Fatal(err)
+	}
+	injector.Set(Faults{SyncErrRate: 1})
+	if _, err := bf.ExistOrAddErr([]byte("failed")); !errors.Is(err, FaultInjectedErr) {
+		t.Fatalf("Should fail with the sync, got %v", err)
+	}
+	injector.Set(Faults{})
+	if bf.Exist([]byte("failed")) || bf.ExistBatch([][]byte{[]byte("failed")})[0] {
+		t.Fatal("Should not report the entry failed to be synced")
+	}
+	if exist, err := bf.ExistOrAddErr([]byte("failed")); exist || err == nil {
+		t.Fatalf("Should not report the entry failed to be synced, got %v %v", exist, err)
+	}
+	if !bf.Exist([]byte("synced")) || !bf.ExistBatch([][]byte{[]byte("synced")})[0] {
+		t.Fatal("Should report the entry synced before")
+	}
+	if _, err := bf.ExistOrAddErr([]byte("another")); !errors.Is(err, ReadOnlyErr) {
+		t.Fatalf("Should become read-only, got %v", err)
+	}
+}
+
+func TestDiskFilter_AlignToPage(t *testing.T) {
+	bf := newTestFilter(t, Controller{AlignToPage: true, MetadataSize: 10})
+	if bf.bloomStart%pageSize != 0 {
+		t.Fatalf("Bloom filter should start at a page boundary, got %v", bf.bloomStart)
+	}
+	_, bits := OptimalParam(1e4, 1e-4)
+	param := bf.FilterParam()
+	if param.Bits%(pageSize*8) != 0 || param.Bits < bits || param.Bits >= bits+pageSize*8 {
+		t.Fatalf("Bits should be rounded up to pages, got %v", param.Bits)
+	}
+	for i := 0; i < 100; i++ {
+		bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	bf.Close()
+	if info, err := os.Stat("testfile"); err != nil {
+		t.Fatal(err)
+	} else if info.Size() != bf.bloomStart+int64(param.Bits/8) {
+		t.Fatalf("File size should be %v, got %v", bf.bloomStart+int64(param.Bits/8), info.Size())
+	}
+	stats, err := ScanFile("testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stats.Healthy || stats.Size != param.Bits/8 || stats.Slots != param.Slots {
+		t.Fatalf("Unexpected stats: %+v", stats)
+	}
+
+	// the alignment is read from the header
+	bf = newTestFilter(t, Controller{MetadataSize: 10})
+	if bf.FilterParam().Bits != param.Bits {
+		t.Fatalf("Bits should be %v after reopening, got %v", param.Bits, bf.FilterParam().Bits)
+	}
+	for i := 0; i < 100; i++ {
+		if !bf.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should exist in filter", i)
+		}
+	}
+}
+
+func TestDiskFilter_FastRange(t *testing.T) {
+	bf := newTestFilter(t, Controller{FastRange: true})
+	if !bf.Header().FastRange() {
+		t.Fatal("FastRange should be recorded in the header")
+	}
+	x, y := doubleFNV([]byte("testing"))
+	for i := 0; i < int(bf.FilterParam().Slots); i++ {
+		want, _ := bits.Mul64(x+uint64(i)*y, bf.FilterParam().Bits)
+		if offset := bf.bloomOffset(x, y, i); offset != want {
+			t.Fatalf("Probe #%v: got offset %v, want %v", i, offset, want)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	bf.Close()
+
+	// the mapping is read from the header
+	bf = newTestFilter(t, Controller{})
+	if !bf.Header().FastRange() {
+		t.Fatal("FastRange should be read from the header")
+	}
+	for i := 0; i < 1000; i++ {
+		if !bf.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should exist in filter", i)
+		}
+	}
+	falsePositives := 0
+	for i := 1000; i < 11000; i++ {
+		if bf.Exist([]byte(strconv.Itoa(i))) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 10 {
+		t.Fatalf("Too many false positives: %v", falsePositives)
+	}
+}
+
+func TestDiskFilter_ConcurrentClose(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeAlways, GroupCommit: time.Millisecond})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; ; j++ {
+				if _, err := bf.ExistOrAddErr([]byte(strconv.Itoa(i*1e6 + j))); err != nil {
+					if !errors.Is(err, ClosedErr) {
+						t.Error(err)
+					}
+					return
+				}
+			}
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if bf.Exist([]byte("0")) {
+		t.Fatal("Exist should be false after Close")
+	}
+	if _, err := bf.ExistOrAddErr([]byte("0")); !errors.Is(err, ClosedErr) {
+		t.Fatalf("got %v, want ClosedErr", err)
+	}
+}
+
+func TestDiskFilter_ConcurrentExist(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo, BlockCache: 1 << 16})
+	for i := 0; i < 1000; i++ {
+		bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if i%8 == 0 {
+					bf.ExistOrAdd([]byte(strconv.Itoa(i*1e6 + j)))
+				} else if !bf.Exist([]byte(strconv.Itoa(j))) {
+					t.Errorf("%v should exist", j)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestDiskFilter_Add(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeAlways, Stats: true})
+	for i := 0; i < 1000; i++ {
+		if err := bf.Add([]byte(strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		if !bf.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should exist in filter", i)
+		}
+	}
+	if bf.ExistOrAdd([]byte("1000")) {
+		t.Fatal("1000 should not exist in filter")
+	}
+	bf.Close()
+	if err := bf.Add([]byte("0")); !errors.Is(err, ClosedErr) {
+		t.Fatalf("got %v, want ClosedErr", err)
+	}
+}
@@ -0,0 +1,69 @@
+package disk_bloom
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+)
+
+// fingerprintFlags are the flags deciding the probes and the layout of the bloom filter, see Fingerprint.
+const fingerprintFlags = FlagFastRange | FlagHardened | FlagAdaptive | FlagCounting | FlagXor | FlagCuckoo | FlagTTL | FlagBlocked | FlagSalted
+
+// Fingerprint returns a hash of the parameters deciding the bits of the filter: the hash kind, slots, bits and block size,
+// the flags of the probes and the variant, the salt, and the parameters of the variant.
+// Two files with the same Fingerprint set the same bits for the same entries on any architecture and version
+// of this package, so that nodes compare them before exchanging or merging the files.
+// The custom hashes and the keys of HashKindSipHash are not covered, and 0 means the parameters are not recorded.
+func (h Header) Fingerprint() uint64 {
+	if h.Version == 0 || h.Bits == 0 {
+		return 0
+	}
+	var b [48]byte
+	// the revision of the encoding below, bumped if it changes
+	b[0] = 1
+	b[1] = uint8(h.HashKind)
+	b[2] = h.Slots
+	b[3] = h.AdaptiveSlots
+	b[4] = h.CounterWidth
+	b[5] = h.Buckets
+	binary.LittleEndian.PutUint16(b[6:], h.Flags&fingerprintFlags)
+	binary.LittleEndian.PutUint64(b[8:], h.Bits)
+	binary.LittleEndian.PutUint64(b[16:], uint64(h.BucketSpan))
+	binary.LittleEndian.PutUint64(b[24:], h.seed)
+	binary.LittleEndian.PutUint64(b[32:], h.fingerprints)
+	binary.LittleEndian.PutUint32(b[40:], h.BlockSize)
+	hash := fnv.New64a()
+	hash.Write(b[:])
+	if h.Salted() {
+		// appended, so that the fingerprints of the files without a salt are kept
+		var salt [8]byte
+		binary.LittleEndian.PutUint64(salt[:], h.Salt)
+		hash.Write(salt[:])
+	}
+	return hash.Sum64()
+}
+
+// Fingerprint returns the Fingerprint of the filter, see Header.Fingerprint.
+// The parameters of the files created before they were recorded are taken from the FilterParam.
+func (f *DiskFilter) Fingerprint() uint64 {
+	h := f.header
+	if h.Bits == 0 {
+		h.Version, h.HashKind, h.Slots, h.Bits = HeaderVersion, f.param.HashKind, f.param.Slots, f.param.Bits
+	}
+	return h.Fingerprint()
+}
+
+// markByteOrderLocked writes the byte order mark into the header of a file written before it was introduced.
+// The files signed by another key are left, since they could not be signed again.
+func (f *DiskFilter) markByteOrderLocked() error {
+	if f.header.Version == 0 || f.header.byteOrder != 0 || f.readOnly ||
+		(f.header.Signed() && len(f.controller.HMACKey) == 0) {
+		return nil
+	}
+	if err := f.writeSignedLocked(func(image []byte) {
+		binary.LittleEndian.PutUint16(image[LenOfMetadataSize+int(f.controller.MetadataSize)+headerByteOrderOffset:], headerByteOrder)
+	}); err != nil {
+		return err
+	}
+	f.header.byteOrder = headerByteOrder
+	return nil
+}
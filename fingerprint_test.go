@@ -0,0 +1,110 @@
+package disk_bloom
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	open := func(name string, param FilterParam, fastRange bool) *DiskFilter {
+		f, err := New(dir+"/"+name, Controller{
+			Fsync:     FsyncModeNo,
+			FastRange: fastRange,
+			GetParam: func(metadata []byte) (FilterParam, []byte) {
+				return param, nil
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { f.Close() })
+		return f
+	}
+	param := FilterParam{Slots: 3, Bits: 1024, HashKind: HashKindXXHash64}
+	a := open("a", param, false)
+	if a.Fingerprint() != open("b", param, false).Fingerprint() {
+		t.Fatal("the filters of the same parameters should have the same fingerprint")
+	}
+	if a.Fingerprint() != a.Header().Fingerprint() {
+		t.Fatal("the fingerprint of the filter should be the one of its header")
+	}
+	for name, f := range map[string]*DiskFilter{
+		"bits":      open("bits", FilterParam{Slots: 3, Bits: 2048, HashKind: HashKindXXHash64}, false),
+		"slots":     open("slots", FilterParam{Slots: 4, Bits: 1024, HashKind: HashKindXXHash64}, false),
+		"hash":      open("hash", FilterParam{Slots: 3, Bits: 1024, HashKind: HashKindFNV}, false),
+		"fastrange": open("fastrange", param, true),
+	} {
+		if f.Fingerprint() == a.Fingerprint() {
+			t.Fatalf("the filters of different %v should have different fingerprints", name)
+		}
+	}
+	// the encoding is a guarantee across architectures and versions
+	if fp := a.Fingerprint(); fp != 0x59a081995ca4dae8 {
+		t.Fatalf("the fingerprint should be stable, got %#x", fp)
+	}
+	if fp := (Header{}).Fingerprint(); fp != 0 {
+		t.Fatalf("the fingerprint of a file without parameters should be 0, got %#x", fp)
+	}
+}
+
+func TestFingerprint_Layout(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo})
+	for _, key := range []string{"a", "b", "c"} {
+		bf.ExistOrAdd([]byte(key))
+	}
+	b, err := os.ReadFile("testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the bits set by the same entries are the same on any architecture
+	sum := sha256.Sum256(b[bf.bloomStart : bf.bloomStart+int64(bf.Size())])
+	if got := hex.EncodeToString(sum[:]); got != "64c78533068090ed39a71d950aa75b91943cb8fce2649c7d2b167c6b061c53cb" {
+		t.Fatalf("the layout of the bloom filter should be stable, got %v", got)
+	}
+}
+
+func TestByteOrderMark(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo})
+	if !bf.Header().Portable() {
+		t.Fatal("a new file should record its byte order")
+	}
+	if err := bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	writeMark := func(mark ...byte) {
+		f, err := os.OpenFile("testfile", os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err = f.WriteAt(mark, LenOfMetadataSize+headerByteOrderOffset); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a file written before the mark was introduced
+	writeMark(0, 0)
+	if h, err := Inspect("testfile"); err != nil || h.Portable() {
+		t.Fatalf("the file should not record its byte order, got %v, %v", h.Portable(), err)
+	}
+	bf = newTestFilter(t, Controller{Fsync: FsyncModeNo})
+	if !bf.Header().Portable() {
+		t.Fatal("the file should be marked when opened for writing")
+	}
+	if err := bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if h, err := Inspect("testfile"); err != nil || !h.Portable() {
+		t.Fatalf("the mark should be written into the file, got %v, %v", h.Portable(), err)
+	}
+
+	// a header written big-endian
+	writeMark(0x01, 0x02)
+	if _, err := Inspect("testfile"); !errors.Is(err, InvalidHeaderErr) {
+		t.Fatalf("a big-endian header should be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"time"
+)
+
+// Flush makes everything added so far durable without closing the filter: it waits for the group commits in flight,
+// writes the adds buffered by Controller.WriteBuffer and DiskFullPolicyBuffer, persists the header and the metadata,
+// and syncs the file whatever the FsyncMode is. The records of the ack ring waiting for the sync are written then,
+// and the journal is truncated. The bytes failed to be written are kept in memory, and retried by the next flush.
+func (f *DiskFilter) Flush() error {
+	if !f.acquire() {
+		return ClosedErr
+	}
+	defer f.release()
+	if f.controller.ReadOnly {
+		return nil
+	}
+	if f.commit != nil {
+		// out of the lock, which the leader takes to forget the offsets synced
+		if err := f.commit.flush(); err != nil {
+			return err
+		}
+	}
+	f.file.mu.Lock()
+	defer f.file.mu.Unlock()
+	if len(f.pending) > 0 {
+		if err := f.flushPendingLocked(); err != nil {
+			return err
+		}
+	}
+	f.controlLocked()
+	if f.file.modified {
+		if err := f.persistHeaderLocked(); err != nil {
+			return err
+		}
+		if err := f.signLocked(); err != nil {
+			return err
+		}
+		if err := f.updateChecksumsLocked(); err != nil {
+			return err
+		}
+	}
+	if err := f.syncFile(); err != nil {
+		return err
+	}
+	f.file.modified = false
+	f.file.metadataModified = false
+	if err := f.flushAcksLocked(); err != nil {
+		return err
+	}
+	if f.journal != nil && f.journal.size > 0 {
+		return f.journal.reset()
+	}
+	return nil
+}
+
+// closeDrained closes the filter, and returns DrainTimeoutErr if the operations in flight do not finish in timeout.
+// The filter is closed in the background once they finish then.
+func (f *DiskFilter) closeDrained(timeout time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- f.close()
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("%w in %v", DrainTimeoutErr, timeout)
+	}
+}
@@ -0,0 +1,63 @@
+package disk_bloom
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiskFilter_FlushSyncs(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo, WriteBuffer: time.Hour, Debug: true})
+	bf.ExistOrAdd([]byte("testing"))
+	if err := bf.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(bf.pending) != 0 || bf.file.modified {
+		t.Fatal("Should write the buffered adds")
+	}
+	if bf.DebugStats().Syncs != 1 {
+		t.Fatalf("Should sync in FsyncModeNo, got %v syncs", bf.DebugStats().Syncs)
+	}
+	if err := bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := bf.Flush(); !errors.Is(err, ClosedErr) {
+		t.Fatalf("Should fail once closed, got %v", err)
+	}
+}
+
+func TestDiskFilter_FlushAcks(t *testing.T) {
+	bf := openAckFilter(t, filepath.Join(t.TempDir(), "filter"), Controller{Fsync: FsyncModeEverySec, SyncInterval: MaxSyncInterval, AckRing: 4})
+	defer bf.Close()
+	if _, err := bf.ExistOrAddSeq([]byte("1"), 1); err != nil {
+		t.Fatal(err)
+	}
+	checkAcked(t, bf)
+	if err := bf.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	checkAcked(t, bf, 1)
+}
+
+func TestDiskFilter_DrainTimeout(t *testing.T) {
+	bf := newTestFilter(t, Controller{DrainTimeout: 10 * time.Millisecond})
+	// an operation stuck in flight
+	if !bf.acquire() {
+		t.Fatal("Should acquire")
+	}
+	if err := bf.Close(); !errors.Is(err, DrainTimeoutErr) {
+		t.Fatalf("Should time out, got %v", err)
+	}
+	bf.release()
+	deadline := time.Now().Add(5 * time.Second)
+	for _, err := bf.ExistErr([]byte("key")); !errors.Is(err, ClosedErr); _, err = bf.ExistErr([]byte("key")) {
+		if time.Now().After(deadline) {
+			t.Fatal("Should close once drained")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,219 @@
+// Package format decodes the filter files of disk_bloom without the machinery of the package:
+// no goroutines, no locks and no I/O, so that tiny sidecar tools, and other languages via cgo or WASM,
+// parse the headers and query the classic filters of files they map read-only or read into memory.
+//
+// The layout of a file is
+//
+//	| len of metadata size(2 bytes) | metadata | header | bloom filter |
+//
+// where the header is described in disk_bloom.Header.
+package format
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"time"
+)
+
+const (
+	Magic = "DSKBLOOM"
+	// LenOfMetadataSize is the size of the metadata size at the head of a file.
+	LenOfMetadataSize = 2
+	// HeaderSize is the size of the headers this package reads.
+	HeaderSize = 1024
+	// PageSize is the alignment of the bloom filter since version 2 and of the files with FlagAligned.
+	PageSize = 4096
+
+	adaptiveOffset  = 72
+	counterOffset   = 73
+	hashKindOffset  = 74
+	slotsOffset     = 75
+	byteOrderOffset = 78
+	bitsOffset      = 104
+	createdOffset   = 112
+	saltOffset      = 152
+	tagOffset       = 256
+	tagSize         = 64
+
+	// ByteOrderMark is recorded little-endian in the headers, or 0 in the files written before it was introduced,
+	// which are little-endian as well.
+	ByteOrderMark = 0x0102
+)
+
+// Flags in the header
+const (
+	FlagEncrypted uint16 = 1 << iota
+	FlagSigned
+	FlagAligned
+	FlagFastRange
+	FlagSealed
+	FlagShrunk
+	FlagAdaptive
+	FlagCounting
+	FlagXor
+	FlagHardened
+	FlagCuckoo
+	FlagTTL
+	FlagBlocked
+	FlagWearLeveled
+	FlagSalted
+)
+
+var (
+	InvalidFileErr = fmt.Errorf("invalid filter file")
+	UnsupportedErr = fmt.Errorf("unsupported")
+)
+
+// Header is the header of a file. Version is 0 if the file has no header.
+type Header struct {
+	Version      uint16
+	Flags        uint16
+	Size         uint32
+	MetadataSize uint16
+	HashKind     HashKind
+	Slots        uint8
+	Bits         uint64
+	// AdaptiveSlots is the number of extra bits set per entry of adaptive filters
+	AdaptiveSlots uint8
+	// Created is the time the file was created, or zero if not recorded
+	Created time.Time
+	Tag     string
+	// Salt is mixed into the double hash by SaltHash if the header has FlagSalted
+	Salt uint64
+}
+
+// BloomStart returns the offset of the bloom filter in the file.
+func (h Header) BloomStart() int64 {
+	start := LenOfMetadataSize + int64(h.MetadataSize) + int64(h.Size)
+	if h.Version >= 2 || h.Flags&FlagAligned != 0 {
+		start = (start + PageSize - 1) / PageSize * PageSize
+	}
+	return start
+}
+
+// ParseHeader parses the header of a file from its beginning b, which holds the metadata and the header at least.
+func ParseHeader(b []byte) (Header, error) {
+	if len(b) < LenOfMetadataSize {
+		return Header{}, fmt.Errorf("%w: truncated", InvalidFileErr)
+	}
+	h := Header{MetadataSize: binary.LittleEndian.Uint16(b)}
+	b = b[LenOfMetadataSize+int(h.MetadataSize):]
+	if len(b) < len(Magic)+8 || string(b[:len(Magic)]) != Magic {
+		// no header
+		return h, nil
+	}
+	if len(b) < HeaderSize {
+		return Header{}, fmt.Errorf("%w: truncated header", InvalidFileErr)
+	}
+	h.Version = binary.LittleEndian.Uint16(b[8:])
+	h.Flags = binary.LittleEndian.Uint16(b[10:])
+	h.Size = binary.LittleEndian.Uint32(b[12:])
+	if h.Version == 0 || h.Size < HeaderSize {
+		return Header{}, fmt.Errorf("%w: version %v, header size %v", InvalidFileErr, h.Version, h.Size)
+	}
+	if mark := binary.LittleEndian.Uint16(b[byteOrderOffset:]); mark != 0 && mark != ByteOrderMark {
+		return Header{}, fmt.Errorf("%w: byte order mark %#04x", InvalidFileErr, mark)
+	}
+	h.AdaptiveSlots = b[adaptiveOffset]
+	h.HashKind = HashKind(b[hashKindOffset])
+	h.Slots = b[slotsOffset]
+	h.Bits = binary.LittleEndian.Uint64(b[bitsOffset:])
+	h.Salt = binary.LittleEndian.Uint64(b[saltOffset:])
+	if nanos := int64(binary.LittleEndian.Uint64(b[createdOffset:])); nanos != 0 {
+		h.Created = time.Unix(0, nanos)
+	}
+	n := int(b[tagOffset])
+	if n > tagSize-1 {
+		n = tagSize - 1
+	}
+	h.Tag = string(b[tagOffset+1 : tagOffset+1+n])
+	return h, nil
+}
+
+// Filter is a classic filter decoded from a file.
+type Filter struct {
+	Header   Header
+	Metadata []byte
+	bloom    []byte
+	hash     func(b []byte) (uint64, uint64)
+}
+
+// Decode decodes the classic filter of the file b, e.g. mapped read-only by syscall.Mmap or read by os.ReadFile,
+// which is referred to by the Filter without a copy. hashKey is the key of HashKindSipHash.
+// The files of the other variants, blocked, encrypted, shrunk or without the parameters recorded are not supported,
+// nor the files of HashKindCustom, which are decoded by DecodeWithHash.
+func Decode(b []byte, hashKey []byte) (*Filter, error) {
+	return decode(b, hashKey, nil)
+}
+
+// DecodeWithHash is like Decode, but the entries are hashed by hash, which should be the custom hash
+// the file of HashKindCustom was written by.
+func DecodeWithHash(b []byte, hash func(b []byte) (uint64, uint64)) (*Filter, error) {
+	if hash == nil {
+		return nil, fmt.Errorf("%w: nil hash", UnsupportedErr)
+	}
+	return decode(b, nil, hash)
+}
+
+func decode(b []byte, hashKey []byte, hash func(b []byte) (uint64, uint64)) (*Filter, error) {
+	h, err := ParseHeader(b)
+	if err != nil {
+		return nil, err
+	}
+	if h.Version == 0 || h.Bits == 0 || h.Slots == 0 {
+		return nil, fmt.Errorf("%w: the parameters are not recorded in the file", UnsupportedErr)
+	}
+	if h.Flags&(FlagEncrypted|FlagShrunk|FlagCounting|FlagXor|FlagCuckoo|FlagTTL|FlagBlocked) != 0 {
+		return nil, fmt.Errorf("%w: flags %#x", UnsupportedErr, h.Flags)
+	}
+	start, size := h.BloomStart(), int64(h.Bits+7)/8
+	if int64(len(b)) < start+size {
+		return nil, fmt.Errorf("%w: %v bytes, which should be %v at least", InvalidFileErr, len(b), start+size)
+	}
+	f := &Filter{
+		Header:   h,
+		Metadata: b[LenOfMetadataSize : LenOfMetadataSize+int(h.MetadataSize)],
+		bloom:    b[start : start+size],
+		hash:     hash,
+	}
+	if f.hash == nil {
+		if h.HashKind == HashKindCustom {
+			return nil, fmt.Errorf("%w: the file is hashed by a custom hash, decode it by DecodeWithHash", UnsupportedErr)
+		}
+		if f.hash, err = Hasher(h.HashKind, hashKey); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Exist returns if an entry is in the filter.
+func (f *Filter) Exist(b []byte) bool {
+	return f.ExistHashed(f.hash(b))
+}
+
+// ExistHashed returns if an entry of the double hash x and y is in the filter.
+func (f *Filter) ExistHashed(x, y uint64) bool {
+	h := f.Header
+	if h.Flags&FlagSalted != 0 {
+		x, y = SaltHash(x, y, h.Salt)
+	}
+	if h.Flags&FlagHardened != 0 {
+		y = HardenedY(x, y, h.Bits)
+	}
+	// the extra bits of adaptive filters are set by every add, so probing them all is exact
+	slots := int(h.Slots) + int(h.AdaptiveSlots)
+	for i := 0; i < slots; i++ {
+		offset := x + uint64(i)*y
+		if h.Flags&FlagFastRange != 0 {
+			offset, _ = bits.Mul64(offset, h.Bits)
+		} else {
+			offset %= h.Bits
+		}
+		if f.bloom[offset/8]&(1<<(offset%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+package format_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	disk_bloom "github.com/mzz2017/disk-bloom"
+	"github.com/mzz2017/disk-bloom/format"
+)
+
+func createFile(t *testing.T, opts ...disk_bloom.Option) []byte {
+	path := filepath.Join(t.TempDir(), "filter")
+	opts = append([]disk_bloom.Option{disk_bloom.WithCapacity(1000, 0.001), disk_bloom.WithTag("sidecar")}, opts...)
+	f, err := disk_bloom.Open(path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i += 2 {
+		f.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDecode(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	for name, opts := range map[string][]disk_bloom.Option{
+		"xxhash64": {disk_bloom.WithHashKind(disk_bloom.HashKindXXHash64, nil), disk_bloom.WithFastRange()},
+		"fnv":      {disk_bloom.WithHashKind(disk_bloom.HashKindFNV, nil), disk_bloom.WithMetadata(10, nil, nil)},
+		"siphash":  {disk_bloom.WithHashKind(disk_bloom.HashKindSipHash, key), disk_bloom.WithHardenedProbes()},
+		"adaptive": {disk_bloom.WithHashKind(disk_bloom.HashKindXXHash64, nil), disk_bloom.WithAdaptiveSlots(2), disk_bloom.WithPageAlignment()},
+		"salted":   {disk_bloom.WithHashKind(disk_bloom.HashKindXXHash64, nil), disk_bloom.WithSalt(0), disk_bloom.WithHardenedProbes()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			f, err := format.Decode(createFile(t, opts...), key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.Header.Tag != "sidecar" || f.Header.Created.IsZero() || f.Header.Version != disk_bloom.HeaderVersion {
+				t.Fatalf("Unexpected header %+v", f.Header)
+			}
+			fp := 0
+			for i := 0; i < 1000; i++ {
+				exist := f.Exist([]byte(strconv.Itoa(i)))
+				if i%2 == 0 && !exist {
+					t.Fatalf("%v should exist in filter", i)
+				}
+				if i%2 == 1 && exist {
+					fp++
+				}
+			}
+			if fp > 10 {
+				t.Fatalf("Too many false positives: %v", fp)
+			}
+		})
+	}
+}
+
+func TestDecode_Unsupported(t *testing.T) {
+	b := createFile(t, disk_bloom.WithHashKind(disk_bloom.HashKindXXHash64, nil), disk_bloom.WithEncryption(make([]byte, 16)))
+	if _, err := format.Decode(b, nil); !errors.Is(err, format.UnsupportedErr) {
+		t.Fatalf("Should not decode encrypted files, got %v", err)
+	}
+	b = createFile(t, disk_bloom.WithHashKind(disk_bloom.HashKindXXHash64, nil))
+	if _, err := format.Decode(b[:len(b)-1], nil); !errors.Is(err, format.InvalidFileErr) {
+		t.Fatalf("Should reject a truncated file, got %v", err)
+	}
+	if _, err := format.ParseHeader(b[:100]); !errors.Is(err, format.InvalidFileErr) {
+		t.Fatalf("Should reject a truncated header, got %v", err)
+	}
+	// the byte order mark written big-endian
+	b[format.LenOfMetadataSize+78], b[format.LenOfMetadataSize+79] = 0x01, 0x02
+	if _, err := format.ParseHeader(b); !errors.Is(err, format.InvalidFileErr) {
+		t.Fatalf("Should reject a big-endian header, got %v", err)
+	}
+}
+
+func TestHashKind(t *testing.T) {
+	for _, kind := range []disk_bloom.HashKind{disk_bloom.HashKindCustom, disk_bloom.HashKindXXHash64, disk_bloom.HashKindFNV, disk_bloom.HashKindSipHash} {
+		if _, err := format.Hasher(format.HashKind(kind), make([]byte, 16)); (err == nil) != (kind != disk_bloom.HashKindCustom) {
+			t.Fatalf("Unexpected hasher of %v: %v", kind, err)
+		}
+	}
+}
+
+func TestDecodeWithHash(t *testing.T) {
+	b := createFile(t, disk_bloom.WithHash(format.DoubleFNV))
+	if _, err := format.Decode(b, nil); !errors.Is(err, format.UnsupportedErr) {
+		t.Fatalf("Should not decode a file of a custom hash without the hash, got %v", err)
+	}
+	f, err := format.DecodeWithHash(b, format.DoubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Exist([]byte("0")) {
+		t.Fatal("0 should exist in filter")
+	}
+}
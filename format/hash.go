@@ -0,0 +1,203 @@
+package format
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+)
+
+// HashKind is the built-in double hash recorded in the header, see disk_bloom.HashKind.
+type HashKind uint8
+
+const (
+	HashKindCustom HashKind = iota
+	HashKindXXHash64
+	HashKindFNV
+	HashKindSipHash
+)
+
+// Hasher returns the double hash of kind, where key is the key of HashKindSipHash.
+// HashKindCustom has no hash here, see DecodeWithHash.
+func Hasher(kind HashKind, key []byte) (func(b []byte) (uint64, uint64), error) {
+	switch kind {
+	case HashKindXXHash64:
+		return DoubleXXHash64, nil
+	case HashKindFNV:
+		return DoubleFNV, nil
+	case HashKindSipHash:
+		if len(key) != 16 {
+			return nil, fmt.Errorf("%w: siphash needs a key of 16 bytes, got %v", UnsupportedErr, len(key))
+		}
+		k0, k1 := binary.LittleEndian.Uint64(key), binary.LittleEndian.Uint64(key[8:])
+		return func(b []byte) (uint64, uint64) {
+			return SipHash128(k0, k1, b)
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: hash kind %v", UnsupportedErr, kind)
+	}
+}
+
+// DoubleFNV is FNV-1 and FNV-1a of b, the hash of HashKindFNV.
+func DoubleFNV(b []byte) (uint64, uint64) {
+	hx := fnv.New64()
+	hx.Write(b)
+	hy := fnv.New64a()
+	hy.Write(b)
+	return hx.Sum64(), hy.Sum64()
+}
+
+// xxHashSeed2 is the seed of the second hash of HashKindXXHash64.
+const xxHashSeed2 = 0x9e3779b97f4a7c15
+
+// DoubleXXHash64 is XXH64 of b with two seeds, the hash of HashKindXXHash64.
+func DoubleXXHash64(b []byte) (uint64, uint64) {
+	return XXHash64(b, 0), XXHash64(b, xxHashSeed2)
+}
+
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMergeRound(acc, val uint64) uint64 {
+	acc ^= xxRound(0, val)
+	return acc*xxPrime1 + xxPrime4
+}
+
+// XXHash64 is XXH64 of b with the seed.
+func XXHash64(b []byte, seed uint64) uint64 {
+	n := len(b)
+	var h uint64
+	if n >= 32 {
+		v1 := seed + xxPrime1 + xxPrime2
+		v2 := seed + xxPrime2
+		v3 := seed
+		v4 := seed - xxPrime1
+		for ; len(b) >= 32; b = b[32:] {
+			v1 = xxRound(v1, binary.LittleEndian.Uint64(b))
+			v2 = xxRound(v2, binary.LittleEndian.Uint64(b[8:]))
+			v3 = xxRound(v3, binary.LittleEndian.Uint64(b[16:]))
+			v4 = xxRound(v4, binary.LittleEndian.Uint64(b[24:]))
+		}
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) + bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		h = xxMergeRound(h, v1)
+		h = xxMergeRound(h, v2)
+		h = xxMergeRound(h, v3)
+		h = xxMergeRound(h, v4)
+	} else {
+		h = seed + xxPrime5
+	}
+	h += uint64(n)
+	for ; len(b) >= 8; b = b[8:] {
+		h ^= xxRound(0, binary.LittleEndian.Uint64(b))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+	}
+	if len(b) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(b)) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		h ^= uint64(c) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+	return h
+}
+
+// SipHash128 is the 128-bit output of SipHash-2-4 of b keyed by k0 and k1.
+func SipHash128(k0, k1 uint64, b []byte) (uint64, uint64) {
+	v0 := k0 ^ 0x736f6d6570736575
+	v1 := k1 ^ 0x646f72616e646f6d ^ 0xee
+	v2 := k0 ^ 0x6c7967656e657261
+	v3 := k1 ^ 0x7465646279746573
+	round := func() {
+		v0 += v1
+		v1 = bits.RotateLeft64(v1, 13)
+		v1 ^= v0
+		v0 = bits.RotateLeft64(v0, 32)
+		v2 += v3
+		v3 = bits.RotateLeft64(v3, 16)
+		v3 ^= v2
+		v0 += v3
+		v3 = bits.RotateLeft64(v3, 21)
+		v3 ^= v0
+		v2 += v1
+		v1 = bits.RotateLeft64(v1, 17)
+		v1 ^= v2
+		v2 = bits.RotateLeft64(v2, 32)
+	}
+	compress := func(m uint64) {
+		v3 ^= m
+		round()
+		round()
+		v0 ^= m
+	}
+	n := len(b)
+	for ; len(b) >= 8; b = b[8:] {
+		compress(binary.LittleEndian.Uint64(b))
+	}
+	last := uint64(n) << 56
+	for i, c := range b {
+		last |= uint64(c) << (8 * uint(i))
+	}
+	compress(last)
+	v2 ^= 0xee
+	for i := 0; i < 4; i++ {
+		round()
+	}
+	x := v0 ^ v1 ^ v2 ^ v3
+	v1 ^= 0xdd
+	for i := 0; i < 4; i++ {
+		round()
+	}
+	return x, v0 ^ v1 ^ v2 ^ v3
+}
+
+// HardenedY returns the second hash of the probes of the files with FlagHardened:
+// the probes x + i*y collapse to x if y is zero, and the hashes returning x twice are as weak as a single hash,
+// so y is rederived from x by the finalizer of SplitMix64 then. It is made odd, so that it is coprime with
+// power-of-two bits, and it is bumped if it is still a multiple of bits, where the probes collapse again.
+func HardenedY(x, y, bits uint64) uint64 {
+	if y == 0 || y == x {
+		y = x ^ x>>30
+		y *= 0xbf58476d1ce4e5b9
+		y ^= y >> 27
+		y *= 0x94d049bb133111eb
+		y ^= y >> 31
+	}
+	y |= 1
+	if y%bits == 0 {
+		y += 2
+	}
+	return y
+}
+
+// SaltHash returns the double hash mixed with the salt of the files with FlagSalted, before the probes are derived:
+// x and y are each mixed with the salt by the finalizer of SplitMix64, so that the keys whose probes collide
+// in a filter of one salt are scattered in the filters of other salts. The keys of the same x and y still collide,
+// which takes a keyed hash like HashKindSipHash to prevent.
+func SaltHash(x, y, salt uint64) (uint64, uint64) {
+	return splitMix64(x ^ salt), splitMix64(y ^ bits.RotateLeft64(salt, 32) ^ 0x9e3779b97f4a7c15)
+}
+
+// splitMix64 is the finalizer of SplitMix64.
+func splitMix64(z uint64) uint64 {
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	return z ^ z>>31
+}
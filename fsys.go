@@ -0,0 +1,107 @@
+package disk_bloom
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// OpenFS opens the existing classic Bloom Filter name in fsys read-only, see Controller.ReadOnly,
+// e.g. shipped in a zip archive or an embed.FS. The controller is used as by New, with ReadOnly set.
+//
+// The files of os.DirFS are opened as the files of New. The other files are read by their ReadAt if they have one,
+// by seeking if they are io.Seeker, and are read into memory otherwise, like the compressed entries of zip archives.
+// Mmap falls back to the file I/O, FileLock is ignored, and Clone copies the file, but Compact, Publish
+// and ReplaceWith return UnsupportedErr.
+func OpenFS(fsys fs.FS, name string, controller Controller) (*DiskFilter, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	controller.ReadOnly = true
+	var h fileHandle
+	if f, ok := file.(*os.File); ok {
+		if controller.FileLock {
+			if err = lockFile(f, false); err != nil {
+				_ = f.Close()
+				return nil, err
+			}
+		}
+		h = f
+	} else if h, err = newFSFile(file, name); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	filter, err := openHandle(h, name, controller, variantClassic, &debugCounters{})
+	if err != nil {
+		return nil, err
+	}
+	filter.start()
+	return filter, nil
+}
+
+// fsFile is a file of an fs.FS opened by OpenFS, which is read-only.
+type fsFile struct {
+	fs.File
+	name string
+	r    io.ReaderAt
+}
+
+func newFSFile(file fs.File, name string) (*fsFile, error) {
+	f := &fsFile{File: file, name: name}
+	switch r := file.(type) {
+	case io.ReaderAt:
+		f.r = r
+	case io.ReadSeeker:
+		f.r = &seekReaderAt{r: r}
+	default:
+		b, err := io.ReadAll(file)
+		if err != nil {
+			return nil, err
+		}
+		f.r = bytes.NewReader(b)
+	}
+	return f, nil
+}
+
+func (f *fsFile) ReadAt(b []byte, offset int64) (int, error) {
+	return f.r.ReadAt(b, offset)
+}
+
+func (f *fsFile) WriteAt(b []byte, offset int64) (int, error) {
+	return 0, fmt.Errorf("%w: %v is opened from an fs.FS", ReadOnlyErr, f.name)
+}
+
+func (f *fsFile) Name() string {
+	return f.name
+}
+
+func (f *fsFile) Sync() error {
+	return nil
+}
+
+func (f *fsFile) Truncate(size int64) error {
+	return fmt.Errorf("%w: %v is opened from an fs.FS", ReadOnlyErr, f.name)
+}
+
+// seekReaderAt reads at an offset by seeking, one read at a time.
+type seekReaderAt struct {
+	mu sync.Mutex
+	r  io.ReadSeeker
+}
+
+func (s *seekReaderAt) ReadAt(b []byte, offset int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.r.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(s.r, b)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
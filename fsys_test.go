@@ -0,0 +1,132 @@
+package disk_bloom
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"testing"
+	"testing/fstest"
+)
+
+// seekFS hides the ReadAt of the files of fsys.
+type seekFS struct{ fsys fs.FS }
+
+type seekFile struct {
+	fs.File
+	io.Seeker
+}
+
+func (s seekFS) Open(name string) (fs.File, error) {
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return seekFile{File: f, Seeker: f.(io.Seeker)}, nil
+}
+
+// streamFS hides the ReadAt and Seek of the files of fsys.
+type streamFS struct{ fsys fs.FS }
+
+type streamFile struct{ fs.File }
+
+func (s streamFS) Open(name string) (fs.File, error) {
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return streamFile{f}, nil
+}
+
+func TestOpenFS(t *testing.T) {
+	bf := newTestFilter(t, Controller{MetadataSize: 8})
+	for i := 0; i < 100; i++ {
+		bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	if err := bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile("testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	w, err := zw.Create("filters/testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapFS := fstest.MapFS{"filters/testfile": &fstest.MapFile{Data: b}}
+	for name, fsys := range map[string]fs.FS{
+		"ReaderAt": mapFS,
+		"Seeker":   seekFS{mapFS},
+		"Stream":   streamFS{mapFS},
+		"zip":      zr,
+	} {
+		r, err := OpenFS(fsys, "filters/testfile", Controller{MetadataSize: 8, GetParam: func([]byte) (FilterParam, []byte) {
+			return FilterParam{Hash: doubleFNV}, nil
+		}})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		for i := 0; i < 100; i++ {
+			if !r.Exist([]byte(strconv.Itoa(i))) {
+				t.Fatalf("%v: %v should exist in filter", name, i)
+			}
+		}
+		if _, err = r.ExistOrAddErr([]byte("new")); !errors.Is(err, ReadOnlyErr) {
+			t.Fatalf("%v: should be ReadOnlyErr, got %v", name, err)
+		}
+		if _, err = r.Compact(); !errors.Is(err, UnsupportedErr) {
+			t.Fatalf("%v: should be UnsupportedErr, got %v", name, err)
+		}
+		if err = r.Clone("testfile.clone"); err != nil {
+			t.Fatal(name, err)
+		}
+		clone, err := os.ReadFile("testfile.clone")
+		_ = os.Remove("testfile.clone")
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if !bytes.Equal(clone, b) {
+			t.Fatalf("%v: the clone should be the file", name)
+		}
+		if err = r.Close(); err != nil {
+			t.Fatal(name, err)
+		}
+	}
+
+	// the files of os.DirFS are files of the OS
+	r, err := OpenFS(os.DirFS("."), "testfile", Controller{MetadataSize: 8, GetParam: func([]byte) (FilterParam, []byte) {
+		return FilterParam{Hash: doubleFNV}, nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if r.file.osFile() == nil {
+		t.Fatal("Should open the file of the OS")
+	}
+	if !r.Exist([]byte("0")) {
+		t.Fatal("0 should exist in filter")
+	}
+}
+
+func TestOpenFS_Missing(t *testing.T) {
+	if _, err := OpenFS(fstest.MapFS{}, "testfile", Controller{}); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Should be fs.ErrNotExist, got %v", err)
+	}
+}
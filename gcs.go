@@ -0,0 +1,195 @@
+package disk_bloom
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+const gcsMagic = "DBLMGCS1"
+
+var InvalidGCSErr = fmt.Errorf("invalid golomb-coded set")
+
+// A Golomb-coded set, as used by BIP-158, keeps the sorted hashes of the entries in the range [0, N*2^P)
+// as Golomb-Rice coded deltas, which takes about P+2 bits per entry for the false positive rate 2^-P:
+//
+// | magic(8) | P(1) | N(8) | deltas |
+//
+// Each delta is the quotient delta>>P in unary (ones terminated by a zero), followed by the low P bits, most significant first.
+
+// ExportGCS writes a Golomb-coded set of the entries of the filter with the false positive rate fpRate,
+// for distributing to clients which only need read-only membership, see ReadGCS.
+// A bloom filter can not enumerate its entries, so source should feed the keys ever added to the filter
+// by invoking add for each of them, e.g. from a log of the keys or the original dataset.
+// Keys not in the filter are skipped.
+func (f *DiskFilter) ExportGCS(w io.Writer, fpRate float64, source func(add func(b []byte)) error) error {
+	if fpRate <= 0 || fpRate >= 1 {
+		return fmt.Errorf("%w: false positive rate %v", InvalidGCSErr, fpRate)
+	}
+	var hashes []uint64
+	err := source(func(b []byte) {
+		h := f.Hash(b)
+		if f.ExistHashed(h) {
+			hashes = append(hashes, h.X)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	p := uint8(math.Ceil(-math.Log2(fpRate)))
+	if p > 32 {
+		return fmt.Errorf("%w: false positive rate %v is too low", InvalidGCSErr, fpRate)
+	}
+	n := uint64(len(hashes))
+	for i, x := range hashes {
+		hashes[i] = gcsRange(x, n, p)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return hashes[i] < hashes[j]
+	})
+
+	bw := bufio.NewWriter(w)
+	var head [len(gcsMagic) + 9]byte
+	copy(head[:], gcsMagic)
+	head[len(gcsMagic)] = p
+	binary.LittleEndian.PutUint64(head[len(gcsMagic)+1:], n)
+	if _, err = bw.Write(head[:]); err != nil {
+		return err
+	}
+	enc := bitWriter{w: bw}
+	var last uint64
+	for _, v := range hashes {
+		delta := v - last
+		last = v
+		for q := delta >> p; q > 0; q-- {
+			enc.writeBit(1)
+		}
+		enc.writeBit(0)
+		for i := int(p) - 1; i >= 0; i-- {
+			enc.writeBit(uint8(delta >> uint(i) & 1))
+		}
+	}
+	enc.flush()
+	if enc.err != nil {
+		return enc.err
+	}
+	return bw.Flush()
+}
+
+// gcsRange maps a hash to [0, n*2^p).
+func gcsRange(x uint64, n uint64, p uint8) uint64 {
+	hi, _ := bits.Mul64(x, n<<p)
+	return hi
+}
+
+type bitWriter struct {
+	w   *bufio.Writer
+	b   byte
+	n   uint8
+	err error
+}
+
+func (w *bitWriter) writeBit(bit uint8) {
+	w.b = w.b<<1 | bit
+	if w.n++; w.n == 8 {
+		w.flush()
+	}
+}
+
+// flush writes the pending bits, padding the byte with zeros.
+func (w *bitWriter) flush() {
+	if w.n == 0 || w.err != nil {
+		return
+	}
+	w.err = w.w.WriteByte(w.b << (8 - w.n))
+	w.b, w.n = 0, 0
+}
+
+// GCS is a read-only Golomb-coded set exported by ExportGCS.
+type GCS struct {
+	p    uint8
+	n    uint64
+	data []byte
+	hash func([]byte) (uint64, uint64)
+}
+
+// ReadGCS reads a Golomb-coded set exported by ExportGCS.
+// hash must be the hash of the exporting filter.
+func ReadGCS(b []byte, hash func([]byte) (uint64, uint64)) (*GCS, error) {
+	if len(b) < len(gcsMagic)+9 || string(b[:len(gcsMagic)]) != gcsMagic {
+		return nil, InvalidGCSErr
+	}
+	g := &GCS{
+		p:    b[len(gcsMagic)],
+		n:    binary.LittleEndian.Uint64(b[len(gcsMagic)+1:]),
+		data: b[len(gcsMagic)+9:],
+		hash: hash,
+	}
+	if g.p > 32 {
+		return nil, fmt.Errorf("%w: P %v", InvalidGCSErr, g.p)
+	}
+	return g, nil
+}
+
+// Len returns the number of entries in the set.
+func (g *GCS) Len() uint64 {
+	return g.n
+}
+
+// Exist returns if an entry is in the set. It decodes the set from the beginning.
+func (g *GCS) Exist(b []byte) bool {
+	if g.n == 0 {
+		return false
+	}
+	x, _ := g.hash(b)
+	target := gcsRange(x, g.n, g.p)
+	r := bitReader{data: g.data}
+	var v uint64
+	for i := uint64(0); i < g.n; i++ {
+		var q uint64
+		for {
+			bit, ok := r.readBit()
+			if !ok {
+				return false
+			}
+			if bit == 0 {
+				break
+			}
+			q++
+		}
+		delta := q << g.p
+		for j := int(g.p) - 1; j >= 0; j-- {
+			bit, ok := r.readBit()
+			if !ok {
+				return false
+			}
+			delta |= uint64(bit) << uint(j)
+		}
+		v += delta
+		if v == target {
+			return true
+		}
+		if v > target {
+			return false
+		}
+	}
+	return false
+}
+
+type bitReader struct {
+	data []byte
+	pos  uint64
+}
+
+func (r *bitReader) readBit() (uint8, bool) {
+	if r.pos/8 >= uint64(len(r.data)) {
+		return 0, false
+	}
+	bit := r.data[r.pos/8] >> (7 - r.pos%8) & 1
+	r.pos++
+	return bit, true
+}
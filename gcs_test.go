@@ -0,0 +1,50 @@
+package disk_bloom
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+)
+
+func TestDiskFilter_ExportGCS(t *testing.T) {
+	bf := newTestFilter(t, Controller{})
+	for i := 0; i < 1000; i++ {
+		bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	var buf bytes.Buffer
+	err := bf.ExportGCS(&buf, 1e-3, func(add func(b []byte)) error {
+		// keys not in the filter are skipped
+		for i := 0; i < 2000; i++ {
+			add([]byte(strconv.Itoa(i)))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// about P+2 bits per entry
+	if buf.Len() > 1000*(10+3)/8+17 {
+		t.Fatalf("The set is too large: %v bytes", buf.Len())
+	}
+	g, err := ReadGCS(buf.Bytes(), doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Len() < 1000 || g.Len() > 1001 {
+		t.Fatalf("Got %v entries, want 1000", g.Len())
+	}
+	for i := 0; i < 1000; i++ {
+		if !g.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should exist in the set", i)
+		}
+	}
+	falsePositives := 0
+	for i := 2000; i < 12000; i++ {
+		if g.Exist([]byte(strconv.Itoa(i))) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 30 {
+		t.Fatalf("Too many false positives: %v", falsePositives)
+	}
+}
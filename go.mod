@@ -1,3 +1,3 @@
 module github.com/mzz2017/disk-bloom
 
-go 1.16
+go 1.18
@@ -0,0 +1,90 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"os"
+	"sync/atomic"
+)
+
+// GoBloomParam returns the number of hashes and the size in bytes of the filter created by New(n, p, h)
+// of github.com/riobard/go-bloom, which are not exported by it.
+func GoBloomParam(n int, p float64) (slots uint8, size int) {
+	k := -math.Log(p) * math.Log2E
+	m := float64(n) * k * math.Log2E
+	return uint8(k), int(m / 8)
+}
+
+// ImportGoBloom creates the filter file filename from the bits b of a classic filter of github.com/riobard/go-bloom
+// with slots hashes, see GoBloomParam, so that its entries need not be added again. Both lay out the bits alike.
+// controller.GetParam must return the double hash given to the go-bloom filter, and the parameters it returns are ignored.
+// FastRange, HardenedProbes, AdaptiveSlots and Salted are not supported, and PageAlignment only if the size is a multiple of the page size.
+func ImportGoBloom(filename string, controller Controller, b []byte, slots uint8) (*DiskFilter, error) {
+	if len(b) == 0 || slots == 0 || controller.GetParam == nil {
+		return nil, fmt.Errorf("%w: the bits, slots and GetParam are required", MissingParamErr)
+	}
+	if controller.FastRange || controller.HardenedProbes || controller.AdaptiveSlots > 0 || controller.Salted || controller.Salt != 0 {
+		return nil, fmt.Errorf("%w: go-bloom supports none of FastRange, HardenedProbes, AdaptiveSlots and Salted", InconsistentParamErr)
+	}
+	if _, err := os.Stat(filename); err == nil {
+		return nil, fmt.Errorf("%w: %v", os.ErrExist, filename)
+	}
+	getParam := controller.GetParam
+	controller.GetParam = func(metadata []byte) (FilterParam, []byte) {
+		param, updatedMetadata := getParam(metadata)
+		param.Slots, param.Bits = slots, uint64(len(b))*8
+		return param, updatedMetadata
+	}
+	f, err := New(filename, controller)
+	if err != nil {
+		return nil, err
+	}
+	if f.param.Bits != uint64(len(b))*8 {
+		err = fmt.Errorf("%w: %v bits are rounded up to %v by PageAlignment", InconsistentParamErr, len(b)*8, f.param.Bits)
+	} else {
+		err = f.importBloom(b)
+	}
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(filename)
+		return nil, err
+	}
+	return f, nil
+}
+
+// importBloom writes b into the empty bloom filter.
+func (f *DiskFilter) importBloom(b []byte) error {
+	if !f.acquire() {
+		return ClosedErr
+	}
+	defer f.release()
+	f.lock()
+	defer f.file.mu.Unlock()
+	const chunk = 1 << 16
+	var set uint64
+	for offset := 0; offset < len(b); offset += chunk {
+		end := offset + chunk
+		if end > len(b) {
+			end = len(b)
+		}
+		if _, err := f.file.rw.WriteAt(b[offset:end], f.fileOffset(int64(offset))); err != nil {
+			return err
+		}
+		for i := offset; i < end; i++ {
+			if b[i] == 0 {
+				continue
+			}
+			if err := f.wroteLocked(b[i], f.fileOffset(int64(i))); err != nil {
+				return err
+			}
+			set += uint64(bits.OnesCount8(b[i]))
+		}
+	}
+	atomic.AddUint64(&f.setBits, set)
+	f.file.modified = true
+	if f.file.fsync == FsyncModeAlways {
+		return f.syncFile()
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package disk_bloom
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// goBloomAdd adds an entry like the classic filter of github.com/riobard/go-bloom.
+func goBloomAdd(b []byte, k uint8, entry []byte) {
+	x, y := doubleXXHash64(entry)
+	for i := 0; i < int(k); i++ {
+		offset := (x + uint64(i)*y) % (8 * uint64(len(b)))
+		b[offset/8] |= 1 << (offset % 8)
+	}
+}
+
+func TestImportGoBloom(t *testing.T) {
+	slots, size := GoBloomParam(1000, 1e-3)
+	if slots != 9 || size != 1797 {
+		t.Fatalf("Should be 9 hashes and 1797 bytes as go-bloom, got %v and %v", slots, size)
+	}
+	b := make([]byte, size)
+	for i := 0; i < 1000; i++ {
+		goBloomAdd(b, slots, []byte(fmt.Sprint(i)))
+	}
+	controller := Controller{
+		Fsync: FsyncModeNo,
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			return FilterParam{Hash: doubleXXHash64}, nil
+		},
+	}
+	bf, err := ImportGoBloom("testfile", controller, b, slots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("testfile")
+	defer bf.Close()
+	for i := 0; i < 1000; i++ {
+		if !bf.Exist([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%v should be imported", i)
+		}
+	}
+	if count := bf.EstimateCount(); count < 950 || count > 1050 {
+		t.Fatalf("count should be about 1000, got %v", count)
+	}
+	if _, err = ImportGoBloom("testfile", controller, b, slots); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("Should not overwrite an existing file, got %v", err)
+	}
+}
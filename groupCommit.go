@@ -0,0 +1,121 @@
+package disk_bloom
+
+import (
+	"sync"
+	"time"
+)
+
+// groupCommit lets concurrent adds in FsyncModeAlways share a single sync.
+// Adds are grouped into batches numbered from 1. The first add waiting for a batch becomes the leader,
+// which lingers for a while to gather more adds into the batch, and then syncs for all of them.
+type groupCommit struct {
+	f      *DiskFilter
+	linger time.Duration
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	// current is the batch accepting adds
+	current uint64
+	// synced is the latest batch synced
+	synced uint64
+	// enqueued is the latest batch having adds
+	enqueued uint64
+	leading  bool
+	// failed is the first batch failed to be synced, and err is its error. The batches from it on all fail,
+	// since the bytes written before the failed sync may never reach the disk, and the filter becomes read-only.
+	failed uint64
+	err    error
+}
+
+func newGroupCommit(f *DiskFilter, linger time.Duration) *groupCommit {
+	c := &groupCommit{
+		f:       f,
+		linger:  linger,
+		current: 1,
+	}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// enqueueLocked records the written file offsets into the current batch and returns the batch.
+// It should be invoked with the file lock held, after the bytes are written.
+func (c *groupCommit) enqueueLocked(written []int64) uint64 {
+	c.mu.Lock()
+	batch := c.current
+	c.enqueued = batch
+	c.mu.Unlock()
+	for _, pos := range written {
+		c.f.unsynced[pos] = batch
+	}
+	return batch
+}
+
+// sync syncs the file for the batch, and forgets the offsets synced.
+// The bytes of the batch are all written since the batch is closed before.
+// If it fails, the offsets are kept waiting, so that the lookups do not report them, and the filter becomes read-only.
+func (c *groupCommit) sync(batch uint64, failed bool) error {
+	err := c.f.syncFile()
+	c.f.file.mu.Lock()
+	defer c.f.file.mu.Unlock()
+	if err != nil || failed {
+		c.f.readOnly = true
+		return err
+	}
+	for pos, b := range c.f.unsynced {
+		if b <= batch {
+			delete(c.f.unsynced, pos)
+		}
+	}
+	return nil
+}
+
+// flush waits for all batches having adds to be synced.
+func (c *groupCommit) flush() error {
+	c.mu.Lock()
+	batch := c.enqueued
+	c.mu.Unlock()
+	return c.wait(batch)
+}
+
+// wait blocks until the given batch is synced, and returns the error of syncing it.
+func (c *groupCommit) wait(batch uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.synced < batch {
+		if c.leading {
+			c.cond.Wait()
+			continue
+		}
+		c.leading = true
+		c.mu.Unlock()
+		time.Sleep(c.linger)
+		c.mu.Lock()
+		closing := c.current
+		c.current++
+		failed := c.failed > 0
+		c.mu.Unlock()
+		err := c.sync(closing, failed)
+		c.mu.Lock()
+		if err != nil && c.failed == 0 {
+			c.failed, c.err = closing, err
+		}
+		c.synced = closing
+		c.leading = false
+		c.cond.Broadcast()
+	}
+	if c.failed > 0 && batch >= c.failed {
+		return c.err
+	}
+	return nil
+}
+
+// pendingLocked returns whether any byte of the bit offsets is waiting for a group commit,
+// e.g. of a batch failed to be synced. It should be invoked with the file lock held.
+func (f *DiskFilter) pendingLocked(offsets []uint64) bool {
+	for _, offset := range offsets {
+		if _, ok := f.unsynced[f.fileOffset(int64(offset/8))]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"os"
+)
+
+// WithMaxFiles keeps at most n files in the group, counting the next filter prepared in the background,
+// by evicting the oldest filters on rotation, which expires their entries. n should be 2 at least.
+func WithMaxFiles(n int) GroupOption {
+	return func(g *FilterGroup) {
+		g.maxFiles = n
+	}
+}
+
+// WithDiskBudget keeps the total size of the files of the group within bytes, counting the next filter prepared
+// in the background, by evicting the oldest filters on rotation, which expires their entries.
+// The budget should hold two filters at least, and SetCapacity fails to grow the filters beyond it.
+// The consolidated filter of Consolidate is counted from the next rotation.
+func WithDiskBudget(bytes int64) GroupOption {
+	return func(g *FilterGroup) {
+		g.diskBudget = bytes
+	}
+}
+
+// checkLimits returns InvalidParamErr if the limits can not hold the active filter and the next one of bits.
+func (g *FilterGroup) checkLimits(bits uint64) error {
+	if g.maxFiles != 0 && g.maxFiles < 2 {
+		return fmt.Errorf("%w: at most %v files can not hold the active filter and the next one", InvalidParamErr, g.maxFiles)
+	}
+	if size := fileSizeOf(bits); g.diskBudget != 0 && g.diskBudget < 2*size {
+		return fmt.Errorf("%w: the disk budget of %v bytes can not hold two filters of %v bytes", InvalidParamErr, g.diskBudget, size)
+	}
+	return nil
+}
+
+// fileSizeOf returns the size of the file of a new filter of bits.
+func fileSizeOf(bits uint64) int64 {
+	h := Header{Version: HeaderVersion, Size: HeaderSize}
+	return h.bloomStart(metadataSize) + h.bloomSize(h.bloomBits(bits))
+}
+
+// size returns the size of the file of the filter, or 0 if it is gone.
+func (o *filterObj) size() int64 {
+	info, err := os.Stat(o.filename)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// evictLocked drops the oldest filters until the files of the group and the next filter to prepare are
+// within the limits. The active filter is never dropped.
+// It should be invoked with mu held when no filter is being prepared, and none is prepared.
+func (g *FilterGroup) evictLocked() error {
+	if g.maxFiles <= 0 && g.diskBudget <= 0 {
+		return nil
+	}
+	filters := g.load()
+	sizes := make([]int64, len(filters))
+	used := fileSizeOf(g.param.Bits)
+	for i, f := range filters {
+		sizes[i] = f.size()
+		used += sizes[i]
+	}
+	evicted := 0
+	for ; evicted < len(filters)-1; evicted++ {
+		files := len(filters) - evicted + 1
+		if (g.maxFiles <= 0 || files <= g.maxFiles) && (g.diskBudget <= 0 || used <= g.diskBudget) {
+			break
+		}
+		used -= sizes[evicted]
+	}
+	if evicted == 0 {
+		return nil
+	}
+	rest := append([]*filterObj(nil), filters[evicted:]...)
+	g.filters.Store(rest)
+	for _, f := range filters[:evicted] {
+		_ = f.filter.Close()
+		if err := os.Remove(f.filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return g.renumberLocked(rest, 0)
+}
@@ -0,0 +1,137 @@
+package disk_bloom
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// groupFiles returns the names and the total size of the files in dir.
+func groupFiles(t *testing.T, dir string) (names []string, size int64) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, e.Name())
+		size += info.Size()
+	}
+	return names, size
+}
+
+func TestFilterGroup_WithMaxFiles(t *testing.T) {
+	dir := t.TempDir()
+	g, err := NewGroup(filepath.Join(dir, "*"), FsyncModeNo, 1000, 1e-3, doubleFNV, WithMaxFiles(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	for i := 0; i < 5; i++ {
+		g.ExistOrAdd([]byte{byte(i)})
+		if err = g.RotateNow(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g.tasks.wait()
+	if names, _ := groupFiles(t, dir); strings.Join(names, ",") != "0,1,2" {
+		t.Fatalf("Should keep the sequence of 3 files, got %v", names)
+	}
+	if g.Exist([]byte{3}) || !g.Exist([]byte{4}) {
+		t.Fatal("Should evict the oldest filters only")
+	}
+}
+
+func TestFilterGroup_WithDiskBudget(t *testing.T) {
+	dir := t.TempDir()
+	_, bits := OptimalParam(1000, 1e-3)
+	budget := 4*fileSizeOf(bits) - 1
+	g, err := NewGroup(filepath.Join(dir, "*"), FsyncModeNo, 1000, 1e-3, doubleFNV, WithDiskBudget(budget))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	for i := 0; i < 5; i++ {
+		if err = g.RotateNow(); err != nil {
+			t.Fatal(err)
+		}
+		g.tasks.wait()
+		if names, size := groupFiles(t, dir); size > budget {
+			t.Fatalf("Should keep within %v bytes, got %v bytes of %v", budget, size, names)
+		}
+	}
+	if n := len(g.Members()); n != 2 {
+		t.Fatalf("Should keep 2 filters and the next one, got %v", n)
+	}
+	if err = g.SetCapacity(8000, 1e-3); !errors.Is(err, InvalidParamErr) {
+		t.Fatalf("Should not grow beyond the budget, got %v", err)
+	}
+}
+
+func TestFilterGroup_InvalidLimits(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewGroup(filepath.Join(dir, "*"), FsyncModeNo, 1000, 1e-3, doubleFNV, WithMaxFiles(1)); !errors.Is(err, InvalidParamErr) {
+		t.Fatalf("Should fail for 1 file, got %v", err)
+	}
+	if _, err := NewGroup(filepath.Join(dir, "*"), FsyncModeNo, 1000, 1e-3, doubleFNV, WithDiskBudget(4096)); !errors.Is(err, InvalidParamErr) {
+		t.Fatalf("Should fail for a small budget, got %v", err)
+	}
+	if names, _ := groupFiles(t, dir); len(names) != 0 {
+		t.Fatalf("Should create no files, got %v", names)
+	}
+}
+
+func TestFilterGroup_NamingSchemeTimestamp(t *testing.T) {
+	dir := t.TempDir()
+	pattern := filepath.Join(dir, "filter-*.bloom")
+	g, err := NewGroup(pattern, FsyncModeNo, 1000, 1e-3, doubleFNV, WithNamingScheme(NamingSchemeTimestamp), WithMaxFiles(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		g.ExistOrAdd([]byte{byte(i)})
+		if err = g.RotateNow(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g.tasks.wait()
+	members := g.Members()
+	g.ExistOrAdd([]byte("active"))
+	if err = g.Close(); err != nil {
+		t.Fatal(err)
+	}
+	names, _ := groupFiles(t, dir)
+	if len(names) != 3 {
+		t.Fatalf("Should keep 3 files, got %v", names)
+	}
+	for _, name := range names {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, "filter-"), ".bloom")
+		if _, err := time.Parse(timestampLayout, stamp); err != nil {
+			t.Fatalf("Should be named by the time, got %v", name)
+		}
+	}
+
+	g, err = NewGroup(pattern, FsyncModeNo, 1000, 1e-3, doubleFNV, WithNamingScheme(NamingSchemeTimestamp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	reopened := g.Members()
+	if len(reopened) != len(members) {
+		t.Fatalf("Should open %v filters, got %v", len(members), len(reopened))
+	}
+	for i := range members {
+		if reopened[i].Filename != members[i].Filename {
+			t.Fatalf("Should open the filters in order, got %v, want %v", reopened[i].Filename, members[i].Filename)
+		}
+	}
+	if !g.Exist([]byte{3}) || !g.Exist([]byte("active")) || g.Exist([]byte{1}) {
+		t.Fatal("Should keep the entries of the retained filters only")
+	}
+}
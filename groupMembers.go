@@ -0,0 +1,75 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+var NoSuchMemberErr = fmt.Errorf("no such member of the group")
+
+// Member is a filter of a FilterGroup, see Members.
+type Member struct {
+	// Window is the generation of the filter like FirstSeenWindow: 0 is the active filter, 1 is the filter before it, and so on
+	Window   int
+	Filename string
+	// Created is the time the file was created, or zero if not recorded
+	Created time.Time
+	// Added is the number of entries added to it, and Expected is the number it is sized for
+	Added     uint64
+	Expected  uint64
+	FillRatio float64
+	// Hits is the decayed number of lookups found in it, which orders the lookups, see WithAdaptiveProbeOrder
+	Hits uint64
+	// Filter is the filter, which should not be closed but by DropMember
+	Filter *DiskFilter
+}
+
+// Members returns the filters of the group, the oldest first, which is the first to drop to bound the group,
+// and the active filter last.
+func (g *FilterGroup) Members() []Member {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	filters := g.load()
+	members := make([]Member, len(filters))
+	for i, f := range filters {
+		members[i] = Member{
+			Window:    len(filters) - 1 - i,
+			Filename:  f.filename,
+			Created:   f.filter.Header().Created,
+			Added:     atomic.LoadUint64(&f.added),
+			Expected:  atomic.LoadUint64(&f.expected),
+			FillRatio: f.filter.FillRatio(),
+			Hits:      atomic.LoadUint64(&f.hits),
+			Filter:    f.filter,
+		}
+	}
+	return members
+}
+
+// DropMember closes the filter of the window and removes its file, e.g. to expire the entries of the oldest window.
+// The files of the newer filters are renamed to keep the sequence of the group.
+// The active filter can not be dropped, rotate by RotateNow first.
+func (g *FilterGroup) DropMember(window int) error {
+	// the preparation holds the name of the next filter, so wait for it
+	g.lockIdle()
+	defer g.mu.Unlock()
+	filters := g.load()
+	if window <= 0 || window >= len(filters) {
+		if window == 0 {
+			return fmt.Errorf("%w: dropping the active filter", UnsupportedErr)
+		}
+		return fmt.Errorf("%w: window %v of %v filters", NoSuchMemberErr, window, len(filters))
+	}
+	i := len(filters) - 1 - window
+	dropped := filters[i]
+	// copy to avoid modifying the slice in use
+	rest := append(append(make([]*filterObj, 0, len(filters)-1), filters[:i]...), filters[i+1:]...)
+	g.filters.Store(rest)
+	_ = dropped.filter.Close()
+	if err := os.Remove(dropped.filename); err != nil {
+		return err
+	}
+	return g.renumberLocked(rest, i)
+}
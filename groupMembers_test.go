@@ -0,0 +1,78 @@
+package disk_bloom
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestFilterGroup_Members(t *testing.T) {
+	os.Mkdir("testfile", os.ModePerm)
+	g, err := NewGroup("testfile/*", FsyncModeNo, 1e3, 1e-3, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		g.Close()
+		os.RemoveAll("testfile")
+	}()
+	// three windows of 10 entries each
+	for w := 0; w < 3; w++ {
+		if w > 0 {
+			if err = g.RotateNow(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i := 0; i < 10; i++ {
+			g.ExistOrAdd([]byte(strconv.Itoa(w*10 + i)))
+		}
+	}
+	members := g.Members()
+	if len(members) != 3 {
+		t.Fatalf("Should have 3 members, got %v", len(members))
+	}
+	for i, m := range members {
+		if m.Window != 2-i || m.Filename != g.positionFilename(i) || m.Added != 10 || m.Created.IsZero() || m.FillRatio <= 0 {
+			t.Fatalf("%v: unexpected %+v", i, m)
+		}
+	}
+	if members[2].Filter != g.Active() || members[2].Expected != 1e3 || members[0].Expected != 10 {
+		t.Fatalf("Unexpected members %+v", members)
+	}
+
+	if err = g.DropMember(0); !errors.Is(err, UnsupportedErr) {
+		t.Fatalf("Should be UnsupportedErr, got %v", err)
+	}
+	if err = g.DropMember(3); !errors.Is(err, NoSuchMemberErr) {
+		t.Fatalf("Should be NoSuchMemberErr, got %v", err)
+	}
+	// the oldest window expires
+	if err = g.DropMember(2); err != nil {
+		t.Fatal(err)
+	}
+	if g.Exist([]byte("0")) || !g.Exist([]byte("10")) || !g.Exist([]byte("20")) {
+		t.Fatal("Should drop the entries of the oldest window only")
+	}
+	members = g.Members()
+	if len(members) != 2 || members[0].Filename != g.positionFilename(0) || members[0].Window != 1 {
+		t.Fatalf("Unexpected members %+v", members)
+	}
+
+	// the sequence is kept after reopening
+	if err = g.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if g, err = NewGroup("testfile/*", FsyncModeNo, 1e3, 1e-3, doubleFNV); err != nil {
+		t.Fatal(err)
+	}
+	if members = g.Members(); len(members) != 2 || members[0].Added != 10 || members[1].Added != 10 {
+		t.Fatalf("Unexpected members after reopening %+v", members)
+	}
+	if len(g.Skipped()) != 0 {
+		t.Fatalf("Should skip no files, got %v", g.Skipped())
+	}
+	if !g.Exist([]byte("10")) || !g.Exist([]byte("20")) {
+		t.Fatal("Should keep the entries after reopening")
+	}
+}
@@ -0,0 +1,105 @@
+package disk_bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/mzz2017/disk-bloom/format"
+)
+
+// HashKind selects a built-in double hash of FilterParam. It is recorded in the header of new files,
+// so that a file is never read with a different hash.
+type HashKind uint8
+
+const (
+	// HashKindCustom means FilterParam.Hash is given by the application.
+	HashKindCustom HashKind = iota
+	// HashKindXXHash64 is xxHash64 of the entry with two seeds. It is fast, and the recommended choice.
+	HashKindXXHash64
+	// HashKindFNV is FNV-1 and FNV-1a of the entry, which distribute similar entries poorly.
+	HashKindFNV
+	// HashKindSipHash is the 128-bit output of SipHash-2-4 keyed by FilterParam.HashKey of 16 bytes,
+	// against adversaries crafting entries to collide.
+	HashKindSipHash
+)
+
+var HashKindErr = fmt.Errorf("invalid hash kind")
+
+func (k HashKind) String() string {
+	switch k {
+	case HashKindCustom:
+		return "custom"
+	case HashKindXXHash64:
+		return "xxhash64"
+	case HashKindFNV:
+		return "fnv"
+	case HashKindSipHash:
+		return "siphash"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(k))
+	}
+}
+
+// hardenedY returns the second hash of the probes of Controller.HardenedProbes, see format.HardenedY.
+func hardenedY(x, y, bits uint64) uint64 {
+	return format.HardenedY(x, y, bits)
+}
+
+// saltHash returns the double hash mixed with the salt of salted filters, see format.SaltHash.
+func saltHash(x, y, salt uint64) (uint64, uint64) {
+	return format.SaltHash(x, y, salt)
+}
+
+// resolveHash sets Hash to the built-in hash of HashKind.
+func (p *FilterParam) resolveHash() error {
+	switch p.HashKind {
+	case HashKindCustom:
+		if p.Hash == nil {
+			return fmt.Errorf("%w: Hash is required by %v", HashKindErr, p.HashKind)
+		}
+		return nil
+	case HashKindXXHash64:
+		p.Hash = doubleXXHash64
+	case HashKindFNV:
+		p.Hash = doubleFNV
+	case HashKindSipHash:
+		if len(p.HashKey) != 16 {
+			return fmt.Errorf("%w: %v needs a key of 16 bytes, got %v", HashKindErr, p.HashKind, len(p.HashKey))
+		}
+		k0, k1 := binary.LittleEndian.Uint64(p.HashKey), binary.LittleEndian.Uint64(p.HashKey[8:])
+		p.Hash = func(b []byte) (uint64, uint64) {
+			return sipHash128(k0, k1, b)
+		}
+	default:
+		return fmt.Errorf("%w: %v", HashKindErr, p.HashKind)
+	}
+	return nil
+}
+
+// checkHashKind checks that the hash kind of param is the one recorded in the header.
+func checkHashKind(header Header, param FilterParam) error {
+	if header.Version == 0 || header.HashKind == param.HashKind {
+		return nil
+	}
+	return fmt.Errorf("%w: the file is hashed by %v, which is different from %v", HashKindErr, header.HashKind, param.HashKind)
+}
+
+// The built-in hashes are implemented by the format package, which decodes the files without this package.
+
+func doubleFNV(b []byte) (uint64, uint64) {
+	return format.DoubleFNV(b)
+}
+
+func doubleXXHash64(b []byte) (uint64, uint64) {
+	return format.DoubleXXHash64(b)
+}
+
+// xxHash64 is XXH64 of b with the seed.
+func xxHash64(b []byte, seed uint64) uint64 {
+	return format.XXHash64(b, seed)
+}
+
+// sipHash128 is the 128-bit output of SipHash-2-4 of b keyed by k0 and k1.
+func sipHash128(k0, k1 uint64, b []byte) (uint64, uint64) {
+	return format.SipHash128(k0, k1, b)
+}
@@ -0,0 +1,134 @@
+package disk_bloom
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestXXHash64(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want uint64
+	}{
+		{"", 0xef46db3751d8e999},
+		{"a", 0xd24ec4f1a98c6e5b},
+		{"abc", 0x44bc2cf5ad770999},
+	} {
+		if got := xxHash64([]byte(c.in), 0); got != c.want {
+			t.Fatalf("xxHash64(%q) = %x, want %x", c.in, got, c.want)
+		}
+	}
+}
+
+func TestSipHash128(t *testing.T) {
+	// the first vector of the reference implementation, with the key 00 01 ... 0f
+	x, y := sipHash128(0x0706050403020100, 0x0f0e0d0c0b0a0908, nil)
+	if x != 0xe6a825ba047f81a3 || y != 0x930255c71472f66d {
+		t.Fatalf("sipHash128 = %x %x", x, y)
+	}
+}
+
+func TestHashKind(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	bf, err := Open("testfile", WithHashKind(HashKindSipHash, key), WithCapacity(1e4, 1e-4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("testfile")
+	if bf.ExistOrAdd([]byte("testing")) {
+		t.Fatal("should be new")
+	}
+	if kind := bf.Header().HashKind; kind != HashKindSipHash {
+		t.Fatalf("hash kind should be %v, got %v", HashKindSipHash, kind)
+	}
+	if err = bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Open("testfile", WithHashKind(HashKindXXHash64, nil), WithCapacity(1e4, 1e-4)); !errors.Is(err, HashKindErr) {
+		t.Fatalf("should fail with HashKindErr, got %v", err)
+	}
+	if _, err = Open("testfile", WithHash(doubleFNV), WithCapacity(1e4, 1e-4)); !errors.Is(err, HashKindErr) {
+		t.Fatalf("should fail with HashKindErr, got %v", err)
+	}
+	if _, err = Open("testfile", WithHashKind(HashKindSipHash, key[:8]), WithCapacity(1e4, 1e-4)); !errors.Is(err, HashKindErr) {
+		t.Fatalf("should fail with HashKindErr, got %v", err)
+	}
+	bf, err = Open("testfile", WithHashKind(HashKindSipHash, key), WithCapacity(1e4, 1e-4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bf.Close()
+	if !bf.Exist([]byte("testing")) {
+		t.Fatal("should exist")
+	}
+}
+
+func TestHardenedProbes(t *testing.T) {
+	// a weak hash whose second half is degenerate
+	for name, hash := range map[string]func([]byte) (uint64, uint64){
+		"zero": func(b []byte) (uint64, uint64) {
+			x, _ := doubleFNV(b)
+			return x, 0
+		},
+		"equal": func(b []byte) (uint64, uint64) {
+			x, _ := doubleFNV(b)
+			return x, x
+		},
+	} {
+		fpr := make(map[bool]float64)
+		for _, hardened := range []bool{false, true} {
+			bf, err := New("testfile", Controller{HardenedProbes: hardened, GetParam: func([]byte) (FilterParam, []byte) {
+				return FilterParam{Slots: 7, Bits: 1 << 14, Hash: hash}, nil
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bf.Header().Hardened() != hardened {
+				t.Fatalf("Should record HardenedProbes in the header")
+			}
+			for i := 0; i < 1000; i++ {
+				bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+			}
+			if hardened {
+				if n := len(uniqueOffsets(bf.offsets(bf.Hash([]byte("testing")), 7))); n < 6 {
+					t.Fatalf("%v: the probes should not collapse, got %v distinct", name, n)
+				}
+				bf.Close()
+				// recorded in the header
+				if bf, err = New("testfile", Controller{GetParam: func([]byte) (FilterParam, []byte) {
+					return FilterParam{Hash: hash}, nil
+				}}); err != nil {
+					t.Fatal(err)
+				}
+				for i := 0; i < 1000; i++ {
+					if !bf.Exist([]byte(strconv.Itoa(i))) {
+						t.Fatalf("%v should exist in filter", i)
+					}
+				}
+			}
+			positives := 0
+			for i := 1000; i < 11000; i++ {
+				if bf.Exist([]byte(strconv.Itoa(i))) {
+					positives++
+				}
+			}
+			fpr[hardened] = float64(positives) / 10000
+			bf.Close()
+			os.Remove("testfile")
+		}
+		// 1000 entries in 2^14 bits with 7 probes: about 2.5e-3 if the probes are independent
+		if fpr[true] > 0.01 || fpr[true]*5 > fpr[false] {
+			t.Fatalf("%v: the false positive rate should drop by hardening, got %v, without %v", name, fpr[true], fpr[false])
+		}
+	}
+}
+
+func uniqueOffsets(offsets []uint64) map[uint64]bool {
+	m := make(map[uint64]bool)
+	for _, offset := range offsets {
+		m[offset] = true
+	}
+	return m
+}
@@ -0,0 +1,538 @@
+package disk_bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// The header is placed between the metadata and the bloom filter, so that the layout seen by Control is unchanged:
+//
+// | len of metadata size(2 bytes) | metadata | header | bloom filter |
+//
+// Files created before the header was introduced have no header, and are read as version 0.
+// In version 1, the bloom filter follows the header immediately.
+// Since version 2, the bloom filter starts at the next page boundary after the header, so that pages of the file
+// and pages of the bloom filter coincide.
+// All integers are little-endian on every platform, which is recorded by the byte order mark since it was introduced;
+// the files without the mark were written little-endian as well, and are marked when opened for writing.
+// The bit i of the bloom filter is the bit i%8 of the byte i/8, the least significant bit first,
+// so that a file is interchangeable across architectures given the same Fingerprint.
+// The format package decodes the files without this package.
+//
+//	offset  size  field
+//	0       8     magic "DSKBLOOM"
+//	8       2     version
+//	10      2     flags
+//	12      4     header size
+//	16      16    nonce of the encryption
+//	32      8     key check of the encryption
+//	40      32    HMAC-SHA256 of the metadata and the header
+//	72      1     extra slots of adaptive filters
+//	73      1     counter width of counting filters
+//	74      1     hash kind
+//	75      1     slots
+//	76      1     buckets of ttl filters
+//	78      2     byte order mark 0x0102, or 0 if not recorded
+//	80      8     bits set of adaptive filters
+//	88      8     seed of xor filters
+//	96      8     number of fingerprints of xor filters
+//	104     8     bits
+//	112     8     creation time in unix nanoseconds
+//	120     8     time of the last add in unix nanoseconds
+//	128     8     time of the last sync in unix nanoseconds
+//	136     8     bucket span of ttl filters in nanoseconds
+//	144     4     block size of blocked filters in bytes
+//	148     1     scope of the HMAC
+//	149     3     reserved
+//	152     8     salt of FlagSalted
+//	160     96    reserved for parameters
+//	256     64    application tag: len(1) + bytes
+//	320     64    creator hostname: len(1) + bytes
+//	384     64    library version: len(1) + bytes
+//	448     256   creation command: len(2) + bytes
+//	704     320   rotating slots of the times and the bits set of FlagWearLeveled, or reserved
+const (
+	headerMagic   = "DSKBLOOM"
+	HeaderVersion = 2
+	HeaderSize    = 1024
+
+	headerFlagsOffset     = 10
+	headerNonceOffset     = 16
+	headerKeyCheckOffset  = 32
+	headerMACOffset       = 40
+	headerAdaptiveOffset  = 72
+	headerCounterOffset   = 73
+	headerHashKindOffset  = 74
+	headerSlotsOffset     = 75
+	headerBucketsOffset   = 76
+	headerByteOrderOffset = 78
+	headerSetBitsOffset   = 80
+	headerSeedOffset      = 88
+	headerXorSizeOffset   = 96
+	headerBitsOffset      = 104
+	headerCreatedOffset   = 112
+	headerLastAddOffset   = 120
+	headerLastSyncOffset  = 128
+	headerSpanOffset      = 136
+	headerBlockOffset     = 144
+	headerMACScopeOffset  = 148
+	headerSaltOffset      = 152
+	headerTagOffset       = 256
+	headerHostOffset      = 320
+	headerLibOffset       = 384
+	headerCommandOffset   = 448
+	headerStringSize      = 64
+	headerCommandSize     = 256
+	headerReservedOffset  = 704
+
+	// headerByteOrder is the byte order mark, which reads as 0x0201 if the header was written big-endian
+	headerByteOrder = 0x0102
+)
+
+// Flags in the header
+const (
+	// FlagEncrypted means the metadata and the bloom filter are encrypted.
+	FlagEncrypted uint16 = 1 << iota
+	// FlagSigned means the header carries an HMAC of the metadata and the header.
+	FlagSigned
+	// FlagAligned means the size of the bloom filter is rounded up to a multiple of the page size,
+	// and the bloom filter starts at a page boundary even in version 1.
+	FlagAligned
+	// FlagFastRange means the probes are mapped to the bloom filter by Lemire's multiply-shift instead of modulo.
+	FlagFastRange
+	// FlagSealed means the file is permanently read-only, see DiskFilter.Seal.
+	FlagSealed
+	// FlagShrunk means only the non-zero blocks of the bloom filter are kept, see Controller.ShrinkOnSeal.
+	FlagShrunk
+	// FlagAdaptive means Exist probes according to the fill ratio, see Controller.AdaptiveSlots.
+	FlagAdaptive
+	// FlagCounting means each bit of the bloom filter is replaced by a counter, see DiskCountingFilter.
+	FlagCounting
+	// FlagXor means the bloom filter is replaced by the fingerprints of a xor filter, see DiskXorFilter.
+	FlagXor
+	// FlagHardened means the degenerate double hashes are corrected before probing, see Controller.HardenedProbes.
+	FlagHardened
+	// FlagCuckoo means the bloom filter is replaced by the buckets of a cuckoo filter, see DiskCuckooFilter.
+	FlagCuckoo
+	// FlagTTL means the bloom filter is divided into time buckets whose entries expire, see DiskTTLFilter.
+	FlagTTL
+	// FlagBlocked means all the probes of an entry land in one block, see FilterParam.BlockSize.
+	FlagBlocked
+	// FlagWearLeveled means the times and the bits set rotate over the slots of the header, see Controller.WearLeveling.
+	FlagWearLeveled
+	// FlagSalted means the double hash is mixed with the salt of the header before probing, see Controller.Salted.
+	FlagSalted
+)
+
+var (
+	InvalidHeaderErr     = fmt.Errorf("invalid header")
+	InconsistentParamErr = fmt.Errorf("inconsistent parameters")
+)
+
+// variant is the kind of filter stored in a file.
+type variant uint8
+
+const (
+	variantClassic variant = iota
+	variantCounting
+	variantXor
+	variantCuckoo
+	variantTTL
+)
+
+func (v variant) String() string {
+	switch v {
+	case variantCounting:
+		return "counting"
+	case variantXor:
+		return "xor"
+	case variantCuckoo:
+		return "cuckoo"
+	case variantTTL:
+		return "ttl"
+	default:
+		return "classic"
+	}
+}
+
+// variant returns the kind of filter stored in the file.
+func (h Header) variant() variant {
+	switch {
+	case h.Counting():
+		return variantCounting
+	case h.Xor():
+		return variantXor
+	case h.Cuckoo():
+		return variantCuckoo
+	case h.TTL():
+		return variantTTL
+	default:
+		return variantClassic
+	}
+}
+
+// Header describes the provenance of a filter file.
+type Header struct {
+	// Version is 0 if the file has no header.
+	Version uint16
+	Flags   uint16
+	// Size of the header in bytes
+	Size uint32
+	// Tag is defined by the application.
+	Tag string
+	// Hostname of the host creating the file
+	Hostname string
+	// LibraryVersion is the version of this package creating the file
+	LibraryVersion string
+	// Command is the command line of the process creating the file
+	Command string
+	// AdaptiveSlots is the number of extra bits set per entry of adaptive filters
+	AdaptiveSlots uint8
+	// CounterWidth is the number of bits of each counter of counting filters
+	CounterWidth uint8
+	// HashKind is the built-in hash of the filter, or HashKindCustom
+	HashKind HashKind
+	// Slots and Bits are the parameters of the filter. They are 0 if the file was created before they are recorded.
+	Slots uint8
+	Bits  uint64
+	// Buckets is the number of time buckets of ttl filters, and BucketSpan is the time each of them covers
+	Buckets    uint8
+	BucketSpan time.Duration
+	// BlockSize is the size in bytes of the blocks of blocked filters
+	BlockSize uint32
+	// Salt is mixed into the double hash of salted filters, see Controller.Salted. A replica is created with the same salt.
+	Salt uint64
+	// Created is the time the file was created, or zero if not recorded
+	Created time.Time
+	// LastAdd is the last time an add changed the filter, and LastSync is the last time the file was synced,
+	// e.g. to delete the filters untouched for days. They are zero if not recorded, and are written into the file
+	// every second while it is modified and on Close, so LastSync in the file is the sync before the last one.
+	LastAdd  time.Time
+	LastSync time.Time
+
+	// setBits is the number of bits set of adaptive filters when the file was opened
+	setBits uint64
+	// seed is the seed of the hash of xor filters
+	seed uint64
+	// fingerprints is the number of fingerprints of xor filters
+	fingerprints uint64
+	// byteOrder is the byte order mark, which is 0 in the files written before it was introduced
+	byteOrder uint16
+	// timesSeq is the seq of the latest slot of the times of FlagWearLeveled
+	timesSeq uint32
+	// macScope is what the HMAC covers, see headerMAC
+	macScope uint8
+
+	nonce    [16]byte
+	keyCheck [8]byte
+	mac      [32]byte
+}
+
+// Signed returns whether the header carries an HMAC of the metadata and the header.
+func (h Header) Signed() bool {
+	return h.Flags&FlagSigned != 0
+}
+
+// Encrypted returns whether the metadata and the bloom filter are encrypted.
+func (h Header) Encrypted() bool {
+	return h.Flags&FlagEncrypted != 0
+}
+
+// Aligned returns whether the size of the bloom filter is a multiple of the page size.
+func (h Header) Aligned() bool {
+	return h.Flags&FlagAligned != 0
+}
+
+// FastRange returns whether the probes are mapped by Lemire's multiply-shift.
+func (h Header) FastRange() bool {
+	return h.Flags&FlagFastRange != 0
+}
+
+// Hardened returns whether the degenerate double hashes are corrected before probing.
+func (h Header) Hardened() bool {
+	return h.Flags&FlagHardened != 0
+}
+
+// Salted returns whether the double hash is mixed with Salt before probing.
+func (h Header) Salted() bool {
+	return h.Flags&FlagSalted != 0
+}
+
+// Blocked returns whether all the probes of an entry land in one block of BlockSize bytes.
+func (h Header) Blocked() bool {
+	return h.Flags&FlagBlocked != 0
+}
+
+// Portable returns whether the header records its byte order, see Fingerprint.
+// The files written before the byte order mark was introduced are marked when opened for writing.
+func (h Header) Portable() bool {
+	return h.byteOrder == headerByteOrder
+}
+
+// bloomStart returns the file offset of the bloom filter.
+func (h Header) bloomStart(metadataSize uint16) int64 {
+	start := LenOfMetadataSize + int64(metadataSize) + int64(h.Size)
+	if h.Version >= 2 || h.Aligned() {
+		start = (start + pageSize - 1) / pageSize * pageSize
+	}
+	return start
+}
+
+// bloomBits returns the number of bits of the bloom filter in the file.
+func (h Header) bloomBits(bits uint64) uint64 {
+	if h.Blocked() {
+		blockBits := uint64(h.BlockSize) * 8
+		bits = (bits + blockBits - 1) / blockBits * blockBits
+	}
+	if h.Aligned() {
+		const pageBits = pageSize * 8
+		bits = (bits + pageBits - 1) / pageBits * pageBits
+	}
+	return bits
+}
+
+// bloomSize returns the size of the bloom filter in the file with the given bits.
+func (h Header) bloomSize(bits uint64) int64 {
+	if h.Counting() {
+		bits *= uint64(h.CounterWidth)
+	}
+	if h.Aligned() {
+		return int64(bits / 8)
+	}
+	// bits may be not a multiple of 8, so a trailing byte is allocated
+	return int64(bits/8) + 1
+}
+
+// libraryVersion returns the version of this module in the build, or "(devel)".
+func libraryVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if info.Main.Path == "github.com/mzz2017/disk-bloom" {
+			return info.Main.Version
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == "github.com/mzz2017/disk-bloom" {
+				return dep.Version
+			}
+		}
+	}
+	return "(devel)"
+}
+
+// newHeader returns the header of a file created now by this process.
+func newHeader(tag string) (Header, error) {
+	if len(tag) >= headerStringSize {
+		return Header{}, fmt.Errorf("%w: tag is longer than %v bytes", InvalidHeaderErr, headerStringSize-1)
+	}
+	hostname, _ := os.Hostname()
+	return Header{
+		Version:        HeaderVersion,
+		Size:           HeaderSize,
+		Tag:            tag,
+		Hostname:       truncate(hostname, headerStringSize-1),
+		LibraryVersion: truncate(libraryVersion(), headerStringSize-1),
+		Command:        truncate(strings.Join(os.Args, " "), headerCommandSize-2),
+		byteOrder:      headerByteOrder,
+		// as read back by readHeader
+		Created: time.Unix(0, time.Now().UnixNano()),
+	}, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// Encode returns the header in bytes.
+func (h Header) Encode() []byte {
+	b := make([]byte, h.Size)
+	copy(b, headerMagic)
+	binary.LittleEndian.PutUint16(b[8:], h.Version)
+	binary.LittleEndian.PutUint16(b[10:], h.Flags)
+	binary.LittleEndian.PutUint32(b[12:], h.Size)
+	copy(b[headerNonceOffset:], h.nonce[:])
+	copy(b[headerKeyCheckOffset:], h.keyCheck[:])
+	copy(b[headerMACOffset:], h.mac[:])
+	b[headerAdaptiveOffset] = h.AdaptiveSlots
+	b[headerCounterOffset] = h.CounterWidth
+	b[headerHashKindOffset] = uint8(h.HashKind)
+	b[headerSlotsOffset] = h.Slots
+	b[headerBucketsOffset] = h.Buckets
+	binary.LittleEndian.PutUint16(b[headerByteOrderOffset:], h.byteOrder)
+	binary.LittleEndian.PutUint64(b[headerSpanOffset:], uint64(h.BucketSpan))
+	binary.LittleEndian.PutUint32(b[headerBlockOffset:], h.BlockSize)
+	b[headerMACScopeOffset] = h.macScope
+	binary.LittleEndian.PutUint64(b[headerSaltOffset:], h.Salt)
+	binary.LittleEndian.PutUint64(b[headerBitsOffset:], h.Bits)
+	putTime(b[headerCreatedOffset:], h.Created)
+	putTime(b[headerLastAddOffset:], h.LastAdd)
+	putTime(b[headerLastSyncOffset:], h.LastSync)
+	binary.LittleEndian.PutUint64(b[headerSetBitsOffset:], h.setBits)
+	binary.LittleEndian.PutUint64(b[headerSeedOffset:], h.seed)
+	binary.LittleEndian.PutUint64(b[headerXorSizeOffset:], h.fingerprints)
+	b[headerTagOffset] = uint8(copy(b[headerTagOffset+1:headerTagOffset+headerStringSize], h.Tag))
+	b[headerHostOffset] = uint8(copy(b[headerHostOffset+1:headerHostOffset+headerStringSize], h.Hostname))
+	b[headerLibOffset] = uint8(copy(b[headerLibOffset+1:headerLibOffset+headerStringSize], h.LibraryVersion))
+	binary.LittleEndian.PutUint16(b[headerCommandOffset:], uint16(copy(b[headerCommandOffset+2:headerCommandOffset+headerCommandSize], h.Command)))
+	if h.WearLeveled() {
+		h.putTimesSlot(b)
+	}
+	return b
+}
+
+// putTime encodes t in unix nanoseconds, where the zero time is 0.
+func putTime(b []byte, t time.Time) {
+	binary.LittleEndian.PutUint64(b, uint64(timeNanos(t)))
+}
+
+func parseTime(b []byte) time.Time {
+	return nanosTime(int64(binary.LittleEndian.Uint64(b)))
+}
+
+func timeNanos(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func nanosTime(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+func parseString(b []byte, offset int, size int) string {
+	n := int(b[offset])
+	if n > size-1 {
+		n = size - 1
+	}
+	return string(b[offset+1 : offset+1+n])
+}
+
+// readHeader reads the header following the metadata.
+// It returns a zero Header if the file has no header.
+func readHeader(r io.ReaderAt, metadataSize uint16) (Header, error) {
+	offset := LenOfMetadataSize + int64(metadataSize)
+	b := make([]byte, HeaderSize)
+	if n, err := r.ReadAt(b, offset); n < len(headerMagic)+8 || string(b[:len(headerMagic)]) != headerMagic {
+		if err != nil && err != io.EOF {
+			return Header{}, err
+		}
+		return Header{}, nil
+	} else if n < HeaderSize {
+		return Header{}, fmt.Errorf("%w: truncated", InvalidHeaderErr)
+	}
+	h := Header{
+		Version: binary.LittleEndian.Uint16(b[8:]),
+		Flags:   binary.LittleEndian.Uint16(b[10:]),
+		Size:    binary.LittleEndian.Uint32(b[12:]),
+	}
+	if h.Version == 0 || h.Size < HeaderSize {
+		return Header{}, fmt.Errorf("%w: version %v, size %v", InvalidHeaderErr, h.Version, h.Size)
+	}
+	copy(h.nonce[:], b[headerNonceOffset:])
+	copy(h.keyCheck[:], b[headerKeyCheckOffset:])
+	copy(h.mac[:], b[headerMACOffset:])
+	h.AdaptiveSlots = b[headerAdaptiveOffset]
+	h.CounterWidth = b[headerCounterOffset]
+	h.HashKind = HashKind(b[headerHashKindOffset])
+	h.Slots = b[headerSlotsOffset]
+	h.Buckets = b[headerBucketsOffset]
+	h.byteOrder = binary.LittleEndian.Uint16(b[headerByteOrderOffset:])
+	if h.byteOrder != 0 && h.byteOrder != headerByteOrder {
+		return Header{}, fmt.Errorf("%w: byte order mark %#04x", InvalidHeaderErr, h.byteOrder)
+	}
+	h.BucketSpan = time.Duration(binary.LittleEndian.Uint64(b[headerSpanOffset:]))
+	h.BlockSize = binary.LittleEndian.Uint32(b[headerBlockOffset:])
+	h.macScope = b[headerMACScopeOffset]
+	h.Salt = binary.LittleEndian.Uint64(b[headerSaltOffset:])
+	h.Bits = binary.LittleEndian.Uint64(b[headerBitsOffset:])
+	h.Created = parseTime(b[headerCreatedOffset:])
+	h.LastAdd = parseTime(b[headerLastAddOffset:])
+	h.LastSync = parseTime(b[headerLastSyncOffset:])
+	h.setBits = binary.LittleEndian.Uint64(b[headerSetBitsOffset:])
+	h.seed = binary.LittleEndian.Uint64(b[headerSeedOffset:])
+	h.fingerprints = binary.LittleEndian.Uint64(b[headerXorSizeOffset:])
+	h.Tag = parseString(b, headerTagOffset, headerStringSize)
+	h.Hostname = parseString(b, headerHostOffset, headerStringSize)
+	h.LibraryVersion = parseString(b, headerLibOffset, headerStringSize)
+	n := int(binary.LittleEndian.Uint16(b[headerCommandOffset:]))
+	if n > headerCommandSize-2 {
+		n = headerCommandSize - 2
+	}
+	h.Command = string(b[headerCommandOffset+2 : headerCommandOffset+2+n])
+	if h.WearLeveled() {
+		h.parseTimesSlots(b)
+	}
+	return h, nil
+}
+
+// restoreParam fills the parameters not given by GetParam from the header, and checks the given ones against it.
+func (h Header) restoreParam(param *FilterParam) error {
+	if h.Version == 0 {
+		return nil
+	}
+	if param.HashKind == HashKindCustom && param.Hash == nil {
+		param.HashKind = h.HashKind
+	}
+	if h.Xor() {
+		// the parameters of xor filters are decided by the keys
+		param.Slots, param.Bits = xorSlots, h.fingerprints*8
+		return nil
+	}
+	if param.BlockSize == 0 {
+		param.BlockSize = h.BlockSize
+	} else if param.BlockSize != h.BlockSize {
+		return fmt.Errorf("%w: the file is created with block size %v, which is different from %v", InconsistentParamErr, h.BlockSize, param.BlockSize)
+	}
+	if h.Bits == 0 {
+		// not recorded
+		return nil
+	}
+	if param.Slots == 0 && param.Bits == 0 {
+		param.Slots, param.Bits = h.Slots, h.Bits
+		return nil
+	}
+	if param.Slots != h.Slots || h.bloomBits(param.Bits) != h.Bits {
+		return fmt.Errorf("%w: the file is created with slots %v and bits %v, which are different from %v and %v", InconsistentParamErr, h.Slots, h.Bits, param.Slots, param.Bits)
+	}
+	return nil
+}
+
+// Inspect reads the header of a filter file without opening it as a DiskFilter.
+func Inspect(filename string) (Header, error) {
+	header, _, err := inspect(filename)
+	return header, err
+}
+
+// inspect is Inspect, but also returns the size of the metadata.
+func inspect(filename string) (Header, uint16, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return Header{}, 0, err
+	}
+	defer f.Close()
+	raw := retryStorage{f}
+	var b [LenOfMetadataSize]byte
+	if _, err = raw.ReadAt(b[:], 0); err != nil {
+		return Header{}, 0, err
+	}
+	metadataSize := binary.LittleEndian.Uint16(b[:])
+	header, err := readHeader(raw, metadataSize)
+	return header, metadataSize, err
+}
+
+// Header returns the header of the filter file, with the times of the last add and sync up to date.
+func (f *DiskFilter) Header() Header {
+	h := f.header
+	h.LastAdd, h.LastSync = f.lastTimes()
+	return h
+}
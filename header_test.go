@@ -0,0 +1,123 @@
+package disk_bloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo, MetadataSize: 8, Tag: "blocklist"})
+	buf := []byte("testing")
+	bf.ExistOrAdd(buf)
+	h, err := Inspect("testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostname, _ := os.Hostname()
+	if h.Version != HeaderVersion || h.Tag != "blocklist" || h.Hostname != hostname || h.Command == "" || h.LibraryVersion == "" {
+		t.Fatalf("Unexpected header %+v", h)
+	}
+	// the times of the last add and sync are written into the file every second
+	live := bf.Header()
+	live.LastAdd, live.LastSync = h.LastAdd, h.LastSync
+	if h != live {
+		t.Fatalf("Header should be the same as inspected, got %+v", live)
+	}
+}
+
+func TestNew_LegacyFile(t *testing.T) {
+	slots, bits := OptimalParam(1e4, 1e-4)
+	param := FilterParam{Slots: slots, Bits: bits, Hash: doubleFNV}
+	// | len of metadata size(2 bytes) | bloom filter |, with bits of "testing" set
+	legacy := make([]byte, LenOfMetadataSize+bits/8+1)
+	x, y := doubleFNV([]byte("testing"))
+	for i := 0; i < int(slots); i++ {
+		offset := (x + uint64(i)*y) % bits
+		legacy[LenOfMetadataSize+offset/8] |= 1 << (offset % 8)
+	}
+	if err := os.WriteFile("testfile", legacy, 0644); err != nil {
+		t.Fatal(err)
+	}
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo})
+	if bf.Header().Version != 0 {
+		t.Fatalf("Legacy file should have no header, got %+v", bf.Header())
+	}
+	if bf.FilterParam().Bits != param.Bits {
+		t.Fatal("Unexpected param")
+	}
+	if !bf.Exist([]byte("testing")) {
+		t.Fatal("Should exist in legacy filter but got false")
+	}
+	if bf.Exist([]byte("not-exists")) {
+		t.Fatal("Should missing in filter but got true")
+	}
+}
+
+func TestNew_Version1File(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo, MetadataSize: 8})
+	if bf.Header().Version != 2 || bf.bloomStart%pageSize != 0 {
+		t.Fatalf("Bloom filter should be aligned in version 2, got %v", bf.bloomStart)
+	}
+	for i := 0; i < 100; i++ {
+		bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	bf.Close()
+	b, err := os.ReadFile("testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// in version 1, the bloom filter follows the header immediately
+	headerStart := LenOfMetadataSize + 8
+	v1 := append([]byte{}, b[:headerStart+HeaderSize]...)
+	binary.LittleEndian.PutUint16(v1[headerStart+8:], 1)
+	v1 = append(v1, b[bf.bloomStart:]...)
+	if err = os.WriteFile("testfile", v1, 0644); err != nil {
+		t.Fatal(err)
+	}
+	bf = newTestFilter(t, Controller{Fsync: FsyncModeNo, MetadataSize: 8})
+	if bf.Header().Version != 1 || bf.bloomStart != int64(headerStart+HeaderSize) {
+		t.Fatalf("Unexpected version %v and bloom start %v", bf.Header().Version, bf.bloomStart)
+	}
+	for i := 0; i < 100; i++ {
+		if !bf.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should exist in version 1 filter", i)
+		}
+	}
+	if bf.Exist([]byte("not-exists")) {
+		t.Fatal("Should missing in filter but got true")
+	}
+}
+
+func TestHeader_RestoreParam(t *testing.T) {
+	bf, err := Open("testfile", WithHashKind(HashKindXXHash64, nil), WithCapacity(1e4, 1e-4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("testfile")
+	bf.ExistOrAdd([]byte("testing"))
+	param := bf.FilterParam()
+	header := bf.Header()
+	if header.Slots != param.Slots || header.Bits != param.Bits || header.Created.IsZero() {
+		t.Fatalf("unexpected header: %+v", header)
+	}
+	bf.Close()
+
+	bf, err = Open("testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored := bf.FilterParam(); restored.Slots != param.Slots || restored.Bits != param.Bits || restored.HashKind != HashKindXXHash64 {
+		t.Fatalf("unexpected parameters: %+v", restored)
+	}
+	if !bf.Exist([]byte("testing")) {
+		t.Fatal("should exist")
+	}
+	bf.Close()
+
+	if _, err = Open("testfile", WithHashKind(HashKindXXHash64, nil), WithParam(param.Slots, param.Bits*2)); !errors.Is(err, InconsistentParamErr) {
+		t.Fatalf("should fail with InconsistentParamErr, got %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package disk_bloom
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+)
+
+var TamperedErr = fmt.Errorf("header or metadata is tampered")
+
+// The scope of the HMAC recorded in the header. The files signed before it was recorded have the scope 0,
+// whose HMAC covers the metadata and the header as stored, and get the current scope once opened for writing.
+const (
+	// macScopeStable means the HMAC covers the len of metadata size, and skips the fields of the header rewritten
+	// by the adds and the syncs: the bits set, the times and the slots of them, so that a crash between writing them
+	// and the HMAC does not leave the HMAC stale.
+	macScopeStable uint8 = 1 << iota
+	// macScopeNoMetadata means the HMAC skips the metadata, which Control rewrites.
+	macScopeNoMetadata
+)
+
+// headerMAC computes the HMAC-SHA256 of the image of the file before the bloom filter, i.e. the len of metadata size,
+// the metadata and the header, in the scope, with the HMAC field in the header zeroed.
+func headerMAC(key []byte, image []byte, metadataSize uint16, scope uint8) (mac [sha256.Size]byte) {
+	b := append([]byte(nil), image...)
+	h := b[LenOfMetadataSize+int(metadataSize):]
+	zero := func(b []byte) {
+		for i := range b {
+			b[i] = 0
+		}
+	}
+	zero(h[headerMACOffset : headerMACOffset+sha256.Size])
+	if scope&macScopeStable == 0 {
+		b = b[LenOfMetadataSize:]
+	} else {
+		zero(h[headerSetBitsOffset : headerSetBitsOffset+8])
+		zero(h[headerLastAddOffset : headerLastSyncOffset+8])
+		zero(h[headerReservedOffset:])
+	}
+	if scope&macScopeNoMetadata != 0 {
+		zero(b[len(b)-len(h)-int(metadataSize) : len(b)-len(h)])
+	}
+	m := hmac.New(sha256.New, key)
+	m.Write(b)
+	copy(mac[:], m.Sum(nil))
+	return mac
+}
+
+// readImage reads the bytes of the file before the bloom filter.
+func readImage(r storage, metadataSize uint16, headerSize uint32) ([]byte, error) {
+	image := make([]byte, LenOfMetadataSize+int(metadataSize)+int(headerSize))
+	if _, err := r.ReadAt(image, 0); err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+// verifyHeaderMAC returns TamperedErr if the HMAC in the header does not match.
+func verifyHeaderMAC(key []byte, r storage, h Header, metadataSize uint16) error {
+	if !h.Signed() {
+		return fmt.Errorf("%w: the file is not signed", TamperedErr)
+	}
+	image, err := readImage(r, metadataSize, h.Size)
+	if err != nil {
+		return err
+	}
+	mac := headerMAC(key, image, metadataSize, h.macScope)
+	if !hmac.Equal(mac[:], h.mac[:]) {
+		return TamperedErr
+	}
+	return nil
+}
+
+// signLocked updates the HMAC in the header. It does nothing if HMACKey is not given, or the file is sealed.
+func (f *DiskFilter) signLocked() error {
+	if len(f.controller.HMACKey) == 0 || f.header.Sealed() || f.controller.ReadOnly {
+		return nil
+	}
+	return f.writeSignedLocked(func(image []byte) {})
+}
+
+// writeSignedLocked applies patch to the image of the file before the bloom filter, and writes the bytes changed
+// together with the HMAC of the result by a single write, so that a crash does not leave the HMAC stale.
+// It writes only the bytes changed if HMACKey is not given, or the file is sealed.
+func (f *DiskFilter) writeSignedLocked(patch func(image []byte)) error {
+	raw := retryStorage{f.file.f}
+	image, err := readImage(raw, f.controller.MetadataSize, f.header.Size)
+	if err != nil {
+		return err
+	}
+	old := append([]byte(nil), image...)
+	patch(image)
+	scope, mac := f.header.macScope, f.header.mac
+	if len(f.controller.HMACKey) > 0 && !f.header.Sealed() && !f.controller.ReadOnly {
+		h := image[LenOfMetadataSize+int(f.controller.MetadataSize):]
+		scope |= macScopeStable
+		if f.controller.Control != nil || f.controller.ControlStorage != nil {
+			scope |= macScopeNoMetadata
+		}
+		h[headerMACScopeOffset] = scope
+		mac = headerMAC(f.controller.HMACKey, image, f.controller.MetadataSize, scope)
+		copy(h[headerMACOffset:], mac[:])
+	}
+	lo, hi := 0, len(image)
+	for lo < hi && image[lo] == old[lo] {
+		lo++
+	}
+	for hi > lo && image[hi-1] == old[hi-1] {
+		hi--
+	}
+	if lo < hi {
+		if _, err = raw.WriteAt(image[lo:hi], int64(lo)); err != nil {
+			return err
+		}
+	}
+	f.header.macScope, f.header.mac = scope, mac
+	return nil
+}
+
+// writeMetadataLocked writes the metadata, signed together with the header, see writeSignedLocked.
+func (f *DiskFilter) writeMetadataLocked(metadata []byte) error {
+	b := append([]byte(nil), metadata...)
+	if f.header.Encrypted() {
+		headerStart := LenOfMetadataSize + int64(f.controller.MetadataSize)
+		encrypted, err := newEncryptedStorage(nil, f.controller.EncryptionKey, f.header.nonce, headerStart, headerStart+int64(f.header.Size))
+		if err != nil {
+			return err
+		}
+		encrypted.xor(b, LenOfMetadataSize)
+	}
+	return f.writeSignedLocked(func(image []byte) {
+		copy(image[LenOfMetadataSize:], b)
+	})
+}
+
+// signImage updates the HMAC of the file r in its scope, e.g. of a copy.
+func signImage(key []byte, r storage, metadataSize uint16, h Header) error {
+	image, err := readImage(r, metadataSize, h.Size)
+	if err != nil {
+		return err
+	}
+	mac := headerMAC(key, image, metadataSize, h.macScope)
+	_, err = r.WriteAt(mac[:], LenOfMetadataSize+int64(metadataSize)+headerMACOffset)
+	return err
+}
@@ -0,0 +1,159 @@
+package disk_bloom
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestDiskFilter_HMAC(t *testing.T) {
+	controller := Controller{
+		Fsync:        FsyncModeNo,
+		MetadataSize: 8,
+		HMACKey:      []byte("secret"),
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			slots, bits := OptimalParam(1e4, 1e-4)
+			return FilterParam{Slots: slots, Bits: bits, Hash: doubleFNV}, nil
+		},
+	}
+	bf, err := New("testfile", controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		os.Remove("testfile")
+	}()
+	if !bf.Header().Signed() {
+		t.Fatal("Header should be signed")
+	}
+	bf.Close()
+	bf, err = New("testfile", controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bf.Close()
+
+	// tamper with the metadata
+	f, err := os.OpenFile("testfile", os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte{1}, LenOfMetadataSize)
+	f.Close()
+	if _, err = New("testfile", controller); !errors.Is(err, TamperedErr) {
+		t.Fatalf("Should return TamperedErr, got %v", err)
+	}
+
+	os.Remove("testfile")
+	controller.HMACKey = nil
+	bf, err = New("testfile", controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bf.Close()
+	controller.HMACKey = []byte("secret")
+	if _, err = New("testfile", controller); !errors.Is(err, TamperedErr) {
+		t.Fatalf("Should return TamperedErr for an unsigned file, got %v", err)
+	}
+}
+
+func TestDiskFilter_HMACCrash(t *testing.T) {
+	controls := 0
+	controller := Controller{
+		Fsync:        FsyncModeNo,
+		MetadataSize: 8,
+		HMACKey:      []byte("secret"),
+		Control: func(f *os.File, modified bool) {
+			controls++
+			f.WriteAt([]byte{byte(controls)}, LenOfMetadataSize)
+		},
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			slots, bits := OptimalParam(1e4, 1e-4)
+			return FilterParam{Slots: slots, Bits: bits, Hash: doubleFNV}, nil
+		},
+	}
+	bf, err := New("testfile", controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("testfile")
+	bf.ExistOrAdd([]byte("testing"))
+	if err = bf.WriteMetadata([]byte("metadata")); err != nil {
+		t.Fatal(err)
+	}
+	bf.Close()
+	if bf.header.macScope != macScopeStable|macScopeNoMetadata {
+		t.Fatalf("Should skip the metadata rewritten by Control, got scope %v", bf.header.macScope)
+	}
+
+	// crashed after Control and the times are written, but before the HMAC
+	f, err := os.OpenFile("testfile", os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte{0xff}, LenOfMetadataSize)
+	f.WriteAt([]byte{0xff}, LenOfMetadataSize+8+headerLastAddOffset)
+	f.Close()
+	bf, err = New("testfile", controller)
+	if err != nil {
+		t.Fatalf("Should open the file crashed before the HMAC is written, got %v", err)
+	}
+	bf.Close()
+
+	// the other fields are still covered, as well as the len of metadata size
+	f, err = os.OpenFile("testfile", os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte{0xff}, LenOfMetadataSize+8+headerCreatedOffset)
+	f.Close()
+	if _, err = New("testfile", controller); !errors.Is(err, TamperedErr) {
+		t.Fatalf("Should return TamperedErr, got %v", err)
+	}
+	image := make([]byte, LenOfMetadataSize+8+HeaderSize)
+	mac := headerMAC([]byte("secret"), image, 8, macScopeStable)
+	image[1] = 1
+	if headerMAC([]byte("secret"), image, 8, macScopeStable) == mac {
+		t.Fatal("Should cover the len of metadata size")
+	}
+}
+
+func TestDiskFilter_HMACControlMetadataTampered(t *testing.T) {
+	controller := Controller{
+		Fsync:        FsyncModeNo,
+		MetadataSize: 8,
+		HMACKey:      []byte("secret"),
+		Control:      func(f *os.File, modified bool) {},
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			slots, bits := OptimalParam(1e4, 1e-4)
+			return FilterParam{Slots: slots, Bits: bits, Hash: doubleFNV}, []byte("metadata")
+		},
+	}
+	bf, err := New("testfile", controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("testfile")
+	bf.Close()
+
+	// the metadata is not covered by the HMAC of a file signed with Control, even opened without it
+	f, err := os.OpenFile("testfile", os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte("tampered"), LenOfMetadataSize)
+	f.Close()
+	controller.Control = nil
+	controller.GetParam = func(metadata []byte) (FilterParam, []byte) {
+		if string(metadata) != "tampered" {
+			t.Fatalf("Should read the tampered metadata, got %q", metadata)
+		}
+		slots, bits := OptimalParam(1e4, 1e-4)
+		return FilterParam{Slots: slots, Bits: bits, Hash: doubleFNV}, nil
+	}
+	bf, err = New("testfile", controller)
+	if err != nil {
+		t.Fatalf("Should not detect the metadata tampered, got %v", err)
+	}
+	bf.Close()
+}
@@ -0,0 +1,214 @@
+package disk_bloom
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// hybridStorage serves the bloom filter from memory, and the rest from the file, see Controller.Hybrid.
+// The pages written are written to the file by flush.
+type hybridStorage struct {
+	storage
+	// start and end are the file offsets of the bloom filter, which is mem
+	start int64
+	end   int64
+	mem   []byte
+	// mu guards the writes to mem and dirty against flush, which is not always invoked with the file lock
+	mu sync.Mutex
+	// dirty are the pages of mem written since the last flush, by their index
+	dirty map[int64]bool
+	// flushMu serializes the flushes, so that an older copy of a page is never written after a newer one
+	flushMu sync.Mutex
+}
+
+// hybridLocked loads the bloom filter into memory for Controller.Hybrid.
+// It returns false without an error if the MemoryBudget is exhausted, which falls back to the file I/O.
+func (f *DiskFilter) hybridLocked() (bool, error) {
+	size := f.header.bloomSize(f.param.Bits)
+	if !f.controller.MemoryBudget.reserve(size) {
+		return false, nil
+	}
+	h := &hybridStorage{
+		storage: f.file.rw,
+		start:   f.bloomStart,
+		end:     f.bloomStart + size,
+		mem:     make([]byte, size),
+		dirty:   make(map[int64]bool),
+	}
+	if _, err := f.file.rw.ReadAt(h.mem, h.start); err != nil {
+		f.controller.MemoryBudget.release(size)
+		return false, err
+	}
+	f.file.rw = h
+	f.hybrid = h
+	return true, nil
+}
+
+// split splits the range of n bytes at offset into the parts before and in the bloom filter.
+func (s *hybridStorage) split(offset int64, n int) (before, in int) {
+	from, to := offset, offset+int64(n)
+	if from < s.start {
+		before = n
+		if to > s.start {
+			before = int(s.start - from)
+		}
+		from = s.start
+	}
+	if to > s.end {
+		to = s.end
+	}
+	if to > from {
+		in = int(to - from)
+	}
+	return before, in
+}
+
+func (s *hybridStorage) ReadAt(b []byte, offset int64) (n int, err error) {
+	before, in := s.split(offset, len(b))
+	if before > 0 {
+		if n, err = s.storage.ReadAt(b[:before], offset); err != nil {
+			return n, err
+		}
+	}
+	if in > 0 {
+		n += copy(b[n:before+in], s.mem[offset+int64(n)-s.start:])
+	}
+	if n < len(b) {
+		m, err := s.storage.ReadAt(b[n:], offset+int64(n))
+		return n + m, err
+	}
+	return n, nil
+}
+
+func (s *hybridStorage) WriteAt(b []byte, offset int64) (n int, err error) {
+	before, in := s.split(offset, len(b))
+	if before > 0 {
+		if n, err = s.storage.WriteAt(b[:before], offset); err != nil {
+			return n, err
+		}
+	}
+	if in > 0 {
+		from := offset + int64(n) - s.start
+		s.mu.Lock()
+		n += copy(s.mem[from:from+int64(in)], b[n:before+in])
+		for page := from / pageSize; page <= (from+int64(in)-1)/pageSize; page++ {
+			s.dirty[page] = true
+		}
+		s.mu.Unlock()
+	}
+	if n < len(b) {
+		m, err := s.storage.WriteAt(b[n:], offset+int64(n))
+		return n + m, err
+	}
+	return n, nil
+}
+
+// flush writes the pages written since the last flush to the file, a run of adjacent pages at a time.
+// The pages failed to be written are kept dirty, and retried by the next flush.
+func (s *hybridStorage) flush() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+	s.mu.Lock()
+	if len(s.dirty) == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	pages := make([]int64, 0, len(s.dirty))
+	for page := range s.dirty {
+		pages = append(pages, page)
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i] < pages[j] })
+	// copy the pages, so that the adds are not blocked by the writes
+	copies := make([][]byte, len(pages))
+	for i, page := range pages {
+		copies[i] = append([]byte(nil), s.page(page)...)
+	}
+	s.dirty = make(map[int64]bool)
+	s.mu.Unlock()
+	for i := 0; i < len(pages); {
+		j := i + 1
+		run := copies[i]
+		for ; j < len(pages) && pages[j] == pages[j-1]+1; j++ {
+			run = append(run, copies[j]...)
+		}
+		if _, err := s.storage.WriteAt(run, s.start+pages[i]*pageSize); err != nil {
+			s.mu.Lock()
+			for _, page := range pages[i:] {
+				s.dirty[page] = true
+			}
+			s.mu.Unlock()
+			return err
+		}
+		i = j
+	}
+	return nil
+}
+
+// page returns the page of mem, which is shorter at the end.
+func (s *hybridStorage) page(page int64) []byte {
+	from, to := page*pageSize, (page+1)*pageSize
+	if to > int64(len(s.mem)) {
+		to = int64(len(s.mem))
+	}
+	return s.mem[from:to]
+}
+
+// detachHybridLocked writes the pages changed in memory by Controller.Hybrid to the file, and serves the bloom filter
+// from the file afterwards, e.g. before it is moved in the file by shrinking. The flush task stops once it is detached.
+func (f *DiskFilter) detachHybridLocked() error {
+	if f.hybrid == nil {
+		return nil
+	}
+	if err := f.hybrid.flush(); err != nil {
+		return err
+	}
+	f.file.rw = f.hybrid.storage
+	f.controller.MemoryBudget.release(int64(len(f.hybrid.mem)))
+	f.hybrid = nil
+	return nil
+}
+
+// Checkpoint writes the pages of the bloom filter changed in memory by Controller.Hybrid to the file now,
+// and syncs the file unless in FsyncModeNo. It is a no-op without Controller.Hybrid.
+func (f *DiskFilter) Checkpoint() error {
+	if !f.acquire() {
+		return ClosedErr
+	}
+	defer f.release()
+	f.file.mu.Lock()
+	defer f.file.mu.Unlock()
+	if f.hybrid == nil {
+		return nil
+	}
+	if f.file.fsync == FsyncModeNo {
+		return f.hybrid.flush()
+	}
+	// the sync flushes the pages first
+	return f.syncFile()
+}
+
+func (f *DiskFilter) flushHybridEvery(t *task) {
+	ticker := time.NewTicker(f.controller.Hybrid)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done():
+			return
+		case <-ticker.C:
+		}
+		if !f.acquire() {
+			return
+		}
+		f.file.mu.Lock()
+		detached := f.hybrid == nil
+		if !detached {
+			f.backgroundError(t.report(f.hybrid.flush()))
+		}
+		f.file.mu.Unlock()
+		f.release()
+		if detached {
+			return
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package disk_bloom
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDiskFilter_Hybrid(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo, Hybrid: time.Hour})
+	if bf.hybrid == nil || bf.Describe().Backend != "hybrid" {
+		t.Fatalf("Should be in memory, got %v", bf.Describe().Backend)
+	}
+	for i := 0; i < 1000; i++ {
+		bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	for i := 0; i < 1000; i++ {
+		if !bf.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should be in the filter", i)
+		}
+	}
+	bloom := func() []byte {
+		b, err := os.ReadFile("testfile")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b[bf.bloomStart:]
+	}
+	if b := bloom(); !bytes.Equal(b, make([]byte, len(b))) {
+		t.Fatal("Should not write the file before the checkpoint")
+	}
+	if err := bf.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bloom(), bf.hybrid.mem) {
+		t.Fatal("Should write the pages changed by the checkpoint")
+	}
+	bf.ExistOrAdd([]byte("new"))
+	if err := bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	bf = newTestFilter(t, Controller{Fsync: FsyncModeNo})
+	for i := 0; i < 1000; i++ {
+		if !bf.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should be in the filter after reopened", i)
+		}
+	}
+	if !bf.Exist([]byte("new")) {
+		t.Fatal("Should write the pages changed on Close")
+	}
+}
+
+func TestDiskFilter_HybridSync(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeEverySec, Hybrid: time.Hour})
+	bf.ExistOrAdd([]byte("a"))
+	if err := bf.barrier(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile("testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b[bf.bloomStart:], bf.hybrid.mem) {
+		t.Fatal("Should write the pages changed before syncing")
+	}
+}
+
+func TestDiskFilter_HybridFallback(t *testing.T) {
+	for name, controller := range map[string]Controller{
+		"FsyncModeAlways": {Fsync: FsyncModeAlways, Hybrid: time.Hour},
+		"MemoryBudget":    {Fsync: FsyncModeNo, Hybrid: time.Hour, MemoryBudget: NewMemoryBudget(1, nil)},
+	} {
+		bf := newTestFilter(t, controller)
+		if bf.hybrid != nil {
+			t.Fatalf("%v: should fall back to the file I/O", name)
+		}
+		bf.ExistOrAdd([]byte("a"))
+		if !bf.Exist([]byte("a")) {
+			t.Fatalf("%v: should be in the filter", name)
+		}
+		bf.Close()
+		os.Remove("testfile")
+	}
+}
@@ -0,0 +1,138 @@
+package disk_bloom
+
+import (
+	"math"
+	"math/bits"
+	"os"
+	"sync/atomic"
+)
+
+// Intersect creates a filter at filename, which must not exist, of the bits set in both f and other,
+// and opens it with the Controller of f. It contains every entry in both, and possibly more than a filter
+// of the common entries would, since a bit may be set by different entries in either.
+// Both must be classic filters created with the same parameters and hash.
+func (f *DiskFilter) Intersect(other *DiskFilter, filename string) (*DiskFilter, error) {
+	if err := f.compatible(other); err != nil {
+		return nil, err
+	}
+	if err := f.Clone(filename); err != nil {
+		return nil, err
+	}
+	g, err := New(filename, *f.controller)
+	if err != nil {
+		_ = os.Remove(filename)
+		return nil, err
+	}
+	if err = g.intersect(other); err != nil {
+		_ = g.Close()
+		_ = os.Remove(filename)
+		return nil, err
+	}
+	return g, nil
+}
+
+// intersect clears the bits of f not set in other, and counts the bits set again.
+func (f *DiskFilter) intersect(other *DiskFilter) error {
+	err := quiesce([]*DiskFilter{f, other}, func() error {
+		if f.readOnly {
+			return f.readOnlyErr()
+		}
+		size := f.header.bloomSize(f.param.Bits)
+		from := make([]byte, 1<<16)
+		to := make([]byte, len(from))
+		for offset := int64(0); offset < size; offset += int64(len(from)) {
+			n := int64(len(from))
+			if size-offset < n {
+				n = size - offset
+			}
+			if err := other.readBloomLocked(from[:n], offset); err != nil {
+				return err
+			}
+			if _, err := f.file.rw.ReadAt(to[:n], f.fileOffset(offset)); err != nil {
+				return err
+			}
+			var changed []int64
+			for i := int64(0); i < n; i++ {
+				if val := to[i] & from[i]; val != to[i] {
+					to[i] = val
+					changed = append(changed, i)
+				}
+			}
+			if len(changed) == 0 {
+				continue
+			}
+			if _, err := f.file.rw.WriteAt(to[:n], f.fileOffset(offset)); err != nil {
+				return err
+			}
+			for _, i := range changed {
+				if err := f.wroteLocked(to[i], f.fileOffset(offset+i)); err != nil {
+					return err
+				}
+			}
+			f.file.modified = true
+		}
+		atomic.StoreInt32(&f.counted, 0)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return f.countSetBits()
+}
+
+// EstimateJaccard estimates the Jaccard similarity |A∩B|/|A∪B| of the entries of f and other,
+// e.g. to compare the URLs crawled by two runs. The sizes of A, B and their union are estimated from the bits set
+// like EstimateCount, so that it is inaccurate once the union is nearly full, and NaN if it is full.
+// It is 0 if both are empty. Both must be classic filters created with the same parameters and hash.
+func (f *DiskFilter) EstimateJaccard(other *DiskFilter) (float64, error) {
+	if err := f.compatible(other); err != nil {
+		return 0, err
+	}
+	if f == other {
+		return 1, nil
+	}
+	var a, b, union uint64
+	err := quiesce([]*DiskFilter{f, other}, func() error {
+		size := f.header.bloomSize(f.param.Bits)
+		x := make([]byte, 1<<16)
+		y := make([]byte, len(x))
+		for offset := int64(0); offset < size; offset += int64(len(x)) {
+			n := int64(len(x))
+			if size-offset < n {
+				n = size - offset
+			}
+			if err := f.readBloomLocked(x[:n], offset); err != nil {
+				return err
+			}
+			if err := other.readBloomLocked(y[:n], offset); err != nil {
+				return err
+			}
+			for i := int64(0); i < n; i++ {
+				a += uint64(bits.OnesCount8(x[i]))
+				b += uint64(bits.OnesCount8(y[i]))
+				union += uint64(bits.OnesCount8(x[i] | y[i]))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if union == 0 {
+		return 0, nil
+	}
+	m := float64(f.param.Bits)
+	na := estimateCount(float64(a)/m, f.param.Slots, f.param.Bits)
+	nb := estimateCount(float64(b)/m, f.param.Slots, f.param.Bits)
+	nu := estimateCount(float64(union)/m, f.param.Slots, f.param.Bits)
+	if math.IsInf(nu, 1) {
+		return math.NaN(), nil
+	}
+	j := (na + nb - nu) / nu
+	if j < 0 {
+		return 0, nil
+	} else if j > 1 {
+		return 1, nil
+	}
+	return j, nil
+}
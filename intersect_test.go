@@ -0,0 +1,57 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"testing"
+)
+
+func TestDiskFilter_Intersect(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo})
+	other, err := New("testfile2", bf.Controller())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("testfile2")
+	defer other.Close()
+	// 0-999 in bf, and 500-1499 in other
+	for i := 0; i < 1500; i++ {
+		if i < 1000 {
+			bf.ExistOrAdd([]byte(fmt.Sprint(i)))
+		}
+		if i >= 500 {
+			other.ExistOrAdd([]byte(fmt.Sprint(i)))
+		}
+	}
+	j, err := bf.EstimateJaccard(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 500.0 / 1500; math.Abs(j-want) > 0.02 {
+		t.Fatalf("Jaccard similarity should be about %v, got %v", want, j)
+	}
+
+	common, err := bf.Intersect(other, "testfile.common")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("testfile.common")
+	defer common.Close()
+	var found int
+	for i := 0; i < 1500; i++ {
+		if common.Exist([]byte(fmt.Sprint(i))) {
+			if i < 500 || i >= 1000 {
+				found++
+			}
+		} else if i >= 500 && i < 1000 {
+			t.Fatalf("%v should be in the intersection", i)
+		}
+	}
+	if found > 10 {
+		t.Fatalf("the entries in either should rarely be in the intersection, got %v", found)
+	}
+	if count := common.EstimateCount(); math.Abs(count-500) > 25 {
+		t.Fatalf("count of the intersection should be about 500, got %v", count)
+	}
+}
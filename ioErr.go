@@ -0,0 +1,68 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ioOpRead  = "read"
+	ioOpWrite = "write"
+	ioOpSync  = "sync"
+)
+
+// IOError is an error of the file I/O of a filter, see Err.
+type IOError struct {
+	// Op is "read", "write" or "sync"
+	Op   string
+	Time time.Time
+	Err  error
+}
+
+func (e *IOError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Op, e.Err)
+}
+
+func (e *IOError) Unwrap() error {
+	return e.Err
+}
+
+// lastIOError is the last I/O error of a filter, which is a *IOError or nil.
+type lastIOError struct {
+	v atomic.Value
+}
+
+func (l *lastIOError) load() *IOError {
+	e, _ := l.v.Load().(*IOError)
+	return e
+}
+
+// Err returns the last I/O error of the filter as an *IOError, whether it happened in an operation or in the background,
+// e.g. for health checks. It is cleared once an I/O of the same Op succeeds, and is nil if there is none.
+// Exist and ExistOrAdd do not return the errors, see ExistErr and ExistOrAddErr.
+func (f *DiskFilter) Err() error {
+	if e := f.ioErr.load(); e != nil {
+		return e
+	}
+	return nil
+}
+
+// noteIO records the result of an I/O of op for Err.
+func (f *DiskFilter) noteIO(op string, err error) {
+	if err != nil {
+		f.ioErr.v.Store(&IOError{Op: op, Time: time.Now(), Err: err})
+		return
+	}
+	// the load is cheap enough for every I/O, unlike the store
+	if e := f.ioErr.load(); e != nil && e.Op == op {
+		f.ioErr.v.CompareAndSwap(e, (*IOError)(nil))
+	}
+}
+
+// backgroundError reports an error of the goroutines in the background to Controller.OnBackgroundError.
+func (f *DiskFilter) backgroundError(err error) {
+	if err != nil && f.controller.OnBackgroundError != nil {
+		f.controller.OnBackgroundError(err)
+	}
+}
@@ -0,0 +1,118 @@
+package disk_bloom
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDiskFilter_ExistErr(t *testing.T) {
+	injector := NewFaultInjector(1)
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo, FaultInjector: injector})
+	for i := 0; i < 100; i++ {
+		bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	if err := bf.Err(); err != nil {
+		t.Fatalf("Should be healthy, got %v", err)
+	}
+	injector.Set(Faults{ReadErrRate: 1})
+	if _, err := bf.ExistErr([]byte("1")); !errors.Is(err, FaultInjectedErr) {
+		t.Fatalf("Should return the read error, got %v", err)
+	}
+	if _, err := bf.ExistOrAddErr([]byte("new")); !errors.Is(err, FaultInjectedErr) {
+		t.Fatalf("Should not add without reading, got %v", err)
+	}
+	var ioErr *IOError
+	if err := bf.Err(); !errors.As(err, &ioErr) || ioErr.Op != "read" || !errors.Is(err, FaultInjectedErr) {
+		t.Fatalf("Should surface the read error, got %v", err)
+	}
+	if h := bf.Describe().Health; h != HealthIOError {
+		t.Fatalf("Should be %v, got %v", HealthIOError, h)
+	}
+	injector.Set(Faults{})
+	for i := 0; i < 100; i++ {
+		if exist, err := bf.ExistErr([]byte(strconv.Itoa(i))); err != nil || !exist {
+			t.Fatalf("%v should be in the filter, got %v", i, err)
+		}
+	}
+	if exist, _ := bf.ExistErr([]byte("new")); exist {
+		t.Fatal("The add failed to read should not be added")
+	}
+	if err := bf.Err(); err != nil {
+		t.Fatalf("Should be cleared by the reads succeeded, got %v", err)
+	}
+	bf.Close()
+	if _, err := bf.ExistErr([]byte("1")); !errors.Is(err, ClosedErr) {
+		t.Fatalf("Should be ClosedErr, got %v", err)
+	}
+}
+
+func TestDiskFilter_ExistOrAddErrClears(t *testing.T) {
+	injector := NewFaultInjector(1)
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo, FaultInjector: injector})
+	injector.Set(Faults{WriteErrRate: 1})
+	if _, err := bf.ExistOrAddErr([]byte("a")); !errors.Is(err, FaultInjectedErr) {
+		t.Fatalf("Should return the write error, got %v", err)
+	}
+	var ioErr *IOError
+	if err := bf.Err(); !errors.As(err, &ioErr) || ioErr.Op != "write" {
+		t.Fatalf("Should surface the write error, got %v", err)
+	}
+	injector.Set(Faults{})
+	if _, err := bf.ExistOrAddErr([]byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := bf.Err(); err != nil {
+		t.Fatalf("Should be cleared by the write succeeded, got %v", err)
+	}
+}
+
+func TestDiskCountingFilter_ExistErr(t *testing.T) {
+	injector := NewFaultInjector(1)
+	c := newTestCountingFilter(t, Controller{Fsync: FsyncModeNo, FaultInjector: injector})
+	if _, err := c.ExistOrAddErr([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	injector.Set(Faults{ReadErrRate: 1})
+	if _, err := c.ExistErr([]byte("a")); !errors.Is(err, FaultInjectedErr) {
+		t.Fatalf("Should return the read error, got %v", err)
+	}
+	if err := c.Delete([]byte("a")); !errors.Is(err, FaultInjectedErr) {
+		t.Fatalf("Should not delete without reading, got %v", err)
+	}
+	injector.Set(Faults{})
+	if exist, err := c.ExistErr([]byte("a")); err != nil || !exist {
+		t.Fatalf("Should be in the filter, got %v", err)
+	}
+}
+
+func TestController_OnBackgroundError(t *testing.T) {
+	injector := NewFaultInjector(1)
+	errs := make(chan error, 10)
+	bf := newTestFilter(t, Controller{
+		Fsync:         FsyncModeEverySec,
+		FaultInjector: injector,
+		OnBackgroundError: func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		},
+	})
+	injector.Set(Faults{SyncErrRate: 1})
+	bf.ExistOrAdd([]byte("a"))
+	select {
+	case err := <-errs:
+		if !errors.Is(err, FaultInjectedErr) {
+			t.Fatalf("Should report the sync error, got %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Should report the failed background sync")
+	}
+	var ioErr *IOError
+	if err := bf.Err(); !errors.As(err, &ioErr) || ioErr.Op != "sync" {
+		t.Fatalf("Should surface the sync error, got %v", err)
+	}
+	injector.Set(Faults{})
+}
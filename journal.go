@@ -0,0 +1,201 @@
+package disk_bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math/bits"
+	"os"
+	"sort"
+	"sync/atomic"
+)
+
+// The journal of Controller.Journal is a sidecar file next to the filter, appended with records:
+//
+// | CRC32C of the rest(4) | kind(1) | file offset(8) | length(4) | data |
+//
+// A record of the bloom filter is ORed into it on replay, and a record of the metadata overwrites it.
+// Replay stops at the first torn or corrupt record, which was never acknowledged.
+const (
+	journalRecordHeaderSize = 17
+
+	journalBloom    = 0
+	journalMetadata = 1
+)
+
+// JournalFilename returns the filename of the journal of the filter file filename.
+func JournalFilename(filename string) string {
+	return filename + ".wal"
+}
+
+type journal struct {
+	f          *os.File
+	durability DurabilityLevel
+	// size is the bytes of the records appended since the last checkpoint
+	size int64
+}
+
+func appendJournalRecord(b []byte, kind byte, offset int64, data []byte) []byte {
+	start := len(b)
+	b = append(b, make([]byte, journalRecordHeaderSize)...)
+	b[start+4] = kind
+	binary.LittleEndian.PutUint64(b[start+5:], uint64(offset))
+	binary.LittleEndian.PutUint32(b[start+13:], uint32(len(data)))
+	b = append(b, data...)
+	binary.LittleEndian.PutUint32(b[start:], crc32.Checksum(b[start+4:], castagnoli))
+	return b
+}
+
+// append appends the records and syncs them, so that they survive a crash once it returns.
+func (j *journal) append(records []byte) error {
+	if _, err := (retryStorage{j.f}).WriteAt(records, j.size); err != nil {
+		return err
+	}
+	if err := syncDurable(j.f, j.durability); err != nil {
+		return err
+	}
+	j.size += int64(len(records))
+	return nil
+}
+
+// reset empties the journal once the filter file is synced.
+func (j *journal) reset() error {
+	if j.size == 0 {
+		return nil
+	}
+	if err := j.f.Truncate(0); err != nil {
+		return err
+	}
+	if err := syncDurable(j.f, j.durability); err != nil {
+		return err
+	}
+	j.size = 0
+	return nil
+}
+
+// journalBytesLocked journals the bytes of the bloom filter about to be written.
+func (f *DiskFilter) journalBytesLocked(changed map[int64]byte) error {
+	if f.journal == nil {
+		return nil
+	}
+	positions := make([]int64, 0, len(changed))
+	for pos := range changed {
+		positions = append(positions, pos)
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i] < positions[j]
+	})
+	records := make([]byte, 0, len(positions)*(journalRecordHeaderSize+1))
+	for _, pos := range positions {
+		records = appendJournalRecord(records, journalBloom, pos, []byte{changed[pos]})
+	}
+	return f.journal.append(records)
+}
+
+// journalMetadataLocked journals the metadata about to be written.
+func (f *DiskFilter) journalMetadataLocked(metadata []byte) error {
+	if f.journal == nil {
+		return nil
+	}
+	return f.journal.append(appendJournalRecord(nil, journalMetadata, LenOfMetadataSize, metadata))
+}
+
+// checkpointLocked syncs the filter file, after which the records journaled are not needed.
+func (f *DiskFilter) checkpointLocked() error {
+	if f.journal == nil || f.journal.size == 0 {
+		return nil
+	}
+	if err := f.syncFile(); err != nil {
+		return err
+	}
+	return f.journal.reset()
+}
+
+// openJournalLocked opens the journal of the filter file, and replays the records left by a crash.
+func (f *DiskFilter) openJournalLocked(filename string) error {
+	if f.header.variant() != variantClassic || f.header.Encrypted() || f.writeBuffer {
+		return fmt.Errorf("journal of %v, encrypted or write-buffered filters is not supported", f.header.variant())
+	}
+	file, err := os.OpenFile(JournalFilename(filename), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	f.journal = &journal{f: file, durability: f.controller.Durability}
+	if err = f.replayJournalLocked(); err != nil {
+		_ = file.Close()
+		f.journal = nil
+		return err
+	}
+	return nil
+}
+
+// replayJournalLocked applies the records of the journal, syncs the filter file and empties the journal.
+func (f *DiskFilter) replayJournalLocked() error {
+	b, err := io.ReadAll(f.journal.f)
+	if err != nil {
+		return err
+	}
+	f.journal.size = int64(len(b))
+	bloomEnd := f.bloomStart + f.header.bloomSize(f.param.Bits)
+	replayed := 0
+	for len(b) >= journalRecordHeaderSize {
+		n := int(binary.LittleEndian.Uint32(b[13:]))
+		if len(b) < journalRecordHeaderSize+n || binary.LittleEndian.Uint32(b) != crc32.Checksum(b[4:journalRecordHeaderSize+n], castagnoli) {
+			// torn by the crash
+			break
+		}
+		kind, offset, data := b[4], int64(binary.LittleEndian.Uint64(b[5:])), b[journalRecordHeaderSize:journalRecordHeaderSize+n]
+		switch {
+		case kind == journalBloom && n == 1 && offset >= f.bloomStart && offset < bloomEnd:
+			var old [1]byte
+			if _, err = f.file.rw.ReadAt(old[:], offset); err != nil {
+				return err
+			}
+			if val := old[0] | data[0]; val != old[0] {
+				if err = f.writeByteLocked(val, offset); err != nil {
+					return err
+				}
+				atomic.AddUint64(&f.setBits, uint64(bits.OnesCount8(val)-bits.OnesCount8(old[0])))
+			}
+		case kind == journalMetadata && offset == LenOfMetadataSize && n == int(f.controller.MetadataSize):
+			if err = f.writeMetadataLocked(data); err != nil {
+				return err
+			}
+			f.file.metadataModified = true
+		default:
+			return fmt.Errorf("invalid journal record of kind %v at %v of %v bytes", kind, offset, n)
+		}
+		b = b[journalRecordHeaderSize+n:]
+		replayed++
+	}
+	if replayed > 0 {
+		f.file.modified = true
+		if err = f.persistHeaderLocked(); err != nil {
+			return err
+		}
+		if err = f.signLocked(); err != nil {
+			return err
+		}
+		if err = f.updateChecksumsLocked(); err != nil {
+			return err
+		}
+	}
+	// the records replayed, or a torn tail, are not needed once the filter file is synced
+	return f.checkpointLocked()
+}
+
+// closeJournalLocked closes the journal, and removes it if the filter file is synced.
+func (f *DiskFilter) closeJournalLocked(synced bool) error {
+	if f.journal == nil {
+		return nil
+	}
+	err := f.journal.f.Close()
+	if synced {
+		if e := os.Remove(f.journal.f.Name()); err == nil {
+			err = e
+		}
+	}
+	f.journal = nil
+	return err
+}
@@ -0,0 +1,83 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestDiskFilter_Journal(t *testing.T) {
+	controller := Controller{
+		Fsync:        FsyncModeNo,
+		Journal:      true,
+		MetadataSize: 8,
+		GetParam: func([]byte) (FilterParam, []byte) {
+			slots, bits := OptimalParam(1e4, 1e-4)
+			return FilterParam{Slots: slots, Bits: bits, Hash: doubleFNV}, nil
+		},
+	}
+	defer os.Remove("testfile")
+	defer os.Remove("testfile2")
+	bf, err := New("testfile", controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the file as it was on disk before the adds, which a crash may leave
+	base, err := os.ReadFile("testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		bf.ExistOrAdd([]byte(fmt.Sprint(i)))
+	}
+	if err = bf.WriteMetadata([]byte("epoch001")); err != nil {
+		t.Fatal(err)
+	}
+	wal, err := os.ReadFile(JournalFilename("testfile"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(JournalFilename("testfile")); !os.IsNotExist(err) {
+		t.Fatalf("Should remove the journal on Close, got %v", err)
+	}
+
+	// crash: the adds are only in the journal, followed by a torn record
+	if err = os.WriteFile("testfile2", base, 0644); err != nil {
+		t.Fatal(err)
+	}
+	torn := appendJournalRecord(nil, journalBloom, bf.bloomStart, []byte{0xff})
+	if err = os.WriteFile(JournalFilename("testfile2"), append(wal, torn[:len(torn)-1]...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(JournalFilename("testfile2"))
+	recovered, err := New("testfile2", controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recovered.Close()
+	for i := 0; i < 100; i++ {
+		if !recovered.Exist([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%v should be replayed", i)
+		}
+	}
+	metadata := make([]byte, 8)
+	if _, err = recovered.file.rw.ReadAt(metadata, LenOfMetadataSize); err != nil {
+		t.Fatal(err)
+	}
+	if string(metadata) != "epoch001" {
+		t.Fatalf("Should replay the metadata, got %q", metadata)
+	}
+	if info, err := os.Stat(JournalFilename("testfile2")); err != nil || info.Size() != 0 {
+		t.Fatalf("Should empty the journal once replayed, got %v", err)
+	}
+	var b [1]byte
+	if _, err = recovered.file.rw.ReadAt(b[:], recovered.bloomStart); err != nil || b[0] == 0xff {
+		t.Fatal("Should not replay the torn record")
+	}
+	if count := recovered.EstimateCount(); count < 90 || count > 110 {
+		t.Fatalf("count should be about 100, got %v", count)
+	}
+}
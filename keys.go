@@ -0,0 +1,100 @@
+package disk_bloom
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+// Encoded entries of common key types. The encodings are stable across versions.
+//
+// netip.Addr:     4 bytes for IPv4 and 16 bytes for IPv6, the zone is ignored
+// netip.AddrPort: the encoded netip.Addr followed by the port in big-endian (2 bytes)
+// UUID:           16 bytes
+// uint64:         8 bytes in big-endian, see EncodeUint64
+
+// encodeAddr encodes addr into buf without allocation.
+func encodeAddr(buf *[18]byte, addr netip.Addr) []byte {
+	if addr.Is4() {
+		a := addr.As4()
+		copy(buf[:], a[:])
+		return buf[:4]
+	}
+	a := addr.As16()
+	copy(buf[:], a[:])
+	return buf[:16]
+}
+
+// encodeAddrPort encodes addrPort into buf without allocation.
+func encodeAddrPort(buf *[18]byte, addrPort netip.AddrPort) []byte {
+	b := encodeAddr(buf, addrPort.Addr())
+	binary.BigEndian.PutUint16(buf[len(b):], addrPort.Port())
+	return buf[:len(b)+2]
+}
+
+// ExistAddr returns if an IP address is in the filter
+func (f *DiskFilter) ExistAddr(addr netip.Addr) bool {
+	var buf [18]byte
+	return f.Exist(encodeAddr(&buf, addr))
+}
+
+// ExistOrAddAddr is like ExistOrAdd, but takes an IP address.
+func (f *DiskFilter) ExistOrAddAddr(addr netip.Addr) bool {
+	var buf [18]byte
+	return f.ExistOrAdd(encodeAddr(&buf, addr))
+}
+
+// ExistAddrPort returns if an IP address and port pair is in the filter
+func (f *DiskFilter) ExistAddrPort(addrPort netip.AddrPort) bool {
+	var buf [18]byte
+	return f.Exist(encodeAddrPort(&buf, addrPort))
+}
+
+// ExistOrAddAddrPort is like ExistOrAdd, but takes an IP address and port pair.
+func (f *DiskFilter) ExistOrAddAddrPort(addrPort netip.AddrPort) bool {
+	var buf [18]byte
+	return f.ExistOrAdd(encodeAddrPort(&buf, addrPort))
+}
+
+// ExistUUID returns if a UUID is in the filter
+func (f *DiskFilter) ExistUUID(uuid [16]byte) bool {
+	return f.Exist(uuid[:])
+}
+
+// ExistOrAddUUID is like ExistOrAdd, but takes a UUID.
+func (f *DiskFilter) ExistOrAddUUID(uuid [16]byte) bool {
+	return f.ExistOrAdd(uuid[:])
+}
+
+// ExistAddr returns if an IP address is in the filterGroup
+func (g *FilterGroup) ExistAddr(addr netip.Addr) bool {
+	var buf [18]byte
+	return g.Exist(encodeAddr(&buf, addr))
+}
+
+// ExistOrAddAddr is like ExistOrAdd, but takes an IP address.
+func (g *FilterGroup) ExistOrAddAddr(addr netip.Addr) bool {
+	var buf [18]byte
+	return g.ExistOrAdd(encodeAddr(&buf, addr))
+}
+
+// ExistAddrPort returns if an IP address and port pair is in the filterGroup
+func (g *FilterGroup) ExistAddrPort(addrPort netip.AddrPort) bool {
+	var buf [18]byte
+	return g.Exist(encodeAddrPort(&buf, addrPort))
+}
+
+// ExistOrAddAddrPort is like ExistOrAdd, but takes an IP address and port pair.
+func (g *FilterGroup) ExistOrAddAddrPort(addrPort netip.AddrPort) bool {
+	var buf [18]byte
+	return g.ExistOrAdd(encodeAddrPort(&buf, addrPort))
+}
+
+// ExistUUID returns if a UUID is in the filterGroup
+func (g *FilterGroup) ExistUUID(uuid [16]byte) bool {
+	return g.Exist(uuid[:])
+}
+
+// ExistOrAddUUID is like ExistOrAdd, but takes a UUID.
+func (g *FilterGroup) ExistOrAddUUID(uuid [16]byte) bool {
+	return g.ExistOrAdd(uuid[:])
+}
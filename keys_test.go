@@ -0,0 +1,44 @@
+package disk_bloom
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestEncodeAddr(t *testing.T) {
+	var buf [18]byte
+	if b := encodeAddr(&buf, netip.MustParseAddr("1.2.3.4")); len(b) != 4 {
+		t.Fatalf("IPv4 should be encoded in 4 bytes, got %v", len(b))
+	}
+	if b := encodeAddr(&buf, netip.MustParseAddr("::ffff:1.2.3.4")); len(b) != 16 {
+		t.Fatalf("IPv6 should be encoded in 16 bytes, got %v", len(b))
+	}
+	if b := encodeAddrPort(&buf, netip.MustParseAddrPort("1.2.3.4:443")); string(b) != "\x01\x02\x03\x04\x01\xbb" {
+		t.Fatalf("Unexpected encoding %x", b)
+	}
+	if n := testing.AllocsPerRun(100, func() {
+		encodeAddrPort(&buf, netip.MustParseAddrPort("[::1]:443"))
+	}); n != 0 {
+		t.Fatalf("Encoding should not allocate, got %v allocs", n)
+	}
+}
+
+func TestDiskFilter_ExistAddr(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo})
+	addr := netip.MustParseAddr("1.2.3.4")
+	bf.ExistOrAddAddr(addr)
+	if !bf.ExistAddr(addr) {
+		t.Fatal("Should exist in filter but got false")
+	}
+	if bf.ExistAddrPort(netip.AddrPortFrom(addr, 80)) {
+		t.Fatal("Should missing in filter but got true")
+	}
+	if bf.ExistAddr(netip.MustParseAddr("::ffff:1.2.3.4")) {
+		t.Fatal("Should missing in filter but got true")
+	}
+	uuid := [16]byte{1, 2, 3}
+	bf.ExistOrAddUUID(uuid)
+	if !bf.ExistUUID(uuid) {
+		t.Fatal("Should exist in filter but got false")
+	}
+}
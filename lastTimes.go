@@ -0,0 +1,29 @@
+package disk_bloom
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// now returns the time of Controller.Clock.
+func (f *DiskFilter) now() time.Time {
+	if f.controller.Clock != nil {
+		return f.controller.Clock.Now()
+	}
+	return time.Now()
+}
+
+// touchAddLocked records that an add changed the filter, for Header.LastAdd.
+func (f *DiskFilter) touchAddLocked() {
+	atomic.StoreInt64(&f.lastAdd, f.now().UnixNano())
+}
+
+// touchSync records that the file is synced, for Header.LastSync.
+func (f *DiskFilter) touchSync() {
+	atomic.StoreInt64(&f.lastSync, f.now().UnixNano())
+}
+
+// lastTimes returns the times of the last add and sync, which are zero if none is recorded.
+func (f *DiskFilter) lastTimes() (lastAdd, lastSync time.Time) {
+	return nanosTime(atomic.LoadInt64(&f.lastAdd)), nanosTime(atomic.LoadInt64(&f.lastSync))
+}
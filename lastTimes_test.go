@@ -0,0 +1,46 @@
+package disk_bloom
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiskFilter_LastTimes(t *testing.T) {
+	clock := NewManualClock(time.Unix(1700000000, 0))
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo, Clock: clock})
+	if h := bf.Header(); !h.LastAdd.IsZero() || !h.LastSync.IsZero() {
+		t.Fatalf("Should record no add or sync, got %v and %v", h.LastAdd, h.LastSync)
+	}
+	clock.Advance(time.Hour)
+	added := clock.Now()
+	bf.ExistOrAdd([]byte("a"))
+	clock.Advance(time.Hour)
+	// an entry already in changes nothing
+	bf.ExistOrAdd([]byte("a"))
+	if err := bf.barrier(); err != nil {
+		t.Fatal(err)
+	}
+	synced := clock.Now()
+	if h := bf.Header(); !h.LastAdd.Equal(added) || !h.LastSync.Equal(synced) {
+		t.Fatalf("Should record the add at %v and the sync at %v, got %v and %v", added, synced, h.LastAdd, h.LastSync)
+	}
+	if d := bf.Describe(); !d.LastAdd.Equal(added) || !d.LastSync.Equal(synced) || d.Created.IsZero() {
+		t.Fatalf("Should describe the times, got %+v", d)
+	}
+	clock.Advance(time.Hour)
+	if err := bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	h, err := Inspect("testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the header is written before the last sync
+	if !h.LastAdd.Equal(added) || !h.LastSync.Equal(synced) {
+		t.Fatalf("Should persist the add at %v and the sync at %v, got %v and %v", added, synced, h.LastAdd, h.LastSync)
+	}
+	bf = newTestFilter(t, Controller{Fsync: FsyncModeNo, Clock: clock})
+	if h := bf.Header(); !h.LastAdd.Equal(added) || !h.LastSync.Equal(synced) {
+		t.Fatalf("Should restore the add at %v and the sync at %v, got %v and %v", added, synced, h.LastAdd, h.LastSync)
+	}
+}
@@ -0,0 +1,117 @@
+package disk_bloom
+
+import (
+	"bufio"
+	"io"
+	"os"
+)
+
+// loadBatch is the number of keys added at a time by LoadFromReader.
+const loadBatch = 4096
+
+// loadBufferSize is the size of the buffers which the keys of a batch are copied into.
+const loadBufferSize = 64 << 10
+
+// LoadStats are the progress of LoadFromReader and LoadFromFile.
+type LoadStats struct {
+	// Keys is the number of keys loaded, and Added is the number of them which were not in the filter
+	Keys  uint64
+	Added uint64
+	// Bytes is the number of bytes read, and Total is the size of the file of LoadFromFile, or 0 if unknown
+	Bytes int64
+	Total int64
+}
+
+// LoadFromReader adds the keys read from r, which are split by split, or are the lines of r if split is nil,
+// in batches of 4096 keys by ExistOrAddBatch, which is much faster than ExistOrAdd per key. The empty keys are skipped,
+// and a key longer than bufio.MaxScanTokenSize fails with bufio.ErrTooLong.
+// onProgress is invoked after every batch if it is not nil. It returns the stats of the keys added before an error.
+func (f *DiskFilter) LoadFromReader(r io.Reader, split bufio.SplitFunc, onProgress func(stats LoadStats)) (LoadStats, error) {
+	return f.load(r, split, LoadStats{}, onProgress)
+}
+
+// LoadFromFile adds the lines of the file at path like LoadFromReader.
+func (f *DiskFilter) LoadFromFile(path string, onProgress func(stats LoadStats)) (LoadStats, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return LoadStats{}, err
+	}
+	defer file.Close()
+	var stats LoadStats
+	if info, err := file.Stat(); err == nil {
+		stats.Total = info.Size()
+	}
+	return f.load(file, nil, stats, onProgress)
+}
+
+func (f *DiskFilter) load(r io.Reader, split bufio.SplitFunc, stats LoadStats, onProgress func(stats LoadStats)) (LoadStats, error) {
+	counted := &countingReader{r: r}
+	scanner := bufio.NewScanner(counted)
+	if split != nil {
+		scanner.Split(split)
+	}
+	keys := make([][]byte, 0, loadBatch)
+	// the keys of a batch are copied into buf, since the scanner reuses its buffer
+	var buf []byte
+	flush := func() error {
+		exist, err := f.ExistOrAddBatchErr(keys)
+		if err != nil {
+			return err
+		}
+		for _, e := range exist {
+			if !e {
+				stats.Added++
+			}
+		}
+		stats.Keys += uint64(len(keys))
+		stats.Bytes = counted.n
+		keys, buf = keys[:0], buf[:0]
+		if onProgress != nil {
+			onProgress(stats)
+		}
+		return nil
+	}
+	for scanner.Scan() {
+		token := scanner.Bytes()
+		if len(token) == 0 {
+			continue
+		}
+		if len(buf)+len(token) > cap(buf) {
+			// the keys before refer to the old buffer, which is kept by them
+			size := loadBufferSize
+			if len(token) > size {
+				size = len(token)
+			}
+			buf = make([]byte, 0, size)
+		}
+		buf = append(buf, token...)
+		keys = append(keys, buf[len(buf)-len(token):len(buf):len(buf)])
+		if len(keys) == loadBatch {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return stats, err
+	}
+	if len(keys) > 0 {
+		if err := flush(); err != nil {
+			return stats, err
+		}
+	}
+	stats.Bytes = counted.n
+	return stats, nil
+}
+
+// countingReader counts the bytes read.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,60 @@
+package disk_bloom
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDiskFilter_LoadFromReader(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo})
+	var lines strings.Builder
+	for i := 0; i < 5000; i++ {
+		lines.WriteString(strconv.Itoa(i))
+		lines.WriteString("\n\n")
+	}
+	var progress []LoadStats
+	stats, err := bf.LoadFromReader(strings.NewReader(lines.String()), nil, func(stats LoadStats) {
+		progress = append(progress, stats)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Keys != 5000 || stats.Added < 4990 || stats.Bytes != int64(lines.Len()) {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+	if len(progress) != 2 || progress[0].Keys != loadBatch || progress[1] != stats {
+		t.Fatalf("Unexpected progress %+v", progress)
+	}
+	for i := 0; i < 5000; i++ {
+		if !bf.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should exist in filter", i)
+		}
+	}
+	if stats, err = bf.LoadFromReader(strings.NewReader("a b 0"), bufio.ScanWords, nil); err != nil || stats.Keys != 3 || stats.Added != 2 {
+		t.Fatalf("Unexpected stats %+v, %v", stats, err)
+	}
+	if !bf.Exist([]byte("a")) || !bf.Exist([]byte("b")) {
+		t.Fatal("Should load the words")
+	}
+}
+
+func TestDiskFilter_LoadFromFile(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo})
+	if err := os.WriteFile("testfile.keys", []byte("x\ny\nz"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("testfile.keys")
+	stats, err := bf.LoadFromFile("testfile.keys", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (LoadStats{Keys: 3, Added: 3, Bytes: 5, Total: 5}) || !bf.Exist([]byte("z")) {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+	if _, err = bf.LoadFromFile("not-exists", nil); !os.IsNotExist(err) {
+		t.Fatalf("Should fail on a missing file, got %v", err)
+	}
+}
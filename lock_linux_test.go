@@ -0,0 +1,62 @@
+package disk_bloom
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDiskFilter_FileLock(t *testing.T) {
+	writer := newTestFilter(t, Controller{FileLock: true})
+	writer.ExistOrAdd([]byte("testing"))
+	getParam := writer.controller.GetParam
+	if _, err := New("testfile", Controller{FileLock: true, GetParam: getParam}); !errors.Is(err, LockedErr) {
+		t.Fatalf("Should not open another writer, got %v", err)
+	}
+	if _, err := New("testfile", Controller{FileLock: true, ReadOnly: true, GetParam: getParam}); !errors.Is(err, LockedErr) {
+		t.Fatalf("Should not open a reader while writing, got %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// shared by the readers
+	readers := make([]*DiskFilter, 2)
+	for i := range readers {
+		r, err := New("testfile", Controller{FileLock: true, ReadOnly: true, GetParam: getParam})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		if !r.Exist([]byte("testing")) {
+			t.Fatal("testing should exist in filter")
+		}
+		readers[i] = r
+	}
+	if _, err := New("testfile", Controller{FileLock: true, GetParam: getParam}); !errors.Is(err, LockedErr) {
+		t.Fatalf("Should not open a writer while reading, got %v", err)
+	}
+	for _, r := range readers {
+		r.Close()
+	}
+	writer, err := New("testfile", Controller{FileLock: true, GetParam: getParam})
+	if err != nil {
+		t.Fatalf("Should release the lock on Close, got %v", err)
+	}
+	writer.Close()
+}
+
+func TestDiskFilter_FileLockReleasedOnError(t *testing.T) {
+	bf := newTestFilter(t, Controller{})
+	getParam := bf.controller.GetParam
+	if err := bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New("testfile", Controller{FileLock: true, MetadataSize: 8, GetParam: getParam}); !errors.Is(err, InconsistentMetadataSizeErr) {
+		t.Fatalf("Should fail with another metadata size, got %v", err)
+	}
+	bf, err := New("testfile", Controller{FileLock: true, GetParam: getParam})
+	if err != nil {
+		t.Fatalf("Should release the lock of the failed open, got %v", err)
+	}
+	bf.Close()
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package disk_bloom
+
+import "os"
+
+// lockFile is only supported on Linux.
+func lockFile(f *os.File, exclusive bool) error {
+	return UnsupportedErr
+}
@@ -0,0 +1,58 @@
+package disk_bloom
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strconv"
+)
+
+// availableMemory returns the bytes of memory available to the process, bounded by the limit of its cgroup if any,
+// or -1 if unknown.
+func availableMemory() int64 {
+	available := int64(-1)
+	if f, err := os.Open("/proc/meminfo"); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if fields := bytes.Fields(scanner.Bytes()); len(fields) >= 2 && string(fields[0]) == "MemAvailable:" {
+				if kb, err := strconv.ParseInt(string(fields[1]), 10, 64); err == nil {
+					available = kb << 10
+				}
+				break
+			}
+		}
+		_ = f.Close()
+	}
+	// cgroup v2, then v1
+	for _, files := range [][2]string{
+		{"/sys/fs/cgroup/memory.max", "/sys/fs/cgroup/memory.current"},
+		{"/sys/fs/cgroup/memory/memory.limit_in_bytes", "/sys/fs/cgroup/memory/memory.usage_in_bytes"},
+	} {
+		limit, ok := readInt(files[0])
+		if !ok {
+			continue
+		}
+		usage, ok := readInt(files[1])
+		if !ok {
+			continue
+		}
+		if headroom := limit - usage; available < 0 || headroom < available {
+			if headroom < 0 {
+				headroom = 0
+			}
+			available = headroom
+		}
+		break
+	}
+	return available
+}
+
+// readInt reads an integer file of cgroup. "max", i.e. unlimited, is not an integer.
+func readInt(filename string) (int64, bool) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(string(bytes.TrimSpace(b)), 10, 64)
+	return n, err == nil
+}
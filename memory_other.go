@@ -0,0 +1,8 @@
+//go:build !linux
+
+package disk_bloom
+
+// availableMemory returns -1, since the available memory is only known on Linux.
+func availableMemory() int64 {
+	return -1
+}
@@ -0,0 +1,148 @@
+package disk_bloom
+
+import (
+	"bytes"
+	"fmt"
+	"math/bits"
+	"os"
+	"sync/atomic"
+)
+
+// Merge ORs the bloom filter of src into f, so that f contains the entries of both.
+// Both must be classic filters created with the same parameters and hash.
+func (f *DiskFilter) Merge(src *DiskFilter) error {
+	if f == src {
+		return nil
+	}
+	if err := f.compatible(src); err != nil {
+		return err
+	}
+	return quiesce([]*DiskFilter{f, src}, func() error {
+		if f.readOnly {
+			return f.readOnlyErr()
+		}
+		size := f.header.bloomSize(f.param.Bits)
+		from := make([]byte, 1<<16)
+		to := make([]byte, len(from))
+		for offset := int64(0); offset < size; offset += int64(len(from)) {
+			n := int64(len(from))
+			if size-offset < n {
+				n = size - offset
+			}
+			if err := src.readBloomLocked(from[:n], offset); err != nil {
+				return err
+			}
+			if _, err := f.file.rw.ReadAt(to[:n], f.fileOffset(offset)); err != nil {
+				return err
+			}
+			var set uint64
+			var changed []int64
+			for i := int64(0); i < n; i++ {
+				// the bits buffered in memory are already counted
+				cur := to[i] | f.pending[f.fileOffset(offset+i)]
+				val := cur | from[i]
+				if val != to[i] {
+					set += uint64(bits.OnesCount8(val) - bits.OnesCount8(cur))
+					to[i] = val
+					changed = append(changed, i)
+				}
+			}
+			if len(changed) == 0 {
+				continue
+			}
+			if _, err := f.file.rw.WriteAt(to[:n], f.fileOffset(offset)); err != nil {
+				return err
+			}
+			for _, i := range changed {
+				if err := f.wroteLocked(to[i], f.fileOffset(offset+i)); err != nil {
+					return err
+				}
+			}
+			atomic.AddUint64(&f.setBits, set)
+			f.file.modified = true
+		}
+		if f.file.fsync == FsyncModeAlways {
+			return f.syncFile()
+		}
+		return nil
+	})
+}
+
+// compatible returns InconsistentParamErr unless f and other are classic filters created with the same parameters and hash,
+// whose bits are comparable.
+func (f *DiskFilter) compatible(other *DiskFilter) error {
+	if f.header.variant() != variantClassic || other.header.variant() != variantClassic {
+		return fmt.Errorf("%w: only classic filters are comparable", InconsistentParamErr)
+	}
+	if f.param.Slots != other.param.Slots || f.param.Bits != other.param.Bits ||
+		f.header.HashKind != other.header.HashKind || !bytes.Equal(f.param.HashKey, other.param.HashKey) ||
+		f.header.AdaptiveSlots != other.header.AdaptiveSlots || f.header.FastRange() != other.header.FastRange() ||
+		f.header.Hardened() != other.header.Hardened() || f.header.Salted() != other.header.Salted() || f.header.Salt != other.header.Salt {
+		return fmt.Errorf("%w: %v is not created like %v", InconsistentParamErr, other.file.f.Name(), f.file.f.Name())
+	}
+	return nil
+}
+
+// readBloomLocked reads the bytes of the bloom filter at offset into b, including the bits buffered in memory.
+func (f *DiskFilter) readBloomLocked(b []byte, offset int64) error {
+	if _, err := f.file.rw.ReadAt(b, f.fileOffset(offset)); err != nil {
+		return err
+	}
+	if len(f.pending) > 0 {
+		for i := range b {
+			b[i] |= f.pending[f.fileOffset(offset+int64(i))]
+		}
+	}
+	return nil
+}
+
+// MergeFiles merges the filter files srcs into the filter file dst like Merge, streaming block by block,
+// e.g. to combine the filters built on the shards into a global one. The files must not be open,
+// and their parameters and hashes are restored from their headers. Encrypted and signed files are not supported
+// since their keys are unknown here, and the files hashed by HashKindSipHash are assumed to share the key.
+func MergeFiles(dst string, srcs ...string) error {
+	d, err := openToMerge(dst, false)
+	if err != nil {
+		return err
+	}
+	for _, src := range srcs {
+		s, err := openToMerge(src, true)
+		if err != nil {
+			_ = d.Close()
+			return err
+		}
+		err = d.Merge(s)
+		_ = s.Close()
+		if err != nil {
+			_ = d.Close()
+			return err
+		}
+	}
+	return d.Close()
+}
+
+// openToMerge opens an existing filter file to merge, whose entries are never hashed. The sources are opened read-only.
+func openToMerge(filename string, readOnly bool) (*DiskFilter, error) {
+	header, metadataSize, err := inspect(filename)
+	if err != nil {
+		return nil, err
+	}
+	if header.Encrypted() || header.Signed() {
+		return nil, fmt.Errorf("cannot merge %v, which is encrypted or signed", filename)
+	}
+	if header.Bits == 0 {
+		return nil, fmt.Errorf("%w: the parameters are not recorded in %v", MissingParamErr, filename)
+	}
+	controller := Controller{
+		MetadataSize: metadataSize,
+		NoHash:       true,
+		ReadOnly:     readOnly,
+	}
+	if !readOnly {
+		_, err = os.Stat(ChecksumFilename(filename))
+		// synced on Close, and keep the checksums up to date if any
+		controller.Fsync = FsyncModeNo
+		controller.Checksums = err == nil
+	}
+	return New(filename, controller)
+}
@@ -0,0 +1,131 @@
+package disk_bloom
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestDiskFilter_Merge(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo})
+	src, err := New("testfile2", bf.Controller())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("testfile2")
+	defer src.Close()
+	for i := 0; i < 100; i++ {
+		bf.ExistOrAdd([]byte(fmt.Sprint("a", i)))
+		src.ExistOrAdd([]byte(fmt.Sprint("b", i)))
+	}
+	ratio := bf.FillRatio()
+	if err = bf.Merge(src); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if !bf.Exist([]byte(fmt.Sprint("a", i))) || !bf.Exist([]byte(fmt.Sprint("b", i))) {
+			t.Fatal("Should contain the entries of both filters")
+		}
+	}
+	if bf.FillRatio() <= ratio {
+		t.Fatal("fill ratio should grow after merging")
+	}
+
+	other, err := Open("testfile3", WithHashKind(HashKindXXHash64, nil), WithParam(src.param.Slots, src.param.Bits))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("testfile3")
+	defer other.Close()
+	if err = bf.Merge(other); !errors.Is(err, InconsistentParamErr) {
+		t.Fatalf("Should fail to merge a filter of another hash, got %v", err)
+	}
+}
+
+func TestMergeFiles(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	var filenames []string
+	for i := 0; i < 3; i++ {
+		filename := fmt.Sprint("testfile.shard", i)
+		bf, err := Open(filename, WithHashKind(HashKindSipHash, key), WithCapacity(1000, 0.01))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(filename)
+		for j := 0; j < 100; j++ {
+			bf.ExistOrAdd([]byte(fmt.Sprint(i, "-", j)))
+		}
+		if err = bf.Close(); err != nil {
+			t.Fatal(err)
+		}
+		filenames = append(filenames, filename)
+	}
+	if err := MergeFiles(filenames[0], filenames[1:]...); err != nil {
+		t.Fatal(err)
+	}
+	bf, err := Open(filenames[0], WithHashKind(HashKindSipHash, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bf.Close()
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 100; j++ {
+			if !bf.Exist([]byte(fmt.Sprint(i, "-", j))) {
+				t.Fatalf("%v-%v should be merged", i, j)
+			}
+		}
+	}
+
+	other, err := Open("testfile.other", WithHashKind(HashKindSipHash, key), WithCapacity(2000, 0.01))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("testfile.other")
+	if err = other.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = MergeFiles(filenames[1], "testfile.other"); !errors.Is(err, InconsistentParamErr) {
+		t.Fatalf("Should fail to merge a filter of other parameters, got %v", err)
+	}
+}
+
+func TestMergeFiles_CustomHash(t *testing.T) {
+	var filenames []string
+	for i := 0; i < 2; i++ {
+		filename := fmt.Sprint("testfile.shard", i)
+		bf, err := Open(filename, WithHash(doubleFNV), WithCapacity(1000, 0.01))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(filename)
+		bf.ExistOrAdd([]byte(fmt.Sprint(i)))
+		if err = bf.Close(); err != nil {
+			t.Fatal(err)
+		}
+		filenames = append(filenames, filename)
+	}
+	src, err := os.ReadFile(filenames[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = MergeFiles(filenames[0], filenames[1]); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(filenames[1]); err != nil || !bytes.Equal(b, src) {
+		t.Fatalf("Should not modify the source, got %v", err)
+	}
+	bf, err := Open(filenames[0], WithHash(doubleFNV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bf.Close()
+	if !bf.Exist([]byte("0")) || !bf.Exist([]byte("1")) {
+		t.Fatal("Should contain the entries of both files")
+	}
+	if _, err = Open("testfile.new", WithoutHash(), WithCapacity(1000, 0.01)); !errors.Is(err, MissingParamErr) {
+		t.Fatalf("Should not create a file without a hash, got %v", err)
+	}
+	os.Remove("testfile.new")
+}
@@ -0,0 +1,79 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"time"
+)
+
+// WriteMetadata replaces the metadata of the filter, e.g. application state like the rotation epoch.
+// The metadata is synced with the bloom filter, or earlier by SyncMetadata or Controller.MetadataSync.
+// In FsyncModeAlways, it is synced before WriteMetadata returns.
+func (f *DiskFilter) WriteMetadata(metadata []byte) error {
+	if len(metadata) != int(f.controller.MetadataSize) {
+		return fmt.Errorf("%w: length of metadata is %v, but not %v", InconsistentMetadataSizeErr, len(metadata), f.controller.MetadataSize)
+	}
+	if !f.acquire() {
+		return ClosedErr
+	}
+	defer f.release()
+	f.file.mu.Lock()
+	defer f.file.mu.Unlock()
+	if f.readOnly {
+		return f.readOnlyErr()
+	}
+	if err := f.journalMetadataLocked(metadata); err != nil {
+		return err
+	}
+	if err := f.writeMetadataLocked(metadata); err != nil {
+		return err
+	}
+	f.file.metadataModified = true
+	if f.file.fsync == FsyncModeAlways {
+		return f.syncMetadataLocked()
+	}
+	return nil
+}
+
+// SyncMetadata syncs only the metadata and the header, which is much cheaper than syncing the whole file.
+func (f *DiskFilter) SyncMetadata() error {
+	if !f.acquire() {
+		return ClosedErr
+	}
+	defer f.release()
+	f.file.mu.Lock()
+	defer f.file.mu.Unlock()
+	return f.syncMetadataLocked()
+}
+
+func (f *DiskFilter) syncMetadataLocked() error {
+	if !f.file.metadataModified {
+		return nil
+	}
+	if err := f.signLocked(); err != nil {
+		return err
+	}
+	if err := syncRange(f.file.osFile(), 0, f.bloomStart); err != nil {
+		return err
+	}
+	f.file.metadataModified = false
+	return nil
+}
+
+func (f *DiskFilter) syncMetadataEvery(t *task) {
+	ticker := time.NewTicker(f.controller.MetadataSync)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done():
+			return
+		case <-ticker.C:
+		}
+		if !f.acquire() {
+			return
+		}
+		f.file.mu.Lock()
+		_ = t.report(f.syncMetadataLocked())
+		f.file.mu.Unlock()
+		f.release()
+	}
+}
@@ -0,0 +1,21 @@
+package disk_bloom
+
+import (
+	"os"
+	"syscall"
+)
+
+// syncRange writes back the dirty pages in [offset, offset+n) of the file and waits for them.
+// Unlike fsync, it does not flush the metadata of the file, which is unchanged since the file never grows.
+func syncRange(f *os.File, offset int64, n int64) error {
+	if err := syncFileRange(f, offset, n); err != nil {
+		// not supported by the filesystem
+		return f.Sync()
+	}
+	return nil
+}
+
+func syncFileRange(f *os.File, offset int64, n int64) error {
+	const flags = 1 | 2 | 4 // SYNC_FILE_RANGE_WAIT_BEFORE | SYNC_FILE_RANGE_WRITE | SYNC_FILE_RANGE_WAIT_AFTER
+	return syscall.SyncFileRange(int(f.Fd()), offset, n, flags)
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package disk_bloom
+
+import "os"
+
+// syncRange syncs the range [offset, offset+n) of the file. It syncs the whole file except on Linux.
+func syncRange(f *os.File, offset int64, n int64) error {
+	return f.Sync()
+}
+
+func syncFileRange(f *os.File, offset int64, n int64) error {
+	return UnsupportedErr
+}
@@ -0,0 +1,47 @@
+package disk_bloom
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDiskFilter_WriteMetadata(t *testing.T) {
+	bf := newTestFilter(t, Controller{MetadataSize: 8, MetadataSync: 10 * time.Millisecond})
+	if err := bf.WriteMetadata([]byte("short")); !errors.Is(err, InconsistentMetadataSizeErr) {
+		t.Fatalf("Should fail with InconsistentMetadataSizeErr, got %v", err)
+	}
+	if err := bf.WriteMetadata([]byte("epoch 42")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	bf.file.mu.Lock()
+	modified := bf.file.metadataModified
+	bf.file.mu.Unlock()
+	if modified {
+		t.Fatal("Metadata should be synced by MetadataSync")
+	}
+	if err := bf.WriteMetadata([]byte("epoch 43")); err != nil {
+		t.Fatal(err)
+	}
+	if err := bf.SyncMetadata(); err != nil {
+		t.Fatal(err)
+	}
+	bf.Close()
+
+	var metadata []byte
+	controller := bf.Controller()
+	controller.GetParam = func(m []byte) (FilterParam, []byte) {
+		metadata = m
+		return bf.FilterParam(), nil
+	}
+	bf, err := New("testfile", controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bf.Close()
+	if !bytes.Equal(metadata, []byte("epoch 43")) {
+		t.Fatalf("Should read the metadata written, got %q", metadata)
+	}
+}
@@ -0,0 +1,71 @@
+package disk_bloom
+
+import (
+	"bufio"
+	"expvar"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// metric is a metric exported by MetricsHandler.
+type metric struct {
+	name  string
+	kind  string
+	help  string
+	value func(f *DiskFilter, s DebugStats) float64
+}
+
+var metrics = []metric{
+	{"disk_bloom_exists_total", "counter", "Entries looked up.", func(f *DiskFilter, s DebugStats) float64 { return float64(s.Exists) }},
+	{"disk_bloom_adds_total", "counter", "Entries added or found.", func(f *DiskFilter, s DebugStats) float64 { return float64(s.Adds) }},
+	{"disk_bloom_hits_total", "counter", "Lookups and adds finding the entry.", func(f *DiskFilter, s DebugStats) float64 { return float64(s.Hits) }},
+	{"disk_bloom_probes_total", "counter", "Probed bytes.", func(f *DiskFilter, s DebugStats) float64 { return float64(s.Probes) }},
+	{"disk_bloom_cache_hits_total", "counter", "Probes served without reading.", func(f *DiskFilter, s DebugStats) float64 { return float64(s.CacheHits) }},
+	{"disk_bloom_read_bytes_total", "counter", "Bytes read from the file.", func(f *DiskFilter, s DebugStats) float64 { return float64(s.BytesRead) }},
+	{"disk_bloom_written_bytes_total", "counter", "Bytes written to the file.", func(f *DiskFilter, s DebugStats) float64 { return float64(s.BytesWritten) }},
+	{"disk_bloom_syncs_total", "counter", "Syncs of the file.", func(f *DiskFilter, s DebugStats) float64 { return float64(s.Syncs) }},
+	{"disk_bloom_sync_seconds_total", "counter", "Time syncing the file.", func(f *DiskFilter, s DebugStats) float64 { return s.SyncTime.Seconds() }},
+	{"disk_bloom_lock_wait_seconds_total", "counter", "Time waiting for the file lock.", func(f *DiskFilter, s DebugStats) float64 { return s.LockWait.Seconds() }},
+	{"disk_bloom_sync_durable", "gauge", "Whether the syncs look durable to the probe on open, 1 if not probed.", func(f *DiskFilter, s DebugStats) float64 {
+		// the Reason is only set if the syncs were probed and do not look durable
+		if f.probe.Reason != "" {
+			return 0
+		}
+		return 1
+	}},
+	{"disk_bloom_fill_ratio", "gauge", "Fraction of the bits set.", func(f *DiskFilter, s DebugStats) float64 { return f.FillRatio() }},
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// MetricsHandler returns an http.Handler serving the DebugStats and the fill ratio of the filters
+// in the Prometheus text format, labeled by their filenames, so that they can be scraped without a client library.
+// The counters are all zero unless Controller.Debug is set, and rates such as operations per second are left to the queries.
+func MetricsHandler(filters ...*DiskFilter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		bw := bufio.NewWriter(w)
+		stats := make([]DebugStats, len(filters))
+		for i, f := range filters {
+			stats[i] = f.DebugStats()
+		}
+		for _, m := range metrics {
+			fmt.Fprintf(bw, "# HELP %v %v\n# TYPE %v %v\n", m.name, m.help, m.name, m.kind)
+			for i, f := range filters {
+				fmt.Fprintf(bw, "%v{filter=\"%v\"} %v\n", m.name, labelEscaper.Replace(f.file.f.Name()), m.value(f, stats[i]))
+			}
+		}
+		_ = bw.Flush()
+	})
+}
+
+// Var returns an expvar.Var of the DebugStats and the fill ratio, to be published by expvar.Publish.
+func (f *DiskFilter) Var() expvar.Var {
+	return expvar.Func(func() interface{} {
+		return struct {
+			DebugStats
+			FillRatio float64
+		}{f.DebugStats(), f.FillRatio()}
+	})
+}
@@ -0,0 +1,40 @@
+package disk_bloom
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	bf := newTestFilter(t, Controller{Debug: true})
+	bf.ExistOrAdd([]byte("testing"))
+	bf.Exist([]byte("testing"))
+	rec := httptest.NewRecorder()
+	MetricsHandler(bf).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	b, _ := io.ReadAll(rec.Body)
+	for _, line := range []string{
+		"# TYPE disk_bloom_hits_total counter",
+		`disk_bloom_exists_total{filter="testfile"} 1`,
+		`disk_bloom_adds_total{filter="testfile"} 1`,
+		`disk_bloom_hits_total{filter="testfile"} 1`,
+		"# TYPE disk_bloom_fill_ratio gauge",
+	} {
+		if !strings.Contains(string(b), line+"\n") {
+			t.Fatalf("Should contain %q, got:\n%s", line, b)
+		}
+	}
+
+	var v struct {
+		Hits      uint64
+		FillRatio float64
+	}
+	if err := json.Unmarshal([]byte(bf.Var().String()), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Hits != 1 || v.FillRatio <= 0 {
+		t.Fatalf("Unexpected var %+v", v)
+	}
+}
@@ -0,0 +1,118 @@
+package disk_bloom
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+	"os"
+	"sync/atomic"
+)
+
+// Migrate rewrites the classic filter file filename created with the old controller into the layout of the new one,
+// e.g. when an upgrade of the application changes MetadataSize, which otherwise fails with InconsistentMetadataSizeErr.
+// The metadata is truncated or padded with zeros to the new MetadataSize, and new.GetParam, if given, is invoked
+// with it and may return the updated metadata as on open; the parameters returned are ignored.
+// The bits are kept, so the parameters, the hash and the probing of the old file are kept, and so is the tag
+// unless new.Tag is given. The new file is written next to filename and renamed over it once complete,
+// and the sidecar checksums and journal of the old file are removed, which New creates again.
+// The filter must not be open elsewhere during the migration.
+func Migrate(filename string, old, new Controller) error {
+	src, err := New(filename, old)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if v := src.header.variant(); v != variantClassic {
+		return fmt.Errorf("%w: migrating %v filters", UnsupportedErr, v)
+	}
+	metadata := make([]byte, old.MetadataSize)
+	src.rlock()
+	_, err = src.file.rw.ReadAt(metadata, LenOfMetadataSize)
+	src.file.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	resized := make([]byte, new.MetadataSize)
+	copy(resized, metadata)
+	if new.GetParam != nil {
+		if _, updated := new.GetParam(resized); len(updated) == len(resized) {
+			resized = updated
+		}
+	}
+	param := *src.param
+	new.GetParam = func([]byte) (FilterParam, []byte) {
+		return param, resized
+	}
+	// the probes are mapped as recorded in the header of the old file
+	new.FastRange, new.AlignToPage = src.header.FastRange(), src.header.Aligned()
+	new.AdaptiveSlots, new.HardenedProbes = src.header.AdaptiveSlots, src.header.Hardened()
+	new.Salted, new.Salt = src.header.Salted(), src.header.Salt
+	new.Checksums, new.Journal, new.ReadOnly = false, false, false
+	if new.Tag == "" {
+		new.Tag = src.header.Tag
+	}
+	tmp := filename + ".migrate"
+	if err = os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	dst, err := New(tmp, new)
+	if err != nil {
+		return err
+	}
+	if err = quiesce([]*DiskFilter{src, dst}, func() error {
+		return dst.copyBloomLocked(src)
+	}); err == nil {
+		err = dst.Barrier(context.Background())
+	}
+	if e := dst.Close(); err == nil {
+		err = e
+	}
+	if e := src.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err = os.Rename(tmp, filename); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	for _, sidecar := range []string{ChecksumFilename(filename), JournalFilename(filename)} {
+		if err = os.Remove(sidecar); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyBloomLocked writes the bloom filter of src of the same parameters into the empty f.
+func (f *DiskFilter) copyBloomLocked(src *DiskFilter) error {
+	size := f.header.bloomSize(f.param.Bits)
+	buf := make([]byte, 1<<16)
+	var set uint64
+	for offset := int64(0); offset < size; offset += int64(len(buf)) {
+		b := buf
+		if size-offset < int64(len(b)) {
+			b = b[:size-offset]
+		}
+		if err := src.readBloomLocked(b, offset); err != nil {
+			return err
+		}
+		if _, err := f.file.rw.WriteAt(b, f.fileOffset(offset)); err != nil {
+			return err
+		}
+		for i, val := range b {
+			if val != 0 {
+				set += uint64(bits.OnesCount8(val))
+				if err := f.wroteLocked(val, f.fileOffset(offset+int64(i))); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	atomic.StoreUint64(&f.setBits, set)
+	atomic.StoreInt32(&f.counted, 1)
+	f.file.modified = true
+	return nil
+}
@@ -0,0 +1,74 @@
+package disk_bloom
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "filter")
+	slots, bits := OptimalParam(1000, 1e-4)
+	old := Controller{
+		Fsync:        FsyncModeNo,
+		MetadataSize: 4,
+		Tag:          "v1",
+		FastRange:    true,
+		Checksums:    true,
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			if metadata == nil {
+				return FilterParam{Slots: slots, Bits: bits, HashKind: HashKindXXHash64}, []byte("abcd")
+			}
+			return FilterParam{}, nil
+		},
+	}
+	bf, err := New(filename, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	fill := bf.FillRatio()
+	if err = bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var given []byte
+	controller := Controller{
+		Fsync:        FsyncModeNo,
+		MetadataSize: 8,
+		Checksums:    true,
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			given = append([]byte(nil), metadata...)
+			return FilterParam{}, nil
+		},
+	}
+	if _, err = New(filename, controller); !errors.Is(err, InconsistentMetadataSizeErr) {
+		t.Fatalf("Should fail before migrated, got %v", err)
+	}
+	if err = Migrate(filename, old, controller); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(given, []byte("abcd\x00\x00\x00\x00")) {
+		t.Fatalf("the metadata should be padded, got %q", given)
+	}
+	if bf, err = New(filename, controller); err != nil {
+		t.Fatal(err)
+	}
+	defer bf.Close()
+	h := bf.Header()
+	if h.Tag != "v1" || !h.FastRange() || bf.FillRatio() != fill {
+		t.Fatalf("the filter should be kept, got %+v, fill ratio %v", h, bf.FillRatio())
+	}
+	for i := 0; i < 1000; i++ {
+		if !bf.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should exist after migrated", i)
+		}
+	}
+	if err = bf.Verify(); err != nil {
+		t.Fatalf("the checksums should be computed again, got %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sync/atomic"
+	"unsafe"
+)
+
+var MmapErr = fmt.Errorf("failed to map the file")
+
+// mmapStorage serves the bloom filter from a shared mapping of the file, and the rest from the file.
+// The mapped bytes are accessed by atomic operations on their uint32 words,
+// so that the lock-free Exist never races with the writes under the lock.
+type mmapStorage struct {
+	storage
+	// start is the file offset of mem[0], which is page-aligned
+	start int64
+	// end is the file offset where the mapped bloom filter ends
+	end int64
+	// bloomStart is the file offset of the bloom filter
+	bloomStart int64
+	mem        []byte
+}
+
+// newMmapStorage maps the bloom filter of size bytes starting at bloomStart of the file.
+func newMmapStorage(rw storage, f *os.File, bloomStart int64, size int64) (*mmapStorage, error) {
+	start := bloomStart &^ int64(os.Getpagesize()-1)
+	// round up to whole words, since the mapped bytes are accessed by uint32
+	length := (bloomStart + size - start + 3) / 4 * 4
+	if length > math.MaxInt {
+		return nil, fmt.Errorf("%w: %v bytes exceed the address space", MmapErr, length)
+	}
+	mem, err := mmapFile(f, start, int(length))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", MmapErr, err)
+	}
+	return &mmapStorage{
+		storage:    rw,
+		start:      start,
+		end:        bloomStart + size,
+		bloomStart: bloomStart,
+		mem:        mem,
+	}, nil
+}
+
+// word returns the uint32 word containing the byte at the file offset pos, and the shift of the byte in the word.
+func (s *mmapStorage) word(pos int64) (*uint32, uint) {
+	i := pos - s.start
+	shift := uint(8 * (i % 4))
+	if !nativeLittleEndian {
+		shift = uint(8 * (3 - i%4))
+	}
+	return (*uint32)(unsafe.Pointer(&s.mem[i/4*4])), shift
+}
+
+func (s *mmapStorage) loadByte(pos int64) byte {
+	word, shift := s.word(pos)
+	return byte(atomic.LoadUint32(word) >> shift)
+}
+
+func (s *mmapStorage) storeByte(pos int64, val byte) {
+	word, shift := s.word(pos)
+	for {
+		old := atomic.LoadUint32(word)
+		if atomic.CompareAndSwapUint32(word, old, old&^(0xff<<shift)|uint32(val)<<shift) {
+			return
+		}
+	}
+}
+
+// mapped splits the range of n bytes at offset into the parts before, in and after the mapped bloom filter.
+func (s *mmapStorage) mapped(offset int64, n int) (before, in int) {
+	from, to := offset, offset+int64(n)
+	if from < s.bloomStart {
+		before = n
+		if to > s.bloomStart {
+			before = int(s.bloomStart - from)
+		}
+		from = s.bloomStart
+	}
+	if to > s.end {
+		to = s.end
+	}
+	if to > from {
+		in = int(to - from)
+	}
+	return before, in
+}
+
+func (s *mmapStorage) ReadAt(b []byte, offset int64) (n int, err error) {
+	before, in := s.mapped(offset, len(b))
+	if before > 0 {
+		if n, err = s.storage.ReadAt(b[:before], offset); err != nil {
+			return n, err
+		}
+	}
+	for ; n < before+in; n++ {
+		b[n] = s.loadByte(offset + int64(n))
+	}
+	if n < len(b) {
+		m, err := s.storage.ReadAt(b[n:], offset+int64(n))
+		return n + m, err
+	}
+	return n, nil
+}
+
+func (s *mmapStorage) WriteAt(b []byte, offset int64) (n int, err error) {
+	before, in := s.mapped(offset, len(b))
+	if before > 0 {
+		if n, err = s.storage.WriteAt(b[:before], offset); err != nil {
+			return n, err
+		}
+	}
+	for ; n < before+in; n++ {
+		s.storeByte(offset+int64(n), b[n])
+	}
+	if n < len(b) {
+		m, err := s.storage.WriteAt(b[n:], offset+int64(n))
+		return n + m, err
+	}
+	return n, nil
+}
+
+func (s *mmapStorage) close() error {
+	return munmapFile(s.mem)
+}
+
+// mmapLocked switches the bloom filter to the mapped storage.
+// Encrypted and shrunk files are not mapped, since their bytes on disk are not the bloom filter itself,
+// and neither are the files of an fs.FS, which are not files of the OS.
+func (f *DiskFilter) mmapLocked() error {
+	file := f.file.osFile()
+	if f.header.Encrypted() || f.header.Shrunk() || file == nil {
+		return fmt.Errorf("%w: encrypted, shrunk or opened by OpenFS", MmapErr)
+	}
+	size := f.header.bloomSize(f.param.Bits)
+	if !f.controller.MemoryBudget.reserve(size) {
+		return fmt.Errorf("%w: mapping %v bytes", MemoryBudgetErr, size)
+	}
+	m, err := newMmapStorage(f.file.rw, file, f.bloomStart, size)
+	if err != nil {
+		f.controller.MemoryBudget.release(size)
+		return err
+	}
+	f.file.rw = m
+	f.mapped = m
+	if f.controller.AccessPattern != AccessPatternNormal {
+		_ = madviseMem(m.mem, f.controller.AccessPattern)
+	}
+	return nil
+}
+
+// munmapLocked switches the bloom filter back to the file I/O.
+// The lock-free lookups must be drained, see exclusive.
+func (f *DiskFilter) munmapLocked() error {
+	if f.mapped == nil {
+		return nil
+	}
+	f.file.rw = f.mapped.storage
+	f.controller.MemoryBudget.release(f.header.bloomSize(f.param.Bits))
+	err := f.mapped.close()
+	f.mapped = nil
+	f.lockFree = false
+	return err
+}
+
+// existMapped is the lock-free ExistHashed on the mapped bloom filter.
+func (f *DiskFilter) existMapped(h KeyHash) bool {
+	m := f.mapped
+	for i := 0; i < f.lookupSlots(); i++ {
+		offset := f.bloomOffset(h.X, h.Y, i)
+		word, shift := m.word(f.fileOffset(int64(offset / 8)))
+		if atomic.LoadUint32(word)&(1<<(shift+uint(offset%8))) == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,26 @@
+package disk_bloom
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+func mmapFile(f *os.File, offset int64, length int) ([]byte, error) {
+	// the holes of a sparse file are allocated on the first write to the mapped pages,
+	// which crashes with SIGBUS if the disk is full, so allocate them beforehand
+	if err := fallocate(f, offset, int64(length)); err != nil && !errors.Is(err, syscall.EOPNOTSUPP) {
+		return nil, err
+	}
+	return syscall.Mmap(int(f.Fd()), offset, length, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+}
+
+// fallocate allocates the range of the file without changing its size.
+func fallocate(f *os.File, offset int64, length int64) error {
+	const keepSize = 0x1 // FALLOC_FL_KEEP_SIZE
+	return syscall.Fallocate(int(f.Fd()), keepSize, offset, length)
+}
+
+func munmapFile(mem []byte) error {
+	return syscall.Munmap(mem)
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package disk_bloom
+
+import "os"
+
+// mmapFile is only supported on Linux, so the mapping falls back to the file I/O elsewhere.
+func mmapFile(f *os.File, offset int64, length int) ([]byte, error) {
+	return nil, UnsupportedErr
+}
+
+func fallocate(f *os.File, offset int64, length int64) error {
+	return UnsupportedErr
+}
+
+func munmapFile(mem []byte) error {
+	return nil
+}
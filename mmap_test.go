@@ -0,0 +1,104 @@
+package disk_bloom
+
+import (
+	"errors"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestDiskFilter_Mmap(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("mmap is only supported on Linux")
+	}
+	always := newTestFilter(t, Controller{Mmap: true})
+	if always.lockFree {
+		t.Fatal("Exist should take the lock in FsyncModeAlways, not seeing the bits before synced")
+	}
+	always.Close()
+	bf := newTestFilter(t, Controller{
+		Fsync: FsyncModeNo,
+		Mmap:  true,
+		OnMmapFallback: func(err error) {
+			t.Errorf("Should not fall back: %v", err)
+		},
+	})
+	if !bf.lockFree {
+		t.Fatal("Exist should be lock-free")
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := i * 1000; j < (i+1)*1000; j++ {
+				key := []byte(strconv.Itoa(j))
+				bf.ExistOrAdd(key)
+				if !bf.Exist(key) {
+					t.Errorf("%v should exist in filter", j)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	bf.Close()
+
+	// the file is the same as written by the file I/O
+	bf = newTestFilter(t, Controller{})
+	for i := 0; i < 4000; i++ {
+		if !bf.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should exist in filter", i)
+		}
+	}
+	falsePositives := 0
+	for i := 4000; i < 14000; i++ {
+		if bf.Exist([]byte(strconv.Itoa(i))) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 10 {
+		t.Fatalf("Too many false positives: %v", falsePositives)
+	}
+}
+
+func TestDiskFilter_MmapFallback(t *testing.T) {
+	var fallback error
+	bf := newTestFilter(t, Controller{
+		Mmap:          true,
+		EncryptionKey: []byte("0123456789abcdef"),
+		OnMmapFallback: func(err error) {
+			fallback = err
+		},
+	})
+	if !errors.Is(fallback, MmapErr) || bf.mapped != nil || bf.lockFree {
+		t.Fatalf("Should fall back to the file I/O, got %v", fallback)
+	}
+	for i := 0; i < 1000; i++ {
+		bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	for i := 0; i < 1000; i++ {
+		if !bf.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should exist in filter", i)
+		}
+	}
+}
+
+func TestDiskFilter_MmapShrinkOnSeal(t *testing.T) {
+	bf := newTestFilter(t, Controller{Mmap: true, ShrinkOnSeal: true})
+	for i := 0; i < 10; i++ {
+		bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	if err := bf.Seal(); err != nil {
+		t.Fatal(err)
+	}
+	if bf.mapped != nil || bf.lockFree {
+		t.Fatal("The shrunk filter should not be mapped")
+	}
+	for i := 0; i < 10; i++ {
+		if !bf.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should exist in the shrunk filter", i)
+		}
+	}
+}
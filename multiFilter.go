@@ -0,0 +1,78 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"sync"
+)
+
+var DuplicateNameErr = fmt.Errorf("duplicate filter name")
+
+// hashedFilter is a Filter taking the double hash of entries, like DiskFilter and FilterGroup.
+// DiskSet stores the entries themselves, so it is probed by the entry.
+type hashedFilter interface {
+	Filter
+	ExistHashed(h KeyHash) bool
+}
+
+// MultiFilter is a registry of named filters, so that an entry is checked against all of them at once,
+// e.g. a blocklist, an allowlist and a dedup filter consulted for every request.
+type MultiFilter struct {
+	hash    func([]byte) (uint64, uint64)
+	mu      sync.RWMutex
+	filters map[string]Filter
+}
+
+// NewMultiFilter returns an empty MultiFilter. If hash is not nil, CheckAll hashes an entry once by it
+// and probes the filters taking the hash with the result, so the filters registered should share the hash.
+// Otherwise, every filter hashes the entry itself.
+func NewMultiFilter(hash func([]byte) (uint64, uint64)) *MultiFilter {
+	return &MultiFilter{hash: hash, filters: make(map[string]Filter)}
+}
+
+// Register adds a filter under the name. It returns DuplicateNameErr if the name is taken.
+func (m *MultiFilter) Register(name string, filter Filter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.filters[name]; ok {
+		return fmt.Errorf("%w: %v", DuplicateNameErr, name)
+	}
+	m.filters[name] = filter
+	return nil
+}
+
+// Unregister removes the filter of the name, which is not closed.
+func (m *MultiFilter) Unregister(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.filters, name)
+}
+
+// CheckAll returns whether the entry is in each of the filters by their names. The filters are probed concurrently.
+func (m *MultiFilter) CheckAll(b []byte) map[string]bool {
+	var h KeyHash
+	if m.hash != nil {
+		h.X, h.Y = m.hash(b)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	exist := make(map[string]bool, len(m.filters))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, filter := range m.filters {
+		wg.Add(1)
+		go func(name string, filter Filter) {
+			defer wg.Done()
+			var e bool
+			if hf, ok := filter.(hashedFilter); ok && m.hash != nil {
+				e = hf.ExistHashed(h)
+			} else {
+				e = filter.Exist(b)
+			}
+			mu.Lock()
+			exist[name] = e
+			mu.Unlock()
+		}(name, filter)
+	}
+	wg.Wait()
+	return exist
+}
@@ -0,0 +1,46 @@
+package disk_bloom
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestMultiFilter_CheckAll(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo})
+	s, err := NewSet("testfile.set", FsyncModeNo, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		s.Close()
+		os.Remove("testfile.set")
+	}()
+	bf.ExistOrAdd([]byte("blocked"))
+	s.ExistOrAdd([]byte("allowed"))
+
+	for _, hash := range []func([]byte) (uint64, uint64){doubleFNV, nil} {
+		m := NewMultiFilter(hash)
+		if err = m.Register("blocklist", bf); err != nil {
+			t.Fatal(err)
+		}
+		if err = m.Register("allowlist", s); err != nil {
+			t.Fatal(err)
+		}
+		if err = m.Register("allowlist", s); !errors.Is(err, DuplicateNameErr) {
+			t.Fatalf("Should return DuplicateNameErr, got %v", err)
+		}
+		exist := m.CheckAll([]byte("blocked"))
+		if len(exist) != 2 || !exist["blocklist"] || exist["allowlist"] {
+			t.Fatalf("Should be only in the blocklist, got %v", exist)
+		}
+		exist = m.CheckAll([]byte("allowed"))
+		if len(exist) != 2 || exist["blocklist"] || !exist["allowlist"] {
+			t.Fatalf("Should be only in the allowlist, got %v", exist)
+		}
+		m.Unregister("blocklist")
+		if exist = m.CheckAll([]byte("blocked")); len(exist) != 1 || exist["allowlist"] {
+			t.Fatalf("Should be checked against the allowlist only, got %v", exist)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package disk_bloom
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+var NoiseErr = fmt.Errorf("invalid noise")
+
+// CloneWithNoise is Clone, but flips every bit of the bloom filter in the copy independently with the probability noise,
+// so that a filter derived from user data can be shared with plausible deniability about the membership of any entry:
+// each bit of the copy is set or not regardless of the original with a probability of at least noise.
+// noise must be in [0, 0.5], where 0.5 leaves nothing of the original. See NoisyRates for its effect on lookups.
+// Only classic filters which are not shrunk are supported.
+func (f *DiskFilter) CloneWithNoise(filename string, noise float64) error {
+	if noise < 0 || noise > 0.5 {
+		return fmt.Errorf("%w: %v is not in [0, 0.5]", NoiseErr, noise)
+	}
+	if f.header.variant() != variantClassic || f.header.Shrunk() {
+		return fmt.Errorf("%w: only classic filters which are not shrunk are supported", NoiseErr)
+	}
+	if err := f.Clone(filename); err != nil {
+		return err
+	}
+	if noise == 0 {
+		return nil
+	}
+	if err := f.addNoise(filename, noise); err != nil {
+		_ = os.Remove(filename)
+		return err
+	}
+	return nil
+}
+
+// addNoise flips the bits of the bloom filter in the copy filename. The bits to flip are sampled by the gaps between them,
+// which are geometrically distributed, so that the cost is proportional to the bits flipped.
+func (f *DiskFilter) addNoise(filename string, noise float64) error {
+	file, err := os.OpenFile(filename, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	raw := retryStorage{file}
+	var rw storage = raw
+	headerStart := LenOfMetadataSize + int64(f.controller.MetadataSize)
+	if f.header.Encrypted() {
+		if rw, err = newEncryptedStorage(raw, f.controller.EncryptionKey, f.header.nonce, headerStart, headerStart+int64(f.header.Size)); err != nil {
+			return err
+		}
+	}
+	// the randomness must be unpredictable, or the noise could be removed
+	random := bufio.NewReader(rand.Reader)
+	logKeep := math.Log1p(-noise)
+	var b [8]byte
+	gap := func() (uint64, error) {
+		if _, err := io.ReadFull(random, b[:]); err != nil {
+			return 0, err
+		}
+		// uniform in (0, 1]
+		u := float64(binary.LittleEndian.Uint64(b[:])>>11+1) / (1 << 53)
+		return uint64(math.Log(u) / logKeep), nil
+	}
+	next, err := gap()
+	if err != nil {
+		return err
+	}
+	// set is the change of the bits set
+	var set int64
+	buf := make([]byte, 1<<16)
+	for offset := uint64(0); offset*8 < f.param.Bits && next < f.param.Bits; offset += uint64(len(buf)) {
+		n := uint64(len(buf))
+		if rest := (f.param.Bits+7)/8 - offset; rest < n {
+			n = rest
+		}
+		if next >= (offset+n)*8 {
+			continue
+		}
+		if _, err = rw.ReadAt(buf[:n], f.bloomStart+int64(offset)); err != nil {
+			return err
+		}
+		for next < (offset+n)*8 && next < f.param.Bits {
+			i, mask := next/8-offset, byte(1)<<(next%8)
+			if buf[i]&mask != 0 {
+				set--
+			} else {
+				set++
+			}
+			buf[i] ^= mask
+			g, err := gap()
+			if err != nil {
+				return err
+			}
+			next += g + 1
+		}
+		if _, err = rw.WriteAt(buf[:n], f.bloomStart+int64(offset)); err != nil {
+			return err
+		}
+	}
+	if f.header.Adaptive() {
+		// the bits set are recorded in the header of adaptive filters
+		if f.header.WearLeveled() {
+			err = addSetBitsSlot(raw, f.controller.MetadataSize, set)
+		} else if _, err = raw.ReadAt(b[:], headerStart+headerSetBitsOffset); err == nil {
+			binary.LittleEndian.PutUint64(b[:], uint64(int64(binary.LittleEndian.Uint64(b[:]))+set))
+			_, err = raw.WriteAt(b[:], headerStart+headerSetBitsOffset)
+		}
+		if err != nil {
+			return err
+		}
+		if len(f.controller.HMACKey) > 0 && !f.header.Sealed() {
+			if err = signImage(f.controller.HMACKey, raw, f.controller.MetadataSize, f.header); err != nil {
+				return err
+			}
+		}
+	}
+	return file.Sync()
+}
+
+// NoisyRates returns the false positive rate and the false negative rate of lookups in a copy by CloneWithNoise,
+// given the number of hashes per entry, the fill ratio of the original filter and the noise.
+// A bit of an entry added reads set with the probability 1-noise, and a bit of an entry not added
+// with the probability fill·(1-noise) + (1-fill)·noise, so that
+//
+//	FPR = (fill·(1-noise) + (1-fill)·noise)^slots
+//	FNR = 1 - (1-noise)^slots
+//
+// Both grow quickly with slots, so that the noise should be small, e.g. 0.01 to 0.05.
+func NoisyRates(slots uint8, fill float64, noise float64) (fpr, fnr float64) {
+	fpr = math.Pow(fill*(1-noise)+(1-fill)*noise, float64(slots))
+	fnr = 1 - math.Pow(1-noise, float64(slots))
+	return fpr, fnr
+}
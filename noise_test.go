@@ -0,0 +1,63 @@
+package disk_bloom
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"os"
+	"testing"
+)
+
+func TestDiskFilter_CloneWithNoise(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo})
+	for i := 0; i < 1000; i++ {
+		bf.ExistOrAdd([]byte(fmt.Sprint(i)))
+	}
+	if err := bf.CloneWithNoise("testfile.noisy", 0.6); !errors.Is(err, NoiseErr) {
+		t.Fatalf("Should reject the noise of 0.6, got %v", err)
+	}
+	const noise = 0.1
+	if err := bf.CloneWithNoise("testfile.noisy", noise); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("testfile.noisy")
+	original, err := os.ReadFile("testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	noisy, err := os.ReadFile("testfile.noisy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var flipped int
+	for i := range original {
+		flipped += bits.OnesCount8(original[i] ^ noisy[i])
+	}
+	if rate := float64(flipped) / float64(bf.param.Bits); math.Abs(rate-noise) > 0.01 {
+		t.Fatalf("about %v of the bits should be flipped, got %v", noise, rate)
+	}
+
+	copied, err := New("testfile.noisy", bf.Controller())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer copied.Close()
+	fill := bf.FillRatio()
+	if want := fill*(1-noise) + (1-fill)*noise; math.Abs(copied.FillRatio()-want) > 0.01 {
+		t.Fatalf("fill ratio of the copy should be about %v, got %v", want, copied.FillRatio())
+	}
+	fpr, fnr := NoisyRates(bf.param.Slots, fill, noise)
+	var fp, fn int
+	for i := 0; i < 1000; i++ {
+		if !copied.Exist([]byte(fmt.Sprint(i))) {
+			fn++
+		}
+		if copied.Exist([]byte(fmt.Sprint("absent", i))) {
+			fp++
+		}
+	}
+	if math.Abs(float64(fn)/1000-fnr) > 0.05 || math.Abs(float64(fp)/1000-fpr) > 0.05 {
+		t.Fatalf("rates should be about %v and %v, got %v and %v", fpr, fnr, float64(fp)/1000, float64(fn)/1000)
+	}
+}
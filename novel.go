@@ -0,0 +1,21 @@
+package disk_bloom
+
+// FilterNovel returns the keys not in the filter, and adds them to the filter.
+// A key repeated in keys is novel only at its first occurrence.
+// It is optimized for large batches, e.g. deduplicating the lines of logs: the offsets of all keys are sorted once,
+// and the probed bytes are read page by page and written in order, all under a single lock acquisition.
+func (f *DiskFilter) FilterNovel(keys [][]byte) (novel [][]byte) {
+	novel, _ = f.FilterNovelErr(keys)
+	return novel
+}
+
+// FilterNovelErr is like FilterNovel, but returns the error if the novel keys failed to be added.
+func (f *DiskFilter) FilterNovelErr(keys [][]byte) (novel [][]byte, err error) {
+	exist, err := f.ExistOrAddBatchErr(keys)
+	for k, key := range keys {
+		if k < len(exist) && !exist[k] {
+			novel = append(novel, key)
+		}
+	}
+	return novel, err
+}
@@ -0,0 +1,36 @@
+package disk_bloom
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestDiskFilter_FilterNovel(t *testing.T) {
+	bf := newTestFilter(t, Controller{})
+	for i := 0; i < 50; i++ {
+		bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	var keys [][]byte
+	for i := 0; i < 100; i++ {
+		keys = append(keys, []byte(strconv.Itoa(i)))
+	}
+	// repeated in the batch
+	keys = append(keys, []byte("60"), []byte("70"))
+	novel := bf.FilterNovel(keys)
+	if len(novel) != 50 {
+		t.Fatalf("Should return 50 novel keys, got %v", len(novel))
+	}
+	for i, key := range novel {
+		if want := strconv.Itoa(50 + i); string(key) != want {
+			t.Fatalf("Novel key #%v: got %s, want %v", i, key, want)
+		}
+	}
+	for _, key := range keys {
+		if !bf.Exist(key) {
+			t.Fatalf("%s should exist in filter", key)
+		}
+	}
+	if novel := bf.FilterNovel(keys); len(novel) != 0 {
+		t.Fatalf("Should return no novel keys, got %v", len(novel))
+	}
+}
@@ -0,0 +1,431 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+var MissingParamErr = fmt.Errorf("missing parameter")
+
+// Option configures a DiskFilter opened by Open.
+type Option func(o *options)
+
+type options struct {
+	controller Controller
+	slots      uint8
+	bits       uint64
+	hash       func([]byte) (uint64, uint64)
+	hashKind   HashKind
+	hashKey    []byte
+	// backend is the Storage of WithBackend
+	backend Storage
+	// onMetadata is invoked with the metadata read from the file, or nil for a new file.
+	onMetadata func(metadata []byte) (updatedMetadata []byte)
+}
+
+// WithFsync sets the FsyncMode. The default is FsyncModeEverySec.
+func WithFsync(fsync FsyncMode) Option {
+	return func(o *options) {
+		o.controller.Fsync = fsync
+	}
+}
+
+// WithGroupCommit lets concurrent adds in FsyncModeAlways share a single sync issued linger after the first of them.
+func WithGroupCommit(linger time.Duration) Option {
+	return func(o *options) {
+		o.controller.GroupCommit = linger
+	}
+}
+
+// WithDebug enables DebugStats and the pprof labels, see Controller.Debug.
+func WithDebug() Option {
+	return func(o *options) {
+		o.controller.Debug = true
+	}
+}
+
+// WithStats enables the per-minute counters of Stats. clock is optional.
+func WithStats(clock Clock) Option {
+	return func(o *options) {
+		o.controller.Stats = true
+		o.controller.Clock = clock
+	}
+}
+
+// WithHash sets the double hash that takes an entry and returns two different hashes. It is required.
+func WithHash(hash func([]byte) (uint64, uint64)) Option {
+	return func(o *options) {
+		o.hash = hash
+	}
+}
+
+// WithHashKind selects a built-in double hash instead of WithHash. key is only used by HashKindSipHash.
+func WithHashKind(kind HashKind, key []byte) Option {
+	return func(o *options) {
+		o.hashKind, o.hashKey = kind, key
+	}
+}
+
+// WithParam sets the number of hashes per entry and the number of bits of the filter.
+func WithParam(slots uint8, bits uint64) Option {
+	return func(o *options) {
+		o.slots, o.bits = slots, bits
+	}
+}
+
+// WithCapacity sets the parameters optimal for n expected entries and the expected false positive rate p.
+func WithCapacity(n uint64, p float64) Option {
+	return func(o *options) {
+		o.slots, o.bits = OptimalParam(n, p)
+	}
+}
+
+// WithMetadata reserves size bytes of application metadata in the file.
+// onMetadata is invoked by Open with the metadata read from the file, or nil for a new file,
+// and the metadata it returns is written back if not nil. control is invoked every SyncInterval.
+// Both of onMetadata and control are optional. See Controller for details.
+func WithMetadata(size uint16, onMetadata func(metadata []byte) (updatedMetadata []byte), control func(f *os.File, modified bool)) Option {
+	return func(o *options) {
+		o.controller.MetadataSize = size
+		o.onMetadata = onMetadata
+		o.controller.Control = control
+	}
+}
+
+// WithPageAlignment aligns the bloom filter of new files to pages, see Controller.AlignToPage.
+func WithPageAlignment() Option {
+	return func(o *options) {
+		o.controller.AlignToPage = true
+	}
+}
+
+// WithFastRange maps the probes of new files by Lemire's multiply-shift, see Controller.FastRange.
+func WithFastRange() Option {
+	return func(o *options) {
+		o.controller.FastRange = true
+	}
+}
+
+// WithHardenedProbes corrects the degenerate double hashes of new files, see Controller.HardenedProbes.
+func WithHardenedProbes() Option {
+	return func(o *options) {
+		o.controller.HardenedProbes = true
+	}
+}
+
+// WithVerifyWrites re-reads every byte written, see Controller.VerifyWrites.
+// onCorruptWrite is optional.
+func WithVerifyWrites(onCorruptWrite func(offset int64, written, read byte)) Option {
+	return func(o *options) {
+		o.controller.VerifyWrites = true
+		o.controller.OnCorruptWrite = onCorruptWrite
+	}
+}
+
+// WithAdaptiveSlots sets extra bits per entry, which Exist probes according to the fill ratio, see Controller.AdaptiveSlots.
+func WithAdaptiveSlots(extra uint8) Option {
+	return func(o *options) {
+		o.controller.AdaptiveSlots = extra
+	}
+}
+
+// WithFillThresholds invokes onFill once the filter reaches each of thresholds of its design capacity, see Controller.FillThresholds.
+func WithFillThresholds(onFill func(threshold float64, fill float64), thresholds ...float64) Option {
+	return func(o *options) {
+		o.controller.FillThresholds = thresholds
+		o.controller.OnFillThreshold = onFill
+	}
+}
+
+// WithChecksums keeps the checksums of the bloom filter, see Controller.Checksums.
+// If verifyOnOpen is set, Open fails with ChecksumErr on a mismatch.
+func WithChecksums(verifyOnOpen bool) Option {
+	return func(o *options) {
+		o.controller.Checksums = true
+		o.controller.VerifyOnOpen = verifyOnOpen
+	}
+}
+
+// WithFaultInjector injects the failures of injector into the file I/O, see Controller.FaultInjector.
+func WithFaultInjector(injector *FaultInjector) Option {
+	return func(o *options) {
+		o.controller.FaultInjector = injector
+	}
+}
+
+// WithMmap serves the bloom filter from a shared mapping of the file, see Controller.Mmap.
+// onFallback is optional.
+func WithMmap(onFallback func(err error)) Option {
+	return func(o *options) {
+		o.controller.Mmap = true
+		o.controller.OnMmapFallback = onFallback
+	}
+}
+
+// WithHybrid keeps the whole bloom filter in memory, writing the pages changed to the file every interval,
+// see Controller.Hybrid.
+func WithHybrid(interval time.Duration) Option {
+	return func(o *options) {
+		o.controller.Hybrid = interval
+	}
+}
+
+// WithAccessPattern declares the access pattern of the bloom filter to the kernel, see Controller.AccessPattern.
+func WithAccessPattern(pattern AccessPattern) Option {
+	return func(o *options) {
+		o.controller.AccessPattern = pattern
+	}
+}
+
+// WithSyncProbe probes whether the syncs of the file look durable on open, see Controller.SyncProbe.
+// onNotDurable is optional.
+func WithSyncProbe(mode SyncProbeMode, onNotDurable func(probe SyncProbe)) Option {
+	return func(o *options) {
+		o.controller.SyncProbe = mode
+		o.controller.OnSyncProbe = onNotDurable
+	}
+}
+
+// WithAutoTune probes the storage at open and turns on the options its latencies call for, see Controller.AutoTune.
+func WithAutoTune() Option {
+	return func(o *options) {
+		o.controller.AutoTune = true
+	}
+}
+
+// WithWriteBuffer defers the writes of adds, flushing them every interval or every ops adds, see Controller.WriteBuffer.
+func WithWriteBuffer(interval time.Duration, ops int) Option {
+	return func(o *options) {
+		o.controller.WriteBuffer = interval
+		o.controller.WriteBufferOps = ops
+	}
+}
+
+// WithBlockCache keeps size bytes of the pages of the bloom filter read most recently in memory, see Controller.BlockCache.
+func WithBlockCache(size int64) Option {
+	return func(o *options) {
+		o.controller.BlockCache = size
+	}
+}
+
+// WithCache keeps size bytes of the bloom filter in memory, the pages read most recently, like WithBlockCache.
+func WithCache(size int64) Option {
+	return WithBlockCache(size)
+}
+
+// WithAdaptiveBlockCache keeps between min and max bytes of the pages of the bloom filter read most recently in memory,
+// resized by the hit rate, see Controller.BlockCacheMin.
+func WithAdaptiveBlockCache(min, max int64) Option {
+	return func(o *options) {
+		o.controller.BlockCacheMin = min
+		o.controller.BlockCache = max
+	}
+}
+
+// WithMemoryBudget bounds the memory of the filter by budget, see Controller.MemoryBudget.
+func WithMemoryBudget(budget *MemoryBudget) Option {
+	return func(o *options) {
+		o.controller.MemoryBudget = budget
+	}
+}
+
+// WithPreallocate allocates the blocks of the bloom filter when the file is created, see Controller.Preallocate.
+func WithPreallocate() Option {
+	return func(o *options) {
+		o.controller.Preallocate = true
+	}
+}
+
+// WithReadOnly opens an existing file read-only, see Controller.ReadOnly.
+func WithReadOnly() Option {
+	return func(o *options) {
+		o.controller.ReadOnly = true
+	}
+}
+
+// WithBackend keeps the filter in s instead of a file on the disk, which is named by the filename of Open,
+// see NewOnStorage.
+func WithBackend(s Storage) Option {
+	return func(o *options) {
+		o.backend = s
+	}
+}
+
+// WithoutHash opens an existing file without the hash of its entries, see Controller.NoHash.
+func WithoutHash() Option {
+	return func(o *options) {
+		o.controller.NoHash = true
+	}
+}
+
+// WithFileLock locks the file while it is open, see Controller.FileLock.
+func WithFileLock() Option {
+	return func(o *options) {
+		o.controller.FileLock = true
+	}
+}
+
+// WithJournal makes the adds durable by a write-ahead journal next to the file, see Controller.Journal.
+func WithJournal() Option {
+	return func(o *options) {
+		o.controller.Journal = true
+	}
+}
+
+// WithAckRing records the last adds of ExistOrAddSeq once durable in a ring of slots, see Controller.AckRing.
+func WithAckRing(slots int) Option {
+	return func(o *options) {
+		o.controller.AckRing = slots
+	}
+}
+
+// WithSlowOpTracer invokes the tracer with the operations taking slowOp at least, see Controller.Tracer.
+func WithSlowOpTracer(tracer Tracer, slowOp time.Duration) Option {
+	return func(o *options) {
+		o.controller.Tracer = tracer
+		o.controller.SlowOp = slowOp
+	}
+}
+
+// WithWearLeveling spreads the writes for the flash media, see Controller.WearLeveling.
+func WithWearLeveling() Option {
+	return func(o *options) {
+		o.controller.WearLeveling = true
+	}
+}
+
+// WithDurability sets what the syncs guarantee, see Controller.Durability.
+func WithDurability(level DurabilityLevel) Option {
+	return func(o *options) {
+		o.controller.Durability = level
+	}
+}
+
+// WithSalt mixes a random salt into the double hash of new files, or salt if it is not zero, see Controller.Salted.
+func WithSalt(salt uint64) Option {
+	return func(o *options) {
+		o.controller.Salted = true
+		o.controller.Salt = salt
+	}
+}
+
+// WithDrainTimeout bounds the wait of Close for the operations in flight, see Controller.DrainTimeout.
+func WithDrainTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.controller.DrainTimeout = timeout
+	}
+}
+
+// WithMetadataSync syncs the metadata written by WriteMetadata at the interval, see Controller.MetadataSync.
+func WithMetadataSync(interval time.Duration) Option {
+	return func(o *options) {
+		o.controller.MetadataSync = interval
+	}
+}
+
+// WithTag sets the application-defined tag written in the header of new files.
+func WithTag(tag string) Option {
+	return func(o *options) {
+		o.controller.Tag = tag
+	}
+}
+
+// WithEncryption enables the encryption at rest with an AES key.
+func WithEncryption(key []byte) Option {
+	return func(o *options) {
+		o.controller.EncryptionKey = key
+	}
+}
+
+// WithHMAC enables the HMAC of the header and the metadata, which leaves the metadata out with Control set,
+// see Controller.HMACKey.
+func WithHMAC(key []byte) Option {
+	return func(o *options) {
+		o.controller.HMACKey = key
+	}
+}
+
+// WithDiskFullPolicy sets what ExistOrAdd does when the disk is full, and the optional alarm.
+func WithDiskFullPolicy(policy DiskFullPolicy, onDiskFull func(err error)) Option {
+	return func(o *options) {
+		o.controller.DiskFullPolicy = policy
+		o.controller.OnDiskFull = onDiskFull
+	}
+}
+
+// WithBackgroundErrors reports the errors of the goroutines in the background, see Controller.OnBackgroundError.
+func WithBackgroundErrors(onError func(err error)) Option {
+	return func(o *options) {
+		o.controller.OnBackgroundError = onError
+	}
+}
+
+// WithSyncInterval sets the interval and the jitter of the background goroutine, see Controller.SyncInterval.
+func WithSyncInterval(interval, jitter time.Duration) Option {
+	return func(o *options) {
+		o.controller.SyncInterval = interval
+		o.controller.SyncJitter = jitter
+	}
+}
+
+// WithXattrs writes the extended attributes on new files, see Controller.Xattrs.
+func WithXattrs() Option {
+	return func(o *options) {
+		o.controller.Xattrs = true
+	}
+}
+
+// WithPin keeps a byte range of the bloom filter in memory.
+func WithPin(r Range) Option {
+	return func(o *options) {
+		o.controller.PinnedRange = r
+	}
+}
+
+// WithController starts from a complete Controller, for callers migrating from New.
+// Options following it override its fields.
+func WithController(controller Controller) Option {
+	return func(o *options) {
+		o.controller = controller
+	}
+}
+
+// Open creates or opens a DiskFilter configured by functional options.
+// It is equivalent to New with a Controller assembled from the options.
+// The parameters and the built-in hash of an existing file are restored from its header if not given,
+// so Open(filename) is enough for a file created with a HashKind.
+func Open(filename string, opts ...Option) (*DiskFilter, error) {
+	o := options{
+		controller: Controller{Fsync: FsyncModeEverySec},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.controller.GetParam == nil {
+		if (o.hash == nil && o.hashKind == HashKindCustom && !o.controller.NoHash) || o.bits == 0 || o.slots == 0 {
+			// the missing ones are restored from the header of an existing file
+			if _, err := os.Stat(filename); err != nil && o.backend == nil {
+				return nil, fmt.Errorf("%w: hash and param are required by new files", MissingParamErr)
+			}
+		}
+		param := FilterParam{
+			Slots:    o.slots,
+			Bits:     o.bits,
+			Hash:     o.hash,
+			HashKind: o.hashKind,
+			HashKey:  o.hashKey,
+		}
+		onMetadata := o.onMetadata
+		o.controller.GetParam = func(metadata []byte) (FilterParam, []byte) {
+			if onMetadata == nil {
+				return param, nil
+			}
+			return param, onMetadata(metadata)
+		}
+	}
+	if o.backend != nil {
+		return NewOnStorage(o.backend, filename, o.controller)
+	}
+	return New(filename, o.controller)
+}
@@ -0,0 +1,74 @@
+package disk_bloom
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestOpen(t *testing.T) {
+	if _, err := Open("testfile", WithFsync(FsyncModeNo)); !errors.Is(err, MissingParamErr) {
+		t.Fatalf("Should return MissingParamErr, got %v", err)
+	}
+	var opened [][]byte
+	opts := []Option{
+		WithFsync(FsyncModeNo),
+		WithHash(doubleFNV),
+		WithCapacity(1e4, 1e-4),
+		WithTag("dedup"),
+		WithMetadata(4, func(metadata []byte) []byte {
+			opened = append(opened, metadata)
+			return []byte("meta")
+		}, nil),
+	}
+	bf, err := Open("testfile", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		os.Remove("testfile")
+	}()
+	buf := []byte("testing")
+	bf.ExistOrAdd(buf)
+	bf.Close()
+	if bf.Header().Tag != "dedup" {
+		t.Fatalf("Unexpected tag %q", bf.Header().Tag)
+	}
+	bf, err = Open("testfile", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bf.Close()
+	if !bf.Exist(buf) {
+		t.Fatal("Should exist in filter but got false")
+	}
+	if len(opened) != 2 || opened[0] != nil || string(opened[1]) != "meta" {
+		t.Fatalf("Unexpected metadata %q", opened)
+	}
+}
+
+func TestOpen_Backend(t *testing.T) {
+	s := &MemStorage{}
+	bf, err := Open("mem", WithBackend(s), WithFsync(FsyncModeNo), WithHashKind(HashKindXXHash64, nil), WithCapacity(1e4, 1e-4), WithCache(1<<16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat("mem"); !os.IsNotExist(err) {
+		t.Fatalf("Should not create a file, got %v", err)
+	}
+	buf := []byte("testing")
+	bf.ExistOrAdd(buf)
+	if bf.Controller().BlockCache != 1<<16 {
+		t.Fatal("Should cache the pages")
+	}
+	bf.Close()
+	// the parameters are restored from the header in the storage
+	bf, err = Open("mem", WithBackend(s), WithFsync(FsyncModeNo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bf.Close()
+	if !bf.Exist(buf) {
+		t.Fatal("Should exist in filter but got false")
+	}
+}
@@ -0,0 +1,163 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// partitionedStripes is the number of locks serializing the adds of a PartitionedFilter by the hash.
+const partitionedStripes = 64
+
+// PartitionedFilter splits the bits of one bloom filter across partition files, e.g. on several NVMe devices,
+// and reads or writes the probes of an entry in all of them concurrently.
+// Unlike ShardedFilter, which routes an entry to one shard, every entry is probed in every partition,
+// so a lookup is spread over all the devices.
+type PartitionedFilter struct {
+	parts   []*DiskFilter
+	stripes [partitionedStripes]sync.Mutex
+}
+
+// NewPartitioned creates or opens partitions filter files, the last "*" in the template replaced by the index
+// of the partition, e.g. "/mnt/nvme*/filter".
+// The GetParam of the controller gives the parameters of the whole filter: the probe i of an entry goes to the
+// partition i%partitions, which holds the bits in proportion to its probes, so partitions should not exceed the Slots.
+func NewPartitioned(template string, partitions int, controller Controller) (*PartitionedFilter, error) {
+	i := strings.LastIndex(template, "*")
+	if i < 0 || partitions <= 0 {
+		return nil, fmt.Errorf("%w: a template with \"*\" and partitions are required", MissingParamErr)
+	}
+	p := &PartitionedFilter{}
+	for j := 0; j < partitions; j++ {
+		filename := template[:i] + strconv.Itoa(j) + template[i+1:]
+		c := controller
+		c.GetParam = partitionParam(controller.GetParam, j, partitions)
+		f, err := New(filename, c)
+		if err != nil {
+			_ = p.Close()
+			return nil, fmt.Errorf("partition %v: %w", j, err)
+		}
+		p.parts = append(p.parts, f)
+	}
+	return p, nil
+}
+
+// partitionParam returns the GetParam of the partition j, giving it the probes j, j+partitions, ... of the filter
+// and the bits in proportion to them. The parameters of the existing files are kept.
+func partitionParam(getParam func(metadata []byte) (FilterParam, []byte), j, partitions int) func(metadata []byte) (FilterParam, []byte) {
+	if getParam == nil {
+		return nil
+	}
+	return func(metadata []byte) (FilterParam, []byte) {
+		param, metadata := getParam(metadata)
+		if param.Slots == 0 || param.Bits == 0 {
+			return param, metadata
+		}
+		slots := int(param.Slots)
+		param.Slots = uint8((slots + partitions - j - 1) / partitions)
+		param.Bits = (param.Bits*uint64(param.Slots)/uint64(slots) + 7) / 8 * 8
+		return param, metadata
+	}
+}
+
+// partHash returns the hash probed in the partition j, whose probe i is the probe j+i*partitions of the entry.
+func (p *PartitionedFilter) partHash(h KeyHash, j int) KeyHash {
+	return KeyHash{X: h.X + uint64(j)*h.Y, Y: uint64(len(p.parts)) * h.Y}
+}
+
+// fanOut calls do on every partition concurrently, and returns whether all of them returned true, and the first error.
+func (p *PartitionedFilter) fanOut(h KeyHash, do func(f *DiskFilter, h KeyHash) (bool, error)) (bool, error) {
+	results := make([]bool, len(p.parts))
+	errs := make([]error, len(p.parts))
+	var wg sync.WaitGroup
+	for j := 1; j < len(p.parts); j++ {
+		wg.Add(1)
+		go func(j int) {
+			defer wg.Done()
+			results[j], errs[j] = do(p.parts[j], p.partHash(h, j))
+		}(j)
+	}
+	results[0], errs[0] = do(p.parts[0], p.partHash(h, 0))
+	wg.Wait()
+	all := true
+	for j := range p.parts {
+		if errs[j] != nil {
+			return false, errs[j]
+		}
+		all = all && results[j]
+	}
+	return all, nil
+}
+
+// Partitions returns the partitions, the partition 0 first.
+func (p *PartitionedFilter) Partitions() []*DiskFilter {
+	return append([]*DiskFilter(nil), p.parts...)
+}
+
+// Hash returns the double hash of an entry.
+func (p *PartitionedFilter) Hash(b []byte) KeyHash {
+	return p.parts[0].Hash(b)
+}
+
+// Exist returns if an entry is in the filter
+func (p *PartitionedFilter) Exist(b []byte) bool {
+	return p.ExistHashed(p.Hash(b))
+}
+
+// ExistErr is like Exist, but returns the error if a partition failed to be read.
+func (p *PartitionedFilter) ExistErr(b []byte) (bool, error) {
+	return p.fanOut(p.Hash(b), (*DiskFilter).existHashed)
+}
+
+// ExistHashed is like Exist, but takes the hash of the entry.
+func (p *PartitionedFilter) ExistHashed(h KeyHash) bool {
+	exist, _ := p.fanOut(h, (*DiskFilter).existHashed)
+	return exist
+}
+
+// ExistOrAdd returns whether the entry was in the filter, and adds an entry to the filter if it was not in.
+func (p *PartitionedFilter) ExistOrAdd(b []byte) bool {
+	exist, _ := p.ExistOrAddErr(b)
+	return exist
+}
+
+// ExistOrAddErr is like ExistOrAdd, but returns the error if the entry failed to be added.
+func (p *PartitionedFilter) ExistOrAddErr(b []byte) (bool, error) {
+	h := p.Hash(b)
+	// an entry is added to the partitions one by one, so the adds of the same entry are serialized
+	// to report it new only once
+	mu := &p.stripes[h.X%partitionedStripes]
+	mu.Lock()
+	defer mu.Unlock()
+	return p.fanOut(h, (*DiskFilter).existOrAddHashed)
+}
+
+// FillRatio returns the average fill ratio of the partitions.
+func (p *PartitionedFilter) FillRatio() float64 {
+	var sum float64
+	for _, f := range p.parts {
+		sum += f.FillRatio()
+	}
+	return sum / float64(len(p.parts))
+}
+
+// Size returns the total size of the bloom filters of the partitions in bytes.
+func (p *PartitionedFilter) Size() uint64 {
+	var sum uint64
+	for _, f := range p.parts {
+		sum += f.Size()
+	}
+	return sum
+}
+
+// Close closes every partition, and returns the first error.
+func (p *PartitionedFilter) Close() error {
+	var err error
+	for _, f := range p.parts {
+		if e := f.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,91 @@
+package disk_bloom
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPartitionedFilter(t *testing.T) {
+	dirs := []string{t.TempDir(), t.TempDir(), t.TempDir()}
+	for i, dir := range dirs {
+		if err := os.Symlink(dir, filepath.Join(dirs[0], fmt.Sprint("disk", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	template := filepath.Join(dirs[0], "disk*", "filter")
+	slots, bits := OptimalParam(3000, 1e-3)
+	controller := Controller{
+		Fsync: FsyncModeNo,
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			return FilterParam{Slots: slots, Bits: bits, HashKind: HashKindXXHash64}, nil
+		},
+	}
+	p, err := NewPartitioned(template, 3, controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sumSlots uint8
+	for _, f := range p.Partitions() {
+		sumSlots += f.FilterParam().Slots
+	}
+	if sumSlots != slots {
+		t.Fatalf("the partitions should share %v slots, got %v", slots, sumSlots)
+	}
+	if p.Size() < bits/8 || p.Size() > bits/8+3 {
+		t.Fatalf("the partitions should share %v bytes, got %v", bits/8, p.Size())
+	}
+	var existed int
+	for i := 0; i < 3000; i++ {
+		if p.ExistOrAdd([]byte(fmt.Sprint(i))) {
+			existed++
+		}
+	}
+	if existed > 30 {
+		t.Fatalf("too many entries existed before added: %v", existed)
+	}
+	for i := 0; i < 3000; i++ {
+		if !p.ExistOrAdd([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%v should exist once added", i)
+		}
+	}
+	for _, f := range p.Partitions() {
+		if f.FillRatio() < 0.3 {
+			t.Fatalf("every partition should be filled, got %v", f.FillRatio())
+		}
+	}
+	if err = p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for i, dir := range dirs {
+		if _, err = os.Stat(filepath.Join(dir, "filter")); err != nil {
+			t.Fatalf("partition %v should be placed by the template: %v", i, err)
+		}
+	}
+
+	p, err = NewPartitioned(template, 3, Controller{Fsync: FsyncModeNo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	var falsePositives int
+	for i := 0; i < 3000; i++ {
+		if !p.Exist([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%v should exist after reopened", i)
+		}
+		if p.Exist([]byte(fmt.Sprint("absent", i))) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 30 {
+		t.Fatalf("too many false positives: %v", falsePositives)
+	}
+}
+
+func TestPartitionedFilter_Invalid(t *testing.T) {
+	if _, err := NewPartitioned(filepath.Join(t.TempDir(), "filter"), 2, Controller{}); !errors.Is(err, MissingParamErr) {
+		t.Fatalf("a template without \"*\" should be rejected, got %v", err)
+	}
+}
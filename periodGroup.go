@@ -0,0 +1,281 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Period is the calendar period of a filter of a PeriodGroup, in UTC.
+type Period uint8
+
+const (
+	// PeriodHourly names the filters like 2006-01-02T15.
+	PeriodHourly Period = iota
+	// PeriodDaily names the filters like 2006-01-02.
+	PeriodDaily
+	// PeriodWeekly names the filters by the ISO week like 2006-W01.
+	PeriodWeekly
+)
+
+func (p Period) String() string {
+	switch p {
+	case PeriodHourly:
+		return "hourly"
+	case PeriodDaily:
+		return "daily"
+	case PeriodWeekly:
+		return "weekly"
+	default:
+		return "invalid"
+	}
+}
+
+// start returns the start of the period containing t.
+func (p Period) start(t time.Time) time.Time {
+	t = t.UTC()
+	switch p {
+	case PeriodHourly:
+		return t.Truncate(time.Hour)
+	case PeriodWeekly:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		// ISO weeks start on Monday
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// add returns the start of the period n periods after the one starting at start.
+func (p Period) add(start time.Time, n int) time.Time {
+	switch p {
+	case PeriodHourly:
+		return start.Add(time.Duration(n) * time.Hour)
+	case PeriodWeekly:
+		return start.AddDate(0, 0, 7*n)
+	default:
+		return start.AddDate(0, 0, n)
+	}
+}
+
+// name returns the name of the period starting at start, which sorts in time.
+func (p Period) name(start time.Time) string {
+	switch p {
+	case PeriodHourly:
+		return start.Format("2006-01-02T15")
+	case PeriodWeekly:
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	default:
+		return start.Format("2006-01-02")
+	}
+}
+
+// parse returns the start of the period of the name, or false if it is not a name of the period.
+func (p Period) parse(name string) (time.Time, bool) {
+	switch p {
+	case PeriodHourly:
+		t, err := time.Parse("2006-01-02T15", name)
+		return t, err == nil
+	case PeriodWeekly:
+		if len(name) != len("2006-W01") || name[4:6] != "-W" {
+			return time.Time{}, false
+		}
+		year, err1 := strconv.Atoi(name[:4])
+		week, err2 := strconv.Atoi(name[6:])
+		if err1 != nil || err2 != nil || week < 1 || week > 53 {
+			return time.Time{}, false
+		}
+		// January 4th is always in the first ISO week
+		start := p.add(p.start(time.Date(year, 1, 4, 0, 0, 0, 0, time.UTC)), week-1)
+		return start, p.name(start) == name
+	default:
+		t, err := time.Parse("2006-01-02", name)
+		return t, err == nil
+	}
+}
+
+// periodFilter is a filter of a PeriodGroup.
+type periodFilter struct {
+	start  time.Time
+	filter *DiskFilter
+}
+
+// PeriodGroup is a group of filters keyed by calendar period, e.g. one per day: the entries are added to the filter
+// of the current period, which is created once the clock enters it, and are looked up in the filters of the last
+// retention periods. The files of the older periods are removed.
+type PeriodGroup struct {
+	dir        string
+	period     Period
+	retention  int
+	controller Controller
+	clock      Clock
+
+	// mu is read-locked by the lookups and the adds, and locked by the rotation
+	mu sync.RWMutex
+	// filters are sorted by the period, the current one last
+	filters []periodFilter
+	closed  bool
+}
+
+// NewPeriodGroup opens the filters of the last retention periods in dir, named by the period, e.g. 2006-01-02
+// for PeriodDaily, and removes the files of the older periods. Every filter is opened with the controller,
+// whose GetParam gives the parameters of a filter, and whose Clock decides the current period.
+func NewPeriodGroup(dir string, period Period, retention int, controller Controller) (*PeriodGroup, error) {
+	if period > PeriodWeekly || retention <= 0 {
+		return nil, fmt.Errorf("%w: a period and a positive retention are required", MissingParamErr)
+	}
+	clock := controller.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+	g := &PeriodGroup{dir: dir, period: period, retention: retention, controller: controller, clock: clock}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	current := period.start(clock.Now())
+	for _, e := range entries {
+		start, ok := period.parse(e.Name())
+		if !ok || e.IsDir() || start.After(current) {
+			continue
+		}
+		f, err := New(filepath.Join(dir, e.Name()), controller)
+		if err != nil {
+			_ = g.Close()
+			return nil, err
+		}
+		g.filters = append(g.filters, periodFilter{start: start, filter: f})
+	}
+	sort.Slice(g.filters, func(i, j int) bool {
+		return g.filters[i].start.Before(g.filters[j].start)
+	})
+	if err = g.rotateLocked(current); err != nil {
+		_ = g.Close()
+		return nil, err
+	}
+	return g, nil
+}
+
+// rotateLocked activates the filter of the period starting at current, and expires the filters out of the retention.
+func (g *PeriodGroup) rotateLocked(current time.Time) error {
+	if n := len(g.filters); n == 0 || g.filters[n-1].start.Before(current) {
+		f, err := New(filepath.Join(g.dir, g.period.name(current)), g.controller)
+		if err != nil {
+			return err
+		}
+		g.filters = append(g.filters, periodFilter{start: current, filter: f})
+	}
+	oldest := g.period.add(current, 1-g.retention)
+	for len(g.filters) > 0 && g.filters[0].start.Before(oldest) {
+		expired := g.filters[0]
+		g.filters = g.filters[1:]
+		_ = expired.filter.Close()
+		if err := os.Remove(filepath.Join(g.dir, g.period.name(expired.start))); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// load returns the filters, rotating them first if the clock entered a new period.
+// It returns with the read lock held.
+func (g *PeriodGroup) load() ([]periodFilter, error) {
+	current := g.period.start(g.clock.Now())
+	g.mu.RLock()
+	if g.closed {
+		g.mu.RUnlock()
+		return nil, ClosedErr
+	}
+	if !g.filters[len(g.filters)-1].start.Before(current) {
+		return g.filters, nil
+	}
+	g.mu.RUnlock()
+	g.mu.Lock()
+	err := g.rotateLocked(current)
+	g.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return g.load()
+}
+
+// Current returns the filter of the current period.
+func (g *PeriodGroup) Current() (*DiskFilter, error) {
+	filters, err := g.load()
+	if err != nil {
+		return nil, err
+	}
+	defer g.mu.RUnlock()
+	return filters[len(filters)-1].filter, nil
+}
+
+// Periods returns the names of the periods of the filters, the oldest first and the current one last.
+func (g *PeriodGroup) Periods() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	names := make([]string, len(g.filters))
+	for i, f := range g.filters {
+		names[i] = g.period.name(f.start)
+	}
+	return names
+}
+
+// Exist returns if an entry is in any filter of the retention.
+func (g *PeriodGroup) Exist(b []byte) bool {
+	filters, err := g.load()
+	if err != nil {
+		return false
+	}
+	defer g.mu.RUnlock()
+	h := filters[0].filter.Hash(b)
+	for i := len(filters) - 1; i >= 0; i-- {
+		if filters[i].filter.ExistHashed(h) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExistOrAdd returns whether the entry was in any filter of the retention, and adds it to the filter of the current
+// period if it was not in.
+func (g *PeriodGroup) ExistOrAdd(b []byte) bool {
+	exist, _ := g.ExistOrAddErr(b)
+	return exist
+}
+
+// ExistOrAddErr is like ExistOrAdd, but returns the error if the entry failed to be added.
+func (g *PeriodGroup) ExistOrAddErr(b []byte) (bool, error) {
+	filters, err := g.load()
+	if err != nil {
+		return false, err
+	}
+	defer g.mu.RUnlock()
+	current := filters[len(filters)-1].filter
+	h := current.Hash(b)
+	for _, f := range filters[:len(filters)-1] {
+		if f.filter.ExistHashed(h) {
+			return true, nil
+		}
+	}
+	return current.existOrAddHashed(h)
+}
+
+// Close closes all filters of the group, and returns the first error.
+func (g *PeriodGroup) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.closed = true
+	var err error
+	for _, f := range g.filters {
+		if e := f.filter.Close(); err == nil {
+			err = e
+		}
+	}
+	g.filters = nil
+	return err
+}
@@ -0,0 +1,88 @@
+package disk_bloom
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPeriod(t *testing.T) {
+	// a Thursday
+	now := time.Date(2026, 1, 1, 13, 30, 0, 0, time.UTC)
+	for _, c := range []struct {
+		period Period
+		name   string
+		start  time.Time
+	}{
+		{PeriodHourly, "2026-01-01T13", time.Date(2026, 1, 1, 13, 0, 0, 0, time.UTC)},
+		{PeriodDaily, "2026-01-01", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{PeriodWeekly, "2026-W01", time.Date(2025, 12, 29, 0, 0, 0, 0, time.UTC)},
+	} {
+		start := c.period.start(now)
+		if !start.Equal(c.start) || c.period.name(start) != c.name {
+			t.Fatalf("the %v period of %v should be %v from %v, got %v from %v", c.period, now, c.name, c.start, c.period.name(start), start)
+		}
+		if parsed, ok := c.period.parse(c.name); !ok || !parsed.Equal(start) {
+			t.Fatalf("%v should be parsed as %v, got %v, %v", c.name, start, parsed, ok)
+		}
+	}
+	if _, ok := PeriodWeekly.parse("2026-W60"); ok {
+		t.Fatal("Should reject an invalid week")
+	}
+}
+
+func TestPeriodGroup(t *testing.T) {
+	dir := t.TempDir()
+	clock := NewManualClock(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	controller := Controller{
+		Fsync: FsyncModeNo,
+		Clock: clock,
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			slots, bits := OptimalParam(1000, 1e-4)
+			return FilterParam{Slots: slots, Bits: bits, HashKind: HashKindXXHash64}, nil
+		},
+	}
+	g, err := NewPeriodGroup(dir, PeriodDaily, 2, controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.ExistOrAdd([]byte("a")) || !g.ExistOrAdd([]byte("a")) {
+		t.Fatal("a should be added once")
+	}
+	clock.Advance(24 * time.Hour)
+	if !g.ExistOrAdd([]byte("a")) {
+		t.Fatal("a should exist in the retention")
+	}
+	g.ExistOrAdd([]byte("b"))
+	if got := g.Periods(); !reflect.DeepEqual(got, []string{"2026-10-16", "2026-10-17"}) {
+		t.Fatalf("Unexpected periods %v", got)
+	}
+	if err = g.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if g.Exist([]byte("a")) {
+		t.Fatal("a closed group should report no entries")
+	}
+
+	// reopened after the first period expired
+	clock.Advance(24 * time.Hour)
+	if g, err = NewPeriodGroup(dir, PeriodDaily, 2, controller); err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	if g.Exist([]byte("a")) || !g.Exist([]byte("b")) {
+		t.Fatal("only the entries of the retention should exist")
+	}
+	if _, err = os.Stat(filepath.Join(dir, "2026-10-16")); !os.IsNotExist(err) {
+		t.Fatalf("the file of the expired period should be removed, got %v", err)
+	}
+	if got := g.Periods(); !reflect.DeepEqual(got, []string{"2026-10-17", "2026-10-18"}) {
+		t.Fatalf("Unexpected periods %v", got)
+	}
+	current, err := g.Current()
+	if err != nil || current.Describe().Filename != filepath.Join(dir, "2026-10-18") {
+		t.Fatalf("Unexpected current filter %v", err)
+	}
+}
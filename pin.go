@@ -0,0 +1,84 @@
+package disk_bloom
+
+import "fmt"
+
+var InvalidRangeErr = fmt.Errorf("invalid range")
+
+// Range is a byte range of the bloom filter, relative to its beginning.
+type Range struct {
+	Offset uint64
+	Length uint64
+}
+
+// pinned keeps a copy of a byte range of the bloom filter in memory.
+type pinned struct {
+	// start is the file offset of the first byte of buf
+	start int64
+	buf   []byte
+}
+
+func (p *pinned) contains(pos int64) bool {
+	return p != nil && pos >= p.start && pos < p.start+int64(len(p.buf))
+}
+
+// Pin keeps the given byte range of the bloom filter in memory, so lookups falling into the range
+// never touch the disk while the rest of the filter is still demand-paged.
+// Writes go through to the disk. Only one range can be pinned, and pinning a new range replaces the old one.
+// A zero-length range unpins.
+func (f *DiskFilter) Pin(r Range) error {
+	// including the trailing byte of the bits not a multiple of 8
+	size := (f.param.Bits + 7) / 8
+	if r.Offset+r.Length > size || r.Offset+r.Length < r.Offset {
+		return fmt.Errorf("%w: %v+%v is beyond the size %v", InvalidRangeErr, r.Offset, r.Length, size)
+	}
+	if !f.acquire() {
+		return ClosedErr
+	}
+	defer f.release()
+	f.file.mu.Lock()
+	defer f.file.mu.Unlock()
+	f.unpinLocked()
+	if r.Length == 0 {
+		return nil
+	}
+	if !f.controller.MemoryBudget.reserve(int64(r.Length)) {
+		return fmt.Errorf("%w: pinning %v bytes", MemoryBudgetErr, r.Length)
+	}
+	p := &pinned{
+		start: f.fileOffset(int64(r.Offset)),
+		buf:   make([]byte, r.Length),
+	}
+	if _, err := f.file.rw.ReadAt(p.buf, p.start); err != nil {
+		f.controller.MemoryBudget.release(int64(r.Length))
+		return err
+	}
+	for pos, val := range f.pending {
+		if p.contains(pos) {
+			p.buf[pos-p.start] |= val
+		}
+	}
+	f.pinned = p
+	return nil
+}
+
+// Pinned returns the pinned byte range.
+func (f *DiskFilter) Pinned() Range {
+	f.file.mu.Lock()
+	defer f.file.mu.Unlock()
+	if f.pinned == nil {
+		return Range{}
+	}
+	return Range{
+		Offset: uint64(f.pinned.start - f.fileOffset(0)),
+		Length: uint64(len(f.pinned.buf)),
+	}
+}
+
+// unpinLocked unpins the pinned range, and releases its memory.
+func (f *DiskFilter) unpinLocked() {
+	if f.pinned == nil {
+		return
+	}
+	f.controller.MemoryBudget.release(int64(len(f.pinned.buf)))
+	f.pinned = nil
+}
@@ -0,0 +1,48 @@
+package disk_bloom
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDiskFilter_Pin(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo})
+	buf := []byte("testing")
+	bf.ExistOrAdd(buf)
+	if err := bf.Pin(Range{Offset: 0, Length: bf.Size()}); err != nil {
+		t.Fatal(err)
+	}
+	other := []byte("other")
+	bf.ExistOrAdd(other)
+	// wipe the disk behind the pinned copy
+	if _, err := bf.file.f.WriteAt(make([]byte, bf.Size()), bf.fileOffset(0)); err != nil {
+		t.Fatal(err)
+	}
+	if !bf.Exist(buf) || !bf.Exist(other) {
+		t.Fatal("Should be served by the pinned copy but got false")
+	}
+	if err := bf.Pin(Range{}); err != nil {
+		t.Fatal(err)
+	}
+	if bf.Exist(buf) {
+		t.Fatal("Should read the wiped disk after unpinning but got true")
+	}
+	if err := bf.Pin(Range{Offset: 1, Length: (bf.param.Bits + 7) / 8}); !errors.Is(err, InvalidRangeErr) {
+		t.Fatalf("Should return InvalidRangeErr, got %v", err)
+	}
+}
+
+func TestDiskFilter_PinTrailingByte(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo})
+	size := (bf.param.Bits + 7) / 8
+	if size == bf.Size() {
+		t.Skip("the bits are a multiple of 8")
+	}
+	if err := bf.Pin(Range{Offset: 0, Length: size}); err != nil {
+		t.Fatalf("Should pin the trailing byte, got %v", err)
+	}
+	bf.Close()
+	if err := bf.Pin(Range{Offset: 0, Length: 1}); !errors.Is(err, ClosedErr) {
+		t.Fatalf("Should return ClosedErr, got %v", err)
+	}
+}
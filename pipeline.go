@@ -0,0 +1,141 @@
+package disk_bloom
+
+import (
+	"sync"
+	"time"
+)
+
+// Pipeline answers ExistOrAdd asynchronously: the keys submitted are queued, and a background worker coalesces them
+// into batches of ExistOrAddBatchErr, whose probed bytes are sorted across the keys and read page by page,
+// trading the latency of each key for the throughput, e.g. for the deduplication of crawls.
+// The answers of a key repeated in a batch are as if they were added in the order submitted.
+type Pipeline struct {
+	f        *DiskFilter
+	maxBatch int
+	linger   time.Duration
+
+	queue chan pipelineOp
+	done  chan struct{}
+	// mu guards closed against the submits racing Close
+	mu     sync.RWMutex
+	closed bool
+}
+
+type pipelineOp struct {
+	key []byte
+	fn  func(exist bool, err error)
+}
+
+// NewPipeline starts a Pipeline of f, which adds up to maxBatch keys at once, and waits at most linger
+// after the first key of a batch for more keys to coalesce. The submits block once 2*maxBatch keys are queued.
+func NewPipeline(f *DiskFilter, maxBatch int, linger time.Duration) *Pipeline {
+	if maxBatch < 1 {
+		maxBatch = 1
+	}
+	p := &Pipeline{
+		f:        f,
+		maxBatch: maxBatch,
+		linger:   linger,
+		queue:    make(chan pipelineOp, 2*maxBatch),
+		done:     make(chan struct{}),
+	}
+	go p.work()
+	return p
+}
+
+// Submit queues b to be added, and returns the channel receiving whether it was in the filter.
+// It receives false if b failed to be added, see SubmitFunc for the error.
+func (p *Pipeline) Submit(b []byte) <-chan bool {
+	result := make(chan bool, 1)
+	p.SubmitFunc(b, func(exist bool, err error) {
+		result <- exist
+	})
+	return result
+}
+
+// SubmitFunc queues b to be added, and calls fn with whether it was in the filter, or the error of adding it,
+// from the worker of the Pipeline. fn should not block, since it delays the following batches.
+// fn is called with ClosedErr if the Pipeline is closed.
+func (p *Pipeline) SubmitFunc(b []byte, fn func(exist bool, err error)) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		fn(false, ClosedErr)
+		return
+	}
+	p.queue <- pipelineOp{key: append([]byte(nil), b...), fn: fn}
+}
+
+// Close answers the keys queued, and stops the worker. The filter is not closed.
+func (p *Pipeline) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.queue)
+	p.mu.Unlock()
+	<-p.done
+	return nil
+}
+
+func (p *Pipeline) work() {
+	defer close(p.done)
+	ops := make([]pipelineOp, 0, p.maxBatch)
+	keys := make([][]byte, 0, p.maxBatch)
+	timer := time.NewTimer(p.linger)
+	timer.Stop()
+	for {
+		op, ok := <-p.queue
+		if !ok {
+			return
+		}
+		ops = append(ops[:0], op)
+		timer.Reset(p.linger)
+		open := p.gather(&ops, timer)
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		keys = keys[:0]
+		for _, op := range ops {
+			keys = append(keys, op.key)
+		}
+		exist, err := p.f.ExistOrAddBatchErr(keys)
+		for i, op := range ops {
+			if err != nil {
+				op.fn(false, err)
+			} else {
+				op.fn(exist[i], nil)
+			}
+		}
+		if !open {
+			// the rest of the queue
+			for op := range p.queue {
+				exist, err := p.f.ExistOrAddErr(op.key)
+				op.fn(exist, err)
+			}
+			return
+		}
+	}
+}
+
+// gather appends the queued keys to ops until the batch is full or the linger expires,
+// and returns false if the queue is closed.
+func (p *Pipeline) gather(ops *[]pipelineOp, timer *time.Timer) bool {
+	for len(*ops) < p.maxBatch {
+		select {
+		case op, ok := <-p.queue:
+			if !ok {
+				return false
+			}
+			*ops = append(*ops, op)
+		case <-timer.C:
+			return true
+		}
+	}
+	return true
+}
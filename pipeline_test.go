@@ -0,0 +1,60 @@
+package disk_bloom
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPipeline(t *testing.T) {
+	defer os.Remove("testfile")
+	bf, err := New("testfile", Controller{Debug: true, GetParam: func([]byte) (FilterParam, []byte) {
+		return FilterParam{Slots: 4, Bits: 1 << 20, Hash: doubleFNV}, nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bf.Close()
+	p := NewPipeline(bf, 64, 10*time.Millisecond)
+	results := make([]<-chan bool, 200)
+	for i := range results {
+		results[i] = p.Submit([]byte(strconv.Itoa(i % 100)))
+	}
+	for i, result := range results {
+		if exist := <-result; exist != (i >= 100) {
+			t.Fatalf("%v: got %v, want %v", i, exist, i >= 100)
+		}
+	}
+
+	// the submits racing Close are answered
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	answered := 0
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			p.SubmitFunc([]byte(strconv.Itoa(1000+i)), func(exist bool, err error) {
+				mu.Lock()
+				answered++
+				mu.Unlock()
+				wg.Done()
+			})
+		}(i)
+	}
+	_ = p.Close()
+	wg.Wait()
+	if answered != 100 {
+		t.Fatalf("Should answer all submits, answered %v", answered)
+	}
+	p.SubmitFunc([]byte("closed"), func(exist bool, err error) {
+		if !errors.Is(err, ClosedErr) {
+			t.Fatalf("Should be ClosedErr, got %v", err)
+		}
+	})
+	if bf.Exist([]byte("closed")) {
+		t.Fatal("Should not add after Close")
+	}
+}
@@ -0,0 +1,53 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"math"
+)
+
+var InvalidParamErr = fmt.Errorf("invalid parameters")
+
+// ParamPlan is the plan of a filter for n expected entries and a target false positive rate, see PlanParam.
+type ParamPlan struct {
+	Slots uint8
+	Bits  uint64
+	// Bytes is the size of the bits in bytes
+	Bytes uint64
+	// FPR is the false positive rate expected after n entries are added, which is worse than the target if Clamped
+	FPR float64
+	// Clamped is whether Slots or Bits are clamped to the range of the filter: the target rate below 1e-77
+	// needs more than 255 slots, and a filter has 8 bits at least
+	Clamped bool
+}
+
+// PlanParam returns the parameters optimal for n expected entries and the expected false positive rate p,
+// with the false positive rate they achieve. It returns InvalidParamErr if n is 0, p is not in (0, 1),
+// or the bits needed exceed the range of a file.
+func PlanParam(n uint64, p float64) (ParamPlan, error) {
+	if n == 0 {
+		return ParamPlan{}, fmt.Errorf("%w: no entries are expected", InvalidParamErr)
+	}
+	if !(p > 0 && p < 1) {
+		return ParamPlan{}, fmt.Errorf("%w: the false positive rate %v is not in (0, 1)", InvalidParamErr, p)
+	}
+	k := -math.Log(p) * math.Log2E   // number of hashes
+	m := float64(n) * k * math.Log2E // number of bits
+	if m >= math.MaxInt64 {
+		return ParamPlan{}, fmt.Errorf("%w: %v bits of %v entries at %v exceed the range of a file", InvalidParamErr, m, n, p)
+	}
+	var plan ParamPlan
+	switch {
+	case k+0.5 < 1:
+		plan.Slots, plan.Clamped = 1, true
+	case k+0.5 >= math.MaxUint8+1:
+		plan.Slots, plan.Clamped = math.MaxUint8, true
+	default:
+		plan.Slots = uint8(k + 0.5)
+	}
+	if plan.Bits = uint64(m / 8 * 8); plan.Bits < 8 {
+		plan.Bits, plan.Clamped = 8, true
+	}
+	plan.Bytes = (plan.Bits + 7) / 8
+	plan.FPR = EstimateFPR(plan.Slots, plan.Bits, n)
+	return plan, nil
+}
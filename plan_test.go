@@ -0,0 +1,37 @@
+package disk_bloom
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestPlanParam(t *testing.T) {
+	plan, err := PlanParam(1e6, 1e-4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slots, bits := OptimalParam(1e6, 1e-4); plan.Slots != slots || plan.Bits != bits || plan.Clamped {
+		t.Fatalf("Unexpected plan %+v, which should be %v slots and %v bits", plan, slots, bits)
+	}
+	if plan.Bytes != (plan.Bits+7)/8 || math.Abs(plan.FPR-1e-4) > 1e-5 {
+		t.Fatalf("Unexpected plan %+v", plan)
+	}
+	for _, c := range []struct {
+		n uint64
+		p float64
+	}{{0, 1e-4}, {1e6, 0}, {1e6, 1}, {1e6, -1}, {1e6, math.NaN()}, {math.MaxUint64, 1e-9}} {
+		if _, err = PlanParam(c.n, c.p); !errors.Is(err, InvalidParamErr) {
+			t.Fatalf("Should reject n %v and p %v, got %v", c.n, c.p, err)
+		}
+		if slots, bits := OptimalParam(c.n, c.p); slots != 0 || bits != 0 {
+			t.Fatalf("Should return zeros for n %v and p %v, got %v and %v", c.n, c.p, slots, bits)
+		}
+	}
+	if plan, err = PlanParam(10, 1e-200); err != nil || plan.Slots != math.MaxUint8 || !plan.Clamped {
+		t.Fatalf("Should clamp the slots, got %+v, %v", plan, err)
+	}
+	if plan, err = PlanParam(1, 0.9); err != nil || plan.Slots != 1 || plan.Bits != 8 || !plan.Clamped || plan.FPR >= 0.9 {
+		t.Fatalf("Should clamp the slots and the bits, got %+v, %v", plan, err)
+	}
+}
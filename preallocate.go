@@ -0,0 +1,26 @@
+package disk_bloom
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// preallocate allocates the range of the file by fallocate, or by writing zeros if it is not supported.
+// The range must be all zeros.
+func preallocate(f *os.File, offset int64, size int64) error {
+	err := fallocate(f, offset, size)
+	if err == nil || !errors.Is(err, UnsupportedErr) && !errors.Is(err, syscall.EOPNOTSUPP) {
+		return err
+	}
+	zero := make([]byte, 1<<20)
+	for done := int64(0); done < size; done += int64(len(zero)) {
+		if size-done < int64(len(zero)) {
+			zero = zero[:size-done]
+		}
+		if _, err = (retryStorage{f}).WriteAt(zero, offset+done); err != nil {
+			return err
+		}
+	}
+	return nil
+}
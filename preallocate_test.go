@@ -0,0 +1,43 @@
+package disk_bloom
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"testing"
+)
+
+func TestDiskFilter_Preallocate(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the allocated size is only known on Linux")
+	}
+	defer os.Remove("testfile")
+	for _, preallocate := range []bool{false, true} {
+		os.Remove("testfile")
+		bf, err := New("testfile", Controller{
+			Fsync:       FsyncModeNo,
+			Preallocate: preallocate,
+			GetParam: func(metadata []byte) (FilterParam, []byte) {
+				return FilterParam{Slots: 4, Bits: 1 << 23, Hash: doubleFNV}, nil
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := bf.header.bloomSize(bf.param.Bits)
+		allocated, err := allocatedSize(bf.file.osFile())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if preallocate != (allocated >= size) {
+			t.Fatalf("Preallocate %v: unexpected %v bytes allocated of %v", preallocate, allocated, size)
+		}
+		if bf.ExistOrAdd([]byte("testing")) || !bf.Exist([]byte("testing")) {
+			t.Fatal("Should be added")
+		}
+		if _, err = bf.Compact(); preallocate && !errors.Is(err, UnsupportedErr) {
+			t.Fatalf("Should not compact a preallocated filter, got %v", err)
+		}
+		bf.Close()
+	}
+}
@@ -0,0 +1,87 @@
+package disk_bloom
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// defaultProbeOrderEvery is the number of lookups between the reorders of WithAdaptiveProbeOrder by default.
+const defaultProbeOrderEvery = 4096
+
+// probeOrder orders the filters of a group by their hits, so that the lookups probe the filter most likely to have
+// the entry first. It is reordered every so many lookups, when the hits are halved, so that the order follows the
+// windows the duplicates currently cluster in.
+type probeOrder struct {
+	// lookups is accessed atomically. It is the first field to be 64-bit aligned.
+	lookups uint64
+	every   uint64
+	// current is the *orderedFilters of the latest filters
+	current atomic.Value
+}
+
+type orderedFilters struct {
+	filters []*filterObj
+	order   []*filterObj
+}
+
+// WithAdaptiveProbeOrder makes the lookups probe the filters by their hits, the most hit first, instead of the oldest first,
+// which saves probes of the filters missing the entry if the duplicates cluster in some windows.
+// The filters are reordered every `every` lookups, 4096 if it is 0, when the hits decay by half. See Member.Hits.
+// The order of ExistOrAdd only applies to the older filters, since the active one adds the entries missing in all of them.
+func WithAdaptiveProbeOrder(every uint64) GroupOption {
+	return func(g *FilterGroup) {
+		if every == 0 {
+			every = defaultProbeOrderEvery
+		}
+		g.order = &probeOrder{every: every}
+	}
+}
+
+// probeOrder returns the filters in the order to probe.
+func (g *FilterGroup) probeOrder(filters []*filterObj) []*filterObj {
+	if g.order == nil {
+		return filters
+	}
+	return g.order.get(filters)
+}
+
+// hit records that the filter has the entry looked up.
+func (g *FilterGroup) hit(f *filterObj) {
+	if g.order != nil {
+		atomic.AddUint64(&f.hits, 1)
+	}
+}
+
+// get returns the order of filters, which is computed again if the filters are replaced, or it is time to reorder.
+func (o *probeOrder) get(filters []*filterObj) []*filterObj {
+	cur, _ := o.current.Load().(*orderedFilters)
+	stale := cur == nil || !sameFilters(cur.filters, filters)
+	if n := atomic.AddUint64(&o.lookups, 1); stale || n%o.every == 0 {
+		cur = &orderedFilters{filters: filters, order: sortByHits(filters, !stale)}
+		o.current.Store(cur)
+	}
+	return cur.order
+}
+
+// sortByHits returns the filters by their hits, the most hit first and the oldest first among the ties,
+// and halves the hits if decay.
+func sortByHits(filters []*filterObj, decay bool) []*filterObj {
+	hits := make(map[*filterObj]uint64, len(filters))
+	for _, f := range filters {
+		h := atomic.LoadUint64(&f.hits)
+		hits[f] = h
+		if decay && h > 1 {
+			atomic.AddUint64(&f.hits, -(h / 2))
+		}
+	}
+	order := append([]*filterObj(nil), filters...)
+	sort.SliceStable(order, func(i, j int) bool {
+		return hits[order[i]] > hits[order[j]]
+	})
+	return order
+}
+
+// sameFilters returns whether a and b are the same slice of filters, which is replaced instead of modified.
+func sameFilters(a, b []*filterObj) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
@@ -0,0 +1,65 @@
+package disk_bloom
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFilterGroup_AdaptiveProbeOrder(t *testing.T) {
+	g, err := NewGroup(filepath.Join(t.TempDir(), "*"), FsyncModeNo, 1000, 1e-3, doubleFNV, WithAdaptiveProbeOrder(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	for i := 0; i < 3; i++ {
+		g.ExistOrAdd([]byte{byte(i)})
+		if err = g.RotateNow(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g.tasks.wait()
+	filters := g.load()
+	if order := g.probeOrder(filters); order[0] != filters[0] {
+		t.Fatal("Should probe the oldest first without hits")
+	}
+	// the duplicates cluster in the window of 2
+	for i := 0; i < 16; i++ {
+		if !g.Exist([]byte{2}) {
+			t.Fatal("Should exist")
+		}
+	}
+	if order := g.probeOrder(filters); order[0] != filters[2] {
+		t.Fatalf("Should probe the most hit first, got %v", order[0].filename)
+	}
+	members := g.Members()
+	if members[2].Hits == 0 || members[2].Hits >= 16 || members[0].Hits != 0 {
+		t.Fatalf("Should decay the hits, got %+v", members)
+	}
+	// ExistOrAdd still adds to the active filter only
+	if g.ExistOrAdd([]byte{9}) || !filters[3].filter.Exist([]byte{9}) {
+		t.Fatal("Should add to the active filter")
+	}
+	if !g.ExistOrAdd([]byte{0}) {
+		t.Fatal("Should find the entry of the oldest filter")
+	}
+}
+
+func TestFilterGroup_ProbeOrderRotation(t *testing.T) {
+	g, err := NewGroup(filepath.Join(t.TempDir(), "*"), FsyncModeNo, 1000, 1e-3, doubleFNV, WithAdaptiveProbeOrder(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	g.ExistOrAdd([]byte("key"))
+	if !g.Exist([]byte("key")) {
+		t.Fatal("Should exist")
+	}
+	if err = g.RotateNow(); err != nil {
+		t.Fatal(err)
+	}
+	g.tasks.wait()
+	// the order of the replaced filters is not used
+	if order := g.probeOrder(g.load()); len(order) != 2 || !g.Exist([]byte("key")) {
+		t.Fatalf("Should order the filters after the rotation, got %v", len(order))
+	}
+}
@@ -0,0 +1,119 @@
+package disk_bloom
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var NotSealedErr = fmt.Errorf("filter is not sealed")
+
+// Manifest describes a published filter file, so that consumers can verify and open it.
+type Manifest struct {
+	// Filename is the base name of the file
+	Filename string `json:"filename"`
+	// Size of the file in bytes
+	Size int64 `json:"size"`
+	// SHA256 of the file in hex
+	SHA256        string `json:"sha256"`
+	HeaderVersion uint16 `json:"header_version"`
+	Flags         uint16 `json:"flags"`
+	Tag           string `json:"tag,omitempty"`
+	MetadataSize  uint16 `json:"metadata_size"`
+	Slots         uint8  `json:"slots"`
+	Bits          uint64 `json:"bits"`
+}
+
+// publisher serves a sealed filter file and its manifest.
+type publisher struct {
+	filename string
+	modTime  time.Time
+	manifest Manifest
+	etag     string
+}
+
+// Publish returns a read-only http.Handler publishing the sealed filter:
+// GET /filter serves the file, supporting range requests, and GET /manifest.json serves its Manifest.
+// Mount it with http.StripPrefix to publish under a path. It returns NotSealedErr if the filter is not sealed,
+// since the file must not change once its checksum is published.
+func Publish(f *DiskFilter) (http.Handler, error) {
+	header := f.Header()
+	if !header.Sealed() {
+		return nil, NotSealedErr
+	}
+	if f.file.osFile() == nil {
+		return nil, fmt.Errorf("%w: publishing filters opened by OpenFS", UnsupportedErr)
+	}
+	filename := f.file.f.Name()
+	manifest, modTime, err := newManifest(filename, header, f.controller.MetadataSize, f.FilterParam())
+	if err != nil {
+		return nil, err
+	}
+	return &publisher{
+		filename: filename,
+		modTime:  modTime,
+		manifest: manifest,
+		etag:     `"` + manifest.SHA256 + `"`,
+	}, nil
+}
+
+// newManifest returns the Manifest of a filter file, and its modification time.
+func newManifest(filename string, header Header, metadataSize uint16, param FilterParam) (Manifest, time.Time, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return Manifest{}, time.Time{}, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return Manifest{}, time.Time{}, err
+	}
+	h := sha256.New()
+	if _, err = io.Copy(h, file); err != nil {
+		return Manifest{}, time.Time{}, err
+	}
+	return Manifest{
+		Filename:      filepath.Base(filename),
+		Size:          info.Size(),
+		SHA256:        hex.EncodeToString(h.Sum(nil)),
+		HeaderVersion: header.Version,
+		Flags:         header.Flags,
+		Tag:           header.Tag,
+		MetadataSize:  metadataSize,
+		Slots:         param.Slots,
+		Bits:          param.Bits,
+	}, info.ModTime(), nil
+}
+
+func (p *publisher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	switch r.URL.Path {
+	case "/filter":
+		file, err := os.Open(p.filename)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		defer file.Close()
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", p.manifest.Filename))
+		w.Header().Set("ETag", p.etag)
+		http.ServeContent(w, r, p.manifest.Filename, p.modTime, file)
+	case "/manifest.json":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", p.etag)
+		_ = json.NewEncoder(w).Encode(p.manifest)
+	default:
+		http.NotFound(w, r)
+	}
+}
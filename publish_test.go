@@ -0,0 +1,72 @@
+package disk_bloom
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestPublish(t *testing.T) {
+	bf := newTestFilter(t, Controller{Tag: "blocklist"})
+	bf.ExistOrAdd([]byte("testing"))
+	if _, err := Publish(bf); err != NotSealedErr {
+		t.Fatalf("Should fail with NotSealedErr, got %v", err)
+	}
+	if err := bf.Seal(); err != nil {
+		t.Fatal(err)
+	}
+	handler, err := Publish(bf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	b, err := os.ReadFile("testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(b)
+	resp, err := http.Get(server.URL + "/manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest Manifest
+	err = json.NewDecoder(resp.Body).Decode(&manifest)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	param := bf.FilterParam()
+	if manifest.SHA256 != hex.EncodeToString(sum[:]) || manifest.Size != int64(len(b)) || manifest.Tag != "blocklist" ||
+		manifest.Slots != param.Slots || manifest.Bits != param.Bits || manifest.Flags&FlagSealed == 0 {
+		t.Fatalf("Unexpected manifest %+v", manifest)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/filter", nil)
+	req.Header.Set("Range", "bytes=0-1")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent || string(got) != string(b[:2]) {
+		t.Fatalf("Should serve the range, got %v %v", resp.StatusCode, got)
+	}
+	if resp.Header.Get("Content-Type") != "application/octet-stream" || resp.Header.Get("ETag") != `"`+manifest.SHA256+`"` {
+		t.Fatalf("Unexpected headers %v", resp.Header)
+	}
+	if resp, err = http.Post(server.URL+"/filter", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("Should be read-only, got %v", resp.StatusCode)
+	}
+}
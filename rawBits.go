@@ -0,0 +1,140 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+)
+
+var OffsetRangeErr = fmt.Errorf("offset out of the bloom filter")
+
+// checkOffsets returns OffsetRangeErr if any of the bit offsets is out of the bloom filter.
+func (f *DiskFilter) checkOffsets(offsets []uint64) error {
+	if v := f.header.variant(); v != variantClassic {
+		return fmt.Errorf("%w: raw bits of %v filters", UnsupportedErr, v)
+	}
+	for _, offset := range offsets {
+		if offset >= f.param.Bits {
+			return fmt.Errorf("%w: bit %v of %v", OffsetRangeErr, offset, f.param.Bits)
+		}
+	}
+	return nil
+}
+
+// sortedPositions returns the sorted unique file offsets of the bytes containing the bit offsets.
+func (f *DiskFilter) sortedPositions(offsets []uint64) []int64 {
+	positions := f.probePositions(offsets)
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i] < positions[j]
+	})
+	return uniquePositions(positions)
+}
+
+// TestOffsets returns whether each of the bits at the offsets of the bloom filter is set, e.g. to probe by a custom
+// scheme like a blocked bloom filter. The bits failed to be read are reported as not set, see TestOffsetsErr.
+func (f *DiskFilter) TestOffsets(offsets []uint64) []bool {
+	set, err := f.TestOffsetsErr(offsets)
+	if err != nil {
+		return make([]bool, len(offsets))
+	}
+	return set
+}
+
+// TestOffsetsErr is like TestOffsets, but returns the error if the bits failed to be read,
+// or OffsetRangeErr if any of the offsets is not less than Bits.
+func (f *DiskFilter) TestOffsetsErr(offsets []uint64) ([]bool, error) {
+	if !f.acquire() {
+		return nil, ClosedErr
+	}
+	defer f.release()
+	if err := f.checkOffsets(offsets); err != nil {
+		return nil, err
+	}
+	positions := f.sortedPositions(offsets)
+	var vals map[int64]byte
+	var batch uint64
+	var err error
+	f.phase("io", func() {
+		f.rlock()
+		vals, batch, err = f.readBatchLocked(positions)
+		f.file.mu.RUnlock()
+	})
+	if err != nil {
+		return nil, err
+	}
+	set := make([]bool, len(offsets))
+	found := false
+	for i, offset := range offsets {
+		set[i] = vals[f.fileOffset(int64(offset/8))]&(1<<(offset%8)) != 0
+		found = found || set[i]
+	}
+	if found && batch > 0 {
+		// do not report the bits before they are durable
+		if err = f.commit.wait(batch); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+// SetOffsets sets the bits at the offsets of the bloom filter, which are durable as an add in the Fsync mode.
+// The bits set are counted by FillRatio, but the entries are not counted by the stats of the adds.
+func (f *DiskFilter) SetOffsets(offsets []uint64) (err error) {
+	if !f.acquire() {
+		return ClosedErr
+	}
+	defer f.release()
+	if err = f.checkOffsets(offsets); err != nil {
+		return err
+	}
+	positions := f.sortedPositions(offsets)
+	var batch uint64
+	f.phase("io", func() {
+		f.lock()
+		defer f.file.mu.Unlock()
+		var vals map[int64]byte
+		if vals, batch, err = f.readBatchLocked(positions); err != nil {
+			batch = 0
+			return
+		}
+		changed := make(map[int64]byte)
+		var set uint64
+		for _, offset := range offsets {
+			pos := f.fileOffset(int64(offset / 8))
+			if vals[pos]&(1<<(offset%8)) == 0 {
+				vals[pos] |= 1 << (offset % 8)
+				changed[pos] = vals[pos]
+				set++
+			}
+		}
+		if batch, err = f.writeChangedLocked(changed, 0, batch); err == nil {
+			atomic.AddUint64(&f.setBits, set)
+		}
+	})
+	if batch > 0 {
+		// do not return before the bits are durable
+		if e := f.commit.wait(batch); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// ReadRegion reads len(buf) bytes of the bloom filter from the byte offset, e.g. to scan a block of a custom scheme.
+// The bit i of the bloom filter is the bit i%8 of the byte i/8.
+func (f *DiskFilter) ReadRegion(offset int64, buf []byte) error {
+	if !f.acquire() {
+		return ClosedErr
+	}
+	defer f.release()
+	if offset < 0 || offset+int64(len(buf)) > int64((f.param.Bits+7)/8) {
+		return fmt.Errorf("%w: bytes %v to %v of %v bits", OffsetRangeErr, offset, offset+int64(len(buf)), f.param.Bits)
+	}
+	var err error
+	f.phase("io", func() {
+		f.rlock()
+		err = f.readBloomLocked(buf, offset)
+		f.file.mu.RUnlock()
+	})
+	return err
+}
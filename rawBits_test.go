@@ -0,0 +1,51 @@
+package disk_bloom
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDiskFilter_RawBits(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo})
+	bits := bf.FilterParam().Bits
+	offsets := []uint64{0, 9, 17, bits - 1, 9}
+	if err := bf.SetOffsets(offsets); err != nil {
+		t.Fatal(err)
+	}
+	set := bf.TestOffsets([]uint64{0, 1, 9, 17, bits - 2, bits - 1})
+	for i, want := range []bool{true, false, true, true, false, true} {
+		if set[i] != want {
+			t.Fatalf("bit %v should be set: %v, got %v", i, want, set)
+		}
+	}
+	if count := bf.EstimateCount(); count <= 0 {
+		t.Fatalf("the bits set should be counted, got %v", count)
+	}
+	buf := make([]byte, 3)
+	if err := bf.ReadRegion(0, buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf[0] != 1 || buf[1] != 1<<1 || buf[2] != 1<<1 {
+		t.Fatalf("the bit i should be the bit i%%8 of the byte i/8, got %08b", buf)
+	}
+
+	// the bits of an entry are the ones of its probes
+	bf.ExistOrAdd([]byte("entry"))
+	h := bf.Hash([]byte("entry"))
+	set = bf.TestOffsets(bf.offsets(h, int(bf.FilterParam().Slots)))
+	for i := range set {
+		if !set[i] {
+			t.Fatalf("the probe %v of an entry added should be set", i)
+		}
+	}
+
+	if err := bf.SetOffsets([]uint64{bits}); !errors.Is(err, OffsetRangeErr) {
+		t.Fatalf("Should reject an offset out of the bloom filter, got %v", err)
+	}
+	if _, err := bf.TestOffsetsErr([]uint64{bits}); !errors.Is(err, OffsetRangeErr) {
+		t.Fatalf("Should reject an offset out of the bloom filter, got %v", err)
+	}
+	if err := bf.ReadRegion(int64(bits/8)-1, buf); !errors.Is(err, OffsetRangeErr) {
+		t.Fatalf("Should reject a region out of the bloom filter, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package disk_bloom
+
+import "io"
+
+// pageSize is the granularity of reading the bloom filter.
+const pageSize = 4096
+
+// pageReader serves the probe bytes of a lookup with the file lock held.
+// Probes within the same or adjacent pages are served by a single read of the pages containing them.
+type pageReader struct {
+	f *DiskFilter
+	// positions are the sorted file offsets of the probe bytes
+	positions []int64
+	// buf holds the file content from start
+	start int64
+	buf   []byte
+	// probes and reads are counted for DebugStats, and bytes are the bytes read for Trace
+	probes uint64
+	reads  uint64
+	bytes  uint64
+	// err is the first error of the reads, whose bytes read as zeros
+	err error
+}
+
+// probePositions returns the sorted file offsets of the bytes containing the sorted bit offsets.
+func (f *DiskFilter) probePositions(offsets []uint64) []int64 {
+	positions := make([]int64, len(offsets))
+	for i, offset := range offsets {
+		positions[i] = f.fileOffset(int64(offset / 8))
+	}
+	return positions
+}
+
+// readByte reads the byte at positions[i], including the bits buffered in memory.
+func (r *pageReader) readByte(i int) byte {
+	f, pos := r.f, r.positions[i]
+	r.probes++
+	if f.pinned.contains(pos) {
+		return f.pinned.buf[pos-f.pinned.start]
+	}
+	if f.mapped != nil {
+		// the mapped pages need no read ahead
+		return f.mapped.loadByte(pos) | f.pending[pos]
+	}
+	if pos < r.start || pos >= r.start+int64(len(r.buf)) {
+		r.fetch(i)
+	}
+	var val byte
+	if pos >= r.start && pos < r.start+int64(len(r.buf)) {
+		val = r.buf[pos-r.start]
+	}
+	return val | f.pending[pos]
+}
+
+// fetch reads the pages from the one containing positions[i] until the following probes leave a gap of a page.
+func (r *pageReader) fetch(i int) {
+	f := r.f
+	first := r.positions[i] / pageSize
+	last := first
+	for _, pos := range r.positions[i+1:] {
+		if f.pinned.contains(pos) {
+			continue
+		}
+		page := pos / pageSize
+		if page < first || page > last+1 {
+			break
+		}
+		if page > last {
+			last = page
+		}
+	}
+	start, end := first*pageSize, (last+1)*pageSize
+	if start < f.bloomStart {
+		start = f.bloomStart
+	}
+	if bloomEnd := f.bloomStart + f.header.bloomSize(f.param.Bits); end > bloomEnd {
+		end = bloomEnd
+	}
+	if int64(cap(r.buf)) < end-start {
+		r.buf = make([]byte, end-start)
+	}
+	r.buf = r.buf[:end-start]
+	n, err := f.file.rw.ReadAt(r.buf, start)
+	if err == io.EOF {
+		// the bytes beyond the end of the file are zeros
+		err = nil
+	}
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+	f.noteIO(ioOpRead, err)
+	r.reads++
+	r.bytes += uint64(n)
+	r.start, r.buf = start, r.buf[:n]
+}
@@ -0,0 +1,49 @@
+package disk_bloom
+
+import (
+	"strconv"
+	"testing"
+)
+
+// countingStorage counts the reads of the wrapped storage.
+type countingStorage struct {
+	storage
+	reads int
+}
+
+func (s *countingStorage) ReadAt(b []byte, offset int64) (int, error) {
+	s.reads++
+	return s.storage.ReadAt(b, offset)
+}
+
+func TestPageReader(t *testing.T) {
+	bf := newTestFilter(t, Controller{})
+	for i := 0; i < 100; i++ {
+		bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	// probes within the same or adjacent pages share a read
+	positions := []int64{
+		bf.fileOffset(0),
+		bf.fileOffset(10),
+		bf.fileOffset(pageSize + 10),
+		bf.fileOffset(5 * pageSize),
+	}
+	rw := &countingStorage{storage: bf.file.rw}
+	bf.file.rw = rw
+	r := pageReader{f: bf, positions: positions}
+	for i, pos := range positions {
+		var want [1]byte
+		rw.storage.ReadAt(want[:], pos)
+		if got := r.readByte(i); got != want[0] {
+			t.Fatalf("byte at %v: got %v, want %v", pos, got, want[0])
+		}
+	}
+	if rw.reads != 2 {
+		t.Fatalf("Should read 2 times, got %v", rw.reads)
+	}
+	for i := 0; i < 100; i++ {
+		if !bf.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should exist in filter", i)
+		}
+	}
+}
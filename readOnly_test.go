@@ -0,0 +1,85 @@
+package disk_bloom
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestOpenReadOnly(t *testing.T) {
+	bf := newTestFilter(t, Controller{MetadataSize: 8})
+	for i := 0; i < 100; i++ {
+		bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	if err := bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile("testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the parameters are restored from the header
+	readers := make([]*DiskFilter, 2)
+	for i := range readers {
+		if readers[i], err = OpenReadOnly("testfile", FilterParam{Hash: doubleFNV}); err != nil {
+			t.Fatal(err)
+		}
+		defer readers[i].Close()
+	}
+	for _, r := range readers {
+		for i := 0; i < 100; i++ {
+			if !r.Exist([]byte(strconv.Itoa(i))) {
+				t.Fatalf("%v should exist in filter", i)
+			}
+		}
+		if _, err = r.ExistOrAddErr([]byte("new")); !errors.Is(err, ReadOnlyErr) {
+			t.Fatalf("Should be ReadOnlyErr, got %v", err)
+		}
+		if err = r.WriteMetadata(make([]byte, 8)); !errors.Is(err, ReadOnlyErr) {
+			t.Fatalf("Should be ReadOnlyErr, got %v", err)
+		}
+		if r.Exist([]byte("new")) {
+			t.Fatal("Should not add")
+		}
+		if err = r.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	after, err := os.ReadFile("testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Fatal("Should not modify the file")
+	}
+}
+
+func TestOpenReadOnly_Missing(t *testing.T) {
+	if _, err := OpenReadOnly("testfile.missing", FilterParam{Hash: doubleFNV}); !os.IsNotExist(err) {
+		t.Fatalf("Should not create the file, got %v", err)
+	}
+	if _, err := os.Stat("testfile.missing"); !os.IsNotExist(err) {
+		t.Fatal("Should not create the file")
+	}
+}
+
+func TestDiskFilter_ReadOnlyIgnoresSidecars(t *testing.T) {
+	bf := newTestFilter(t, Controller{})
+	bf.ExistOrAdd([]byte("testing"))
+	bf.Close()
+	ro, err := New("testfile", Controller{ReadOnly: true, Checksums: true, Journal: true, GetParam: bf.controller.GetParam})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+	if !ro.Exist([]byte("testing")) {
+		t.Fatal("testing should exist in filter")
+	}
+	for _, sidecar := range []string{ChecksumFilename("testfile"), JournalFilename("testfile")} {
+		if _, err = os.Stat(sidecar); !os.IsNotExist(err) {
+			t.Fatalf("Should not create %v", sidecar)
+		}
+	}
+}
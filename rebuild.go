@@ -0,0 +1,188 @@
+package disk_bloom
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+	"os"
+	"sync/atomic"
+)
+
+// rebuildBatch is the number of keys added by Rebuild at once.
+const rebuildBatch = 1024
+
+// Rebuild creates the filter file filename with param, and adds the keys yielded by iterate in batches,
+// e.g. to grow a filter whose traffic grew past the provisioned capacity, or to change the false positive rate.
+// The new filter is opened with the Controller of f, and carries the metadata of f. The keys may be reused by iterate
+// once yield returns. Reopen the users on the new filter, or replace f with it by ReplaceWith once it is closed
+// if the parameters are unchanged. The file is removed if the adds fail.
+func (f *DiskFilter) Rebuild(filename string, param FilterParam, iterate func(yield func(key []byte))) (*DiskFilter, error) {
+	dst, err := f.create(filename, param)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([][]byte, 0, rebuildBatch)
+	iterate(func(key []byte) {
+		if err != nil {
+			return
+		}
+		keys = append(keys, append([]byte(nil), key...))
+		if len(keys) == rebuildBatch {
+			err = dst.AddBatch(keys)
+			keys = keys[:0]
+		}
+	})
+	if err == nil && len(keys) > 0 {
+		err = dst.AddBatch(keys)
+	}
+	if err == nil {
+		err = dst.Barrier(context.Background())
+	}
+	if err != nil {
+		_ = dst.Close()
+		_ = os.Remove(filename)
+		return nil, err
+	}
+	return dst, nil
+}
+
+// Fold creates the filter file filename with Bits divided by factor, a power of two, by ORing the bits of f
+// which the probes map to the same bit of the smaller filter, so that it contains the entries of f without rehashing them.
+// The false positive rate grows with the fill ratio of the smaller filter. Bits must be a multiple of 8 * factor,
+// and of the page size in bits if Controller.AlignToPage is set. Like Rebuild, the new filter is opened
+// with the Controller of f, and carries the metadata of f.
+func (f *DiskFilter) Fold(filename string, factor uint64) (*DiskFilter, error) {
+	if f.header.variant() != variantClassic {
+		return nil, fmt.Errorf("%w: folding %v filters", UnsupportedErr, f.header.variant())
+	}
+	if f.header.Blocked() {
+		return nil, fmt.Errorf("%w: folding blocked filters", UnsupportedErr)
+	}
+	if factor < 2 || factor&(factor-1) != 0 || f.param.Bits%(8*factor) != 0 {
+		return nil, fmt.Errorf("%w: %v bits can not be folded by %v", InconsistentParamErr, f.param.Bits, factor)
+	}
+	param := *f.param
+	param.Bits /= factor
+	if f.header.Aligned() && f.header.bloomBits(param.Bits) != param.Bits {
+		return nil, fmt.Errorf("%w: %v bits are not aligned to pages", InconsistentParamErr, param.Bits)
+	}
+	dst, err := f.create(filename, param)
+	if err != nil {
+		return nil, err
+	}
+	if err = quiesce([]*DiskFilter{f, dst}, func() error {
+		return dst.foldLocked(f, factor)
+	}); err == nil {
+		err = dst.Barrier(context.Background())
+	}
+	if err != nil {
+		_ = dst.Close()
+		_ = os.Remove(filename)
+		return nil, err
+	}
+	return dst, nil
+}
+
+// foldLocked writes the bits of src folded by factor into the empty f.
+func (f *DiskFilter) foldLocked(src *DiskFilter, factor uint64) error {
+	size := int64(f.param.Bits / 8)
+	out := make([]byte, 1<<16)
+	in := make([]byte, len(out))
+	var set uint64
+	for offset := int64(0); offset < size; offset += int64(len(out)) {
+		n := int64(len(out))
+		if size-offset < n {
+			n = size - offset
+		}
+		for i := range out[:n] {
+			out[i] = 0
+		}
+		if src.header.FastRange() {
+			// the probes are scaled, so that the bit i is folded into the bit i / factor
+			if err := foldScaled(src, out[:n], in, offset, factor); err != nil {
+				return err
+			}
+		} else {
+			// the probes are taken modulo, so that the bit i is folded into the bit i % Bits
+			for k := int64(0); k < int64(factor); k++ {
+				if err := src.readBloomLocked(in[:n], k*size+offset); err != nil {
+					return err
+				}
+				for i := range out[:n] {
+					out[i] |= in[i]
+				}
+			}
+		}
+		if _, err := f.file.rw.WriteAt(out[:n], f.fileOffset(offset)); err != nil {
+			return err
+		}
+		for i := int64(0); i < n; i++ {
+			if out[i] != 0 {
+				set += uint64(bits.OnesCount8(out[i]))
+				if err := f.wroteLocked(out[i], f.fileOffset(offset+i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	atomic.StoreUint64(&f.setBits, set)
+	atomic.StoreInt32(&f.counted, 1)
+	f.file.modified = true
+	return nil
+}
+
+// foldScaled folds the bytes of src from offset*factor into out, ORing each factor bits into one.
+func foldScaled(src *DiskFilter, out []byte, in []byte, offset int64, factor uint64) error {
+	// the bits of out are read in chunks of len(in)
+	perChunk := int64(len(in)) / int64(factor) * 8
+	if perChunk == 0 {
+		perChunk = 8
+		in = make([]byte, factor)
+	}
+	for start := int64(0); start < int64(len(out))*8; start += perChunk {
+		end := start + perChunk
+		if end > int64(len(out))*8 {
+			end = int64(len(out)) * 8
+		}
+		chunk := in[:(end-start)*int64(factor)/8]
+		if err := src.readBloomLocked(chunk, (offset*8+start)*int64(factor)/8); err != nil {
+			return err
+		}
+		for bit := start; bit < end; bit++ {
+			for i := (bit - start) * int64(factor); i < (bit-start+1)*int64(factor); i++ {
+				if chunk[i/8]&(1<<(i%8)) != 0 {
+					out[bit/8] |= 1 << (bit % 8)
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// create creates the filter file filename with param like f, which carries the metadata of f.
+func (f *DiskFilter) create(filename string, param FilterParam) (*DiskFilter, error) {
+	if _, err := os.Stat(filename); err == nil {
+		return nil, fmt.Errorf("%w: %v", os.ErrExist, filename)
+	}
+	if !f.acquire() {
+		return nil, ClosedErr
+	}
+	metadata := make([]byte, f.controller.MetadataSize)
+	f.rlock()
+	_, err := f.file.rw.ReadAt(metadata, LenOfMetadataSize)
+	f.file.mu.RUnlock()
+	f.release()
+	if err != nil {
+		return nil, err
+	}
+	controller := *f.controller
+	// the mapping of the probes is recorded in the header of f
+	controller.FastRange, controller.AlignToPage = f.header.FastRange(), f.header.Aligned()
+	controller.AdaptiveSlots, controller.HardenedProbes = f.header.AdaptiveSlots, f.header.Hardened()
+	controller.Salted, controller.Salt = f.header.Salted(), f.header.Salt
+	controller.GetParam = func([]byte) (FilterParam, []byte) {
+		return param, metadata
+	}
+	return New(filename, controller)
+}
@@ -0,0 +1,105 @@
+package disk_bloom
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestDiskFilter_Rebuild(t *testing.T) {
+	controller := Controller{MetadataSize: 4}
+	controller.GetParam = func([]byte) (FilterParam, []byte) {
+		return FilterParam{Slots: 7, Bits: 1 << 12, Hash: doubleFNV}, []byte("meta")
+	}
+	defer os.Remove("testfile")
+	bf, err := New("testfile", controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bf.Close()
+	defer os.Remove("testfile.rebuilt")
+	rebuilt, err := bf.Rebuild("testfile.rebuilt", FilterParam{Slots: 7, Bits: 1 << 16, Hash: doubleFNV}, func(yield func(key []byte)) {
+		key := make([]byte, 0, 8)
+		for i := 0; i < 3000; i++ {
+			// reused by the iteration
+			key = strconv.AppendInt(key[:0], int64(i), 10)
+			yield(key)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rebuilt.Close()
+	if rebuilt.FilterParam().Bits != 1<<16 {
+		t.Fatalf("Unexpected bits: %v", rebuilt.FilterParam().Bits)
+	}
+	for i := 0; i < 3000; i++ {
+		if !rebuilt.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should exist in the rebuilt filter", i)
+		}
+	}
+	metadata := make([]byte, 4)
+	if _, err = rebuilt.file.rw.ReadAt(metadata, LenOfMetadataSize); err != nil || string(metadata) != "meta" {
+		t.Fatalf("Should carry the metadata, got %q, %v", metadata, err)
+	}
+	if _, err = bf.Rebuild("testfile.rebuilt", FilterParam{Slots: 7, Bits: 1 << 16, Hash: doubleFNV}, func(func([]byte)) {}); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("Should not overwrite, got %v", err)
+	}
+}
+
+func TestDiskFilter_Fold(t *testing.T) {
+	for _, fastRange := range []bool{false, true} {
+		for _, factor := range []uint64{2, 16} {
+			testFold(t, fastRange, factor)
+		}
+	}
+}
+
+func testFold(t *testing.T, fastRange bool, factor uint64) {
+	newFilter := func(filename string, bits uint64) *DiskFilter {
+		bf, err := New(filename, Controller{FastRange: fastRange, GetParam: func([]byte) (FilterParam, []byte) {
+			return FilterParam{Slots: 5, Bits: bits, Hash: doubleFNV}, nil
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bf
+	}
+	defer os.Remove("testfile")
+	defer os.Remove("testfile.folded")
+	defer os.Remove("testfile.direct")
+	bf := newFilter("testfile", 1<<18)
+	defer bf.Close()
+	// the filter built directly with the folded bits
+	direct := newFilter("testfile.direct", 1<<18/factor)
+	defer direct.Close()
+	for i := 0; i < 2000; i++ {
+		bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+		direct.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	folded, err := bf.Fold("testfile.folded", factor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer folded.Close()
+	size := int64(1 << 18 / factor / 8)
+	want, got := make([]byte, size), make([]byte, size)
+	if _, err = direct.file.rw.ReadAt(want, direct.bloomStart); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = folded.file.rw.ReadAt(got, folded.bloomStart); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("fast range: %v, factor %v: the folded bits differ from the bits built directly", fastRange, factor)
+	}
+	if folded.FillRatio() != direct.FillRatio() {
+		t.Fatalf("Unexpected fill ratio: %v, want %v", folded.FillRatio(), direct.FillRatio())
+	}
+	if _, err = bf.Fold("testfile.invalid", 3); err == nil {
+		os.Remove("testfile.invalid")
+		t.Fatal("Should not fold by 3")
+	}
+}
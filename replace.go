@@ -0,0 +1,135 @@
+package disk_bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// ReplaceWith replaces the file of the filter by the filter file at path, e.g. rebuilt on a schedule:
+// the file is renamed over the file of the filter, which is reopened under the same DiskFilter,
+// so that the users of the filter see the new file without being rewired.
+// Other processes opening the filename see either file, since the rename is atomic.
+//
+// The file at path must be closed, and be the same kind of filter of the same metadata size and FilterParam,
+// otherwise ReplaceWith returns InvalidHeaderErr or InconsistentParamErr and nothing is changed.
+// The checksums of Controller.Checksums are moved along after the file if they exist next to path,
+// and computed otherwise. The adds to the filter which are not in the new file are lost,
+// including the buffered and journaled ones, and the ones of ExistOrAddSeq waiting to be acknowledged.
+// If the file fails to be renamed, the filter is left open on the old file. Once the file is renamed,
+// the filter is closed if the checksums fail to be moved or the new file fails to be opened.
+func (f *DiskFilter) ReplaceWith(path string) error {
+	if !f.exclusive() {
+		return ClosedErr
+	}
+	defer f.inflight.Unlock()
+	f.file.mu.Lock()
+	defer f.file.mu.Unlock()
+	if f.file.osFile() == nil {
+		return fmt.Errorf("%w: replacing filters opened by OpenFS", UnsupportedErr)
+	}
+	if err := f.checkReplacement(path); err != nil {
+		return err
+	}
+	filename := f.file.f.Name()
+	if err := os.Rename(path, filename); err != nil {
+		return err
+	}
+	ticked := f.ticks()
+	_ = f.closeJournalLocked(false)
+	_ = os.Remove(JournalFilename(filename))
+	if f.checksums != nil {
+		_ = f.checksums.f.Close()
+		f.checksums = nil
+	}
+	_ = f.munmapLocked()
+	f.releaseMemoryLocked()
+	_ = f.file.f.Close()
+	// the checksums of the old file are replaced, or removed to be computed afresh
+	if err := os.Rename(ChecksumFilename(path), ChecksumFilename(filename)); os.IsNotExist(err) {
+		if err = os.Remove(ChecksumFilename(filename)); err != nil && !os.IsNotExist(err) {
+			return f.replaceFailedLocked(err)
+		}
+	} else if err != nil {
+		// the checksums of the old file would fail the verification of the new one
+		_ = os.Remove(ChecksumFilename(filename))
+		return f.replaceFailedLocked(err)
+	}
+	replaced, err := openFile(filename, *f.controller, f.header.variant(), f.debug)
+	if err != nil {
+		return f.replaceFailedLocked(err)
+	}
+	// the parameters are read by the hashing out of the locks, so they are kept
+	if replaced.param.Slots != f.param.Slots || replaced.param.Bits != f.param.Bits {
+		_ = replaced.Close()
+		return f.replaceFailedLocked(fmt.Errorf("%w: %v slots and %v bits are replaced by %v slots and %v bits",
+			InconsistentParamErr, f.param.Slots, f.param.Bits, replaced.param.Slots, replaced.param.Bits))
+	}
+	atomic.StoreUint64(&f.setBits, atomic.LoadUint64(&replaced.setBits))
+	atomic.StoreInt32(&f.counted, atomic.LoadInt32(&replaced.counted))
+	f.header = replaced.header
+	f.bloomStart = replaced.bloomStart
+	f.file.f, f.file.rw = replaced.file.f, replaced.file.rw
+	f.file.modified, f.file.metadataModified = replaced.file.modified, replaced.file.metadataModified
+	f.pending, f.diskFull, f.readOnly = replaced.pending, false, replaced.readOnly
+	f.pinned = replaced.pinned
+	f.mapped, f.lockFree = replaced.mapped, replaced.lockFree
+	f.hybrid = replaced.hybrid
+	f.changes = replaced.changes
+	f.checksums = replaced.checksums
+	f.reached = replaced.reached
+	f.journal = replaced.journal
+	f.cache = replaced.cache
+	if replaced.acks != nil {
+		// the ring is the same file, which is kept open without the records of the adds lost
+		_ = replaced.acks.f.Close()
+		f.acks.dropPending()
+	}
+	f.buffered = 0
+	for pos := range f.unsynced {
+		delete(f.unsynced, pos)
+	}
+	if !ticked && f.ticks() {
+		f.tasks.start("sync", f.eventEverySec)
+	}
+	return nil
+}
+
+// checkReplacement returns an error if the filter file at path can not replace the file of the filter.
+func (f *DiskFilter) checkReplacement(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	raw := retryStorage{file}
+	var metadataSize [LenOfMetadataSize]byte
+	if _, err = raw.ReadAt(metadataSize[:], 0); err != nil {
+		return err
+	}
+	if size := binary.LittleEndian.Uint16(metadataSize[:]); size != f.controller.MetadataSize {
+		return fmt.Errorf("%w: the metadata size of %v is %v, which is different from %v", InconsistentMetadataSizeErr, path, size, f.controller.MetadataSize)
+	}
+	h, err := readHeader(raw, f.controller.MetadataSize)
+	if err != nil {
+		return err
+	}
+	old := f.header
+	if h.variant() != old.variant() || h.Encrypted() != old.Encrypted() {
+		return fmt.Errorf("%w: %v is a %v filter, encrypted: %v, which can not replace a %v filter, encrypted: %v",
+			InvalidHeaderErr, path, h.variant(), h.Encrypted(), old.variant(), old.Encrypted())
+	}
+	if h.Slots != old.Slots || h.Bits != old.Bits || h.fingerprints != old.fingerprints || h.HashKind != old.HashKind ||
+		h.FastRange() != old.FastRange() || h.Hardened() != old.Hardened() || h.AdaptiveSlots != old.AdaptiveSlots || h.CounterWidth != old.CounterWidth ||
+		h.Salted() != old.Salted() || h.Salt != old.Salt {
+		return fmt.Errorf("%w: the parameters of %v are different from the filter", InconsistentParamErr, path)
+	}
+	return nil
+}
+
+// replaceFailedLocked closes the filter whose file failed to be replaced, and returns err.
+func (f *DiskFilter) replaceFailedLocked(err error) error {
+	close(f.closed)
+	return err
+}
@@ -0,0 +1,163 @@
+package disk_bloom
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestDiskFilter_ReplaceWith(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeEverySec, Checksums: true})
+	defer os.Remove(ChecksumFilename("testfile"))
+	for i := 0; i < 100; i++ {
+		bf.ExistOrAdd([]byte("old" + strconv.Itoa(i)))
+	}
+
+	// rebuilt with other entries
+	defer os.Remove("testfile.new")
+	defer os.Remove(ChecksumFilename("testfile.new"))
+	rebuilt, err := New("testfile.new", Controller{Fsync: FsyncModeNo, Checksums: true, GetParam: bf.controller.GetParam})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		rebuilt.ExistOrAdd([]byte("new" + strconv.Itoa(i)))
+	}
+	if err = rebuilt.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the lookups in flight see either file
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			bf.Exist([]byte("new0"))
+		}
+	}()
+	if err = bf.ReplaceWith("testfile.new"); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if _, err = os.Stat("testfile.new"); !os.IsNotExist(err) {
+		t.Fatalf("Should move the file, got %v", err)
+	}
+	if _, err = os.Stat(ChecksumFilename("testfile.new")); !os.IsNotExist(err) {
+		t.Fatalf("Should move the checksums, got %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if !bf.Exist([]byte("new" + strconv.Itoa(i))) {
+			t.Fatalf("new%v should exist in the replaced filter", i)
+		}
+	}
+	found := 0
+	for i := 0; i < 100; i++ {
+		if bf.Exist([]byte("old" + strconv.Itoa(i))) {
+			found++
+		}
+	}
+	if found > 1 {
+		t.Fatalf("Should forget the old entries, found %v", found)
+	}
+	if count := bf.EstimateCount(); count < 90 || count > 110 {
+		t.Fatalf("count should be about 100, got %v", count)
+	}
+	if bf.ExistOrAdd([]byte("added")) || !bf.Exist([]byte("added")) {
+		t.Fatal("Should add to the replaced filter")
+	}
+	if err = bf.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiskFilter_ReplaceWithInconsistent(t *testing.T) {
+	bf := newTestFilter(t, Controller{})
+	bf.ExistOrAdd([]byte("testing"))
+	defer os.Remove("testfile.new")
+	other, err := New("testfile.new", Controller{GetParam: func([]byte) (FilterParam, []byte) {
+		return FilterParam{Slots: 3, Bits: 1 << 16, Hash: doubleFNV}, nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Close()
+	if err = bf.ReplaceWith("testfile.new"); !errors.Is(err, InconsistentParamErr) {
+		t.Fatalf("Should be InconsistentParamErr, got %v", err)
+	}
+	if !bf.Exist([]byte("testing")) {
+		t.Fatal("Should keep the file")
+	}
+	if _, err = os.Stat("testfile.new"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiskFilter_ReplaceWithRenameFailed(t *testing.T) {
+	bf := newTestFilter(t, Controller{Checksums: true})
+	defer os.Remove(ChecksumFilename("testfile"))
+	bf.ExistOrAdd([]byte("testing"))
+	// on another filesystem, which the file can not be renamed from
+	path := "/dev/shm/disk-bloom-test-" + strconv.Itoa(os.Getpid())
+	rebuilt, err := New(path, Controller{GetParam: bf.controller.GetParam})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer os.Remove(path)
+	rebuilt.Close()
+	if err = bf.ReplaceWith(path); err == nil {
+		t.Skip("renamed across the filesystems")
+	}
+	if !bf.Exist([]byte("testing")) || bf.ExistOrAdd([]byte("added")) {
+		t.Fatal("Should keep the filter open on the old file")
+	}
+	if err = bf.Verify(); err != nil {
+		t.Fatalf("Should keep the checksums, got %v", err)
+	}
+}
+
+func TestDiskFilter_ReplaceWithAcks(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "filter")
+	bf := openAckFilter(t, filename, Controller{Fsync: FsyncModeEverySec, AckRing: 4})
+	defer bf.Close()
+	if _, err := bf.ExistOrAddSeq([]byte("1"), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := bf.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// waiting for the sync, which is lost by the replacement
+	if _, err := bf.ExistOrAddSeq([]byte("2"), 2); err != nil {
+		t.Fatal(err)
+	}
+
+	rebuilt := openAckFilter(t, filename+".new", Controller{Fsync: FsyncModeNo})
+	if err := rebuilt.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := bf.ReplaceWith(filename + ".new"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bf.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	checkAcked(t, bf, 1)
+	if n := openFiles(t, AckRingFilename(filename)); n != 1 {
+		t.Fatalf("Should keep the ack ring open once, got %v", n)
+	}
+}
+
+// openFiles returns the number of the file descriptors of the process open on filename.
+func openFiles(t *testing.T, filename string) (n int) {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip(err)
+	}
+	for _, e := range entries {
+		if target, _ := os.Readlink(filepath.Join("/proc/self/fd", e.Name())); target == filename {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,368 @@
+package disk_bloom
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ObjectStore is where an Uploader pushes a filter for its replicas, e.g. an S3 bucket adapted by the application,
+// or DirStore. Keys are slash-separated.
+type ObjectStore interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get returns an error satisfying os.IsNotExist if the key does not exist.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// List returns the keys with the prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// The objects under the prefix are grouped by epochs, each of which starts with a base, the whole filter file,
+// followed by the deltas in sequence:
+//
+//	<prefix>/<epoch>/base
+//	<prefix>/<epoch>/delta-<sequence>
+//
+// The epoch is the start of the Uploader in unix nanoseconds, and the sequence starts from 1. Both are zero-padded to 20 digits.
+const (
+	replicaBase        = "base"
+	replicaDeltaPrefix = "delta-"
+)
+
+func replicaKey(prefix string, epoch uint64, name string) string {
+	return path.Join(prefix, fmt.Sprintf("%020d", epoch), name)
+}
+
+func replicaDelta(sequence uint64) string {
+	return fmt.Sprintf("%v%020d", replicaDeltaPrefix, sequence)
+}
+
+// DirStore is an ObjectStore in a local directory, e.g. on a shared filesystem.
+type DirStore string
+
+func (d DirStore) Put(ctx context.Context, key string, r io.Reader) error {
+	filename := filepath.Join(string(d), filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	// written aside and renamed, so that the object is never seen partially
+	tmp := filename + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+func (d DirStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(d), filepath.FromSlash(key)))
+}
+
+func (d DirStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.Walk(string(d), func(filename string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasSuffix(filename, ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(string(d), filename)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return keys, err
+}
+
+// Uploader pushes a filter to an ObjectStore periodically: a base once, and then a delta of the bytes changed every interval,
+// so that replicas in other regions are updated without direct connectivity. See NewReplica.
+type Uploader struct {
+	f        *DiskFilter
+	store    ObjectStore
+	prefix   string
+	epoch    uint64
+	sequence uint64
+	// onError is invoked with the errors of the periodic uploads
+	onError func(err error)
+	// mu serializes the uploads
+	mu     sync.Mutex
+	closed chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewUploader uploads the base of the filter, which should be opened with Controller.TrackDeltas,
+// and uploads a delta every interval until Close. onError is optional.
+func NewUploader(ctx context.Context, f *DiskFilter, store ObjectStore, prefix string, interval time.Duration, onError func(err error)) (*Uploader, error) {
+	u := &Uploader{
+		f:       f,
+		store:   store,
+		prefix:  prefix,
+		epoch:   uint64(time.Now().UnixNano()),
+		onError: onError,
+		closed:  make(chan struct{}),
+	}
+	if err := u.uploadBase(ctx); err != nil {
+		return nil, err
+	}
+	u.wg.Add(1)
+	go func() {
+		defer u.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-u.closed:
+				return
+			case <-ticker.C:
+				if err := u.Upload(context.Background()); err != nil && u.onError != nil {
+					u.onError(err)
+				}
+			}
+		}
+	}()
+	return u, nil
+}
+
+// uploadBase uploads the filter file, and starts tracking the deltas from it.
+func (u *Uploader) uploadBase(ctx context.Context) error {
+	f := u.f
+	dir := filepath.Dir(f.file.f.Name())
+	if f.file.osFile() == nil {
+		// the name is in the fs.FS
+		dir = ""
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(f.file.f.Name())+".base-*")
+	if err != nil {
+		return err
+	}
+	_ = tmp.Close()
+	_ = os.Remove(tmp.Name())
+	defer os.Remove(tmp.Name())
+	if !f.acquire() {
+		return ClosedErr
+	}
+	f.file.mu.Lock()
+	if f.changes == nil {
+		err = fmt.Errorf("deltas are not tracked")
+	} else if err = f.cloneLocked(tmp.Name()); err == nil {
+		f.changes = make(map[int64]byte)
+	}
+	f.file.mu.Unlock()
+	f.release()
+	if err != nil {
+		return err
+	}
+	base, err := os.Open(tmp.Name())
+	if err != nil {
+		return err
+	}
+	defer base.Close()
+	return u.store.Put(ctx, replicaKey(u.prefix, u.epoch, replicaBase), base)
+}
+
+// Upload uploads a delta of the bytes changed since the last upload now, if any.
+func (u *Uploader) Upload(ctx context.Context) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	var buf bytes.Buffer
+	n, err := u.f.ExportDelta(&buf)
+	if err != nil || n == 0 {
+		return err
+	}
+	if err = u.store.Put(ctx, replicaKey(u.prefix, u.epoch, replicaDelta(u.sequence+1)), bytes.NewReader(buf.Bytes())); err != nil {
+		_ = u.f.untakeDelta(buf.Bytes())
+		return err
+	}
+	u.sequence++
+	return nil
+}
+
+// Close stops the periodic uploads after uploading the last delta.
+func (u *Uploader) Close() error {
+	close(u.closed)
+	u.wg.Wait()
+	return u.Upload(context.Background())
+}
+
+// Replica is a read replica of a filter reconstructed from the base and the deltas pushed by an Uploader.
+type Replica struct {
+	store      ObjectStore
+	prefix     string
+	filename   string
+	controller Controller
+	// mu guards filter, which is replaced when the Uploader restarts with a new base
+	mu     sync.RWMutex
+	filter *DiskFilter
+	// syncMu serializes Sync, and guards epoch and sequence
+	syncMu   sync.Mutex
+	epoch    uint64
+	sequence uint64
+}
+
+// NewReplica downloads the latest base under prefix into filename, which is replaced, applies the deltas after it,
+// and opens it with the controller, whose GetParam can be nil if the parameters are recorded in the file.
+// Call Sync to apply the deltas uploaded afterwards.
+func NewReplica(ctx context.Context, store ObjectStore, prefix string, filename string, controller Controller) (*Replica, error) {
+	r := &Replica{store: store, prefix: prefix, filename: filename, controller: controller}
+	if err := r.Sync(ctx); err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// latestEpoch returns the latest epoch with a base.
+func (r *Replica) latestEpoch(ctx context.Context) (uint64, error) {
+	keys, err := r.store.List(ctx, r.prefix)
+	if err != nil {
+		return 0, err
+	}
+	var latest uint64
+	found := false
+	for _, key := range keys {
+		dir, name := path.Split(key)
+		if name != replicaBase {
+			continue
+		}
+		if epoch, err := strconv.ParseUint(path.Base(dir), 10, 64); err == nil && (!found || epoch > latest) {
+			latest, found = epoch, true
+		}
+	}
+	if !found {
+		return 0, fmt.Errorf("%w: no base under %v", os.ErrNotExist, r.prefix)
+	}
+	return latest, nil
+}
+
+// download replaces the file with the base of the epoch, and opens it.
+func (r *Replica) download(ctx context.Context, epoch uint64) error {
+	body, err := r.store.Get(ctx, replicaKey(r.prefix, epoch, replicaBase))
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	tmp := r.filename + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, body)
+	if err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.filter != nil {
+		_ = r.filter.Close()
+		r.filter = nil
+	}
+	if err = os.Rename(tmp, r.filename); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	filter, err := New(r.filename, r.controller)
+	if err != nil {
+		return err
+	}
+	r.filter, r.epoch, r.sequence = filter, epoch, 0
+	return nil
+}
+
+// Sync applies the deltas uploaded since the last Sync. If the Uploader has restarted with a new base,
+// the replica is downloaded again.
+func (r *Replica) Sync(ctx context.Context) error {
+	r.syncMu.Lock()
+	defer r.syncMu.Unlock()
+	if epoch, err := r.latestEpoch(ctx); err != nil {
+		return err
+	} else if epoch != r.epoch || r.filter == nil {
+		if err = r.download(ctx, epoch); err != nil {
+			return err
+		}
+	}
+	keys, err := r.store.List(ctx, replicaKey(r.prefix, r.epoch, replicaDeltaPrefix))
+	if err != nil {
+		return err
+	}
+	var sequences []uint64
+	for _, key := range keys {
+		if sequence, err := strconv.ParseUint(strings.TrimPrefix(path.Base(key), replicaDeltaPrefix), 10, 64); err == nil && sequence > r.sequence {
+			sequences = append(sequences, sequence)
+		}
+	}
+	sort.Slice(sequences, func(i, j int) bool {
+		return sequences[i] < sequences[j]
+	})
+	for _, sequence := range sequences {
+		if sequence != r.sequence+1 {
+			// a gap, which is filled by the next Sync
+			return nil
+		}
+		body, err := r.store.Get(ctx, replicaKey(r.prefix, r.epoch, replicaDelta(sequence)))
+		if err != nil {
+			return err
+		}
+		r.mu.RLock()
+		err = r.filter.ApplyDelta(body)
+		r.mu.RUnlock()
+		_ = body.Close()
+		if err != nil {
+			return err
+		}
+		r.sequence = sequence
+	}
+	return nil
+}
+
+// Exist returns if an entry is in the replica
+func (r *Replica) Exist(b []byte) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.filter == nil {
+		return false
+	}
+	return r.filter.Exist(b)
+}
+
+// Close closes the replica. The file is kept.
+func (r *Replica) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.filter == nil {
+		return nil
+	}
+	err := r.filter.Close()
+	r.filter = nil
+	return err
+}
@@ -0,0 +1,80 @@
+package disk_bloom
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReplica(t *testing.T) {
+	os.Mkdir("testfile", os.ModePerm)
+	defer os.RemoveAll("testfile")
+	ctx := context.Background()
+	controller := Controller{
+		Fsync:       FsyncModeNo,
+		TrackDeltas: true,
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			slots, bits := OptimalParam(1e4, 1e-4)
+			return FilterParam{Slots: slots, Bits: bits, Hash: doubleFNV}, nil
+		},
+	}
+	primary, err := New(filepath.Join("testfile", "primary"), controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer primary.Close()
+	primary.ExistOrAdd([]byte("base"))
+	store := DirStore(filepath.Join("testfile", "store"))
+	u, err := NewUploader(ctx, primary, store, "filters/a", time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		primary.ExistOrAdd([]byte(fmt.Sprint(i)))
+	}
+	if err = u.Upload(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	controller.TrackDeltas = false
+	replica, err := NewReplica(ctx, store, "filters/a", filepath.Join("testfile", "replica"), controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replica.Close()
+	for i := 0; i < 100; i++ {
+		if !replica.Exist([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%v should exist in the replica", i)
+		}
+	}
+	if !replica.Exist([]byte("base")) {
+		t.Fatal("base should exist in the replica")
+	}
+	primary.ExistOrAdd([]byte("delta"))
+	if err = u.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = replica.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !replica.Exist([]byte("delta")) {
+		t.Fatal("delta should exist after Sync")
+	}
+
+	// a restarted uploader uploads a new base
+	primary.ExistOrAdd([]byte("restart"))
+	u, err = NewUploader(ctx, primary, store, "filters/a", time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer u.Close()
+	if err = replica.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !replica.Exist([]byte("restart")) || !replica.Exist([]byte("delta")) {
+		t.Fatal("the entries should exist after the new base")
+	}
+}
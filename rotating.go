@@ -0,0 +1,276 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// generation is a filter of a RotatingGroup, which accepts the entries of the window starting at start.
+type generation struct {
+	start    time.Time
+	filename string
+	filter   *DiskFilter
+}
+
+// RotatingGroup is a group of filters rotated on a time interval, e.g. one file per day keeping the last 7 days,
+// which is the classic replay protection where entries older than the window naturally fall out.
+// Exist checks all live generations, ExistOrAdd adds to the newest, and the expired files are deleted.
+// The rotation happens on the first operation of a new interval, so an idle group keeps its files until then.
+type RotatingGroup struct {
+	pattern     string
+	fsync       FsyncMode
+	interval    time.Duration
+	generations int
+	n           uint64
+	param       FilterParam
+	clock       Clock
+	// filename returns the filename of the given index, which is the start of the window in unix seconds
+	filename func(index string) string
+	// mu is read-locked by the operations, and locked by the rotation
+	mu sync.RWMutex
+	// live is the live generations, the oldest first
+	live   []*generation
+	closed bool
+}
+
+// NewRotatingGroup returns a RotatingGroup, each generation is a file.
+// The filenames are generated by replacing the last "*" of pattern with the start of the window in unix seconds.
+// Each window lasts interval, and generations is the number of windows kept, including the current one.
+// n is the expected number of entries in single file, and p is the expected false positive rate, which apply to new files.
+// clock is optional, and defaults to SystemClock.
+// Files of expired windows are deleted, and files matching the pattern with other names are left untouched.
+func NewRotatingGroup(pattern string, fsync FsyncMode, interval time.Duration, generations int, n uint64, p float64, hash func([]byte) (uint64, uint64), clock Clock) (*RotatingGroup, error) {
+	starIndex := strings.LastIndex(pattern, "*")
+	if starIndex == -1 {
+		return nil, InvalidPatternErr
+	}
+	if interval < time.Second || interval%time.Second != 0 {
+		return nil, fmt.Errorf("invalid interval %v, which should be whole seconds", interval)
+	}
+	if generations < 1 {
+		return nil, fmt.Errorf("invalid generations %v", generations)
+	}
+	if clock == nil {
+		clock = SystemClock
+	}
+	slots, bits := OptimalParam(n, p)
+	g := &RotatingGroup{
+		pattern:     pattern,
+		fsync:       fsync,
+		interval:    interval,
+		generations: generations,
+		n:           n,
+		param: FilterParam{
+			Slots: slots,
+			Bits:  bits,
+			Hash:  hash,
+		},
+		clock: clock,
+		filename: func(index string) string {
+			return pattern[:starIndex] + index + pattern[starIndex+1:]
+		},
+	}
+	if err := g.search(starIndex); err != nil {
+		g.closeLocked()
+		return nil, err
+	}
+	if err := g.rotateLocked(g.windowStart(clock.Now())); err != nil {
+		g.closeLocked()
+		return nil, err
+	}
+	return g, nil
+}
+
+// search opens the existing generations.
+func (g *RotatingGroup) search(starIndex int) error {
+	// the pattern is validated
+	matches, _ := filepath.Glob(g.pattern)
+	suffix := len(g.pattern) - starIndex - 1
+	for _, filename := range matches {
+		if len(filename) < starIndex+suffix {
+			continue
+		}
+		sec, err := strconv.ParseInt(filename[starIndex:len(filename)-suffix], 10, 64)
+		if err != nil || g.filename(strconv.FormatInt(sec, 10)) != filename {
+			continue
+		}
+		start := time.Unix(sec, 0)
+		if !start.Equal(g.windowStart(start)) {
+			// of another interval
+			continue
+		}
+		m, err := readGroupMetadata(filename)
+		if err != nil {
+			continue
+		}
+		filter, err := New(filename, Controller{
+			Fsync:        g.fsync,
+			MetadataSize: metadataSize,
+			GetParam: func(metadata []byte) (FilterParam, []byte) {
+				return FilterParam{
+					Slots: m.Slots,
+					Bits:  m.Bits,
+					Hash:  g.param.Hash,
+				}, nil
+			},
+		})
+		if err != nil {
+			return err
+		}
+		g.live = append(g.live, &generation{start: start, filename: filename, filter: filter})
+	}
+	sort.Slice(g.live, func(i, j int) bool {
+		return g.live[i].start.Before(g.live[j].start)
+	})
+	return nil
+}
+
+// windowStart returns the start of the window containing t.
+func (g *RotatingGroup) windowStart(t time.Time) time.Time {
+	sec := t.Unix()
+	interval := int64(g.interval / time.Second)
+	// floor for the times before the epoch
+	start := sec - sec%interval
+	if sec%interval < 0 {
+		start -= interval
+	}
+	return time.Unix(start, 0)
+}
+
+// current returns the live generations, rotating them first if the window has moved.
+// It returns them with mu read-locked, or ClosedErr.
+func (g *RotatingGroup) current() ([]*generation, error) {
+	start := g.windowStart(g.clock.Now())
+	g.mu.RLock()
+	if g.closed {
+		g.mu.RUnlock()
+		return nil, ClosedErr
+	}
+	if len(g.live) > 0 && !g.live[len(g.live)-1].start.Before(start) {
+		return g.live, nil
+	}
+	g.mu.RUnlock()
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return nil, ClosedErr
+	}
+	err := g.rotateLocked(start)
+	g.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return g.current()
+}
+
+// rotateLocked creates the generation of the window starting at start, and deletes the expired ones.
+func (g *RotatingGroup) rotateLocked(start time.Time) error {
+	if len(g.live) == 0 || g.live[len(g.live)-1].start.Before(start) {
+		filename := g.filename(strconv.FormatInt(start.Unix(), 10))
+		filter, err := New(filename, Controller{
+			Fsync:        g.fsync,
+			MetadataSize: metadataSize,
+			GetParam: func(metadata []byte) (FilterParam, []byte) {
+				return g.param, Metadata{
+					Expected: g.n,
+					Slots:    g.param.Slots,
+					Bits:     g.param.Bits,
+				}.Encode()
+			},
+		})
+		if err != nil {
+			return err
+		}
+		g.live = append(g.live, &generation{start: start, filename: filename, filter: filter})
+	}
+	expiry := start.Add(-time.Duration(g.generations-1) * g.interval)
+	var live []*generation
+	for _, gen := range g.live {
+		if !gen.start.Before(expiry) {
+			live = append(live, gen)
+			continue
+		}
+		_ = gen.filter.Close()
+		if err := os.Remove(gen.filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	g.live = live
+	return nil
+}
+
+// Hash returns the double hash of an entry.
+func (g *RotatingGroup) Hash(b []byte) KeyHash {
+	x, y := g.param.Hash(b)
+	return KeyHash{X: x, Y: y}
+}
+
+// Exist returns if an entry is in any live generation.
+func (g *RotatingGroup) Exist(b []byte) bool {
+	live, err := g.current()
+	if err != nil {
+		return false
+	}
+	defer g.mu.RUnlock()
+	h := g.Hash(b)
+	for _, gen := range live {
+		if gen.filter.ExistHashed(h) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExistOrAdd returns whether the entry was in any live generation, and adds it to the newest if it was not in.
+func (g *RotatingGroup) ExistOrAdd(b []byte) (exist bool) {
+	exist, _ = g.ExistOrAddErr(b)
+	return exist
+}
+
+// ExistOrAddErr is like ExistOrAdd, but returns the error if the entry failed to be added.
+func (g *RotatingGroup) ExistOrAddErr(b []byte) (exist bool, err error) {
+	live, err := g.current()
+	if err != nil {
+		return false, err
+	}
+	defer g.mu.RUnlock()
+	h := g.Hash(b)
+	for _, gen := range live[:len(live)-1] {
+		if gen.filter.ExistHashed(h) {
+			return true, nil
+		}
+	}
+	return live[len(live)-1].filter.existOrAddHashed(h)
+}
+
+// Generations returns the filenames of the live generations, the oldest first.
+func (g *RotatingGroup) Generations() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	filenames := make([]string, len(g.live))
+	for i, gen := range g.live {
+		filenames[i] = gen.filename
+	}
+	return filenames
+}
+
+// Close closes all generations. The files are kept.
+func (g *RotatingGroup) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.closeLocked()
+	return nil
+}
+
+func (g *RotatingGroup) closeLocked() {
+	g.closed = true
+	for _, gen := range g.live {
+		_ = gen.filter.Close()
+	}
+}
@@ -0,0 +1,59 @@
+package disk_bloom
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRotatingGroup(t *testing.T) {
+	os.Mkdir("testfile", os.ModePerm)
+	defer os.RemoveAll("testfile")
+	clock := NewManualClock(time.Unix(1700000000, 0))
+	open := func() *RotatingGroup {
+		g, err := NewRotatingGroup("testfile/*.bloom", FsyncModeNo, time.Hour, 3, 1000, 1e-6, doubleFNV, clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return g
+	}
+	g := open()
+	if g.ExistOrAdd([]byte("a")) {
+		t.Fatal("a should be new")
+	}
+	clock.Advance(time.Hour)
+	if !g.ExistOrAdd([]byte("a")) {
+		t.Fatal("a should exist in the previous generation")
+	}
+	if g.ExistOrAdd([]byte("b")) {
+		t.Fatal("b should be new")
+	}
+	if n := len(g.Generations()); n != 2 {
+		t.Fatalf("should have 2 generations, got %v", n)
+	}
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// reopened two hours later, the generation of a expires
+	clock.Advance(2 * time.Hour)
+	g = open()
+	defer g.Close()
+	if g.Exist([]byte("a")) {
+		t.Fatal("a should have expired")
+	}
+	if !g.Exist([]byte("b")) {
+		t.Fatal("b should exist")
+	}
+	if n := len(g.Generations()); n != 2 {
+		t.Fatalf("should have 2 generations, got %v", n)
+	}
+	files, _ := os.ReadDir("testfile")
+	if len(files) != 2 {
+		t.Fatalf("the expired file should be deleted, got %v files", len(files))
+	}
+	clock.Advance(2 * time.Hour)
+	if g.Exist([]byte("b")) {
+		t.Fatal("b should have expired")
+	}
+}
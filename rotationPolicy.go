@@ -0,0 +1,101 @@
+package disk_bloom
+
+import (
+	"sync"
+	"time"
+)
+
+// WithRotationPolicy sets the RotationPolicy of the group. The default rotates once the active filter
+// has the expected number of entries, like RotateByCount.
+func WithRotationPolicy(policy RotationPolicy) GroupOption {
+	return func(g *FilterGroup) {
+		g.policy = policy
+	}
+}
+
+// ActiveFilter is the active filter of a FilterGroup given to RotationPolicy.
+type ActiveFilter struct {
+	Filter *DiskFilter
+	// Added is the number of entries added to it, and Expected is the number it is sized for
+	Added    uint64
+	Expected uint64
+}
+
+// RotationPolicy decides when a FilterGroup rotates to a new active filter. RotateNow rotates regardless of it.
+type RotationPolicy interface {
+	// Full reports whether the active filter is full. It is invoked after an entry is added to it,
+	// and never for an empty filter, so an idle group does not rotate until the next entry added.
+	Full(active ActiveFilter) bool
+}
+
+// RotationPolicyFunc is a RotationPolicy of a function.
+type RotationPolicyFunc func(active ActiveFilter) bool
+
+func (fn RotationPolicyFunc) Full(active ActiveFilter) bool {
+	return fn(active)
+}
+
+// RotateByCount rotates once the active filter has the expected number of entries, which is the default.
+func RotateByCount() RotationPolicy {
+	return RotationPolicyFunc(func(active ActiveFilter) bool {
+		return active.Added >= active.Expected
+	})
+}
+
+// RotateByFillRatio rotates once the ratio of the bits set in the active filter reaches ratio,
+// which bounds the false positive rate even if the entries are more than expected.
+func RotateByFillRatio(ratio float64) RotationPolicy {
+	return RotationPolicyFunc(func(active ActiveFilter) bool {
+		return active.Filter.FillRatio() >= ratio
+	})
+}
+
+// intervalPolicy rotates every interval.
+type intervalPolicy struct {
+	interval time.Duration
+	clock    Clock
+
+	mu     sync.Mutex
+	active *DiskFilter
+	since  time.Time
+}
+
+// RotateEvery rotates once the active filter has been active for interval, counted from the first entry added to it,
+// or once it has the expected number of entries if full is true. clock is optional, and defaults to SystemClock.
+// The policy keeps the state of the active filter, so it must not be shared by groups.
+func RotateEvery(interval time.Duration, full bool, clock Clock) RotationPolicy {
+	if clock == nil {
+		clock = SystemClock
+	}
+	p := &intervalPolicy{interval: interval, clock: clock}
+	if !full {
+		return p
+	}
+	return RotationPolicyFunc(func(active ActiveFilter) bool {
+		// the interval starts on the first entry, so always consult it
+		expired := p.Full(active)
+		return expired || active.Added >= active.Expected
+	})
+}
+
+func (p *intervalPolicy) Full(active ActiveFilter) bool {
+	now := p.clock.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if active.Filter != p.active {
+		p.active, p.since = active.Filter, now
+	}
+	return now.Sub(p.since) >= p.interval
+}
+
+// full reports whether the active filter of added entries is full by the RotationPolicy.
+// An empty filter is never full, so the policy first sees a filter on its first entry.
+func (g *FilterGroup) full(active *filterObj, added uint64) bool {
+	if added == 0 {
+		return false
+	}
+	if g.policy == nil {
+		return added >= active.expected
+	}
+	return g.policy.Full(ActiveFilter{Filter: active.filter, Added: added, Expected: active.expected})
+}
@@ -0,0 +1,86 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFilterGroup_RotateByFillRatio(t *testing.T) {
+	os.Mkdir("testfile", os.ModePerm)
+	defer os.RemoveAll("testfile")
+	bf, err := NewGroup("testfile/*", FsyncModeNo, 1000, 1e-3, doubleFNV, WithRotationPolicy(RotateByFillRatio(0.1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 500; i++ {
+		bf.ExistOrAdd([]byte(fmt.Sprint(i)))
+		bf.tasks.wait()
+	}
+	filters := bf.load()
+	if len(filters) < 3 {
+		t.Fatalf("Should rotate long before 1000 entries, got %v filters", len(filters))
+	}
+	for _, f := range filters[:len(filters)-1] {
+		if ratio := f.filter.FillRatio(); ratio < 0.1 || ratio > 0.15 {
+			t.Fatalf("Should rotate at the fill ratio 0.1, got %v", ratio)
+		}
+	}
+	bf.Close()
+
+	// the filters rotated by the policy are not the active filter once opened again
+	bf, err = NewGroup("testfile/*", FsyncModeNo, 1000, 1e-3, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bf.Close()
+	if reopened := bf.load(); len(reopened) != len(filters) {
+		t.Fatalf("Should reopen %v filters, got %v", len(filters), len(reopened))
+	}
+	for i := 0; i < 500; i++ {
+		if !bf.Exist([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%v should exist in filter", i)
+		}
+	}
+}
+
+func TestFilterGroup_RotateEvery(t *testing.T) {
+	os.Mkdir("testfile", os.ModePerm)
+	defer os.RemoveAll("testfile")
+	clock := NewManualClock(time.Unix(1e9, 0))
+	bf, err := NewGroup("testfile/*", FsyncModeNo, 1000, 1e-3, doubleFNV, WithRotationPolicy(RotateEvery(time.Hour, true, clock)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bf.Close()
+	bf.ExistOrAdd([]byte("first"))
+	bf.tasks.wait()
+	clock.Advance(30 * time.Minute)
+	bf.ExistOrAdd([]byte("second"))
+	bf.tasks.wait()
+	if n := len(bf.load()); n != 1 {
+		t.Fatalf("Should not rotate within the interval, got %v filters", n)
+	}
+	clock.Advance(30 * time.Minute)
+	bf.ExistOrAdd([]byte("third"))
+	bf.tasks.wait()
+	if n := len(bf.load()); n != 2 {
+		t.Fatalf("Should rotate after the interval, got %v filters", n)
+	}
+	// the interval of the new filter starts on its first entry
+	clock.Advance(time.Hour)
+	bf.ExistOrAdd([]byte("fourth"))
+	bf.tasks.wait()
+	if n := len(bf.load()); n != 2 {
+		t.Fatalf("Should not rotate on the first entry, got %v filters", n)
+	}
+	// full before the interval
+	for i := 0; bf.Active().FillRatio() == 0 || len(bf.load()) == 2; i++ {
+		bf.ExistOrAdd([]byte(fmt.Sprint(i)))
+		bf.tasks.wait()
+		if i > 2000 {
+			t.Fatal("Should rotate once full")
+		}
+	}
+}
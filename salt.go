@@ -0,0 +1,22 @@
+package disk_bloom
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+)
+
+// newSalt returns the salt of a new salted filter of the variant: salt if it is not zero, or a random one.
+func newSalt(salt uint64, v variant) (uint64, error) {
+	if v == variantXor || v == variantCuckoo {
+		return 0, fmt.Errorf("%w: salted %v filters", UnsupportedErr, v)
+	}
+	for salt == 0 {
+		var b [8]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return 0, err
+		}
+		salt = binary.LittleEndian.Uint64(b[:])
+	}
+	return salt, nil
+}
@@ -0,0 +1,79 @@
+package disk_bloom
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiskFilter_Salt(t *testing.T) {
+	dir := t.TempDir()
+	primary := openAckFilter(t, filepath.Join(dir, "primary"), Controller{Salted: true})
+	salt := primary.Header().Salt
+	if !primary.Header().Salted() || salt == 0 {
+		t.Fatalf("Should record a random salt, got %+v", primary.Header())
+	}
+	for i := 0; i < 100; i++ {
+		primary.ExistOrAdd([]byte(fmt.Sprint(i)))
+	}
+	if err := primary.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// reopened with the salt recorded
+	primary = openAckFilter(t, filepath.Join(dir, "primary"), Controller{})
+	defer primary.Close()
+	for i := 0; i < 100; i++ {
+		if !primary.Exist([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%v should exist after reopening", i)
+		}
+	}
+	if _, err := New(filepath.Join(dir, "primary"), Controller{Salt: salt + 1}); !errors.Is(err, InconsistentParamErr) {
+		t.Fatalf("Should fail to open with another salt, got %v", err)
+	}
+
+	// a replica of the same salt probes the same bits
+	replica := openAckFilter(t, filepath.Join(dir, "replica"), Controller{Salt: salt})
+	defer replica.Close()
+	other := openAckFilter(t, filepath.Join(dir, "other"), Controller{Salted: true})
+	defer other.Close()
+	for i := 0; i < 100; i++ {
+		replica.ExistOrAdd([]byte(fmt.Sprint(i)))
+		other.ExistOrAdd([]byte(fmt.Sprint(i)))
+	}
+	if replica.Fingerprint() != primary.Fingerprint() || other.Fingerprint() == primary.Fingerprint() {
+		t.Fatal("Should fingerprint the salt")
+	}
+	a, _, err := primary.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _, err := replica.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a) != string(b) {
+		t.Fatal("Should set the same bits in the replica")
+	}
+	h := primary.Hash([]byte("key"))
+	if primary.bloomOffset(h.X, h.Y, 0) == other.bloomOffset(h.X, h.Y, 0) &&
+		primary.bloomOffset(h.X, h.Y, 1) == other.bloomOffset(h.X, h.Y, 1) {
+		t.Fatal("Should probe other bits with another salt")
+	}
+	if err = primary.Merge(other); !errors.Is(err, InconsistentParamErr) {
+		t.Fatalf("Should fail to merge a filter of another salt, got %v", err)
+	}
+	if err = primary.Merge(replica); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiskFilter_SaltUnsupported(t *testing.T) {
+	if _, err := newSalt(0, variantXor); !errors.Is(err, UnsupportedErr) {
+		t.Fatalf("Should not salt xor filters, got %v", err)
+	}
+	if salt, err := newSalt(7, variantClassic); err != nil || salt != 7 {
+		t.Fatalf("Should keep the given salt, got %v, %v", salt, err)
+	}
+}
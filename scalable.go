@@ -0,0 +1,230 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// scalableGrowth is the ratio of the capacity of a segment to the one before it.
+	scalableGrowth = 2
+	// scalableTightening is the ratio of the false positive rate of a segment to the one before it.
+	scalableTightening = 0.5
+)
+
+// ScalableDiskFilter is a scalable Bloom filter (Almeida et al.), which grows instead of guessing n up front.
+// Once the active segment reaches its capacity, a new segment of twice the capacity and half the false positive rate
+// is appended, so the compound false positive rate stays below p however many entries are added.
+// Segment 0 is the given file, and segment i is the file with the suffix ".i". The number of entries added to each segment
+// is kept in its metadata, in the format of FilterGroup.
+type ScalableDiskFilter struct {
+	filename string
+	fsync    FsyncMode
+	n        uint64
+	p        float64
+	hash     func([]byte) (uint64, uint64)
+	// ExistOrAdd holds the read lock, and the growth holds the write lock.
+	mu       sync.RWMutex
+	segments []*filterObj
+}
+
+// NewScalable returns a ScalableDiskFilter. n is the expected number of entries of the first segment,
+// and p is the expected false positive rate of the whole filter.
+// Existing segments keep the parameters they were created with.
+func NewScalable(filename string, fsync FsyncMode, n uint64, p float64, hash func([]byte) (uint64, uint64)) (*ScalableDiskFilter, error) {
+	if n == 0 || p <= 0 || p >= 1 {
+		return nil, fmt.Errorf("invalid n %v or p %v", n, p)
+	}
+	s := &ScalableDiskFilter{
+		filename: filename,
+		fsync:    fsync,
+		n:        n,
+		p:        p,
+		hash:     hash,
+	}
+	for i := 0; ; i++ {
+		filename := s.segmentFilename(i)
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			break
+		}
+		obj, err := s.openSegment(filename)
+		if err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+		s.segments = append(s.segments, obj)
+	}
+	if len(s.segments) == 0 || s.full() {
+		if err := s.grow(); err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *ScalableDiskFilter) segmentFilename(i int) string {
+	if i == 0 {
+		return s.filename
+	}
+	return fmt.Sprintf("%v.%v", s.filename, i)
+}
+
+// openSegment opens an existing segment with the parameters in its metadata.
+func (s *ScalableDiskFilter) openSegment(filename string) (*filterObj, error) {
+	m, err := readGroupMetadata(filename)
+	if err != nil {
+		return nil, err
+	}
+	obj := &filterObj{filename: filename, added: m.Added, expected: m.Expected}
+	obj.filter, err = New(filename, Controller{
+		Fsync:        s.fsync,
+		MetadataSize: metadataSize,
+		Control:      obj.control,
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			return FilterParam{
+				Slots: m.Slots,
+				Bits:  m.Bits,
+				Hash:  s.hash,
+			}, nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// grow appends a new segment. It should be invoked with mu held, or before the filter is used.
+func (s *ScalableDiskFilter) grow() error {
+	i := len(s.segments)
+	expected, p := s.n, s.p*(1-scalableTightening)
+	for j := 0; j < i; j++ {
+		expected *= scalableGrowth
+		p *= scalableTightening
+	}
+	slots, bits := OptimalParam(expected, p)
+	param := FilterParam{Slots: slots, Bits: bits, Hash: s.hash}
+	obj := &filterObj{filename: s.segmentFilename(i), expected: expected}
+	filter, err := New(obj.filename, Controller{
+		Fsync:        s.fsync,
+		MetadataSize: metadataSize,
+		Control:      obj.control,
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			return param, Metadata{
+				Expected: expected,
+				Slots:    slots,
+				Bits:     bits,
+			}.Encode()
+		},
+	})
+	if err != nil {
+		return err
+	}
+	obj.filter = filter
+	s.segments = append(s.segments, obj)
+	return nil
+}
+
+// full returns whether the active segment reaches its capacity.
+func (s *ScalableDiskFilter) full() bool {
+	active := s.segments[len(s.segments)-1]
+	return atomic.LoadUint64(&active.added) >= active.expected
+}
+
+// Hash returns the double hash of an entry.
+func (s *ScalableDiskFilter) Hash(b []byte) KeyHash {
+	x, y := s.hash(b)
+	return KeyHash{X: x, Y: y}
+}
+
+// Exist returns if an entry is in any segment.
+func (s *ScalableDiskFilter) Exist(b []byte) bool {
+	h := s.Hash(b)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, obj := range s.segments {
+		if obj.filter.ExistHashed(h) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExistOrAdd returns whether the entry was in the filter, and adds it to the active segment if it was not in.
+func (s *ScalableDiskFilter) ExistOrAdd(b []byte) (exist bool) {
+	exist, _ = s.ExistOrAddErr(b)
+	return exist
+}
+
+// ExistOrAddErr is like ExistOrAdd, but returns the error if the entry failed to be added, or a segment failed to be appended.
+func (s *ScalableDiskFilter) ExistOrAddErr(b []byte) (exist bool, err error) {
+	exist, full, err := s.existOrAdd(s.Hash(b))
+	if err != nil || !full {
+		return exist, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.full() {
+		// grown by another ExistOrAdd
+		return exist, nil
+	}
+	return exist, s.grow()
+}
+
+func (s *ScalableDiskFilter) existOrAdd(h KeyHash) (exist bool, full bool, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, obj := range s.segments[:len(s.segments)-1] {
+		if obj.filter.ExistHashed(h) {
+			return true, false, nil
+		}
+	}
+	active := s.segments[len(s.segments)-1]
+	if exist, err = active.filter.existOrAddHashed(h); exist || err != nil {
+		return exist, false, err
+	}
+	return false, atomic.AddUint64(&active.added, 1) >= active.expected, nil
+}
+
+// Segments returns the number of segments.
+func (s *ScalableDiskFilter) Segments() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.segments)
+}
+
+// Count returns the approximate number of entries in the filter.
+func (s *ScalableDiskFilter) Count() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var count uint64
+	for _, obj := range s.segments {
+		count += atomic.LoadUint64(&obj.added)
+	}
+	return count
+}
+
+// EstimateFPR returns the estimated false positive rate of the filter, which is 1 - Π(1 - p_i) over the segments.
+func (s *ScalableDiskFilter) EstimateFPR() float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	pass := 1.0
+	for _, obj := range s.segments {
+		param := obj.filter.FilterParam()
+		pass *= 1 - EstimateFPR(param.Slots, param.Bits, atomic.LoadUint64(&obj.added))
+	}
+	return 1 - pass
+}
+
+// Close closes all segments.
+func (s *ScalableDiskFilter) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, obj := range s.segments {
+		_ = obj.filter.Close()
+	}
+	return nil
+}
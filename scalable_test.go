@@ -0,0 +1,69 @@
+package disk_bloom
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// doubleSHA256 is a double hash distributing sequential keys better than doubleFNV,
+// to check the false positive rate of the segments compounded.
+func doubleSHA256(b []byte) (uint64, uint64) {
+	sum := sha256.Sum256(b)
+	return binary.LittleEndian.Uint64(sum[:]), binary.LittleEndian.Uint64(sum[8:])
+}
+
+func TestScalableDiskFilter(t *testing.T) {
+	const n = 1000
+	const p = 1e-3
+	os.Mkdir("testfile", os.ModePerm)
+	defer os.RemoveAll("testfile")
+	filename := filepath.Join("testfile", "scalable")
+	s, err := NewScalable(filename, FsyncModeNo, n, p, doubleSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const added = 10 * n
+	for i := 0; i < added; i++ {
+		s.ExistOrAdd([]byte(fmt.Sprint(i)))
+	}
+	// 1000 + 2000 + 4000 < 10000 < 15000
+	if segments := s.Segments(); segments != 4 {
+		t.Fatalf("should have 4 segments, got %v", segments)
+	}
+	if fpr := s.EstimateFPR(); fpr > p {
+		t.Fatalf("estimated false positive rate %v exceeds %v", fpr, p)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = NewScalable(filename, FsyncModeNo, n, p, doubleSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if segments := s.Segments(); segments != 4 {
+		t.Fatalf("should reopen 4 segments, got %v", segments)
+	}
+	if count := s.Count(); count < added*999/1000 || count > added {
+		t.Fatalf("unexpected count %v", count)
+	}
+	for i := 0; i < added; i++ {
+		if !s.Exist([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%v should exist", i)
+		}
+	}
+	var fp int
+	for i := added; i < added+100000; i++ {
+		if s.Exist([]byte(fmt.Sprint(i))) {
+			fp++
+		}
+	}
+	if fp > 100000*p*2 {
+		t.Fatalf("too many false positives: %v", fp)
+	}
+}
@@ -0,0 +1,144 @@
+package disk_bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+)
+
+var UnscannableErr = fmt.Errorf("file can not be scanned")
+
+// FileStats describes a filter file scanned by ScanFile.
+type FileStats struct {
+	MetadataSize uint16
+	// Size of the bloom filter in bytes
+	Size uint64
+	// SetBits is the number of bits set in the bloom filter
+	SetBits uint64
+	// Slots is the number of hashes per entry, zero if unknown, which the caller can set for the estimations
+	// of the files created before the parameters were recorded in the header.
+	Slots uint8
+	// Healthy reports whether the file is as large as its layout requires
+	Healthy bool
+}
+
+// ScanFile reads a classic filter file without opening it as a DiskFilter, and counts the bits set.
+// The slots and the size are read from the header, or from the metadata of the files of a FilterGroup.
+// The encrypted files and the other variants, whose bits are not those of a bloom filter, fail with UnscannableErr.
+func ScanFile(filename string) (FileStats, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return FileStats{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return FileStats{}, err
+	}
+	var b [LenOfMetadataSize]byte
+	raw := retryStorage{f}
+	if _, err = raw.ReadAt(b[:], 0); err != nil {
+		return FileStats{}, err
+	}
+	stats := FileStats{
+		MetadataSize: binary.LittleEndian.Uint16(b[:]),
+		Healthy:      true,
+	}
+	header, err := readHeader(raw, stats.MetadataSize)
+	if err != nil {
+		return FileStats{}, err
+	}
+	if header.Encrypted() {
+		return FileStats{}, fmt.Errorf("%w: %v is encrypted", UnscannableErr, filename)
+	}
+	if v := header.variant(); v != variantClassic {
+		return FileStats{}, fmt.Errorf("%w: %v is a %v filter", UnscannableErr, filename, v)
+	}
+	bloomStart := header.bloomStart(stats.MetadataSize)
+	if header.Bits > 0 {
+		stats.Slots = header.Slots
+		stats.Size = header.Bits / 8
+		stats.Healthy = info.Size() >= bloomStart+header.bloomSize(header.Bits)
+	} else if stats.MetadataSize == metadataSize {
+		metadata := make([]byte, metadataSize)
+		if _, err = raw.ReadAt(metadata, LenOfMetadataSize); err != nil {
+			return FileStats{}, err
+		}
+		if m := parseMetadata(metadata); m.Bits > 0 {
+			stats.Slots = m.Slots
+			bits := header.bloomBits(m.Bits)
+			stats.Size = bits / 8
+			stats.Healthy = info.Size() >= bloomStart+header.bloomSize(bits)
+		}
+	}
+	var bloom io.ReaderAt = f
+	if header.Shrunk() {
+		size := header.bloomSize(stats.Size * 8)
+		if stats.Size == 0 && info.Size() >= bloomStart+8 {
+			// only the number of blocks is known
+			var b [8]byte
+			if _, err = raw.ReadAt(b[:], info.Size()-8); err != nil {
+				return FileStats{}, err
+			}
+			size = int64(binary.LittleEndian.Uint64(b[:])) * pageSize
+			stats.Size = uint64(size)
+		}
+		index, err := readBlockIndex(raw, info.Size(), bloomStart, size)
+		if err != nil {
+			stats.Healthy = false
+			return stats, nil
+		}
+		stats.Healthy = true
+		bloom = &blockedStorage{storage: raw, index: index}
+	} else if stats.Size == 0 && info.Size() > bloomStart {
+		stats.Size = uint64(info.Size() - bloomStart)
+		if !header.Aligned() {
+			// the file has a trailing byte allocated at creation
+			stats.Size--
+		}
+	}
+	buf := make([]byte, 1<<16)
+	r := io.NewSectionReader(bloom, bloomStart, int64(stats.Size))
+	for {
+		n, err := r.Read(buf)
+		for _, v := range buf[:n] {
+			stats.SetBits += uint64(bits.OnesCount8(v))
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return FileStats{}, err
+		}
+	}
+	return stats, nil
+}
+
+// FillRatio returns the fraction of bits set.
+func (s FileStats) FillRatio() float64 {
+	if s.Size == 0 {
+		return 0
+	}
+	return float64(s.SetBits) / float64(s.Size*8)
+}
+
+// EstimateCount estimates the number of entries added from the fill ratio: -(m/k)·ln(1-X/m).
+// It returns NaN if Slots is unknown.
+func (s FileStats) EstimateCount() float64 {
+	if s.Slots == 0 {
+		return math.NaN()
+	}
+	m := float64(s.Size * 8)
+	return -m / float64(s.Slots) * math.Log(1-float64(s.SetBits)/m)
+}
+
+// EstimateFPR estimates the false positive rate from the fill ratio.
+// It returns NaN if Slots is unknown.
+func (s FileStats) EstimateFPR() float64 {
+	if s.Slots == 0 {
+		return math.NaN()
+	}
+	return math.Pow(s.FillRatio(), float64(s.Slots))
+}
@@ -0,0 +1,58 @@
+package disk_bloom
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"testing"
+)
+
+func TestScanFile(t *testing.T) {
+	const n = 1e3
+	os.Mkdir("testfile", os.ModePerm)
+	bf, _ := NewGroup("testfile/*", FsyncModeNo, 10*n, 1e-4, doubleFNV)
+	defer func() {
+		os.RemoveAll("testfile")
+	}()
+	for i := 0; i < n; i++ {
+		bf.ExistOrAdd([]byte(fmt.Sprint(i)))
+	}
+	bf.Close()
+	stats, err := ScanFile("testfile/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stats.Healthy {
+		t.Fatal("Should be healthy but got false")
+	}
+	if count := stats.EstimateCount(); math.Abs(count-n) > n*0.05 {
+		t.Fatalf("Count should be about %v, got %v", n, count)
+	}
+	if fpr := stats.EstimateFPR(); fpr > 1e-4 {
+		t.Fatalf("FPR should be less than %v, got %v", 1e-4, fpr)
+	}
+}
+
+func TestScanFile_Unscannable(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo, EncryptionKey: make([]byte, 32)})
+	bf.Close()
+	if _, err := ScanFile("testfile"); !errors.Is(err, UnscannableErr) {
+		t.Fatalf("Should not scan an encrypted file, got %v", err)
+	}
+	os.Remove("testfile")
+	slots, bits := OptimalParam(1e3, 1e-4)
+	cf, err := NewCounting("testfile", Controller{
+		Fsync: FsyncModeNo,
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			return FilterParam{Slots: slots, Bits: bits, Hash: doubleFNV}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf.Close()
+	if _, err = ScanFile("testfile"); !errors.Is(err, UnscannableErr) {
+		t.Fatalf("Should not scan a counting filter, got %v", err)
+	}
+}
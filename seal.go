@@ -0,0 +1,97 @@
+package disk_bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+var SealedErr = fmt.Errorf("%w: sealed", ReadOnlyErr)
+
+// Sealed returns whether the file is permanently read-only.
+func (h Header) Sealed() bool {
+	return h.Flags&FlagSealed != 0
+}
+
+// Shrunk returns whether only the non-zero blocks of the bloom filter are kept.
+func (h Header) Shrunk() bool {
+	return h.Flags&FlagShrunk != 0
+}
+
+// Seal makes the filter permanently read-only: it sets FlagSealed in the header,
+// and all future opens of the file reject writes with SealedErr. It is for published filters,
+// e.g. blocklists, which must never be mutated in the field.
+// If Controller.ShrinkOnSeal is set, the bloom filter is rewritten to keep only its non-zero blocks.
+// Files created before the header was introduced can not be sealed.
+func (f *DiskFilter) Seal() error {
+	if f.controller.ShrinkOnSeal {
+		// shrinking moves the bloom filter under the lock-free lookups
+		if !f.exclusive() {
+			return ClosedErr
+		}
+		defer f.inflight.Unlock()
+	} else {
+		if !f.acquire() {
+			return ClosedErr
+		}
+		defer f.release()
+	}
+	f.file.mu.Lock()
+	defer f.file.mu.Unlock()
+	if f.header.Sealed() {
+		return nil
+	}
+	if f.controller.ReadOnly {
+		return ReadOnlyErr
+	}
+	if f.header.Version == 0 {
+		return fmt.Errorf("%w: the file has no header to seal", InvalidHeaderErr)
+	}
+	if len(f.pending) > 0 {
+		_ = f.flushPendingLocked()
+		if len(f.pending) > 0 {
+			return fmt.Errorf("failed to flush the buffered bits before sealing")
+		}
+	}
+	flags := f.header.Flags | FlagSealed
+	if f.controller.ShrinkOnSeal {
+		// the shrunk file is not mapped, nor kept in memory whose pages would be flushed over the moved blocks
+		if err := f.munmapLocked(); err != nil {
+			return err
+		}
+		if err := f.detachHybridLocked(); err != nil {
+			return err
+		}
+		if err := f.shrinkLocked(); err != nil {
+			return err
+		}
+		// the blocks of the shrunk bloom filter are moved
+		if err := f.removeChecksumsLocked(); err != nil {
+			return err
+		}
+		flags |= FlagShrunk
+	}
+	// signed for the last time with the flags
+	if err := f.writeSignedLocked(func(image []byte) {
+		binary.LittleEndian.PutUint16(image[LenOfMetadataSize+int(f.controller.MetadataSize)+headerFlagsOffset:], flags)
+	}); err != nil {
+		return err
+	}
+	if err := f.updateChecksumsLocked(); err != nil {
+		return err
+	}
+	if err := f.syncFile(); err != nil {
+		return err
+	}
+	f.header.Flags = flags
+	f.readOnly = true
+	f.file.modified = false
+	return nil
+}
+
+// readOnlyErr returns the error of writing to a read-only filter.
+func (f *DiskFilter) readOnlyErr() error {
+	if f.header.Sealed() {
+		return SealedErr
+	}
+	return ReadOnlyErr
+}
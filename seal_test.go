@@ -0,0 +1,39 @@
+package disk_bloom
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDiskFilter_Seal(t *testing.T) {
+	key := []byte("hmac key")
+	bf := newTestFilter(t, Controller{HMACKey: key})
+	buf := []byte("testing")
+	bf.ExistOrAdd(buf)
+	if err := bf.Seal(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bf.ExistOrAddErr([]byte("new")); !errors.Is(err, SealedErr) || !errors.Is(err, ReadOnlyErr) {
+		t.Fatalf("Should fail with SealedErr, got %v", err)
+	}
+	bf.Close()
+
+	h, err := Inspect("testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.Sealed() {
+		t.Fatalf("Should be sealed in the header, got %+v", h)
+	}
+	// the seal survives reopening, and the HMAC still verifies
+	bf = newTestFilter(t, Controller{HMACKey: key})
+	if !bf.Exist(buf) {
+		t.Fatal("Should exist in filter but got false")
+	}
+	if exist, err := bf.ExistOrAddErr([]byte("new")); exist || !errors.Is(err, SealedErr) {
+		t.Fatalf("Should fail with SealedErr, got %v %v", exist, err)
+	}
+	if bf.Exist([]byte("new")) {
+		t.Fatal("Should missing in sealed filter but got true")
+	}
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultNovelWait and maxNovelWait are the default and the largest wait of GET /novel
+	defaultNovelWait = 30 * time.Second
+	maxNovelWait     = 5 * time.Minute
+	// defaultNovelLimit is the default of the most keys returned by GET /novel
+	defaultNovelLimit = 1024
+)
+
+// Novel is the body of GET /novel?after=N&wait=D&limit=L, which returns the keys added for the first time
+// after the sequence number N, waiting up to D, e.g. "30s", for some if there are none yet.
+// Without after, it starts from the keys added after the request, whose first response gives the Next to follow.
+type Novel struct {
+	// Keys are the keys added for the first time in order, encoded in base64, up to L of them
+	Keys []string `json:"keys"`
+	// Next is the after of the next request, which is the sequence number of the last key returned
+	Next uint64 `json:"next"`
+	// Missed is the number of keys dropped from the buffer before they were returned
+	Missed uint64 `json:"missed,omitempty"`
+}
+
+// WithNovelKeys keeps the last size keys added for the first time by /add and /exist-or-add, which are long-polled
+// by GET /novel, so that the consumers mirror the first seen events without a message bus.
+// A consumer falling behind by more than size keys misses the keys dropped, which Novel.Missed counts.
+func WithNovelKeys(size int) Option {
+	return func(s *Server) {
+		if size > 0 {
+			s.novel = newNovelLog(size)
+		}
+	}
+}
+
+// novelLog is a ring of the last keys added for the first time, numbered from 1.
+type novelLog struct {
+	mu   sync.Mutex
+	keys [][]byte
+	// last is the sequence number of the last key
+	last uint64
+	// appended is closed once keys are appended
+	appended chan struct{}
+}
+
+func newNovelLog(size int) *novelLog {
+	return &novelLog{keys: make([][]byte, size), appended: make(chan struct{})}
+}
+
+// append appends the keys not existing.
+func (l *novelLog) append(keys [][]byte, exist []bool) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := l.last
+	for i, key := range keys {
+		if !exist[i] {
+			l.last++
+			l.keys[l.last%uint64(len(l.keys))] = append([]byte(nil), key...)
+		}
+	}
+	if l.last != n {
+		close(l.appended)
+		l.appended = make(chan struct{})
+	}
+}
+
+// since returns up to limit keys after the sequence number after, the number of the keys missed,
+// and the channel closed once more keys are appended.
+func (l *novelLog) since(after uint64, limit int) (novel Novel, appended <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	novel.Next = after
+	if after > l.last {
+		// from a log of the last run
+		novel.Next = l.last
+	}
+	if oldest := l.last - uint64(len(l.keys)); l.last > uint64(len(l.keys)) && novel.Next < oldest {
+		novel.Missed = oldest - novel.Next
+		novel.Next = oldest
+	}
+	novel.Keys = []string{}
+	for novel.Next < l.last && len(novel.Keys) < limit {
+		novel.Next++
+		novel.Keys = append(novel.Keys, base64.StdEncoding.EncodeToString(l.keys[novel.Next%uint64(len(l.keys))]))
+	}
+	return novel, l.appended
+}
+
+// serveNovel serves GET /novel.
+func (s *Server) serveNovel(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	wait, limit := defaultNovelWait, defaultNovelLimit
+	var after uint64
+	var err error
+	if v := query.Get("wait"); v != "" {
+		if wait, err = time.ParseDuration(v); err != nil || wait < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid wait %q", v))
+			return
+		}
+		if wait > maxNovelWait {
+			wait = maxNovelWait
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", v))
+			return
+		}
+	}
+	if v := query.Get("after"); v != "" {
+		if after, err = strconv.ParseUint(v, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid after %q", v))
+			return
+		}
+	} else {
+		s.novel.mu.Lock()
+		after = s.novel.last
+		s.novel.mu.Unlock()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		novel, appended := s.novel.since(after, limit)
+		if len(novel.Keys) > 0 || novel.Missed > 0 {
+			writeJSON(w, http.StatusOK, novel)
+			return
+		}
+		select {
+		case <-appended:
+		case <-timer.C:
+			writeJSON(w, http.StatusOK, novel)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,260 @@
+// Package server serves the operations of a filter over HTTP with JSON,
+// so that services written in other languages can share one filter. The client package is a Go client of it.
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	disk_bloom "github.com/mzz2017/disk-bloom"
+)
+
+// maxBodySize is the largest request body accepted.
+const maxBodySize = 32 << 20
+
+// Filter is the filter served, e.g. *disk_bloom.DiskFilter, *disk_bloom.FilterGroup or *disk_bloom.ShardedFilter.
+type Filter interface {
+	Exist(b []byte) bool
+	ExistOrAdd(b []byte) bool
+}
+
+// The optional methods of a Filter, used if it implements them.
+type (
+	existOrAddErr interface {
+		ExistOrAddErr(b []byte) (bool, error)
+	}
+	existBatch interface {
+		ExistBatch(keys [][]byte) []bool
+	}
+	existOrAddBatchErr interface {
+		ExistOrAddBatchErr(keys [][]byte) ([]bool, error)
+	}
+	fillRatio interface {
+		FillRatio() float64
+	}
+	estimateCount interface {
+		EstimateCount() float64
+	}
+	estimateFPR interface {
+		EstimateFPR() float64
+	}
+	minuteStats interface {
+		Stats() disk_bloom.Stats
+	}
+)
+
+// Request is the body of POST /exist, /add and /exist-or-add. A single key is a batch of one.
+type Request struct {
+	Keys []string `json:"keys"`
+	// Encoding of the keys, "base64" for binary keys, or empty for the UTF-8 strings as they are
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// Response is the body of the responses of POST requests.
+type Response struct {
+	// Exist reports whether each of the keys was in the filter, omitted by /add
+	Exist []bool `json:"exist,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Stats is the body of GET /stats. The fields the filter does not provide are omitted.
+type Stats struct {
+	// Lookups is the number of keys looked up by /exist
+	Lookups uint64 `json:"lookups"`
+	// Adds is the number of keys given to /add and /exist-or-add
+	Adds uint64 `json:"adds"`
+	// Hits is the number of keys found in the filter by /exist and /exist-or-add
+	Hits           uint64                   `json:"hits"`
+	FillRatio      *float64                 `json:"fill_ratio,omitempty"`
+	EstimatedCount *float64                 `json:"estimated_count,omitempty"`
+	EstimatedFPR   *float64                 `json:"estimated_fpr,omitempty"`
+	Minutes        []disk_bloom.MinuteStats `json:"minutes,omitempty"`
+}
+
+// Server is an http.Handler serving a Filter:
+// POST /exist, /add and /exist-or-add take a Request and return a Response, GET /stats returns Stats,
+// and GET /novel long-polls the keys added for the first time, see WithNovelKeys.
+// Mount it with http.StripPrefix to serve under a path.
+type Server struct {
+	filter  Filter
+	lookups uint64
+	adds    uint64
+	hits    uint64
+	novel   *novelLog
+}
+
+// Option configures a Server.
+type Option func(s *Server)
+
+// New returns a Server serving the filter, which is not closed by the Server.
+func New(filter Filter, opts ...Option) *Server {
+	s := &Server{filter: filter}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/exist", "/add", "/exist-or-add":
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			writeError(w, http.StatusMethodNotAllowed, errors.New(http.StatusText(http.StatusMethodNotAllowed)))
+			return
+		}
+		keys, err := readKeys(w, r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		var resp Response
+		switch r.URL.Path {
+		case "/exist":
+			resp.Exist = s.exist(keys)
+		case "/add":
+			_, err = s.existOrAdd(keys)
+		default:
+			resp.Exist, err = s.existOrAdd(keys)
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	case "/stats":
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeError(w, http.StatusMethodNotAllowed, errors.New(http.StatusText(http.StatusMethodNotAllowed)))
+			return
+		}
+		writeJSON(w, http.StatusOK, s.Stats())
+	case "/novel":
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			writeError(w, http.StatusMethodNotAllowed, errors.New(http.StatusText(http.StatusMethodNotAllowed)))
+			return
+		}
+		if s.novel == nil {
+			writeError(w, http.StatusNotFound, errors.New("novel keys are not kept, see WithNovelKeys"))
+			return
+		}
+		s.serveNovel(w, r)
+	default:
+		writeError(w, http.StatusNotFound, errors.New(http.StatusText(http.StatusNotFound)))
+	}
+}
+
+// Stats returns the counters of the Server and the statistics provided by the filter.
+func (s *Server) Stats() Stats {
+	stats := Stats{
+		Lookups: atomic.LoadUint64(&s.lookups),
+		Adds:    atomic.LoadUint64(&s.adds),
+		Hits:    atomic.LoadUint64(&s.hits),
+	}
+	if f, ok := s.filter.(fillRatio); ok {
+		v := f.FillRatio()
+		stats.FillRatio = &v
+	}
+	if f, ok := s.filter.(estimateCount); ok {
+		v := f.EstimateCount()
+		stats.EstimatedCount = &v
+	}
+	if f, ok := s.filter.(estimateFPR); ok {
+		v := f.EstimateFPR()
+		stats.EstimatedFPR = &v
+	}
+	if f, ok := s.filter.(minuteStats); ok {
+		stats.Minutes = f.Stats().Minutes
+	}
+	return stats
+}
+
+func (s *Server) exist(keys [][]byte) []bool {
+	var exist []bool
+	if f, ok := s.filter.(existBatch); ok {
+		exist = f.ExistBatch(keys)
+	} else {
+		exist = make([]bool, len(keys))
+		for i, key := range keys {
+			exist[i] = s.filter.Exist(key)
+		}
+	}
+	atomic.AddUint64(&s.lookups, uint64(len(keys)))
+	s.countHits(exist)
+	return exist
+}
+
+func (s *Server) existOrAdd(keys [][]byte) (exist []bool, err error) {
+	switch f := s.filter.(type) {
+	case existOrAddBatchErr:
+		exist, err = f.ExistOrAddBatchErr(keys)
+	case existOrAddErr:
+		exist = make([]bool, len(keys))
+		for i, key := range keys {
+			if exist[i], err = f.ExistOrAddErr(key); err != nil {
+				break
+			}
+		}
+	default:
+		exist = make([]bool, len(keys))
+		for i, key := range keys {
+			exist[i] = s.filter.ExistOrAdd(key)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddUint64(&s.adds, uint64(len(keys)))
+	s.countHits(exist)
+	s.novel.append(keys, exist)
+	return exist, nil
+}
+
+func (s *Server) countHits(exist []bool) {
+	var hits uint64
+	for _, e := range exist {
+		if e {
+			hits++
+		}
+	}
+	atomic.AddUint64(&s.hits, hits)
+}
+
+// readKeys decodes the keys of a Request.
+func readKeys(w http.ResponseWriter, r *http.Request) ([][]byte, error) {
+	var req Request
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&req); err != nil {
+		return nil, err
+	}
+	keys := make([][]byte, len(req.Keys))
+	for i, key := range req.Keys {
+		switch req.Encoding {
+		case "":
+			keys[i] = []byte(key)
+		case "base64":
+			b, err := base64.StdEncoding.DecodeString(key)
+			if err != nil {
+				return nil, fmt.Errorf("key %v: %w", i, err)
+			}
+			keys[i] = b
+		default:
+			return nil, fmt.Errorf("unknown encoding %q", req.Encoding)
+		}
+	}
+	return keys, nil
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, Response{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
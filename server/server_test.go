@@ -0,0 +1,177 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	disk_bloom "github.com/mzz2017/disk-bloom"
+)
+
+func doubleFNV(b []byte) (uint64, uint64) {
+	hx := fnv.New64()
+	hx.Write(b)
+	x := hx.Sum64()
+	hy := fnv.New64a()
+	hy.Write(b)
+	y := hy.Sum64()
+	return x, y
+}
+
+func post(t *testing.T, url string, req Request) (int, Response) {
+	b, _ := json.Marshal(req)
+	resp, err := http.Post(url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var r Response
+	if err = json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, r
+}
+
+func TestServer(t *testing.T) {
+	slots, bits := disk_bloom.OptimalParam(1000, 0.001)
+	f, err := disk_bloom.New(filepath.Join(t.TempDir(), "testfile"), disk_bloom.Controller{
+		Fsync: disk_bloom.FsyncModeNo,
+		GetParam: func(metadata []byte) (disk_bloom.FilterParam, []byte) {
+			return disk_bloom.FilterParam{Slots: slots, Bits: bits, HashKind: disk_bloom.HashKindXXHash64}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	s := New(f)
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	if code, r := post(t, server.URL+"/exist-or-add", Request{Keys: []string{"a", "b", "a"}}); code != http.StatusOK ||
+		len(r.Exist) != 3 || r.Exist[0] || r.Exist[1] || !r.Exist[2] {
+		t.Fatalf("Unexpected response %v %+v", code, r)
+	}
+	binary := base64.StdEncoding.EncodeToString([]byte{0, 0xff})
+	if code, r := post(t, server.URL+"/add", Request{Keys: []string{binary}, Encoding: "base64"}); code != http.StatusOK || r.Exist != nil {
+		t.Fatalf("Unexpected response %v %+v", code, r)
+	}
+	if !f.Exist([]byte{0, 0xff}) {
+		t.Fatal("the binary key should be added")
+	}
+	if code, r := post(t, server.URL+"/exist", Request{Keys: []string{"b", "c"}}); code != http.StatusOK ||
+		len(r.Exist) != 2 || !r.Exist[0] || r.Exist[1] {
+		t.Fatalf("Unexpected response %v %+v", code, r)
+	}
+	if code, r := post(t, server.URL+"/exist", Request{Keys: []string{"!"}, Encoding: "base64"}); code != http.StatusBadRequest || r.Error == "" {
+		t.Fatalf("Should reject the invalid key, got %v %+v", code, r)
+	}
+	resp, err := http.Get(server.URL + "/exist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("Should allow POST only, got %v", resp.StatusCode)
+	}
+
+	resp, err = http.Get(server.URL + "/stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats Stats
+	err = json.NewDecoder(resp.Body).Decode(&stats)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Lookups != 2 || stats.Adds != 4 || stats.Hits != 2 || stats.EstimatedCount == nil || stats.EstimatedFPR != nil {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestServer_FilterGroup(t *testing.T) {
+	g, err := disk_bloom.NewGroup(filepath.Join(t.TempDir(), "group-*"), disk_bloom.FsyncModeNo, 1000, 0.001, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	server := httptest.NewServer(New(g))
+	defer server.Close()
+	if code, r := post(t, server.URL+"/exist-or-add", Request{Keys: []string{"a", "a"}}); code != http.StatusOK ||
+		len(r.Exist) != 2 || r.Exist[0] || !r.Exist[1] {
+		t.Fatalf("Unexpected response %v %+v", code, r)
+	}
+	if stats := New(g).Stats(); stats.EstimatedFPR == nil || stats.FillRatio != nil {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+}
+
+func getNovel(t *testing.T, url string) Novel {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Unexpected status %v", resp.Status)
+	}
+	var novel Novel
+	if err = json.NewDecoder(resp.Body).Decode(&novel); err != nil {
+		t.Fatal(err)
+	}
+	return novel
+}
+
+func TestServer_Novel(t *testing.T) {
+	g, err := disk_bloom.NewGroup(filepath.Join(t.TempDir(), "group-*"), disk_bloom.FsyncModeNo, 1000, 0.001, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	server := httptest.NewServer(New(g, WithNovelKeys(2)))
+	defer server.Close()
+
+	post(t, server.URL+"/exist-or-add", Request{Keys: []string{"a", "a"}})
+	novel := getNovel(t, server.URL+"/novel?after=0")
+	if len(novel.Keys) != 1 || novel.Keys[0] != base64.StdEncoding.EncodeToString([]byte("a")) || novel.Next != 1 {
+		t.Fatalf("Unexpected %+v", novel)
+	}
+
+	// long-polled until added
+	got := make(chan Novel, 1)
+	go func() {
+		got <- getNovel(t, server.URL+"/novel?after=1&wait=10s")
+	}()
+	time.Sleep(20 * time.Millisecond)
+	post(t, server.URL+"/add", Request{Keys: []string{"a", "b"}})
+	if novel = <-got; len(novel.Keys) != 1 || novel.Keys[0] != base64.StdEncoding.EncodeToString([]byte("b")) || novel.Next != 2 {
+		t.Fatalf("Unexpected %+v", novel)
+	}
+	if novel = getNovel(t, server.URL+"/novel?wait=1ms"); len(novel.Keys) != 0 || novel.Next != 2 {
+		t.Fatalf("Should start from now without after, got %+v", novel)
+	}
+
+	// fallen behind the buffer
+	post(t, server.URL+"/add", Request{Keys: []string{"c", "d", "e"}})
+	if novel = getNovel(t, server.URL+"/novel?after=1&limit=1"); novel.Missed != 2 || len(novel.Keys) != 1 || novel.Next != 4 {
+		t.Fatalf("Unexpected %+v", novel)
+	}
+
+	server2 := httptest.NewServer(New(g))
+	defer server2.Close()
+	resp, err := http.Get(server2.URL + "/novel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Should not serve novel keys by default, got %v", resp.Status)
+	}
+}
@@ -0,0 +1,110 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"math/bits"
+	"path/filepath"
+)
+
+// ShardedFilter spreads the entries over shard files placed across several directories, e.g. on hosts with several
+// small disks rather than one large one. An entry is routed to a shard by its hash.
+type ShardedFilter struct {
+	shards []*DiskFilter
+}
+
+// NewShardedAcross creates or opens shards filter files, placing the shard i in paths[i%len(paths)]
+// as shard-<i>-of-<shards>, so that reopening with another number of shards never misroutes the entries.
+// Every shard is opened with the controller, whose GetParam gives the parameters of a shard,
+// which should be sized for 1/shards of the entries.
+func NewShardedAcross(paths []string, shards int, controller Controller) (*ShardedFilter, error) {
+	if len(paths) == 0 || shards <= 0 {
+		return nil, fmt.Errorf("%w: paths and shards are required", MissingParamErr)
+	}
+	s := &ShardedFilter{}
+	for i := 0; i < shards; i++ {
+		filename := filepath.Join(paths[i%len(paths)], fmt.Sprintf("shard-%d-of-%d", i, shards))
+		f, err := New(filename, controller)
+		if err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+		s.shards = append(s.shards, f)
+	}
+	return s, nil
+}
+
+// shard returns the shard of the hash. The bits of the hash mixed are independent of the offsets within a shard.
+func (s *ShardedFilter) shard(h KeyHash) *DiskFilter {
+	i, _ := bits.Mul64(h.X^bits.RotateLeft64(h.Y, 32), uint64(len(s.shards)))
+	return s.shards[i]
+}
+
+// Shards returns the shards, the shard i first.
+func (s *ShardedFilter) Shards() []*DiskFilter {
+	return append([]*DiskFilter(nil), s.shards...)
+}
+
+// Hash returns the double hash of an entry.
+func (s *ShardedFilter) Hash(b []byte) KeyHash {
+	return s.shards[0].Hash(b)
+}
+
+// Exist returns if an entry is in the filter
+func (s *ShardedFilter) Exist(b []byte) bool {
+	return s.ExistHashed(s.Hash(b))
+}
+
+// ExistHashed is like Exist, but takes the hash of the entry.
+func (s *ShardedFilter) ExistHashed(h KeyHash) bool {
+	return s.shard(h).ExistHashed(h)
+}
+
+// ExistOrAdd returns whether the entry was in the filter, and adds an entry to the filter if it was not in.
+func (s *ShardedFilter) ExistOrAdd(b []byte) bool {
+	h := s.Hash(b)
+	return s.shard(h).ExistOrAddHashed(h)
+}
+
+// ExistOrAddErr is like ExistOrAdd, but returns the error if the entry failed to be added.
+func (s *ShardedFilter) ExistOrAddErr(b []byte) (bool, error) {
+	h := s.Hash(b)
+	return s.shard(h).existOrAddHashed(h)
+}
+
+// FillRatio returns the average fill ratio of the shards.
+func (s *ShardedFilter) FillRatio() float64 {
+	var sum float64
+	for _, f := range s.shards {
+		sum += f.FillRatio()
+	}
+	return sum / float64(len(s.shards))
+}
+
+// EstimateCount returns the sum of the entries estimated in the shards, see DiskFilter.EstimateCount.
+func (s *ShardedFilter) EstimateCount() float64 {
+	var sum float64
+	for _, f := range s.shards {
+		sum += f.EstimateCount()
+	}
+	return sum
+}
+
+// Size returns the total size of the bloom filters of the shards in bytes.
+func (s *ShardedFilter) Size() uint64 {
+	var sum uint64
+	for _, f := range s.shards {
+		sum += f.Size()
+	}
+	return sum
+}
+
+// Close closes every shard, and returns the first error.
+func (s *ShardedFilter) Close() error {
+	var err error
+	for _, f := range s.shards {
+		if e := f.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,54 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShardedFilter(t *testing.T) {
+	paths := []string{t.TempDir(), t.TempDir()}
+	controller := Controller{
+		Fsync: FsyncModeNo,
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			slots, bits := OptimalParam(1000, 1e-3)
+			return FilterParam{Slots: slots, Bits: bits, HashKind: HashKindXXHash64}, nil
+		},
+	}
+	s, err := NewShardedAcross(paths, 4, controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2000; i++ {
+		s.ExistOrAdd([]byte(fmt.Sprint(i)))
+	}
+	for i, f := range s.Shards() {
+		if count := f.EstimateCount(); math.Abs(count-500) > 100 {
+			t.Fatalf("shard %v should have about 500 entries, got %v", i, count)
+		}
+	}
+	if count := s.EstimateCount(); math.Abs(count-2000) > 100 {
+		t.Fatalf("count should be about 2000, got %v", count)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err = os.Stat(filepath.Join(paths[i%2], fmt.Sprintf("shard-%d-of-4", i))); err != nil {
+			t.Fatalf("shard %v should be placed round-robin: %v", i, err)
+		}
+	}
+
+	s, err = NewShardedAcross(paths, 4, controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for i := 0; i < 2000; i++ {
+		if !s.Exist([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%v should exist after reopening", i)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// sharedHeaderSize is the size of the header at the beginning of the shared memory segment,
+// which is followed by a bit per page of the bloom filter set once the page is changed, so that only the dirty pages
+// are persisted.
+//
+// | change counter(8) | reserved(56) | dirty pages | bloom filter |
+const sharedHeaderSize = 64
+
+// LeaderFilename returns the filename of the lock electing the process persisting the SharedFilter of the filter file filename.
+func LeaderFilename(filename string) string {
+	return filename + ".leader"
+}
+
+// sharedDirtySize returns the size of the bits of the dirty pages of a bloom filter of bitmapLen bytes,
+// in whole uint32 words.
+func sharedDirtySize(bitmapLen int64) int64 {
+	pages := (bitmapLen + pageSize - 1) / pageSize
+	return (pages + 31) / 32 * 4
+}
+
+var UnsupportedErr = fmt.Errorf("not supported on this platform")
+
+// nativeLittleEndian reports whether the uint32 words in the shared memory are little-endian.
+var nativeLittleEndian = func() bool {
+	x := uint32(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}()
+
+// SharedFilter is a DiskFilter whose bloom filter lives in a shared memory segment,
+// so that several processes on one host can look up and add concurrently without locks or syscalls.
+// One of the processes is elected by the lock of LeaderFilename to persist the pages changed into the file every second,
+// and another process takes over if it exits.
+type SharedFilter struct {
+	disk    *DiskFilter
+	segment sharedSegment
+	// mem is the whole mapped segment, and dirty and bitmap are the dirty pages and the bloom filter parts of it
+	mem    []byte
+	dirty  []byte
+	bitmap []byte
+	closed chan struct{}
+	tasks  *taskGroup
+
+	mu        sync.Mutex
+	persister bool
+	persisted uint64
+}
+
+// sharedSegment is the platform-specific shared memory segment.
+type sharedSegment interface {
+	// tryElect tries to become the persister of the file. It does not block.
+	tryElect() bool
+	close() error
+}
+
+func (s *SharedFilter) counter() *uint64 {
+	return (*uint64)(unsafe.Pointer(&s.mem[0]))
+}
+
+// word returns the uint32 word containing the given bit and the mask of the bit in the word.
+func (s *SharedFilter) word(offset uint64) (*uint32, uint32) {
+	i := offset / 8
+	shift := 8*(i%4) + offset%8
+	if !nativeLittleEndian {
+		shift = 8*(3-i%4) + offset%8
+	}
+	return (*uint32)(unsafe.Pointer(&s.bitmap[i/4*4])), 1 << shift
+}
+
+// markDirty marks the page of the bloom filter containing the given bit as changed.
+func (s *SharedFilter) markDirty(offset uint64) {
+	page := offset / 8 / pageSize
+	word, mask := (*uint32)(unsafe.Pointer(&s.dirty[page/32*4])), uint32(1)<<(page%32)
+	for {
+		old := atomic.LoadUint32(word)
+		if old&mask != 0 || atomic.CompareAndSwapUint32(word, old, old|mask) {
+			return
+		}
+	}
+}
+
+// Exist returns if an entry is in the filter
+func (s *SharedFilter) Exist(b []byte) bool {
+	param := s.disk.param
+	x, y := param.Hash(b)
+	for i := 0; i < s.disk.slots(); i++ {
+		word, mask := s.word(s.disk.bloomOffset(x, y, i))
+		if atomic.LoadUint32(word)&mask == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ExistOrAdd returns whether the entry was in the filter, and adds an entry to the filter if it was not in.
+func (s *SharedFilter) ExistOrAdd(b []byte) (exist bool) {
+	param := s.disk.param
+	x, y := param.Hash(b)
+	exist = true
+	for i := 0; i < s.disk.slots(); i++ {
+		offset := s.disk.bloomOffset(x, y, i)
+		word, mask := s.word(offset)
+		for {
+			old := atomic.LoadUint32(word)
+			if old&mask != 0 {
+				break
+			}
+			if atomic.CompareAndSwapUint32(word, old, old|mask) {
+				s.markDirty(offset)
+				exist = false
+				break
+			}
+		}
+	}
+	if !exist {
+		atomic.AddUint64(s.counter(), 1)
+	}
+	return exist
+}
+
+// Persister returns whether this process is persisting the shared memory into the file.
+func (s *SharedFilter) Persister() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.persister
+}
+
+// persist writes the pages of the shared memory changed into the file if this process is the persister.
+func (s *SharedFilter) persist() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all := false
+	if !s.persister {
+		if s.persister = s.segment.tryElect(); !s.persister {
+			return nil
+		}
+		// write all the pages, since the last persister may exit between taking the dirty pages and writing them
+		all = true
+	}
+	counter := atomic.LoadUint64(s.counter())
+	if counter == s.persisted && !all {
+		return nil
+	}
+	f := &s.disk.file
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// the pages taken in this round by the words, which are marked changed again if they fail to be persisted
+	taken := make([]uint32, len(s.dirty)/4)
+	failed := func(err error) error {
+		for i, dirty := range taken {
+			if dirty != 0 {
+				s.restoreDirty((*uint32)(unsafe.Pointer(&s.dirty[i*4])), dirty)
+			}
+		}
+		return err
+	}
+	for i := 0; i < len(s.dirty); i += 4 {
+		word := (*uint32)(unsafe.Pointer(&s.dirty[i]))
+		dirty := atomic.SwapUint32(word, 0)
+		if all {
+			dirty = ^uint32(0)
+		}
+		taken[i/4] = dirty
+		for bit := 0; dirty>>bit != 0; bit++ {
+			if dirty&(1<<bit) == 0 {
+				continue
+			}
+			from := int64(i/4*32+bit) * pageSize
+			if from >= int64(len(s.bitmap)) {
+				break
+			}
+			to := from + pageSize
+			if to > int64(len(s.bitmap)) {
+				to = int64(len(s.bitmap))
+			}
+			if _, err := f.rw.WriteAt(s.bitmap[from:to], s.disk.fileOffset(from)); err != nil {
+				// written by the next persist
+				s.disk.noteIO(ioOpWrite, err)
+				return failed(err)
+			}
+			s.disk.checksums.markDirty(from, to-from)
+		}
+	}
+	s.disk.noteIO(ioOpWrite, nil)
+	if s.disk.controller.Fsync != FsyncModeNo {
+		if err := s.disk.syncFile(); err != nil {
+			// written and synced again by the next persist
+			return failed(err)
+		}
+	}
+	s.persisted = counter
+	return nil
+}
+
+// restoreDirty marks the pages of dirty in the word as changed again.
+func (s *SharedFilter) restoreDirty(word *uint32, dirty uint32) {
+	for {
+		old := atomic.LoadUint32(word)
+		if atomic.CompareAndSwapUint32(word, old, old|dirty) {
+			return
+		}
+	}
+}
+
+func (s *SharedFilter) persistEverySec(t *task) {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done():
+			return
+		case <-ticker.C:
+			_ = t.report(s.persist())
+		}
+	}
+}
+
+// Tasks returns the state of the background tasks of the filter, i.e. persisting the segment every second,
+// with their last errors.
+func (s *SharedFilter) Tasks() []TaskStats {
+	return s.tasks.stats()
+}
+
+// Close persists the shared memory if this process is the persister, and releases the resources.
+// The shared memory segment itself is kept for other processes.
+func (s *SharedFilter) Close() error {
+	select {
+	case <-s.closed:
+		return nil
+	default:
+	}
+	close(s.closed)
+	s.tasks.wait()
+	err := s.persist()
+	if e := s.segment.close(); err == nil {
+		err = e
+	}
+	if e := s.disk.Close(); err == nil {
+		err = e
+	}
+	return err
+}
@@ -0,0 +1,112 @@
+package disk_bloom
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+)
+
+type shmSegment struct {
+	shm *os.File
+	// leader is the lock file of LeaderFilename
+	leader *os.File
+	mem    []byte
+}
+
+// NewShared creates a SharedFilter. The bloom filter is placed in the shared memory segment /dev/shm/<name>,
+// which is loaded from the file by the first process opening it.
+// All processes sharing the segment must use the same filename and controller.
+func NewShared(filename string, name string, controller Controller) (*SharedFilter, error) {
+	disk, err := New(filename, controller)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newShared(disk, filepath.Join("/dev/shm", name))
+	if err != nil {
+		_ = disk.Close()
+		return nil, err
+	}
+	s.tasks.start("persist", s.persistEverySec)
+	return s, nil
+}
+
+func newShared(disk *DiskFilter, shmPath string) (*SharedFilter, error) {
+	shm, err := os.OpenFile(shmPath, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	// hold an exclusive lock during initialization, so that only one process loads the file
+	if err = syscall.Flock(int(shm.Fd()), syscall.LOCK_EX); err != nil {
+		_ = shm.Close()
+		return nil, err
+	}
+	defer syscall.Flock(int(shm.Fd()), syscall.LOCK_UN)
+	// round up to whole words, since the bloom filter is accessed by uint32
+	bitmapLen := int64((disk.param.Bits + 7) / 8)
+	dirtyLen := sharedDirtySize(bitmapLen)
+	size := sharedHeaderSize + dirtyLen + (bitmapLen+3)/4*4
+	info, err := shm.Stat()
+	if err != nil {
+		_ = shm.Close()
+		return nil, err
+	}
+	fresh := info.Size() == 0
+	if fresh {
+		if err = shm.Truncate(size); err != nil {
+			_ = shm.Close()
+			return nil, err
+		}
+	} else if info.Size() != size {
+		_ = shm.Close()
+		return nil, InconsistentMetadataSizeErr
+	}
+	// the election does not lock the filter file itself, which Controller.FileLock locks
+	leader, err := os.OpenFile(LeaderFilename(disk.file.f.Name()), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		_ = shm.Close()
+		return nil, err
+	}
+	mem, err := syscall.Mmap(int(shm.Fd()), 0, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		_ = leader.Close()
+		_ = shm.Close()
+		return nil, err
+	}
+	segment := &shmSegment{shm: shm, leader: leader, mem: mem}
+	bitmapStart := sharedHeaderSize + dirtyLen
+	s := &SharedFilter{
+		disk:    disk,
+		segment: segment,
+		mem:     mem,
+		dirty:   mem[sharedHeaderSize:bitmapStart],
+		bitmap:  mem[bitmapStart : bitmapStart+bitmapLen],
+		closed:  make(chan struct{}),
+	}
+	s.tasks = newTaskGroup(s.closed)
+	if fresh {
+		if _, err = disk.file.rw.ReadAt(s.bitmap, disk.fileOffset(0)); err != nil {
+			_ = segment.close()
+			return nil, err
+		}
+	}
+	s.persister = segment.tryElect()
+	s.persisted = atomic.LoadUint64(s.counter())
+	return s, nil
+}
+
+func (s *shmSegment) tryElect() bool {
+	return syscall.Flock(int(s.leader.Fd()), syscall.LOCK_EX|syscall.LOCK_NB) == nil
+}
+
+func (s *shmSegment) close() error {
+	err := syscall.Munmap(s.mem)
+	if e := s.shm.Close(); err == nil {
+		err = e
+	}
+	// releases the lock of the election
+	if e := s.leader.Close(); err == nil {
+		err = e
+	}
+	return err
+}
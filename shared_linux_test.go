@@ -0,0 +1,160 @@
+package disk_bloom
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"os"
+	"testing"
+)
+
+func TestSharedFilter(t *testing.T) {
+	name := fmt.Sprintf("disk-bloom-test-%v", os.Getpid())
+	controller := Controller{
+		Fsync: FsyncModeNo,
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			slots, bits := OptimalParam(1e4, 1e-4)
+			return FilterParam{
+				Slots: slots,
+				Bits:  bits,
+				Hash:  doubleFNV,
+			}, nil
+		},
+	}
+	a, err := NewShared("testfile", name, controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		os.Remove("testfile")
+		os.Remove(LeaderFilename("testfile"))
+		os.Remove("/dev/shm/" + name)
+	}()
+	b, err := NewShared("testfile", name, controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.Persister() || b.Persister() {
+		t.Fatal("Only the first filter should be elected")
+	}
+	buf := []byte("testing")
+	if b.ExistOrAdd(buf) {
+		t.Fatal("Should missing in filter but got true")
+	}
+	if !a.Exist(buf) {
+		t.Fatal("Should be shared but got false")
+	}
+	if a.Exist([]byte("not-exists")) {
+		t.Fatal("Should missing in filter but got true")
+	}
+
+	// only the dirty pages are persisted
+	if err = a.persist(); err != nil {
+		t.Fatal(err)
+	}
+	pages := func(b []byte) map[int64]bool {
+		x, y := a.disk.param.Hash(b)
+		pages := make(map[int64]bool)
+		for i := 0; i < a.disk.slots(); i++ {
+			pages[int64(a.disk.bloomOffset(x, y, i)/8/pageSize)] = true
+		}
+		return pages
+	}
+	var key []byte
+	clean := int64(-1)
+	for i := 0; clean < 0; i++ {
+		key = []byte(fmt.Sprint("key", i))
+		dirty := pages(key)
+		for page := int64(0); page*pageSize < int64(len(a.bitmap)); page++ {
+			if !dirty[page] {
+				clean = page
+				break
+			}
+		}
+	}
+	file, err := os.OpenFile("testfile", os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	offset := a.disk.fileOffset(clean * pageSize)
+	if _, err = file.WriteAt([]byte{0xaa}, offset); err != nil {
+		t.Fatal(err)
+	}
+	b.ExistOrAdd(key)
+	if err = a.persist(); err != nil {
+		t.Fatal(err)
+	}
+	var marker [1]byte
+	if _, err = file.ReadAt(marker[:], offset); err != nil || marker[0] != 0xaa {
+		t.Fatalf("Should not rewrite the clean page %v, got %#x %v", clean, marker[0], err)
+	}
+	if _, err = file.WriteAt([]byte{a.bitmap[clean*pageSize]}, offset); err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+	a.Close()
+
+	bf, err := New("testfile", controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bf.Close()
+	if !bf.Exist(buf) {
+		t.Fatal("Should be persisted into the file but got false")
+	}
+}
+
+func TestSharedFilter_PersistSyncFailed(t *testing.T) {
+	name := fmt.Sprintf("disk-bloom-test-%v", os.Getpid())
+	injector := NewFaultInjector(1)
+	controller := Controller{
+		Fsync:         FsyncModeEverySec,
+		FaultInjector: injector,
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			slots, bits := OptimalParam(1e4, 1e-4)
+			return FilterParam{Slots: slots, Bits: bits, Hash: doubleFNV}, nil
+		},
+	}
+	s, err := NewShared("testfile", name, controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		s.Close()
+		os.Remove("testfile")
+		os.Remove(LeaderFilename("testfile"))
+		os.Remove("/dev/shm/" + name)
+	}()
+	if err = s.persist(); err != nil {
+		t.Fatal(err)
+	}
+	dirty := func() (n int) {
+		for _, b := range s.dirty {
+			n += bits.OnesCount8(b)
+		}
+		return n
+	}
+	s.ExistOrAdd([]byte("testing"))
+	changed := dirty()
+	if changed == 0 {
+		t.Fatal("Should mark the pages changed")
+	}
+	injector.Set(Faults{SyncErrRate: 1})
+	if err = s.persist(); !errors.Is(err, FaultInjectedErr) {
+		t.Fatalf("Should fail to sync, got %v", err)
+	}
+	if n := dirty(); n != changed {
+		t.Fatalf("Should mark the %v pages changed again, got %v", changed, n)
+	}
+	if err = s.disk.Err(); err == nil {
+		t.Fatal("Should surface the sync error")
+	}
+	injector.Set(Faults{})
+	if err = s.persist(); err != nil {
+		t.Fatal(err)
+	}
+	if n := dirty(); n != 0 || s.disk.Err() != nil {
+		t.Fatalf("Should persist the pages, got %v pages left and %v", n, s.disk.Err())
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package disk_bloom
+
+// NewShared creates a SharedFilter. It is only supported on Linux.
+func NewShared(filename string, name string, controller Controller) (*SharedFilter, error) {
+	return nil, UnsupportedErr
+}
@@ -0,0 +1,175 @@
+package disk_bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// A shrunk bloom filter keeps only its non-zero blocks of pageSize bytes, in order, followed by an index:
+//
+// | block | block | ... | presence bitmap of the blocks (8 bytes per 64 blocks) | number of blocks(8) |
+//
+// The last block may be shorter than pageSize.
+type blockIndex struct {
+	// start is the file offset of the bloom filter
+	start int64
+	// size is the size of the bloom filter before shrinking
+	size    int64
+	present []uint64
+	// rank is the number of present blocks before each word of present
+	rank []uint32
+}
+
+func newBlockIndex(start int64, size int64) *blockIndex {
+	blocks := (size + pageSize - 1) / pageSize
+	return &blockIndex{
+		start:   start,
+		size:    size,
+		present: make([]uint64, (blocks+63)/64),
+	}
+}
+
+func (x *blockIndex) buildRank() {
+	x.rank = make([]uint32, len(x.present))
+	var rank uint32
+	for i, word := range x.present {
+		x.rank[i] = rank
+		rank += uint32(bits.OnesCount64(word))
+	}
+}
+
+// physical returns the index of the block in the shrunk file, and false if the block is all zeros.
+func (x *blockIndex) physical(block int64) (int64, bool) {
+	word, bit := x.present[block/64], uint(block%64)
+	if word&(1<<bit) == 0 {
+		return 0, false
+	}
+	return int64(x.rank[block/64]) + int64(bits.OnesCount64(word&(1<<bit-1))), true
+}
+
+func (x *blockIndex) encode() []byte {
+	b := make([]byte, len(x.present)*8+8)
+	for i, word := range x.present {
+		binary.LittleEndian.PutUint64(b[i*8:], word)
+	}
+	binary.LittleEndian.PutUint64(b[len(x.present)*8:], uint64((x.size+pageSize-1)/pageSize))
+	return b
+}
+
+// readBlockIndex reads the index at the end of a shrunk file of fileSize bytes.
+func readBlockIndex(r io.ReaderAt, fileSize int64, start int64, size int64) (*blockIndex, error) {
+	x := newBlockIndex(start, size)
+	b := make([]byte, len(x.present)*8+8)
+	if fileSize-int64(len(b)) < start {
+		return nil, fmt.Errorf("%w: truncated block index", InvalidHeaderErr)
+	}
+	if _, err := r.ReadAt(b, fileSize-int64(len(b))); err != nil {
+		return nil, err
+	}
+	if blocks := binary.LittleEndian.Uint64(b[len(x.present)*8:]); blocks != uint64((size+pageSize-1)/pageSize) {
+		return nil, fmt.Errorf("%w: block index of %v blocks, but the bloom filter has %v", InvalidHeaderErr, blocks, (size+pageSize-1)/pageSize)
+	}
+	for i := range x.present {
+		x.present[i] = binary.LittleEndian.Uint64(b[i*8:])
+	}
+	x.buildRank()
+	return x, nil
+}
+
+// blockedStorage reads a shrunk bloom filter as if it was not shrunk. It rejects writes to the bloom filter.
+type blockedStorage struct {
+	storage
+	index *blockIndex
+}
+
+func (s *blockedStorage) ReadAt(b []byte, offset int64) (n int, err error) {
+	x := s.index
+	if offset < x.start {
+		m := len(b)
+		if offset+int64(m) > x.start {
+			m = int(x.start - offset)
+		}
+		if n, err = s.storage.ReadAt(b[:m], offset); err != nil {
+			return n, err
+		}
+	}
+	for n < len(b) {
+		rel := offset + int64(n) - x.start
+		if rel >= x.size {
+			return n, io.EOF
+		}
+		m := pageSize - rel%pageSize
+		if m > int64(len(b)-n) {
+			m = int64(len(b) - n)
+		}
+		if rel+m > x.size {
+			m = x.size - rel
+		}
+		if block, ok := x.physical(rel / pageSize); ok {
+			if _, err = s.storage.ReadAt(b[n:n+int(m)], x.start+block*pageSize+rel%pageSize); err != nil {
+				return n, err
+			}
+		} else {
+			zero := b[n : n+int(m)]
+			for i := range zero {
+				zero[i] = 0
+			}
+		}
+		n += int(m)
+	}
+	return n, nil
+}
+
+func (s *blockedStorage) WriteAt(b []byte, offset int64) (int, error) {
+	if offset+int64(len(b)) > s.index.start {
+		return 0, SealedErr
+	}
+	return s.storage.WriteAt(b, offset)
+}
+
+// shrinkLocked rewrites the bloom filter in place, keeping only its non-zero blocks.
+// Every block moves towards the beginning, so it never overwrites a block not yet moved.
+func (f *DiskFilter) shrinkLocked() error {
+	size := f.header.bloomSize(f.param.Bits)
+	x := newBlockIndex(f.bloomStart, size)
+	buf := make([]byte, pageSize)
+	var shrunk int64
+	for block := int64(0); block*pageSize < size; block++ {
+		m := size - block*pageSize
+		if m > pageSize {
+			m = pageSize
+		}
+		if _, err := f.file.rw.ReadAt(buf[:m], f.bloomStart+block*pageSize); err != nil {
+			return err
+		}
+		if isZero(buf[:m]) {
+			continue
+		}
+		x.present[block/64] |= 1 << uint(block%64)
+		if _, err := f.file.rw.WriteAt(buf[:m], f.bloomStart+shrunk); err != nil {
+			return err
+		}
+		shrunk += m
+	}
+	x.buildRank()
+	index := x.encode()
+	if _, err := f.file.rw.WriteAt(index, f.bloomStart+shrunk); err != nil {
+		return err
+	}
+	if err := f.file.f.Truncate(f.bloomStart + shrunk + int64(len(index))); err != nil {
+		return err
+	}
+	f.file.rw = &blockedStorage{storage: f.file.rw, index: x}
+	return nil
+}
+
+func isZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package disk_bloom
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDiskFilter_ShrinkOnSeal(t *testing.T) {
+	defer os.Remove("testfile")
+	for _, key := range [][]byte{nil, []byte("0123456789abcdef")} {
+		controller := Controller{
+			ShrinkOnSeal:  true,
+			EncryptionKey: key,
+			GetParam: func(metadata []byte) (FilterParam, []byte) {
+				// a sparse filter
+				slots, bits := OptimalParam(1e6, 1e-4)
+				return FilterParam{Slots: slots, Bits: bits, Hash: doubleFNV}, nil
+			},
+		}
+		bf, err := New("testfile", controller)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 10; i++ {
+			bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+		}
+		before, err := os.Stat("testfile")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = bf.Seal(); err != nil {
+			t.Fatal(err)
+		}
+		after, err := os.Stat("testfile")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if after.Size() >= before.Size()/2 {
+			t.Fatalf("Should shrink, got %v bytes from %v", after.Size(), before.Size())
+		}
+		for i := 0; i < 10; i++ {
+			if !bf.Exist([]byte(strconv.Itoa(i))) {
+				t.Fatalf("%v should exist in the shrunk filter", i)
+			}
+		}
+		bf.Close()
+
+		if bf, err = New("testfile", controller); err != nil {
+			t.Fatal(err)
+		}
+		if h := bf.Header(); !h.Sealed() || !h.Shrunk() {
+			t.Fatalf("Should be sealed and shrunk, got %+v", h)
+		}
+		for i := 0; i < 10; i++ {
+			if !bf.Exist([]byte(strconv.Itoa(i))) {
+				t.Fatalf("%v should exist in the reopened filter", i)
+			}
+		}
+		falsePositives := 0
+		for i := 10; i < 10010; i++ {
+			if bf.Exist([]byte(strconv.Itoa(i))) {
+				falsePositives++
+			}
+		}
+		if falsePositives > 10 {
+			t.Fatalf("Too many false positives: %v", falsePositives)
+		}
+		if _, err = bf.ExistOrAddErr([]byte("new")); err == nil {
+			t.Fatal("Should not add to a shrunk filter")
+		}
+		if key == nil {
+			stats, err := ScanFile("testfile")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !stats.Healthy || stats.SetBits == 0 || stats.SetBits > 10*uint64(bf.FilterParam().Slots) {
+				t.Fatalf("Unexpected stats %+v", stats)
+			}
+		}
+		bf.Close()
+		os.Remove("testfile")
+	}
+}
+
+func TestDiskFilter_ShrinkOnSealHybrid(t *testing.T) {
+	defer os.Remove("testfile")
+	controller := Controller{
+		Fsync:        FsyncModeEverySec,
+		Hybrid:       time.Hour,
+		ShrinkOnSeal: true,
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			slots, bits := OptimalParam(1e6, 1e-4)
+			return FilterParam{Slots: slots, Bits: bits, Hash: doubleFNV}, nil
+		},
+	}
+	bf, err := New("testfile", controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	before, err := os.Stat("testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = bf.Seal(); err != nil {
+		t.Fatal(err)
+	}
+	if bf.hybrid != nil {
+		t.Fatal("Should serve the shrunk filter from the file")
+	}
+	if err = bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.Stat("testfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size() >= before.Size()/2 {
+		t.Fatalf("Should shrink, got %v bytes from %v", after.Size(), before.Size())
+	}
+	if bf, err = New("testfile", controller); err != nil {
+		t.Fatal(err)
+	}
+	defer bf.Close()
+	for i := 0; i < 5; i++ {
+		if !bf.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should exist in the reopened filter", i)
+		}
+	}
+}
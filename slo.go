@@ -0,0 +1,90 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// sloRotations is the number of filters a window is split into by ParamFromSLO,
+// so that an entry is remembered for at most a quarter of the window longer than the window.
+const sloRotations = 4
+
+// GroupOptions are the parameters of a FilterGroup derived from the operational requirements by ParamFromSLO.
+type GroupOptions struct {
+	// N is the expected number of entries of a filter, and P is its false positive rate
+	N uint64
+	P float64
+	// Rotations is the number of filters a window is split into, and Interval is how long a filter is active
+	Rotations int
+	Interval  time.Duration
+	// Retain is the number of filters to keep: the active filter and the Rotations filters before it,
+	// which cover the window whenever the active filter rotates
+	Retain int
+	// FPR is the false positive rate of a lookup in all the Retain filters
+	FPR float64
+}
+
+// ParamFromSLO returns the parameters of a FilterGroup remembering the entries seen within window,
+// at qps entries per second, with the false positive rate of a lookup within fprBudget.
+// The window is split into filters rotated every Interval, and a lookup probes all the Retain filters,
+// so the budget is shared by them. It returns InvalidParamErr if qps or window is not positive,
+// or fprBudget is not in (0, 1).
+func ParamFromSLO(qps float64, window time.Duration, fprBudget float64) (GroupOptions, error) {
+	if !(qps > 0) || math.IsInf(qps, 1) || window <= 0 {
+		return GroupOptions{}, fmt.Errorf("%w: %v entries per second in the window %v", InvalidParamErr, qps, window)
+	}
+	if !(fprBudget > 0 && fprBudget < 1) {
+		return GroupOptions{}, fmt.Errorf("%w: the false positive rate %v is not in (0, 1)", InvalidParamErr, fprBudget)
+	}
+	o := GroupOptions{
+		Rotations: sloRotations,
+		Interval:  window / sloRotations,
+		Retain:    sloRotations + 1,
+	}
+	if o.Interval <= 0 {
+		o.Rotations, o.Interval, o.Retain = 1, window, 2
+	}
+	n := math.Ceil(qps * o.Interval.Seconds())
+	if n >= math.MaxUint64 {
+		return GroupOptions{}, fmt.Errorf("%w: %v entries per filter", InvalidParamErr, n)
+	}
+	o.N = uint64(n)
+	if o.N == 0 {
+		o.N = 1
+	}
+	// 1-(1-P)^Retain = fprBudget
+	o.P = -math.Expm1(math.Log1p(-fprBudget) / float64(o.Retain))
+	plan, err := PlanParam(o.N, o.P)
+	if err != nil {
+		return GroupOptions{}, err
+	}
+	o.FPR = -math.Expm1(math.Log1p(-plan.FPR) * float64(o.Retain))
+	return o, nil
+}
+
+// Options returns the GroupOption rotating the active filter every Interval, or once it has N entries
+// if the rate exceeds the qps. clock is optional, and defaults to SystemClock.
+func (o GroupOptions) Options(clock Clock) []GroupOption {
+	return []GroupOption{WithRotationPolicy(RotateEvery(o.Interval, true, clock))}
+}
+
+// NewGroup is like the NewGroup of the package, with the parameters and the Options of o.
+// The options given are applied after them.
+func (o GroupOptions) NewGroup(pattern string, fsync FsyncMode, hash func([]byte) (uint64, uint64), opts ...GroupOption) (*FilterGroup, error) {
+	return NewGroup(pattern, fsync, o.N, o.P, hash, append(o.Options(nil), opts...)...)
+}
+
+// Trim drops the oldest filters of the group beyond Retain, which expires the entries out of the window.
+// It should be invoked periodically, e.g. every Interval.
+func (o GroupOptions) Trim(g *FilterGroup) error {
+	for {
+		members := g.Members()
+		if len(members) <= o.Retain {
+			return nil
+		}
+		if err := g.DropMember(members[0].Window); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package disk_bloom
+
+import (
+	"errors"
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParamFromSLO(t *testing.T) {
+	o, err := ParamFromSLO(1000, time.Hour, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Rotations != 4 || o.Interval != 15*time.Minute || o.Retain != 5 || o.N != 900000 {
+		t.Fatalf("unexpected options %+v", o)
+	}
+	if fpr := 1 - math.Pow(1-o.P, 5); math.Abs(fpr-0.01) > 1e-9 {
+		t.Fatalf("the budget should be shared by the filters, got %v", fpr)
+	}
+	if math.Abs(o.FPR-0.01) > 0.002 {
+		t.Fatalf("the false positive rate should be close to the budget, got %v", o.FPR)
+	}
+	for _, c := range []struct {
+		qps    float64
+		window time.Duration
+		fpr    float64
+	}{
+		{0, time.Hour, 0.01},
+		{math.NaN(), time.Hour, 0.01},
+		{1000, 0, 0.01},
+		{1000, time.Hour, 0},
+		{1000, time.Hour, 1},
+	} {
+		if _, err := ParamFromSLO(c.qps, c.window, c.fpr); !errors.Is(err, InvalidParamErr) {
+			t.Fatalf("%+v should be invalid, got %v", c, err)
+		}
+	}
+}
+
+func TestGroupOptions_NewGroup(t *testing.T) {
+	os.Mkdir("testfile", os.ModePerm)
+	defer os.RemoveAll("testfile")
+	o, err := ParamFromSLO(1, 400*time.Second, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := o.NewGroup("testfile/*", FsyncModeNo, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	if g.n != o.N {
+		t.Fatalf("the group should expect %v entries, got %v", o.N, g.n)
+	}
+	for i := 0; i < 8; i++ {
+		if err := g.RotateNow(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g.ExistOrAdd([]byte("active"))
+	if err := o.Trim(g); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(g.Members()); n != o.Retain {
+		t.Fatalf("Should keep %v filters, got %v", o.Retain, n)
+	}
+	if !g.Exist([]byte("active")) {
+		t.Fatal("the active filter should be kept")
+	}
+}
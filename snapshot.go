@@ -0,0 +1,246 @@
+package disk_bloom
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// snapshotManifest is the name of the manifest in the directory of a generation, which is written last.
+const snapshotManifest = "manifest.json"
+
+var (
+	SnapshotErr   = fmt.Errorf("failed to snapshot")
+	NoSnapshotErr = fmt.Errorf("no snapshot")
+)
+
+// Snapshot is the shared manifest of the filters snapshotted together by SnapshotAll.
+type Snapshot struct {
+	// Generation increases with each snapshot in the same directory
+	Generation uint64     `json:"generation"`
+	Created    time.Time  `json:"created"`
+	Filters    []Manifest `json:"filters"`
+	// Dir is the directory of the generation, where the copies are
+	Dir string `json:"-"`
+}
+
+// SnapshotAll copies the files of filters into a new generation under dir, e.g. the filters of all tenants,
+// so that restoring them is mutually consistent: all filters are locked together during the copy,
+// and no entry is added to any of them between the copies. See Clone for how each file is copied.
+// The generation is the directory dir/<generation>, whose manifest.json is written once all copies are complete.
+// The base names of the files must be distinct.
+func SnapshotAll(filters []*DiskFilter, dir string) (Snapshot, error) {
+	names := make(map[string]bool)
+	for _, f := range filters {
+		name := filepath.Base(f.file.f.Name())
+		if names[name] {
+			return Snapshot{}, fmt.Errorf("%w: duplicate filename %v", SnapshotErr, name)
+		}
+		names[name] = true
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Snapshot{}, err
+	}
+	latest, err := latestGeneration(dir)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	s := Snapshot{
+		Generation: latest + 1,
+		Created:    time.Now(),
+		Dir:        filepath.Join(dir, strconv.FormatUint(latest+1, 10)),
+	}
+	// fails if a concurrent SnapshotAll takes the generation
+	if err = os.Mkdir(s.Dir, 0755); err != nil {
+		return Snapshot{}, err
+	}
+	if err = quiesce(filters, func() error {
+		for _, f := range filters {
+			if err := f.cloneLocked(filepath.Join(s.Dir, filepath.Base(f.file.f.Name()))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		_ = os.RemoveAll(s.Dir)
+		return Snapshot{}, err
+	}
+	for _, f := range filters {
+		m, _, err := newManifest(filepath.Join(s.Dir, filepath.Base(f.file.f.Name())), f.Header(), f.controller.MetadataSize, f.FilterParam())
+		if err != nil {
+			_ = os.RemoveAll(s.Dir)
+			return Snapshot{}, err
+		}
+		s.Filters = append(s.Filters, m)
+	}
+	if err = writeSnapshotManifest(s); err != nil {
+		_ = os.RemoveAll(s.Dir)
+		return Snapshot{}, err
+	}
+	return s, nil
+}
+
+// quiesce invokes fn with all filters locked. They are locked in the order of their filenames,
+// so that concurrent quiesce never deadlocks.
+func quiesce(filters []*DiskFilter, fn func() error) error {
+	sorted := append([]*DiskFilter(nil), filters...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].file.f.Name() < sorted[j].file.f.Name()
+	})
+	for i, f := range sorted {
+		if !f.acquire() {
+			for _, locked := range sorted[:i] {
+				locked.file.mu.Unlock()
+				locked.release()
+			}
+			return fmt.Errorf("%w: %v", ClosedErr, f.file.f.Name())
+		}
+		f.file.mu.Lock()
+	}
+	defer func() {
+		for _, f := range sorted {
+			f.file.mu.Unlock()
+			f.release()
+		}
+	}()
+	return fn()
+}
+
+func writeSnapshotManifest(s Snapshot) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(s.Dir, snapshotManifest+".tmp")
+	if err = os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.Dir, snapshotManifest))
+}
+
+// latestGeneration returns the largest generation under dir, complete or not, or 0 if none.
+func latestGeneration(dir string) (uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	var latest uint64
+	for _, e := range entries {
+		if g, err := strconv.ParseUint(e.Name(), 10, 64); err == nil && e.IsDir() && g > latest {
+			latest = g
+		}
+	}
+	return latest, nil
+}
+
+// LatestSnapshot returns the newest complete snapshot under dir taken by SnapshotAll, to restore from.
+// It returns NoSnapshotErr if there is none.
+func LatestSnapshot(dir string) (Snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	var generations []uint64
+	for _, e := range entries {
+		if g, err := strconv.ParseUint(e.Name(), 10, 64); err == nil && e.IsDir() {
+			generations = append(generations, g)
+		}
+	}
+	sort.Slice(generations, func(i, j int) bool {
+		return generations[i] > generations[j]
+	})
+	for _, g := range generations {
+		genDir := filepath.Join(dir, strconv.FormatUint(g, 10))
+		b, err := os.ReadFile(filepath.Join(genDir, snapshotManifest))
+		if os.IsNotExist(err) {
+			// incomplete
+			continue
+		} else if err != nil {
+			return Snapshot{}, err
+		}
+		var s Snapshot
+		if err = json.Unmarshal(b, &s); err != nil {
+			return Snapshot{}, fmt.Errorf("%w: %v: %v", SnapshotErr, genDir, err)
+		}
+		s.Dir = genDir
+		return s, nil
+	}
+	return Snapshot{}, NoSnapshotErr
+}
+
+// Snapshot copies the filter file to filename, which must not exist, while the adds continue, unlike Clone,
+// which blocks them during the copy unless the filesystem supports reflinks. The file is copied without the lock,
+// and the bytes written meanwhile are tracked and copied again under a brief lock at last,
+// so that the copy is consistent as of its end. It can be opened with the same Controller.
+func (f *DiskFilter) Snapshot(filename string) error {
+	if !f.acquire() {
+		return ClosedErr
+	}
+	defer f.release()
+	f.snapshotMu.Lock()
+	defer f.snapshotMu.Unlock()
+	dst, err := os.OpenFile(filename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	err = f.snapshotTo(dst)
+	f.file.mu.Lock()
+	f.written = nil
+	f.file.mu.Unlock()
+	if err == nil {
+		err = dst.Sync()
+	}
+	if e := dst.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		_ = os.Remove(filename)
+	}
+	return err
+}
+
+func (f *DiskFilter) snapshotTo(dst *os.File) error {
+	f.file.mu.Lock()
+	err := f.prepareCopyLocked()
+	f.written = make(map[int64]bool)
+	f.file.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	// the bytes on disk are copied as they are, e.g. encrypted, and the mapped bytes are read through the page cache
+	info, err := f.file.f.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dst, io.NewSectionReader(f.file.f, 0, info.Size())); err != nil {
+		return err
+	}
+	f.file.mu.Lock()
+	defer f.file.mu.Unlock()
+	if err = f.prepareCopyLocked(); err != nil {
+		return err
+	}
+	// the metadata and the header, which are small, and the bytes written during the copy
+	raw := retryStorage{f.file.f}
+	b := make([]byte, f.bloomStart)
+	if _, err = raw.ReadAt(b, 0); err != nil {
+		return err
+	}
+	if _, err = dst.WriteAt(b, 0); err != nil {
+		return err
+	}
+	for pos := range f.written {
+		if _, err = raw.ReadAt(b[:1], pos); err != nil {
+			return err
+		}
+		if _, err = dst.WriteAt(b[:1], pos); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package disk_bloom
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestSnapshotAll(t *testing.T) {
+	os.Mkdir("testfile", os.ModePerm)
+	defer os.RemoveAll("testfile")
+	controller := Controller{
+		Fsync: FsyncModeNo,
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			slots, bits := OptimalParam(1e4, 1e-4)
+			return FilterParam{Slots: slots, Bits: bits, Hash: doubleFNV}, nil
+		},
+	}
+	var filters []*DiskFilter
+	for _, tenant := range []string{"a", "b"} {
+		bf, err := New(filepath.Join("testfile", tenant), controller)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer bf.Close()
+		bf.ExistOrAdd([]byte(tenant))
+		filters = append(filters, bf)
+	}
+	dir := filepath.Join("testfile", "snapshots")
+	if _, err := LatestSnapshot(dir); !os.IsNotExist(err) {
+		t.Fatalf("should fail with not exist, got %v", err)
+	}
+	if _, err := SnapshotAll(filters, dir); err != nil {
+		t.Fatal(err)
+	}
+	filters[0].ExistOrAdd([]byte("c"))
+	s, err := SnapshotAll(filters, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Generation != 2 || len(s.Filters) != 2 {
+		t.Fatalf("unexpected snapshot: %+v", s)
+	}
+	latest, err := LatestSnapshot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Generation != 2 || latest.Dir != s.Dir || latest.Filters[0] != s.Filters[0] {
+		t.Fatalf("%+v != %+v", latest, s)
+	}
+	restored, err := New(filepath.Join(latest.Dir, latest.Filters[0].Filename), controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	if !restored.Exist([]byte("a")) || !restored.Exist([]byte("c")) {
+		t.Fatal("the entries should be in the snapshot")
+	}
+
+	if _, err = SnapshotAll([]*DiskFilter{filters[0], filters[0]}, dir); !errors.Is(err, SnapshotErr) {
+		t.Fatalf("should fail with SnapshotErr, got %v", err)
+	}
+	filters[1].Close()
+	if _, err = SnapshotAll(filters, dir); !errors.Is(err, ClosedErr) {
+		t.Fatalf("should fail with ClosedErr, got %v", err)
+	}
+	if latest, err = LatestSnapshot(dir); err != nil || latest.Generation != 2 {
+		t.Fatalf("the failed snapshot should not be the latest: %v, %v", latest.Generation, err)
+	}
+}
+
+func TestDiskFilter_Snapshot(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo})
+	for i := 0; i < 1000; i++ {
+		bf.ExistOrAdd([]byte(fmt.Sprint(i)))
+	}
+	// the adds continue during the snapshot
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1000; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			bf.ExistOrAdd([]byte(fmt.Sprint(i)))
+		}
+	}()
+	err := bf.Snapshot("testfile.snapshot")
+	close(done)
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("testfile.snapshot")
+	if bf.written != nil {
+		t.Fatal("the writes should not be tracked after the snapshot")
+	}
+	if err = bf.Snapshot("testfile.snapshot"); !os.IsExist(err) {
+		t.Fatalf("Should not overwrite an existing file, got %v", err)
+	}
+	snapshot, err := New("testfile.snapshot", bf.Controller())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snapshot.Close()
+	for i := 0; i < 1000; i++ {
+		if !snapshot.Exist([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%v should be in the snapshot", i)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package disk_bloom
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// statsMinutes is the number of minutes kept by Stats.
+const statsMinutes = 60
+
+// MinuteStats are the counters of a minute.
+type MinuteStats struct {
+	// Minute is the beginning of the minute
+	Minute time.Time
+	// Adds is the number of ExistOrAdd
+	Adds uint64
+	// Hits is the number of Exist and ExistOrAdd finding the entry
+	Hits uint64
+	// Novel is the number of entries added
+	Novel uint64
+}
+
+// DuplicateRate returns the fraction of ExistOrAdd finding the entry already in the filter.
+func (s MinuteStats) DuplicateRate() float64 {
+	if s.Adds == 0 {
+		return 0
+	}
+	return float64(s.Adds-s.Novel) / float64(s.Adds)
+}
+
+// Stats are the per-minute counters of the last hour, see Controller.Stats, and the state of the background sync.
+type Stats struct {
+	// Minutes are the minutes having operations, the oldest first
+	Minutes []MinuteStats
+	// Sync is the state of the background sync, which is kept regardless of Controller.Stats
+	Sync SyncStats
+}
+
+// statsRing keeps the counters of the last statsMinutes minutes.
+type statsRing struct {
+	clock Clock
+	mu    sync.Mutex
+	ring  [statsMinutes]MinuteStats
+}
+
+func newStatsRing(clock Clock) *statsRing {
+	if clock == nil {
+		clock = SystemClock
+	}
+	return &statsRing{clock: clock}
+}
+
+func (r *statsRing) add(adds, hits, novel uint64) {
+	minute := r.clock.Now().Truncate(time.Minute)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := &r.ring[minute.Unix()/60%statsMinutes]
+	if !s.Minute.Equal(minute) {
+		*s = MinuteStats{Minute: minute}
+	}
+	s.Adds += adds
+	s.Hits += hits
+	s.Novel += novel
+}
+
+func (r *statsRing) stats() Stats {
+	now := r.clock.Now().Truncate(time.Minute)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var stats Stats
+	// from the slot after the current minute, which is the oldest
+	for i := 1; i <= statsMinutes; i++ {
+		s := r.ring[(now.Unix()/60+int64(i))%statsMinutes]
+		if s.Minute.IsZero() || now.Sub(s.Minute) >= statsMinutes*time.Minute || s.Minute.After(now) {
+			continue
+		}
+		stats.Minutes = append(stats.Minutes, s)
+	}
+	return stats
+}
+
+// Stats returns the per-minute counters of the last hour, which are empty unless Controller.Stats is set,
+// and the state of the background sync.
+func (f *DiskFilter) Stats() Stats {
+	var stats Stats
+	if f.stats != nil {
+		stats = f.stats.stats()
+	}
+	stats.Sync = f.breaker.syncStats()
+	stats.Sync.Probe = f.probe
+	return stats
+}
+
+// countLookup counts an Exist.
+func (f *DiskFilter) countLookup(exist bool) {
+	if f.controller.Debug {
+		atomic.AddUint64(&f.debug.exists, 1)
+		if exist {
+			atomic.AddUint64(&f.debug.hits, 1)
+		}
+	}
+	if f.stats != nil && exist {
+		f.stats.add(0, 1, 0)
+	}
+}
+
+// countAdds counts ExistOrAdd of adds entries, of which novel entries were added.
+func (f *DiskFilter) countAdds(adds, novel uint64) {
+	if f.controller.Debug {
+		atomic.AddUint64(&f.debug.adds, adds)
+		atomic.AddUint64(&f.debug.hits, adds-novel)
+	}
+	if f.stats != nil {
+		f.stats.add(adds, adds-novel, novel)
+	}
+}
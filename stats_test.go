@@ -0,0 +1,42 @@
+package disk_bloom
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiskFilter_Stats(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	bf := newTestFilter(t, Controller{Stats: true, Clock: clock})
+	bf.ExistOrAdd([]byte("a"))
+	bf.ExistOrAdd([]byte("a"))
+	bf.Exist([]byte("a"))
+	clock.Advance(time.Minute + time.Second)
+	bf.ExistOrAdd([]byte("b"))
+	bf.FilterNovel([][]byte{[]byte("a"), []byte("c")})
+
+	stats := bf.Stats()
+	if len(stats.Minutes) != 2 {
+		t.Fatalf("Should have 2 minutes, got %+v", stats)
+	}
+	want := []MinuteStats{
+		{Minute: start, Adds: 2, Hits: 2, Novel: 1},
+		{Minute: start.Add(time.Minute), Adds: 3, Hits: 1, Novel: 2},
+	}
+	for i := range want {
+		if got := stats.Minutes[i]; !got.Minute.Equal(want[i].Minute) || got.Adds != want[i].Adds || got.Hits != want[i].Hits || got.Novel != want[i].Novel {
+			t.Fatalf("Minute #%v: got %+v, want %+v", i, got, want[i])
+		}
+	}
+	if rate := stats.Minutes[0].DuplicateRate(); rate != 0.5 {
+		t.Fatalf("DuplicateRate: got %v, want 0.5", rate)
+	}
+
+	// minutes older than an hour are dropped
+	clock.Advance(time.Hour)
+	bf.ExistOrAdd([]byte("d"))
+	if stats := bf.Stats(); len(stats.Minutes) != 1 || stats.Minutes[0].Novel != 1 {
+		t.Fatalf("Should only have the current minute, got %+v", stats)
+	}
+}
@@ -0,0 +1,73 @@
+package disk_bloom
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+)
+
+// storage is where a DiskFilter reads and writes its metadata and bloom filter.
+type storage interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+// retryStorage retries the reads and writes interrupted by signals, and continues the short ones,
+// which network filesystems may return, until they complete or fail.
+type retryStorage struct {
+	storage
+}
+
+func (s retryStorage) ReadAt(b []byte, offset int64) (n int, err error) {
+	for n < len(b) {
+		var m int
+		m, err = s.storage.ReadAt(b[n:], offset+int64(n))
+		n += m
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		if m == 0 {
+			return n, io.ErrNoProgress
+		}
+	}
+	return n, nil
+}
+
+func (s retryStorage) WriteAt(b []byte, offset int64) (n int, err error) {
+	for n < len(b) {
+		var m int
+		m, err = s.storage.WriteAt(b[n:], offset+int64(n))
+		n += m
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		if m == 0 {
+			return n, io.ErrShortWrite
+		}
+	}
+	return n, nil
+}
+
+// fileHandle is the filter file, which is an *os.File, an fsFile opened by OpenFS, or a storageFile opened by NewOnStorage.
+type fileHandle interface {
+	storage
+	io.Reader
+	Name() string
+	Stat() (os.FileInfo, error)
+	Sync() error
+	Truncate(size int64) error
+	Close() error
+}
+
+// osFile returns the *os.File of the filter file, or nil if it is opened by OpenFS or NewOnStorage.
+func (m *muFile) osFile() *os.File {
+	f, _ := m.f.(*os.File)
+	return f
+}
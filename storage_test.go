@@ -0,0 +1,56 @@
+package disk_bloom
+
+import (
+	"bytes"
+	"io"
+	"syscall"
+	"testing"
+)
+
+// flakyStorage serves at most 3 bytes per call, and fails every other call with EINTR.
+type flakyStorage struct {
+	b     []byte
+	calls int
+}
+
+func (s *flakyStorage) ReadAt(b []byte, offset int64) (int, error) {
+	if s.calls++; s.calls%2 == 0 {
+		return 0, syscall.EINTR
+	}
+	if offset >= int64(len(s.b)) {
+		return 0, io.EOF
+	}
+	if len(b) > 3 {
+		b = b[:3]
+	}
+	return copy(b, s.b[offset:]), nil
+}
+
+func (s *flakyStorage) WriteAt(b []byte, offset int64) (int, error) {
+	if s.calls++; s.calls%2 == 0 {
+		return 0, syscall.EINTR
+	}
+	if len(b) > 3 {
+		b = b[:3]
+	}
+	return copy(s.b[offset:], b), nil
+}
+
+func TestRetryStorage(t *testing.T) {
+	s := retryStorage{&flakyStorage{b: make([]byte, 16)}}
+	want := []byte("0123456789")
+	if n, err := s.WriteAt(want, 4); n != len(want) || err != nil {
+		t.Fatalf("WriteAt: got %v %v", n, err)
+	}
+	got := make([]byte, len(want))
+	if n, err := s.ReadAt(got, 4); n != len(want) || err != nil {
+		t.Fatalf("ReadAt: got %v %v", n, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Should read %q, got %q", want, got)
+	}
+	// reading across the end
+	if n, err := s.ReadAt(got, 10); n != 6 || err != io.EOF {
+		t.Fatalf("ReadAt across the end: got %v %v", n, err)
+	}
+}
@@ -0,0 +1,84 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// WriteTo streams the whole filter file, including the metadata and the header, to w, e.g. to ship a built filter
+// to another node, where ReadFrom opens it. Like Clone, the copy is consistent, and the adds wait until it is written.
+func (f *DiskFilter) WriteTo(w io.Writer) (n int64, err error) {
+	if !f.acquire() {
+		return 0, ClosedErr
+	}
+	defer f.release()
+	f.file.mu.Lock()
+	defer f.file.mu.Unlock()
+	if err = f.prepareCopyLocked(); err != nil {
+		return 0, err
+	}
+	info, err := f.file.f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return io.Copy(w, io.NewSectionReader(f.file.f, 0, info.Size()))
+}
+
+// ReadFrom writes a filter file streamed by WriteTo into filename, which must not exist, and opens it with the controller,
+// whose GetParam can be nil if the parameters are recorded in the file.
+// The file is written aside and renamed, so that a partial stream never leaves a filter behind.
+func ReadFrom(r io.Reader, filename string, controller Controller) (*DiskFilter, error) {
+	if _, err := os.Stat(filename); err == nil {
+		return nil, fmt.Errorf("%w: %v", os.ErrExist, filename)
+	}
+	tmp := filename + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(file, r)
+	if err == nil {
+		err = file.Sync()
+	}
+	if e := file.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp, filename)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return nil, err
+	}
+	f, err := New(filename, controller)
+	if err != nil {
+		_ = os.Remove(filename)
+		return nil, err
+	}
+	return f, nil
+}
+
+// Bytes returns a copy of the bloom filter in memory with its parameters, to convert it to an in-memory filter.
+// The bit i is b[i/8]&(1<<(i%8)), and the bits of an entry of the double hash x and y are (x + j*y) % Bits
+// for j in [0, Slots), or by Lemire's fast range if the header has FlagFastRange, and y is corrected by
+// HardenedProbes if the header has FlagHardened, after x and y are mixed by format.SaltHash if the header
+// has FlagSalted. It is laid out like a filter
+// of github.com/riobard/go-bloom if Bits is a multiple of 8, see ImportGoBloom for the other way around.
+// Only classic filters are supported.
+func (f *DiskFilter) Bytes() (b []byte, param FilterParam, err error) {
+	if f.header.variant() != variantClassic {
+		return nil, FilterParam{}, fmt.Errorf("bytes of %v filters are not supported", f.header.variant())
+	}
+	if !f.acquire() {
+		return nil, FilterParam{}, ClosedErr
+	}
+	defer f.release()
+	f.rlock()
+	defer f.file.mu.RUnlock()
+	b = make([]byte, (f.param.Bits+7)/8)
+	if err = f.readBloomLocked(b, 0); err != nil {
+		return nil, FilterParam{}, err
+	}
+	return b, *f.param, nil
+}
@@ -0,0 +1,65 @@
+package disk_bloom
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDiskFilter_WriteTo(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo})
+	for i := 0; i < 100; i++ {
+		bf.ExistOrAdd([]byte(fmt.Sprint(i)))
+	}
+	var buf bytes.Buffer
+	n, err := bf.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat("testfile"); n != info.Size() {
+		t.Fatalf("Should write the whole file of %v bytes, got %v", info.Size(), n)
+	}
+	received, err := ReadFrom(bytes.NewReader(buf.Bytes()), "testfile2", bf.Controller())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("testfile2")
+	defer received.Close()
+	for i := 0; i < 100; i++ {
+		if !received.Exist([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%v should be received", i)
+		}
+	}
+	if _, err = ReadFrom(bytes.NewReader(buf.Bytes()), "testfile2", bf.Controller()); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("Should not overwrite an existing file, got %v", err)
+	}
+	if _, err = ReadFrom(bytes.NewReader(buf.Bytes()[:100]), "testfile3", bf.Controller()); err == nil {
+		t.Fatal("Should fail to open a partial stream")
+	}
+	if _, err = os.Stat("testfile3"); !os.IsNotExist(err) {
+		t.Fatal("Should not leave a partial stream behind")
+	}
+}
+
+func TestDiskFilter_Bytes(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo, WriteBuffer: time.Hour})
+	bf.ExistOrAdd([]byte("testing"))
+	b, param, err := bf.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if param.Slots != bf.param.Slots || param.Bits != bf.param.Bits || uint64(len(b)) != (param.Bits+7)/8 {
+		t.Fatalf("Unexpected parameters %v and %v bytes", param, len(b))
+	}
+	// the bits buffered in memory are included
+	x, y := param.Hash([]byte("testing"))
+	for i := 0; i < int(param.Slots); i++ {
+		offset := (x + uint64(i)*y) % param.Bits
+		if b[offset/8]&(1<<(offset%8)) == 0 {
+			t.Fatalf("bit %v should be set", offset)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package disk_bloom
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// syncMinBackoff is the backoff after the first failure of the background sync.
+	syncMinBackoff = time.Second
+	// syncMaxBackoff is the longest backoff, which the probes of an open breaker wait.
+	syncMaxBackoff = time.Minute
+	// syncBreakerThreshold is the number of failures in a row which open the breaker.
+	syncBreakerThreshold = 5
+)
+
+// SyncState is the state of the background sync of the filter, which acts as a circuit breaker:
+// the failed syncs are retried with exponential backoff, and the breaker opens once they keep failing,
+// probing the disk every minute until a sync succeeds.
+type SyncState uint8
+
+const (
+	// SyncStateClosed means the syncs succeed.
+	SyncStateClosed SyncState = iota
+	// SyncStateRetrying means the last syncs failed, which are retried with exponential backoff.
+	SyncStateRetrying
+	// SyncStateOpen means the syncs failed so many times in a row that the failure looks permanent,
+	// e.g. the disk is gone, and a sync is only attempted every minute.
+	SyncStateOpen
+)
+
+func (s SyncState) String() string {
+	switch s {
+	case SyncStateClosed:
+		return "closed"
+	case SyncStateRetrying:
+		return "retrying"
+	case SyncStateOpen:
+		return "open"
+	default:
+		return "invalid"
+	}
+}
+
+// SyncStats are the state of the background sync, see Stats.
+type SyncStats struct {
+	State SyncState
+	// ConsecutiveFailures is the number of syncs failed since the last success
+	ConsecutiveFailures uint64
+	// Failures is the number of syncs failed, and Recoveries is the number of times the syncs resumed after failures
+	Failures   uint64
+	Recoveries uint64
+	// LastErr is the error of the last failed sync, which is kept after the recovery
+	LastErr error
+	// RetryAt is when the failed sync is retried, which is zero in SyncStateClosed
+	RetryAt time.Time
+	// Probe is the result of Controller.SyncProbe, which is zero if it is off
+	Probe SyncProbe
+}
+
+// syncBreaker backs off the background syncs while they fail.
+type syncBreaker struct {
+	clock Clock
+	mu    sync.Mutex
+	stats SyncStats
+}
+
+func newSyncBreaker(clock Clock) *syncBreaker {
+	if clock == nil {
+		clock = SystemClock
+	}
+	return &syncBreaker{clock: clock}
+}
+
+// allow returns whether a sync is attempted now.
+func (b *syncBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats.State == SyncStateClosed || !b.clock.Now().Before(b.stats.RetryAt)
+}
+
+// done records the result of a sync, and schedules the retry if it failed.
+func (b *syncBreaker) done(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := &b.stats
+	if err == nil {
+		if s.State != SyncStateClosed {
+			s.Recoveries++
+		}
+		s.State, s.ConsecutiveFailures, s.RetryAt = SyncStateClosed, 0, time.Time{}
+		return
+	}
+	s.Failures++
+	s.ConsecutiveFailures++
+	s.LastErr = err
+	backoff := syncMaxBackoff
+	if s.ConsecutiveFailures < syncBreakerThreshold {
+		s.State = SyncStateRetrying
+		backoff = syncMinBackoff << (s.ConsecutiveFailures - 1)
+	} else {
+		s.State = SyncStateOpen
+	}
+	s.RetryAt = b.clock.Now().Add(backoff)
+}
+
+func (b *syncBreaker) syncStats() SyncStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
+
+// syncEverySecLocked syncs the modified file in the background unless the breaker backs off,
+// and keeps it modified until a sync succeeds. The records of the ack ring waiting for the sync are written then.
+// It returns the error of the failed sync.
+func (f *DiskFilter) syncEverySecLocked() error {
+	if !f.file.modified {
+		if f.file.fsync == FsyncModeNo {
+			return nil
+		}
+		// the adds of the records added since the last sync wrote nothing, or were synced by it
+		return f.flushAcksLocked()
+	}
+	// FsyncModeAlways syncs the bloom filter on every add, but not the metadata written by Control
+	if f.file.fsync != FsyncModeNo {
+		if !f.breaker.allow() {
+			return nil
+		}
+		tr := f.startTrace(TraceOpSync, KeyHash{})
+		err := f.syncFile()
+		if tr != nil {
+			f.endTrace(tr, false, err)
+		}
+		f.breaker.done(err)
+		if err != nil {
+			f.backgroundError(err)
+			return err
+		}
+		f.file.metadataModified = false
+		if err = f.flushAcksLocked(); err != nil {
+			// kept modified to retry
+			f.backgroundError(err)
+			return err
+		}
+	}
+	f.file.modified = false
+	return nil
+}
@@ -0,0 +1,74 @@
+package disk_bloom
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSyncBreaker(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	b := newSyncBreaker(clock)
+	for i := 0; i < syncBreakerThreshold-1; i++ {
+		b.done(FaultInjectedErr)
+		s := b.syncStats()
+		if s.State != SyncStateRetrying {
+			t.Fatalf("%v: should be retrying, got %v", i, s.State)
+		}
+		if want := clock.Now().Add(syncMinBackoff << i); !s.RetryAt.Equal(want) {
+			t.Fatalf("%v: should retry at %v, got %v", i, want, s.RetryAt)
+		}
+		if b.allow() {
+			t.Fatalf("%v: should back off", i)
+		}
+		clock.Set(s.RetryAt)
+		if !b.allow() {
+			t.Fatalf("%v: should retry", i)
+		}
+	}
+	b.done(FaultInjectedErr)
+	if s := b.syncStats(); s.State != SyncStateOpen || !s.RetryAt.Equal(clock.Now().Add(syncMaxBackoff)) {
+		t.Fatalf("Should open, got %+v", s)
+	}
+	b.done(nil)
+	s := b.syncStats()
+	if s.State != SyncStateClosed || s.ConsecutiveFailures != 0 || s.Failures != syncBreakerThreshold || s.Recoveries != 1 ||
+		!errors.Is(s.LastErr, FaultInjectedErr) {
+		t.Fatalf("Should recover, got %+v", s)
+	}
+}
+
+func TestDiskFilter_SyncRecovery(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	injector := NewFaultInjector(1)
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeEverySec, Clock: clock, FaultInjector: injector})
+	injector.Set(Faults{SyncErrRate: 1})
+	bf.ExistOrAdd([]byte("key"))
+	tick := func() {
+		bf.file.mu.Lock()
+		defer bf.file.mu.Unlock()
+		bf.syncEverySecLocked()
+	}
+	tick()
+	if s := bf.Stats().Sync; s.State != SyncStateRetrying || s.ConsecutiveFailures != 1 {
+		t.Fatalf("Should retry, got %+v", s)
+	}
+	// backing off
+	tick()
+	if injector.Injected() != 1 {
+		t.Fatalf("Should not sync while backing off, synced %v times", injector.Injected())
+	}
+	// the disk recovers
+	injector.Set(Faults{})
+	clock.Advance(syncMinBackoff)
+	tick()
+	if s := bf.Stats().Sync; s.State != SyncStateClosed || s.Recoveries != 1 {
+		t.Fatalf("Should recover, got %+v", s)
+	}
+	bf.file.mu.Lock()
+	modified := bf.file.modified
+	bf.file.mu.Unlock()
+	if modified {
+		t.Fatal("Should be synced")
+	}
+}
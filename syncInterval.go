@@ -0,0 +1,61 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultSyncInterval is the interval of the background goroutine if Controller.SyncInterval is not given.
+	DefaultSyncInterval = time.Second
+	MinSyncInterval     = 10 * time.Millisecond
+	MaxSyncInterval     = time.Hour
+)
+
+var SyncIntervalErr = fmt.Errorf("invalid sync interval")
+
+// checkSyncInterval validates the interval and the jitter, where a zero interval is DefaultSyncInterval.
+func checkSyncInterval(interval, jitter time.Duration) (time.Duration, error) {
+	if interval == 0 {
+		interval = DefaultSyncInterval
+	}
+	if interval < MinSyncInterval || interval > MaxSyncInterval {
+		return 0, fmt.Errorf("%w: %v, which should be from %v to %v", SyncIntervalErr, interval, MinSyncInterval, MaxSyncInterval)
+	}
+	if jitter < 0 || jitter > interval {
+		return 0, fmt.Errorf("%w: jitter %v, which should be from 0 to the interval %v", SyncIntervalErr, jitter, interval)
+	}
+	return interval, nil
+}
+
+// SetSyncInterval changes Controller.SyncInterval and Controller.SyncJitter of the open filter,
+// which take effect from the next tick. A zero interval is DefaultSyncInterval.
+func (f *DiskFilter) SetSyncInterval(interval, jitter time.Duration) error {
+	interval, err := checkSyncInterval(interval, jitter)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt64(&f.syncInterval, int64(interval))
+	atomic.StoreInt64(&f.syncJitter, int64(jitter))
+	select {
+	case f.rescheduled <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// SyncInterval returns the interval and the jitter of the background goroutine.
+func (f *DiskFilter) SyncInterval() (interval, jitter time.Duration) {
+	return time.Duration(atomic.LoadInt64(&f.syncInterval)), time.Duration(atomic.LoadInt64(&f.syncJitter))
+}
+
+// nextTick returns the time to wait for the next tick, which is the interval delayed by a random jitter.
+func (f *DiskFilter) nextTick() time.Duration {
+	interval, jitter := f.SyncInterval()
+	if jitter > 0 {
+		interval += time.Duration(rand.Int63n(int64(jitter)))
+	}
+	return interval
+}
@@ -0,0 +1,48 @@
+package disk_bloom
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDiskFilter_SyncInterval(t *testing.T) {
+	var ticks int64
+	bf := newTestFilter(t, Controller{
+		Fsync:        FsyncModeEverySec,
+		SyncInterval: 20 * time.Millisecond,
+		SyncJitter:   10 * time.Millisecond,
+		Control: func(f *os.File, modified bool) {
+			atomic.AddInt64(&ticks, 1)
+		},
+	})
+	time.Sleep(300 * time.Millisecond)
+	if n := atomic.LoadInt64(&ticks); n < 5 {
+		t.Fatalf("Should tick every 20-30ms, got %v ticks in 300ms", n)
+	}
+	if err := bf.SetSyncInterval(time.Hour, 0); err != nil {
+		t.Fatal(err)
+	}
+	if interval, jitter := bf.SyncInterval(); interval != time.Hour || jitter != 0 {
+		t.Fatalf("Unexpected interval %v and jitter %v", interval, jitter)
+	}
+	// a tick in progress
+	time.Sleep(50 * time.Millisecond)
+	n := atomic.LoadInt64(&ticks)
+	time.Sleep(100 * time.Millisecond)
+	if atomic.LoadInt64(&ticks) != n {
+		t.Fatal("Should not tick once the interval is changed to an hour")
+	}
+	if err := bf.SetSyncInterval(time.Millisecond, 0); !errors.Is(err, SyncIntervalErr) {
+		t.Fatalf("Should reject the interval, got %v", err)
+	}
+	if err := bf.SetSyncInterval(time.Second, 2*time.Second); !errors.Is(err, SyncIntervalErr) {
+		t.Fatalf("Should reject the jitter, got %v", err)
+	}
+	if _, err := New("testfile2", Controller{SyncInterval: 2 * time.Hour}); !errors.Is(err, SyncIntervalErr) {
+		t.Fatalf("Should reject the interval, got %v", err)
+	}
+	os.Remove("testfile2")
+}
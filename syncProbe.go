@@ -0,0 +1,106 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// syncProbeRounds is the number of syncs timed by ProbeSync.
+	syncProbeRounds = 5
+	// syncProbeMinLatency is the latency below which a sync of a page just written can not have reached stable storage.
+	syncProbeMinLatency = 10 * time.Microsecond
+)
+
+var NotDurableErr = fmt.Errorf("syncs do not look durable")
+
+// SyncProbeMode is whether New probes the durability of the syncs, see Controller.SyncProbe.
+type SyncProbeMode uint8
+
+const (
+	// SyncProbeOff does not probe the syncs.
+	SyncProbeOff SyncProbeMode = iota
+	// SyncProbeWarn probes the syncs, and reports the result by Controller.OnSyncProbe and Stats.
+	SyncProbeWarn
+	// SyncProbeStrict is SyncProbeWarn, but New fails with NotDurableErr if the syncs do not look durable.
+	SyncProbeStrict
+)
+
+// SyncProbe is the result of ProbeSync.
+type SyncProbe struct {
+	// Filesystem is the type of the filesystem, or empty if it is not known on the platform
+	Filesystem string
+	// Latency is the median latency of a sync of a page just written
+	Latency time.Duration
+	// Durable is whether the syncs look durable, and Reason explains why not
+	Durable bool
+	Reason  string
+}
+
+// ProbeSync checks whether the syncs of the files in dir look durable with a temporary file, which is removed before it returns.
+// Some filesystems acknowledge a sync without reaching stable storage, e.g. tmpfs, or FUSE and SMB mounts caching the writes,
+// on which FsyncModeAlways silently degrades to FsyncModeNo. A sync can not be proven durable without pulling the plug,
+// so the probe is a heuristic: it rejects the filesystems known to be volatile or to cache the syncs on Linux,
+// and the syncs returning faster than any device writes a page.
+func ProbeSync(dir string) (SyncProbe, error) {
+	var p SyncProbe
+	f, err := os.CreateTemp(dir, ".syncprobe-*")
+	if err != nil {
+		return p, err
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+	var volatile bool
+	p.Filesystem, volatile = filesystemType(f)
+	page := make([]byte, pageSize)
+	latencies := make([]time.Duration, syncProbeRounds)
+	for i := range latencies {
+		// a sync with nothing dirty is free even on durable filesystems, so dirty a page every round
+		page[0] = byte(i + 1)
+		if _, err = f.WriteAt(page, 0); err != nil {
+			return p, err
+		}
+		start := time.Now()
+		if err = f.Sync(); err != nil {
+			return p, err
+		}
+		latencies[i] = time.Since(start)
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	p.Latency = latencies[len(latencies)/2]
+	switch {
+	case volatile:
+		p.Reason = fmt.Sprintf("%v is volatile or caches the syncs", p.Filesystem)
+	case p.Latency < syncProbeMinLatency:
+		p.Reason = fmt.Sprintf("syncs take %v, faster than a device writes a page", p.Latency)
+	default:
+		p.Durable = true
+	}
+	return p, nil
+}
+
+// probeSync probes the syncs of the directory of filename for Controller.SyncProbe,
+// and fails in SyncProbeStrict if they do not look durable.
+func probeSync(filename string, controller Controller) (SyncProbe, error) {
+	if controller.SyncProbe == SyncProbeOff || controller.ReadOnly {
+		return SyncProbe{}, nil
+	}
+	p, err := ProbeSync(filepath.Dir(filename))
+	if err != nil {
+		return p, err
+	}
+	if !p.Durable {
+		if controller.OnSyncProbe != nil {
+			controller.OnSyncProbe(p)
+		}
+		if controller.SyncProbe == SyncProbeStrict {
+			return p, fmt.Errorf("%w: %v", NotDurableErr, p.Reason)
+		}
+	}
+	return p, nil
+}
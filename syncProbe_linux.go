@@ -0,0 +1,40 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// filesystems are the names of the filesystem magics of statfs, and whether their syncs are volatile.
+var filesystems = map[uint32]struct {
+	name     string
+	volatile bool
+}{
+	0xef53:     {"ext4", false},
+	0x58465342: {"xfs", false},
+	0x9123683e: {"btrfs", false},
+	0x2fc12fc1: {"zfs", false},
+	0xf2f52010: {"f2fs", false},
+	0x6969:     {"nfs", false},
+	0x794c7630: {"overlayfs", false},
+	0x01021994: {"tmpfs", true},
+	0x858458f6: {"ramfs", true},
+	0x65735546: {"fuse", true},
+	0xff534d42: {"cifs", true},
+	0xfe534d42: {"smb2", true},
+	0x01021997: {"9p", true},
+}
+
+// filesystemType returns the type of the filesystem of f, and whether its syncs are volatile.
+func filesystemType(f *os.File) (name string, volatile bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Fstatfs(int(f.Fd()), &st); err != nil {
+		return "", false
+	}
+	fs, ok := filesystems[uint32(st.Type)]
+	if !ok {
+		return fmt.Sprintf("0x%x", uint32(st.Type)), false
+	}
+	return fs.name, fs.volatile
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package disk_bloom
+
+import "os"
+
+// filesystemType is only known on Linux, so only the latency of the syncs is probed elsewhere.
+func filesystemType(f *os.File) (name string, volatile bool) {
+	return "", false
+}
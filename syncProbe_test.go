@@ -0,0 +1,68 @@
+package disk_bloom
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"testing"
+)
+
+func TestProbeSync(t *testing.T) {
+	p, err := ProbeSync(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%+v", p)
+	if p.Latency <= 0 {
+		t.Fatalf("Should time the syncs, got %+v", p)
+	}
+	if p.Durable == (p.Reason != "") {
+		t.Fatalf("Should explain the syncs not durable only, got %+v", p)
+	}
+	if runtime.GOOS == "linux" && p.Filesystem == "" {
+		t.Fatalf("Should know the filesystem on Linux, got %+v", p)
+	}
+	if _, err = ProbeSync("not-exists"); !os.IsNotExist(err) {
+		t.Fatalf("Should fail on a missing directory, got %v", err)
+	}
+	if info, err := os.Stat("/dev/shm"); runtime.GOOS == "linux" && err == nil && info.IsDir() {
+		if p, err = ProbeSync("/dev/shm"); err != nil {
+			t.Fatal(err)
+		}
+		if p.Durable || p.Filesystem != "tmpfs" {
+			t.Fatalf("Should find tmpfs volatile, got %+v", p)
+		}
+	}
+}
+
+func TestController_SyncProbe(t *testing.T) {
+	expected, err := ProbeSync(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var warned bool
+	bf := newTestFilter(t, Controller{SyncProbe: SyncProbeWarn, OnSyncProbe: func(SyncProbe) { warned = true }})
+	probe := bf.Stats().Sync.Probe
+	// the latency varies between the probes, so only the filesystem is compared
+	if probe.Filesystem != expected.Filesystem || probe.Latency <= 0 || warned == probe.Durable {
+		t.Fatalf("Should report the probe %+v, got %+v warned %v", expected, probe, warned)
+	}
+	if bf = newTestFilter(t, Controller{}); bf.Stats().Sync.Probe != (SyncProbe{}) {
+		t.Fatal("Should not probe by default")
+	}
+	if info, err := os.Stat("/dev/shm"); runtime.GOOS != "linux" || err != nil || !info.IsDir() {
+		t.Skip("no tmpfs")
+	}
+	const filename = "/dev/shm/testfile-syncprobe"
+	_, err = New(filename, Controller{SyncProbe: SyncProbeStrict, GetParam: func([]byte) (FilterParam, []byte) {
+		return FilterParam{Slots: 4, Bits: 1 << 16, Hash: doubleFNV}, nil
+	}})
+	if !errors.Is(err, NotDurableErr) {
+		_ = os.Remove(filename)
+		t.Fatalf("Should refuse tmpfs, got %v", err)
+	}
+	if _, err = os.Stat(filename); !os.IsNotExist(err) {
+		_ = os.Remove(filename)
+		t.Fatalf("Should not create the file, got %v", err)
+	}
+}
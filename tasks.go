@@ -0,0 +1,122 @@
+package disk_bloom
+
+import (
+	"sync"
+	"time"
+)
+
+// TaskStats are the state of a background task, see DiskFilter.Tasks.
+type TaskStats struct {
+	Name string
+	// Running is whether the task is running, which stops once its owner is closed
+	Running bool
+	// Failures is the number of times the task failed, and LastErr is the error of the last failure at LastErrAt,
+	// which is kept after the recovery
+	Failures  uint64
+	LastErr   error
+	LastErrAt time.Time
+}
+
+// taskGroup runs the background tasks of an owner, e.g. a filter: they are canceled once done is closed,
+// and wait waits for them, so that no goroutine outlives the owner.
+type taskGroup struct {
+	done <-chan struct{}
+	mu   sync.Mutex
+	cond *sync.Cond
+	// blocking is the number of running tasks which wait waits for
+	blocking int
+	tasks    []*TaskStats
+}
+
+func newTaskGroup(done <-chan struct{}) *taskGroup {
+	g := &taskGroup{done: done}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+// task is a background task of a taskGroup.
+type task struct {
+	g     *taskGroup
+	stats *TaskStats
+}
+
+// start runs fn in the background as the task of name, whose stats are shared by the tasks of the same name.
+func (g *taskGroup) start(name string, fn func(t *task)) {
+	g.mu.Lock()
+	var stats *TaskStats
+	for _, s := range g.tasks {
+		if s.Name == name {
+			stats = s
+		}
+	}
+	if stats == nil {
+		stats = &TaskStats{Name: name}
+		g.tasks = append(g.tasks, stats)
+	}
+	stats.Running = true
+	g.blocking++
+	g.mu.Unlock()
+	go func() {
+		defer func() {
+			g.mu.Lock()
+			stats.Running = false
+			g.blocking--
+			g.cond.Broadcast()
+			g.mu.Unlock()
+		}()
+		fn(&task{g: g, stats: stats})
+	}()
+}
+
+// wait waits for the running tasks, which should be canceled first.
+func (g *taskGroup) wait() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for g.blocking > 0 {
+		g.cond.Wait()
+	}
+}
+
+// stats returns the stats of the tasks in the order they were first started.
+func (g *taskGroup) stats() []TaskStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	stats := make([]TaskStats, len(g.tasks))
+	for i, s := range g.tasks {
+		stats[i] = *s
+	}
+	return stats
+}
+
+// done returns the channel closed once the task is canceled.
+func (t *task) done() <-chan struct{} {
+	return t.g.done
+}
+
+// report records err as a failure of the task if it is not nil, and returns it.
+func (t *task) report(err error) error {
+	if err == nil {
+		return nil
+	}
+	t.g.mu.Lock()
+	t.stats.Failures++
+	t.stats.LastErr, t.stats.LastErrAt = err, SystemClock.Now()
+	t.g.mu.Unlock()
+	return err
+}
+
+// detach runs fn without being waited for by wait, e.g. a callback of the application which may close the owner.
+// The task should not touch its owner after fn returns if it is canceled meanwhile.
+func (t *task) detach(fn func()) {
+	g := t.g
+	g.mu.Lock()
+	g.blocking--
+	g.cond.Broadcast()
+	g.mu.Unlock()
+	defer func() {
+		g.mu.Lock()
+		g.blocking++
+		g.mu.Unlock()
+	}()
+	fn()
+}
@@ -0,0 +1,68 @@
+package disk_bloom
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestDiskFilter_TasksStopOnClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		bf := newTestFilter(t, Controller{Fsync: FsyncModeEverySec, MetadataSync: time.Hour, WriteBuffer: time.Hour, Hybrid: time.Hour})
+		if tasks := bf.Tasks(); len(tasks) != 4 || !tasks[0].Running || tasks[0].Name != "sync" {
+			t.Fatalf("Should run the background tasks, got %+v", tasks)
+		}
+		if err := bf.Close(); err != nil {
+			t.Fatal(err)
+		}
+		for _, task := range bf.Tasks() {
+			if task.Running {
+				t.Fatalf("%v should stop once closed", task.Name)
+			}
+		}
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Fatalf("Should leak no goroutines, got %v before and %v after", before, after)
+	}
+}
+
+func TestDiskFilter_TasksLastErr(t *testing.T) {
+	injector := NewFaultInjector(1)
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeEverySec, SyncInterval: MinSyncInterval, FaultInjector: injector})
+	injector.Set(Faults{SyncErrRate: 1})
+	bf.ExistOrAdd([]byte("key"))
+	deadline := time.Now().Add(5 * time.Second)
+	for bf.Tasks()[0].Failures == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Should record the failed sync")
+		}
+		time.Sleep(MinSyncInterval)
+	}
+	task := bf.Tasks()[0]
+	if !errors.Is(task.LastErr, FaultInjectedErr) || task.LastErrAt.IsZero() {
+		t.Fatalf("Should keep the last error, got %+v", task)
+	}
+}
+
+func TestDiskFilter_CloseInFillCallback(t *testing.T) {
+	var bf *DiskFilter
+	closed := make(chan error, 1)
+	bf = newTestFilter(t, Controller{
+		SyncInterval:   MinSyncInterval,
+		FillThresholds: []float64{1e-9},
+		OnFillThreshold: func(threshold float64, fill float64) {
+			closed <- bf.Close()
+		},
+	})
+	bf.ExistOrAdd([]byte("key"))
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Should close the filter from the callback")
+	}
+}
@@ -0,0 +1,39 @@
+package disk_bloom
+
+import (
+	"math"
+)
+
+// Fill returns the estimated number of entries added as a fraction of the design capacity, see Controller.FillThresholds.
+// It is derived from the bits set like EstimateCount, and is 0 for filters other than classic ones.
+func (f *DiskFilter) Fill() float64 {
+	ratio := f.FillRatio()
+	if ratio >= 1 {
+		return math.Inf(1)
+	}
+	// the estimated count -(m/k)·ln(1-X/m) over the design capacity m·ln2/k
+	return -math.Log(1-ratio) / math.Ln2
+}
+
+// initFillThresholds counts the bits set of an existing file, see countSetBits.
+func (f *DiskFilter) initFillThresholds() error {
+	if f.header.variant() != variantClassic {
+		return nil
+	}
+	f.reached = make([]bool, len(f.controller.FillThresholds))
+	return f.countSetBits()
+}
+
+// checkFillThresholds invokes OnFillThreshold for the thresholds newly reached.
+func (f *DiskFilter) checkFillThresholds() {
+	if f.reached == nil || f.controller.OnFillThreshold == nil {
+		return
+	}
+	fill := f.Fill()
+	for i, threshold := range f.controller.FillThresholds {
+		if !f.reached[i] && fill >= threshold {
+			f.reached[i] = true
+			f.controller.OnFillThreshold(threshold, fill)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDiskFilter_FillThresholds(t *testing.T) {
+	var mu sync.Mutex
+	var reached []float64
+	controller := Controller{
+		Fsync:          FsyncModeNo,
+		FillThresholds: []float64{0.5, 0.9},
+		OnFillThreshold: func(threshold float64, fill float64) {
+			mu.Lock()
+			defer mu.Unlock()
+			reached = append(reached, threshold)
+		},
+	}
+	bf := newTestFilter(t, controller)
+	// the design capacity is 1e4
+	for i := 0; i < 6000; i++ {
+		bf.ExistOrAdd([]byte(fmt.Sprint(i)))
+	}
+	if fill := bf.Fill(); fill < 0.55 || fill > 0.65 {
+		t.Fatalf("fill should be about 0.6, got %v", fill)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	mu.Lock()
+	if len(reached) != 1 || reached[0] != 0.5 {
+		t.Fatalf("only 0.5 should be reached, got %v", reached)
+	}
+	mu.Unlock()
+
+	fill := bf.Fill()
+	if err := bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	bf = newTestFilter(t, controller)
+	if reopened := bf.Fill(); reopened != fill {
+		t.Fatalf("fill should be %v after reopening, got %v", fill, reopened)
+	}
+}
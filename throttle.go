@@ -0,0 +1,109 @@
+package disk_bloom
+
+import (
+	"sync"
+	"time"
+)
+
+// ThrottledWriter paces inserts into a Filter, so that bulk backfills do not starve foreground lookups on the same disk.
+// The pace adapts to the lookup latency: it halves whenever the observed latency exceeds the target,
+// and recovers linearly up to the configured rate otherwise.
+type ThrottledWriter struct {
+	filter Filter
+
+	mu      sync.Mutex
+	maxRate float64
+	rate    float64
+	next    time.Time
+	// target lookup latency; zero disables the adaptation
+	target time.Duration
+	// exponentially weighted moving average of the lookup latency
+	latency time.Duration
+}
+
+// NewThrottledWriter returns a ThrottledWriter adding at most ratelimit entries per second to filter.
+func NewThrottledWriter(filter Filter, ratelimit float64) *ThrottledWriter {
+	return &ThrottledWriter{
+		filter:  filter,
+		maxRate: ratelimit,
+		rate:    ratelimit,
+	}
+}
+
+// SetTargetLatency enables the adaptation to keep lookups under target. Zero disables it.
+func (w *ThrottledWriter) SetTargetLatency(target time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.target = target
+	if target == 0 {
+		w.rate = w.maxRate
+	}
+}
+
+// Rate returns the current insert rate in entries per second.
+func (w *ThrottledWriter) Rate() float64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rate
+}
+
+// ObserveLookup reports the latency of a foreground lookup done without the ThrottledWriter.
+func (w *ThrottledWriter) ObserveLookup(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.latency == 0 {
+		w.latency = d
+	} else {
+		w.latency = (w.latency*7 + d) / 8
+	}
+	if w.target == 0 {
+		return
+	}
+	if w.latency > w.target {
+		w.rate /= 2
+		if minRate := w.maxRate / 64; w.rate < minRate {
+			w.rate = minRate
+		}
+	} else if w.rate < w.maxRate {
+		w.rate += w.maxRate / 64
+		if w.rate > w.maxRate {
+			w.rate = w.maxRate
+		}
+	}
+}
+
+// wait blocks until the next insert is allowed.
+func (w *ThrottledWriter) wait() {
+	w.mu.Lock()
+	now := time.Now()
+	if w.next.Before(now) {
+		w.next = now
+	}
+	slot := w.next
+	if w.rate > 0 {
+		w.next = w.next.Add(time.Duration(float64(time.Second) / w.rate))
+	}
+	w.mu.Unlock()
+	if d := slot.Sub(now); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// Exist returns if an entry is in the filter. It is not throttled, and its latency is observed.
+func (w *ThrottledWriter) Exist(b []byte) bool {
+	start := time.Now()
+	exist := w.filter.Exist(b)
+	w.ObserveLookup(time.Since(start))
+	return exist
+}
+
+// ExistOrAdd waits for its turn, and then invokes ExistOrAdd of the filter.
+func (w *ThrottledWriter) ExistOrAdd(b []byte) bool {
+	w.wait()
+	return w.filter.ExistOrAdd(b)
+}
+
+// Close closes the filter.
+func (w *ThrottledWriter) Close() error {
+	return w.filter.Close()
+}
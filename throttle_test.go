@@ -0,0 +1,39 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestThrottledWriter(t *testing.T) {
+	s, err := NewSet("testfile", FsyncModeNo, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		os.Remove("testfile")
+	}()
+	w := NewThrottledWriter(s, 1000)
+	defer w.Close()
+	start := time.Now()
+	for i := 0; i < 50; i++ {
+		w.ExistOrAdd([]byte(fmt.Sprint(i)))
+	}
+	if d := time.Since(start); d < 45*time.Millisecond {
+		t.Fatalf("50 inserts at 1000/s should take about 50ms, took %v", d)
+	}
+
+	w.SetTargetLatency(time.Millisecond)
+	w.ObserveLookup(10 * time.Millisecond)
+	if rate := w.Rate(); rate != 500 {
+		t.Fatalf("Rate should be halved, got %v", rate)
+	}
+	for i := 0; i < 1000; i++ {
+		w.ObserveLookup(0)
+	}
+	if rate := w.Rate(); rate != 1000 {
+		t.Fatalf("Rate should recover, got %v", rate)
+	}
+}
@@ -0,0 +1,155 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// TombstoneFilter pairs a primary filter of the entries added with a filter of the entries deleted, the tombstones,
+// so that deletes are supported on classic filters: an entry exists if it is in the primary and not in the tombstones.
+// A false positive of the tombstones hides an entry of the primary, which is a false negative,
+// so the tombstones are bounded: once they are filled to the threshold of their design capacity, see DiskFilter.Fill,
+// the onRebuild of NewTombstone is invoked to rebuild the primary without the entries deleted, and Rebuild clears the tombstones.
+type TombstoneFilter struct {
+	primary    *DiskFilter
+	tombstones *DiskFilter
+	threshold  float64
+	onRebuild  func(fill float64)
+	// the lookups, adds and deletes hold the read lock, and Rebuild holds the write lock.
+	mu sync.RWMutex
+	// signaled is whether onRebuild is invoked since the last Rebuild
+	signaled int32
+}
+
+// NewTombstone returns a TombstoneFilter of the primary and the tombstones, both classic filters, which are closed by Close.
+// onRebuild is invoked once the tombstones reach threshold, e.g. 0.5 of their design capacity, with their fill.
+// It is invoked once until Rebuild, by the Delete reaching the threshold or by NewTombstone if it is already reached,
+// and should not block, e.g. schedule the rebuild.
+func NewTombstone(primary, tombstones *DiskFilter, threshold float64, onRebuild func(fill float64)) (*TombstoneFilter, error) {
+	if threshold <= 0 {
+		return nil, fmt.Errorf("%w: the rebuild threshold %v", InvalidParamErr, threshold)
+	}
+	if primary.header.variant() != variantClassic || tombstones.header.variant() != variantClassic {
+		return nil, fmt.Errorf("%w: tombstones of filters other than classic ones", UnsupportedErr)
+	}
+	t := &TombstoneFilter{
+		primary:    primary,
+		tombstones: tombstones,
+		threshold:  threshold,
+		onRebuild:  onRebuild,
+	}
+	t.checkFill()
+	return t, nil
+}
+
+// Exist returns whether the entry is in the primary and not deleted.
+func (t *TombstoneFilter) Exist(b []byte) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.primary.Exist(b) && !t.tombstones.Exist(b)
+}
+
+// ExistOrAdd returns whether the entry exists, and adds it to the primary if it does not.
+// An entry deleted is not added again until the primary is rebuilt, since its tombstone can not be removed.
+func (t *TombstoneFilter) ExistOrAdd(b []byte) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.primary.ExistOrAdd(b) && !t.tombstones.Exist(b)
+}
+
+// Delete deletes the entry by adding it to the tombstones. Entries which do not exist are not added,
+// so that they do not fill the tombstones.
+func (t *TombstoneFilter) Delete(b []byte) error {
+	t.mu.RLock()
+	if !t.primary.Exist(b) {
+		t.mu.RUnlock()
+		return nil
+	}
+	err := t.tombstones.Add(b)
+	t.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	t.checkFill()
+	return nil
+}
+
+// Fill returns the fill of the tombstones, see DiskFilter.Fill.
+func (t *TombstoneFilter) Fill() float64 {
+	return t.tombstones.Fill()
+}
+
+// EstimateFNR estimates the rate of the entries of the primary hidden by false positives of the tombstones.
+func (t *TombstoneFilter) EstimateFNR() float64 {
+	return math.Pow(t.tombstones.FillRatio(), float64(t.tombstones.param.Slots))
+}
+
+// Rebuild replaces the primary by the filter file at path, rebuilt without the entries deleted, e.g. from the source of truth,
+// see DiskFilter.ReplaceWith, and clears the tombstones. onRebuild is invoked again once they reach the threshold again.
+// If the tombstones fail to be cleared, they keep hiding the entries deleted, and Rebuild can be retried with another file.
+func (t *TombstoneFilter) Rebuild(path string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.primary.ReplaceWith(path); err != nil {
+		return err
+	}
+	if err := t.tombstones.clearBits(); err != nil {
+		return err
+	}
+	if err := t.tombstones.Flush(); err != nil {
+		return err
+	}
+	atomic.StoreInt32(&t.signaled, 0)
+	return nil
+}
+
+// Close closes the primary and the tombstones.
+func (t *TombstoneFilter) Close() error {
+	err := t.primary.Close()
+	if e := t.tombstones.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// checkFill invokes OnRebuild if the tombstones reach the threshold, unless it is invoked since the last Rebuild.
+func (t *TombstoneFilter) checkFill() {
+	if t.onRebuild == nil {
+		return
+	}
+	if fill := t.tombstones.Fill(); fill >= t.threshold && atomic.CompareAndSwapInt32(&t.signaled, 0, 1) {
+		t.onRebuild(fill)
+	}
+}
+
+// clearBits clears the bloom filter, e.g. of the tombstones once the primary is rebuilt without the entries deleted.
+// The bytes buffered are dropped.
+func (f *DiskFilter) clearBits() error {
+	return quiesce([]*DiskFilter{f}, func() error {
+		if f.readOnly {
+			return f.readOnlyErr()
+		}
+		for pos := range f.pending {
+			f.removePendingLocked(pos)
+		}
+		f.buffered = 0
+		size := f.header.bloomSize(f.param.Bits)
+		zeros := make([]byte, ttlZeroChunk)
+		for n := int64(0); n < size; n += ttlZeroChunk {
+			chunk := zeros
+			if size-n < ttlZeroChunk {
+				chunk = zeros[:size-n]
+			}
+			if err := f.writeRangeLocked(chunk, f.fileOffset(n)); err != nil {
+				return f.onWriteErrorLocked(err, nil)
+			}
+		}
+		f.noteIO(ioOpWrite, nil)
+		f.file.modified = true
+		atomic.StoreUint64(&f.setBits, 0)
+		atomic.StoreInt32(&f.counted, 1)
+		return nil
+	})
+}
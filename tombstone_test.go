@@ -0,0 +1,134 @@
+package disk_bloom
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func openTombstoneFilter(t *testing.T, dir string, threshold float64, onRebuild func(fill float64)) *TombstoneFilter {
+	controller := Controller{
+		Fsync: FsyncModeNo,
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			slots, bits := OptimalParam(100, 1e-4)
+			return FilterParam{Slots: slots, Bits: bits, Hash: doubleFNV}, nil
+		},
+	}
+	primary, err := New(filepath.Join(dir, "primary"), controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tombstones, err := New(filepath.Join(dir, "tombstones"), controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tf, err := NewTombstone(primary, tombstones, threshold, onRebuild)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tf
+}
+
+func TestTombstoneFilter(t *testing.T) {
+	dir := t.TempDir()
+	var signals []float64
+	tf := openTombstoneFilter(t, dir, 0.5, func(fill float64) {
+		signals = append(signals, fill)
+	})
+	for i := 0; i < 100; i++ {
+		tf.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	// deleting the entries not added does not fill the tombstones
+	for i := 100; i < 200; i++ {
+		if err := tf.Delete([]byte(strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fill := tf.Fill(); fill != 0 {
+		t.Fatalf("Should not fill the tombstones, got %v", fill)
+	}
+	for i := 0; i < 60; i++ {
+		if err := tf.Delete([]byte(strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+		if i < 40 && len(signals) > 0 {
+			t.Fatalf("Should not signal the rebuild after %v deletes, got %v", i+1, signals)
+		}
+	}
+	if len(signals) != 1 || signals[0] < 0.5 {
+		t.Fatalf("Should signal the rebuild once, got %v", signals)
+	}
+	for i := 0; i < 60; i++ {
+		if tf.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should be deleted", i)
+		}
+	}
+	if fnr := tf.EstimateFNR(); fnr <= 0 || fnr > 1e-3 {
+		t.Fatalf("Should estimate a small false negative rate, got %v", fnr)
+	}
+	if tf.ExistOrAdd([]byte("0")) || tf.Exist([]byte("0")) {
+		t.Fatal("Should not add an entry deleted before the rebuild")
+	}
+
+	// rebuilt from the entries not deleted
+	rebuilt, err := New(filepath.Join(dir, "rebuilt"), tf.primary.Controller())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 60; i < 100; i++ {
+		rebuilt.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	if err = rebuilt.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = tf.Rebuild(filepath.Join(dir, "rebuilt")); err != nil {
+		t.Fatal(err)
+	}
+	if fill := tf.Fill(); fill != 0 {
+		t.Fatalf("Should clear the tombstones, got %v", fill)
+	}
+	for i := 60; i < 100; i++ {
+		if !tf.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should exist", i)
+		}
+	}
+	if tf.ExistOrAdd([]byte("0")) || !tf.Exist([]byte("0")) {
+		t.Fatal("Should add an entry deleted before the rebuild")
+	}
+	for i := 100; i < 120; i++ {
+		tf.ExistOrAdd([]byte(strconv.Itoa(i)))
+	}
+	for i := 60; i < 120; i++ {
+		if err = tf.Delete([]byte(strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(signals) != 2 {
+		t.Fatalf("Should signal the rebuild again, got %v", signals)
+	}
+	if err = tf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the tombstones are kept, and the threshold reached is signaled on open
+	tf = openTombstoneFilter(t, dir, 0.3, func(fill float64) {
+		signals = append(signals, fill)
+	})
+	defer tf.Close()
+	if len(signals) != 3 {
+		t.Fatalf("Should signal the rebuild on open, got %v", signals)
+	}
+	if tf.Exist([]byte("60")) || !tf.Exist([]byte("0")) {
+		t.Fatal("Should keep the deletes and the adds")
+	}
+}
+
+func TestNewTombstone_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	tf := openTombstoneFilter(t, dir, 0.5, nil)
+	defer tf.Close()
+	if _, err := NewTombstone(tf.primary, tf.tombstones, 0, nil); !errors.Is(err, InvalidParamErr) {
+		t.Fatalf("Should reject the threshold, got %v", err)
+	}
+}
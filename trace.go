@@ -0,0 +1,157 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"time"
+)
+
+// TraceOp is the kind of an operation traced, see Controller.Tracer.
+type TraceOp uint8
+
+const (
+	// TraceOpExist is a lookup by Exist and the variants of it
+	TraceOpExist TraceOp = iota
+	// TraceOpExistOrAdd is an add by ExistOrAdd and the variants of it
+	TraceOpExistOrAdd
+	// TraceOpSync is a sync of the file by the goroutine in the background
+	TraceOpSync
+	// TraceOpRotate is the preparation of the next filter of a FilterGroup, see WithTracer
+	TraceOpRotate
+)
+
+func (o TraceOp) String() string {
+	switch o {
+	case TraceOpExist:
+		return "exist"
+	case TraceOpExistOrAdd:
+		return "exist or add"
+	case TraceOpSync:
+		return "sync"
+	case TraceOpRotate:
+		return "rotate"
+	default:
+		return "invalid"
+	}
+}
+
+// Trace is the details of an operation given to the Tracer.
+type Trace struct {
+	Op       TraceOp
+	Filename string
+	// Hash is the hash of the entry looked up or added, which is zero for the other operations
+	Hash KeyHash
+	// Offsets are the sorted file offsets of the bytes probed, which should not be modified
+	Offsets []int64
+	// Reads is the number of reads from the storage, and BytesRead and BytesWritten are the bytes read and written,
+	// not counting the bytes served by Mmap or BlockCache
+	Reads        uint64
+	BytesRead    uint64
+	BytesWritten uint64
+	// Start is when the operation started, LockWait is the time waiting for the file lock, and Duration is the time of it
+	Start    time.Time
+	LockWait time.Duration
+	Duration time.Duration
+	Exist    bool
+	Err      error
+}
+
+func (t Trace) String() string {
+	s := fmt.Sprintf("%v %v: %v, lock wait %v, %v reads of %v bytes, %v bytes written",
+		t.Op, t.Filename, t.Duration, t.LockWait, t.Reads, t.BytesRead, t.BytesWritten)
+	if t.Op == TraceOpExist || t.Op == TraceOpExistOrAdd {
+		s += fmt.Sprintf(", hash %x/%x, offsets %v, exist %v", t.Hash.X, t.Hash.Y, t.Offsets, t.Exist)
+	}
+	if t.Err != nil {
+		s += fmt.Sprintf(", error: %v", t.Err)
+	}
+	return s
+}
+
+// Tracer is invoked with the details of the operations slower than Controller.SlowOp, e.g. to log them,
+// so that the spikes of the tail latency can be attributed to the disk. It is invoked synchronously
+// by the operations, so it should not block.
+type Tracer interface {
+	Trace(t Trace)
+}
+
+// TracerFunc is a Tracer of a function, e.g. TracerFunc(func(t Trace) { log.Println("slow:", t) }).
+type TracerFunc func(t Trace)
+
+func (fn TracerFunc) Trace(t Trace) {
+	fn(t)
+}
+
+// WithTracer sets the Tracer and the SlowOp of the filters of the group, see Controller.Tracer,
+// and traces the preparation of the next filter as TraceOpRotate.
+func WithTracer(tracer Tracer, slowOp time.Duration) GroupOption {
+	return func(g *FilterGroup) {
+		g.tracer, g.slowOp = tracer, slowOp
+	}
+}
+
+// traceRotate traces the preparation of the next filter started at start, if it is slow.
+func (g *FilterGroup) traceRotate(start time.Time, obj *filterObj, err error) {
+	if g.tracer == nil {
+		return
+	}
+	t := Trace{Op: TraceOpRotate, Start: start, Duration: time.Since(start), Err: err}
+	if obj != nil {
+		t.Filename = obj.filename
+	}
+	if t.Duration >= g.slowOp {
+		g.tracer.Trace(t)
+	}
+}
+
+// startTrace returns the Trace of an operation starting now, or nil if Controller.Tracer is not set.
+func (f *DiskFilter) startTrace(op TraceOp, h KeyHash) *Trace {
+	if f.controller.Tracer == nil {
+		return nil
+	}
+	return &Trace{Op: op, Filename: f.file.f.Name(), Hash: h, Start: time.Now()}
+}
+
+// endTrace finishes the Trace with the result of the operation, and invokes the Tracer if the operation is slow.
+func (f *DiskFilter) endTrace(t *Trace, exist bool, err error) {
+	t.Duration, t.Exist, t.Err = time.Since(t.Start), exist, err
+	if t.Duration >= f.controller.SlowOp {
+		f.controller.Tracer.Trace(*t)
+	}
+}
+
+// lockTraced is lock, which records the time waiting for the lock in t if traced.
+func (f *DiskFilter) lockTraced(t *Trace) {
+	if t == nil {
+		f.lock()
+		return
+	}
+	start := time.Now()
+	f.lock()
+	t.LockWait = time.Since(start)
+}
+
+// rlockTraced is rlock, which records the time waiting for the lock in t if traced.
+func (f *DiskFilter) rlockTraced(t *Trace) {
+	if t == nil {
+		f.rlock()
+		return
+	}
+	start := time.Now()
+	f.rlock()
+	t.LockWait = time.Since(start)
+}
+
+// read records the probes of the lookup of r if traced.
+func (t *Trace) read(r *pageReader) {
+	if t == nil {
+		return
+	}
+	t.Offsets, t.Reads, t.BytesRead = r.positions, r.reads, r.bytes
+}
+
+// wrote records the bytes written if traced.
+func (t *Trace) wrote(n int) {
+	if t != nil {
+		t.BytesWritten += uint64(n)
+	}
+}
@@ -0,0 +1,96 @@
+package disk_bloom
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type traceRecorder struct {
+	mu     sync.Mutex
+	traces []Trace
+}
+
+func (r *traceRecorder) Trace(t Trace) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.traces = append(r.traces, t)
+}
+
+func (r *traceRecorder) ops(op TraceOp) []Trace {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var traces []Trace
+	for _, t := range r.traces {
+		if t.Op == op {
+			traces = append(traces, t)
+		}
+	}
+	return traces
+}
+
+func TestDiskFilter_Tracer(t *testing.T) {
+	var r traceRecorder
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeEverySec, SyncInterval: MaxSyncInterval, Tracer: &r})
+	if exist, err := bf.ExistOrAddErr([]byte("key")); err != nil || exist {
+		t.Fatalf("Should add, got %v, %v", exist, err)
+	}
+	if exist, err := bf.ExistErr([]byte("key")); err != nil || !exist {
+		t.Fatalf("Should exist, got %v, %v", exist, err)
+	}
+	adds, lookups := r.ops(TraceOpExistOrAdd), r.ops(TraceOpExist)
+	if len(adds) != 1 || len(lookups) != 1 {
+		t.Fatalf("Should trace every operation, got %+v", r.traces)
+	}
+	add, lookup := adds[0], lookups[0]
+	if add.Exist || add.Hash != bf.Hash([]byte("key")) || add.Filename != "testfile" || add.BytesWritten == 0 || add.Reads == 0 {
+		t.Fatalf("Should trace the add, got %v", add)
+	}
+	if !lookup.Exist || len(lookup.Offsets) != len(add.Offsets) || lookup.BytesWritten != 0 || lookup.Duration <= 0 {
+		t.Fatalf("Should trace the lookup, got %v", lookup)
+	}
+	for i := range add.Offsets {
+		if lookup.Offsets[i] != add.Offsets[i] || i > 0 && add.Offsets[i] < add.Offsets[i-1] {
+			t.Fatalf("Should probe the sorted offsets, got %v and %v", add.Offsets, lookup.Offsets)
+		}
+	}
+
+	bf.file.mu.Lock()
+	err := bf.syncEverySecLocked()
+	bf.file.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if syncs := r.ops(TraceOpSync); len(syncs) != 1 || syncs[0].Err != nil {
+		t.Fatalf("Should trace the sync, got %+v", syncs)
+	}
+}
+
+func TestDiskFilter_TracerSlowOp(t *testing.T) {
+	var r traceRecorder
+	bf := newTestFilter(t, Controller{Tracer: &r, SlowOp: time.Hour})
+	bf.ExistOrAdd([]byte("key"))
+	bf.Exist([]byte("key"))
+	if len(r.traces) != 0 {
+		t.Fatalf("Should trace only the slow operations, got %+v", r.traces)
+	}
+}
+
+func TestFilterGroup_Tracer(t *testing.T) {
+	var r traceRecorder
+	g, err := NewGroup(filepath.Join(t.TempDir(), "*"), FsyncModeNo, 100, 1e-3, doubleFNV, WithTracer(&r, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.ExistOrAdd([]byte("key"))
+	if err = g.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if rotations := r.ops(TraceOpRotate); len(rotations) == 0 || rotations[0].Filename == "" || rotations[0].Err != nil {
+		t.Fatalf("Should trace the preparation of the next filter, got %+v", rotations)
+	}
+	if len(r.ops(TraceOpExistOrAdd)) != 1 {
+		t.Fatalf("Should trace the adds of the filters, got %+v", r.traces)
+	}
+}
@@ -0,0 +1,386 @@
+package disk_bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+// The bloom filter of a ttl filter is divided into the epoch table and the buckets of the same size:
+//
+// | epoch of bucket 0 (8 bytes) | ... | epoch of bucket n-1 | bucket 0 | ... | bucket n-1 |
+//
+// The epoch of an entry added at t is t / BucketSpan, which is added to the bucket epoch % Buckets.
+// A bucket is cleared once it is reused for a newer epoch, and an epoch of 0 means the bucket is unused.
+
+// ttlEpochSize is the size of the epoch of a bucket in the epoch table.
+const ttlEpochSize = 8
+
+// ttlZeroChunk is the size of the zeros written at a time to clear a bucket.
+const ttlZeroChunk = 64 << 10
+
+var InvalidTTLErr = fmt.Errorf("invalid ttl")
+
+// TTL returns whether the bloom filter is divided into time buckets whose entries expire.
+func (h Header) TTL() bool {
+	return h.Flags&FlagTTL != 0
+}
+
+// ttlBits returns the Bits of the bloom filter of a ttl filter whose buckets have the given bits.
+func ttlBits(bits uint64, buckets int) uint64 {
+	return uint64(buckets) * (ttlEpochSize + (bits+7)/8) * 8
+}
+
+// DiskTTLFilter is a disk-based Bloom filter whose entries expire, e.g. to detect the replays in the last minutes.
+// The bloom filter is divided into time buckets in one file: the entries are added to the bucket of the current time,
+// and Exist only looks at the buckets of the last ttl, so an entry is reported for at least the ttl after it is added,
+// and at most the ttl and one bucket span, which is ttl/(buckets-1).
+// A lookup probes every live bucket, and an add clears the oldest bucket once the time enters a new bucket.
+type DiskTTLFilter struct {
+	disk    *DiskFilter
+	buckets int64
+	span    time.Duration
+	// bucketBytes is the size of each bucket
+	bucketBytes int64
+	// epochs are the epochs of the buckets, guarded by the lock of the file
+	epochs []int64
+}
+
+// NewTTLFilter creates or opens a ttl filter, whose entries expire after ttl in the given number of buckets,
+// from 2 to 255, which should be the ones the file was created with. The controller is the same as New,
+// but the Bits of GetParam are the bits of each bucket, which is sized for the entries added in a bucket span,
+// and the time is told by Controller.Clock.
+// WriteBuffer, DiskFullPolicyBuffer, AdaptiveSlots, Journal and PinnedRange are not supported.
+func NewTTLFilter(filename string, ttl time.Duration, buckets int, controller Controller) (*DiskTTLFilter, error) {
+	if buckets < 2 || buckets > math.MaxUint8 || ttl < time.Duration(buckets-1) {
+		return nil, fmt.Errorf("%w: ttl %v of %v buckets, which should be 2 to 255", InvalidTTLErr, ttl, buckets)
+	}
+	if controller.WriteBuffer > 0 || controller.DiskFullPolicy == DiskFullPolicyBuffer || controller.AdaptiveSlots > 0 ||
+		controller.Journal || controller.PinnedRange.Length > 0 {
+		return nil, fmt.Errorf("%w: WriteBuffer, DiskFullPolicyBuffer, AdaptiveSlots, Journal and PinnedRange of ttl filters", UnsupportedErr)
+	}
+	if getParam := controller.GetParam; getParam != nil {
+		controller.GetParam = func(b []byte) (FilterParam, []byte) {
+			param, metadata := getParam(b)
+			if param.Bits > 0 {
+				param.Bits = ttlBits(param.Bits, buckets)
+			}
+			return param, metadata
+		}
+	}
+	disk, err := open(filename, controller, variantTTL)
+	if err != nil {
+		return nil, err
+	}
+	t := &DiskTTLFilter{disk: disk, buckets: int64(buckets), span: ttl / time.Duration(buckets-1)}
+	if err = t.init(); err != nil {
+		_ = disk.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// init records the buckets in the header of a new file, or checks them against the header, and reads the epochs.
+func (t *DiskTTLFilter) init() error {
+	f := t.disk
+	f.file.mu.Lock()
+	defer f.file.mu.Unlock()
+	if f.header.Buckets == 0 {
+		// new file
+		if f.readOnly {
+			return fmt.Errorf("%w: the buckets are not recorded in the file", MissingParamErr)
+		}
+		if err := f.writeSignedLocked(func(image []byte) {
+			h := image[LenOfMetadataSize+int(f.controller.MetadataSize):]
+			h[headerBucketsOffset] = uint8(t.buckets)
+			binary.LittleEndian.PutUint64(h[headerSpanOffset:], uint64(t.span))
+		}); err != nil {
+			return err
+		}
+		f.header.Buckets, f.header.BucketSpan = uint8(t.buckets), t.span
+		f.file.modified = true
+	} else if int64(f.header.Buckets) != t.buckets || f.header.BucketSpan != t.span {
+		return fmt.Errorf("%w: the file is created with %v buckets of %v, which are different from %v buckets of %v", InconsistentParamErr, f.header.Buckets, f.header.BucketSpan, t.buckets, t.span)
+	}
+	t.bucketBytes = int64(f.param.Bits/8)/t.buckets - ttlEpochSize
+	b := make([]byte, t.buckets*ttlEpochSize)
+	if _, err := f.file.rw.ReadAt(b, f.fileOffset(0)); err != nil {
+		return err
+	}
+	t.epochs = make([]int64, t.buckets)
+	for i := range t.epochs {
+		t.epochs[i] = int64(binary.LittleEndian.Uint64(b[i*ttlEpochSize:]))
+	}
+	return nil
+}
+
+// TTL returns the time an entry is reported for at least.
+func (t *DiskTTLFilter) TTL() time.Duration {
+	return t.span * time.Duration(t.buckets-1)
+}
+
+func (t *DiskTTLFilter) FilterParam() FilterParam {
+	return t.disk.FilterParam()
+}
+
+// Header returns the header of the filter file.
+func (t *DiskTTLFilter) Header() Header {
+	return t.disk.Header()
+}
+
+// Close should be invoked if the filter is not needed anymore
+func (t *DiskTTLFilter) Close() error {
+	return t.disk.Close()
+}
+
+// Exist returns if an entry is added within the ttl.
+func (t *DiskTTLFilter) Exist(b []byte) bool {
+	exist, _ := t.ExistErr(b)
+	return exist
+}
+
+// ExistErr is like Exist, but returns the error if the filter failed to be read,
+// instead of reporting the entry as not in.
+func (t *DiskTTLFilter) ExistErr(b []byte) (exist bool, err error) {
+	f := t.disk
+	if !f.acquire() {
+		return false, ClosedErr
+	}
+	defer f.release()
+	h := f.Hash(b)
+	var batch uint64
+	f.phase("io", func() {
+		f.rlock()
+		defer f.file.mu.RUnlock()
+		// the expired buckets are ignored until an add clears them
+		live := t.liveLocked(t.epoch())
+		var vals map[int64]byte
+		if vals, batch, err = f.readBatchLocked(t.positions(h, live)); err != nil {
+			return
+		}
+		_, exist = t.newestIn(vals, h, live)
+	})
+	if err != nil {
+		return false, err
+	}
+	if exist && batch > 0 {
+		// do not report an entry before its bits are durable, nor the one whose bits failed to be synced
+		exist = f.commit.wait(batch) == nil
+	}
+	f.countLookup(exist)
+	return exist, nil
+}
+
+// ExpiresAt returns the time by which an entry added within the ttl is no longer reported, which counts from its last add,
+// or false if it is not in the filter.
+func (t *DiskTTLFilter) ExpiresAt(b []byte) (expires time.Time, exist bool, err error) {
+	f := t.disk
+	if !f.acquire() {
+		return time.Time{}, false, ClosedErr
+	}
+	defer f.release()
+	h := f.Hash(b)
+	var newest int64
+	f.phase("io", func() {
+		f.rlock()
+		defer f.file.mu.RUnlock()
+		live := t.liveLocked(t.epoch())
+		var vals map[int64]byte
+		if vals, _, err = f.readBatchLocked(t.positions(h, live)); err != nil {
+			return
+		}
+		newest, exist = t.newestIn(vals, h, live)
+	})
+	if err != nil || !exist {
+		return time.Time{}, false, err
+	}
+	return time.Unix(0, (newest+t.buckets)*int64(t.span)), true, nil
+}
+
+// ExistOrAdd returns whether the entry is added within the ttl, and adds it if it is not,
+// so the ttl of an entry counts from its first add.
+func (t *DiskTTLFilter) ExistOrAdd(b []byte) (exist bool) {
+	exist, _ = t.ExistOrAddErr(b)
+	return exist
+}
+
+// ExistOrAddErr is like ExistOrAdd, but returns the error if the entry failed to be added.
+func (t *DiskTTLFilter) ExistOrAddErr(b []byte) (exist bool, err error) {
+	return t.update(t.disk.Hash(b), func(exist bool) bool { return !exist })
+}
+
+// Add adds an entry to the bucket of the current time, even if it is in the filter, which restarts its ttl.
+func (t *DiskTTLFilter) Add(b []byte) error {
+	_, err := t.update(t.disk.Hash(b), func(exist bool) bool { return true })
+	return err
+}
+
+// update adds an entry to the current bucket if add(exist), and returns whether the entry was in the filter.
+func (t *DiskTTLFilter) update(h KeyHash, add func(exist bool) bool) (exist bool, err error) {
+	f := t.disk
+	if !f.acquire() {
+		return false, ClosedErr
+	}
+	defer f.release()
+	var batch uint64
+	var added bool
+	f.phase("io", func() {
+		f.lock()
+		defer f.file.mu.Unlock()
+		e := t.epoch()
+		if err = t.rolloverLocked(e); err != nil {
+			return
+		}
+		live := t.liveLocked(e)
+		var vals map[int64]byte
+		if vals, batch, err = f.readBatchLocked(t.positions(h, live)); err != nil {
+			batch = 0
+			return
+		}
+		_, exist = t.newestIn(vals, h, live)
+		if added = add(exist); !added {
+			return
+		}
+		changed := make(map[int64]byte)
+		for _, offset := range t.offsets(h) {
+			pos := t.fileOffset(e%t.buckets, offset)
+			if val := vals[pos] | 1<<(offset%8); val != vals[pos] {
+				vals[pos] = val
+				changed[pos] = val
+			}
+		}
+		batch, err = f.writeChangedLocked(changed, 1, batch)
+	})
+	if batch > 0 && (exist || added) {
+		// do not report an entry or return before its bits are durable
+		if e := f.commit.wait(batch); err == nil {
+			err = e
+		}
+	}
+	return exist, err
+}
+
+// epoch returns the epoch of the current time.
+func (t *DiskTTLFilter) epoch() int64 {
+	return t.disk.now().UnixNano() / int64(t.span)
+}
+
+// liveLocked returns the buckets holding the entries of the epochs within the ttl of epoch e.
+func (t *DiskTTLFilter) liveLocked(e int64) []int64 {
+	var live []int64
+	for i, epoch := range t.epochs {
+		if epoch != 0 && e-epoch < t.buckets {
+			live = append(live, int64(i))
+		}
+	}
+	return live
+}
+
+// rolloverLocked clears the bucket of epoch e if it holds the entries of an older epoch.
+func (t *DiskTTLFilter) rolloverLocked(e int64) error {
+	f := t.disk
+	i := e % t.buckets
+	if t.epochs[i] >= e {
+		// the epoch of a clock set back is not cleared, whose entries expire with the newer one
+		return nil
+	}
+	if f.readOnly {
+		return f.readOnlyErr()
+	}
+	zeros := make([]byte, ttlZeroChunk)
+	start := t.fileOffset(i, 0)
+	for n := int64(0); n < t.bucketBytes; n += ttlZeroChunk {
+		chunk := zeros
+		if t.bucketBytes-n < ttlZeroChunk {
+			chunk = zeros[:t.bucketBytes-n]
+		}
+		if err := f.writeRangeLocked(chunk, start+n); err != nil {
+			return f.onWriteErrorLocked(err, nil)
+		}
+	}
+	var b [ttlEpochSize]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(e))
+	if err := f.writeRangeLocked(b[:], f.fileOffset(i*ttlEpochSize)); err != nil {
+		return f.onWriteErrorLocked(err, nil)
+	}
+	t.epochs[i] = e
+	f.noteIO(ioOpWrite, nil)
+	f.file.modified = true
+	if f.file.fsync == FsyncModeAlways {
+		return f.syncFile()
+	}
+	return nil
+}
+
+// offsets returns the bit offsets of the probes of an entry in a bucket.
+func (t *DiskTTLFilter) offsets(h KeyHash) []uint64 {
+	f := t.disk
+	bucketBits := uint64(t.bucketBytes) * 8
+	if f.header.Salted() {
+		h.X, h.Y = saltHash(h.X, h.Y, f.header.Salt)
+	}
+	offsets := make([]uint64, f.param.Slots)
+	for i := range offsets {
+		x := h.X + uint64(i)*h.Y
+		if f.header.FastRange() {
+			offsets[i], _ = bits.Mul64(x, bucketBits)
+		} else {
+			offsets[i] = x % bucketBits
+		}
+	}
+	return offsets
+}
+
+// fileOffset returns the file offset of the byte containing the bit offset of bucket i.
+func (t *DiskTTLFilter) fileOffset(i int64, offset uint64) int64 {
+	return t.disk.fileOffset(t.buckets*ttlEpochSize + i*t.bucketBytes + int64(offset/8))
+}
+
+// positions returns the sorted unique file offsets of the probes of an entry in the buckets.
+func (t *DiskTTLFilter) positions(h KeyHash, buckets []int64) []int64 {
+	offsets := t.offsets(h)
+	positions := make([]int64, 0, len(buckets)*len(offsets))
+	for _, i := range buckets {
+		for _, offset := range offsets {
+			positions = append(positions, t.fileOffset(i, offset))
+		}
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i] < positions[j]
+	})
+	return uniquePositions(positions)
+}
+
+// newestIn returns the newest epoch of the buckets where all probes of an entry are set, or false if none.
+func (t *DiskTTLFilter) newestIn(vals map[int64]byte, h KeyHash, buckets []int64) (newest int64, ok bool) {
+	offsets := t.offsets(h)
+	for _, i := range buckets {
+		exist := true
+		for _, offset := range offsets {
+			if vals[t.fileOffset(i, offset)]&(1<<(offset%8)) == 0 {
+				exist = false
+				break
+			}
+		}
+		if exist && (!ok || t.epochs[i] > newest) {
+			newest, ok = t.epochs[i], true
+		}
+	}
+	return newest, ok
+}
+
+// writeRangeLocked writes b at pos, keeping the pinned copy, the checksums and the deltas up to date.
+func (f *DiskFilter) writeRangeLocked(b []byte, pos int64) error {
+	if _, err := f.file.rw.WriteAt(b, pos); err != nil {
+		return err
+	}
+	for i, val := range b {
+		if err := f.wroteLocked(val, pos+int64(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
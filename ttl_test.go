@@ -0,0 +1,118 @@
+package disk_bloom
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newTestTTLFilter(t testing.TB, clock Clock) *DiskTTLFilter {
+	bf, err := NewTTLFilter("testfile", 10*time.Minute, 6, Controller{
+		GetParam: func([]byte) (FilterParam, []byte) {
+			return FilterParam{Slots: 14, Bits: 1e4 * 20, Hash: doubleFNV}, nil
+		},
+		Clock: clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		bf.Close()
+		os.Remove("testfile")
+	})
+	return bf
+}
+
+func TestDiskTTLFilter(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	bf := newTestTTLFilter(t, clock)
+	if bf.TTL() != 10*time.Minute || bf.Header().Buckets != 6 || bf.Header().BucketSpan != 2*time.Minute {
+		t.Fatalf("Unexpected ttl %v of header %+v", bf.TTL(), bf.Header())
+	}
+	for i := 0; i < 1000; i++ {
+		if bf.ExistOrAdd([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should not exist in filter", i)
+		}
+	}
+	clock.Advance(5 * time.Minute)
+	if err := bf.Add([]byte("refreshed")); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(5 * time.Minute)
+	for i := 0; i < 1000; i++ {
+		if !bf.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should exist in filter within the ttl", i)
+		}
+	}
+	if !bf.ExistOrAdd([]byte("0")) {
+		t.Fatal("0 should exist in filter, which is not refreshed")
+	}
+	clock.Advance(2 * time.Minute)
+	for i := 0; i < 1000; i++ {
+		if bf.Exist([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%v should expire after the ttl and a bucket span", i)
+		}
+	}
+	if !bf.Exist([]byte("refreshed")) {
+		t.Fatal("refreshed should exist in filter")
+	}
+	if bf.ExistOrAdd([]byte("0")) {
+		t.Fatal("0 should be added again after it expires")
+	}
+	if !bf.Exist([]byte("0")) {
+		t.Fatal("0 should exist in filter")
+	}
+}
+
+func TestDiskTTLFilter_Reopen(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	bf := newTestTTLFilter(t, clock)
+	bf.ExistOrAdd([]byte("a"))
+	if err := bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	controller := Controller{Clock: clock, GetParam: func([]byte) (FilterParam, []byte) {
+		return FilterParam{Hash: doubleFNV}, nil
+	}}
+	if _, err := NewTTLFilter("testfile", time.Hour, 6, controller); !errors.Is(err, InconsistentParamErr) {
+		t.Fatalf("Should reject the different ttl, got %v", err)
+	}
+	reopened, err := NewTTLFilter("testfile", 10*time.Minute, 6, controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if !reopened.Exist([]byte("a")) || reopened.Describe().Variant != "ttl" {
+		t.Fatal("Should keep the entries and the variant")
+	}
+	if _, err = New("testfile", controller); !errors.Is(err, InvalidHeaderErr) {
+		t.Fatalf("Should not be opened as a classic filter, got %v", err)
+	}
+	if _, err = NewTTLFilter("testfile2", time.Minute, 1, Controller{}); !errors.Is(err, InvalidTTLErr) {
+		t.Fatalf("Should reject a bucket, got %v", err)
+	}
+}
+
+func TestDiskTTLFilter_ExpiresAt(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 1, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	bf := newTestTTLFilter(t, clock)
+	bf.ExistOrAdd([]byte("a"))
+	expires, exist, err := bf.ExpiresAt([]byte("a"))
+	if err != nil || !exist {
+		t.Fatal(exist, err)
+	}
+	if expires.Before(start.Add(bf.TTL())) || expires.After(start.Add(bf.TTL()+2*time.Minute)) {
+		t.Fatalf("Should expire after the ttl and within a bucket span, got %v", expires)
+	}
+	clock.Advance(expires.Sub(start) - time.Nanosecond)
+	if !bf.Exist([]byte("a")) {
+		t.Fatal("a should exist in filter before it expires")
+	}
+	clock.Advance(time.Nanosecond)
+	if _, exist, _ = bf.ExpiresAt([]byte("a")); exist || bf.Exist([]byte("a")) {
+		t.Fatal("a should expire")
+	}
+}
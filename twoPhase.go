@@ -0,0 +1,92 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"sync"
+)
+
+var InvalidTokenErr = fmt.Errorf("token is unknown or already committed or aborted")
+
+// AddToken is an add prepared by Prepare, which is settled by Commit or Abort.
+type AddToken struct {
+	id uint64
+	h  KeyHash
+}
+
+// preparedAdds are the adds prepared but not settled yet.
+type preparedAdds struct {
+	mu     sync.Mutex
+	next   uint64
+	tokens map[uint64]KeyHash
+	// keys are the entries of the tokens, which are regarded as existing by Prepare
+	keys map[KeyHash]bool
+}
+
+// Prepare is the first phase of an add for exactly-once pipelines: it returns whether the entry is in the filter,
+// and if not, a token to add it by Commit once the message is processed, or to give it up by Abort if the processing fails,
+// so that a key is not marked seen when its processing fails.
+// An entry prepared but not settled is regarded as existing by the other Prepares, so a message delivered twice
+// at once is processed once, and its redelivery after Abort is processed again.
+// The prepared adds are kept in memory, so they are given up when the filter is closed.
+func (f *DiskFilter) Prepare(b []byte) (token AddToken, existed bool) {
+	h := f.Hash(b)
+	p := &f.prepared
+	// the lookup is under the lock, so that an entry is either prepared or added when it is looked up
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.keys[h] || f.ExistHashed(h) {
+		return AddToken{}, true
+	}
+	if p.tokens == nil {
+		p.tokens = make(map[uint64]KeyHash)
+		p.keys = make(map[KeyHash]bool)
+	}
+	p.next++
+	p.tokens[p.next] = h
+	p.keys[h] = true
+	return AddToken{id: p.next, h: h}, false
+}
+
+// Commit adds the entry of the token prepared by Prepare, which is durable as an add in the Fsync mode.
+// It returns InvalidTokenErr if the token is settled, or the error of the add, in which case the token is kept
+// to be committed again or aborted.
+func (f *DiskFilter) Commit(token AddToken) error {
+	p := &f.prepared
+	p.mu.Lock()
+	_, ok := p.tokens[token.id]
+	p.mu.Unlock()
+	if !ok {
+		return InvalidTokenErr
+	}
+	if err := f.AddHashed(token.h); err != nil {
+		return err
+	}
+	// forgotten once added, so that Prepare sees either
+	return p.settle(token)
+}
+
+// Abort gives up the add of the token prepared by Prepare. It returns InvalidTokenErr if the token is settled.
+func (f *DiskFilter) Abort(token AddToken) error {
+	return f.prepared.settle(token)
+}
+
+// Prepared returns the number of adds prepared but not settled yet.
+func (f *DiskFilter) Prepared() int {
+	p := &f.prepared
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.tokens)
+}
+
+// settle forgets the token.
+func (p *preparedAdds) settle(token AddToken) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h, ok := p.tokens[token.id]
+	if !ok {
+		return InvalidTokenErr
+	}
+	delete(p.tokens, token.id)
+	delete(p.keys, h)
+	return nil
+}
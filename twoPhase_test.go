@@ -0,0 +1,48 @@
+package disk_bloom
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDiskFilter_Prepare(t *testing.T) {
+	bf := newTestFilter(t, Controller{})
+	token, existed := bf.Prepare([]byte("message"))
+	if existed {
+		t.Fatal("Should not exist before committed")
+	}
+	if bf.Exist([]byte("message")) {
+		t.Fatal("Should not add before committed")
+	}
+	// delivered twice at once
+	if _, existed = bf.Prepare([]byte("message")); !existed {
+		t.Fatal("Should regard the prepared entry as existing")
+	}
+	// the processing fails
+	if err := bf.Abort(token); err != nil {
+		t.Fatal(err)
+	}
+	if bf.Exist([]byte("message")) || bf.Prepared() != 0 {
+		t.Fatal("Should not add an aborted entry")
+	}
+	if err := bf.Commit(token); !errors.Is(err, InvalidTokenErr) {
+		t.Fatalf("Should be InvalidTokenErr, got %v", err)
+	}
+
+	// redelivered
+	if token, existed = bf.Prepare([]byte("message")); existed {
+		t.Fatal("Should process the redelivery of an aborted entry")
+	}
+	if err := bf.Commit(token); err != nil {
+		t.Fatal(err)
+	}
+	if !bf.Exist([]byte("message")) || bf.Prepared() != 0 {
+		t.Fatal("Should add a committed entry")
+	}
+	if _, existed = bf.Prepare([]byte("message")); !existed {
+		t.Fatal("Should exist once committed")
+	}
+	if err := bf.Abort(token); !errors.Is(err, InvalidTokenErr) {
+		t.Fatalf("Should be InvalidTokenErr, got %v", err)
+	}
+}
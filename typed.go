@@ -0,0 +1,65 @@
+package disk_bloom
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+// TypedFilter is a filter of keys of type T, which are encoded into entries by an encoder,
+// e.g. EncodeString, EncodeUint64, EncodeIP and EncodeUUID, on top of a DiskFilter, a FilterGroup or another Filter.
+// The encoder should be the same whenever the filter is opened, since the entries of the same key must be equal.
+type TypedFilter[T any] struct {
+	filter Filter
+	encode func(key T) []byte
+}
+
+// NewTyped wraps filter to take keys of type T encoded by encode. Closing the TypedFilter closes filter.
+func NewTyped[T any](filter Filter, encode func(key T) []byte) *TypedFilter[T] {
+	return &TypedFilter[T]{filter: filter, encode: encode}
+}
+
+// Filter returns the underlying filter.
+func (t *TypedFilter[T]) Filter() Filter {
+	return t.filter
+}
+
+// Exist returns if a key is in the filter
+func (t *TypedFilter[T]) Exist(key T) bool {
+	return t.filter.Exist(t.encode(key))
+}
+
+// ExistOrAdd returns whether the key was in the filter, and adds it to the filter if it was not in.
+func (t *TypedFilter[T]) ExistOrAdd(key T) bool {
+	return t.filter.ExistOrAdd(t.encode(key))
+}
+
+// Close should be invoked if the filter is not needed anymore
+func (t *TypedFilter[T]) Close() error {
+	return t.filter.Close()
+}
+
+// EncodeString encodes a string as its bytes.
+func EncodeString(key string) []byte {
+	return []byte(key)
+}
+
+// EncodeUint64 encodes an integer in big-endian (8 bytes).
+func EncodeUint64(key uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], key)
+	return b[:]
+}
+
+// EncodeIP encodes an IP address like ExistAddr: 4 bytes for IPv4 and 16 bytes for IPv6,
+// so an IPv4 address is the same key in either form of net.IP. An invalid address is encoded as it is.
+func EncodeIP(key net.IP) []byte {
+	if ip := key.To4(); ip != nil {
+		return ip
+	}
+	return key
+}
+
+// EncodeUUID encodes a UUID as its 16 bytes like ExistUUID.
+func EncodeUUID(key [16]byte) []byte {
+	return key[:]
+}
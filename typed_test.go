@@ -0,0 +1,48 @@
+package disk_bloom
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"os"
+	"testing"
+)
+
+func TestTypedFilter(t *testing.T) {
+	bf := newTestFilter(t, Controller{Fsync: FsyncModeNo})
+	ips := NewTyped(bf, EncodeIP)
+	if ips.ExistOrAdd(net.ParseIP("1.2.3.4")) {
+		t.Fatal("Should missing in filter but got true")
+	}
+	if !ips.Exist(net.IPv4(1, 2, 3, 4).To4()) || !bf.ExistAddr(netip.MustParseAddr("1.2.3.4")) {
+		t.Fatal("Should exist in filter in either form but got false")
+	}
+	ids := NewTyped(bf, EncodeUint64)
+	ids.ExistOrAdd(42)
+	if !ids.Exist(42) || ids.Exist(43) {
+		t.Fatal("Unexpected existence of the integers")
+	}
+	uuids := NewTyped(bf, EncodeUUID)
+	uuids.ExistOrAdd([16]byte{1, 2, 3})
+	if !bf.ExistUUID([16]byte{1, 2, 3}) {
+		t.Fatal("Should encode a UUID like ExistUUID")
+	}
+	if !bytes.Equal(EncodeUint64(1), []byte{0, 0, 0, 0, 0, 0, 0, 1}) {
+		t.Fatalf("Unexpected encoding %x", EncodeUint64(1))
+	}
+}
+
+func TestTypedFilter_Group(t *testing.T) {
+	os.Mkdir("testfile", os.ModePerm)
+	g, err := NewGroup("testfile/*", FsyncModeNo, 1e3, 1e-4, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll("testfile")
+	names := NewTyped(g, EncodeString)
+	defer names.Close()
+	names.ExistOrAdd("alice")
+	if !names.Exist("alice") || !g.Exist([]byte("alice")) || names.Exist("bob") {
+		t.Fatal("Unexpected existence of the names")
+	}
+}
@@ -0,0 +1,36 @@
+package disk_bloom
+
+import "fmt"
+
+// verifyRetries is the number of rewrites of a byte not reading back as written.
+const verifyRetries = 3
+
+var CorruptWriteErr = fmt.Errorf("written byte does not read back")
+
+// verifyLocked re-reads the byte written at pos, and rewrites it if it does not read back as val.
+// It returns CorruptWriteErr if it still mismatches after verifyRetries rewrites.
+func (f *DiskFilter) verifyLocked(val byte, pos int64) error {
+	rw := f.file.rw
+	if f.cache != nil {
+		// read the disk, not the page cached
+		rw = f.cache.storage
+	}
+	var b [1]byte
+	for i := 0; ; i++ {
+		if _, err := rw.ReadAt(b[:], pos); err != nil {
+			return err
+		}
+		if b[0] == val {
+			return nil
+		}
+		if f.controller.OnCorruptWrite != nil {
+			f.controller.OnCorruptWrite(pos, val, b[0])
+		}
+		if i == verifyRetries {
+			return fmt.Errorf("%w: offset %v, written %08b, read %08b", CorruptWriteErr, pos, val, b[0])
+		}
+		if _, err := rw.WriteAt([]byte{val}, pos); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package disk_bloom
+
+import (
+	"errors"
+	"testing"
+)
+
+// corruptStorage flips the lowest bit of the first n writes.
+type corruptStorage struct {
+	storage
+	n int
+}
+
+func (s *corruptStorage) WriteAt(b []byte, offset int64) (int, error) {
+	if s.n > 0 {
+		s.n--
+		corrupt := append([]byte{}, b...)
+		corrupt[0] ^= 1
+		return s.storage.WriteAt(corrupt, offset)
+	}
+	return s.storage.WriteAt(b, offset)
+}
+
+func TestDiskFilter_VerifyWrites(t *testing.T) {
+	var mismatches int
+	bf := newTestFilter(t, Controller{
+		VerifyWrites: true,
+		OnCorruptWrite: func(offset int64, written, read byte) {
+			mismatches++
+			if written^read != 1 {
+				t.Errorf("Unexpected mismatch at %v: written %08b, read %08b", offset, written, read)
+			}
+		},
+	})
+	bf.file.rw = &corruptStorage{storage: bf.file.rw, n: 2}
+	buf := []byte("testing")
+	if exist, err := bf.ExistOrAddErr(buf); exist || err != nil {
+		t.Fatalf("Should be added after rewrites, got %v %v", exist, err)
+	}
+	if mismatches != 2 {
+		t.Fatalf("Should find 2 mismatches, got %v", mismatches)
+	}
+	if !bf.Exist(buf) {
+		t.Fatal("Should exist in filter but got false")
+	}
+
+	bf.file.rw = &corruptStorage{storage: bf.file.rw, n: 1 << 10}
+	if _, err := bf.ExistOrAddErr([]byte("corrupt")); !errors.Is(err, CorruptWriteErr) {
+		t.Fatalf("Should fail with CorruptWriteErr, got %v", err)
+	}
+}
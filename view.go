@@ -0,0 +1,59 @@
+package disk_bloom
+
+import "fmt"
+
+// ViewPage returns the page i of the bloom filter, its bytes [i*4096, (i+1)*4096) clipped to Size,
+// without copying it if it is in memory, e.g. for density scans or custom exports at memory speed:
+// it is the mapping of Mmap, the range pinned by Pin, or the page of the BlockCache, which reads it on a miss.
+// Otherwise it is read into a new buffer.
+//
+// The view must not be modified, and is valid until release is invoked, which must be invoked once.
+// The adds wait meanwhile, so release it promptly. The adds buffered by WriteBuffer are not in the view
+// unless it is read, see Flush.
+func (f *DiskFilter) ViewPage(i int64) (view []byte, release func(), err error) {
+	size := int64(f.Size())
+	start, end := i*pageSize, (i+1)*pageSize
+	if i < 0 || start >= size {
+		return nil, nil, fmt.Errorf("%w: page %v is beyond the size %v", InvalidRangeErr, i, size)
+	}
+	if end > size {
+		end = size
+	}
+	if !f.acquire() {
+		return nil, nil, ClosedErr
+	}
+	f.rlock()
+	release = func() {
+		f.file.mu.RUnlock()
+		f.release()
+	}
+	if view, err = f.viewLocked(f.fileOffset(start), f.fileOffset(end)); err != nil {
+		release()
+		return nil, nil, err
+	}
+	return view, release, nil
+}
+
+// viewLocked returns the bytes of the file [from, to) in memory, or reads them.
+func (f *DiskFilter) viewLocked(from, to int64) ([]byte, error) {
+	if m := f.mapped; m != nil {
+		return m.mem[from-m.start : to-m.start : to-m.start], nil
+	}
+	if h := f.hybrid; h != nil {
+		return h.mem[from-h.start : to-h.start : to-h.start], nil
+	}
+	if p := f.pinned; p.contains(from) && p.contains(to-1) {
+		return p.buf[from-p.start : to-p.start : to-p.start], nil
+	}
+	// the pages of the cache are the pages of the file
+	if c := f.cache; c != nil && from/pageSize == (to-1)/pageSize {
+		if b, err := c.view(from, to); err == nil {
+			return b, nil
+		}
+	}
+	b := make([]byte, to-from)
+	if err := f.readBloomLocked(b, from-f.bloomStart); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,72 @@
+package disk_bloom
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDiskFilter_ViewPage(t *testing.T) {
+	for name, controller := range map[string]Controller{
+		"file":  {},
+		"mmap":  {Mmap: true},
+		"cache": {BlockCache: 1 << 20},
+	} {
+		t.Run(name, func(t *testing.T) {
+			bf := newTestFilter(t, controller)
+			for i := 0; i < 1000; i++ {
+				bf.ExistOrAdd([]byte(strconv.Itoa(i)))
+			}
+			b, _, err := bf.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			b = b[:bf.Size()]
+			var pages int64
+			for ; pages*pageSize < int64(len(b)); pages++ {
+				view, release, err := bf.ViewPage(pages)
+				if err != nil {
+					t.Fatal(err)
+				}
+				end := (pages + 1) * pageSize
+				if end > int64(len(b)) {
+					end = int64(len(b))
+				}
+				if !bytes.Equal(view, b[pages*pageSize:end]) {
+					release()
+					t.Fatalf("Page %v should be the bytes of the filter", pages)
+				}
+				release()
+			}
+			if _, _, err = bf.ViewPage(pages); !errors.Is(err, InvalidRangeErr) {
+				t.Fatalf("Should be InvalidRangeErr, got %v", err)
+			}
+			if name == "cache" {
+				before := bf.BlockCacheStats()
+				_, release, _ := bf.ViewPage(0)
+				release()
+				if after := bf.BlockCacheStats(); after.Misses != before.Misses || after.Hits != before.Hits+1 {
+					t.Fatalf("Should view the cached page, got %+v after %+v", after, before)
+				}
+			}
+			// the adds wait for the views
+			_, release, _ := bf.ViewPage(0)
+			added := make(chan struct{})
+			go func() {
+				bf.ExistOrAdd([]byte("new"))
+				close(added)
+			}()
+			select {
+			case <-added:
+				t.Fatal("Should wait for the view to be released")
+			case <-time.After(20 * time.Millisecond):
+			}
+			release()
+			<-added
+		})
+	}
+	os.Remove("testfile")
+}
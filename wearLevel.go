@@ -0,0 +1,98 @@
+package disk_bloom
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"math/rand"
+	"sync/atomic"
+)
+
+// The header of FlagWearLeveled keeps the times and the bits set, persisted every second while the file is modified,
+// in rotating slots of the reserved region instead of their fixed offsets, where a slot is
+//
+// | seq(4) | CRC32C of the rest(4) | last add(8) | last sync(8) | bits set(8) |
+//
+// and the valid slot of the greatest seq is the latest. The fixed offsets keep the values of the creation.
+const (
+	headerTimesOffset   = headerReservedOffset
+	headerTimesSlotSize = 32
+	headerTimesSlots    = (HeaderSize - headerTimesOffset) / headerTimesSlotSize
+)
+
+// WearLeveled returns whether the times and the bits set rotate over the slots of the header, see Controller.WearLeveling.
+func (h Header) WearLeveled() bool {
+	return h.Flags&FlagWearLeveled != 0
+}
+
+// encodeTimesSlot encodes the slot of seq into b.
+func encodeTimesSlot(b []byte, seq uint32, lastAdd, lastSync int64, setBits uint64) {
+	binary.LittleEndian.PutUint32(b, seq)
+	binary.LittleEndian.PutUint64(b[8:], uint64(lastAdd))
+	binary.LittleEndian.PutUint64(b[16:], uint64(lastSync))
+	binary.LittleEndian.PutUint64(b[24:], setBits)
+	crc := crc32.Update(crc32.Checksum(b[:4], castagnoli), castagnoli, b[8:headerTimesSlotSize])
+	binary.LittleEndian.PutUint32(b[4:], crc)
+}
+
+// putTimesSlot writes the times and the bits set of h into the slot of h.timesSeq of the encoded header b.
+func (h Header) putTimesSlot(b []byte) {
+	slot := b[headerTimesOffset+int(h.timesSeq%headerTimesSlots)*headerTimesSlotSize:]
+	encodeTimesSlot(slot, h.timesSeq, timeNanos(h.LastAdd), timeNanos(h.LastSync), h.setBits)
+}
+
+// parseTimesSlots restores the times and the bits set of h from the latest valid slot of the encoded header b.
+// A torn slot is skipped, and the fixed offsets are kept if no slot is valid.
+func (h *Header) parseTimesSlots(b []byte) {
+	found := false
+	for i := 0; i < headerTimesSlots; i++ {
+		slot := b[headerTimesOffset+i*headerTimesSlotSize:][:headerTimesSlotSize]
+		crc := crc32.Update(crc32.Checksum(slot[:4], castagnoli), castagnoli, slot[8:])
+		if binary.LittleEndian.Uint32(slot[4:]) != crc {
+			continue
+		}
+		seq := binary.LittleEndian.Uint32(slot)
+		if found && seq <= h.timesSeq {
+			continue
+		}
+		found = true
+		h.timesSeq = seq
+		h.LastAdd = parseTime(slot[8:])
+		h.LastSync = parseTime(slot[16:])
+		h.setBits = binary.LittleEndian.Uint64(slot[24:])
+	}
+}
+
+// persistTimesSlotLocked writes the times and the bits set into the next slot of the header.
+func (f *DiskFilter) persistTimesSlotLocked(start int64) error {
+	seq := f.header.timesSeq + 1
+	var b [headerTimesSlotSize]byte
+	lastAdd, lastSync := f.lastTimes()
+	encodeTimesSlot(b[:], seq, timeNanos(lastAdd), timeNanos(lastSync), atomic.LoadUint64(&f.setBits))
+	if _, err := (retryStorage{f.file.f}).WriteAt(b[:], start+headerTimesOffset+int64(seq%headerTimesSlots)*headerTimesSlotSize); err != nil {
+		return err
+	}
+	f.header.timesSeq = seq
+	return nil
+}
+
+// addSetBitsSlot changes the bits set of the header of the file by set, writing them into the next slot.
+func addSetBitsSlot(raw retryStorage, metadataSize uint16, set int64) error {
+	h, err := readHeader(raw, metadataSize)
+	if err != nil {
+		return err
+	}
+	h.timesSeq++
+	var b [headerTimesSlotSize]byte
+	encodeTimesSlot(b[:], h.timesSeq, timeNanos(h.LastAdd), timeNanos(h.LastSync), uint64(int64(h.setBits)+set))
+	_, err = raw.WriteAt(b[:], LenOfMetadataSize+int64(metadataSize)+headerTimesOffset+int64(h.timesSeq%headerTimesSlots)*headerTimesSlotSize)
+	return err
+}
+
+// firstWrite returns the index of the first of the n bytes changed by an add to be written,
+// which is random if the file is wear-leveled, so that the writes do not always start at the lowest offset.
+func (f *DiskFilter) firstWrite(n int) int {
+	if !f.header.WearLeveled() || n < 2 {
+		return 0
+	}
+	return rand.Intn(n)
+}
@@ -0,0 +1,115 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiskFilter_WearLeveling(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "filter")
+	controller := Controller{WearLeveling: true, AdaptiveSlots: 2, GetParam: func(metadata []byte) (FilterParam, []byte) {
+		slots, bits := OptimalParam(1e4, 1e-3)
+		return FilterParam{Slots: slots, Bits: bits, Hash: doubleFNV}, nil
+	}}
+	bf, err := New(filename, controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bf.Header().WearLeveled() {
+		t.Fatal("Should record FlagWearLeveled")
+	}
+	for i := 0; i < 2*headerTimesSlots; i++ {
+		if bf.ExistOrAdd([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%v should be added", i)
+		}
+		bf.file.mu.Lock()
+		err = bf.persistHeaderLocked()
+		bf.file.mu.Unlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	setBits, header := bf.setBits, bf.Header()
+	if err = bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// every slot is written, and the fixed offsets keep the values of the creation
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := raw[LenOfMetadataSize:]
+	if v := parseTime(b[headerLastAddOffset:]); !v.IsZero() {
+		t.Fatalf("Should not rewrite the fixed offset, got %v", v)
+	}
+	for i := 0; i < headerTimesSlots; i++ {
+		if slot := b[headerTimesOffset+i*headerTimesSlotSize:][:headerTimesSlotSize]; string(slot) == string(make([]byte, headerTimesSlotSize)) {
+			t.Fatalf("Should rotate over slot %v", i)
+		}
+	}
+
+	bf, err = New(filename, controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bf.setBits != setBits || !bf.Header().LastAdd.Equal(header.LastAdd) || bf.header.timesSeq != header.timesSeq+1 {
+		t.Fatalf("Should restore the latest slot, got %v bits set and %+v", bf.setBits, bf.Header())
+	}
+	for i := 0; i < 2*headerTimesSlots; i++ {
+		if !bf.Exist([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%v should exist", i)
+		}
+	}
+	if err = bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	h, err := Inspect(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq := h.timesSeq
+
+	// a torn slot falls back to the one before
+	f, err := os.OpenFile(filename, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset := LenOfMetadataSize + headerTimesOffset + int64(seq%headerTimesSlots)*headerTimesSlotSize + 8
+	if _, err = f.WriteAt([]byte{0xff}, offset); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+	if h, err = Inspect(filename); err != nil {
+		t.Fatal(err)
+	}
+	if h.timesSeq != seq-1 {
+		t.Fatalf("Should skip the torn slot %v, got %v", seq, h.timesSeq)
+	}
+}
+
+func TestDiskFilter_WearLevelingFirstWrite(t *testing.T) {
+	bf := newTestFilter(t, Controller{WearLeveling: true})
+	random := false
+	for i := 0; i < 100; i++ {
+		if bf.firstWrite(8) != 0 {
+			random = true
+		}
+	}
+	if !random {
+		t.Fatal("Should start the writes at a random probe")
+	}
+	for i := 0; i < 1000; i++ {
+		bf.ExistOrAdd([]byte(fmt.Sprint(i)))
+	}
+	for i := 0; i < 1000; i++ {
+		if !bf.Exist([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%v should exist", i)
+		}
+	}
+	if (&DiskFilter{}).firstWrite(8) != 0 {
+		t.Fatal("Should write in order without WearLeveling")
+	}
+}
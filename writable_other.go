@@ -0,0 +1,5 @@
+//go:build !wasm || diskbloom_writable
+
+package disk_bloom
+
+const writable = true
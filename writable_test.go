@@ -0,0 +1,48 @@
+package disk_bloom
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWasmBuild(t *testing.T) {
+	if testing.Short() || runtime.GOARCH == "wasm" {
+		t.Skip("cross compiling")
+	}
+	gobin := filepath.Join(runtime.GOROOT(), "bin", "go")
+	for _, goos := range []string{"js", "wasip1"} {
+		for _, tags := range []string{"", "diskbloom_writable"} {
+			cmd := exec.Command(gobin, "build", "-tags="+tags, ".", "./format")
+			cmd.Env = append(os.Environ(), "GOOS="+goos, "GOARCH=wasm")
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("the package should compile for %v/wasm with tags %q: %v\n%s", goos, tags, err, out)
+			}
+		}
+	}
+}
+
+func TestWritable(t *testing.T) {
+	c, err := Capabilities(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Writable && runtime.GOARCH != "wasm" {
+		t.Fatal("only the WebAssembly builds should be read-only")
+	}
+	_, err = New(filepath.Join(t.TempDir(), "filter"), Controller{
+		Fsync: FsyncModeNo,
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			return FilterParam{Slots: 3, Bits: 1024, HashKind: HashKindXXHash64}, nil
+		},
+	})
+	if writable && err != nil {
+		t.Fatal(err)
+	}
+	if !writable && !errors.Is(err, UnsupportedErr) {
+		t.Fatalf("writing should be unsupported, got %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !diskbloom_writable
+
+package disk_bloom
+
+// writable is whether the filters can be opened for writing. The WebAssembly builds, e.g. edge functions querying
+// the published filters, only read them unless built with the diskbloom_writable tag, since the runtimes may have
+// no durable fsync, no file locks and no threads for the background syncs.
+const writable = false
@@ -0,0 +1,47 @@
+package disk_bloom
+
+import "time"
+
+// bufferLocked keeps the bytes changed by an add in memory until they are flushed, see Controller.WriteBuffer.
+// They are visible to lookups like the bytes buffered by DiskFullPolicyBuffer.
+func (f *DiskFilter) bufferLocked(changed map[int64]byte) error {
+	for pos, val := range changed {
+		if f.addPendingLocked(pos, val) {
+			continue
+		}
+		// Controller.MemoryBudget is exhausted, so the buffer is flushed to make room
+		_ = f.flushPendingLocked()
+		if !f.addPendingLocked(pos, val) {
+			if err := f.writeByteLocked(val, pos); err != nil {
+				return err
+			}
+			f.file.modified = true
+		}
+	}
+	f.buffered++
+	if ops := f.controller.WriteBufferOps; ops > 0 && f.buffered >= ops {
+		_ = f.flushPendingLocked()
+	}
+	return nil
+}
+
+func (f *DiskFilter) flushEvery(t *task) {
+	ticker := time.NewTicker(f.controller.WriteBuffer)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done():
+			return
+		case <-ticker.C:
+		}
+		if !f.acquire() {
+			return
+		}
+		f.file.mu.Lock()
+		if len(f.pending) > 0 {
+			f.backgroundError(t.report(f.flushPendingLocked()))
+		}
+		f.file.mu.Unlock()
+		f.release()
+	}
+}
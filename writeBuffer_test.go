@@ -0,0 +1,105 @@
+package disk_bloom
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDiskFilter_WriteBuffer(t *testing.T) {
+	bf := newTestFilter(t, Controller{
+		Fsync:          FsyncModeNo,
+		WriteBuffer:    time.Hour,
+		WriteBufferOps: 100,
+	})
+	for i := 0; i < 50; i++ {
+		bf.ExistOrAdd([]byte(fmt.Sprint(i)))
+	}
+	if len(bf.pending) == 0 {
+		t.Fatal("adds should be buffered")
+	}
+	for i := 0; i < 50; i++ {
+		if !bf.Exist([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%v should exist before flushed", i)
+		}
+	}
+	for i := 50; i < 100; i++ {
+		if err := bf.Add([]byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(bf.pending) != 0 {
+		t.Fatalf("adds should be flushed after %v adds, got %v bytes buffered", 100, len(bf.pending))
+	}
+	bf.ExistOrAddBatch([][]byte{[]byte("100"), []byte("101")})
+	if err := bf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	bf = newTestFilter(t, Controller{Fsync: FsyncModeNo})
+	for i := 0; i < 102; i++ {
+		if !bf.Exist([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%v should be written on Close", i)
+		}
+	}
+}
+
+func TestDiskFilter_Flush(t *testing.T) {
+	bf := newTestFilter(t, Controller{
+		Fsync:       FsyncModeNo,
+		WriteBuffer: time.Hour,
+	})
+	bf.ExistOrAdd([]byte("testing"))
+	if err := bf.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(bf.pending) != 0 {
+		t.Fatal("adds should be flushed")
+	}
+	if bf.ExistOrAdd([]byte("testing")) != true {
+		t.Fatal("Should exist after flushed")
+	}
+}
+
+func TestDiskFilter_ReadYourWrites(t *testing.T) {
+	// the writes fail with ENOSPC once the filter is opened, so the adds only reach the memory
+	diskFull := NewFaultInjector(1)
+	for _, tc := range []struct {
+		name       string
+		controller Controller
+	}{
+		{"WriteBuffer", Controller{WriteBuffer: time.Millisecond}},
+		{"WriteBuffer+BlockCache", Controller{WriteBuffer: time.Millisecond, BlockCache: 4 * pageSize}},
+		{"WriteBuffer+Pin", Controller{WriteBuffer: time.Hour, PinnedRange: Range{Length: 8 << 10}}},
+		{"WriteBuffer+Mmap", Controller{WriteBuffer: time.Hour, Mmap: true}},
+		{"WriteBuffer+MemoryBudget", Controller{WriteBuffer: time.Hour, MemoryBudget: NewMemoryBudget(10*pendingEntrySize, nil)}},
+		{"DiskFullPolicyBuffer+BlockCache", Controller{DiskFullPolicy: DiskFullPolicyBuffer, BlockCache: 4 * pageSize, FaultInjector: diskFull}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.controller.Fsync = FsyncModeNo
+			bf := newTestFilter(t, tc.controller)
+			diskFull.Set(Faults{WriteErrRate: 1, Err: syscall.ENOSPC})
+			defer diskFull.Set(Faults{})
+			var wg sync.WaitGroup
+			for g := 0; g < 4; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					for i := 0; i < 200; i++ {
+						key := []byte(fmt.Sprint(g, "-", i))
+						if _, err := bf.ExistOrAddErr(key); err != nil {
+							t.Error(err)
+							return
+						}
+						if !bf.Exist(key) || !bf.ExistBatch([][]byte{key})[0] || !bf.ExplainExist(key).Exist {
+							t.Errorf("%s should be seen once added", key)
+							return
+						}
+					}
+				}(g)
+			}
+			wg.Wait()
+		})
+	}
+}
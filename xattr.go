@@ -0,0 +1,63 @@
+package disk_bloom
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// The extended attributes written on new files by Controller.Xattrs, so that audit tools classify the files
+// by ReadXattrs or getfattr without opening them.
+const (
+	// XattrCreator is the hostname creating the file and the version of this package
+	XattrCreator = "user.diskbloom.creator"
+	// XattrPurpose is Controller.Tag
+	XattrPurpose = "user.diskbloom.purpose"
+	// XattrParams are the parameters of the filter, e.g. "variant=classic slots=14 bits=191701 hash=fnv"
+	XattrParams = "user.diskbloom.params"
+	// XattrDigest is the SHA-256 of XattrParams in hex, to compare the parameters of the files at a glance
+	XattrDigest = "user.diskbloom.digest"
+)
+
+// xattrNames are the names of the extended attributes in the order they are written.
+var xattrNames = []string{XattrCreator, XattrPurpose, XattrParams, XattrDigest}
+
+// headerXattrs returns the extended attributes of a file of the header.
+func headerXattrs(header Header) map[string]string {
+	params := fmt.Sprintf("variant=%v slots=%v bits=%v hash=%v", header.variant(), header.Slots, header.Bits, header.HashKind)
+	digest := sha256.Sum256([]byte(params))
+	return map[string]string{
+		XattrCreator: fmt.Sprintf("%v %v", header.Hostname, header.LibraryVersion),
+		XattrPurpose: header.Tag,
+		XattrParams:  params,
+		XattrDigest:  hex.EncodeToString(digest[:]),
+	}
+}
+
+// writeXattrs writes the extended attributes of the header on the file.
+// It returns UnsupportedErr if the platform has no extended attributes, or the error of the filesystem.
+func writeXattrs(filename string, header Header) error {
+	attrs := headerXattrs(header)
+	for _, name := range xattrNames {
+		if err := setXattr(filename, name, attrs[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadXattrs reads the extended attributes written by Controller.Xattrs, without opening the filter.
+// The attributes missing on the file are omitted, and it returns UnsupportedErr if the platform has no extended attributes.
+func ReadXattrs(filename string) (map[string]string, error) {
+	attrs := make(map[string]string)
+	for _, name := range xattrNames {
+		value, ok, err := getXattr(filename, name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			attrs[name] = value
+		}
+	}
+	return attrs, nil
+}
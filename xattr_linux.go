@@ -0,0 +1,27 @@
+package disk_bloom
+
+import (
+	"syscall"
+)
+
+func setXattr(filename string, name string, value string) error {
+	return syscall.Setxattr(filename, name, []byte(value), 0)
+}
+
+// getXattr returns the attribute, or false if the file has no such attribute.
+func getXattr(filename string, name string) (string, bool, error) {
+	buf := make([]byte, 256)
+	for {
+		n, err := syscall.Getxattr(filename, name, buf)
+		switch err {
+		case nil:
+			return string(buf[:n]), true, nil
+		case syscall.ENODATA:
+			return "", false, nil
+		case syscall.ERANGE:
+			buf = make([]byte, 2*len(buf))
+		default:
+			return "", false, err
+		}
+	}
+}
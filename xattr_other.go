@@ -0,0 +1,12 @@
+//go:build !linux
+
+package disk_bloom
+
+// setXattr is only supported on Linux.
+func setXattr(filename string, name string, value string) error {
+	return UnsupportedErr
+}
+
+func getXattr(filename string, name string) (string, bool, error) {
+	return "", false, UnsupportedErr
+}
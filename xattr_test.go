@@ -0,0 +1,37 @@
+package disk_bloom
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDiskFilter_Xattrs(t *testing.T) {
+	bf := newTestFilter(t, Controller{Xattrs: true, Tag: "dedup"})
+	attrs, err := ReadXattrs("testfile")
+	if errors.Is(err, UnsupportedErr) {
+		t.Skip(err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	if len(attrs) == 0 {
+		t.Skip("extended attributes are not supported by the filesystem")
+	}
+	header := bf.Header()
+	if attrs[XattrPurpose] != "dedup" || !strings.HasPrefix(attrs[XattrCreator], header.Hostname) {
+		t.Fatalf("Unexpected attributes %v", attrs)
+	}
+	if attrs[XattrParams] != "variant=classic slots=13 bits=191701 hash=custom" || len(attrs[XattrDigest]) != 64 {
+		t.Fatalf("Unexpected parameters %v", attrs)
+	}
+	f, err := os.CreateTemp(".", "xattrs-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	if attrs, err = ReadXattrs(f.Name()); err != nil || len(attrs) != 0 {
+		t.Fatalf("Should have no attributes, got %v, %v", attrs, err)
+	}
+}
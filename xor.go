@@ -0,0 +1,262 @@
+package disk_bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"os"
+	"sort"
+)
+
+// xorSlots is the number of fingerprints probed per entry.
+const xorSlots = 3
+
+// xorMaxAttempts bounds the seeds tried by BuildXor, each of which succeeds with a high probability.
+const xorMaxAttempts = 100
+
+var XorBuildErr = fmt.Errorf("failed to build the xor filter")
+
+// Xor returns whether the bloom filter is replaced by the fingerprints of a xor filter.
+func (h Header) Xor() bool {
+	return h.Flags&FlagXor != 0
+}
+
+// DiskXorFilter is a disk-based xor filter of 8-bit fingerprints (Graf and Lemire), built from a finished key set.
+// It takes about 9.84 bits per entry for the false positive rate 0.39%, far less than a classic Bloom filter,
+// and a lookup probes 3 bytes. It is static: the file is sealed once built.
+type DiskXorFilter struct {
+	disk *DiskFilter
+}
+
+// BuildXor builds a xor filter of keys into a new file.
+// GetParam of the controller is only used for the hash and the metadata, since the parameters are decided by the keys.
+func BuildXor(filename string, controller Controller, keys [][]byte) (*DiskXorFilter, error) {
+	if _, err := os.Stat(filename); err == nil {
+		return nil, fmt.Errorf("%w: %v", os.ErrExist, filename)
+	}
+	param, _ := controller.GetParam(nil)
+	if err := param.resolveHash(); err != nil {
+		return nil, err
+	}
+	hashes := make([]uint64, len(keys))
+	for i, key := range keys {
+		hashes[i], _ = param.Hash(key)
+	}
+	seed, fingerprints, err := buildXor(hashes)
+	if err != nil {
+		return nil, err
+	}
+	getParam := controller.GetParam
+	controller.GetParam = func(b []byte) (FilterParam, []byte) {
+		param, metadata := getParam(b)
+		param.Slots, param.Bits = xorSlots, uint64(len(fingerprints))*8
+		return param, metadata
+	}
+	disk, err := open(filename, controller, variantXor)
+	if err != nil {
+		return nil, err
+	}
+	if err = disk.writeXor(seed, fingerprints); err != nil {
+		_ = disk.Close()
+		_ = os.Remove(filename)
+		return nil, err
+	}
+	return &DiskXorFilter{disk: disk}, nil
+}
+
+// NewXor opens a xor filter built by BuildXor.
+// GetParam of the controller is only used for the hash.
+func NewXor(filename string, controller Controller) (*DiskXorFilter, error) {
+	disk, err := open(filename, controller, variantXor)
+	if err != nil {
+		return nil, err
+	}
+	return &DiskXorFilter{disk: disk}, nil
+}
+
+// writeXor writes the fingerprints and the seed, and seals the file.
+func (f *DiskFilter) writeXor(seed uint64, fingerprints []byte) error {
+	f.file.mu.Lock()
+	if _, err := f.file.rw.WriteAt(fingerprints, f.fileOffset(0)); err != nil {
+		f.file.mu.Unlock()
+		return err
+	}
+	f.checksums.markDirty(0, int64(len(fingerprints)))
+	if err := f.writeSignedLocked(func(image []byte) {
+		binary.LittleEndian.PutUint64(image[LenOfMetadataSize+int(f.controller.MetadataSize)+headerSeedOffset:], seed)
+	}); err != nil {
+		f.file.mu.Unlock()
+		return err
+	}
+	f.header.seed = seed
+	f.file.modified = true
+	f.file.mu.Unlock()
+	return f.Seal()
+}
+
+// Len returns the number of fingerprints.
+func (x *DiskXorFilter) Len() uint64 {
+	return x.disk.header.fingerprints
+}
+
+// Header returns the header of the filter file.
+func (x *DiskXorFilter) Header() Header {
+	return x.disk.Header()
+}
+
+// Close should be invoked if the filter is not needed anymore
+func (x *DiskXorFilter) Close() error {
+	return x.disk.Close()
+}
+
+// Exist returns if an entry is in the filter
+func (x *DiskXorFilter) Exist(b []byte) bool {
+	exist, _ := x.ExistErr(b)
+	return exist
+}
+
+// ExistErr is like Exist, but returns the error if the filter failed to be read,
+// instead of reporting the entry as not in.
+func (x *DiskXorFilter) ExistErr(b []byte) (bool, error) {
+	f := x.disk
+	if !f.acquire() {
+		return false, ClosedErr
+	}
+	defer f.release()
+	key, _ := f.param.Hash(b)
+	h := xorMix(key + f.header.seed)
+	indexes := xorIndexes(h, f.header.fingerprints/3)
+	positions := make([]int64, len(indexes))
+	for i, index := range indexes {
+		positions[i] = f.fileOffset(int64(index))
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i] < positions[j]
+	})
+	var vals map[int64]byte
+	var err error
+	f.phase("io", func() {
+		f.rlock()
+		vals, _, err = f.readBatchLocked(positions)
+		f.file.mu.RUnlock()
+	})
+	if err != nil {
+		return false, err
+	}
+	var fp byte
+	for _, index := range indexes {
+		fp ^= vals[f.fileOffset(int64(index))]
+	}
+	return fp == xorFingerprint(h), nil
+}
+
+// ExistOrAdd is Exist, since a xor filter is static. It is for the Filter interface.
+func (x *DiskXorFilter) ExistOrAdd(b []byte) bool {
+	return x.Exist(b)
+}
+
+// xorMix is the finalizer of MurmurHash3, which mixes the seed into the hash of a key.
+func xorMix(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+func xorFingerprint(h uint64) byte {
+	return byte(h ^ h>>32)
+}
+
+// xorIndexes returns the index of the fingerprint in each of the 3 blocks of blockLength.
+func xorIndexes(h uint64, blockLength uint64) [xorSlots]uint64 {
+	var indexes [xorSlots]uint64
+	for i := range indexes {
+		// Lemire's fast range of a 32-bit value, whose product with blockLength fits in 64 bits
+		indexes[i] = uint64(uint32(bits.RotateLeft64(h, 21*i)))*blockLength>>32 + uint64(i)*blockLength
+	}
+	return indexes
+}
+
+// buildXor returns the seed and the fingerprints of a xor filter of the hashes.
+func buildXor(hashes []uint64) (seed uint64, fingerprints []byte, err error) {
+	// duplicates can not be peeled
+	sort.Slice(hashes, func(i, j int) bool {
+		return hashes[i] < hashes[j]
+	})
+	n := 0
+	for i, h := range hashes {
+		if i == 0 || h != hashes[n-1] {
+			hashes[n] = h
+			n++
+		}
+	}
+	hashes = hashes[:n]
+	capacity := uint64(32 + 1.23*float64(len(hashes)))
+	blockLength := capacity / 3
+	capacity = blockLength * 3
+
+	type set struct {
+		mask  uint64
+		count uint32
+	}
+	type peeled struct {
+		index uint64
+		h     uint64
+	}
+	sets := make([]set, capacity)
+	queue := make([]uint64, 0, capacity)
+	stack := make([]peeled, 0, len(hashes))
+	for attempt := 0; attempt < xorMaxAttempts; attempt++ {
+		seed = xorMix(uint64(attempt) + 0x9e3779b97f4a7c15)
+		for i := range sets {
+			sets[i] = set{}
+		}
+		for _, key := range hashes {
+			h := xorMix(key + seed)
+			for _, index := range xorIndexes(h, blockLength) {
+				sets[index].mask ^= h
+				sets[index].count++
+			}
+		}
+		queue, stack = queue[:0], stack[:0]
+		for i := range sets {
+			if sets[i].count == 1 {
+				queue = append(queue, uint64(i))
+			}
+		}
+		for len(queue) > 0 {
+			index := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			if sets[index].count != 1 {
+				continue
+			}
+			h := sets[index].mask
+			stack = append(stack, peeled{index: index, h: h})
+			for _, i := range xorIndexes(h, blockLength) {
+				sets[i].mask ^= h
+				sets[i].count--
+				if sets[i].count == 1 {
+					queue = append(queue, i)
+				}
+			}
+		}
+		if len(stack) < len(hashes) {
+			continue
+		}
+		fingerprints = make([]byte, capacity)
+		for i := len(stack) - 1; i >= 0; i-- {
+			p := stack[i]
+			fp := xorFingerprint(p.h)
+			for _, index := range xorIndexes(p.h, blockLength) {
+				if index != p.index {
+					fp ^= fingerprints[index]
+				}
+			}
+			fingerprints[p.index] = fp
+		}
+		return seed, fingerprints, nil
+	}
+	return 0, nil, fmt.Errorf("%w: no seed peels the keys after %v attempts", XorBuildErr, xorMaxAttempts)
+}
@@ -0,0 +1,67 @@
+package disk_bloom
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestBuildXor(t *testing.T) {
+	defer os.Remove("testfile")
+	controller := Controller{
+		MetadataSize: 8,
+		GetParam: func(metadata []byte) (FilterParam, []byte) {
+			return FilterParam{Hash: doubleFNV}, nil
+		},
+	}
+	var keys [][]byte
+	for i := 0; i < 10000; i++ {
+		keys = append(keys, []byte(strconv.Itoa(i)))
+	}
+	// duplicates are allowed
+	keys = append(keys, []byte("0"), []byte("1"))
+	x, err := BuildXor("testfile", controller, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bitsPerEntry := float64(x.Len()*8) / 10000; bitsPerEntry > 10 {
+		t.Fatalf("Got %v bits per entry", bitsPerEntry)
+	}
+	for _, key := range keys {
+		if !x.Exist(key) {
+			t.Fatalf("%s should exist in filter", key)
+		}
+	}
+	x.Close()
+	if _, err = BuildXor("testfile", controller, keys); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("got %v, want ErrExist", err)
+	}
+	if _, err = New("testfile", controller); !errors.Is(err, InvalidHeaderErr) {
+		t.Fatalf("got %v, want InvalidHeaderErr", err)
+	}
+
+	x, err = NewXor("testfile", controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer x.Close()
+	if h := x.Header(); !h.Xor() || !h.Sealed() {
+		t.Fatalf("Should be a sealed xor filter, got %+v", h)
+	}
+	for _, key := range keys {
+		if !x.Exist(key) {
+			t.Fatalf("%s should exist in filter", key)
+		}
+	}
+	falsePositives := 0
+	for i := 10000; i < 110000; i++ {
+		if x.Exist([]byte(strconv.Itoa(i))) {
+			falsePositives++
+		}
+	}
+	// 1/256
+	if falsePositives > 500 {
+		t.Fatalf("Too many false positives: %v", falsePositives)
+	}
+}